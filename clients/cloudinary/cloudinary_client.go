@@ -0,0 +1,29 @@
+package cloudinary_client
+
+import (
+	"stockbackend/config"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"go.uber.org/zap"
+)
+
+// Client archives the uploaded files, it is shared by every request and nil when Cloudinary isn't configured
+var Client *cloudinary.Cloudinary
+
+func init() {
+	Client = New(config.Get().CloudinaryURL)
+}
+
+// New creates a Cloudinary client from its URL, nil when the URL is unset or invalid
+func New(url string) *cloudinary.Cloudinary {
+	if url == "" {
+		zap.L().Warn("CLOUDINARY_URL is not set, uploaded files won't be archived")
+		return nil
+	}
+	cld, err := cloudinary.NewFromURL(url)
+	if err != nil {
+		zap.L().Error("Invalid CLOUDINARY_URL, uploaded files won't be archived", zap.Error(err))
+		return nil
+	}
+	return cld
+}
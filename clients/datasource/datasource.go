@@ -0,0 +1,104 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// DataSource is a provider of company financials. Company documents are returned in the shape
+// stored by the enrichment pipeline (see helpers.ScrapedFieldsUpdate), peers as peer table rows.
+type DataSource interface {
+	// Search returns the companies matching a name, best match first
+	Search(ctx context.Context, name string) ([]types.Company, error)
+	// FetchCompany returns the fundamentals of the company at a URL returned by Search, with the
+	// provider ID of its peers under helpers.PeersIDKey when it has one
+	FetchCompany(ctx context.Context, url string) (map[string]interface{}, error)
+	// FetchPeers returns the peer table of a company by its provider ID
+	FetchPeers(ctx context.Context, id string) ([]map[string]string, error)
+}
+
+// FetchCompanyWithPeers fetches the company at url from source and, unless FETCH_PEERS=false, its
+// peers by the ID the company came back with. Peers that fail to fetch are left out, the company is
+// still returned.
+func FetchCompanyWithPeers(ctx context.Context, source DataSource, url string) (map[string]interface{}, error) {
+	data, err := source.FetchCompany(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := data[helpers.PeersIDKey].(string)
+	// FETCH_PEERS=false skips the round trip to the peers API, and its pause
+	if !ok || id == "" || !config.Get().FetchPeers {
+		return data, nil
+	}
+	peers, err := source.FetchPeers(ctx, id)
+	if err != nil {
+		helpers.Logger(ctx).Warn("Error fetching the peers", zap.String("url", url), zap.String("id", id), zap.Error(err))
+		return data, nil
+	}
+	data["peers"] = peers
+	return data, nil
+}
+
+// DefaultSource is the provider used when DATA_SOURCE is unset
+const DefaultSource = "screener"
+
+var (
+	mu      sync.RWMutex
+	sources = map[string]func() DataSource{
+		DefaultSource: func() DataSource { return &screenerSource{} },
+	}
+
+	currentOnce sync.Once
+	current     DataSource
+)
+
+// Register makes a provider selectable by name through DATA_SOURCE
+func Register(name string, factory func() DataSource) {
+	mu.Lock()
+	defer mu.Unlock()
+	sources[strings.ToLower(name)] = factory
+}
+
+// New creates the provider registered under name
+func New(name string) (DataSource, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	factory, ok := sources[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		names := make([]string, 0, len(sources))
+		for registered := range sources {
+			names = append(names, registered)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown data source %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return factory(), nil
+}
+
+// Current returns the provider selected by DATA_SOURCE, created on first use. An unknown
+// name is logged and falls back to the default screener source.
+func Current() DataSource {
+	currentOnce.Do(func() {
+		source, err := New(config.Get().DataSource)
+		if err != nil {
+			zap.L().Error("Error selecting data source, using the default", zap.String("default", DefaultSource), zap.Error(err))
+			source, _ = New(DefaultSource)
+		}
+		current = source
+	})
+	return current
+}
+
+// Set replaces the provider Current returns, e.g. with a fake in tests
+func Set(source DataSource) {
+	currentOnce.Do(func() {})
+	current = source
+}
@@ -0,0 +1,115 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"testing"
+)
+
+type staticSource struct{}
+
+func (s *staticSource) Search(ctx context.Context, name string) ([]types.Company, error) {
+	return []types.Company{{Name: name, URL: "static://" + name}}, nil
+}
+
+func (s *staticSource) FetchCompany(ctx context.Context, url string) (map[string]interface{}, error) {
+	return map[string]interface{}{"stockPE": "12"}, nil
+}
+
+func (s *staticSource) FetchPeers(ctx context.Context, id string) ([]map[string]string, error) {
+	return nil, nil
+}
+
+func TestNew(t *testing.T) {
+	source, err := New("")
+	if err == nil {
+		t.Errorf("Expected an error for an empty name, got %T", source)
+	}
+	if source, err := New(" Screener "); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if _, ok := source.(*screenerSource); !ok {
+		t.Errorf("Expected the screener source, got %T", source)
+	}
+	if _, err := New("unknown"); err == nil || err.Error() != `unknown data source "unknown", expected one of screener` {
+		t.Errorf("Expected the registered names in the error, got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("Static", func() DataSource { return &staticSource{} })
+	defer func() {
+		mu.Lock()
+		delete(sources, "static")
+		mu.Unlock()
+	}()
+
+	source, err := New("static")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	results, err := source.Search(context.Background(), "Infosys")
+	if err != nil || len(results) != 1 || results[0].URL != "static://Infosys" {
+		t.Errorf("Expected the registered source to be used, got %v (%v)", results, err)
+	}
+}
+
+// peerSource returns a company with the peers ID id, counting the peer fetches. Fetching the
+// peers fails with err.
+type peerSource struct {
+	staticSource
+	id      string
+	err     error
+	fetched []string
+}
+
+func (s *peerSource) FetchCompany(ctx context.Context, url string) (map[string]interface{}, error) {
+	data := map[string]interface{}{"stockPE": "12"}
+	if s.id != "" {
+		data[helpers.PeersIDKey] = s.id
+	}
+	return data, nil
+}
+
+func (s *peerSource) FetchPeers(ctx context.Context, id string) ([]map[string]string, error) {
+	s.fetched = append(s.fetched, id)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []map[string]string{{"name": "Infosys"}}, nil
+}
+
+func TestFetchCompanyWithPeers(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+
+	tests := []struct {
+		name       string
+		source     *peerSource
+		fetchPeers string
+		fetched    int
+		peers      bool
+	}{
+		{"peers", &peerSource{id: "6599230"}, "true", 1, true},
+		{"FETCH_PEERS=false", &peerSource{id: "6599230"}, "false", 0, false},
+		{"no peers ID", &peerSource{}, "true", 0, false},
+		{"peers failing", &peerSource{id: "6599230", err: errors.New("rate limited")}, "true", 1, false},
+	}
+
+	for _, test := range tests {
+		config.Set(config.FromMap(map[string]string{"FETCH_PEERS": test.fetchPeers}))
+		data, err := FetchCompanyWithPeers(context.Background(), test.source, "static://Infosys")
+		if err != nil || data["stockPE"] != "12" {
+			t.Errorf("%s: expected the company, got %v (%v)", test.name, data, err)
+			continue
+		}
+		if len(test.source.fetched) != test.fetched || (test.fetched > 0 && test.source.fetched[0] != "6599230") {
+			t.Errorf("%s: expected %d peer fetches by the peers ID, got %v", test.name, test.fetched, test.source.fetched)
+		}
+		if _, ok := data["peers"]; ok != test.peers {
+			t.Errorf("%s: expected peers %v, got %v", test.name, test.peers, data["peers"])
+		}
+	}
+}
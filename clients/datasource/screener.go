@@ -0,0 +1,56 @@
+package datasource
+
+import (
+	"context"
+	"stockbackend/clients/http_client"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// screenerSource scrapes screener's search API, company pages and peers API (COMPANY_URL)
+type screenerSource struct{}
+
+// Search returns the search results with their company page URLs made absolute, the search
+// API answers with paths such as "/company/TCS/consolidated/"
+func (s *screenerSource) Search(ctx context.Context, name string) ([]types.Company, error) {
+	results, err := http_client.SearchCompany(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if strings.HasPrefix(results[i].URL, "/") {
+			results[i].URL = strings.TrimSuffix(config.Get().CompanyURL, "/") + results[i].URL
+		}
+	}
+	return results, nil
+}
+
+// FetchCompany scrapes a company page, recording the basis of its statements. A consolidated page
+// without the statements the F-Score needs (companies without subsidiaries) gets the statements of
+// the standalone page stored alongside under "standalone".
+func (s *screenerSource) FetchCompany(ctx context.Context, url string) (map[string]interface{}, error) {
+	data, err := helpers.FetchCompanyData(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	data["statementBasis"] = helpers.StatementBasis(url)
+	if data["statementBasis"] != helpers.StatementsConsolidated || len(helpers.FScoreStatementGaps(data)) == 0 {
+		return data, nil
+	}
+
+	standalone, err := helpers.FetchCompanyData(ctx, helpers.StandaloneURL(url))
+	if err != nil {
+		helpers.Logger(ctx).Warn("Error fetching the standalone statements", zap.String("url", url), zap.Error(err))
+		return data, nil
+	}
+	data["standalone"] = helpers.StatementTables(standalone)
+	return data, nil
+}
+
+func (s *screenerSource) FetchPeers(ctx context.Context, id string) ([]map[string]string, error) {
+	return helpers.FetchPeerData(ctx, id)
+}
@@ -0,0 +1,332 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"stockbackend/clients/http_client"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"strings"
+	"testing"
+)
+
+// screenerFixtures maps the screener paths onto the recorded responses in testdata/screener
+var screenerFixtures = map[string]string{
+	"/api/company/search/":        "search.json",
+	"/company/TCS/consolidated/":  "company.html",
+	"/api/company/6599230/peers/": "peers.html",
+	// A company without subsidiaries: empty consolidated statements, the standalone page has them
+	"/company/EXAMPLE/consolidated/": "company_no_subsidiaries.html",
+	"/company/EXAMPLE/":              "company.html",
+	// Company pages without the warehouse id element, one still has the peers API URL in a script
+	"/company/NOID/":     "company_no_warehouse_id.html",
+	"/company/SCRIPTID/": "company_script_warehouse_id.html",
+	// Responses after a redesign, without the elements the parser looks for
+	"/company/REDESIGNED/":   "company_redesigned.html",
+	"/api/company/42/peers/": "peers_redesigned.html",
+}
+
+// screenerStatuses are the paths answering with an error status
+var screenerStatuses = map[string]int{
+	"/company/BUSY/":         http.StatusTooManyRequests,
+	"/api/company/43/peers/": http.StatusTooManyRequests,
+	"/company/DOWN/":         http.StatusServiceUnavailable,
+}
+
+// newScreenerServer serves the recorded screener responses and points COMPANY_URL at them,
+// the paths of screenerStatuses answer their status, unknown paths answer 404 and searches for anything but Tata find nothing
+func newScreenerServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/company/search/" && !strings.Contains(r.URL.Query().Get("q"), "Tata") {
+			w.Write([]byte(`[]`))
+			return
+		}
+		if status, ok := screenerStatuses[r.URL.Path]; ok {
+			w.WriteHeader(status)
+			return
+		}
+		fixture, ok := screenerFixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := os.ReadFile(filepath.Join("testdata", "screener", fixture))
+		if err != nil {
+			t.Errorf("Error reading fixture %s: %v", fixture, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	previous := config.Get()
+	config.Set(config.FromMap(map[string]string{"COMPANY_URL": server.URL}))
+	t.Cleanup(func() { config.Set(previous) })
+	return server
+}
+
+func TestScreenerSource_Search(t *testing.T) {
+	server := newScreenerServer(t)
+
+	results, err := (&screenerSource{}).Search(context.Background(), "Tata Consultancy Services Limited")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []types.Company{
+		{ID: 3365, Name: "Tata Consultancy Services Ltd", URL: server.URL + "/company/TCS/consolidated/"},
+		{ID: 3366, Name: "Tata Consumer Products Ltd", URL: server.URL + "/company/TATACONSUM/consolidated/"},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, got %v", expected, results)
+	}
+}
+
+func TestScreenerSource_SearchNoMatch(t *testing.T) {
+	newScreenerServer(t)
+
+	results, err := (&screenerSource{}).Search(context.Background(), "Unheard Of Industries Limited")
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected no results, got %v (%v)", results, err)
+	}
+}
+
+func TestScreenerSource_FetchPeers(t *testing.T) {
+	newScreenerServer(t)
+
+	peers, err := (&screenerSource{}).FetchPeers(context.Background(), "6599230")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(peers) != 4 {
+		t.Fatalf("Expected 3 peers and the median, got %v", peers)
+	}
+	expectedInfosys := map[string]string{
+		"name":           "Infosys",
+		"current_price":  "1843.35",
+		"pe":             "28.17",
+		"market_cap":     "765217.06",
+		"div_yield":      "2.05",
+		"np_qtr":         "6368.00",
+		"qtr_profit_var": "7.10",
+		"sales_qtr":      "39315.00",
+		"qtr_sales_var":  "3.60",
+		"roce":           "39.99",
+	}
+	if !reflect.DeepEqual(peers[1], expectedInfosys) {
+		t.Errorf("Expected %v, got %v", expectedInfosys, peers[1])
+	}
+	if median := peers[3]; median["company_count"] != "Median: 3 Co." || median["pe"] != "28.4" || median["roce"] != "39.99" {
+		t.Errorf("Unexpected median row: %v", median)
+	}
+}
+
+func TestScreenerSource_FetchCompany(t *testing.T) {
+	server := newScreenerServer(t)
+
+	data, err := FetchCompanyWithPeers(context.Background(), &screenerSource{}, server.URL+"/company/TCS/consolidated/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ratios := map[string]string{
+		"Market Cap":     "14,78,954",
+		"Current Price":  "4,087",
+		"High / Low":     "4,592/3,311",
+		"Stock P/E":      "30.8",
+		"Dividend Yield": "1.79",
+		"ROCE":           "64.3",
+	}
+	for key, value := range ratios {
+		if data[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, data[key])
+		}
+	}
+	if pros, cons := data["pros"].([]string), data["cons"].([]string); len(pros) != 2 || len(cons) != 1 || cons[0] != "Stock is trading at 15.6 times its book value" {
+		t.Errorf("Unexpected pros and cons: %v, %v", pros, cons)
+	}
+	if insights, _ := data["consInsights"].([]types.Insight); len(insights) != 1 || insights[0].Category != helpers.InsightValuation || insights[0].Value == nil || *insights[0].Value != 15.6 {
+		t.Errorf("Expected the con classified as a valuation at 15.6 times, got %+v", insights)
+	}
+
+	quarters := data["quarterlyResults"].(map[string][]map[string]string)
+	expectedSales := []map[string]string{{"Dec 2023": "60,583"}, {"Mar 2024": "61,237"}, {"Jun 2024": "62,613"}}
+	if len(quarters) != 3 || !reflect.DeepEqual(quarters["Sales\u00a0+"], expectedSales) {
+		t.Errorf("Expected the quarters table only, got %v", quarters)
+	}
+
+	if headers := data["profitLossHeaders"].([]string); !reflect.DeepEqual(headers, []string{"Mar 2023", "Mar 2024", "TTM"}) {
+		t.Errorf("Unexpected profit & loss headers: %v", headers)
+	}
+	balanceSheet := data["balanceSheet"].([]types.TableRow)
+	if len(balanceSheet) != 6 || len(balanceSheet[2].Children) != 1 || balanceSheet[2].Children[0].Label != "Lease Liabilities" {
+		t.Errorf("Expected borrowings with their lease liabilities, got %v", balanceSheet)
+	}
+	if cashFlows := data["cashFlows"].([]types.TableRow); len(cashFlows) != 1 || cashFlows[0].Values[1] != "44,338" {
+		t.Errorf("Unexpected cash flows: %v", cashFlows)
+	}
+	growth := helpers.GrowthSummary{
+		"compoundedSalesGrowth":  {"10yr": 13, "5yr": 11, "3yr": 13, "1yr": 6},
+		"compoundedProfitGrowth": {"10yr": 11, "5yr": 10, "3yr": 9, "1yr": 9},
+		"stockPriceCagr":         {"10yr": 12, "5yr": 14, "3yr": 3, "1yr": -2},
+		"returnOnEquity":         {"10yr": 41, "5yr": 45, "3yr": 48, "1yr": 51},
+	}
+	if !reflect.DeepEqual(data["growthSummary"], growth) {
+		t.Errorf("Expected %v, got %v", growth, data["growthSummary"])
+	}
+	series := data["ratioSeries"].(helpers.RatioSeries)
+	if cycle := series.Series["cashConversionCycle"]; !reflect.DeepEqual(series.Periods, []string{"Mar 2023", "Mar 2024"}) || len(cycle) != 2 || *cycle[0] != 70 || *cycle[1] != 66 {
+		t.Errorf("Unexpected ratio series: %+v", series)
+	}
+	if inventory := series.Series["inventoryDays"]; len(inventory) != 2 || inventory[0] != nil || *series.Series["roce"][1] != 64 {
+		t.Errorf("Expected blank inventory days and a parsed ROCE %%, got %+v", series.Series)
+	}
+	if trend := helpers.AnalyzeWorkingCapital(series); trend.Trend != helpers.TrendDecreasing || trend.Change != -4 {
+		t.Errorf("Expected an improving cash conversion cycle, got %+v", trend)
+	}
+	if shareholding := data["shareholdingPattern"].(map[string]interface{}); shareholding["quarterly"] == nil {
+		t.Errorf("Expected the quarterly shareholding pattern, got %v", shareholding)
+	}
+	if peers := data["peers"].([]map[string]string); len(peers) != 4 || peers[0]["name"] != "TCS" {
+		t.Errorf("Expected the peers to be fetched from the warehouse id, got %v", data["peers"])
+	}
+	if data["sector"] != "it - software" {
+		t.Errorf("Expected the sector above the peers table, got %v", data["sector"])
+	}
+	if data["statementBasis"] != helpers.StatementsConsolidated || data["standalone"] != nil {
+		t.Errorf("Expected the consolidated statements only, got %v and %v", data["statementBasis"], data["standalone"])
+	}
+	if fScore := helpers.GenerateFScore(data); fScore == helpers.FScoreNotComputable {
+		t.Errorf("Expected an F-Score from the consolidated statements")
+	}
+}
+
+func TestScreenerSource_FetchCompanyStandaloneOnly(t *testing.T) {
+	server := newScreenerServer(t)
+
+	data, err := (&screenerSource{}).FetchCompany(context.Background(), server.URL+"/company/EXAMPLE/consolidated/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	standalone, ok := data["standalone"].(map[string]interface{})
+	if !ok || standalone["balanceSheet"] == nil || standalone["profitLossHeaders"] == nil {
+		t.Fatalf("Expected the standalone statements to be stored alongside, got %v", data["standalone"])
+	}
+
+	breakdown := helpers.GenerateFScoreBreakdown(data)
+	if breakdown.Score == helpers.FScoreNotComputable || breakdown.Statements != helpers.StatementsStandalone {
+		t.Errorf("Expected an F-Score from the standalone statements, got %+v", breakdown)
+	}
+}
+
+func TestScreenerSource_FetchCompanyStandaloneDebugHTML(t *testing.T) {
+	server := newScreenerServer(t)
+	dir := t.TempDir()
+	config.Set(config.FromMap(map[string]string{
+		"COMPANY_URL":      server.URL,
+		"DEBUG_STORE_HTML": "true",
+		"DEBUG_HTML_DIR":   dir,
+	}))
+
+	data, err := (&screenerSource{}).FetchCompany(context.Background(), server.URL+"/company/EXAMPLE/consolidated/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	consolidated := filepath.Join(dir, "EXAMPLE_consolidated.html")
+	if data["debugHtml"] != consolidated {
+		t.Errorf("Expected the consolidated page at %s, got %v", consolidated, data["debugHtml"])
+	}
+	for path, fixture := range map[string]string{
+		consolidated:                       "company_no_subsidiaries.html",
+		filepath.Join(dir, "EXAMPLE.html"): "company.html",
+	} {
+		stored, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", path, err)
+		}
+		expected, _ := os.ReadFile(filepath.Join("testdata", "screener", fixture))
+		if string(stored) != string(expected) {
+			t.Errorf("Expected %s to hold %s", path, fixture)
+		}
+	}
+}
+
+func TestScreenerSource_FetchCompanyWarehouseID(t *testing.T) {
+	server := newScreenerServer(t)
+	source := &screenerSource{}
+
+	data, err := FetchCompanyWithPeers(context.Background(), source, server.URL+"/company/SCRIPTID/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if peers, ok := data["peers"].([]map[string]string); !ok || len(peers) != 4 || data["peersUnavailable"] != false {
+		t.Errorf("Expected the peers fetched by the warehouse id of the script, got %v %v", data["peers"], data["peersUnavailable"])
+	}
+
+	data, err = FetchCompanyWithPeers(context.Background(), source, server.URL+"/company/NOID/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := data["peers"]; ok || data["peersUnavailable"] != true {
+		t.Errorf("Expected the peers to be flagged unavailable, got %v %v", data["peers"], data["peersUnavailable"])
+	}
+	if report := helpers.NewScrapeDiagnostics("", data); report.Peers != helpers.PeersUnavailable {
+		t.Errorf("Expected the diagnostics to report the peers %s, got %s", helpers.PeersUnavailable, report.Peers)
+	}
+}
+
+func TestScreenerSource_ErrorTypes(t *testing.T) {
+	server := newScreenerServer(t)
+	source := &screenerSource{}
+
+	tests := []struct {
+		name      string
+		fetch     func() error
+		expected  error
+		retryable bool
+	}{
+		{"company not found", func() error {
+			_, err := source.FetchCompany(context.Background(), server.URL+"/company/UNKNOWN/")
+			return err
+		}, http_client.ErrNotFound, false},
+		{"company rate limited", func() error {
+			_, err := source.FetchCompany(context.Background(), server.URL+"/company/BUSY/")
+			return err
+		}, http_client.ErrRateLimited, true},
+		{"company server error", func() error {
+			_, err := source.FetchCompany(context.Background(), server.URL+"/company/DOWN/")
+			return err
+		}, http_client.ErrTransient, true},
+		{"company without the top ratios", func() error {
+			_, err := source.FetchCompany(context.Background(), server.URL+"/company/REDESIGNED/")
+			return err
+		}, http_client.ErrLayoutChanged, false},
+		{"peers not found", func() error {
+			_, err := source.FetchPeers(context.Background(), "41")
+			return err
+		}, http_client.ErrNotFound, false},
+		{"peers rate limited", func() error {
+			_, err := source.FetchPeers(context.Background(), "43")
+			return err
+		}, http_client.ErrRateLimited, true},
+		{"peers without a table", func() error {
+			_, err := source.FetchPeers(context.Background(), "42")
+			return err
+		}, http_client.ErrLayoutChanged, false},
+	}
+	for _, test := range tests {
+		err := test.fetch()
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, err)
+		}
+		if http_client.IsRetryable(err) != test.retryable {
+			t.Errorf("%s: expected retryable %v, got %v", test.name, test.retryable, err)
+		}
+	}
+}
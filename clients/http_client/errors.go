@@ -0,0 +1,50 @@
+package http_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Scraping failures, wrapped with %w so callers can tell with errors.Is whether retrying may help
+var (
+	// ErrNotFound means the page or company doesn't exist, retrying won't help
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited means the site asked us to slow down, retry later
+	ErrRateLimited = errors.New("rate limited")
+	// ErrLayoutChanged means the response no longer has the expected structure, the parser needs updating
+	ErrLayoutChanged = errors.New("page layout changed")
+	// ErrTransient covers network failures and server errors, retrying may help
+	ErrTransient = errors.New("transient failure")
+)
+
+// IsRetryable reports whether a scraping error may go away when the request is sent again
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTransient)
+}
+
+// StatusError classifies a non-200 response status, nil for 200. Statuses that fit no class
+// (e.g. 403) are returned unclassified.
+func StatusError(status int) error {
+	switch {
+	case status == http.StatusOK:
+		return nil
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return fmt.Errorf("%w: status code %d", ErrNotFound, status)
+	case status == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: status code %d", ErrRateLimited, status)
+	case status == http.StatusRequestTimeout || status >= 500:
+		return fmt.Errorf("%w: status code %d", ErrTransient, status)
+	}
+	return fmt.Errorf("unexpected status code %d", status)
+}
+
+// RequestError classifies the error of a request that got no response as transient, unless the
+// caller gave up on it (its context was canceled)
+func RequestError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrTransient, err)
+}
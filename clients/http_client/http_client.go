@@ -1,19 +1,23 @@
 package http_client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
+	"stockbackend/config"
 	"stockbackend/types"
 	"strings"
 
 	"go.uber.org/zap"
 )
 
-func SearchCompany(queryString string) ([]types.Company, error) {
+// SearchCompany queries the search API, its errors are classified like the other scraping errors
+// (see StatusError), an answer that isn't the expected JSON is ErrLayoutChanged. Cancelling ctx
+// cancels the request, also while it waits its turn on the host.
+func SearchCompany(ctx context.Context, queryString string) ([]types.Company, error) {
 	// Replace "corporation" with "Corpn" and "limited" with "Ltd"
 	queryString = strings.ReplaceAll(queryString, " Corporation ", " Corpn ")
 	queryString = strings.ReplaceAll(queryString, " corporation ", " Corpn ")
@@ -22,7 +26,7 @@ func SearchCompany(queryString string) ([]types.Company, error) {
 	queryString = strings.ReplaceAll(queryString, " and ", " & ")
 	queryString = strings.ReplaceAll(queryString, " And ", " & ")
 	// Base URL for the Screener API
-	baseURL := os.Getenv("COMPANY_URL") + "/api/company/search/"
+	baseURL := config.Get().CompanyURL + "/api/company/search/"
 
 	// Create the URL with query parameters
 	params := url.Values{}
@@ -31,45 +35,53 @@ func SearchCompany(queryString string) ([]types.Company, error) {
 	params.Add("fts", "1")
 
 	// Create the request
-	req, err := http.NewRequest("GET", baseURL+"?"+params.Encode(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"?"+params.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create the client and send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// Send the request through the shared scraper client
+	resp, err := ScraperClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error searching companies: %w", RequestError(err))
 	}
 	defer resp.Body.Close()
+	if err := StatusError(resp.StatusCode); err != nil {
+		return nil, fmt.Errorf("error searching companies: %w", err)
+	}
 
 	// Read the response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error reading search response: %w", RequestError(err))
 	}
 
 	var searchResponse []types.Company
 	err = json.Unmarshal(body, &searchResponse)
 	if err != nil {
 		zap.L().Error("Failed to unmarshal search response", zap.Error(err))
-		return nil, err
+		return nil, fmt.Errorf("%w: unexpected search response: %v", ErrLayoutChanged, err)
 	}
 
 	// Return the list of results
 	return searchResponse, nil
 }
 
-func GetCompanyPage(url string) (io.ReadCloser, error) {
-	resp, err := http.Get(url)
+// GetCompanyPage returns the body of the company page, the caller is responsible for closing it.
+// Errors are classified by StatusError and RequestError. Cancelling ctx cancels the request.
+func GetCompanyPage(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch the URL: %v", err)
+		return nil, fmt.Errorf("error creating request to the company page: %w", err)
+	}
+	resp, err := ScraperClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the URL: %w", RequestError(err))
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to retrieve the content, status code: %d", resp.StatusCode)
+	if err := StatusError(resp.StatusCode); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to retrieve the content: %w", err)
 	}
 
 	return resp.Body, nil
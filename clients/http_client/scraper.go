@@ -0,0 +1,112 @@
+package http_client
+
+import (
+	"context"
+	"net/http"
+	"stockbackend/clients/tracing"
+	"stockbackend/config"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const defaultUserAgent = "stock-backend/1.0 (+https://github.com/shivamsouravjha/stock-backend)"
+
+// ScraperClient is the shared client for all outbound scraping requests, it identifies
+// itself with SCRAPER_USER_AGENT and sends the usual browser Accept headers
+var ScraperClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: &politeTransport{base: http.DefaultTransport, limiter: &HostLimiter{}},
+}
+
+// UserAgent returns the User-Agent sent by the scrapers
+func UserAgent() string {
+	if userAgent := config.Get().ScraperUserAgent; userAgent != "" {
+		return userAgent
+	}
+	return defaultUserAgent
+}
+
+// HostLimiter spaces the requests sent to a host by a minimum interval, whichever goroutine sends
+// them. The zero value is ready to use.
+type HostLimiter struct {
+	mu sync.Mutex
+	// next is the earliest time the next request to a host may be sent
+	next map[string]time.Time
+}
+
+// Wait blocks until a request may be sent to the host, reserving its slot so concurrent callers
+// queue up interval apart. It returns early with the context's error when it is done.
+func (l *HostLimiter) Wait(ctx context.Context, host string, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.next == nil {
+		l.next = map[string]time.Time{}
+	}
+	now := time.Now()
+	slot := now
+	if next := l.next[host]; next.After(now) {
+		slot = next
+	}
+	l.next[host] = slot.Add(interval)
+	l.mu.Unlock()
+
+	wait := slot.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type politeTransport struct {
+	base    http.RoundTripper
+	limiter *HostLimiter
+}
+
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Every scraping request, search, company page or peers, waits its turn on the host
+	if err := t.limiter.Wait(req.Context(), req.URL.Host, config.Get().ScrapeMinInterval); err != nil {
+		return nil, err
+	}
+
+	// Every request gets a span of its own under the caller's, the trace headers carry it to the host
+	ctx, span := tracing.Start(req.Context(), "HTTP "+req.Method,
+		attribute.String("http.request.method", req.Method),
+		attribute.String("url.full", req.URL.String()),
+	)
+	defer span.End()
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent())
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/json;q=0.9,*/*;q=0.8")
+	}
+	if req.Header.Get("Accept-Language") == "" {
+		req.Header.Set("Accept-Language", "en-IN,en;q=0.9")
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	tracing.RecordError(span, StatusError(resp.StatusCode))
+	return resp, nil
+}
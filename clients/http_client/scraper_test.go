@@ -0,0 +1,226 @@
+package http_client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"stockbackend/config"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGetCompanyPage_SendsPoliteHeaders(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"SCRAPER_USER_AGENT": "test-agent/1.0"}))
+
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	body, err := GetCompanyPage(context.Background(), server.URL+"/company/TCS/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	io.ReadAll(body)
+	body.Close()
+
+	if received.Get("User-Agent") != "test-agent/1.0" {
+		t.Errorf("Expected the configured User-Agent, got %q", received.Get("User-Agent"))
+	}
+	if received.Get("Accept") == "" || received.Get("Accept-Language") == "" {
+		t.Errorf("Expected Accept and Accept-Language headers, got %v", received)
+	}
+}
+
+func TestSearchCompany_DefaultUserAgent(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	previous := config.Get()
+	config.Set(config.FromMap(map[string]string{"COMPANY_URL": server.URL}))
+	defer config.Set(previous)
+
+	if _, err := SearchCompany(context.Background(), "Tata Consultancy Services Limited"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if userAgent != defaultUserAgent {
+		t.Errorf("Expected %q, got %q", defaultUserAgent, userAgent)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusGone, ErrNotFound},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusBadGateway, ErrTransient},
+		{http.StatusRequestTimeout, ErrTransient},
+	}
+	for _, test := range tests {
+		if err := StatusError(test.status); !errors.Is(err, test.expected) {
+			t.Errorf("StatusError(%d): expected %v, got %v", test.status, test.expected, err)
+		}
+	}
+	if err := StatusError(http.StatusOK); err != nil {
+		t.Errorf("Expected no error for 200, got %v", err)
+	}
+	if err := StatusError(http.StatusForbidden); err == nil || IsRetryable(err) || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an unclassified error for 403, got %v", err)
+	}
+}
+
+func TestSearchCompany_ErrorTypes(t *testing.T) {
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`<html>Too many requests</html>`))
+	}))
+	defer server.Close()
+	previous := config.Get()
+	config.Set(config.FromMap(map[string]string{"COMPANY_URL": server.URL}))
+	defer config.Set(previous)
+
+	if _, err := SearchCompany(context.Background(), "Infosys Limited"); !errors.Is(err, ErrRateLimited) || !IsRetryable(err) {
+		t.Errorf("Expected a retryable rate limit, got %v", err)
+	}
+	status = http.StatusOK
+	if _, err := SearchCompany(context.Background(), "Infosys Limited"); !errors.Is(err, ErrLayoutChanged) {
+		t.Errorf("Expected a response that isn't JSON to be a layout change, got %v", err)
+	}
+
+	server.Close()
+	if _, err := SearchCompany(context.Background(), "Infosys Limited"); !errors.Is(err, ErrTransient) {
+		t.Errorf("Expected a connection failure to be transient, got %v", err)
+	}
+}
+
+func TestScraperClient_SpacesRequestsPerHost(t *testing.T) {
+	var sent []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, time.Now())
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	previous := config.Get()
+	config.Set(config.FromMap(map[string]string{"COMPANY_URL": server.URL, "SCRAPE_MIN_INTERVAL_SECONDS": "0.2"}))
+	defer config.Set(previous)
+
+	if _, err := SearchCompany(context.Background(), "Infosys Limited"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	body, err := GetCompanyPage(context.Background(), server.URL+"/company/INFY/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	body.Close()
+
+	if len(sent) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(sent))
+	}
+	if gap := sent[1].Sub(sent[0]); gap < 200*time.Millisecond {
+		t.Errorf("Expected the requests at least 200ms apart, got %v", gap)
+	}
+}
+
+func TestHostLimiter_Wait(t *testing.T) {
+	limiter := &HostLimiter{}
+	ctx := context.Background()
+	interval := 100 * time.Millisecond
+
+	start := time.Now()
+	limiter.Wait(ctx, "www.screener.in", interval)
+	// Another host has its own window
+	limiter.Wait(ctx, "www.nseindia.com", interval)
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Errorf("Expected the first request to each host right away, waited %v", elapsed)
+	}
+
+	limiter.Wait(ctx, "www.screener.in", interval)
+	if elapsed := time.Since(start); elapsed < interval {
+		t.Errorf("Expected the second request to wait %v, waited %v", interval, elapsed)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := limiter.Wait(canceled, "www.screener.in", interval); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+}
+
+func TestGetCompanyPage_TracesRequest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "FetchCompanyData")
+	_, err := GetCompanyPage(ctx, server.URL+"/company/GONE/")
+	parent.End()
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	traceID := parent.SpanContext().TraceID().String()
+	if !strings.Contains(traceparent, traceID) {
+		t.Errorf("Expected the trace %s in the traceparent header, got %q", traceID, traceparent)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "HTTP GET" || spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("Expected an HTTP GET span under the scrape's, got %v", spans)
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("Expected the 404 to fail the span, got %v", spans[0].Status())
+	}
+}
+
+func TestSearchCompany_Cancelled(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	previous := config.Get()
+	config.Set(config.FromMap(map[string]string{"COMPANY_URL": server.URL}))
+	defer config.Set(previous)
+
+	// A cancelled scrape, e.g. past the upload deadline, isn't sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SearchCompany(ctx, "Infosys Limited"); !errors.Is(err, context.Canceled) || IsRetryable(err) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request, got %d", requests)
+	}
+}
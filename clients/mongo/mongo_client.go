@@ -2,39 +2,84 @@ package mongo_client
 
 import (
 	"context"
-	"os"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+	"sync/atomic"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 	"go.uber.org/zap"
 	"gopkg.in/mgo.v2/bson"
 )
 
+// pingTimeout bounds each startup ping, so an unreachable Mongo is tried again rather than waited on
+const pingTimeout = 5 * time.Second
+
+// ErrConnecting is reported while the startup ping hasn't succeeded yet, ErrNotConfigured when
+// MONGO_URI couldn't be used to create a client
 var (
+	ErrConnecting    = helpers.ErrMongoConnecting
+	ErrNotConfigured = helpers.ErrMongoNotConfigured
+)
+
+var (
+	// Client is shared by every request, nil when MONGO_URI is unset or invalid
 	Client *mongo.Client
+
+	connected atomic.Bool
 )
 
+// Connected reports whether MongoDB answered the startup ping
+func Connected() bool {
+	return connected.Load()
+}
+
+// logRetry logs a failed ping before the next one
+func logRetry(attempt int, wait time.Duration, err error) {
+	zap.L().Warn("MongoDB not available, retrying", zap.Int("attempt", attempt), zap.Duration("wait", wait), zap.Error(err))
+}
+
 func init() {
-	zap.L().Info("MONGO_URI: ", zap.String("uri", os.Getenv("MONGO_URI")))
-	zap.L().Info("CLOUDINARY_URL", zap.String("uri", os.Getenv("CLOUDINARY_URL")))
+	cfg := config.Get()
+	zap.L().Info("MONGO_URI: ", zap.String("uri", cfg.MongoURI))
+	zap.L().Info("CLOUDINARY_URL", zap.String("uri", cfg.CloudinaryURL))
 
 	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-	mongoURI := os.Getenv("MONGO_URI")
+	mongoURI := cfg.MongoURI
 	// zap.L().Info("Mongo URI", zap.String("uri", mongoURI))
 	opts := options.Client().ApplyURI(mongoURI).SetServerAPIOptions(serverAPI)
+	// Every command gets a span, a no-op until tracing is initialized
+	opts.SetMonitor(otelmongo.NewMonitor())
 
-	// Create a new client and connect to the server
-	var err error // This is to ensure Client is not redeclared in the local scope
-	Client, err = mongo.Connect(context.TODO(), opts)
+	// Creating the client doesn't reach the server, it only fails on an invalid URI or options, which
+	// retrying can't fix. The API then starts without a client and /api/readyz reports it.
+	client, err := mongo.Connect(context.Background(), opts)
 	if err != nil {
-		panic(err)
+		zap.L().Error("Invalid MONGO_URI, MongoDB is unavailable", zap.Error(err))
+		return
 	}
+	Client = client
 
-	// Send a ping to confirm a successful connection
-	pingCmd := bson.M{"ping": 1}
-	if err := Client.Database("admin").RunCommand(context.TODO(), pingCmd).Err(); err != nil {
-		panic(err)
-	}
+	// Mongo may start after the API (e.g. in the same pod), the ping is retried with
+	// MONGO_CONNECT_ATTEMPTS and an exponential backoff before giving up. It waits for the server in
+	// the background, so the API starts and /api/readyz reports not ready until it answers. Giving up
+	// only logs the error, the API keeps running and /api/readyz keeps reporting not ready.
+	backoff := helpers.Backoff{Attempts: cfg.MongoConnectAttempts, Initial: cfg.MongoConnectBackoff, Max: cfg.MongoConnectMaxBackoff}
+	go func() {
+		pingCmd := bson.M{"ping": 1}
+		err := helpers.RetryWithBackoff(context.Background(), backoff, func(ctx context.Context) error {
+			pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+			defer cancel()
+			return Client.Database("admin").RunCommand(pingCtx, pingCmd).Err()
+		}, logRetry)
+		if err != nil {
+			zap.L().Error("Giving up connecting to MongoDB", zap.Int("attempts", backoff.Attempts), zap.Error(err))
+			return
+		}
 
-	zap.L().Info("Connected to MongoDB")
+		connected.Store(true)
+		zap.L().Info("Connected to MongoDB")
+	}()
 }
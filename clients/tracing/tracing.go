@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"stockbackend/config"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName  = "stockbackend"
+	serviceName = "stock-backend"
+)
+
+var provider *sdktrace.TracerProvider
+
+// Init exports the spans to the OTLP collector at OTEL_EXPORTER_OTLP_ENDPOINT. Without an
+// endpoint nothing is installed and every span is a no-op.
+func Init(ctx context.Context) error {
+	endpoint := config.Get().OTLPEndpoint
+	if endpoint == "" {
+		return nil
+	}
+
+	// Like the OpenTelemetry SDKs, the endpoint is the collector base URL the signal path is appended to
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/traces"))
+	if err != nil {
+		return fmt.Errorf("error creating OTLP exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating trace resource: %w", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return nil
+}
+
+// Shutdown flushes the pending spans, it does nothing when tracing is disabled
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
+// Start starts a span as a child of the span in ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks the span as failed with err, a nil error is ignored
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
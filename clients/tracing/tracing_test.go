@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"stockbackend/config"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestInit_DisabledWithoutEndpoint(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{}))
+
+	if err := Init(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if provider != nil {
+		t.Errorf("Expected no provider without an endpoint")
+	}
+	_, span := Start(context.Background(), "test")
+	defer span.End()
+	if span.IsRecording() {
+		t.Errorf("Expected a no-op span without an endpoint")
+	}
+	if err := Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected shutdown to be a no-op, got %v", err)
+	}
+}
+
+func TestInit_ExportsToEndpoint(t *testing.T) {
+	var exported atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" {
+			exported.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	previous := config.Get()
+	previousProvider := otel.GetTracerProvider()
+	defer func() {
+		config.Set(previous)
+		otel.SetTracerProvider(previousProvider)
+		provider = nil
+	}()
+	config.Set(config.FromMap(map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": server.URL + "/"}))
+
+	if err := Init(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ctx, parent := Start(context.Background(), "upload")
+	_, child := Start(ctx, "row")
+	if !child.IsRecording() {
+		t.Fatalf("Expected a recording span with an endpoint")
+	}
+	if child.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("Expected the child span to share the trace of its parent")
+	}
+	RecordError(child, errors.New("scrape failed"))
+	child.End()
+	parent.End()
+
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected no shutdown error, got %v", err)
+	}
+	if exported.Load() == 0 {
+		t.Errorf("Expected the spans to be exported to /v1/traces")
+	}
+}
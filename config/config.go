@@ -0,0 +1,490 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+)
+
+// Config is the typed application configuration, read once from the environment
+type Config struct {
+	Environment      string
+	Port             string
+	MongoURI         string
+	Database         string
+	Collection       string
+	CloudinaryURL    string
+	CompanyURL       string
+	SentryDSN        string
+	SentrySampleRate float64
+	// Bearer token of the admin endpoints, disabled when empty
+	AdminToken string
+	// Bearer token of the read and upload endpoints, open when empty
+	APIToken string
+
+	// Mongo connection at startup: tries before giving up, and the pause after the first failed
+	// try, doubling up to the max
+	MongoConnectAttempts   int
+	MongoConnectBackoff    time.Duration
+	MongoConnectMaxBackoff time.Duration
+
+	UploadsCollection     string
+	NameMapCollection     string
+	SectorBenchmarkWeight float64
+	SectorBenchmarkTTL    time.Duration
+	ShareholdingWeight    float64
+	WorkingCapitalWeight  float64
+	FuzzyMatchThreshold   float64
+	UploadWriteBatchSize  int
+	HighDebtToEquity      float64
+	HighDebtToAssets      float64
+	// Weight of the compounded sales and profit growth in the rating, and the growth (%) scoring as strong
+	GrowthWeight        float64
+	GrowthStrongPercent float64
+	// Weight of the balance of the pros and cons screener lists in the rating
+	ProsConsWeight float64
+	// Text search results compared per holding, the ones tied on the top score go to a tiebreaker
+	TextSearchCandidates int
+	// PE at or below the undervalued ratio of the peer median (or of its own historical median PE)
+	// reads as cheap, at or above the overvalued ratio as expensive
+	ValuationUndervaluedRatio float64
+	ValuationOvervaluedRatio  float64
+	// Stored data scraped longer ago is scraped again when an upload matches it, 0 never does
+	MaxDataAge time.Duration
+	// How reads of a company whose data is older than MaxDataAge revalidate it when the request
+	// doesn't say: async, sync or never
+	ReadRevalidate string
+	// Peer snapshots kept per company, oldest dropped first
+	PeerSnapshots int
+	// Dividend yields (%) outside the band don't score above peers, outlier-high yields are likely traps
+	DividendYieldMin float64
+	DividendYieldMax float64
+	// Absolute thresholds a stock is scored against when it has neither peers nor quarterly history
+	FundamentalsMaxPE   float64
+	FundamentalsMinROCE float64
+	// Bounds the stockRate is clamped into, the raw score is kept as stockRateRaw
+	StockRateMin float64
+	StockRateMax float64
+	// Decimal places computed numbers (scores, percentiles, ratios) are rounded to before they are
+	// stored or returned, 0 to 10
+	DecimalPlaces int
+	// How the numbers of scraped pages and uploaded sheets are written: us (default), indian, eu or auto
+	NumberFormat string
+	// How the formatted fields next to numeric ones are written: indian (default), us, eu, or none to leave them out
+	OutputNumberFormat string
+	// Exclude companies with unparseable financial cells from scoring instead of reading them as 0
+	StrictParsing bool
+	// Leave a trailing TTM column out of the quarterly trend, like the F-Score leaves it out of the yearly tables
+	TrendExcludeTTM bool
+	// Overrides of the trend metric directions read from the JSON object of TREND_METRIC_DIRECTIONS,
+	// e.g. {"Sales": 1, "Expenses": -1, "Depreciation": 0}. None when empty or invalid.
+	TrendMetricDirections map[string]float64
+	// Words marking a holding without an ISIN as a foreign stock (e.g. "Inc", "ADR"), matched whole
+	// and case insensitively
+	ForeignNameMarkers []string
+	// Holding rows whose market value and % of AUM columns look swapped are corrected: a % of AUM
+	// above SwapMaxAUMPercent, or a market value per unit held below SwapMinValuePerUnit while the
+	// % of AUM is larger than the market value
+	SwapMaxAUMPercent   float64
+	SwapMinValuePerUnit float64
+	// Merge the holdings of a sheet sharing an ISIN (share classes, partly paid shares) into one
+	AggregateByISIN bool
+	// Bulky fields (raw financial tables, peer tables) left out of responses unless ?fields= asks for them
+	ResponseDenylist []string
+	// JSON list of section labels to skip in holdings sheets, the embedded defaults when empty
+	SkipLabelsFile string
+	// Market cap categories, largest first, read from the JSON list of MARKET_CAP_TIERS, e.g.
+	// [{"name": "Large Cap", "minValue": 20000}]. The default SEBI tiers when empty or invalid.
+	MarketCapTiers []MarketCapTier
+	// Converts the scraped market cap (in crore) into the unit of MarketCapTiers, e.g. into USD millions
+	MarketCapUnitRate float64
+
+	// HTTP server timeouts, 0 disables one. Uploads replace the read and write timeouts with their
+	// own since their response streams for as long as the holdings take to enrich.
+	ServerReadHeaderTimeout time.Duration
+	ServerReadTimeout       time.Duration
+	ServerWriteTimeout      time.Duration
+	ServerIdleTimeout       time.Duration
+	UploadReadTimeout       time.Duration
+	UploadWriteTimeout      time.Duration
+	// Streams (uploads, ingests) write a heartbeat after this long without an entry, so proxies
+	// don't close them as idle while holdings are scraped. 0 disables it.
+	StreamHeartbeatInterval time.Duration
+
+	// Upload requests: total size, files per request and memory a multipart form may take before it
+	// spills to disk, set in MB for the sizes
+	MaxUploadSize      int64
+	MaxUploadFiles     int
+	MaxMultipartMemory int64
+	// Total size of the files inside an uploaded zip, set in MB
+	MaxZipUncompressedSize int64
+
+	// Multi-file uploads report every file as processed or failed, the upload fails when fewer than
+	// UploadMinProcessedFiles files were processed or, with UploadFailOnFileError, when any failed
+	UploadMinProcessedFiles int
+	UploadFailOnFileError   bool
+
+	// Uploads processed at once, an upload over the limit waits up to UploadQueueTimeout for its turn
+	// before it is rejected, at once when 0
+	MaxConcurrentUploads int
+	UploadQueueTimeout   time.Duration
+
+	// Time budget of a whole upload, none when 0: once it is spent no further holding is enriched
+	// and, with UploadDeadlineIngest, the holdings left are enriched in the background, at most
+	// UploadBacklogQueue uploads' of them queued at once
+	UploadDeadline       time.Duration
+	UploadDeadlineIngest bool
+	UploadBacklogQueue   int
+
+	// Resumable uploads: where their chunks are assembled, how long an idle session is kept and how
+	// many may be open at once
+	UploadSessionDir  string
+	UploadSessionTTL  time.Duration
+	MaxUploadSessions int
+
+	// Spreadsheets fetched from a URL: how long the download may take and whether hosts on
+	// private networks may be fetched (only for local testing, it opens the door to SSRF)
+	RemoteXLSXTimeout      time.Duration
+	RemoteXLSXAllowPrivate bool
+
+	// Background refresh of stale company documents
+	RefreshEnabled       bool
+	RefreshInterval      time.Duration
+	RefreshStaleAfter    time.Duration
+	RefreshRatePerMinute int
+	RefreshBatchSize     int64
+
+	// Minimum pause between two scraping requests to the same host, whatever sends them
+	ScrapeMinInterval time.Duration
+	// Retries of a company scrape failing with a retryable error (rate limited, network or server
+	// errors), with an exponential backoff
+	ScrapeRetryAttempts   int
+	ScrapeRetryBackoff    time.Duration
+	ScrapeRetryMaxBackoff time.Duration
+	// User-Agent the scrapers identify themselves with, the default one of the scraper when empty
+	ScraperUserAgent string
+
+	// Keep the raw HTML of scraped pages in DebugHTMLDir, and serve it on the debug endpoints
+	DebugStoreHTML bool
+	DebugEndpoints bool
+	DebugHTMLDir   string
+
+	// Bulk ingest of company names: names accepted per request, parallel scrapes and scrape rate
+	IngestMaxNames      int
+	IngestConcurrency   int
+	IngestRatePerMinute int
+
+	// Provider of the company financials, see the datasource package
+	DataSource string
+	// Fetch the peers of scraped companies, without them the rating leans on the F-Score instead
+	FetchPeers bool
+
+	// OpenTelemetry collector base URL, tracing is disabled when empty
+	OTLPEndpoint string
+}
+
+// defaultResponseDenylist are the raw tables a scrape stores, returned only when asked for
+const defaultResponseDenylist = "quarterlyResults,profitLoss,balanceSheet,cashFlows,ratios,ratioSeries,shareholdingPattern,peersTable,peers,peerSnapshots,debugHtml"
+
+// defaultForeignNameMarkers are the legal forms and receipts of foreign listings, rarely found in Indian company names
+const defaultForeignNameMarkers = "Inc,Inc.,Corp,Corp.,ADR,ADRs,GDR,PLC,N.V.,S.A."
+
+var (
+	once    sync.Once
+	current *Config
+)
+
+func init() {
+	// Runs before any package reading the configuration (e.g. the Mongo client) is initialized
+	if err := LoadEnvFiles(); err != nil {
+		zap.L().Error("Error loading config files", zap.Error(err))
+	}
+}
+
+// envFiles returns the config files in order of precedence: CONFIG_FILE, .env.<APP_ENV>, .env
+func envFiles() []string {
+	var files []string
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		files = append(files, path)
+	}
+	appEnv := os.Getenv("APP_ENV")
+	if appEnv == "" {
+		appEnv = os.Getenv("ENVIRONMENT")
+	}
+	if appEnv != "" {
+		files = append(files, ".env."+appEnv)
+	}
+	return append(files, ".env")
+}
+
+// LoadEnvFiles loads the layered config files into the environment. Variables already set in
+// the real environment always win, then the files in the order returned by envFiles. Missing
+// files are skipped, except for an explicit CONFIG_FILE.
+func LoadEnvFiles() error {
+	explicit := os.Getenv("CONFIG_FILE")
+	for _, file := range envFiles() {
+		values, err := godotenv.Read(file)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && file != explicit {
+				continue
+			}
+			return fmt.Errorf("error reading %s: %w", file, err)
+		}
+		for key, value := range values {
+			if _, set := os.LookupEnv(key); !set {
+				os.Setenv(key, value)
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns the configuration, reading it from the environment on first use
+func Get() *Config {
+	once.Do(func() {
+		current = FromEnv(os.LookupEnv)
+	})
+	return current
+}
+
+// Set replaces the configuration returned by Get, mainly for tests
+func Set(cfg *Config) {
+	once.Do(func() {})
+	current = cfg
+}
+
+// FromMap builds a Config from a map of environment values
+func FromMap(values map[string]string) *Config {
+	return FromEnv(func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	})
+}
+
+// FromEnv builds a Config from an environment lookup function such as os.LookupEnv
+func FromEnv(lookup func(string) (string, bool)) *Config {
+	get := func(key, fallback string) string {
+		if value, ok := lookup(key); ok && value != "" {
+			return value
+		}
+		return fallback
+	}
+
+	number := func(key string, fallback float64) float64 {
+		value, err := strconv.ParseFloat(get(key, ""), 64)
+		if err != nil || value < 0 {
+			return fallback
+		}
+		return value
+	}
+
+	signed := func(key string, fallback float64) float64 {
+		value, err := strconv.ParseFloat(get(key, ""), 64)
+		if err != nil {
+			return fallback
+		}
+		return value
+	}
+
+	positive := func(key string, fallback int) int {
+		value, err := strconv.Atoi(get(key, ""))
+		if err != nil || value <= 0 {
+			return fallback
+		}
+		return value
+	}
+
+	seconds := func(key string, fallback float64) time.Duration {
+		return time.Duration(number(key, fallback) * float64(time.Second))
+	}
+
+	// The range raw scores fall in with the default weights, so only outliers are clamped
+	stockRateMin, stockRateMax := signed("STOCK_RATE_MIN", -10), signed("STOCK_RATE_MAX", 35)
+	if stockRateMin >= stockRateMax {
+		stockRateMin, stockRateMax = -10, 35
+	}
+
+	decimalPlaces := number("DECIMAL_PLACES", 2)
+	if decimalPlaces > 10 || decimalPlaces != math.Trunc(decimalPlaces) {
+		decimalPlaces = 2
+	}
+
+	undervaluedRatio, overvaluedRatio := number("VALUATION_UNDERVALUED_RATIO", 0.8), number("VALUATION_OVERVALUED_RATIO", 1.2)
+	if undervaluedRatio >= overvaluedRatio {
+		undervaluedRatio, overvaluedRatio = 0.8, 1.2
+	}
+
+	dividendYieldMin, dividendYieldMax := number("DIVIDEND_YIELD_MIN", 0), number("DIVIDEND_YIELD_MAX", 8)
+	if dividendYieldMin >= dividendYieldMax {
+		dividendYieldMin, dividendYieldMax = 0, 8
+	}
+
+	// A rate of 0 would put every company in the smallest tier
+	marketCapUnitRate := number("MARKET_CAP_UNIT_RATE", 1)
+	if marketCapUnitRate == 0 {
+		marketCapUnitRate = 1
+	}
+
+	marketCapTiers := DefaultMarketCapTiers
+	if raw := get("MARKET_CAP_TIERS", ""); raw != "" {
+		if tiers, err := ParseMarketCapTiers([]byte(raw)); err != nil {
+			zap.L().Error("Error loading MARKET_CAP_TIERS, using the default tiers", zap.Error(err))
+		} else {
+			marketCapTiers = tiers
+		}
+	}
+
+	var trendMetricDirections map[string]float64
+	if raw := get("TREND_METRIC_DIRECTIONS", ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &trendMetricDirections); err != nil {
+			zap.L().Error("Invalid TREND_METRIC_DIRECTIONS, using defaults", zap.Error(err))
+			trendMetricDirections = nil
+		}
+	}
+
+	numberFormat := strings.ToLower(strings.TrimSpace(get("NUMBER_FORMAT", "us")))
+	if numberFormat != "indian" && numberFormat != "eu" && numberFormat != "auto" {
+		numberFormat = "us"
+	}
+
+	outputNumberFormat := strings.ToLower(strings.TrimSpace(get("OUTPUT_NUMBER_FORMAT", "indian")))
+	if outputNumberFormat != "us" && outputNumberFormat != "eu" && outputNumberFormat != "none" {
+		outputNumberFormat = "indian"
+	}
+
+	// Comma separated values, "none" for an empty list
+	list := func(key, fallback string) []string {
+		var values []string
+		if raw := get(key, fallback); raw != "none" {
+			for _, value := range strings.Split(raw, ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					values = append(values, value)
+				}
+			}
+		}
+		return values
+	}
+
+	return &Config{
+		Environment:      get("ENVIRONMENT", ""),
+		Port:             get("PORT", "4000"),
+		MongoURI:         get("MONGO_URI", ""),
+		Database:         get("DATABASE", ""),
+		Collection:       get("COLLECTION", ""),
+		CloudinaryURL:    get("CLOUDINARY_URL", ""),
+		CompanyURL:       get("COMPANY_URL", ""),
+		SentryDSN:        get("SENTRY_DSN", ""),
+		SentrySampleRate: number("SENTRY_SAMPLE_RATE", 1.0),
+		AdminToken:       get("ADMIN_TOKEN", ""),
+		APIToken:         get("API_TOKEN", ""),
+
+		MongoConnectAttempts:   positive("MONGO_CONNECT_ATTEMPTS", 10),
+		MongoConnectBackoff:    seconds("MONGO_CONNECT_BACKOFF_SECONDS", 1),
+		MongoConnectMaxBackoff: seconds("MONGO_CONNECT_MAX_BACKOFF_SECONDS", 30),
+
+		UploadsCollection:         get("UPLOADS_COLLECTION", "uploads"),
+		NameMapCollection:         get("NAME_MAP_COLLECTION", "namemap"),
+		SectorBenchmarkWeight:     number("SECTOR_BENCHMARK_WEIGHT", 0),
+		SectorBenchmarkTTL:        time.Duration(positive("SECTOR_BENCHMARK_TTL_MINUTES", 60)) * time.Minute,
+		ShareholdingWeight:        number("SHAREHOLDING_WEIGHT", 0),
+		WorkingCapitalWeight:      number("WORKING_CAPITAL_WEIGHT", 0),
+		GrowthWeight:              number("GROWTH_WEIGHT", 0),
+		GrowthStrongPercent:       number("GROWTH_STRONG_PERCENT", 10),
+		ProsConsWeight:            number("PROS_CONS_WEIGHT", 0),
+		FuzzyMatchThreshold:       number("FUZZY_MATCH_THRESHOLD", 0.85),
+		TextSearchCandidates:      positive("TEXT_SEARCH_CANDIDATES", 5),
+		MaxDataAge:                time.Duration(number("MAX_DATA_AGE_DAYS", 30) * float64(24*time.Hour)),
+		ReadRevalidate:            get("READ_REVALIDATE", "never"),
+		UploadWriteBatchSize:      positive("UPLOAD_WRITE_BATCH_SIZE", 100),
+		PeerSnapshots:             positive("PEER_SNAPSHOTS", 5),
+		HighDebtToEquity:          number("HIGH_DEBT_TO_EQUITY", 2),
+		HighDebtToAssets:          number("HIGH_DEBT_TO_ASSETS", 0.5),
+		ValuationUndervaluedRatio: undervaluedRatio,
+		ValuationOvervaluedRatio:  overvaluedRatio,
+		DividendYieldMin:          dividendYieldMin,
+		DividendYieldMax:          dividendYieldMax,
+		FundamentalsMaxPE:         number("FUNDAMENTALS_MAX_PE", 25),
+		FundamentalsMinROCE:       number("FUNDAMENTALS_MIN_ROCE", 15),
+		StockRateMin:              stockRateMin,
+		StockRateMax:              stockRateMax,
+		DecimalPlaces:             int(decimalPlaces),
+		StrictParsing:             get("STRICT_PARSING", "false") == "true",
+		TrendExcludeTTM:           get("TREND_EXCLUDE_TTM", "false") == "true",
+		TrendMetricDirections:     trendMetricDirections,
+		NumberFormat:              numberFormat,
+		OutputNumberFormat:        outputNumberFormat,
+		ResponseDenylist:          list("RESPONSE_DENYLIST", defaultResponseDenylist),
+		ForeignNameMarkers:        list("FOREIGN_NAME_MARKERS", defaultForeignNameMarkers),
+		SwapMaxAUMPercent:         number("SWAP_MAX_AUM_PERCENT", 100),
+		SwapMinValuePerUnit:       number("SWAP_MIN_VALUE_PER_UNIT", 0.000001),
+		AggregateByISIN:           get("AGGREGATE_BY_ISIN", "false") == "true",
+		SkipLabelsFile:            get("SKIP_LABELS_FILE", ""),
+		MarketCapTiers:            marketCapTiers,
+		MarketCapUnitRate:         marketCapUnitRate,
+
+		ServerReadHeaderTimeout: seconds("SERVER_READ_HEADER_TIMEOUT_SECONDS", 10),
+		ServerReadTimeout:       seconds("SERVER_READ_TIMEOUT_SECONDS", 60),
+		ServerWriteTimeout:      seconds("SERVER_WRITE_TIMEOUT_SECONDS", 60),
+		ServerIdleTimeout:       seconds("SERVER_IDLE_TIMEOUT_SECONDS", 120),
+		UploadReadTimeout:       seconds("UPLOAD_READ_TIMEOUT_SECONDS", 300),
+		UploadWriteTimeout:      seconds("UPLOAD_WRITE_TIMEOUT_SECONDS", 0),
+		StreamHeartbeatInterval: seconds("STREAM_HEARTBEAT_SECONDS", 5),
+
+		MaxUploadSize:          int64(positive("MAX_UPLOAD_SIZE_MB", 50)) << 20,
+		MaxUploadFiles:         positive("MAX_UPLOAD_FILES", 10),
+		MaxMultipartMemory:     int64(positive("MAX_MULTIPART_MEMORY_MB", 32)) << 20,
+		MaxZipUncompressedSize: int64(positive("MAX_ZIP_UNCOMPRESSED_MB", 200)) << 20,
+
+		UploadMinProcessedFiles: int(number("UPLOAD_MIN_PROCESSED_FILES", 0)),
+		UploadFailOnFileError:   get("UPLOAD_FAIL_ON_FILE_ERROR", "false") == "true",
+
+		MaxConcurrentUploads: positive("MAX_CONCURRENT_UPLOADS", 4),
+		UploadQueueTimeout:   seconds("UPLOAD_QUEUE_TIMEOUT_SECONDS", 30),
+
+		UploadDeadline:       seconds("UPLOAD_DEADLINE_SECONDS", 0),
+		UploadDeadlineIngest: get("UPLOAD_DEADLINE_INGEST", "true") == "true",
+		UploadBacklogQueue:   positive("UPLOAD_BACKLOG_QUEUE", 10),
+
+		UploadSessionDir:  get("UPLOAD_SESSION_DIR", "./uploads/sessions"),
+		UploadSessionTTL:  time.Duration(positive("UPLOAD_SESSION_TTL_MINUTES", 60)) * time.Minute,
+		MaxUploadSessions: positive("MAX_UPLOAD_SESSIONS", 20),
+
+		RemoteXLSXTimeout:      time.Duration(positive("REMOTE_XLSX_TIMEOUT_SECONDS", 60)) * time.Second,
+		RemoteXLSXAllowPrivate: get("REMOTE_XLSX_ALLOW_PRIVATE", "false") == "true",
+
+		RefreshEnabled:       get("REFRESH_ENABLED", "false") == "true",
+		RefreshInterval:      time.Duration(positive("REFRESH_INTERVAL_MINUTES", 360)) * time.Minute,
+		RefreshStaleAfter:    time.Duration(positive("REFRESH_STALE_DAYS", 7)) * 24 * time.Hour,
+		RefreshRatePerMinute: positive("REFRESH_RATE_PER_MINUTE", 6),
+		RefreshBatchSize:     int64(positive("REFRESH_BATCH_SIZE", 50)),
+
+		ScrapeMinInterval:     seconds("SCRAPE_MIN_INTERVAL_SECONDS", 1),
+		ScrapeRetryAttempts:   positive("SCRAPE_RETRY_ATTEMPTS", 3),
+		ScrapeRetryBackoff:    seconds("SCRAPE_RETRY_BACKOFF_SECONDS", 2),
+		ScrapeRetryMaxBackoff: seconds("SCRAPE_RETRY_MAX_BACKOFF_SECONDS", 30),
+		ScraperUserAgent:      get("SCRAPER_USER_AGENT", ""),
+
+		DebugStoreHTML: get("DEBUG_STORE_HTML", "false") == "true",
+		DebugEndpoints: get("DEBUG_ENDPOINTS", "false") == "true",
+		DebugHTMLDir:   get("DEBUG_HTML_DIR", "./debug_html"),
+
+		IngestMaxNames:      positive("INGEST_MAX_NAMES", 100),
+		IngestConcurrency:   positive("INGEST_CONCURRENCY", 4),
+		IngestRatePerMinute: positive("INGEST_RATE_PER_MINUTE", 30),
+
+		DataSource: get("DATA_SOURCE", "screener"),
+		FetchPeers: get("FETCH_PEERS", "true") != "false",
+
+		OTLPEndpoint: get("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+	}
+}
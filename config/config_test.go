@@ -0,0 +1,321 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Error writing %s: %v", path, err)
+	}
+}
+
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Error getting working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Error changing directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestLoadEnvFiles_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+
+	explicit := filepath.Join(dir, "custom.env")
+	writeFile(t, explicit, "CFG_TEST_A=explicit\n")
+	writeFile(t, ".env.production", "CFG_TEST_A=production\nCFG_TEST_B=production\n")
+	writeFile(t, ".env", "CFG_TEST_A=dotenv\nCFG_TEST_B=dotenv\nCFG_TEST_C=dotenv\nCFG_TEST_D=dotenv\n")
+
+	t.Setenv("CONFIG_FILE", explicit)
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("CFG_TEST_D", "real")
+	for _, key := range []string{"CFG_TEST_A", "CFG_TEST_B", "CFG_TEST_C"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	if err := LoadEnvFiles(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"CFG_TEST_A": "explicit",
+		"CFG_TEST_B": "production",
+		"CFG_TEST_C": "dotenv",
+		"CFG_TEST_D": "real",
+	}
+	for key, value := range expected {
+		if got := os.Getenv(key); got != value {
+			t.Errorf("Expected %s=%s, got %s", key, value, got)
+		}
+	}
+}
+
+func TestLoadEnvFiles_MissingExplicitFile(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("CONFIG_FILE", "does-not-exist.env")
+
+	if err := LoadEnvFiles(); err == nil {
+		t.Errorf("Expected an error for a missing CONFIG_FILE")
+	}
+}
+
+func TestLoadEnvFiles_NoFiles(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("APP_ENV", "")
+
+	if err := LoadEnvFiles(); err != nil {
+		t.Errorf("Expected missing optional files to be skipped, got %v", err)
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	cfg := FromMap(map[string]string{
+		"PORT":                         "8080",
+		"MONGO_URI":                    "mongodb://localhost:27017",
+		"DATABASE":                     "stocks",
+		"COLLECTION":                   "companies",
+		"COMPANY_URL":                  "https://example.com",
+		"SENTRY_SAMPLE_RATE":           "0.25",
+		"SECTOR_BENCHMARK_WEIGHT":      "0.5",
+		"SECTOR_BENCHMARK_TTL_MINUTES": "15",
+	})
+
+	if cfg.Port != "8080" || cfg.MongoURI != "mongodb://localhost:27017" || cfg.Database != "stocks" || cfg.Collection != "companies" {
+		t.Errorf("Unexpected connection settings: %+v", cfg)
+	}
+	if cfg.CompanyURL != "https://example.com" {
+		t.Errorf("Expected the company URL, got %q", cfg.CompanyURL)
+	}
+	if cfg.SentrySampleRate != 0.25 || cfg.SectorBenchmarkWeight != 0.5 {
+		t.Errorf("Unexpected rates: %v, %v", cfg.SentrySampleRate, cfg.SectorBenchmarkWeight)
+	}
+	if cfg.SectorBenchmarkTTL != 15*time.Minute {
+		t.Errorf("Expected a 15 minute TTL, got %v", cfg.SectorBenchmarkTTL)
+	}
+}
+
+func TestFromMap_Defaults(t *testing.T) {
+	cfg := FromMap(map[string]string{
+		"SHAREHOLDING_WEIGHT":          "-1",
+		"SECTOR_BENCHMARK_TTL_MINUTES": "abc",
+	})
+
+	if cfg.Port != "4000" {
+		t.Errorf("Expected default port 4000, got %q", cfg.Port)
+	}
+	if cfg.NumberFormat != "us" || cfg.OutputNumberFormat != "indian" || cfg.TrendMetricDirections != nil {
+		t.Errorf("Unexpected default number formats or trend directions: %q, %q, %v", cfg.NumberFormat, cfg.OutputNumberFormat, cfg.TrendMetricDirections)
+	}
+	if cfg.MaxUploadSize != 50<<20 || cfg.MaxUploadFiles != 10 || cfg.MaxMultipartMemory != 32<<20 {
+		t.Errorf("Unexpected default upload limits: %v, %v, %v", cfg.MaxUploadSize, cfg.MaxUploadFiles, cfg.MaxMultipartMemory)
+	}
+	if cfg.AdminToken != "" || cfg.APIToken != "" || cfg.DebugStoreHTML || cfg.DebugEndpoints || cfg.DebugHTMLDir != "./debug_html" {
+		t.Errorf("Unexpected default admin and debug settings: %q, %v, %v, %q", cfg.AdminToken, cfg.DebugStoreHTML, cfg.DebugEndpoints, cfg.DebugHTMLDir)
+	}
+	if cfg.UploadsCollection != "uploads" {
+		t.Errorf("Expected default uploads collection, got %q", cfg.UploadsCollection)
+	}
+	if cfg.NameMapCollection != "namemap" {
+		t.Errorf("Expected default name map collection, got %q", cfg.NameMapCollection)
+	}
+	if cfg.SentrySampleRate != 1.0 || cfg.SectorBenchmarkWeight != 0 || cfg.ShareholdingWeight != 0 || cfg.FuzzyMatchThreshold != 0.85 {
+		t.Errorf("Unexpected default weights: %+v", cfg)
+	}
+	if cfg.SectorBenchmarkTTL != time.Hour {
+		t.Errorf("Expected default TTL of an hour, got %v", cfg.SectorBenchmarkTTL)
+	}
+	if cfg.RefreshEnabled || cfg.RefreshInterval != 6*time.Hour || cfg.RefreshStaleAfter != 7*24*time.Hour {
+		t.Errorf("Unexpected default refresh schedule: %+v", cfg)
+	}
+	if cfg.RefreshRatePerMinute != 6 || cfg.RefreshBatchSize != 50 {
+		t.Errorf("Unexpected default refresh limits: %+v", cfg)
+	}
+	if cfg.HighDebtToEquity != 2 || cfg.HighDebtToAssets != 0.5 {
+		t.Errorf("Unexpected default debt thresholds: %+v", cfg)
+	}
+	if cfg.UploadWriteBatchSize != 100 {
+		t.Errorf("Expected a default write batch of 100, got %d", cfg.UploadWriteBatchSize)
+	}
+	if cfg.WorkingCapitalWeight != 0 {
+		t.Errorf("Expected the working capital left out of the score by default, got %v", cfg.WorkingCapitalWeight)
+	}
+	if cfg.PeerSnapshots != 5 {
+		t.Errorf("Expected 5 peer snapshots by default, got %d", cfg.PeerSnapshots)
+	}
+	if cfg.DividendYieldMin != 0 || cfg.DividendYieldMax != 8 {
+		t.Errorf("Unexpected default dividend yield band: %v to %v", cfg.DividendYieldMin, cfg.DividendYieldMax)
+	}
+	if cfg.StockRateMin != -10 || cfg.StockRateMax != 35 {
+		t.Errorf("Unexpected default stockRate scale: %v to %v", cfg.StockRateMin, cfg.StockRateMax)
+	}
+	if cfg.ServerReadHeaderTimeout != 10*time.Second || cfg.ServerReadTimeout != time.Minute || cfg.ServerWriteTimeout != time.Minute || cfg.ServerIdleTimeout != 2*time.Minute {
+		t.Errorf("Unexpected default server timeouts: %+v", cfg)
+	}
+	if cfg.UploadReadTimeout != 5*time.Minute || cfg.UploadWriteTimeout != 0 {
+		t.Errorf("Unexpected default upload timeouts: %v, %v", cfg.UploadReadTimeout, cfg.UploadWriteTimeout)
+	}
+	if cfg.ValuationUndervaluedRatio != 0.8 || cfg.ValuationOvervaluedRatio != 1.2 {
+		t.Errorf("Unexpected default valuation ratios: %v, %v", cfg.ValuationUndervaluedRatio, cfg.ValuationOvervaluedRatio)
+	}
+	if !cfg.FetchPeers {
+		t.Errorf("Expected peers to be fetched by default")
+	}
+	if cfg.MongoConnectAttempts != 10 || cfg.MongoConnectBackoff != time.Second || cfg.MongoConnectMaxBackoff != 30*time.Second {
+		t.Errorf("Unexpected default Mongo connect retries: %v, %v, %v", cfg.MongoConnectAttempts, cfg.MongoConnectBackoff, cfg.MongoConnectMaxBackoff)
+	}
+	if cfg.TrendExcludeTTM {
+		t.Errorf("Expected the TTM column to be kept in the trend by default")
+	}
+	if len(cfg.ResponseDenylist) != 11 || cfg.ResponseDenylist[0] != "quarterlyResults" {
+		t.Errorf("Unexpected default response denylist: %v", cfg.ResponseDenylist)
+	}
+	if cfg.SwapMaxAUMPercent != 100 || cfg.SwapMinValuePerUnit != 0.000001 {
+		t.Errorf("Unexpected default swap thresholds: %v, %v", cfg.SwapMaxAUMPercent, cfg.SwapMinValuePerUnit)
+	}
+	if cfg.AggregateByISIN {
+		t.Errorf("Expected holdings sharing an ISIN to stay apart by default")
+	}
+	if cfg.StreamHeartbeatInterval != 5*time.Second {
+		t.Errorf("Expected a 5s stream heartbeat by default, got %v", cfg.StreamHeartbeatInterval)
+	}
+	if cfg.ReadRevalidate != "never" {
+		t.Errorf("Expected no revalidation by default, got %q", cfg.ReadRevalidate)
+	}
+	if cfg.GrowthWeight != 0 || cfg.GrowthStrongPercent != 10 {
+		t.Errorf("Unexpected default growth scoring: %v, %v", cfg.GrowthWeight, cfg.GrowthStrongPercent)
+	}
+	if cfg.UploadMinProcessedFiles != 0 || cfg.UploadFailOnFileError {
+		t.Errorf("Unexpected default upload failure policy: %v, %v", cfg.UploadMinProcessedFiles, cfg.UploadFailOnFileError)
+	}
+	if cfg.ProsConsWeight != 0 {
+		t.Errorf("Expected the pros and cons left out of the score by default, got %v", cfg.ProsConsWeight)
+	}
+	if cfg.TextSearchCandidates != 5 {
+		t.Errorf("Expected 5 text search candidates by default, got %v", cfg.TextSearchCandidates)
+	}
+	if len(cfg.MarketCapTiers) != len(DefaultMarketCapTiers) || cfg.MarketCapTiers[0] != DefaultMarketCapTiers[0] || cfg.MarketCapUnitRate != 1 {
+		t.Errorf("Expected the default market cap tiers in crore, got %+v at %v", cfg.MarketCapTiers, cfg.MarketCapUnitRate)
+	}
+	if cfg.MaxConcurrentUploads != 4 || cfg.UploadQueueTimeout != 30*time.Second {
+		t.Errorf("Unexpected default upload concurrency: %v, %v", cfg.MaxConcurrentUploads, cfg.UploadQueueTimeout)
+	}
+	if cfg.UploadDeadline != 0 || !cfg.UploadDeadlineIngest || cfg.UploadBacklogQueue != 10 {
+		t.Errorf("Expected no upload deadline by default, got %v (ingest %v, queue %d)", cfg.UploadDeadline, cfg.UploadDeadlineIngest, cfg.UploadBacklogQueue)
+	}
+	if cfg.ScrapeMinInterval != time.Second {
+		t.Errorf("Expected a 1s scrape interval by default, got %v", cfg.ScrapeMinInterval)
+	}
+	if cfg.ScrapeRetryAttempts != 3 || cfg.ScrapeRetryBackoff != 2*time.Second || cfg.ScrapeRetryMaxBackoff != 30*time.Second {
+		t.Errorf("Unexpected default scrape retries: %v, %v, %v", cfg.ScrapeRetryAttempts, cfg.ScrapeRetryBackoff, cfg.ScrapeRetryMaxBackoff)
+	}
+	if cfg.IngestMaxNames != 100 || cfg.IngestConcurrency != 4 || cfg.IngestRatePerMinute != 30 {
+		t.Errorf("Unexpected default ingest limits: %v, %v, %v", cfg.IngestMaxNames, cfg.IngestConcurrency, cfg.IngestRatePerMinute)
+	}
+	if cfg.MaxDataAge != 30*24*time.Hour {
+		t.Errorf("Unexpected default max data age: %v", cfg.MaxDataAge)
+	}
+	if cfg.UploadSessionDir != "./uploads/sessions" || cfg.UploadSessionTTL != time.Hour || cfg.MaxUploadSessions != 20 {
+		t.Errorf("Unexpected default upload sessions: %v, %v, %v", cfg.UploadSessionDir, cfg.UploadSessionTTL, cfg.MaxUploadSessions)
+	}
+	if cfg.RemoteXLSXTimeout != time.Minute || cfg.RemoteXLSXAllowPrivate {
+		t.Errorf("Unexpected default remote spreadsheets: %v, %v", cfg.RemoteXLSXTimeout, cfg.RemoteXLSXAllowPrivate)
+	}
+	if cfg.StrictParsing {
+		t.Errorf("Expected lenient parsing by default")
+	}
+}
+
+func TestFromMap_Refresh(t *testing.T) {
+	cfg := FromMap(map[string]string{
+		"REFRESH_ENABLED":          "true",
+		"REFRESH_INTERVAL_MINUTES": "30",
+		"REFRESH_STALE_DAYS":       "2",
+		"REFRESH_RATE_PER_MINUTE":  "0",
+	})
+
+	if !cfg.RefreshEnabled {
+		t.Errorf("Expected the refresh to be enabled")
+	}
+	if cfg.RefreshInterval != 30*time.Minute || cfg.RefreshStaleAfter != 48*time.Hour {
+		t.Errorf("Unexpected refresh schedule: %v, %v", cfg.RefreshInterval, cfg.RefreshStaleAfter)
+	}
+	if cfg.RefreshRatePerMinute != 6 {
+		t.Errorf("Expected an invalid rate to fall back to 6, got %d", cfg.RefreshRatePerMinute)
+	}
+}
+
+func TestFromMap_StockRateScale(t *testing.T) {
+	cfg := FromMap(map[string]string{"STOCK_RATE_MIN": "-10", "STOCK_RATE_MAX": "10"})
+	if cfg.StockRateMin != -10 || cfg.StockRateMax != 10 {
+		t.Errorf("Expected a -10 to 10 scale, got %v to %v", cfg.StockRateMin, cfg.StockRateMax)
+	}
+	cfg = FromMap(map[string]string{"STOCK_RATE_MIN": "10", "STOCK_RATE_MAX": "5"})
+	if cfg.StockRateMin != -10 || cfg.StockRateMax != 35 {
+		t.Errorf("Expected an empty scale to fall back to -10 to 35, got %v to %v", cfg.StockRateMin, cfg.StockRateMax)
+	}
+}
+
+func TestFromMap_FundamentalsThresholds(t *testing.T) {
+	if cfg := FromMap(map[string]string{}); cfg.FundamentalsMaxPE != 25 || cfg.FundamentalsMinROCE != 15 {
+		t.Errorf("Unexpected default fundamentals thresholds: PE %v, ROCE %v", cfg.FundamentalsMaxPE, cfg.FundamentalsMinROCE)
+	}
+	if cfg := FromMap(map[string]string{"FUNDAMENTALS_MAX_PE": "40", "FUNDAMENTALS_MIN_ROCE": "12.5"}); cfg.FundamentalsMaxPE != 40 || cfg.FundamentalsMinROCE != 12.5 {
+		t.Errorf("Expected PE 40 and ROCE 12.5, got %v and %v", cfg.FundamentalsMaxPE, cfg.FundamentalsMinROCE)
+	}
+}
+
+func TestFromMap_DecimalPlaces(t *testing.T) {
+	if cfg := FromMap(map[string]string{}); cfg.DecimalPlaces != 2 {
+		t.Errorf("Expected 2 decimal places by default, got %d", cfg.DecimalPlaces)
+	}
+	if cfg := FromMap(map[string]string{"DECIMAL_PLACES": "0"}); cfg.DecimalPlaces != 0 {
+		t.Errorf("Expected 0 decimal places, got %d", cfg.DecimalPlaces)
+	}
+	for _, invalid := range []string{"-1", "1.5", "11", "two"} {
+		if cfg := FromMap(map[string]string{"DECIMAL_PLACES": invalid}); cfg.DecimalPlaces != 2 {
+			t.Errorf("Expected %q to fall back to 2 decimal places, got %d", invalid, cfg.DecimalPlaces)
+		}
+	}
+}
+
+func TestFromMap_ResponseDenylist(t *testing.T) {
+	if cfg := FromMap(map[string]string{"RESPONSE_DENYLIST": " peers, ,profitLoss "}); !reflect.DeepEqual(cfg.ResponseDenylist, []string{"peers", "profitLoss"}) {
+		t.Errorf("Expected [peers profitLoss], got %v", cfg.ResponseDenylist)
+	}
+	if cfg := FromMap(map[string]string{"RESPONSE_DENYLIST": "none"}); len(cfg.ResponseDenylist) != 0 {
+		t.Errorf("Expected no denylist, got %v", cfg.ResponseDenylist)
+	}
+}
+
+func TestFromMap_ForeignNameMarkers(t *testing.T) {
+	if cfg := FromMap(map[string]string{}); len(cfg.ForeignNameMarkers) == 0 || cfg.ForeignNameMarkers[0] != "Inc" {
+		t.Errorf("Unexpected default foreign name markers: %v", cfg.ForeignNameMarkers)
+	}
+	if cfg := FromMap(map[string]string{"FOREIGN_NAME_MARKERS": "AG, SE"}); !reflect.DeepEqual(cfg.ForeignNameMarkers, []string{"AG", "SE"}) {
+		t.Errorf("Expected [AG SE], got %v", cfg.ForeignNameMarkers)
+	}
+	if cfg := FromMap(map[string]string{"FOREIGN_NAME_MARKERS": "none"}); len(cfg.ForeignNameMarkers) != 0 {
+		t.Errorf("Expected no markers, got %v", cfg.ForeignNameMarkers)
+	}
+}
+
+func TestFromMap_DividendYieldBand(t *testing.T) {
+	cfg := FromMap(map[string]string{"DIVIDEND_YIELD_MIN": "0.5", "DIVIDEND_YIELD_MAX": "6"})
+	if cfg.DividendYieldMin != 0.5 || cfg.DividendYieldMax != 6 {
+		t.Errorf("Expected a 0.5 to 6 band, got %v to %v", cfg.DividendYieldMin, cfg.DividendYieldMax)
+	}
+	cfg = FromMap(map[string]string{"DIVIDEND_YIELD_MIN": "10", "DIVIDEND_YIELD_MAX": "5"})
+	if cfg.DividendYieldMin != 0 || cfg.DividendYieldMax != 8 {
+		t.Errorf("Expected an empty band to fall back to 0 to 8, got %v to %v", cfg.DividendYieldMin, cfg.DividendYieldMax)
+	}
+}
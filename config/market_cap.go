@@ -0,0 +1,45 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidMarketCapTiers = errors.New("invalid market cap tiers")
+
+// MarketCapTier is a market cap category, companies with a market cap of at least MinValue that
+// don't reach a larger tier fall into it
+type MarketCapTier struct {
+	Name     string  `json:"name"`
+	MinValue float64 `json:"minValue"`
+}
+
+// DefaultMarketCapTiers are the crore boundaries of SEBI's large, mid and small caps
+var DefaultMarketCapTiers = []MarketCapTier{
+	{Name: "Large Cap", MinValue: 20000},
+	{Name: "Mid Cap", MinValue: 5000},
+	{Name: "Small Cap", MinValue: 0},
+}
+
+// ParseMarketCapTiers reads a JSON list of market cap tiers, largest first: every tier needs a name
+// and a minValue below the one of the tier before it
+func ParseMarketCapTiers(data []byte) ([]MarketCapTier, error) {
+	var tiers []MarketCapTier
+	if err := json.Unmarshal(data, &tiers); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMarketCapTiers, err)
+	}
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("%w: no tiers", ErrInvalidMarketCapTiers)
+	}
+	for i, tier := range tiers {
+		if strings.TrimSpace(tier.Name) == "" {
+			return nil, fmt.Errorf("%w: tier %d has no name", ErrInvalidMarketCapTiers, i+1)
+		}
+		if i > 0 && tier.MinValue >= tiers[i-1].MinValue {
+			return nil, fmt.Errorf("%w: minValue of %q must be below the one of %q", ErrInvalidMarketCapTiers, tier.Name, tiers[i-1].Name)
+		}
+	}
+	return tiers, nil
+}
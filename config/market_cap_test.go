@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMarketCapTiers(t *testing.T) {
+	tiers, err := ParseMarketCapTiers([]byte(`[{"name": "Large Cap", "minValue": 20000}, {"name": "Mid Cap", "minValue": 5000}]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tiers) != 2 || tiers[1] != (MarketCapTier{Name: "Mid Cap", MinValue: 5000}) {
+		t.Errorf("Unexpected tiers: %+v", tiers)
+	}
+
+	invalid := map[string]string{
+		"malformed":      `{"name": "Large Cap"}`,
+		"empty":          `[]`,
+		"unnamed":        `[{"name": "Large Cap", "minValue": 20000}, {"name": " ", "minValue": 0}]`,
+		"ascending":      `[{"name": "Small Cap", "minValue": 0}, {"name": "Large Cap", "minValue": 20000}]`,
+		"repeated bound": `[{"name": "Large Cap", "minValue": 5000}, {"name": "Mid Cap", "minValue": 5000}]`,
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseMarketCapTiers([]byte(data)); !errors.Is(err, ErrInvalidMarketCapTiers) {
+				t.Errorf("Expected ErrInvalidMarketCapTiers, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFromMap_MarketCapTiers(t *testing.T) {
+	cfg := FromMap(map[string]string{"MARKET_CAP_TIERS": `[{"name": "Large Cap", "minValue": 10000}, {"name": "Small Cap", "minValue": 0}]`})
+	if len(cfg.MarketCapTiers) != 2 || cfg.MarketCapTiers[0] != (MarketCapTier{Name: "Large Cap", MinValue: 10000}) {
+		t.Errorf("Expected the configured tiers, got %+v", cfg.MarketCapTiers)
+	}
+
+	// Invalid tiers fall back to the default ones, like the other settings
+	cfg = FromMap(map[string]string{"MARKET_CAP_TIERS": `[{"name": "Small Cap", "minValue": 0}, {"name": "Large Cap", "minValue": 20000}]`})
+	if len(cfg.MarketCapTiers) != 3 || cfg.MarketCapTiers[0] != DefaultMarketCapTiers[0] {
+		t.Errorf("Expected the default tiers, got %+v", cfg.MarketCapTiers)
+	}
+}
@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"stockbackend/config"
+	"stockbackend/services"
+	"stockbackend/utils/helpers"
+	"strconv"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type CompanyControllerI interface {
+	ListCompanies(ctx *gin.Context)
+	DeleteCompany(ctx *gin.Context)
+	InvalidateCompany(ctx *gin.Context)
+	RefreshCompany(ctx *gin.Context)
+	GetCompany(ctx *gin.Context)
+	PeerHistory(ctx *gin.Context)
+	FactSheet(ctx *gin.Context)
+	FinancialHistory(ctx *gin.Context)
+	MergeCompanies(ctx *gin.Context)
+	ListDuplicates(ctx *gin.Context)
+	IngestCompanies(ctx *gin.Context)
+}
+
+type companyController struct{}
+
+var CompanyController CompanyControllerI = &companyController{}
+
+func (c *companyController) ListCompanies(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	query, err := helpers.ParseCompanyListQuery(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	companies, err := services.CompanyService.ListCompanies(ctx, query)
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+	for i := range companies {
+		if companies[i].MarketCapValue != nil {
+			companies[i].MarketCapFormatted, _ = helpers.FormatMarketCap(*companies[i].MarketCapValue)
+		}
+	}
+	// The name read along with the requested fields is dropped here
+	projected, err := query.Fields.ApplyJSON(companies)
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"companies": projected, "limit": query.Limit, "offset": query.Offset})
+}
+
+func (c *companyController) DeleteCompany(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	existed, err := services.CompanyService.DeleteCompany(ctx, ctx.Param("name"))
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"deleted": existed})
+}
+
+func (c *companyController) InvalidateCompany(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	existed, err := services.CompanyService.InvalidateCompany(ctx, ctx.Param("name"))
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+	if !existed {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"invalidated": true})
+}
+
+func (c *companyController) RefreshCompany(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	fields, err := helpers.ParseFieldProjection(ctx.Query("fields"), config.Get().ResponseDenylist)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	company, err := services.CompanyService.RefreshCompany(ctx, ctx.Param("name"))
+	if errors.Is(err, services.ErrCompanyNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	helpers.AddCompanyFormattedFields(company)
+	ctx.JSON(http.StatusOK, fields.Apply(company))
+}
+
+// GetCompany returns a stored company, revalidating its data when it is stale (?revalidate=)
+func (c *companyController) GetCompany(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	fields, err := helpers.ParseFieldProjection(ctx.Query("fields"), config.Get().ResponseDenylist)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mode, err := helpers.ParseRevalidateMode(ctx.Query("revalidate"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// An async revalidation outlives the gin context, which is reused once the response is sent
+	company, err := services.CompanyService.GetCompany(ctx.Request.Context(), ctx.Param("name"), mode)
+	if errors.Is(err, services.ErrCompanyNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	helpers.AddCompanyFormattedFields(company)
+	// stale is returned whatever the fields
+	projected := fields.Apply(company)
+	projected["stale"] = company["stale"]
+	ctx.JSON(http.StatusOK, projected)
+}
+
+func (c *companyController) PeerHistory(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	history, err := services.CompanyService.PeerHistory(ctx, ctx.Param("name"))
+	if errors.Is(err, services.ErrCompanyNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"snapshots": history})
+}
+
+// FactSheet renders the stored document of a company as a one page PDF, it never scrapes
+func (c *companyController) FactSheet(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	company, err := services.CompanyService.GetCompanyDocument(ctx, ctx.Param("name"))
+	if errors.Is(err, services.ErrCompanyNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	// Rendered in memory so a failure can still be answered with an error
+	var pdf bytes.Buffer
+	if err := helpers.WriteFactSheetPDF(&pdf, helpers.NewFactSheet(company)); err != nil {
+		internalError(ctx, err)
+		return
+	}
+	ctx.Header("Content-Disposition", `inline; filename="factsheet.pdf"`)
+	ctx.Data(http.StatusOK, helpers.FactSheetContentType, pdf.Bytes())
+}
+
+// FinancialHistory returns the series of one or more metrics (repeated metric query params) of a
+// stored statement, e.g. ?statement=profitLoss&metric=Sales&metric=Net+Profit
+func (c *companyController) FinancialHistory(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	statement := ctx.Query("statement")
+	metrics := ctx.QueryArray("metric")
+	if statement == "" || len(metrics) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "statement and at least one metric are required"})
+		return
+	}
+
+	history, err := services.CompanyService.FinancialHistory(ctx, ctx.Param("name"), statement, metrics)
+	if errors.Is(err, services.ErrCompanyNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		return
+	}
+	if errors.Is(err, helpers.ErrUnknownStatement) || errors.Is(err, helpers.ErrUnknownMetric) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"statement": statement, "history": history})
+}
+
+type mergeRequest struct {
+	// Names or ISINs of the two duplicates
+	Companies []string `json:"companies" binding:"required,len=2"`
+}
+
+func (c *companyController) MergeCompanies(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	var request mergeRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fields, err := helpers.ParseFieldProjection(ctx.Query("fields"), config.Get().ResponseDenylist)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	company, removed, err := services.CompanyService.MergeCompanies(ctx, request.Companies[0], request.Companies[1])
+	if errors.Is(err, services.ErrCompanyNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrMergeSameCompany) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"company": fields.Apply(company), "removed": removed})
+}
+
+func (c *companyController) ListDuplicates(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	threshold := config.Get().FuzzyMatchThreshold
+	if raw := ctx.Query("minSimilarity"); raw != "" {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value <= 0 || value > 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid minSimilarity: " + strconv.Quote(raw)})
+			return
+		}
+		threshold = value
+	}
+
+	duplicates, err := services.CompanyService.ListDuplicates(ctx, threshold)
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"duplicates": duplicates, "minSimilarity": threshold})
+}
+
+type ingestRequest struct {
+	Names []string `json:"names" binding:"required"`
+}
+
+// IngestCompanies scrapes, scores and stores a list of company names, streaming a result per name
+// as it finishes and a summary at the end
+func (c *companyController) IngestCompanies(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	format, err := helpers.ParseStreamFormat(ctx.Query("format"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var request ingestRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	names, err := helpers.ParseIngestNames(request.Names, config.Get().IngestMaxNames)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stream := helpers.NewStreamWriter(ctx.Writer, format)
+	ctx.Writer.Header().Set("Content-Type", stream.ContentType())
+	ctx.Writer.Header().Set("Cache-Control", "no-cache")
+	defer stream.StartHeartbeat(config.Get().StreamHeartbeatInterval)()
+
+	stored, failed := 0, 0
+	var writeErr error
+	// Every result is read, so the workers finish even when the client is gone
+	for result := range services.IngestService.IngestCompanies(ctx.Request.Context(), names) {
+		if result.Status == services.IngestStored {
+			stored++
+		} else {
+			failed++
+		}
+		if writeErr == nil {
+			writeErr = stream.WriteEntry(result)
+		}
+	}
+	if writeErr == nil {
+		writeErr = stream.WriteEntry(gin.H{"type": "summary", "names": len(names), "stored": stored, "failed": failed})
+	}
+	if writeErr == nil {
+		writeErr = stream.Close()
+	}
+	if writeErr != nil {
+		helpers.Logger(ctx).Error("Error streaming ingest results", zap.Error(writeErr))
+	}
+}
+
+// internalError reports err to sentry and answers a 500 without it: the wrapped Mongo errors name the
+// hosts and collections of the deployment
+func internalError(ctx *gin.Context, err error) {
+	sentry.CaptureException(err)
+	helpers.Logger(ctx).Error("Internal error", zap.String("path", ctx.FullPath()), zap.Error(err))
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+}
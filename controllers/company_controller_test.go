@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	mongo_client "stockbackend/clients/mongo"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestInternalError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/company/:name", func(ctx *gin.Context) {
+		internalError(ctx, errors.New("server selection error: mongo.internal:27017"))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/company/acme", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected %v, got %v", http.StatusInternalServerError, w.Code)
+	}
+	if strings.Contains(w.Body.String(), "mongo.internal") {
+		t.Errorf("Expected the error to be kept out of the response, got %s", w.Body.String())
+	}
+}
+
+func TestFactSheet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/company/:name/factsheet.pdf", CompanyController.FactSheet)
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("route", func(mt *mtest.T) {
+		previous, previousConfig := mongo_client.Client, config.Get()
+		mongo_client.Client = mt.Client
+		config.Set(config.FromMap(map[string]string{"DATABASE": "stocks", "COLLECTION": "companies"}))
+		defer func() {
+			mongo_client.Client = previous
+			config.Set(previousConfig)
+		}()
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "name", Value: "Infosys Ltd"},
+				{Key: "sector", Value: "IT - Software"},
+				{Key: "marketCap", Value: "6,48,000"},
+				{Key: "pros", Value: bson.A{"Company has a good return on equity"}},
+				{Key: "profitLossHeaders", Value: bson.A{"Mar 2023", "Mar 2024"}},
+				{Key: "profitLoss", Value: bson.A{bson.D{{Key: "label", Value: "Sales +"}, {Key: "values", Value: bson.A{"1,46,767", "1,53,670"}}}}},
+				// A shape that doesn't fit the typed document doesn't fail the fact sheet
+				{Key: "fScore", Value: 6.5},
+			}),
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch),
+		)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/company/Infosys%20Ltd/factsheet.pdf", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %v, got %v: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != helpers.FactSheetContentType {
+			t.Errorf("Expected %s, got %s", helpers.FactSheetContentType, contentType)
+		}
+		if !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")) || w.Body.Len() < 500 {
+			t.Errorf("Expected a PDF, got %d bytes", w.Body.Len())
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/company/Unknown/factsheet.pdf", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected %v for an unknown company, got %v", http.StatusNotFound, w.Code)
+		}
+	})
+}
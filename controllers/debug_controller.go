@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"stockbackend/services"
+	"stockbackend/utils/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DebugControllerI interface {
+	GetStoredHTML(ctx *gin.Context)
+}
+
+type debugController struct{}
+
+var DebugController DebugControllerI = &debugController{}
+
+func (d *debugController) GetStoredHTML(ctx *gin.Context) {
+	// Debug routes are invisible unless explicitly enabled
+	if !helpers.DebugEndpointsEnabled() {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	html, err := services.DebugService.GetStoredHTML(ctx, ctx.Param("name"))
+	if errors.Is(err, services.ErrDebugHTMLNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", html)
+}
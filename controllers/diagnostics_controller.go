@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"stockbackend/clients/datasource"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+type DiagnosticsControllerI interface {
+	Scrape(ctx *gin.Context)
+}
+
+type diagnosticsController struct{}
+
+var DiagnosticsController DiagnosticsControllerI = &diagnosticsController{}
+
+// Scrape runs the data source's company page scraper, peers included, against ?url=, a page of
+// COMPANY_URL, without storing anything. A failed scrape is still a 200, the report carries the error.
+func (d *diagnosticsController) Scrape(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	target, err := helpers.DiagnosticsURL(ctx.Query("url"), config.Get().CompanyURL)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fetch := func(ctx context.Context, url string) (map[string]interface{}, error) {
+		return datasource.FetchCompanyWithPeers(ctx, datasource.Current(), url)
+	}
+	ctx.JSON(http.StatusOK, helpers.DiagnoseScrape(ctx, target, fetch))
+}
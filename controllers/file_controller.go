@@ -2,17 +2,35 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"io"
+	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
+	"stockbackend/config"
+	"stockbackend/middlewares"
 	"stockbackend/services"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"strconv"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	defaultPreviewRows = 5
+	maxPreviewRows     = 100
 )
 
 type FileControllerI interface {
 	ParseXLSXFile(ctx *gin.Context)
+	PreviewXLSXFile(ctx *gin.Context)
+	ParseXLSXFromURL(ctx *gin.Context)
+	ParseHoldingsJSON(ctx *gin.Context)
 }
 
 type fileController struct{}
@@ -29,6 +47,13 @@ func (f *fileController) ParseXLSXFile(ctx *gin.Context) {
 	span := sentry.StartSpan(context.TODO(), "ParseXLSXFile")
 	defer span.Finish()
 
+	// ndjson (default), json array or server-sent events
+	format, err := helpers.ParseStreamFormat(ctx.Query("format"))
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Parse the form and retrieve the uploaded files
 	form, err := ctx.MultipartForm()
 	if err != nil {
@@ -45,6 +70,16 @@ func (f *fileController) ParseXLSXFile(ctx *gin.Context) {
 		return
 	}
 
+	// Optional password for protected workbooks, never logged
+	password := formValue(form.Value, "password")
+
+	// Optional fund the holdings belong to
+	fund, err := helpers.ParseFundTag(formValue(form.Value, "fundName"), formValue(form.Value, "amc"), formValue(form.Value, "asOfDate"), time.Now())
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
 	uploadDir := "./uploads"
 	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
 		span.Status = sentry.SpanStatusFailedPrecondition
@@ -52,8 +87,41 @@ func (f *fileController) ParseXLSXFile(ctx *gin.Context) {
 		ctx.JSON(500, gin.H{"error": "Error creating upload directory"})
 		return
 	}
-	var savedFilePaths = make(chan string, len(files))
+	// The spreadsheets of the zips count against MAX_UPLOAD_FILES along with the files uploaded as they are
+	remainingFiles := middlewares.MaxUploadFiles()
 	for _, file := range files {
+		if !helpers.IsZipUpload(file.Filename) {
+			remainingFiles--
+		}
+	}
+	var saved []string
+	var rejected []helpers.ZipEntry
+	for _, file := range files {
+		if helpers.IsZipUpload(file.Filename) {
+			entries, dir, err := saveZipEntries(file, uploadDir, remainingFiles)
+			if err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, helpers.ErrZipTooLarge) || errors.Is(err, helpers.ErrZipTooMany) {
+					status = http.StatusRequestEntityTooLarge
+				} else if !errors.Is(err, helpers.ErrZipInvalid) && !errors.Is(err, helpers.ErrZipEmpty) {
+					status = http.StatusInternalServerError
+					sentry.CaptureException(err)
+				}
+				ctx.JSON(status, gin.H{"error": err.Error(), "file": filepath.Base(file.Filename)})
+				return
+			}
+			defer os.RemoveAll(dir)
+			for _, entry := range entries {
+				if entry.Err != nil {
+					rejected = append(rejected, entry)
+					continue
+				}
+				saved = append(saved, filepath.Join(dir, entry.Name))
+				remainingFiles--
+			}
+			continue
+		}
+
 		src, err := file.Open()
 		if err != nil {
 			span.Status = sentry.SpanStatusFailedPrecondition
@@ -82,16 +150,293 @@ func (f *fileController) ParseXLSXFile(ctx *gin.Context) {
 			return
 		}
 
-		savedFilePaths <- savePath
+		saved = append(saved, savePath)
 	}
-	close(savedFilePaths)
+
+	streamSavedFiles(ctx, span, format, saved, rejected, password, fund)
+}
+
+// ParseHoldingsJSON enriches a JSON array of holdings, checked by helpers.ValidateHoldings. The valid
+// holdings are processed either way, any invalid one makes the response a 422 listing the invalid
+// items by index and field.
+func (f *fileController) ParseHoldingsJSON(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	// Optional fund the holdings belong to
+	fund, err := helpers.ParseFundTag(ctx.Query("fundName"), ctx.Query("amc"), ctx.Query("asOfDate"), time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, middlewares.MaxUploadSize())
+	holdings, invalid, err := helpers.ValidateHoldings(ctx.Request.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload exceeds the maximum allowed size"})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enriched := services.FileService.ParseHoldings(ctx, holdings, fund)
+	status := http.StatusOK
+	if len(invalid) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	ctx.JSON(status, gin.H{"holdings": enriched, "errors": invalid})
+}
+
+// saveZipEntries extracts the spreadsheets of an uploaded zip into a directory of their own, at most
+// maxFiles of them, the entries are returned with the rejected ones (their Err set). The directory is
+// returned for removal once parsed, also when extraction fails after it was created.
+func saveZipEntries(file *multipart.FileHeader, uploadDir string, maxFiles int) ([]helpers.ZipEntry, string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(src)
+	src.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	entries, err := helpers.ExtractZipXLSX(data, middlewares.MaxZipUncompressedSize(), maxFiles)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dir, err := os.MkdirTemp(uploadDir, "zip-")
+	if err != nil {
+		return nil, "", err
+	}
+	for _, entry := range entries {
+		if entry.Err != nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.Name), entry.Data, 0o644); err != nil {
+			os.RemoveAll(dir)
+			return nil, "", err
+		}
+	}
+	return entries, dir, nil
+}
+
+type xlsxFromURLRequest struct {
+	URL      string `json:"url" binding:"required"`
+	Password string `json:"password"`
+	FundName string `json:"fundName"`
+	AMC      string `json:"amc"`
+	AsOfDate string `json:"asOfDate"`
+}
+
+// ParseXLSXFromURL downloads the spreadsheet published at the url of the JSON body and parses it
+// like an upload of that file. The download is limited to MAX_UPLOAD_SIZE_MB and may only reach
+// public addresses.
+func (f *fileController) ParseXLSXFromURL(ctx *gin.Context) {
+	defer sentry.Recover()
+	transaction := sentry.TransactionFromContext(ctx)
+	if transaction != nil {
+		transaction.Name = "ParseXLSXFile"
+	}
+
+	span := sentry.StartSpan(context.TODO(), "ParseXLSXFile")
+	defer span.Finish()
+
+	format, err := helpers.ParseStreamFormat(ctx.Query("format"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request xlsxFromURLRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fund, err := helpers.ParseFundTag(request.FundName, request.AMC, request.AsOfDate, time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uploadDir := "./uploads"
+	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+		sentry.CaptureException(err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating upload directory"})
+		return
+	}
+	// Each download gets its own directory so files of the same name don't collide
+	dir, err := os.MkdirTemp(uploadDir, "remote-")
+	if err != nil {
+		sentry.CaptureException(err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating upload directory"})
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	savePath, err := downloadXLSX(ctx, request.URL, dir)
+	switch {
+	case err == nil:
+	case errors.Is(err, helpers.ErrRemoteInvalidURL), errors.Is(err, helpers.ErrRemoteForbiddenHost):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, helpers.ErrRemoteNotSpreadsheet):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, helpers.ErrRemoteTooLarge):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, helpers.ErrRemoteUnreachable):
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	default:
+		sentry.CaptureException(err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving file"})
+		return
+	}
+
+	streamSavedFiles(ctx, span, format, []string{savePath}, nil, request.Password, fund)
+}
+
+// downloadXLSX saves the spreadsheet at the URL in dir under its published name
+func downloadXLSX(ctx context.Context, url string, dir string) (string, error) {
+	cfg := config.Get()
+	fetcher := helpers.NewRemoteXLSXFetcher(cfg.RemoteXLSXTimeout, middlewares.MaxUploadSize(), cfg.RemoteXLSXAllowPrivate)
+
+	tmp, err := os.CreateTemp(dir, "download-")
+	if err != nil {
+		return "", err
+	}
+	filename, err := fetcher.Fetch(ctx, url, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	savePath := filepath.Join(dir, filepath.Base(filename))
+	if err := os.Rename(tmp.Name(), savePath); err != nil {
+		return "", err
+	}
+	return savePath, nil
+}
+
+// PreviewXLSXFile runs only the header detection on the uploaded workbooks and returns, per sheet,
+// the columns mapped onto the standard keys and the first rows as they would be extracted. The
+// files are read in memory: nothing is looked up in Mongo, scraped or uploaded to Cloudinary.
+func (f *fileController) PreviewXLSXFile(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	limit := defaultPreviewRows
+	if raw := ctx.Query("rows"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 || value > maxPreviewRows {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid rows: " + strconv.Quote(raw) + ", expected 0 to " + strconv.Itoa(maxPreviewRows)})
+			return
+		}
+		limit = value
+	}
+
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Error parsing form data"})
+		return
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "No files found"})
+		return
+	}
+	password := formValue(form.Value, "password")
+
+	previews := []gin.H{}
+	for _, file := range files {
+		filename := filepath.Base(file.Filename)
+		src, err := file.Open()
+		if err != nil {
+			sentry.CaptureException(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error opening file"})
+			return
+		}
+		sheets, err := helpers.ReadXLSXSheets(src, excelize.Options{Password: password})
+		src.Close()
+		if helpers.IsXLSXPasswordError(err) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "file": filename})
+			return
+		}
+		if err != nil {
+			previews = append(previews, gin.H{"file": filename, "error": err.Error()})
+			continue
+		}
+
+		sheetPreviews := []types.SheetPreview{}
+		for _, sheet := range sheets {
+			sheetPreviews = append(sheetPreviews, helpers.PreviewSheet(sheet, limit))
+		}
+		previews = append(previews, gin.H{"file": filename, "sheets": sheetPreviews})
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"files": previews})
+}
+
+// streamSavedFiles parses the saved files and streams their holdings in the format, the rejected
+// entries of uploaded zips are reported as failed files first
+func streamSavedFiles(ctx *gin.Context, span *sentry.Span, format helpers.StreamFormat, saved []string, rejected []helpers.ZipEntry, password string, fund types.FundTag) {
+	// Only the requested fields of each holding are streamed, see helpers.ParseFieldProjection
+	fields, err := helpers.ParseFieldProjection(ctx.Query("fields"), config.Get().ResponseDenylist)
+	if err != nil {
+		for _, path := range saved {
+			os.Remove(path)
+		}
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Reject protected workbooks up front, before the response starts streaming
+	for _, savePath := range saved {
+		if err := checkWorkbookPassword(savePath, password); err != nil {
+			for _, path := range saved {
+				os.Remove(path)
+			}
+			ctx.JSON(400, gin.H{"error": err.Error(), "file": filepath.Base(savePath)})
+			return
+		}
+	}
+
+	stream := helpers.NewStreamWriter(ctx.Writer, format)
+	stream.SetFields(fields)
+	services.SetStreamWriter(ctx, stream)
 
 	// Set headers for chunked transfer (if needed)
-	ctx.Writer.Header().Set("Content-Type", "text/plain")
+	ctx.Writer.Header().Set("Content-Type", stream.ContentType())
 	ctx.Writer.Header().Set("Cache-Control", "no-cache")
 	ctx.Writer.Header().Set("Connection", "keep-alive")
 
-	err = services.FileService.ParseXLSXFile(ctx, savedFilePaths)
+	savedFilePaths := make(chan string, len(saved))
+	for _, savePath := range saved {
+		savedFilePaths <- savePath
+	}
+	close(savedFilePaths)
+
+	// Keeps the stream alive while holdings are scraped, stopped before anything else writes the response
+	stopHeartbeat := stream.StartHeartbeat(config.Get().StreamHeartbeatInterval)
+	defer stopHeartbeat()
+	err = services.FileService.ParseXLSXFile(ctx, savedFilePaths, rejected, password, fund)
+	stopHeartbeat()
+	// Too many failed files fail the upload, the response already streamed so it ends with the error
+	if errors.Is(err, helpers.ErrUploadFilesFailed) {
+		span.Status = sentry.SpanStatusFailedPrecondition
+		if err := stream.WriteEntry(gin.H{"type": "error", "error": err.Error()}); err != nil {
+			sentry.CaptureException(err)
+		}
+		if err := stream.Close(); err != nil {
+			sentry.CaptureException(err)
+		}
+		return
+	}
 	if err != nil {
 		span.Status = sentry.SpanStatusFailedPrecondition
 		sentry.CaptureException(err)
@@ -100,6 +445,31 @@ func (f *fileController) ParseXLSXFile(ctx *gin.Context) {
 	}
 
 	span.Status = sentry.SpanStatusOK
-	ctx.Writer.Write([]byte("\nStream complete.\n"))
-	ctx.Writer.Flush() // Ensure the final response is sent
+	// Ends the stream and ensures the final response is sent
+	if err := stream.Close(); err != nil {
+		sentry.CaptureException(err)
+	}
+}
+
+// formValue returns the first value of a multipart form field, empty when it is missing
+func formValue(values map[string][]string, key string) string {
+	if len(values[key]) > 0 {
+		return values[key][0]
+	}
+	return ""
+}
+
+// checkWorkbookPassword only reports a missing or wrong password, other read failures
+// (e.g. corrupt workbooks) are reported per file in the stream
+func checkWorkbookPassword(path string, password string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	if err := helpers.CheckXLSXPassword(file, password); helpers.IsXLSXPasswordError(err) {
+		return err
+	}
+	return nil
 }
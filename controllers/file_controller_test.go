@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"stockbackend/services"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeFileService returns the holdings it is given, recording them
+type fakeFileService struct {
+	services.FileServiceI
+	holdings []types.HoldingSnapshot
+	fund     types.FundTag
+}
+
+func (f *fakeFileService) ParseHoldings(ctx *gin.Context, holdings []types.HoldingSnapshot, fund types.FundTag) []map[string]interface{} {
+	f.holdings, f.fund = holdings, fund
+	enriched := []map[string]interface{}{}
+	for _, holding := range holdings {
+		enriched = append(enriched, helpers.HoldingDetail(holding))
+	}
+	return enriched
+}
+
+func TestParseHoldingsJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/uploadJson", FileController.ParseHoldingsJSON)
+
+	previous := services.FileService
+	defer func() { services.FileService = previous }()
+
+	tests := []struct {
+		name     string
+		query    string
+		body     string
+		status   int
+		holdings int
+		fund     string
+		errors   []helpers.HoldingValidationError
+	}{
+		{"valid", "?fundName=Flexi%20Cap%20Fund", `[{"name": "Infosys Limited", "isin": "INE009A01021", "quantity": 1000}]`, http.StatusOK, 1, "Flexi Cap Fund", []helpers.HoldingValidationError{}},
+		{"some invalid", "", `[{"name": "Infosys Limited", "isin": "INE009A01022", "quantity": 1000}, {"name": "Tata Consultancy Services Limited", "quantity": 10}]`, http.StatusUnprocessableEntity, 1, "", []helpers.HoldingValidationError{
+			{Index: 0, Field: "isin", Error: "check digit doesn't match"},
+		}},
+		{"not an array", "", `{"name": "Infosys Limited"}`, http.StatusBadRequest, 0, "", nil},
+		{"invalid fund date", "?asOfDate=yesterday", `[]`, http.StatusBadRequest, 0, "", nil},
+	}
+
+	for _, test := range tests {
+		fake := &fakeFileService{}
+		services.FileService = fake
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/uploadJson"+test.query, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%s: expected %v, got %v: %s", test.name, test.status, w.Code, w.Body.String())
+			continue
+		}
+		if test.status == http.StatusBadRequest {
+			if fake.holdings != nil {
+				t.Errorf("%s: expected nothing to be processed, got %+v", test.name, fake.holdings)
+			}
+			continue
+		}
+
+		var response struct {
+			Holdings []map[string]interface{}         `json:"holdings"`
+			Errors   []helpers.HoldingValidationError `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: unexpected response %s: %v", test.name, w.Body.String(), err)
+		}
+		// The valid holdings are processed whatever the invalid ones
+		if len(fake.holdings) != test.holdings || len(response.Holdings) != test.holdings {
+			t.Errorf("%s: expected %d holdings processed, got %+v", test.name, test.holdings, response.Holdings)
+		}
+		if fake.fund.FundName != test.fund {
+			t.Errorf("%s: expected the fund %q, got %q", test.name, test.fund, fake.fund.FundName)
+		}
+		if len(response.Errors) != len(test.errors) || (len(test.errors) > 0 && response.Errors[0] != test.errors[0]) {
+			t.Errorf("%s: expected the errors %+v, got %+v", test.name, test.errors, response.Errors)
+		}
+	}
+}
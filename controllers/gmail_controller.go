@@ -11,6 +11,7 @@ import (
 	"os"
 	"regexp"
 	"stockbackend/services"
+	"stockbackend/types"
 	"strings"
 	"sync"
 	"time"
@@ -143,7 +144,7 @@ func (g *gmailController) GetEmails(ctx *gin.Context) {
 	}()
 
 	// Process XLSX files
-	err = services.FileService.ParseXLSXFile(ctx, fileList)
+	err = services.FileService.ParseXLSXFile(ctx, fileList, nil, "", types.FundTag{AsOfDate: time.Now().UTC()})
 	if err != nil {
 		sentrySpan.Status = sentry.SpanStatusFailedPrecondition
 		sentry.CaptureException(err)
@@ -1,15 +1,65 @@
 package controllers
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"net/http"
+	mongo_client "stockbackend/clients/mongo"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.uber.org/zap"
+)
 
 type HealthControllerI interface {
 	IsRunning(ctx *gin.Context)
+	Livez(ctx *gin.Context)
+	Readyz(ctx *gin.Context)
+	Health(ctx *gin.Context)
 }
 
 type healthController struct{}
 
 var HealthController HealthControllerI = &healthController{}
 
+// readinessTimeout bounds the Mongo ping of a readiness probe
+const readinessTimeout = 2 * time.Second
+
 func (h *healthController) IsRunning(ctx *gin.Context) {
 	ctx.JSON(200, gin.H{"message": "Server is running"})
 }
+
+// Livez answers as long as the process is up, it checks no dependency
+func (h *healthController) Livez(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz answers 200 when Mongo is reachable and the required config is present, 503 otherwise
+func (h *healthController) Readyz(ctx *gin.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	report := helpers.CheckReadiness(pingCtx, config.Get(), func(ctx context.Context) error {
+		if mongo_client.Client == nil {
+			return mongo_client.ErrNotConfigured
+		}
+		// Not ready while the startup connection is still being retried
+		if !mongo_client.Connected() {
+			return mongo_client.ErrConnecting
+		}
+		return mongo_client.Client.Ping(ctx, readpref.Primary())
+	})
+	if !report.Ready {
+		zap.L().Warn("Not ready", zap.Any("checks", report.Checks))
+		ctx.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// Health reports the load of the upload queue: the uploads being processed and those waiting for a slot
+func (h *healthController) Health(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "uploads": helpers.Uploads().Stats()})
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	mongo_client "stockbackend/clients/mongo"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadyz_MongoNotConfigured(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"DATABASE": "stocks", "COLLECTION": "companies", "COMPANY_URL": "https://example.com"}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/readyz", HealthController.Readyz)
+
+	if mongo_client.Client != nil {
+		t.Skip("MONGO_URI is set, the client exists")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected %v, got %v", http.StatusServiceUnavailable, w.Code)
+	}
+
+	var report helpers.ReadinessReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Error decoding the report: %v", err)
+	}
+	if report.Ready || report.Checks["mongo"] != "MongoDB client not configured" || report.Checks["config"] != "missing MONGO_URI" {
+		t.Errorf("Expected the missing client and URI to be reported, got %+v", report)
+	}
+}
+
+func TestLivez(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/livez", HealthController.Livez)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/livez", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected %v, got %v", http.StatusOK, w.Code)
+	}
+}
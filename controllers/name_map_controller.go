@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"stockbackend/services"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+type NameMapControllerI interface {
+	Import(ctx *gin.Context)
+	List(ctx *gin.Context)
+}
+
+type nameMapController struct{}
+
+var NameMapController NameMapControllerI = &nameMapController{}
+
+type nameMapImportRequest struct {
+	Entries []types.NameMapEntry `json:"entries" binding:"required"`
+}
+
+// Import upserts a list of company name -> ISIN -> screener URL entries into the name map
+func (n *nameMapController) Import(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	var request nameMapImportRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inserted, updated, err := services.NameMapService.Import(ctx, request.Entries)
+	if errors.Is(err, helpers.ErrInvalidNameMap) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"inserted": inserted, "updated": updated})
+}
+
+// List returns the whole name map sorted by name
+func (n *nameMapController) List(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	entries, err := services.NameMapService.List(ctx)
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"entries": entries})
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"net/http"
+	"stockbackend/utils/helpers"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+type ScoreControllerI interface {
+	FScore(ctx *gin.Context)
+	ScoringConfig(ctx *gin.Context)
+}
+
+type scoreController struct{}
+
+var ScoreController ScoreControllerI = &scoreController{}
+
+// FScore computes the F-Score of the tables in the body, without touching stored companies
+func (s *scoreController) FScore(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	var stock map[string]interface{}
+	if err := ctx.ShouldBindJSON(&stock); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if missing := helpers.MissingFScoreSections(stock); len(missing) > 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing or invalid sections", "missing": missing})
+		return
+	}
+
+	breakdown := helpers.GenerateFScoreBreakdown(stock)
+	if breakdown.Score < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing required rows", "missing": breakdown.Missing})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, breakdown)
+}
+
+// ScoringConfig returns the scoring config in effect with its hash, the scoringConfig stored with
+// the scores it computes
+func (s *scoreController) ScoringConfig(ctx *gin.Context) {
+	scoring := helpers.CurrentScoringConfig()
+	ctx.JSON(http.StatusOK, gin.H{"hash": scoring.Hash(), "config": scoring})
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"stockbackend/services"
+	"stockbackend/utils/helpers"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+type UploadControllerI interface {
+	Diff(ctx *gin.Context)
+	Unmatched(ctx *gin.Context)
+	Backlog(ctx *gin.Context)
+}
+
+type uploadController struct{}
+
+var UploadController UploadControllerI = &uploadController{}
+
+type diffRequest struct {
+	FromUploadID string                  `json:"fromUploadId" binding:"required"`
+	ToUploadID   string                  `json:"toUploadId" binding:"required"`
+	Thresholds   *helpers.DiffThresholds `json:"thresholds"`
+}
+
+func (u *uploadController) Diff(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	var request diffRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// By default any quantity change, or a move of more than 0.1 percentage points of AUM, is reported
+	thresholds := helpers.DiffThresholds{QuantityPct: 0, PercentageOfAUM: 0.1}
+	if request.Thresholds != nil {
+		thresholds = *request.Thresholds
+	}
+
+	diff, err := services.UploadService.DiffUploads(ctx, request.FromUploadID, request.ToUploadID, thresholds)
+	if errors.Is(err, services.ErrUploadNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, diff)
+}
+
+// Unmatched lists the holdings of an upload whose company couldn't be found or scraped, with the
+// closest stored names, to grow the name map from
+func (u *uploadController) Unmatched(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	unmatched, err := services.UploadService.GetUnmatched(ctx, ctx.Param("id"))
+	if errors.Is(err, services.ErrUploadNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"uploadId": ctx.Param("id"), "unmatched": unmatched})
+}
+
+// Backlog returns the holdings an upload left past its deadline, pending while they are enriched in the
+// background and enriched once it finished
+func (u *uploadController) Backlog(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	backlog, err := services.UploadService.GetBacklog(ctx, ctx.Param("id"))
+	if errors.Is(err, services.ErrUploadNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+	if backlog == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "upload has no backlog"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"uploadId": ctx.Param("id"), "backlog": backlog})
+}
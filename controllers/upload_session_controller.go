@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"stockbackend/services"
+	"stockbackend/utils/helpers"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+type UploadSessionControllerI interface {
+	CreateSession(ctx *gin.Context)
+	UploadChunk(ctx *gin.Context)
+	SessionStatus(ctx *gin.Context)
+	ParseSession(ctx *gin.Context)
+}
+
+type uploadSessionController struct{}
+
+var UploadSessionController UploadSessionControllerI = &uploadSessionController{}
+
+type createSessionRequest struct {
+	Filename string `json:"filename" binding:"required"`
+}
+
+func (u *uploadSessionController) CreateSession(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	var request createSessionRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status, err := services.UploadSessions().Create(request.Filename)
+	if errors.Is(err, helpers.ErrTooManyUploadSessions) {
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, status)
+}
+
+func (u *uploadSessionController) UploadChunk(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	contentRange, err := helpers.ParseContentRange(ctx.GetHeader("Content-Range"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if ctx.Request.ContentLength >= 0 && ctx.Request.ContentLength != contentRange.Length() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "the body length doesn't match the Content-Range"})
+		return
+	}
+
+	status, err := services.UploadSessions().WriteChunk(ctx.Param("id"), contentRange, ctx.Request.Body)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, status)
+	case errors.Is(err, helpers.ErrUploadSessionNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, helpers.ErrInvalidContentRange):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "status": status})
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		// The bytes read before the connection dropped are kept, the client resends the rest
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "the chunk ended early", "status": status})
+	default:
+		internalError(ctx, err)
+	}
+}
+
+func (u *uploadSessionController) SessionStatus(ctx *gin.Context) {
+	defer sentry.Recover()
+
+	status, err := services.UploadSessions().Status(ctx.Param("id"))
+	if errors.Is(err, helpers.ErrUploadSessionNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, status)
+}
+
+// ParseSession parses the assembled file of a complete session like an upload of that file, the
+// password and fund fields are read from the (urlencoded or multipart) form
+func (u *uploadSessionController) ParseSession(ctx *gin.Context) {
+	defer sentry.Recover()
+	transaction := sentry.TransactionFromContext(ctx)
+	if transaction != nil {
+		transaction.Name = "ParseXLSXFile"
+	}
+
+	span := sentry.StartSpan(context.TODO(), "ParseXLSXFile")
+	defer span.Finish()
+
+	format, err := helpers.ParseStreamFormat(ctx.Query("format"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fund, err := helpers.ParseFundTag(ctx.PostForm("fundName"), ctx.PostForm("amc"), ctx.PostForm("asOfDate"), time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	path, status, err := services.UploadSessions().Take(ctx.Param("id"))
+	if errors.Is(err, helpers.ErrUploadSessionNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, helpers.ErrUploadIncomplete) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": status})
+		return
+	}
+	// The file is removed once parsed, its session directory with it
+	defer os.RemoveAll(filepath.Dir(path))
+
+	streamSavedFiles(ctx, span, format, []string{path}, nil, ctx.PostForm("password"), fund)
+}
@@ -7,8 +7,12 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"stockbackend/clients/tracing"
+	"stockbackend/config"
+	"stockbackend/middlewares"
 	"stockbackend/routes"
-	"strconv"
+	"stockbackend/services"
+	"stockbackend/utils/helpers"
 	"syscall"
 	"time"
 
@@ -46,6 +50,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, trell-auth-token, trell-app-version-int, creator-space-auth-token")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Upload-Id, X-Request-Id")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -54,8 +59,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-// GracefulShutdown handles graceful shutdown of the server and ticker
-func GracefulShutdown(server *http.Server, ticker *time.Ticker) {
+// GracefulShutdown handles graceful shutdown of the server and tickers
+func GracefulShutdown(server *http.Server, tickers ...*time.Ticker) {
 	stopper := make(chan os.Signal, 1)
 	// Listen for interrupt and SIGTERM signals
 	signal.Notify(stopper, os.Interrupt, syscall.SIGTERM)
@@ -64,8 +69,12 @@ func GracefulShutdown(server *http.Server, ticker *time.Ticker) {
 		<-stopper
 		zap.L().Info("Shutting down gracefully...")
 
-		// Stop the ticker
-		ticker.Stop()
+		// Stop the tickers
+		for _, ticker := range tickers {
+			ticker.Stop()
+		}
+		// The upload backlogs still pending stay recorded on their uploads
+		helpers.Backlogs().Stop()
 
 		// Create a context with a timeout for shutdown
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -76,59 +85,72 @@ func GracefulShutdown(server *http.Server, ticker *time.Ticker) {
 			zap.L().Error("Server shutdown failed", zap.Error(err))
 			return
 		}
+
+		// Flush the spans still buffered
+		if err := tracing.Shutdown(ctx); err != nil {
+			zap.L().Error("Tracing shutdown failed", zap.Error(err))
+		}
 		zap.L().Info("Server exited gracefully")
 	}()
 }
 
 func setupSentry() {
-	tracesSampleRate, err := strconv.ParseFloat(os.Getenv("SENTRY_SAMPLE_RATE"), 64)
-	if err != nil {
-		tracesSampleRate = 1.0
-	}
+	cfg := config.Get()
 
 	if err := sentry.Init(sentry.ClientOptions{
-		Dsn:           os.Getenv("SENTRY_DSN"),
-		Environment:   os.Getenv("ENVIRONMENT"),
+		Dsn:           cfg.SentryDSN,
+		Environment:   cfg.Environment,
 		EnableTracing: true,
 		Debug:         true,
 		// Set TracesSampleRate to 1.0 to capture 100%
 		// of transactions for tracing.
 		// Sentry recommend adjusting this value in production,
-		TracesSampleRate: tracesSampleRate, // 1.0 by default if ENV SENTRY_SAMPLE_RATE not set
+		TracesSampleRate: cfg.SentrySampleRate, // 1.0 by default if ENV SENTRY_SAMPLE_RATE not set
 	}); err != nil {
 		zap.L().Error("Sentry initialization failed: ", zap.Any("error", err.Error()))
 	}
 }
 
 func main() {
-	config := zap.NewProductionConfig()
-	config.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
-	logger, _ := config.Build()
+	loggerConfig := zap.NewProductionConfig()
+	loggerConfig.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+	logger, _ := loggerConfig.Build()
 	zap.ReplaceGlobals(logger)
 
 	setupSentry()
+	if err := tracing.Init(context.Background()); err != nil {
+		zap.L().Error("Tracing initialization failed", zap.Error(err))
+	}
 
 	router := gin.New()
+	router.MaxMultipartMemory = middlewares.MaxMultipartMemory()
 	router.Use(sentrygin.New(sentrygin.Options{}))
 	router.Use(CORSMiddleware())
+	router.Use(middlewares.RequestLogger())
 
 	ticker := startTicker()
+	tickers := []*time.Ticker{ticker}
+	if refreshTicker := startRefreshTicker(); refreshTicker != nil {
+		tickers = append(tickers, refreshTicker)
+	}
 
 	routes.Routes(router)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "4000"
-	}
+	cfg := config.Get()
 
-	// Create a server instance using gin engine as handler
+	// Create a server instance using gin engine as handler, the upload routes replace the read and
+	// write timeouts with their own (middlewares.UploadDeadlines)
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: router,
+		Addr:              ":" + cfg.Port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+		ReadTimeout:       cfg.ServerReadTimeout,
+		WriteTimeout:      cfg.ServerWriteTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
 	}
 
-	// Call GracefulShutdown with the server and ticker
-	GracefulShutdown(server, ticker)
+	// Call GracefulShutdown with the server and tickers
+	GracefulShutdown(server, tickers...)
 
 	// Start the server
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -158,3 +180,30 @@ func startTicker() *time.Ticker {
 
 	return ticker
 }
+
+// startRefreshTicker periodically scrapes again the stored companies that went stale,
+// it returns nil unless REFRESH_ENABLED is true
+func startRefreshTicker() *time.Ticker {
+	cfg := config.Get()
+	if !cfg.RefreshEnabled {
+		return nil
+	}
+	ticker := time.NewTicker(cfg.RefreshInterval)
+
+	go func() {
+		for t := range ticker.C {
+			zap.L().Info("Refreshing stale companies at: ", zap.String("time", t.String()))
+
+			refreshed, err := services.RefreshService.RefreshStale(context.Background())
+			if err != nil {
+				zap.L().Error("Error refreshing stale companies: ", zap.Error(err))
+				sentry.CaptureException(err)
+				continue
+			}
+
+			zap.L().Info("Refreshed stale companies", zap.Int("count", refreshed))
+		}
+	}()
+
+	return ticker
+}
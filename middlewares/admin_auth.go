@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"stockbackend/config"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth only lets requests through that carry the ADMIN_TOKEN as a bearer token.
+// When no ADMIN_TOKEN is configured the guarded endpoints are disabled entirely.
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminToken := config.Get().AdminToken
+		if adminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin endpoints are disabled"})
+			return
+		}
+
+		if !hasBearerToken(c, adminToken) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// APIAuth guards the public read and upload endpoints when an API_TOKEN is configured, requiring
+// it (or the ADMIN_TOKEN) as a bearer token. Without an API_TOKEN they stay open.
+func APIAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiToken := config.Get().APIToken
+		if apiToken == "" {
+			c.Next()
+			return
+		}
+
+		adminToken := config.Get().AdminToken
+		if !hasBearerToken(c, apiToken) && (adminToken == "" || !hasBearerToken(c, adminToken)) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TokenAuth guards the endpoints that must never be open, e.g. those keeping files on the server's
+// disk: it requires the API_TOKEN or the ADMIN_TOKEN as a bearer token whatever the API_TOKEN
+// setting, and disables them (403) when neither is configured
+func TokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Get()
+		if cfg.APIToken == "" && cfg.AdminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Endpoint disabled, no API_TOKEN or ADMIN_TOKEN is configured"})
+			return
+		}
+
+		if (cfg.APIToken == "" || !hasBearerToken(c, cfg.APIToken)) && (cfg.AdminToken == "" || !hasBearerToken(c, cfg.AdminToken)) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// hasBearerToken reports whether the request's Authorization header carries the token, compared
+// in constant time
+func hasBearerToken(c *gin.Context, expected string) bool {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"stockbackend/config"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func adminRequest(token string) *httptest.ResponseRecorder {
+	return authRequest(AdminAuth(), token)
+}
+
+func authRequest(auth gin.HandlerFunc, token string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/admin", auth, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAuth(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"ADMIN_TOKEN": "secret"}))
+
+	if w := adminRequest("secret"); w.Code != http.StatusOK {
+		t.Errorf("Expected %v, got %v", http.StatusOK, w.Code)
+	}
+	if w := adminRequest("wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %v, got %v", http.StatusUnauthorized, w.Code)
+	}
+	if w := adminRequest(""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %v, got %v", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAdminAuth_NoTokenConfigured(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{}))
+
+	if w := adminRequest("anything"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected %v, got %v", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestAPIAuth(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"ADMIN_TOKEN": "admin"}))
+
+	// Open without an API_TOKEN
+	if w := authRequest(APIAuth(), ""); w.Code != http.StatusOK {
+		t.Errorf("Expected %v, got %v", http.StatusOK, w.Code)
+	}
+
+	config.Set(config.FromMap(map[string]string{"ADMIN_TOKEN": "admin", "API_TOKEN": "public"}))
+	for token, expected := range map[string]int{"public": http.StatusOK, "admin": http.StatusOK, "wrong": http.StatusUnauthorized, "": http.StatusUnauthorized} {
+		if w := authRequest(APIAuth(), token); w.Code != expected {
+			t.Errorf("Token %q: expected %v, got %v", token, expected, w.Code)
+		}
+	}
+
+	// The API token doesn't open the admin endpoints
+	if w := adminRequest("public"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %v, got %v", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+
+	// Never open, even without an API_TOKEN
+	config.Set(config.FromMap(map[string]string{}))
+	if w := authRequest(TokenAuth(), "anything"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected %v, got %v", http.StatusForbidden, w.Code)
+	}
+
+	config.Set(config.FromMap(map[string]string{"ADMIN_TOKEN": "admin"}))
+	for token, expected := range map[string]int{"admin": http.StatusOK, "wrong": http.StatusUnauthorized, "": http.StatusUnauthorized} {
+		if w := authRequest(TokenAuth(), token); w.Code != expected {
+			t.Errorf("Token %q: expected %v, got %v", token, expected, w.Code)
+		}
+	}
+
+	config.Set(config.FromMap(map[string]string{"API_TOKEN": "public"}))
+	for token, expected := range map[string]int{"public": http.StatusOK, "": http.StatusUnauthorized} {
+		if w := authRequest(TokenAuth(), token); w.Code != expected {
+			t.Errorf("Token %q: expected %v, got %v", token, expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// UploadDeadlines replaces the server's read and write timeouts with UPLOAD_READ_TIMEOUT_SECONDS and
+// UPLOAD_WRITE_TIMEOUT_SECONDS for an upload: the body of a large upload takes longer to read, and
+// its response keeps streaming holdings long after SERVER_WRITE_TIMEOUT_SECONDS
+func UploadDeadlines() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Get()
+		SetDeadlines(c, cfg.UploadReadTimeout, cfg.UploadWriteTimeout)
+		c.Next()
+	}
+}
+
+// SetDeadlines moves the read and write deadlines of the request's connection to the timeouts from
+// now, a zero timeout removing the deadline
+func SetDeadlines(c *gin.Context, read, write time.Duration) {
+	deadline := func(timeout time.Duration) time.Time {
+		if timeout <= 0 {
+			return time.Time{}
+		}
+		return time.Now().Add(timeout)
+	}
+
+	controller := http.NewResponseController(c.Writer)
+	err := errors.Join(controller.SetReadDeadline(deadline(read)), controller.SetWriteDeadline(deadline(write)))
+	if err != nil {
+		helpers.Logger(c).Warn("Error setting the connection deadlines", zap.Error(err))
+	}
+}
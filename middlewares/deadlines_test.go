@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"stockbackend/config"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slowStreamServer serves a response that only finishes after the server's write timeout
+func slowStreamServer(t *testing.T, middleware ...gin.HandlerFunc) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handlers := append(middleware, func(c *gin.Context) {
+		c.Writer.WriteString("first holding\n")
+		c.Writer.Flush()
+		time.Sleep(300 * time.Millisecond)
+		c.Writer.WriteString("last holding\n")
+	})
+	router.GET("/stream", handlers...)
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func readStream(server *httptest.Server) (string, error) {
+	resp, err := http.Get(server.URL + "/stream")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestUploadDeadlines(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"UPLOAD_WRITE_TIMEOUT_SECONDS": "0"}))
+
+	if body, err := readStream(slowStreamServer(t)); err == nil && body == "first holding\nlast holding\n" {
+		t.Fatalf("Expected the server write timeout to cut the stream short")
+	}
+
+	body, err := readStream(slowStreamServer(t, UploadDeadlines()))
+	if err != nil || body != "first holding\nlast holding\n" {
+		t.Errorf("Expected the whole stream without an upload write timeout, got %q (%v)", body, err)
+	}
+
+	config.Set(config.FromMap(map[string]string{"UPLOAD_WRITE_TIMEOUT_SECONDS": "0.05"}))
+	if body, err := readStream(slowStreamServer(t, UploadDeadlines())); err == nil && body == "first holding\nlast holding\n" {
+		t.Errorf("Expected UPLOAD_WRITE_TIMEOUT_SECONDS to cut the stream short")
+	}
+}
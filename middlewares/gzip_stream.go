@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// gzipStreamWriter compresses the response while keeping Flush working, every flush
+// emits the data compressed so far so streamed entries still reach the client promptly
+type gzipStreamWriter struct {
+	gin.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipStreamWriter) Write(data []byte) (int, error) {
+	return w.gz.Write(data)
+}
+
+func (w *gzipStreamWriter) WriteString(s string) (int, error) {
+	return w.gz.Write([]byte(s))
+}
+
+func (w *gzipStreamWriter) WriteHeader(code int) {
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipStreamWriter) Flush() {
+	if err := w.gz.Flush(); err != nil {
+		zap.L().Error("Error flushing gzip stream", zap.Error(err))
+	}
+	w.ResponseWriter.Flush()
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows a gzip response
+func acceptsGzip(header string) bool {
+	for _, encoding := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// GzipStream compresses streamed responses for clients sending Accept-Encoding: gzip,
+// other clients get the uncompressed stream
+func GzipStream() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		gz := gzip.NewWriter(c.Writer)
+		c.Header("Content-Encoding", "gzip")
+		c.Writer = &gzipStreamWriter{ResponseWriter: c.Writer, gz: gz}
+		defer func() {
+			if err := gz.Close(); err != nil {
+				zap.L().Error("Error closing gzip stream", zap.Error(err))
+			}
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newStreamRouter(t *testing.T, recorder *httptest.ResponseRecorder) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stream", GzipStream(), func(c *gin.Context) {
+		c.Writer.Write([]byte(`{"name":"first"}` + "\n"))
+		c.Writer.Flush()
+
+		// The flushed entry has to be readable before the response completes
+		if recorder.Header().Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(bytes.NewReader(recorder.Body.Bytes()))
+			if err != nil {
+				t.Errorf("Expected a gzip header after the first flush: %v", err)
+			} else if line, err := bufio.NewReader(gz).ReadString('\n'); err != nil || line != `{"name":"first"}`+"\n" {
+				t.Errorf("Expected the first entry after the flush, got %q (%v)", line, err)
+			}
+		}
+
+		c.Writer.Write([]byte(`{"name":"second"}` + "\n"))
+		c.Writer.Flush()
+	})
+	return router
+}
+
+func TestGzipStream_Compressed(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	newStreamRouter(t, recorder).ServeHTTP(recorder, req)
+
+	if recorder.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzip encoded response, got %q", recorder.Header().Get("Content-Encoding"))
+	}
+	gz, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatalf("Error opening gzip body: %v", err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Error decoding gzip body: %v", err)
+	}
+	if expected := "{\"name\":\"first\"}\n{\"name\":\"second\"}\n"; string(body) != expected {
+		t.Errorf("Expected %q, got %q", expected, body)
+	}
+}
+
+func TestGzipStream_Uncompressed(t *testing.T) {
+	for _, acceptEncoding := range []string{"", "deflate", "gzip;q=0"} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		newStreamRouter(t, recorder).ServeHTTP(recorder, req)
+
+		if recorder.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Expected no Content-Encoding for %q, got %q", acceptEncoding, recorder.Header().Get("Content-Encoding"))
+		}
+		if expected := "{\"name\":\"first\"}\n{\"name\":\"second\"}\n"; recorder.Body.String() != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, acceptEncoding, recorder.Body.String())
+		}
+	}
+}
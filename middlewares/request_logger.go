@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"stockbackend/utils/helpers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RequestLogger gives every request a correlation ID, returned in the X-Request-Id header, and a
+// logger tagged with it that handlers and services get back with helpers.Logger
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := uuid.New().String()
+		logger := zap.L().With(zap.String("uploadId", id))
+
+		c.Header(helpers.RequestIDHeader, id)
+		c.Set(helpers.RequestIDKey, id)
+		c.Set(helpers.LoggerKey, logger)
+		c.Request = c.Request.WithContext(helpers.WithRequestLogger(c.Request.Context(), id, logger))
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"stockbackend/utils/helpers"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestLogger())
+	router.GET("/logged", func(c *gin.Context) {
+		// Services get the logger back from the gin context or from contexts derived from the request
+		helpers.Logger(c).Info("from the gin context")
+		helpers.Logger(context.WithoutCancel(c.Request.Context())).Info("from the request context")
+		c.String(http.StatusOK, helpers.RequestID(c))
+	})
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logged", nil))
+		id := w.Header().Get(helpers.RequestIDHeader)
+		if id == "" || w.Body.String() != id {
+			t.Fatalf("Expected the correlation ID in the header and the context, got %q and %q", id, w.Body.String())
+		}
+		ids = append(ids, id)
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("Expected a new correlation ID per request, got %v", ids)
+	}
+
+	entries := logs.All()
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 log entries, got %d", len(entries))
+	}
+	// Each request logs twice, in order
+	expected := []string{ids[0], ids[0], ids[1], ids[1]}
+	for i, entry := range entries {
+		if id := entry.ContextMap()["uploadId"]; id != expected[i] {
+			t.Errorf("Expected %q of request %d to carry %q, got %v", entry.Message, i/2+1, expected[i], id)
+		}
+	}
+}
+
+func TestLogger_OutsideOfRequest(t *testing.T) {
+	if helpers.Logger(context.Background()) != zap.L() || helpers.RequestID(context.Background()) != "" {
+		t.Errorf("Expected the global logger and no ID outside of a request")
+	}
+}
@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadConcurrency processes at most MAX_CONCURRENT_UPLOADS uploads at once. An upload over the
+// limit waits up to UPLOAD_QUEUE_TIMEOUT_SECONDS for a slot before it is rejected with 429.
+func UploadConcurrency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uploadConcurrency(helpers.Uploads(), config.Get().UploadQueueTimeout)(c)
+	}
+}
+
+func uploadConcurrency(slots *helpers.UploadSlots, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := slots.Acquire(c.Request.Context(), timeout)
+		if errors.Is(err, helpers.ErrUploadSlotsFull) {
+			c.Header("Retry-After", strconv.Itoa(max(1, int(timeout.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many uploads in progress, try again later"})
+			return
+		}
+		if err != nil {
+			// The client went away while queued
+			c.Abort()
+			return
+		}
+		defer slots.Release()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"stockbackend/utils/helpers"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newConcurrencyRouter serves uploads that hold their slot until release is closed, recording the
+// most uploads seen in the handler at once
+func newConcurrencyRouter(slots *helpers.UploadSlots, timeout time.Duration, release <-chan struct{}, started chan<- struct{}) (*gin.Engine, *atomic.Int64) {
+	gin.SetMode(gin.TestMode)
+	var active, peak atomic.Int64
+	router := gin.New()
+	router.POST("/upload", uploadConcurrency(slots, timeout), func(c *gin.Context) {
+		now := active.Add(1)
+		defer active.Add(-1)
+		for {
+			seen := peak.Load()
+			if now <= seen || peak.CompareAndSwap(seen, now) {
+				break
+			}
+		}
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	return router, &peak
+}
+
+func TestUploadConcurrency_Limit(t *testing.T) {
+	const limit, uploads = 2, 6
+	slots := helpers.NewUploadSlots(limit)
+	release := make(chan struct{})
+	started := make(chan struct{}, uploads)
+	router, peak := newConcurrencyRouter(slots, time.Minute, release, started)
+
+	codes := make([]int, uploads)
+	var wg sync.WaitGroup
+	for i := range uploads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload", nil))
+			codes[i] = w.Code
+		}()
+	}
+
+	// The first uploads hold the slots, the others queue behind them
+	for range limit {
+		<-started
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for slots.Stats().Queued != uploads-limit && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := slots.Stats(); stats != (helpers.UploadSlotsStats{Limit: limit, Active: limit, Queued: uploads - limit}) {
+		t.Errorf("Unexpected stats while queued: %+v", stats)
+	}
+	close(release)
+	wg.Wait()
+
+	if peak.Load() != limit {
+		t.Errorf("Expected at most %d uploads at once, got %d", limit, peak.Load())
+	}
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Expected queued upload %d to be processed, got %v", i, code)
+		}
+	}
+	if stats := slots.Stats(); stats.Active != 0 || stats.Queued != 0 {
+		t.Errorf("Expected the slots to be released, got %+v", stats)
+	}
+}
+
+func TestUploadConcurrency_QueueTimeout(t *testing.T) {
+	slots := helpers.NewUploadSlots(1)
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	router, _ := newConcurrencyRouter(slots, 10*time.Millisecond, release, started)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/upload", nil))
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected a 429 with Retry-After, got %v %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(release)
+	<-done
+}
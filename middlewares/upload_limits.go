@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxUploadSize returns the maximum total request size for uploads in bytes (MAX_UPLOAD_SIZE_MB)
+func MaxUploadSize() int64 {
+	return config.Get().MaxUploadSize
+}
+
+// MaxUploadFiles returns the maximum number of files accepted per upload request (MAX_UPLOAD_FILES)
+func MaxUploadFiles() int {
+	return config.Get().MaxUploadFiles
+}
+
+// MaxZipUncompressedSize returns the maximum total size of the files inside an uploaded zip in bytes (MAX_ZIP_UNCOMPRESSED_MB)
+func MaxZipUncompressedSize() int64 {
+	return config.Get().MaxZipUncompressedSize
+}
+
+// MaxMultipartMemory returns the memory gin may use for multipart forms before spilling to disk (MAX_MULTIPART_MEMORY_MB)
+func MaxMultipartMemory() int64 {
+	return config.Get().MaxMultipartMemory
+}
+
+// UploadLimits caps the size of an upload request, the number of files in it and their combined size.
+// The parsed form is cached on the request so handlers can call ctx.MultipartForm() again for free.
+func UploadLimits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxSize := MaxUploadSize()
+		if c.Request.ContentLength > maxSize {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload exceeds the maximum allowed size"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+
+		form, err := c.MultipartForm()
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload exceeds the maximum allowed size"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Error parsing form data"})
+			return
+		}
+
+		files := form.File["files"]
+		if len(files) > MaxUploadFiles() {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Too many files in upload"})
+			return
+		}
+
+		var total int64
+		for _, file := range files {
+			total += file.Size
+		}
+		if total > maxSize {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload exceeds the maximum allowed size"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// UploadChunkLimits rejects the chunks of a resumable upload whose Content-Range announces a file
+// larger than MAX_UPLOAD_SIZE_MB, the chunk itself is read up to the length of its range
+func UploadChunkLimits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		contentRange, err := helpers.ParseContentRange(c.GetHeader("Content-Range"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if contentRange.Total > MaxUploadSize() {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload exceeds the maximum allowed size"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,124 @@
+package middlewares
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"stockbackend/config"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newUploadRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/upload", UploadLimits(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func multipartBody(t *testing.T, files map[string][]byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, content := range files {
+		part, err := writer.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatalf("Error creating form file: %v", err)
+		}
+		part.Write(content)
+	}
+	writer.Close()
+	return body, writer.FormDataContentType()
+}
+
+func TestUploadLimits_OversizedBody(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"MAX_UPLOAD_SIZE_MB": "1"}))
+	body, contentType := multipartBody(t, map[string][]byte{"big.xlsx": make([]byte, 2<<20)})
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	newUploadRouter().ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected %v, got %v", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestUploadLimits_OversizedBodyWithoutContentLength(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"MAX_UPLOAD_SIZE_MB": "1"}))
+	body, contentType := multipartBody(t, map[string][]byte{"big.xlsx": make([]byte, 2<<20)})
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	newUploadRouter().ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected %v, got %v", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestUploadLimits_TooManyFiles(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"MAX_UPLOAD_FILES": "1"}))
+	body, contentType := multipartBody(t, map[string][]byte{"a.xlsx": []byte("a"), "b.xlsx": []byte("b")})
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	newUploadRouter().ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected %v, got %v", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestUploadLimits_WithinLimits(t *testing.T) {
+	body, contentType := multipartBody(t, map[string][]byte{"a.xlsx": []byte("a")})
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	newUploadRouter().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected %v, got %v", http.StatusOK, w.Code)
+	}
+}
+
+func TestUploadChunkLimits(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"MAX_UPLOAD_SIZE_MB": "1"}))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/chunk", UploadChunkLimits(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for contentRange, expected := range map[string]int{
+		"bytes 0-9/1048576": http.StatusOK,
+		"bytes 0-9/1048577": http.StatusRequestEntityTooLarge,
+		"bytes 0-9/*":       http.StatusBadRequest,
+		"":                  http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/chunk", bytes.NewReader(make([]byte, 10)))
+		req.Header.Set("Content-Range", contentRange)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != expected {
+			t.Errorf("Content-Range %q: expected %v, got %v", contentRange, expected, w.Code)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"stockbackend/controllers"
+	"stockbackend/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,9 +11,50 @@ func Routes(r *gin.Engine) {
 
 	v1 := r.Group("/api")
 
+	// Probes stay open whatever the auth configuration
 	{
-		v1.POST("/uploadXlsx", controllers.FileController.ParseXLSXFile)
 		v1.GET("/keepServerRunning", controllers.HealthController.IsRunning)
-		v1.POST("/fetchGmail", controllers.GmailController.GetEmails)
+		v1.GET("/livez", controllers.HealthController.Livez)
+		v1.GET("/readyz", controllers.HealthController.Readyz)
+		v1.GET("/health", controllers.HealthController.Health)
+	}
+
+	// Read and upload endpoints, open unless an API_TOKEN is configured
+	public := v1.Group("", middlewares.APIAuth())
+	{
+		public.POST("/uploadXlsx", middlewares.UploadDeadlines(), middlewares.UploadConcurrency(), middlewares.UploadLimits(), middlewares.GzipStream(), controllers.FileController.ParseXLSXFile)
+		public.POST("/uploadXlsxFromUrl", middlewares.UploadDeadlines(), middlewares.UploadConcurrency(), middlewares.GzipStream(), controllers.FileController.ParseXLSXFromURL)
+		public.POST("/uploadJson", middlewares.UploadDeadlines(), middlewares.UploadConcurrency(), controllers.FileController.ParseHoldingsJSON)
+		public.POST("/preview", middlewares.UploadLimits(), controllers.FileController.PreviewXLSXFile)
+		public.POST("/uploadXlsx/sessions", middlewares.TokenAuth(), controllers.UploadSessionController.CreateSession)
+		public.PUT("/uploadXlsx/sessions/:id", middlewares.UploadDeadlines(), middlewares.UploadChunkLimits(), controllers.UploadSessionController.UploadChunk)
+		public.GET("/uploadXlsx/sessions/:id", controllers.UploadSessionController.SessionStatus)
+		public.POST("/uploadXlsx/sessions/:id/parse", middlewares.UploadDeadlines(), middlewares.UploadConcurrency(), controllers.UploadSessionController.ParseSession)
+		public.POST("/fetchGmail", middlewares.UploadDeadlines(), middlewares.UploadConcurrency(), controllers.GmailController.GetEmails)
+		public.GET("/debug/html/:name", controllers.DebugController.GetStoredHTML)
+		public.GET("/companies/list", controllers.CompanyController.ListCompanies)
+		public.POST("/diff", controllers.UploadController.Diff)
+		public.GET("/uploads/:id/unmatched", controllers.UploadController.Unmatched)
+		public.GET("/uploads/:id/backlog", controllers.UploadController.Backlog)
+		public.GET("/namemap", controllers.NameMapController.List)
+		public.POST("/fscore", controllers.ScoreController.FScore)
+		public.GET("/config/scoring", controllers.ScoreController.ScoringConfig)
+		public.GET("/company/:name", controllers.CompanyController.GetCompany)
+		public.GET("/company/:name/peers/history", controllers.CompanyController.PeerHistory)
+		public.GET("/company/:name/factsheet.pdf", controllers.CompanyController.FactSheet)
+		public.GET("/company/:name/history", controllers.CompanyController.FinancialHistory)
+	}
+
+	// Destructive and expensive endpoints, disabled unless an ADMIN_TOKEN is configured
+	admin := v1.Group("", middlewares.AdminAuth())
+	{
+		admin.GET("/companies/duplicates", controllers.CompanyController.ListDuplicates)
+		admin.POST("/companies/merge", controllers.CompanyController.MergeCompanies)
+		admin.POST("/namemap/import", controllers.NameMapController.Import)
+		admin.GET("/diagnostics/scrape", controllers.DiagnosticsController.Scrape)
+		admin.POST("/companies/ingest", middlewares.UploadDeadlines(), controllers.CompanyController.IngestCompanies)
+		admin.DELETE("/company/:name", controllers.CompanyController.DeleteCompany)
+		admin.POST("/company/:name/invalidate", controllers.CompanyController.InvalidateCompany)
+		admin.POST("/company/:name/refresh", controllers.CompanyController.RefreshCompany)
 	}
 }
@@ -0,0 +1,738 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"stockbackend/clients/datasource"
+	"stockbackend/clients/http_client"
+	mongo_client "stockbackend/clients/mongo"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/constants"
+	"stockbackend/utils/helpers"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type CompanyServiceI interface {
+	ListCompanies(ctx context.Context, query helpers.CompanyListQuery) ([]types.CompanySummary, error)
+	DeleteCompany(ctx context.Context, key string) (bool, error)
+	InvalidateCompany(ctx context.Context, key string) (bool, error)
+	ScrapeCompany(ctx context.Context, query string, extra bson.M, fund string) (string, error)
+	FindMapped(ctx context.Context, entry types.NameMapEntry) (bson.M, error)
+	ScrapeMapped(ctx context.Context, entry types.NameMapEntry, stored bson.M, extra bson.M, fund string) (string, error)
+	ScrapeStored(ctx context.Context, company bson.M) (string, error)
+	FindFuzzyMatch(ctx context.Context, name string) (bson.M, float64, error)
+	FuzzyCandidates(ctx context.Context, name string, limit int) ([]types.FuzzyCandidate, error)
+	RefreshCompany(ctx context.Context, name string) (bson.M, error)
+	GetCompany(ctx context.Context, key string, mode helpers.RevalidateMode) (bson.M, error)
+	GetCompanyDocument(ctx context.Context, key string) (types.CompanyDocument, error)
+	PeerHistory(ctx context.Context, key string) ([]helpers.PeerHistoryEntry, error)
+	FinancialHistory(ctx context.Context, key string, statement string, metrics []string) (map[string][]helpers.HistoryPoint, error)
+	MergeCompanies(ctx context.Context, firstKey, secondKey string) (bson.M, string, error)
+	ListDuplicates(ctx context.Context, threshold float64) ([]types.DuplicateCompanies, error)
+}
+
+// companyNamesTTL is how long the names used for fuzzy matching are reused
+const companyNamesTTL = 5 * time.Minute
+
+var (
+	ErrCompanyNotFound  = errors.New("no company found")
+	ErrCompanyNotStored = errors.New("failed to store company")
+	ErrMergeSameCompany = errors.New("both keys resolve to the same company")
+)
+
+type companyService struct {
+	indexOnce helpers.SucceedOnce
+
+	namesMu        sync.Mutex
+	names          []string
+	namesExpiresAt time.Time
+}
+
+var CompanyService CompanyServiceI = &companyService{}
+
+// collections shares the collection handles between requests
+var collections helpers.CollectionCache
+
+// companyLocks serializes every write to a company, keyed by its page URL (see helpers.CompanyLockKey),
+// so concurrent enrichment of the same company neither upserts it twice nor scores it from a document
+// another scrape is replacing
+var companyLocks helpers.KeyedMutex
+
+func companiesCollection() *mongo.Collection {
+	cfg := config.Get()
+	return collections.Collection(mongo_client.Client, cfg.Database, cfg.Collection)
+}
+
+// ensureListIndexes creates the indexes backing the list filters the first time they are needed,
+// again on the next list when Mongo couldn't be reached
+func (cs *companyService) ensureListIndexes(ctx context.Context) {
+	err := cs.indexOnce.Do(func() error {
+		_, err := companiesCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: primitive.D{{Key: "marketCapCategory", Value: 1}, {Key: "stockRate", Value: -1}}},
+			{Keys: primitive.D{{Key: "sector", Value: 1}, {Key: "stockRate", Value: -1}}},
+			{Keys: primitive.D{{Key: "stockRate", Value: -1}}},
+			{Keys: primitive.D{{Key: "fScore", Value: -1}}},
+			{Keys: primitive.D{{Key: "marketCapValue", Value: -1}}},
+			{Keys: primitive.D{{Key: "funds", Value: 1}, {Key: "stockRate", Value: -1}}},
+		})
+		return err
+	})
+	if err != nil {
+		zap.L().Error("Error creating company list indexes", zap.Error(err))
+	}
+}
+
+// ListCompanies returns the summaries of the stored companies matching the query
+func (cs *companyService) ListCompanies(ctx context.Context, query helpers.CompanyListQuery) ([]types.CompanySummary, error) {
+	cs.ensureListIndexes(ctx)
+
+	findOptions := options.Find().
+		SetProjection(query.Projection()).
+		SetSort(query.Sort()).
+		SetSkip(query.Offset).
+		SetLimit(query.Limit)
+
+	cursor, err := companiesCollection().Find(ctx, query.Filter(), findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error listing companies: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	companies := []types.CompanySummary{}
+	if err := cursor.All(ctx, &companies); err != nil {
+		return nil, fmt.Errorf("error decoding companies: %w", err)
+	}
+	return companies, nil
+}
+
+// DeleteCompany removes the company matching the ISIN or exact name, reporting whether it existed
+func (cs *companyService) DeleteCompany(ctx context.Context, key string) (bool, error) {
+	company, err := findStoredCompany(ctx, key)
+	if errors.Is(err, ErrCompanyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	unlock := companyLocks.Lock(helpers.StoredCompanyLockKey(company))
+	defer unlock()
+
+	result, err := companiesCollection().DeleteOne(ctx, bson.M{"_id": company["_id"]})
+	if err != nil {
+		return false, fmt.Errorf("error deleting company: %w", err)
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// InvalidateCompany clears the scraped fundamentals of a company so it is scraped again the next
+// time it is matched, its identity (name, url, isin, sector) is kept
+func (cs *companyService) InvalidateCompany(ctx context.Context, key string) (bool, error) {
+	company, err := findStoredCompany(ctx, key)
+	if errors.Is(err, ErrCompanyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	unlock := companyLocks.Lock(helpers.StoredCompanyLockKey(company))
+	defer unlock()
+
+	unset := bson.M{}
+	for _, field := range constants.ScrapedFields {
+		unset[field] = ""
+	}
+	result, err := companiesCollection().UpdateOne(ctx, bson.M{"_id": company["_id"]}, bson.M{"$unset": unset})
+	if err != nil {
+		return false, fmt.Errorf("error invalidating company: %w", err)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// searchCompany returns the first search result for the query, ErrCompanyNotFound without one
+func searchCompany(ctx context.Context, query string) (types.Company, error) {
+	results, err := datasource.Current().Search(ctx, query)
+	if err != nil {
+		return types.Company{}, fmt.Errorf("error searching company: %w", err)
+	}
+	if len(results) == 0 {
+		return types.Company{}, ErrCompanyNotFound
+	}
+	return results[0], nil
+}
+
+// ScrapeCompany searches the company, scrapes its page and upserts the fundamentals together with
+// the extra fields (e.g. isin, sector) the page didn't provide, tagged with the fund when it isn't
+// empty. It returns the stored name.
+func (cs *companyService) ScrapeCompany(ctx context.Context, query string, extra bson.M, fund string) (string, error) {
+	match, err := searchCompany(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	unlock := companyLocks.Lock(helpers.CompanyLockKey(match.URL, match.Name))
+	defer unlock()
+	return cs.scrapeLocked(ctx, match, bson.M{"name": match.Name}, extra, fund)
+}
+
+// FindMapped returns the stored company of a name map entry, found by its ISIN or page URL. Its
+// error wraps mongo.ErrNoDocuments when the company isn't stored yet.
+func (cs *companyService) FindMapped(ctx context.Context, entry types.NameMapEntry) (bson.M, error) {
+	var company bson.M
+	if err := companiesCollection().FindOne(ctx, helpers.MappedCompanyFilter(entry)).Decode(&company); err != nil {
+		return nil, fmt.Errorf("error finding mapped company %s: %w", entry.Name, err)
+	}
+	return company, nil
+}
+
+// ScrapeMapped scrapes the page of a name map entry without searching. The stored company FindMapped
+// returned (nil when there is none) is updated in place, whatever its name; otherwise the company is
+// upserted by its URL under the entry's name. Its ISIN and URL are stored so FindMapped finds it next time.
+func (cs *companyService) ScrapeMapped(ctx context.Context, entry types.NameMapEntry, stored bson.M, extra bson.M, fund string) (string, error) {
+	mapped := bson.M{"url": entry.URL}
+	if entry.ISIN != "" {
+		mapped["isin"] = entry.ISIN
+	}
+	for key, value := range extra {
+		if _, ok := mapped[key]; !ok {
+			mapped[key] = value
+		}
+	}
+
+	match := types.Company{Name: entry.Name, URL: entry.URL}
+	filter := bson.M{"url": entry.URL}
+	lockKey := helpers.CompanyLockKey(entry.URL, entry.Name)
+	if stored != nil {
+		if name, _ := stored["name"].(string); name != "" {
+			match.Name = name
+		}
+		filter = bson.M{"_id": stored["_id"]}
+		lockKey = helpers.StoredCompanyLockKey(stored)
+	}
+	unlock := companyLocks.Lock(lockKey)
+	defer unlock()
+	return cs.scrapeLocked(ctx, match, filter, mapped, fund)
+}
+
+// ScrapeStored scrapes a stored company again, updates it in place by its _id, whatever name it is
+// stored under, and rescores it like RefreshCompany. Its stored page URL is scraped; a company stored
+// before the URL was recorded is searched by its name, the result only being used when it is the
+// same company.
+func (cs *companyService) ScrapeStored(ctx context.Context, company bson.M) (string, error) {
+	match, err := storedMatch(ctx, company)
+	if err != nil {
+		return "", err
+	}
+	unlock := companyLocks.Lock(helpers.StoredCompanyLockKey(company))
+	defer unlock()
+	refreshed, err := cs.refreshLocked(ctx, match, bson.M{"_id": company["_id"]})
+	if err != nil {
+		return "", err
+	}
+	name, _ := refreshed["name"].(string)
+	return name, nil
+}
+
+// storedMatch returns the page a stored company is scraped from again
+func storedMatch(ctx context.Context, company bson.M) (types.Company, error) {
+	name, _ := company["name"].(string)
+	if url, _ := company["url"].(string); url != "" {
+		return types.Company{Name: name, URL: url}, nil
+	}
+	match, err := searchCompany(ctx, name)
+	if err != nil {
+		return types.Company{}, err
+	}
+	if helpers.NormalizeCompanyName(match.Name) != helpers.NormalizeCompanyName(name) {
+		return types.Company{}, fmt.Errorf("%w: %s, the search found %s", ErrCompanyNotFound, name, match.Name)
+	}
+	match.Name = name
+	return match, nil
+}
+
+// scrapeBackoff retries a company scrape SCRAPE_RETRY_ATTEMPTS times, only while it fails with a
+// retryable error: a page that is gone or no longer parses fails at once
+func scrapeBackoff() helpers.Backoff {
+	cfg := config.Get()
+	return helpers.Backoff{
+		Attempts:  cfg.ScrapeRetryAttempts,
+		Initial:   cfg.ScrapeRetryBackoff,
+		Max:       cfg.ScrapeRetryMaxBackoff,
+		Retryable: http_client.IsRetryable,
+	}
+}
+
+// scrapeLocked scrapes the company, retried by scrapeBackoff, and upserts it by the filter, its lock
+// must be held. A filter on the _id only updates the stored company. A company upserted by anything
+// but its name is named after the match when it is inserted.
+func (cs *companyService) scrapeLocked(ctx context.Context, match types.Company, filter bson.M, extra bson.M, fund string) (string, error) {
+	var data map[string]interface{}
+	err := helpers.RetryWithBackoff(ctx, scrapeBackoff(), func(ctx context.Context) error {
+		var err error
+		data, err = datasource.FetchCompanyWithPeers(ctx, datasource.Current(), match.URL)
+		return err
+	}, func(attempt int, wait time.Duration, err error) {
+		helpers.Logger(ctx).Warn("Retrying company scrape", zap.String("url", match.URL), zap.Int("attempt", attempt), zap.Duration("wait", wait), zap.Error(err))
+	})
+	if err != nil {
+		return "", fmt.Errorf("error fetching company data: %w", err)
+	}
+
+	// Sections that failed this time keep their previously stored data
+	fields := helpers.ScrapedFieldsUpdate(data)
+	// The sector screener shows wins over the fund sheet's industry column
+	for key, value := range extra {
+		if _, scraped := fields[key]; !scraped {
+			fields[key] = value
+		}
+	}
+	percentiles := helpers.PeerPercentiles(map[string]interface{}{
+		"name":          match.Name,
+		"stockPE":       fields["stockPE"],
+		"marketCap":     fields["marketCap"],
+		"dividendYield": fields["dividendYield"],
+		"roce":          fields["roce"],
+		"peers":         fields["peers"],
+	})
+	if len(percentiles) > 0 {
+		fields["peerPercentiles"] = percentiles
+	}
+	// The page URL is the company's lock key, companies stored before it was recorded get it here
+	if match.URL != "" {
+		fields["url"] = match.URL
+	}
+
+	// peers keeps the latest scrape, peerSnapshots the last PEER_SNAPSHOTS of them
+	update := bson.M{"$set": helpers.StampScraped(fields)}
+	if snapshot := helpers.PeerSnapshot(fields, time.Now().UTC()); snapshot != nil {
+		update["$push"] = helpers.PeerSnapshotsPush(snapshot)
+	}
+	// Companies remember the funds holding them so the list can be filtered by fund
+	if fund != "" {
+		update["$addToSet"] = bson.M{"funds": fund}
+	}
+
+	// A successful scrape ends the backoff of failed refreshes, see helpers.RefreshFailureUpdate
+	update["$unset"] = bson.M{"refreshFailures": "", "nextRefreshAt": ""}
+
+	if _, byName := filter["name"]; !byName {
+		update["$setOnInsert"] = bson.M{"name": match.Name}
+	}
+	// A company updated by its _id isn't brought back when it was deleted meanwhile
+	_, byID := filter["_id"]
+	if _, err := companiesCollection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(!byID)); err != nil {
+		return match.Name, fmt.Errorf("%w %s: %v", ErrCompanyNotStored, match.Name, err)
+	}
+	helpers.Logger(ctx).Info("Successfully updated document", zap.String("company", match.Name))
+	return match.Name, nil
+}
+
+// storedCompanyNames returns the names of the companies with fundamentals, cached for companyNamesTTL
+func (cs *companyService) storedCompanyNames(ctx context.Context) ([]string, error) {
+	cs.namesMu.Lock()
+	defer cs.namesMu.Unlock()
+	if cs.names != nil && time.Now().Before(cs.namesExpiresAt) {
+		return cs.names, nil
+	}
+
+	cursor, err := companiesCollection().Find(ctx, bson.M{"marketCap": bson.M{"$exists": true}}, options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("error listing company names: %w", err)
+	}
+	var companies []struct {
+		Name string `bson:"name"`
+	}
+	if err := cursor.All(ctx, &companies); err != nil {
+		return nil, fmt.Errorf("error decoding company names: %w", err)
+	}
+
+	names := make([]string, 0, len(companies))
+	for _, company := range companies {
+		if company.Name != "" {
+			names = append(names, company.Name)
+		}
+	}
+	cs.names = names
+	cs.namesExpiresAt = time.Now().Add(companyNamesTTL)
+	return names, nil
+}
+
+// FuzzyCandidates returns up to limit stored company names resembling the given one, the most
+// similar first, whether or not they reach FUZZY_MATCH_THRESHOLD
+func (cs *companyService) FuzzyCandidates(ctx context.Context, name string, limit int) ([]types.FuzzyCandidate, error) {
+	names, err := cs.storedCompanyNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return helpers.TopFuzzyMatches(name, names, limit), nil
+}
+
+// FindFuzzyMatch returns the stored company whose name is the most similar to the given one, with
+// its similarity, when it reaches FUZZY_MATCH_THRESHOLD. The company is nil when nothing is close enough.
+func (cs *companyService) FindFuzzyMatch(ctx context.Context, name string) (bson.M, float64, error) {
+	threshold := config.Get().FuzzyMatchThreshold
+	if threshold <= 0 {
+		return nil, 0, nil
+	}
+
+	names, err := cs.storedCompanyNames(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	match, similarity := helpers.BestFuzzyMatch(name, names)
+	if match == "" || similarity < threshold {
+		return nil, similarity, nil
+	}
+
+	var company bson.M
+	if err := companiesCollection().FindOne(ctx, bson.M{"name": match}).Decode(&company); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, similarity, nil
+		}
+		return nil, similarity, fmt.Errorf("error finding company %s: %w", match, err)
+	}
+	return company, similarity, nil
+}
+
+// companyScores computes the scores of a stored company as they are persisted on its document,
+// the sector picks the benchmark when SECTOR_BENCHMARK_WEIGHT is set. fScore is left out when
+// the tables don't allow computing it.
+func companyScores(ctx context.Context, company bson.M, sector string) bson.M {
+	debtToEquity, debtToEquityHistory := helpers.DebtToEquity(company)
+	if helpers.StrictParsing() {
+		if fields := helpers.UnparseableFields(company); len(fields) > 0 {
+			// The scores would be computed with these cells read as 0, clear them rather than keep stale ones
+			helpers.Logger(ctx).Warn("Excluding company with unparseable fields from scoring", zap.Any("name", company["name"]), zap.Strings("fields", fields))
+			return bson.M{
+				"stockRate":           nil,
+				"stockRateRaw":        nil,
+				"insufficientData":    nil,
+				"scoreReasons":        []string{},
+				"peerPercentiles":     nil,
+				"fScore":              nil,
+				"marketCapCategory":   helpers.GetMarketCapCategory(fmt.Sprintf("%v", company["marketCap"])),
+				"marketCapValue":      helpers.MarketCapValue(company),
+				"highDebt":            helpers.HighDebt(company),
+				"debtToEquity":        debtToEquity,
+				"debtToEquityHistory": debtToEquityHistory,
+				"valuation":           helpers.StockValuation(company),
+				"zScore":              helpers.GenerateZScore(company),
+				"shareholdingTrend":   helpers.AnalyzeShareholding(company["shareholdingPattern"]),
+				"unparseableFields":   fields,
+			}
+		}
+	}
+
+	var benchmark *helpers.SectorBenchmark
+	if helpers.SectorBenchmarkWeight() > 0 && sector != "" {
+		var err error
+		benchmark, err = SectorService.GetBenchmark(ctx, sector)
+		if err != nil {
+			helpers.Logger(ctx).Error("Error computing sector benchmark", zap.String("sector", sector), zap.Error(err))
+		}
+	}
+
+	rating := helpers.RateCompany(company, benchmark)
+	scored := bson.M{
+		"stockRate":         rating.Rate,
+		"stockRateRaw":      rating.Raw,
+		"insufficientData":  rating.InsufficientData,
+		"scoreReasons":      rating.Reasons,
+		"peerPercentiles":   helpers.PeerPercentiles(company),
+		"marketCapCategory": helpers.GetMarketCapCategory(fmt.Sprintf("%v", company["marketCap"])),
+		"marketCapValue":    helpers.MarketCapValue(company),
+		// Unknown (nil) when the balance sheet doesn't tell
+		"highDebt":            helpers.HighDebt(company),
+		"debtToEquity":        debtToEquity,
+		"debtToEquityHistory": debtToEquityHistory,
+		"valuation":           helpers.StockValuation(company),
+		"zScore":              helpers.GenerateZScore(company),
+		"shareholdingTrend":   helpers.AnalyzeShareholding(company["shareholdingPattern"]),
+	}
+	if fScore := helpers.GenerateFScore(company); fScore >= 0 {
+		scored["fScore"] = fScore
+	}
+	if helpers.StrictParsing() {
+		scored["unparseableFields"] = []string{}
+	}
+	return scored
+}
+
+// RefreshCompany scrapes the company again, recomputes its scores and returns the updated
+// document. ErrCompanyNotFound is returned when the name doesn't resolve to a company page.
+func (cs *companyService) RefreshCompany(ctx context.Context, name string) (bson.M, error) {
+	match, err := searchCompany(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	// Held until the scores are written, they must be computed from the document this scrape stored
+	unlock := companyLocks.Lock(helpers.CompanyLockKey(match.URL, match.Name))
+	defer unlock()
+	return cs.refreshLocked(ctx, match, bson.M{"name": match.Name})
+}
+
+// refreshLocked scrapes the company's page, upserts it by the filter and rescores it, its lock must be held
+func (cs *companyService) refreshLocked(ctx context.Context, match types.Company, filter bson.M) (bson.M, error) {
+	storedName, err := cs.scrapeLocked(ctx, match, filter, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var company bson.M
+	// The peer history is only returned by PeerHistory
+	projection := options.FindOne().SetProjection(bson.M{"peerSnapshots": 0})
+	if err := companiesCollection().FindOne(ctx, filter, projection).Decode(&company); err != nil {
+		return nil, fmt.Errorf("error finding company %s: %w", storedName, err)
+	}
+
+	sector, _ := company["sector"].(string)
+	scored := helpers.StampScored(companyScores(ctx, company, sector))
+	if _, err := companiesCollection().UpdateOne(ctx, bson.M{"_id": company["_id"]}, bson.M{"$set": scored}); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrCompanyNotStored, storedName, err)
+	}
+	for key, value := range scored {
+		company[key] = value
+	}
+	return company, nil
+}
+
+// GetCompany returns a stored company, resolved by ISIN or exact stored name, with "stale" telling
+// whether its data is older than MAX_DATA_AGE_DAYS (or was never scraped). Stale data is revalidated
+// in the mode, see helpers.Revalidate: the stored company is scraped from its page and updated by its
+// _id. A sync revalidation returns the rescraped company, the stale one when the scrape fails. ErrCompanyNotFound is returned when the company isn't stored.
+func (cs *companyService) GetCompany(ctx context.Context, key string, mode helpers.RevalidateMode) (bson.M, error) {
+	company, err := findStoredCompany(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	maxDataAge := config.Get().MaxDataAge
+	if helpers.UsableStoredData(company, maxDataAge, time.Now()) {
+		company["stale"] = false
+		return company, nil
+	}
+
+	name, _ := company["name"].(string)
+	// Only read once a sync revalidation returned, an async one sets it in the background
+	var fresh bson.M
+	stale, err := helpers.Revalidate(ctx, &companyLocks, helpers.StoredCompanyLockKey(company), mode, func(ctx context.Context) error {
+		// A revalidation that held the lock first may have refreshed it already
+		var current bson.M
+		projection := options.FindOne().SetProjection(bson.M{"peerSnapshots": 0})
+		if err := companiesCollection().FindOne(ctx, bson.M{"_id": company["_id"]}, projection).Decode(&current); err != nil {
+			return fmt.Errorf("error finding company %s: %w", name, err)
+		}
+		if helpers.UsableStoredData(current, maxDataAge, time.Now()) {
+			fresh = current
+			return nil
+		}
+		// The stored company is scraped from its own page and updated in place, like a refresh
+		match, err := storedMatch(ctx, current)
+		if err != nil {
+			return err
+		}
+		fresh, err = cs.refreshLocked(ctx, match, bson.M{"_id": company["_id"]})
+		return err
+	})
+	if err != nil {
+		helpers.Logger(ctx).Warn("Returning stale company, revalidation failed", zap.String("company", name), zap.Error(err))
+	}
+	if !stale {
+		company = fresh
+	}
+	company["stale"] = stale
+	return company, nil
+}
+
+// GetCompanyDocument returns a stored company as a typed document, resolved by ISIN or exact stored
+// name, without its peer history. It never revalidates. Fields whose stored shape doesn't fit the
+// document are left out rather than failing the read, see helpers.LenientCompanyDocument.
+// ErrCompanyNotFound is returned when the company isn't stored.
+func (cs *companyService) GetCompanyDocument(ctx context.Context, key string) (types.CompanyDocument, error) {
+	company, err := findStoredCompany(ctx, key)
+	if err != nil {
+		return types.CompanyDocument{}, err
+	}
+	document, dropped := helpers.LenientCompanyDocument(company)
+	if len(dropped) > 0 {
+		helpers.Logger(ctx).Warn("Company fields don't fit the typed document", zap.Any("company", company["name"]), zap.Strings("fields", dropped))
+	}
+	return document, nil
+}
+
+// findStoredCompany resolves a company like findCompany, without its peer history
+func findStoredCompany(ctx context.Context, key string) (bson.M, error) {
+	var company bson.M
+	// The peer history is only returned by PeerHistory
+	projection := options.FindOne().SetProjection(bson.M{"peerSnapshots": 0})
+	err := companiesCollection().FindOne(ctx, helpers.CompanyLookupFilter(key), projection).Decode(&company)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: %s", ErrCompanyNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding company %s: %w", key, err)
+	}
+	return company, nil
+}
+
+// PeerHistory returns the stored peer snapshots of a company with the change in peer score between them
+func (cs *companyService) PeerHistory(ctx context.Context, key string) ([]helpers.PeerHistoryEntry, error) {
+	var company bson.M
+	projection := options.FindOne().SetProjection(bson.M{"peerSnapshots": 1})
+	err := companiesCollection().FindOne(ctx, helpers.CompanyLookupFilter(key), projection).Decode(&company)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrCompanyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding company: %w", err)
+	}
+	return helpers.PeerHistory(company["peerSnapshots"]), nil
+}
+
+// FinancialHistory returns the series of the metrics of a stored statement, see helpers.FinancialHistory
+func (cs *companyService) FinancialHistory(ctx context.Context, key string, statement string, metrics []string) (map[string][]helpers.HistoryPoint, error) {
+	headersField, ok := helpers.HistoryStatements[statement]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", helpers.ErrUnknownStatement, statement)
+	}
+
+	var company bson.M
+	projection := options.FindOne().SetProjection(bson.M{statement: 1, headersField: 1})
+	err := companiesCollection().FindOne(ctx, helpers.CompanyLookupFilter(key), projection).Decode(&company)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrCompanyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding company: %w", err)
+	}
+	return helpers.FinancialHistory(company, statement, metrics)
+}
+
+// findCompany resolves a company by ISIN or exact stored name, ErrCompanyNotFound when it isn't stored
+func findCompany(ctx context.Context, key string) (bson.M, error) {
+	var company bson.M
+	err := companiesCollection().FindOne(ctx, helpers.CompanyLookupFilter(key)).Decode(&company)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: %s", ErrCompanyNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding company %s: %w", key, err)
+	}
+	return company, nil
+}
+
+// MergeCompanies merges two duplicate companies into the more complete one (helpers.MergeWinner),
+// rescores it and deletes the other, returning the merged document and the name of the deleted one.
+// The name map entries and stored uploads referring to the deleted company are moved to the kept one.
+func (cs *companyService) MergeCompanies(ctx context.Context, firstKey, secondKey string) (bson.M, string, error) {
+	first, err := findCompany(ctx, firstKey)
+	if err != nil {
+		return nil, "", err
+	}
+	second, err := findCompany(ctx, secondKey)
+	if err != nil {
+		return nil, "", err
+	}
+	if first["_id"] == second["_id"] {
+		return nil, "", ErrMergeSameCompany
+	}
+	// Both companies are read again under their locks, so a scrape finishing meanwhile is merged
+	unlock := lockCompanies(first, second)
+	defer unlock()
+	if first, err = findCompany(ctx, firstKey); err != nil {
+		return nil, "", err
+	}
+	if second, err = findCompany(ctx, secondKey); err != nil {
+		return nil, "", err
+	}
+
+	winner, loser := helpers.MergeWinner(first, second)
+	winnerName, _ := winner["name"].(string)
+	loserName, _ := loser["name"].(string)
+
+	fields := helpers.MergeCompanyFields(winner, loser)
+	merged := bson.M{"_id": winner["_id"], "name": winner["name"]}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	sector, _ := merged["sector"].(string)
+	for key, value := range helpers.StampScored(companyScores(ctx, merged, sector)) {
+		fields[key] = value
+		merged[key] = value
+	}
+
+	if _, err := companiesCollection().UpdateOne(ctx, bson.M{"_id": winner["_id"]}, bson.M{"$set": fields}); err != nil {
+		return nil, "", fmt.Errorf("%w %s: %v", ErrCompanyNotStored, winnerName, err)
+	}
+	// The references to the loser move to the winner before it is deleted, a failure leaves both
+	// companies stored so the merge can be run again
+	loserURL, _ := loser["url"].(string)
+	winnerURL, _ := merged["url"].(string)
+	if loserURL != "" && winnerURL != "" && loserURL != winnerURL {
+		if _, err := NameMapService.ReplaceURL(ctx, loserURL, winnerURL); err != nil {
+			return nil, "", err
+		}
+	}
+	if loserName != winnerName {
+		if _, err := UploadService.RenameMatches(ctx, loserName, winnerName); err != nil {
+			return nil, "", err
+		}
+	}
+	if _, err := companiesCollection().DeleteOne(ctx, bson.M{"_id": loser["_id"]}); err != nil {
+		return nil, "", fmt.Errorf("error deleting merged company %s: %w", loserName, err)
+	}
+
+	// Fuzzy matching must stop resolving holdings to the deleted name
+	cs.namesMu.Lock()
+	cs.names = nil
+	cs.namesMu.Unlock()
+
+	helpers.Logger(ctx).Info("Merged duplicate companies", zap.String("company", winnerName), zap.String("removed", loserName))
+	// The peer history is only returned by PeerHistory
+	delete(merged, "peerSnapshots")
+	return merged, loserName, nil
+}
+
+// lockCompanies takes the locks of the stored companies in the order of their keys, so two writers
+// locking the same companies can't wait on each other, and returns the function releasing them
+func lockCompanies(companies ...bson.M) (unlock func()) {
+	keys := make([]string, 0, len(companies))
+	for _, company := range companies {
+		keys = append(keys, helpers.StoredCompanyLockKey(company))
+	}
+	sort.Strings(keys)
+
+	unlocks := make([]func(), 0, len(keys))
+	for i, key := range keys {
+		// Duplicates often share their page URL, hence their key
+		if i > 0 && key == keys[i-1] {
+			continue
+		}
+		unlocks = append(unlocks, companyLocks.Lock(key))
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// ListDuplicates lists the pairs of stored companies sharing an ISIN or with names at least the threshold similar
+func (cs *companyService) ListDuplicates(ctx context.Context, threshold float64) ([]types.DuplicateCompanies, error) {
+	cursor, err := companiesCollection().Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"name": 1, "isin": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("error listing companies: %w", err)
+	}
+	var companies []types.CompanyIdentity
+	if err := cursor.All(ctx, &companies); err != nil {
+		return nil, fmt.Errorf("error decoding companies: %w", err)
+	}
+	return helpers.FindDuplicateCompanies(companies, threshold), nil
+}
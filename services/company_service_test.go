@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stockbackend/clients/datasource"
+	"stockbackend/clients/http_client"
+	mongo_client "stockbackend/clients/mongo"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// useMockMongo points the services at the mock deployment of mt, the test queues the replies of
+// the commands it expects with mt.AddMockResponses
+func useMockMongo(mt *mtest.T) {
+	previous := mongo_client.Client
+	mongo_client.Client = mt.Client
+	collections = helpers.CollectionCache{}
+	useConfig(mt, nil)
+	mt.Cleanup(func() {
+		mongo_client.Client = previous
+		collections = helpers.CollectionCache{}
+	})
+}
+
+// useConfig sets the configuration of the test, the mock deployment's database and collection with settings
+func useConfig(mt *mtest.T, settings map[string]string) {
+	previous := config.Get()
+	values := map[string]string{"DATABASE": "stocks", "COLLECTION": "companies"}
+	for key, value := range settings {
+		values[key] = value
+	}
+	config.Set(config.FromMap(values))
+	mt.Cleanup(func() { config.Set(previous) })
+}
+
+// fakeSource finds every name at the same page and scrapes it slowly, counting the fetches in
+// progress. Without a page it finds nothing, fetching the failing page fails. The first fetches fail
+// with errs in turn.
+type fakeSource struct {
+	url     string
+	delay   time.Duration
+	failing string
+	errs    []error
+
+	mu          sync.Mutex
+	fetching    int
+	maxFetching int
+	fetched     int
+}
+
+// useSource makes the services scrape with source
+func useSource(mt *mtest.T, source datasource.DataSource) {
+	previous := datasource.Current()
+	datasource.Set(source)
+	mt.Cleanup(func() { datasource.Set(previous) })
+}
+
+func (s *fakeSource) Search(ctx context.Context, name string) ([]types.Company, error) {
+	if s.url == "" {
+		return nil, nil
+	}
+	return []types.Company{{Name: name, URL: s.url}}, nil
+}
+
+func (s *fakeSource) FetchCompany(ctx context.Context, url string) (map[string]interface{}, error) {
+	if url == s.failing {
+		return nil, errors.New("page not found")
+	}
+	s.mu.Lock()
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		s.fetched++
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.fetching++
+	if s.fetching > s.maxFetching {
+		s.maxFetching = s.fetching
+	}
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetching--
+	s.fetched++
+	return map[string]interface{}{"Market Cap": "1,889", "Stock P/E": "11.5", "ROCE": "6.70"}, nil
+}
+
+func (s *fakeSource) FetchPeers(ctx context.Context, id string) ([]map[string]string, error) {
+	return nil, nil
+}
+
+func TestScrapeCompany_SerializesSameCompany(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("search and name map", func(mt *mtest.T) {
+		useMockMongo(mt)
+		source := &fakeSource{url: "https://www.screener.in/company/63MOONS/consolidated/", delay: 10 * time.Millisecond}
+		useSource(mt, source)
+
+		// A search result and a name map entry of the same page, scraped by concurrent uploads
+		const scrapes = 6
+		for i := 0; i < scrapes; i++ {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+		}
+		errs := make(chan error, scrapes)
+		var wg sync.WaitGroup
+		for i := 0; i < scrapes; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var err error
+				if i%2 == 0 {
+					_, err = CompanyService.ScrapeCompany(context.Background(), "63 Moons Tech", nil, "")
+				} else {
+					entry := types.NameMapEntry{Name: "63 MOONS TECHNOLOGIES LIMITED", URL: "https://www.screener.in/company/63moons/consolidated"}
+					_, err = CompanyService.ScrapeMapped(context.Background(), entry, nil, nil, "")
+				}
+				errs <- err
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}
+		if source.fetched != scrapes || source.maxFetching != 1 {
+			t.Errorf("Expected the %d scrapes to run one at a time, got %d with up to %d at once", scrapes, source.fetched, source.maxFetching)
+		}
+		// Every write stores the whole scrape with the page URL it is locked on
+		for i := 0; i < scrapes; i++ {
+			event := mt.GetStartedEvent()
+			if event == nil || event.CommandName != "update" {
+				t.Fatalf("Expected %d updates, got %v", scrapes, event)
+			}
+			set := event.Command.Lookup("updates", "0", "u", "$set")
+			if url, _ := set.Document().Lookup("url").StringValueOK(); url == "" || set.Document().Lookup("marketCap").StringValue() != "1,889" {
+				t.Errorf("Expected the scraped fields and the page URL, got %v", set)
+			}
+		}
+	})
+}
+
+func TestScrapeCompany_Retries(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	rateLimited := fmt.Errorf("%w: status code 429", http_client.ErrRateLimited)
+	transient := fmt.Errorf("%w: status code 503", http_client.ErrTransient)
+	notFound := fmt.Errorf("%w: status code 404", http_client.ErrNotFound)
+	layoutChanged := fmt.Errorf("%w: no ratios on the company page", http_client.ErrLayoutChanged)
+
+	tests := []struct {
+		name    string
+		errs    []error
+		fetched int
+		err     error
+	}{
+		{"rate limited then scraped", []error{rateLimited, transient}, 3, nil},
+		{"retries exhausted", []error{transient, transient, transient}, 3, http_client.ErrTransient},
+		{"not found", []error{notFound}, 1, http_client.ErrNotFound},
+		{"layout changed", []error{layoutChanged}, 1, http_client.ErrLayoutChanged},
+	}
+
+	for _, test := range tests {
+		mt.Run(test.name, func(mt *mtest.T) {
+			useMockMongo(mt)
+			useConfig(mt, map[string]string{"SCRAPE_RETRY_ATTEMPTS": "3", "SCRAPE_RETRY_BACKOFF_SECONDS": "0.001"})
+			source := &fakeSource{url: "https://www.screener.in/company/63MOONS/", errs: test.errs}
+			useSource(mt, source)
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+			_, err := CompanyService.ScrapeCompany(context.Background(), "63 Moons Tech", nil, "")
+			if test.err == nil && err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+			}
+			if source.fetched != test.fetched {
+				t.Errorf("%s: expected %d fetches, got %d", test.name, test.fetched, source.fetched)
+			}
+		})
+	}
+}
+
+func TestDeleteCompany_NotStored(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("not stored", func(mt *mtest.T) {
+		useMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch))
+
+		existed, err := CompanyService.DeleteCompany(context.Background(), "INE111B01023")
+		if err != nil || existed {
+			t.Errorf("Expected nothing to be deleted, got %v (%v)", existed, err)
+		}
+		if event := mt.GetStartedEvent(); event.CommandName != "find" || mt.GetStartedEvent() != nil {
+			t.Errorf("Expected only the lookup, got %v", event.CommandName)
+		}
+	})
+}
+
+func TestGetCompany_RevalidatesStoredCompany(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("sync", func(mt *mtest.T) {
+		useMockMongo(mt)
+		// Searching the stored name would find another company
+		useSource(mt, &fakeSource{url: "https://www.screener.in/company/OTHER/"})
+		id := primitive.NewObjectID()
+		stale := bson.D{
+			{Key: "_id", Value: id},
+			{Key: "name", Value: "63 Moons Tech."},
+			{Key: "url", Value: "https://www.screener.in/company/63MOONS/"},
+			{Key: "marketCap", Value: "1,500"},
+			{Key: "lastScraped", Value: primitive.NewDateTimeFromTime(time.Now().AddDate(-1, 0, 0))},
+		}
+		fresh := bson.D{
+			{Key: "_id", Value: id},
+			{Key: "name", Value: "63 Moons Tech."},
+			{Key: "url", Value: "https://www.screener.in/company/63MOONS/"},
+			{Key: "marketCap", Value: "1,889"},
+			{Key: "lastScraped", Value: primitive.NewDateTimeFromTime(time.Now())},
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, stale),
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, stale),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, fresh),
+			mtest.CreateSuccessResponse(),
+		)
+
+		company, err := CompanyService.GetCompany(context.Background(), "63 Moons Tech.", helpers.RevalidateSync)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if company["stale"] != false || company["marketCap"] != "1,889" {
+			t.Errorf("Expected the rescraped company, got %v", company)
+		}
+
+		mt.GetStartedEvent()
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent()
+		if update == nil || update.CommandName != "update" {
+			t.Fatalf("Expected the company to be scraped again, got %v", update)
+		}
+		if got := update.Command.Lookup("updates", "0", "q", "_id").ObjectID(); got != id {
+			t.Errorf("Expected the stored company to be updated by its _id, got %v", update.Command)
+		}
+		if url := update.Command.Lookup("updates", "0", "u", "$set", "url").StringValue(); url != "https://www.screener.in/company/63MOONS/" {
+			t.Errorf("Expected the stored page to be scraped, got %s", url)
+		}
+	})
+}
+
+func TestMergeCompanies_MovesReferences(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("merge", func(mt *mtest.T) {
+		useMockMongo(mt)
+		scraped := primitive.NewDateTimeFromTime(time.Now())
+		winner := bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "name", Value: "Infosys Ltd"},
+			{Key: "url", Value: "https://www.screener.in/company/INFY/consolidated/"},
+			{Key: "marketCap", Value: "6,00,000"},
+			{Key: "stockPE", Value: "25"},
+			{Key: "lastScraped", Value: scraped},
+		}
+		loserID := primitive.NewObjectID()
+		loser := bson.D{
+			{Key: "_id", Value: loserID},
+			{Key: "name", Value: "Infosys Limited"},
+			{Key: "url", Value: "https://www.screener.in/company/INFY/"},
+			{Key: "lastScraped", Value: scraped},
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, winner),
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, loser),
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, winner),
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, loser),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		_, removed, err := CompanyService.MergeCompanies(context.Background(), "Infosys Ltd", "Infosys Limited")
+		if err != nil || removed != "Infosys Limited" {
+			t.Fatalf("Expected Infosys Limited to be merged away, got %q (%v)", removed, err)
+		}
+
+		for i := 0; i < 5; i++ {
+			mt.GetStartedEvent()
+		}
+		nameMap := mt.GetStartedEvent()
+		if nameMap == nil || nameMap.CommandName != "update" || nameMap.Command.Lookup("update").StringValue() != "namemap" {
+			t.Fatalf("Expected the name map to be updated, got %v", nameMap)
+		}
+		if url := nameMap.Command.Lookup("updates", "0", "u", "$set", "url").StringValue(); url != "https://www.screener.in/company/INFY/consolidated/" {
+			t.Errorf("Expected the entries to point at the kept company, got %s", url)
+		}
+		uploads := mt.GetStartedEvent()
+		if uploads == nil || uploads.CommandName != "update" || uploads.Command.Lookup("update").StringValue() != "uploads" {
+			t.Fatalf("Expected the uploads to be updated, got %v", uploads)
+		}
+		if name := uploads.Command.Lookup("updates", "0", "u", "$set", "unmatched.$[match].textMatch").StringValue(); name != "Infosys Ltd" {
+			t.Errorf("Expected the text matches to name the kept company, got %s", name)
+		}
+		deletion := mt.GetStartedEvent()
+		if deletion == nil || deletion.CommandName != "delete" {
+			t.Fatalf("Expected the merged company to be deleted last, got %v", deletion)
+		}
+		if id := deletion.Command.Lookup("deletes", "0", "q", "_id").ObjectID(); id != loserID {
+			t.Errorf("Expected Infosys Limited to be deleted, got %v", deletion.Command)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrDebugHTMLNotFound = errors.New("no stored HTML for company")
+
+type DebugServiceI interface {
+	GetStoredHTML(ctx context.Context, name string) ([]byte, error)
+}
+
+type debugService struct{}
+
+var DebugService DebugServiceI = &debugService{}
+
+// GetStoredHTML returns the raw page recorded on the company document during the last scrape
+func (ds *debugService) GetStoredHTML(ctx context.Context, name string) ([]byte, error) {
+	collection := companiesCollection()
+
+	var result bson.M
+	err := collection.FindOne(ctx, bson.M{"name": name}).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrDebugHTMLNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding document: %w", err)
+	}
+
+	path, ok := result["debugHtml"].(string)
+	if !ok || path == "" {
+		return nil, ErrDebugHTMLNotFound
+	}
+
+	html, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrDebugHTMLNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading stored HTML: %w", err)
+	}
+	return html, nil
+}
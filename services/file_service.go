@@ -2,293 +2,758 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"stockbackend/clients/http_client"
-	mongo_client "stockbackend/clients/mongo"
+	"path/filepath"
+	cloudinary_client "stockbackend/clients/cloudinary"
+	"stockbackend/clients/tracing"
+	"stockbackend/config"
+	"stockbackend/types"
 	"stockbackend/utils/constants"
 	"stockbackend/utils/helpers"
 	"strings"
+	"time"
 
-	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gopkg.in/mgo.v2/bson"
 )
 
 type FileServiceI interface {
-	ParseXLSXFile(ctx *gin.Context, files <-chan string) error
+	ParseXLSXFile(ctx *gin.Context, files <-chan string, rejected []helpers.ZipEntry, password string, fund types.FundTag) error
+	ParseHoldings(ctx *gin.Context, holdings []types.HoldingSnapshot, fund types.FundTag) []map[string]interface{}
 }
 
 type fileService struct{}
 
+const streamWriterKey = "streamWriter"
+
 var FileService FileServiceI = &fileService{}
 
-func (fs *fileService) ParseXLSXFile(ctx *gin.Context, files <-chan string) error {
-	cld, err := cloudinary.NewFromURL(os.Getenv("CLOUDINARY_URL"))
-	if err != nil {
-		return fmt.Errorf("error initializing Cloudinary: %w", err)
+// ParseXLSXFile streams the holdings of every file, password is used to open protected workbooks.
+// The rejected entries of uploaded zips are reported as failed files before them. The fund tag is
+// stored on the upload record and, when it names a fund, on every enriched holding.
+func (fs *fileService) ParseXLSXFile(ctx *gin.Context, files <-chan string, rejected []helpers.ZipEntry, password string, fund types.FundTag) error {
+	// Every upload is recorded with its holdings so later uploads of the same fund can be compared
+	// The upload takes the correlation ID of the request, so its logs, record and X-Upload-Id match
+	uploadID := helpers.RequestID(ctx)
+	if uploadID == "" {
+		uploadID = uuid.New().String()
+	}
+	logger := helpers.Logger(ctx)
+	upload := types.UploadRecord{
+		ID:        uploadID,
+		CreatedAt: time.Now(),
+		Files:     []string{},
+		Holdings:  []types.HoldingSnapshot{},
+		Unmatched: []types.UnmatchedInstrument{},
+		FundTag:   fund,
+	}
+	// Every file is reported as processed or failed in the summary streamed after the last one
+	outcomes := helpers.NewUploadOutcomes()
+	ctx.Writer.Header().Set("X-Upload-Id", upload.ID)
+	// A zip entry that wasn't extracted fails like a file that couldn't be opened
+	for _, entry := range rejected {
+		upload.Files = append(upload.Files, entry.Name)
+		streamError(ctx, entry.Name, "", entry.Err)
+		outcomes.Failed(entry.Name, entry.Err)
+	}
+
+	enricher := newHoldingEnricher(fund)
+	// Past the deadline the holdings left are recorded instead of enriched, and the stream ends
+	deadline := helpers.NewUploadDeadline(time.Now(), config.Get().UploadDeadline)
+	backlog := helpers.NewUploadBacklog()
+	deadlineExceeded := func() bool {
+		return deadline.Exceeded(time.Now())
 	}
+
+	// The stores keep going if the client disconnects, only the trace is inherited from the request
+	uploadCtx, uploadSpan := tracing.Start(context.WithoutCancel(ctx.Request.Context()), "ParseXLSXFile", attribute.String("upload.id", upload.ID))
+	defer uploadSpan.End()
+
 	for filePath := range files {
+		fileName := filepath.Base(filePath)
+		upload.Files = append(upload.Files, fileName)
 		file, err := os.Open(filePath)
 		if err != nil {
-			zap.L().Error("Error opening file", zap.String("filePath", filePath), zap.Error(err))
+			logger.Error("Error opening file", zap.String("filePath", filePath), zap.Error(err))
+			err = fmt.Errorf("could not open file: %w", err)
+			streamError(ctx, fileName, "", err)
+			outcomes.Failed(fileName, err)
 			if err := os.Remove(filePath); err != nil {
-				zap.L().Error("Error removing file", zap.String("filePath", filePath), zap.Error(err))
+				logger.Error("Error removing file", zap.String("filePath", filePath), zap.Error(err))
 			} else {
-				zap.L().Info("File removed successfully", zap.String("filePath", filePath))
+				logger.Info("File removed successfully", zap.String("filePath", filePath))
 			}
 			continue
 		}
 		defer file.Close()
 
-		// Generate a UUID for the filename
-		uuid := uuid.New().String()
-		cloudinaryFilename := uuid + ".xlsx"
-
-		// Upload file to Cloudinary
-		uploadResult, err := cld.Upload.Upload(ctx, file, uploader.UploadParams{
-			PublicID: cloudinaryFilename,
-			Folder:   "xlsx_uploads",
-		})
-		if err != nil {
-			zap.L().Error("Error uploading file to Cloudinary", zap.String("filePath", filePath), zap.Error(err))
+		if deadlineExceeded() {
+			backlogFile(ctx, file, fileName, password, backlog)
+			outcomes.Unprocessed(fileName)
+			if err := os.Remove(filePath); err != nil {
+				logger.Error("Error removing file", zap.String("filePath", filePath), zap.Error(err))
+			} else {
+				logger.Info("File removed successfully", zap.String("filePath", filePath))
+			}
 			continue
 		}
 
-		zap.L().Info("File uploaded to Cloudinary", zap.String("filePath", filePath), zap.String("url", uploadResult.SecureURL))
+		// Archive the file to Cloudinary when it is configured
+		if cld := cloudinary_client.Client; cld != nil {
+			// Generate a UUID for the filename
+			uuid := uuid.New().String()
+			cloudinaryFilename := uuid + ".xlsx"
 
-		// Create a new reader from the uploaded file
-		file.Seek(0, 0)
-		f, err := excelize.OpenReader(file)
+			uploadResult, err := cld.Upload.Upload(ctx, file, uploader.UploadParams{
+				PublicID: cloudinaryFilename,
+				Folder:   "xlsx_uploads",
+			})
+			if err != nil {
+				logger.Error("Error uploading file to Cloudinary", zap.String("filePath", filePath), zap.Error(err))
+				err = fmt.Errorf("could not upload file: %w", err)
+				streamError(ctx, fileName, "", err)
+				outcomes.Failed(fileName, err)
+				continue
+			}
+
+			logger.Info("File uploaded to Cloudinary", zap.String("filePath", filePath), zap.String("url", uploadResult.SecureURL))
+
+			// Create a new reader from the uploaded file
+			file.Seek(0, 0)
+		}
+		sheets, err := helpers.ReadXLSXSheets(file, excelize.Options{Password: password})
 		if err != nil {
-			zap.L().Error("Error parsing XLSX file", zap.String("filePath", filePath), zap.Error(err))
+			logger.Error("Error parsing XLSX file", zap.String("filePath", filePath), zap.Error(err))
+			streamError(ctx, fileName, "", err)
+			outcomes.Failed(fileName, err)
 			if err := os.Remove(filePath); err != nil {
-				zap.L().Error("Error removing file", zap.String("filePath", filePath), zap.Error(err))
+				logger.Error("Error removing file", zap.String("filePath", filePath), zap.Error(err))
 			} else {
-				zap.L().Info("File removed successfully", zap.String("filePath", filePath))
+				logger.Info("File removed successfully", zap.String("filePath", filePath))
 			}
 			continue
 		}
-		defer f.Close()
 
-		// Get all the sheet names
-		sheetList := f.GetSheetList()
-		// Loop through the sheets and extract relevant information
-		for _, sheet := range sheetList {
-			zap.L().Info("Processing file", zap.String("filePath", filePath), zap.String("sheet", sheet))
+		outcomes.Processed(fileName)
+		summary := helpers.NewPortfolioSummary()
+		// streamHolding sends a holding with its formatted amounts and adds it to the summary and the upload record
+		streamHolding := func(stockDetail map[string]interface{}) error {
+			helpers.AddFormattedFields(stockDetail)
+			if err := StreamWriter(ctx).WriteHolding(stockDetail); err != nil {
+				return err
+			}
+			summary.Add(stockDetail)
+			upload.Holdings = append(upload.Holdings, helpers.NewHoldingSnapshot(stockDetail))
+			return nil
+		}
 
-			// Get all the rows in the sheet
-			rows, err := f.GetRows(sheet)
-			if err != nil {
-				zap.L().Error("Error reading rows from sheet", zap.String("sheet", sheet), zap.Error(err))
+		// Loop through the sheets and extract relevant information, a corrupt sheet
+		// is reported to the client while the readable ones are still processed
+		for _, sheetRows := range sheets {
+			sheet := sheetRows.Sheet
+			logger.Info("Processing file", zap.String("filePath", filePath), zap.String("sheet", sheet))
+
+			if sheetRows.Err != nil {
+				logger.Error("Error reading rows from sheet", zap.String("sheet", sheet), zap.Error(sheetRows.Err))
+				streamError(ctx, fileName, sheet, sheetRows.Err)
 				continue
 			}
+			rows := sheetRows.Rows
 
-			headerFound := false
-			headerMap := make(map[string]int)
-			stopExtracting := false
-
-			// Loop through the rows in the sheet
-			for _, row := range rows {
-				if len(row) == 0 {
-					continue
+			// A % of AUM total far from 100 usually means rows were missed or counted twice
+			if total, ok := helpers.SheetAUMTotal(rows); ok {
+				if aumTotal := summary.AddAUMTotal(sheet, total); !aumTotal.Plausible {
+					logger.Warn("Implausible % of AUM total", zap.String("filePath", filePath), zap.String("sheet", sheet), zap.Float64("total", total))
 				}
+			}
 
-				if !headerFound {
-					for _, cell := range row {
-						if helpers.MatchHeader(cell, []string{`name\s*of\s*(the)?\s*instrument`}) {
-							headerFound = true
-							// Build the header map
-							for i, headerCell := range row {
-								normalizedHeader := helpers.NormalizeString(headerCell)
-								// Map possible variations to standard keys
-								switch {
-								case helpers.MatchHeader(normalizedHeader, []string{`name\s*of\s*(the)?\s*instrument`}):
-									headerMap["Name of the Instrument"] = i
-								case helpers.MatchHeader(normalizedHeader, []string{`isin`}):
-									headerMap["ISIN"] = i
-								case helpers.MatchHeader(normalizedHeader, []string{`rating\s*/\s*industry`, `industry\s*/\s*rating`}):
-									headerMap["Industry/Rating"] = i
-								case helpers.MatchHeader(normalizedHeader, []string{`quantity`}):
-									headerMap["Quantity"] = i
-								case helpers.MatchHeader(normalizedHeader, []string{`market\s*/\s*fair\s*value.*`, `market\s*value.*`}):
-									headerMap["Market/Fair Value"] = i
-								case helpers.MatchHeader(normalizedHeader, []string{`%.*nav`, `%.*net\s*assets`}):
-									headerMap["Percentage of AUM"] = i
-								}
-							}
-							// zap.L().Info("Header found", zap.Any("headerMap", headerMap))
-							break
-						}
-					}
+			// Share classes and partly paid shares of a company are merged with AGGREGATE_BY_ISIN
+			holdings := helpers.ExtractHoldings(rows)
+			if config.Get().AggregateByISIN {
+				holdings = helpers.AggregateHoldingsByISIN(holdings)
+			}
+
+			// Loop through the holdings between the header row and the total row, each holding
+			// gets its own span which is ended when the next one starts or the loop exits
+			var rowSpan trace.Span
+			for _, stockDetail := range holdings {
+				if rowSpan != nil {
+					rowSpan.End()
+				}
+				// Past the deadline the equity holdings are left to the background, derivatives and
+				// foreign holdings need no lookup and are still streamed
+				if deadlineExceeded() && !helpers.IsDerivativeHolding(stockDetail) && !helpers.IsForeignHolding(stockDetail) {
+					backlog.Add(fileName, sheet, stockDetail)
+					rowSpan = nil
 					continue
 				}
-
-				// Check for the end marker "Subtotal" or "Total"
-				joinedRow := strings.Join(row, "")
-				if strings.Contains(strings.ToLower(joinedRow), "subtotal") || strings.Contains(strings.ToLower(joinedRow), "total") {
-					stopExtracting = true
-					break
+				instrumentName, _ := stockDetail["Name of the Instrument"].(string)
+				var rowCtx context.Context
+				rowCtx, rowSpan = tracing.Start(uploadCtx, "ParseXLSXFile.row",
+					attribute.String("file", fileName),
+					attribute.String("sheet", sheet),
+					attribute.String("instrument", instrumentName),
+				)
+
+				enriched := enricher.enrich(rowCtx, stockDetail, fileName, sheet)
+				if enriched.storeErr != nil {
+					streamStoreError(ctx, fileName, enriched.failed, enriched.storeErr)
+				}
+				if enriched.unmatched != nil {
+					upload.Unmatched = append(upload.Unmatched, *enriched.unmatched)
 				}
 
-				if !stopExtracting {
-					stockDetail := make(map[string]interface{})
-
-					// Extract data using the header map
-					for key, idx := range headerMap {
-						if idx < len(row) {
-							stockDetail[key] = row[idx]
-						} else {
-							stockDetail[key] = ""
-						}
-					}
-
-					// Check if the stockDetail has meaningful data
-					if stockDetail["Name of the Instrument"] == nil || stockDetail["Name of the Instrument"] == "" {
-						continue
-					}
-
-					// Additional processing
-					instrumentName, ok := stockDetail["Name of the Instrument"].(string)
-					if !ok {
-						continue
-					}
-
-					// Apply mapping if exists
-					if mappedName, exists := constants.MapValues[instrumentName]; exists {
-						stockDetail["Name of the Instrument"] = mappedName
-						instrumentName = mappedName
-					}
-
-					// Clean up the query string
-					queryString := instrumentName
-					queryString = strings.ReplaceAll(queryString, " Corporation ", " Corpn ")
-					queryString = strings.ReplaceAll(queryString, " corporation ", " Corpn ")
-					queryString = strings.ReplaceAll(queryString, " Limited", " Ltd ")
-					queryString = strings.ReplaceAll(queryString, " limited", " Ltd ")
-					queryString = strings.ReplaceAll(queryString, " and ", " & ")
-					queryString = strings.ReplaceAll(queryString, " And ", " & ")
-
-					// Prepare the text search filter
-					textSearchFilter := bson.M{
-						"$text": bson.M{
-							"$search": queryString,
-						},
-					}
-
-					// MongoDB collection
-					collection := mongo_client.Client.Database(os.Getenv("DATABASE")).Collection(os.Getenv("COLLECTION"))
-
-					// Set find options
-					findOptions := options.FindOne()
-					findOptions.SetProjection(bson.M{
-						"score": bson.M{"$meta": "textScore"},
-					})
-					findOptions.SetSort(bson.M{
-						"score": bson.M{"$meta": "textScore"},
-					})
-
-					// Perform the search
-					var result bson.M
-					err = collection.FindOne(context.TODO(), textSearchFilter, findOptions).Decode(&result)
-					if err != nil {
-						zap.L().Error("Error finding document", zap.Error(err))
-						continue
-					}
-
-					// Process based on the score
-					if score, ok := result["score"].(float64); ok {
-						if score >= 1 {
-							// zap.L().Info("marketCap", zap.Any("marketCap", result["marketCap"]), zap.Any("name", stockDetail["Name of the Instrument"]))
-							stockDetail["marketCapValue"] = result["marketCap"]
-							stockDetail["url"] = result["url"]
-							stockDetail["marketCap"] = helpers.GetMarketCapCategory(fmt.Sprintf("%v", result["marketCap"]))
-							stockDetail["stockRate"] = helpers.RateStock(result)
-
-							stockFScore := helpers.GenerateFScore(result)
-							if stockFScore < 0 {
-								stockDetail["fScore"] = "Not Available"
-							} else {
-								stockDetail["fScore"] = stockFScore
-							}
-						} else {
-							// zap.L().Info("score less than 1", zap.Float64("score", score))
-							results, err := http_client.SearchCompany(instrumentName)
-							if err != nil || len(results) == 0 {
-								zap.L().Error("No company found", zap.Error(err))
-								continue
-							}
-							data, err := helpers.FetchCompanyData(results[0].URL)
-							if err != nil {
-								zap.L().Error("Error fetching company data", zap.Error(err))
-								continue
-							}
-							// Update MongoDB with fetched data
-							update := bson.M{
-								"$set": bson.M{
-									"marketCap":           data["Market Cap"],
-									"currentPrice":        data["Current Price"],
-									"highLow":             data["High / Low"],
-									"stockPE":             data["Stock P/E"],
-									"bookValue":           data["Book Value"],
-									"dividendYield":       data["Dividend Yield"],
-									"roce":                data["ROCE"],
-									"roe":                 data["ROE"],
-									"faceValue":           data["Face Value"],
-									"pros":                data["pros"],
-									"cons":                data["cons"],
-									"quarterlyResults":    data["quarterlyResults"],
-									"profitLoss":          data["profitLoss"],
-									"balanceSheet":        data["balanceSheet"],
-									"cashFlows":           data["cashFlows"],
-									"ratios":              data["ratios"],
-									"shareholdingPattern": data["shareholdingPattern"],
-									"peersTable":          data["peersTable"],
-									"peers":               data["peers"],
-								},
-							}
-							updateOptions := options.Update().SetUpsert(true)
-							filter := bson.M{"name": results[0].Name}
-							_, err = collection.UpdateOne(context.TODO(), filter, update, updateOptions)
-							if err != nil {
-								zap.L().Error("Failed to update document", zap.Error(err))
-							} else {
-								zap.L().Info("Successfully updated document", zap.String("company", results[0].Name))
-							}
-						}
-					} else {
-						zap.L().Error("No score available for", zap.String("company", instrumentName))
-					}
-
-					// Marshal and write the stockDetail
-					stockDataMarshal, err := json.Marshal(stockDetail)
-					if err != nil {
-						zap.L().Error("Error marshalling data", zap.Error(err))
-						continue
-					}
-
-					_, err = ctx.Writer.Write(append(stockDataMarshal, '\n')) // Send each stockDetail as JSON with a newline separator
-
-					if err != nil {
-						zap.L().Error("Error writing data", zap.Error(err))
-						break
-					}
-					ctx.Writer.Flush() // Flush each chunk immediately
+				// Write the stockDetail, each entry is flushed immediately
+				if err := streamHolding(stockDetail); err != nil {
+					logger.Error("Error writing data", zap.Error(err))
+					break
 				}
 			}
+			if rowSpan != nil {
+				rowSpan.End()
+			}
+		}
+		// The file's holdings were streamed, the summary follows the last of their updates
+		if failed, err := enricher.updates.Flush(uploadCtx); err != nil {
+			streamStoreError(ctx, fileName, failed, err)
 		}
+		if err := writeStreamEntry(ctx, summary.Entry(fileName)); err != nil {
+			logger.Error("Error writing summary", zap.String("filePath", filePath), zap.Error(err))
+		}
+
 		if err := os.Remove(filePath); err != nil {
-			zap.L().Error("Error removing file", zap.String("filePath", filePath), zap.Error(err))
+			logger.Error("Error removing file", zap.String("filePath", filePath), zap.Error(err))
 		} else {
-			zap.L().Info("File removed successfully", zap.String("filePath", filePath))
+			logger.Info("File removed successfully", zap.String("filePath", filePath))
 		}
 	}
 
-	return nil
+	// The holdings left past the deadline are recorded on the upload, and enriched in the background
+	// (UPLOAD_DEADLINE_INGEST) when the backlog queue has room for them
+	uploadSummary := outcomes.Entry()
+	backlogs := helpers.Backlogs()
+	queued := false
+	if len(backlog.Rows) > 0 || outcomes.Count(helpers.FileUnprocessed) > 0 {
+		status := helpers.BacklogFinished
+		if len(backlog.Rows) > 0 {
+			queued = config.Get().UploadDeadlineIngest && backlogs.Reserve()
+			status = helpers.BacklogSkipped
+			if queued {
+				status = helpers.BacklogQueued
+			}
+		}
+		logger.Warn("Upload deadline exceeded", zap.Int("unprocessedRows", len(backlog.Rows)), zap.Int("unprocessedFiles", outcomes.Count(helpers.FileUnprocessed)), zap.String("backlog", status))
+		upload.Backlog = backlog.Record(status)
+		backlog.AddTo(uploadSummary, status)
+	}
+	if err := writeStreamEntry(ctx, uploadSummary); err != nil {
+		logger.Error("Error writing upload summary", zap.String("uploadId", upload.ID), zap.Error(err))
+	}
+
+	// The backlog is enriched once the record it is stored on exists
+	if err := UploadService.SaveUpload(uploadCtx, upload); err != nil {
+		logger.Error("Error saving upload record", zap.String("uploadId", upload.ID), zap.Error(err))
+		if queued {
+			backlogs.Release()
+		}
+	} else if queued {
+		backlogs.Run(func(ctx context.Context) {
+			enrichBacklog(ctx, upload.ID, upload.FundTag, backlog.Rows)
+		})
+	}
+
+	// The holdings of the processed files were streamed either way, see UPLOAD_MIN_PROCESSED_FILES
+	return outcomes.Check(config.Get().UploadMinProcessedFiles, config.Get().UploadFailOnFileError)
+}
+
+// jsonUploadFile is the file name a JSON upload's holdings are recorded and reported under
+const jsonUploadFile = "holdings.json"
+
+// ParseHoldings enriches the holdings of a JSON upload like the rows of a sheet and records the
+// upload, returning the enriched holdings in order. The fund tag is handled as by ParseXLSXFile.
+func (fs *fileService) ParseHoldings(ctx *gin.Context, holdings []types.HoldingSnapshot, fund types.FundTag) []map[string]interface{} {
+	uploadID := helpers.RequestID(ctx)
+	if uploadID == "" {
+		uploadID = uuid.New().String()
+	}
+	logger := helpers.Logger(ctx)
+	upload := types.UploadRecord{
+		ID:        uploadID,
+		CreatedAt: time.Now(),
+		Files:     []string{jsonUploadFile},
+		Holdings:  []types.HoldingSnapshot{},
+		Unmatched: []types.UnmatchedInstrument{},
+		FundTag:   fund,
+	}
+	ctx.Writer.Header().Set("X-Upload-Id", upload.ID)
+
+	// The stores keep going if the client disconnects, only the trace is inherited from the request
+	uploadCtx, uploadSpan := tracing.Start(context.WithoutCancel(ctx.Request.Context()), "ParseHoldings", attribute.String("upload.id", upload.ID))
+	defer uploadSpan.End()
+
+	details := make([]map[string]interface{}, 0, len(holdings))
+	for _, holding := range holdings {
+		details = append(details, helpers.HoldingDetail(holding))
+	}
+	if config.Get().AggregateByISIN {
+		details = helpers.AggregateHoldingsByISIN(details)
+	}
+
+	enricher := newHoldingEnricher(fund)
+	for _, stockDetail := range details {
+		enriched := enricher.enrich(uploadCtx, stockDetail, jsonUploadFile, "")
+		if enriched.storeErr != nil {
+			logger.Error("Error storing holdings", zap.Strings("companies", enriched.failed), zap.Error(enriched.storeErr))
+		}
+		if enriched.unmatched != nil {
+			upload.Unmatched = append(upload.Unmatched, *enriched.unmatched)
+		}
+		helpers.AddFormattedFields(stockDetail)
+		upload.Holdings = append(upload.Holdings, helpers.NewHoldingSnapshot(stockDetail))
+	}
+	if failed, err := enricher.updates.Flush(uploadCtx); err != nil {
+		logger.Error("Error storing holdings", zap.Strings("companies", failed), zap.Error(err))
+	}
+
+	if err := UploadService.SaveUpload(uploadCtx, upload); err != nil {
+		logger.Error("Error saving upload record", zap.String("uploadId", upload.ID), zap.Error(err))
+	}
+	return details
+}
+
+// holdingEnricher enriches the holdings of an upload, the ones it streams and the ones it left past its
+// deadline. The companies collection is resolved once for all of them, the score and fund updates of
+// the holdings are written to it in batches.
+type holdingEnricher struct {
+	collection *mongo.Collection
+	updates    *helpers.UpdateBatch
+	maxDataAge time.Duration
+	fund       types.FundTag
+	fundFields map[string]interface{}
+}
+
+func newHoldingEnricher(fund types.FundTag) *holdingEnricher {
+	cfg := config.Get()
+	collection := companiesCollection()
+	return &holdingEnricher{
+		collection: collection,
+		updates:    helpers.NewUpdateBatch(collection, cfg.UploadWriteBatchSize),
+		maxDataAge: cfg.MaxDataAge,
+		fund:       fund,
+		fundFields: helpers.FundHoldingFields(fund),
+	}
+}
+
+// enrichment is the outcome of enriching a holding
+type enrichment struct {
+	// company is the stored company the holding was matched to and scored with, nil when none was
+	company bson.M
+	// unmatched describes the holding when its company couldn't be resolved
+	unmatched *types.UnmatchedInstrument
+	// failed are the companies a batched write failed to store, with its storeErr
+	failed   []string
+	storeErr error
+}
+
+// enrich classifies a holding and matches an equity one to its stored company, scraping the company when
+// none is usable, adding the company's data and scores to it
+func (e *holdingEnricher) enrich(ctx context.Context, stockDetail map[string]interface{}, fileName, sheet string) enrichment {
+	logger := helpers.Logger(ctx)
+	instrumentName, _ := stockDetail["Name of the Instrument"].(string)
+	originalName := instrumentName
+
+	// Futures and options never match a company, their exposure is kept without enrichment
+	if helpers.IsDerivativeHolding(stockDetail) {
+		stockDetail["classification"] = helpers.HoldingDerivative
+		return enrichment{}
+	}
+	// Foreign stocks and ADRs aren't listed on screener, a scrape attempt would only fail
+	if helpers.IsForeignHolding(stockDetail) {
+		stockDetail["classification"] = helpers.HoldingForeign
+		return enrichment{}
+	}
+	stockDetail["classification"] = helpers.HoldingEquity
+
+	// An ISIN failing its check digit may belong to another company, the holding is matched
+	// by name instead and the bad ISIN flagged
+	isin, isinErr := helpers.HoldingISIN(stockDetail)
+	if isinErr != nil {
+		logger.Warn("Ignoring invalid ISIN", zap.String("company", instrumentName), zap.Any("isin", stockDetail["ISIN"]), zap.Error(isinErr))
+		stockDetail["invalidIsin"] = isinErr.Error()
+	}
+
+	// An imported name map entry resolves the holding first, by ISIN or name, without a text search
+	mapped, err := NameMapService.Lookup(ctx, isin, instrumentName)
+	if err != nil {
+		logger.Error("Error looking up name map", zap.String("company", instrumentName), zap.Error(err))
+	}
+
+	// Apply mapping if exists
+	if mappedName, exists := constants.MapValues[instrumentName]; exists && mapped == nil {
+		stockDetail["Name of the Instrument"] = mappedName
+		instrumentName = mappedName
+	}
+
+	// Perform the search
+	var result bson.M
+	var textMatch helpers.TextMatch
+	if mapped != nil {
+		result, err = CompanyService.FindMapped(ctx, *mapped)
+	} else {
+		textMatch, err = textSearchCompany(ctx, e.collection, instrumentName, isin)
+		result = textMatch.Company
+	}
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		logger.Error("Error finding document", zap.Error(err))
+		helpers.MarkUnresolved(stockDetail, helpers.UnresolvedLookupFailed)
+		unmatched := unmatchedInstrument(ctx, stockDetail, originalName, fileName, sheet, "", 0)
+		return enrichment{unmatched: &unmatched}
+	}
+
+	// Invalidated companies only keep their identity and go through a fresh scrape, like
+	// companies last scraped more than MAX_DATA_AGE_DAYS ago
+	score, _ := result["score"].(float64)
+	matched := err == nil && (mapped != nil || score >= 1) && helpers.UsableStoredData(result, e.maxDataAge, time.Now())
+	if matched && mapped != nil {
+		stockDetail["match"] = map[string]interface{}{"method": "nameMap", "name": result["name"]}
+	}
+	// A company matched but scraped too long ago is scraped again, its stored data is the
+	// fallback when that scrape fails
+	var staleMatch bson.M
+	var staleLabel interface{}
+	if !matched && err == nil && (mapped != nil || score >= 1) && helpers.HasFundamentals(result) {
+		staleMatch = result
+		if mapped != nil {
+			staleLabel = map[string]interface{}{"method": "nameMap", "name": result["name"]}
+		}
+	}
+	// Companies tied on the text score that no tiebreaker told apart, the pick may be wrong
+	if matched && textMatch.Ambiguous {
+		logger.Warn("Ambiguous text match", zap.String("company", instrumentName), zap.Strings("tied", textMatch.Tied))
+		stockDetail["match"] = map[string]interface{}{
+			"method":     "text",
+			"name":       result["name"],
+			"ambiguous":  true,
+			"candidates": textMatch.Tied,
+		}
+	}
+
+	// A weak text match falls back to the most similar stored name before scraping
+	if !matched && mapped == nil {
+		match, similarity, err := CompanyService.FindFuzzyMatch(ctx, instrumentName)
+		if err != nil {
+			logger.Error("Error fuzzy matching company", zap.String("company", instrumentName), zap.Error(err))
+		} else if match != nil && helpers.HasFundamentals(match) {
+			label := map[string]interface{}{
+				"method":     "fuzzy",
+				"name":       match["name"],
+				"similarity": helpers.Round(similarity),
+			}
+			if helpers.UsableStoredData(match, e.maxDataAge, time.Now()) {
+				result = match
+				matched = true
+				stockDetail["match"] = label
+			} else if staleMatch == nil {
+				staleMatch, staleLabel = match, label
+			}
+		}
+	}
+
+	if !matched {
+		// logger.Info("score less than 1", zap.Float64("score", score))
+		extra := bson.M{}
+		if isin != "" {
+			extra["isin"] = isin
+		}
+		if industry, _ := stockDetail["Industry/Rating"].(string); industry != "" {
+			extra["sector"] = helpers.NormalizeSector(industry)
+		}
+		// A mapped company is scraped from its entry's page, without searching
+		var storedName string
+		if mapped != nil {
+			// result is the stored company FindMapped found, nil when there is none
+			storedName, err = CompanyService.ScrapeMapped(ctx, *mapped, result, extra, e.fund.FundName)
+		} else {
+			storedName, err = CompanyService.ScrapeCompany(ctx, instrumentName, extra, e.fund.FundName)
+		}
+		// The scraped company is read back to be scored like a stored one
+		if err == nil {
+			var scraped bson.M
+			var findErr error
+			if mapped != nil {
+				scraped, findErr = CompanyService.FindMapped(ctx, *mapped)
+			} else {
+				scraped, findErr = findStoredCompany(ctx, storedName)
+			}
+			if findErr != nil {
+				logger.Error("Error finding scraped company", zap.String("company", storedName), zap.Error(findErr))
+			} else {
+				result, matched = scraped, true
+			}
+		}
+		if err != nil {
+			logger.Error("Error scraping company", zap.String("company", instrumentName), zap.Error(err))
+			// A failed write still keeps the holding. A failed scrape keeps the stored data of a
+			// stale match, flagged stale, and the holding unresolved without one.
+			switch {
+			case errors.Is(err, ErrCompanyNotStored):
+			case staleMatch != nil:
+				result, matched = staleMatch, true
+				stockDetail["stale"] = true
+				if staleLabel != nil {
+					stockDetail["match"] = staleLabel
+				}
+			case errors.Is(err, ErrCompanyNotFound):
+				helpers.MarkUnresolved(stockDetail, helpers.UnresolvedNoSearchMatch)
+			default:
+				helpers.MarkUnresolved(stockDetail, helpers.UnresolvedScrapeFailed)
+			}
+			if unresolved, _ := stockDetail["unresolved"].(bool); unresolved {
+				textMatch, _ := result["name"].(string)
+				unmatched := unmatchedInstrument(ctx, stockDetail, originalName, fileName, sheet, textMatch, score)
+				return enrichment{unmatched: &unmatched}
+			}
+		}
+	}
+	if !matched {
+		return enrichment{}
+	}
+
+	// logger.Info("marketCap", zap.Any("marketCap", result["marketCap"]), zap.Any("name", stockDetail["Name of the Instrument"]))
+	stockDetail["marketCapValue"] = result["marketCap"]
+	stockDetail["url"] = result["url"]
+	stockDetail["marketCap"] = helpers.GetMarketCapCategory(fmt.Sprintf("%v", result["marketCap"]))
+
+	// Prefer the stored sector, falling back to the sheet's industry column
+	industry, _ := stockDetail["Industry/Rating"].(string)
+	sector, _ := result["sector"].(string)
+	storeSector := sector == "" && industry != ""
+	if storeSector {
+		sector = helpers.NormalizeSector(industry)
+	}
+	// Persist the computed scores so stored companies can be listed and filtered. They are
+	// computed under the company's lock like every other write to it.
+	unlock := companyLocks.Lock(helpers.StoredCompanyLockKey(result))
+	scored := companyScores(ctx, result, sector)
+	for _, field := range []string{"stockRate", "stockRateRaw", "insufficientData", "scoreReasons", "peerPercentiles", "highDebt", "debtToEquity", "debtToEquityHistory", "valuation", "zScore"} {
+		stockDetail[field] = scored[field]
+	}
+	if unparseable, ok := scored["unparseableFields"]; ok {
+		stockDetail["unparseableFields"] = unparseable
+	}
+	if fScore, ok := scored["fScore"]; ok && fScore != nil {
+		stockDetail["fScore"] = fScore
+	} else {
+		stockDetail["fScore"] = "Not Available"
+	}
+	for key, value := range e.fundFields {
+		stockDetail[key] = value
+	}
+
+	if storeSector {
+		scored["sector"] = sector
+	}
+	if isin != "" && result["isin"] == nil {
+		scored["isin"] = isin
+	}
+	helpers.StampScored(scored)
+	stockDetail["lastScored"] = scored["lastScored"]
+	stockDetail["scoringConfig"] = scored["scoringConfig"]
+	if lastScraped, ok := result["lastScraped"]; ok {
+		stockDetail["lastScraped"] = lastScraped
+	}
+	update := bson.M{"$set": scored}
+	if e.fund.FundName != "" {
+		// Companies remember the funds holding them so the list can be filtered by fund
+		update["$addToSet"] = bson.M{"funds": e.fund.FundName}
+	}
+	companyName, _ := result["name"].(string)
+	// The write is batched, so it only applies to the document the scores were computed
+	// from: a scrape storing new fundamentals meanwhile leaves it unmatched
+	filter := bson.M{"_id": result["_id"], "lastScraped": result["lastScraped"]}
+	failed, err := e.updates.Add(ctx, companyName, filter, update)
+	unlock()
+	return enrichment{company: result, failed: failed, storeErr: err}
+}
+
+// backlogFile records the holdings of a file reached after the upload deadline, without enriching them
+func backlogFile(ctx context.Context, file io.Reader, fileName, password string, backlog *helpers.UploadBacklog) {
+	sheets, err := helpers.ReadXLSXSheets(file, excelize.Options{Password: password})
+	if err != nil {
+		helpers.Logger(ctx).Warn("Error reading file left past the upload deadline", zap.Error(err))
+		return
+	}
+	for _, sheetRows := range sheets {
+		if sheetRows.Err != nil {
+			continue
+		}
+		holdings := helpers.ExtractHoldings(sheetRows.Rows)
+		if config.Get().AggregateByISIN {
+			holdings = helpers.AggregateHoldingsByISIN(holdings)
+		}
+		for _, holding := range holdings {
+			backlog.Add(fileName, sheetRows.Sheet, holding)
+		}
+	}
+}
+
+// enrichBacklog enriches the holdings an upload left past its deadline like the ones it streamed, and
+// stores them on its record. It runs on the backlog queue (helpers.Backlogs) and waits for an upload
+// slot like an upload. A backlog stopped with the queue is left pending.
+func enrichBacklog(ctx context.Context, uploadID string, fund types.FundTag, rows []types.BacklogRow) {
+	logger := helpers.Logger(ctx).With(zap.String("uploadId", uploadID))
+	slots := helpers.Uploads()
+	for {
+		err := slots.Acquire(ctx, config.Get().UploadQueueTimeout)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, helpers.ErrUploadSlotsFull) || config.Get().UploadQueueTimeout <= 0 {
+			logger.Warn("Leaving the upload backlog pending", zap.Error(err))
+			return
+		}
+	}
+	defer slots.Release()
+
+	enricher := newHoldingEnricher(fund)
+	fields := helpers.DefaultFieldProjection()
+	holdings := make([]map[string]interface{}, 0, len(rows))
+	snapshots := make([]types.HoldingSnapshot, 0, len(rows))
+	unmatched := []types.UnmatchedInstrument{}
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			logger.Warn("Leaving the upload backlog pending", zap.Error(ctx.Err()))
+			return
+		}
+		enriched := enricher.enrich(ctx, row.Holding, row.File, row.Sheet)
+		if enriched.storeErr != nil {
+			logger.Error("Failed to store company updates", zap.String("file", row.File), zap.Strings("companies", enriched.failed), zap.Error(enriched.storeErr))
+		}
+		if enriched.unmatched != nil {
+			unmatched = append(unmatched, *enriched.unmatched)
+		}
+		helpers.AddFormattedFields(row.Holding)
+		holdings = append(holdings, fields.Apply(row.Holding))
+		snapshots = append(snapshots, helpers.NewHoldingSnapshot(row.Holding))
+	}
+	if failed, err := enricher.updates.Flush(ctx); err != nil {
+		logger.Error("Failed to store company updates", zap.Strings("companies", failed), zap.Error(err))
+	}
+
+	if err := UploadService.FinishBacklog(ctx, uploadID, holdings, snapshots, unmatched); err != nil {
+		logger.Error("Error saving upload backlog", zap.Error(err))
+		return
+	}
+	logger.Info("Enriched the upload backlog", zap.Int("holdings", len(holdings)), zap.Int("unmatched", len(unmatched)))
+}
+
+// textSearchCompany returns the stored company best matching an instrument name with its text
+// search score, the companies tied on the top score are told apart by helpers.PickTextMatch. The
+// error is mongo.ErrNoDocuments when nothing matches.
+func textSearchCompany(ctx context.Context, collection *mongo.Collection, instrumentName, isin string) (helpers.TextMatch, error) {
+	// Clean up the query string
+	queryString := instrumentName
+	queryString = strings.ReplaceAll(queryString, " Corporation ", " Corpn ")
+	queryString = strings.ReplaceAll(queryString, " corporation ", " Corpn ")
+	queryString = strings.ReplaceAll(queryString, " Limited", " Ltd ")
+	queryString = strings.ReplaceAll(queryString, " limited", " Ltd ")
+	queryString = strings.ReplaceAll(queryString, " and ", " & ")
+	queryString = strings.ReplaceAll(queryString, " And ", " & ")
+
+	// Prepare the text search filter, quotes and minus signs are stripped so they
+	// don't turn into phrase searches or negations
+	textSearchFilter := helpers.TextSearchFilter(queryString)
+
+	// Set find options, the top TEXT_SEARCH_CANDIDATES results so ties can be seen
+	findOptions := options.Find()
+	findOptions.SetProjection(bson.M{
+		"score": bson.M{"$meta": "textScore"},
+	})
+	findOptions.SetSort(bson.M{
+		"score": bson.M{"$meta": "textScore"},
+	})
+	findOptions.SetLimit(int64(config.Get().TextSearchCandidates))
+
+	cursor, err := collection.Find(ctx, textSearchFilter, findOptions)
+	if err != nil {
+		return helpers.TextMatch{}, err
+	}
+	var results []bson.M
+	if err := cursor.All(ctx, &results); err != nil {
+		return helpers.TextMatch{}, err
+	}
+	if len(results) == 0 {
+		return helpers.TextMatch{}, mongo.ErrNoDocuments
+	}
+	return helpers.PickTextMatch(results, isin, instrumentName), nil
+}
+
+// unmatchedCandidates is how many stored names are suggested for an unmatched instrument
+const unmatchedCandidates = 3
+
+// unmatchedInstrument describes an unresolved holding for its upload record, with the best text
+// search result and the stored names that came closest
+func unmatchedInstrument(ctx context.Context, stockDetail map[string]interface{}, originalName, file, sheet, textMatch string, textScore float64) types.UnmatchedInstrument {
+	unmatched := helpers.NewUnmatchedInstrument(stockDetail, originalName)
+	unmatched.File, unmatched.Sheet = file, sheet
+	unmatched.TextMatch, unmatched.TextScore = textMatch, helpers.Round(textScore)
+
+	instrumentName, _ := stockDetail["Name of the Instrument"].(string)
+	candidates, err := CompanyService.FuzzyCandidates(ctx, instrumentName, unmatchedCandidates)
+	if err != nil {
+		helpers.Logger(ctx).Error("Error listing fuzzy candidates", zap.String("company", instrumentName), zap.Error(err))
+	} else {
+		unmatched.Candidates = candidates
+	}
+	return unmatched
+}
+
+// writeStreamEntry sends a single entry in the requested format and flushes it immediately
+func writeStreamEntry(ctx *gin.Context, entry interface{}) error {
+	return StreamWriter(ctx).WriteEntry(entry)
+}
+
+// StreamWriter returns the writer of the response stream, the controller picks its format and
+// fields (see helpers.ParseStreamFormat and helpers.ParseFieldProjection), ndjson without the
+// denylisted fields is used when none was set
+func StreamWriter(ctx *gin.Context) *helpers.StreamWriter {
+	if value, ok := ctx.Get(streamWriterKey); ok {
+		if stream, ok := value.(*helpers.StreamWriter); ok {
+			return stream
+		}
+	}
+	stream := helpers.NewStreamWriter(ctx.Writer, helpers.StreamNDJSON)
+	stream.SetFields(helpers.DefaultFieldProjection())
+	ctx.Set(streamWriterKey, stream)
+	return stream
+}
+
+// SetStreamWriter makes the service stream entries with the given writer
+func SetStreamWriter(ctx *gin.Context, stream *helpers.StreamWriter) {
+	ctx.Set(streamWriterKey, stream)
+}
+
+// streamStoreError tells the client which companies' scores or fund tags a batched write failed to store
+func streamStoreError(ctx *gin.Context, file string, companies []string, err error) {
+	helpers.Logger(ctx).Error("Failed to store company updates", zap.String("file", file), zap.Strings("companies", companies), zap.Error(err))
+	// The write error is logged only, the driver's errors name the hosts of the deployment
+	streamError(ctx, file, "", fmt.Errorf("could not store the updates of %s", strings.Join(companies, ", ")))
+}
+
+// streamError tells the client that a file or sheet could not be processed instead of skipping it silently
+func streamError(ctx *gin.Context, file string, sheet string, err error) {
+	if writeErr := writeStreamEntry(ctx, helpers.StreamErrorEntry(file, sheet, err)); writeErr != nil {
+		helpers.Logger(ctx).Error("Error streaming parse error", zap.String("file", file), zap.Error(writeErr))
+	}
 }
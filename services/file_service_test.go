@@ -0,0 +1,498 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// holdingsHeader is the header row of the workbooks the tests upload
+var holdingsHeader = []interface{}{"Name of the Instrument", "ISIN", "Industry/Rating", "Quantity", "Market/Fair Value", "% to Net Assets"}
+
+// writeWorkbook writes the rows to an xlsx file of the test's temporary directory
+func writeWorkbook(t testing.TB, name string, rows [][]interface{}) string {
+	workbook := excelize.NewFile()
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := workbook.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatalf("Error writing workbook row: %v", err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := workbook.SaveAs(path); err != nil {
+		t.Fatalf("Error writing workbook: %v", err)
+	}
+	return path
+}
+
+// parseUpload runs ParseXLSXFile on the workbooks and returns the entries it streamed
+func parseUpload(t testing.TB, paths ...string) ([]map[string]interface{}, error) {
+	return parseUploadRejecting(t, nil, paths...)
+}
+
+// parseUploadRejecting runs ParseXLSXFile like parseUpload, with zip entries rejected before the workbooks
+func parseUploadRejecting(t testing.TB, rejected []helpers.ZipEntry, paths ...string) ([]map[string]interface{}, error) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/api/uploadXlsx", nil)
+
+	files := make(chan string, len(paths))
+	for _, path := range paths {
+		files <- path
+	}
+	close(files)
+	err := FileService.ParseXLSXFile(ctx, files, rejected, "", types.FundTag{})
+
+	entries := []map[string]interface{}{}
+	decoder := json.NewDecoder(w.Body)
+	for decoder.More() {
+		var entry map[string]interface{}
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatalf("Error decoding the stream: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, err
+}
+
+// fakeNameMap resolves holdings with its entries like the stored name map
+type fakeNameMap struct {
+	NameMapServiceI
+	entries []types.NameMapEntry
+}
+
+func (f *fakeNameMap) Lookup(ctx context.Context, isin, name string) (*types.NameMapEntry, error) {
+	entries := make([]types.NameMapEntry, len(f.entries))
+	for i, entry := range f.entries {
+		entry.Key = helpers.NameMapKey(entry.Name)
+		entries[i] = entry
+	}
+	return helpers.PickNameMapEntry(entries, isin, name), nil
+}
+
+// fakeUploads keeps the saved upload records and the enriched backlog holdings
+type fakeUploads struct {
+	UploadServiceI
+	saved    []types.UploadRecord
+	backlogs map[string][]map[string]interface{}
+}
+
+func (f *fakeUploads) SaveUpload(ctx context.Context, record types.UploadRecord) error {
+	f.saved = append(f.saved, record)
+	return nil
+}
+
+func (f *fakeUploads) FinishBacklog(ctx context.Context, id string, holdings []map[string]interface{}, snapshots []types.HoldingSnapshot, unmatched []types.UnmatchedInstrument) error {
+	if f.backlogs == nil {
+		f.backlogs = map[string][]map[string]interface{}{}
+	}
+	f.backlogs[id] = holdings
+	return nil
+}
+
+// useUploadFakes replaces the name map and the upload records for the test
+func useUploadFakes(mt *mtest.T, nameMap *fakeNameMap, uploads *fakeUploads) {
+	previousNameMap, previousUploads := NameMapService, UploadService
+	NameMapService, UploadService = nameMap, uploads
+	mt.Cleanup(func() {
+		NameMapService, UploadService = previousNameMap, previousUploads
+	})
+}
+
+// holdingsOf returns the holdings among the streamed entries
+func holdingsOf(entries []map[string]interface{}) []map[string]interface{} {
+	holdings := []map[string]interface{}{}
+	for _, entry := range entries {
+		if _, ok := entry["type"]; !ok {
+			holdings = append(holdings, entry)
+		}
+	}
+	return holdings
+}
+
+func TestParseXLSXFile_NameMapPrecedence(t *testing.T) {
+	byName := types.NameMapEntry{Name: "63 Moons Technologies Limited", URL: "https://www.screener.in/company/63MOONS/"}
+	byISIN := types.NameMapEntry{Name: "63 Moons Tech", ISIN: "INE111B01023", URL: "https://www.screener.in/company/63MOONS/consolidated/"}
+	workbook := [][]interface{}{
+		holdingsHeader,
+		{"63 Moons Technologies Limited", "INE111B01023", "Capital Markets", 1000, 250.5, 1.2},
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("stored company", func(mt *mtest.T) {
+		useMockMongo(mt)
+		useUploadFakes(mt, &fakeNameMap{entries: []types.NameMapEntry{byName, byISIN}}, &fakeUploads{})
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: id},
+				{Key: "name", Value: "63 Moons Tech."},
+				{Key: "url", Value: byISIN.URL},
+				{Key: "marketCap", Value: "1,889"},
+				{Key: "lastScraped", Value: primitive.NewDateTimeFromTime(time.Now())},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		entries, err := parseUpload(mt, writeWorkbook(mt, "moons.xlsx", workbook))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		holdings := holdingsOf(entries)
+		if len(holdings) != 1 {
+			t.Fatalf("Expected 1 holding, got %v", entries)
+		}
+		match, _ := holdings[0]["match"].(map[string]interface{})
+		if match["method"] != "nameMap" || match["name"] != "63 Moons Tech." {
+			t.Errorf("Expected the stored company of the name map entry, got %v", holdings[0]["match"])
+		}
+
+		// The ISIN's entry wins over the name's, and no text search is run
+		find := mt.GetStartedEvent()
+		if find == nil || find.CommandName != "find" {
+			t.Fatalf("Expected the mapped company to be looked up, got %v", find)
+		}
+		if _, err := find.Command.LookupErr("filter", "$text"); err == nil {
+			t.Errorf("Expected no text search, got %v", find.Command)
+		}
+		if url := find.Command.Lookup("filter", "$or", "0", "url").StringValue(); url != byISIN.URL {
+			t.Errorf("Expected the company of the ISIN's entry, got %s", url)
+		}
+		if update := mt.GetStartedEvent(); update == nil || update.CommandName != "update" {
+			t.Errorf("Expected the scores to be written, got %v", update)
+		}
+	})
+
+	mt.Run("stale company", func(mt *mtest.T) {
+		useMockMongo(mt)
+		useUploadFakes(mt, &fakeNameMap{entries: []types.NameMapEntry{byName, byISIN}}, &fakeUploads{})
+		useSource(mt, &fakeSource{url: byISIN.URL})
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(
+			// Stored under its screener name and scraped long ago
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: id},
+				{Key: "name", Value: "63 Moons Tech."},
+				{Key: "isin", Value: "INE111B01023"},
+				{Key: "marketCap", Value: "1,889"},
+				{Key: "lastScraped", Value: primitive.NewDateTimeFromTime(time.Now().AddDate(-1, 0, 0))},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		if _, err := parseUpload(mt, writeWorkbook(mt, "moons.xlsx", workbook)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent()
+		if update == nil || update.CommandName != "update" {
+			t.Fatalf("Expected the company to be scraped again, got %v", update)
+		}
+		// The stored document is updated in place instead of a new one being inserted under the entry's name
+		filter := update.Command.Lookup("updates", "0", "q")
+		if filter.Document().Lookup("_id").ObjectID() != id {
+			t.Errorf("Expected the stored company to be updated by its _id, got %v", filter)
+		}
+	})
+}
+
+func TestParseXLSXFile_StaleMatchFallback(t *testing.T) {
+	entry := types.NameMapEntry{Name: "63 Moons Tech", ISIN: "INE111B01023", URL: "https://www.screener.in/company/63MOONS/"}
+	workbook := [][]interface{}{
+		holdingsHeader,
+		{"63 Moons Technologies Limited", "INE111B01023", "Capital Markets", 1000, 250.5, 1.2},
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("scrape fails", func(mt *mtest.T) {
+		useMockMongo(mt)
+		uploads := &fakeUploads{}
+		useUploadFakes(mt, &fakeNameMap{entries: []types.NameMapEntry{entry}}, uploads)
+		useSource(mt, &fakeSource{failing: entry.URL})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "name", Value: "63 Moons Tech."},
+				{Key: "url", Value: entry.URL},
+				{Key: "marketCap", Value: "1,500"},
+				{Key: "lastScraped", Value: primitive.NewDateTimeFromTime(time.Now().AddDate(-1, 0, 0))},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		entries, err := parseUpload(mt, writeWorkbook(mt, "moons.xlsx", workbook))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		holdings := holdingsOf(entries)
+		if len(holdings) != 1 {
+			t.Fatalf("Expected 1 holding, got %v", entries)
+		}
+		holding := holdings[0]
+		if holding["stale"] != true || holding["unresolved"] != nil {
+			t.Errorf("Expected the stored data flagged stale, got %v", holding)
+		}
+		if holding["marketCapValue"] != "1,500" {
+			t.Errorf("Expected the stored market cap, got %v", holding["marketCapValue"])
+		}
+		if len(uploads.saved) != 1 || len(uploads.saved[0].Unmatched) != 0 {
+			t.Errorf("Expected no unmatched holding, got %v", uploads.saved)
+		}
+	})
+}
+
+func TestParseXLSXFile_DeadlineExceeded(t *testing.T) {
+	entry := types.NameMapEntry{Name: "63 Moons Tech", ISIN: "INE111B01023", URL: "https://www.screener.in/company/63MOONS/"}
+	first := [][]interface{}{
+		holdingsHeader,
+		{"63 Moons Technologies Limited", "INE111B01023", "Capital Markets", 1000, 250.5, 1.2},
+		{"Infosys Limited", "INE009A01021", "IT - Software", 500, 800, 3.4},
+		{"NIFTY 26SEP2024 FUT", "", "", 50, 120, 0.5},
+		{"Apple Inc", "US0378331005", "Technology", 10, 30, 0.1},
+	}
+	second := [][]interface{}{
+		holdingsHeader,
+		{"HDFC Bank Limited", "INE040A01034", "Banks", 700, 1100, 4.1},
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("deadline passes during the first scrape", func(mt *mtest.T) {
+		useMockMongo(mt)
+		useConfig(mt, map[string]string{"UPLOAD_DEADLINE_SECONDS": "0.1", "UPLOAD_DEADLINE_INGEST": "false"})
+		uploads := &fakeUploads{}
+		useUploadFakes(mt, &fakeNameMap{entries: []types.NameMapEntry{entry}}, uploads)
+		useSource(mt, &fakeSource{url: entry.URL, delay: 300 * time.Millisecond})
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: id},
+				{Key: "name", Value: "63 Moons Tech."},
+				{Key: "url", Value: entry.URL},
+				{Key: "lastScraped", Value: primitive.NewDateTimeFromTime(time.Now().AddDate(-1, 0, 0))},
+			}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: id},
+				{Key: "name", Value: "63 Moons Tech."},
+				{Key: "url", Value: entry.URL},
+				{Key: "marketCap", Value: "1,889"},
+				{Key: "lastScraped", Value: primitive.NewDateTimeFromTime(time.Now())},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		entries, err := parseUpload(mt, writeWorkbook(mt, "first.xlsx", first), writeWorkbook(mt, "second.xlsx", second))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// The holdings reached after the scrape are left to the background, except the derivative and
+		// the foreign holding which need no lookup
+		classifications := map[string]interface{}{}
+		for _, holding := range holdingsOf(entries) {
+			classifications[holding["Name of the Instrument"].(string)] = holding["classification"]
+		}
+		if len(classifications) != 3 || classifications["NIFTY 26SEP2024 FUT"] != helpers.HoldingDerivative || classifications["Apple Inc"] != helpers.HoldingForeign {
+			t.Errorf("Expected the first holding, the derivative and the foreign holding, got %v", classifications)
+		}
+		if _, ok := classifications["Infosys Limited"]; ok {
+			t.Errorf("Expected Infosys Limited not to be enriched")
+		}
+		// Only the first holding was looked up, scraped, read back and scored
+		for _, command := range []string{"find", "update", "find", "update"} {
+			if event := mt.GetStartedEvent(); event == nil || event.CommandName != command {
+				t.Fatalf("Expected a %s of the first holding, got %v", command, event)
+			}
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			t.Errorf("Expected no lookup past the deadline, got %v", event.CommandName)
+		}
+
+		summary := entries[len(entries)-1]
+		if summary["type"] != "uploadSummary" || summary["deadlineExceeded"] != true || summary["unprocessed"] != float64(1) || summary["unprocessedRows"] != float64(2) || summary["backlog"] != helpers.BacklogSkipped {
+			t.Errorf("Unexpected upload summary: %v", summary)
+		}
+		files, _ := summary["files"].([]interface{})
+		if len(files) != 2 || files[1].(map[string]interface{})["status"] != helpers.FileUnprocessed {
+			t.Errorf("Expected the second file unprocessed, got %v", summary["files"])
+		}
+
+		// The holdings left are recorded on the upload with the file they were read from
+		if len(uploads.saved) != 1 || uploads.saved[0].Backlog == nil {
+			t.Fatalf("Expected the backlog on the upload record, got %v", uploads.saved)
+		}
+		pending := uploads.saved[0].Backlog.Pending
+		if len(pending) != 2 || pending[0].File != "first.xlsx" || pending[1].File != "second.xlsx" || pending[1].Holding["Name of the Instrument"] != "HDFC Bank Limited" {
+			t.Errorf("Unexpected pending rows: %v", pending)
+		}
+	})
+}
+
+func TestEnrichBacklog(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("derivative and foreign holding", func(mt *mtest.T) {
+		useMockMongo(mt)
+		uploads := &fakeUploads{}
+		useUploadFakes(mt, &fakeNameMap{}, uploads)
+		rows := []types.BacklogRow{
+			{File: "first.xlsx", Sheet: "Sheet1", Holding: map[string]interface{}{"Name of the Instrument": "NIFTY 26SEP2024 FUT", "Quantity": 50.0}},
+			{File: "first.xlsx", Sheet: "Sheet1", Holding: map[string]interface{}{"Name of the Instrument": "Apple Inc", "ISIN": "US0378331005"}},
+		}
+
+		enrichBacklog(context.Background(), "upload-1", types.FundTag{}, rows)
+
+		holdings := uploads.backlogs["upload-1"]
+		if len(holdings) != 2 || holdings[0]["classification"] != helpers.HoldingDerivative || holdings[1]["classification"] != helpers.HoldingForeign {
+			t.Errorf("Expected the backlog classified and stored on the upload, got %v", holdings)
+		}
+	})
+}
+
+func TestParseXLSXFile_FileOutcomes(t *testing.T) {
+	// Futures need no lookup, the workbooks are processed without a company being read
+	futures := [][]interface{}{
+		holdingsHeader,
+		{"NIFTY 26SEP2024 FUT", "", "", 50, 120, 0.5},
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("corrupt file and rejected zip entry", func(mt *mtest.T) {
+		useMockMongo(mt)
+		useConfig(mt, map[string]string{"UPLOAD_FAIL_ON_FILE_ERROR": "true"})
+		uploads := &fakeUploads{}
+		useUploadFakes(mt, &fakeNameMap{}, uploads)
+		corrupt := filepath.Join(mt.TempDir(), "corrupt.xlsx")
+		if err := os.WriteFile(corrupt, []byte("this is not a spreadsheet"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		rejected := []helpers.ZipEntry{{Name: "notes.xlsx", Err: helpers.ErrZipTooLarge}}
+
+		entries, err := parseUploadRejecting(mt, rejected, writeWorkbook(mt, "first.xlsx", futures), corrupt, writeWorkbook(mt, "second.xlsx", futures))
+		if !errors.Is(err, helpers.ErrUploadFilesFailed) {
+			t.Errorf("Expected the failed files to fail the upload, got %v", err)
+		}
+		if holdings := holdingsOf(entries); len(holdings) != 2 {
+			t.Errorf("Expected the holdings of the 2 good files, got %v", holdings)
+		}
+
+		summary := entries[len(entries)-1]
+		if summary["type"] != "uploadSummary" || summary["processed"] != float64(2) || summary["failed"] != float64(2) {
+			t.Fatalf("Unexpected upload summary: %v", summary)
+		}
+		want := []struct{ file, status string }{
+			{"notes.xlsx", helpers.FileFailed},
+			{"first.xlsx", helpers.FileProcessed},
+			{"corrupt.xlsx", helpers.FileFailed},
+			{"second.xlsx", helpers.FileProcessed},
+		}
+		files, _ := summary["files"].([]interface{})
+		if len(files) != len(want) {
+			t.Fatalf("Expected %d files, got %v", len(want), files)
+		}
+		for i, file := range files {
+			outcome := file.(map[string]interface{})
+			if outcome["file"] != want[i].file || outcome["status"] != want[i].status {
+				t.Errorf("files[%d] = %v, want %s %s", i, outcome, want[i].file, want[i].status)
+			}
+			if _, hasError := outcome["error"]; hasError != (want[i].status == helpers.FileFailed) {
+				t.Errorf("files[%d] has error %v", i, outcome["error"])
+			}
+		}
+		if len(uploads.saved) != 1 || len(uploads.saved[0].Files) != 4 {
+			t.Errorf("Expected the 4 files on the upload record, got %v", uploads.saved)
+		}
+	})
+}
+
+func TestParseXLSXFile_UnresolvedHolding(t *testing.T) {
+	workbook := [][]interface{}{
+		holdingsHeader,
+		{"NIFTY 26SEP2024 FUT", "", "", 50, 120, 0.5},
+		{"Unheard Of Industries Limited", "", "Chemicals", 10, 20, 0.1},
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("no search match", func(mt *mtest.T) {
+		useMockMongo(mt)
+		useConfig(mt, map[string]string{"FUZZY_MATCH_THRESHOLD": "0"})
+		uploads := &fakeUploads{}
+		useUploadFakes(mt, &fakeNameMap{}, uploads)
+		// Neither stored nor found by the search
+		useSource(mt, &fakeSource{})
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch))
+
+		entries, err := parseUpload(mt, writeWorkbook(mt, "unheard.xlsx", workbook))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// The holding is streamed as a placeholder with its sheet columns instead of dropped
+		holdings := holdingsOf(entries)
+		if len(holdings) != 2 {
+			t.Fatalf("Expected one entry per holding row, got %v", entries)
+		}
+		placeholder := holdings[1]
+		if placeholder["Name of the Instrument"] != "Unheard Of Industries Limited" || placeholder["unresolved"] != true || placeholder["reason"] != helpers.UnresolvedNoSearchMatch {
+			t.Errorf("Expected an unresolved placeholder, got %v", placeholder)
+		}
+		if placeholder["Industry/Rating"] != "Chemicals" || placeholder["stockRate"] != nil {
+			t.Errorf("Expected the sheet columns without a rating, got %v", placeholder)
+		}
+		if len(uploads.saved) != 1 || len(uploads.saved[0].Unmatched) != 1 || uploads.saved[0].Unmatched[0].Reason != helpers.UnresolvedNoSearchMatch {
+			t.Errorf("Expected the holding on the upload's unmatched instruments, got %v", uploads.saved)
+		}
+	})
+}
+
+func TestParseHoldings(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("json upload", func(mt *mtest.T) {
+		useMockMongo(mt)
+		useConfig(mt, map[string]string{"FUZZY_MATCH_THRESHOLD": "0"})
+		uploads := &fakeUploads{}
+		useUploadFakes(mt, &fakeNameMap{}, uploads)
+		useSource(mt, &fakeSource{})
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch))
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/uploadJson", nil)
+		holdings := []types.HoldingSnapshot{
+			{Name: "NIFTY 26SEP2024 FUT", Quantity: 50, MarketValue: 120, PercentageOfAUM: 0.5},
+			{Name: "Unheard Of Industries Limited", Quantity: 10, MarketValue: 20, PercentageOfAUM: 0.1},
+		}
+		enriched := FileService.ParseHoldings(ctx, holdings, types.FundTag{FundName: "Flexi Cap Fund"})
+
+		// The holdings are enriched in order like the rows of a sheet
+		if len(enriched) != 2 || enriched[0]["classification"] != helpers.HoldingDerivative || enriched[1]["unresolved"] != true {
+			t.Fatalf("Expected the derivative and the unresolved holding, got %v", enriched)
+		}
+		if w.Header().Get("X-Upload-Id") == "" {
+			t.Errorf("Expected the upload ID header")
+		}
+		if len(uploads.saved) != 1 {
+			t.Fatalf("Expected the upload to be recorded, got %v", uploads.saved)
+		}
+		saved := uploads.saved[0]
+		if saved.FundTag.FundName != "Flexi Cap Fund" || len(saved.Holdings) != 2 || saved.Holdings[1].Quantity != 10 || len(saved.Unmatched) != 1 {
+			t.Errorf("Expected the fund, holdings and unmatched instrument on the record, got %+v", saved)
+		}
+	})
+}
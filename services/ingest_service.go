@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	IngestStored = "stored"
+	IngestFailed = "failed"
+)
+
+type IngestServiceI interface {
+	IngestCompanies(ctx context.Context, names []string) <-chan types.IngestResult
+}
+
+// ingestService runs ingest on every name, ingestCompany outside of tests. Its limiter is shared by
+// every ingest, so concurrent ingests start INGEST_RATE_PER_MINUTE names per minute between them.
+type ingestService struct {
+	limiter *helpers.RateLimiter
+	ingest  func(ctx context.Context, name string) types.IngestResult
+}
+
+var IngestService IngestServiceI = &ingestService{limiter: &helpers.RateLimiter{}, ingest: ingestCompany}
+
+// IngestCompanies resolves, scrapes, scores and stores every name like a holding of an upload (see
+// holdingEnricher), INGEST_CONCURRENCY at a time and starting at most INGEST_RATE_PER_MINUTE per
+// minute across every ingest. The results are sent in the order they finish and the channel is
+// closed after the last one. Once ctx is done no further name is started, the ones in progress are
+// still stored.
+func (is *ingestService) IngestCompanies(ctx context.Context, names []string) <-chan types.IngestResult {
+	cfg := config.Get()
+	interval := time.Minute / time.Duration(cfg.IngestRatePerMinute)
+	results := make(chan types.IngestResult)
+	pending := make(chan string)
+
+	go func() {
+		defer close(pending)
+		for _, name := range names {
+			select {
+			case <-ctx.Done():
+				return
+			case pending <- name:
+			}
+		}
+	}()
+
+	var workers sync.WaitGroup
+	for range min(cfg.IngestConcurrency, len(names)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for name := range pending {
+				// A name waiting for its turn when ctx is done isn't started
+				if err := is.limiter.Wait(ctx, interval); err != nil {
+					continue
+				}
+				results <- is.ingest(context.WithoutCancel(ctx), name)
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// ingestCompany runs the enrichment of an upload's holdings for one name and summarizes its outcome
+func ingestCompany(ctx context.Context, name string) types.IngestResult {
+	enricher := newHoldingEnricher(types.FundTag{})
+	stockDetail := map[string]interface{}{"Name of the Instrument": name}
+	enriched := enricher.enrich(ctx, stockDetail, "", "")
+	if enriched.storeErr == nil {
+		enriched.failed, enriched.storeErr = enricher.updates.Flush(ctx)
+	}
+
+	switch {
+	case stockDetail["classification"] != helpers.HoldingEquity:
+		return types.IngestResult{Name: name, Status: IngestFailed, Reason: helpers.IngestNotEquity}
+	case stockDetail["unresolved"] == true:
+		reason, _ := stockDetail["reason"].(string)
+		return types.IngestResult{Name: name, Status: IngestFailed, Reason: reason}
+	case enriched.company == nil || enriched.storeErr != nil:
+		helpers.Logger(ctx).Error("Error storing ingested company", zap.String("company", name), zap.Error(enriched.storeErr))
+		return types.IngestResult{Name: name, Status: IngestFailed, Reason: helpers.IngestNotStored}
+	}
+
+	stored, _ := enriched.company["name"].(string)
+	return types.IngestResult{
+		Name:      name,
+		Status:    IngestStored,
+		Company:   stored,
+		StockRate: stockDetail["stockRate"],
+		FScore:    stockDetail["fScore"],
+	}
+}
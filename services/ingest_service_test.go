@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// fakeIngest records when every name was started and how many ran at once
+type fakeIngest struct {
+	delay time.Duration
+
+	mu         sync.Mutex
+	started    []time.Time
+	running    int
+	maxRunning int
+}
+
+func (f *fakeIngest) ingest(ctx context.Context, name string) types.IngestResult {
+	f.mu.Lock()
+	f.started = append(f.started, time.Now())
+	f.running++
+	f.maxRunning = max(f.maxRunning, f.running)
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running--
+	return types.IngestResult{Name: name, Status: IngestStored}
+}
+
+// useIngestConfig sets the ingest limits of the test
+func useIngestConfig(t *testing.T, concurrency, ratePerMinute string) {
+	previous := config.Get()
+	config.Set(config.FromMap(map[string]string{"INGEST_CONCURRENCY": concurrency, "INGEST_RATE_PER_MINUTE": ratePerMinute}))
+	t.Cleanup(func() { config.Set(previous) })
+}
+
+func TestIngestCompanies_Concurrency(t *testing.T) {
+	useIngestConfig(t, "2", "60000")
+	fake := &fakeIngest{delay: 20 * time.Millisecond}
+	service := &ingestService{limiter: &helpers.RateLimiter{}, ingest: fake.ingest}
+
+	names := []string{"Infosys", "HDFC Bank", "Reliance", "TCS", "ITC", "Wipro"}
+	results := 0
+	for range service.IngestCompanies(context.Background(), names) {
+		results++
+	}
+	if results != len(names) {
+		t.Errorf("Expected %d results, got %d", len(names), results)
+	}
+	if fake.maxRunning != 2 {
+		t.Errorf("Expected 2 names ingested at once, got %d", fake.maxRunning)
+	}
+}
+
+func TestIngestCompanies_SharedRate(t *testing.T) {
+	// 50ms apart, across the ingests running at once
+	useIngestConfig(t, "4", "1200")
+	fake := &fakeIngest{}
+	service := &ingestService{limiter: &helpers.RateLimiter{}, ingest: fake.ingest}
+
+	var wg sync.WaitGroup
+	for _, names := range [][]string{{"Infosys", "HDFC Bank"}, {"Reliance", "TCS"}} {
+		wg.Add(1)
+		go func(names []string) {
+			defer wg.Done()
+			for range service.IngestCompanies(context.Background(), names) {
+			}
+		}(names)
+	}
+	wg.Wait()
+
+	if len(fake.started) != 4 {
+		t.Fatalf("Expected 4 names started, got %d", len(fake.started))
+	}
+	if spread := fake.started[3].Sub(fake.started[0]); spread < 140*time.Millisecond {
+		t.Errorf("Expected the 4 names started 50ms apart, got them within %v", spread)
+	}
+}
+
+func TestIngestCompanies_Cancel(t *testing.T) {
+	// A minute apart, the second name waits for its turn
+	useIngestConfig(t, "1", "1")
+	fake := &fakeIngest{}
+	service := &ingestService{limiter: &helpers.RateLimiter{}, ingest: fake.ingest}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := service.IngestCompanies(ctx, []string{"Infosys", "HDFC Bank", "Reliance"})
+	if first := <-results; first.Name != "Infosys" {
+		t.Errorf("Expected Infosys first, got %v", first)
+	}
+	cancel()
+
+	done := make(chan int)
+	go func() {
+		rest := 0
+		for range results {
+			rest++
+		}
+		done <- rest
+	}()
+	select {
+	case rest := <-done:
+		if rest != 0 {
+			t.Errorf("Expected no further name started, got %d", rest)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the results closed once the ingest is cancelled")
+	}
+}
+
+func TestIngestCompany_UploadPipeline(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("stored company", func(mt *mtest.T) {
+		useMockMongo(mt)
+		useUploadFakes(mt, &fakeNameMap{}, &fakeUploads{})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "name", Value: "Infosys Ltd"},
+				{Key: "url", Value: "https://www.screener.in/company/INFY/consolidated/"},
+				{Key: "marketCap", Value: "6,00,000"},
+				{Key: "score", Value: 2.5},
+				{Key: "lastScraped", Value: primitive.NewDateTimeFromTime(time.Now())},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		result := ingestCompany(context.Background(), "Infosys Limited")
+		if result.Status != IngestStored || result.Company != "Infosys Ltd" || result.StockRate == nil {
+			t.Errorf("Expected Infosys Ltd stored with its rating, got %+v", result)
+		}
+
+		// The name is matched by the text search of an upload, then its scores are written
+		if find := mt.GetStartedEvent(); find == nil || find.CommandName != "find" {
+			t.Fatalf("Expected the text search, got %v", find)
+		} else if _, err := find.Command.LookupErr("filter", "$text"); err != nil {
+			t.Errorf("Expected a text search, got %v", find.Command)
+		}
+		if update := mt.GetStartedEvent(); update == nil || update.CommandName != "update" {
+			t.Errorf("Expected the scores to be written, got %v", update)
+		}
+	})
+
+	mt.Run("derivative", func(mt *mtest.T) {
+		useMockMongo(mt)
+		useUploadFakes(mt, &fakeNameMap{}, &fakeUploads{})
+
+		result := ingestCompany(context.Background(), "NIFTY 26SEP2024 FUT")
+		if result.Status != IngestFailed || result.Reason != helpers.IngestNotEquity {
+			t.Errorf("Expected the future to be refused, got %+v", result)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			t.Errorf("Expected no lookup, got %v", event.CommandName)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	mongo_client "stockbackend/clients/mongo"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type NameMapServiceI interface {
+	Import(ctx context.Context, entries []types.NameMapEntry) (inserted int, updated int, err error)
+	List(ctx context.Context) ([]types.NameMapEntry, error)
+	Lookup(ctx context.Context, isin, name string) (*types.NameMapEntry, error)
+	ReplaceURL(ctx context.Context, from, to string) (int, error)
+}
+
+type nameMapService struct {
+	indexOnce helpers.SucceedOnce
+}
+
+var NameMapService NameMapServiceI = &nameMapService{}
+
+// nameMapCollection holds the imported name map, one entry per normalized name (NAME_MAP_COLLECTION,
+// default "namemap")
+func nameMapCollection() *mongo.Collection {
+	cfg := config.Get()
+	return collections.Collection(mongo_client.Client, cfg.Database, cfg.NameMapCollection)
+}
+
+// ensureIndexes indexes the ISINs holdings are looked up by, the names are the _id
+func (ns *nameMapService) ensureIndexes(ctx context.Context) {
+	err := ns.indexOnce.Do(func() error {
+		_, err := nameMapCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: primitive.D{{Key: "isin", Value: 1}},
+		})
+		return err
+	})
+	if err != nil {
+		zap.L().Error("Error creating name map index", zap.Error(err))
+	}
+}
+
+// Import upserts the entries of the name map by their normalized name, returning how many were new
+// and how many replaced an existing entry. The entries are validated with helpers.NormalizeNameMap,
+// a single invalid entry fails the import with helpers.ErrInvalidNameMap.
+func (ns *nameMapService) Import(ctx context.Context, entries []types.NameMapEntry) (int, int, error) {
+	entries, err := helpers.NormalizeNameMap(entries, config.Get().CompanyURL, time.Now().UTC())
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(entries) == 0 {
+		return 0, 0, nil
+	}
+	ns.ensureIndexes(ctx)
+
+	models := make([]mongo.WriteModel, len(entries))
+	for i, entry := range entries {
+		models[i] = mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": entry.Key}).SetReplacement(entry).SetUpsert(true)
+	}
+	result, err := nameMapCollection().BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, 0, fmt.Errorf("error importing name map: %w", err)
+	}
+	return int(result.UpsertedCount), int(result.MatchedCount), nil
+}
+
+// List returns the whole name map sorted by name
+func (ns *nameMapService) List(ctx context.Context) ([]types.NameMapEntry, error) {
+	cursor, err := nameMapCollection().Find(ctx, bson.M{}, options.Find().SetSort(primitive.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error listing name map: %w", err)
+	}
+	entries := []types.NameMapEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("error decoding name map: %w", err)
+	}
+	return entries, nil
+}
+
+// Lookup returns the name map entry of a holding, by ISIN first then by name, nil when the map has none
+func (ns *nameMapService) Lookup(ctx context.Context, isin, name string) (*types.NameMapEntry, error) {
+	cursor, err := nameMapCollection().Find(ctx, helpers.NameMapLookupFilter(isin, name))
+	if err != nil {
+		return nil, fmt.Errorf("error looking up name map: %w", err)
+	}
+	var entries []types.NameMapEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("error decoding name map: %w", err)
+	}
+	return helpers.PickNameMapEntry(entries, isin, name), nil
+}
+
+// ReplaceURL points the entries mapped to the page from at the page to, e.g. the company a merged
+// away duplicate was merged into, returning how many were changed
+func (ns *nameMapService) ReplaceURL(ctx context.Context, from, to string) (int, error) {
+	result, err := nameMapCollection().UpdateMany(ctx, bson.M{"url": from}, bson.M{"$set": bson.M{"url": to, "updatedAt": time.Now().UTC()}})
+	if err != nil {
+		return 0, fmt.Errorf("error updating name map: %w", err)
+	}
+	return int(result.ModifiedCount), nil
+}
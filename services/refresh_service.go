@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stockbackend/clients/http_client"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type RefreshServiceI interface {
+	RefreshStale(ctx context.Context) (int, error)
+}
+
+type refreshService struct {
+	indexOnce helpers.SucceedOnce
+}
+
+var RefreshService RefreshServiceI = &refreshService{}
+
+func (rs *refreshService) ensureIndexes(ctx context.Context) {
+	err := rs.indexOnce.Do(func() error {
+		_, err := companiesCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: primitive.D{{Key: "lastScraped", Value: 1}},
+		})
+		return err
+	})
+	if err != nil {
+		zap.L().Error("Error creating lastScraped index", zap.Error(err))
+	}
+}
+
+// RefreshStale scrapes again a batch of the companies older than REFRESH_STALE_DAYS, the oldest
+// first, at most REFRESH_RATE_PER_MINUTE of them per minute. Each is scraped from its stored page and
+// updated by its _id. A failed refresh is retried after REFRESH_INTERVAL_MINUTES, twice as long after
+// every further failure up to REFRESH_STALE_DAYS, a page not found or with a changed layout after
+// REFRESH_STALE_DAYS (see refreshBackoff). It returns how many were refreshed.
+func (rs *refreshService) RefreshStale(ctx context.Context) (int, error) {
+	cfg := config.Get()
+	rs.ensureIndexes(ctx)
+
+	findOptions := options.Find().
+		SetProjection(bson.M{"name": 1, "url": 1, "refreshFailures": 1}).
+		SetSort(primitive.D{{Key: "lastScraped", Value: 1}}).
+		SetLimit(cfg.RefreshBatchSize)
+
+	now := time.Now()
+	cursor, err := companiesCollection().Find(ctx, helpers.StaleCompanyFilter(now.Add(-cfg.RefreshStaleAfter), now), findOptions)
+	if err != nil {
+		return 0, fmt.Errorf("error finding stale companies: %w", err)
+	}
+	var stale []bson.M
+	if err := cursor.All(ctx, &stale); err != nil {
+		return 0, fmt.Errorf("error decoding stale companies: %w", err)
+	}
+
+	limiter := time.NewTicker(time.Minute / time.Duration(cfg.RefreshRatePerMinute))
+	defer limiter.Stop()
+
+	backoff := helpers.Backoff{Initial: cfg.RefreshInterval, Max: cfg.RefreshStaleAfter}
+	refreshed := 0
+	for i, company := range stale {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return refreshed, ctx.Err()
+			case <-limiter.C:
+			}
+		}
+		name, _ := company["name"].(string)
+		if _, err := CompanyService.ScrapeStored(ctx, company); err != nil {
+			zap.L().Error("Error refreshing company", zap.String("company", name), zap.Error(err))
+			rs.recordFailure(ctx, company, refreshBackoff(backoff, err))
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// refreshBackoff is the backoff of a failed refresh: a page that is gone or no longer parses won't be
+// back by the next interval, it waits the longest (REFRESH_STALE_DAYS) at once
+func refreshBackoff(backoff helpers.Backoff, err error) helpers.Backoff {
+	if errors.Is(err, http_client.ErrNotFound) || errors.Is(err, http_client.ErrLayoutChanged) {
+		backoff.Initial = backoff.Max
+	}
+	return backoff
+}
+
+// recordFailure pushes the next refresh of a company back, see helpers.RefreshFailureUpdate
+func (rs *refreshService) recordFailure(ctx context.Context, company bson.M, backoff helpers.Backoff) {
+	unlock := companyLocks.Lock(helpers.StoredCompanyLockKey(company))
+	defer unlock()
+	update := helpers.RefreshFailureUpdate(company, backoff, time.Now())
+	if _, err := companiesCollection().UpdateOne(ctx, bson.M{"_id": company["_id"]}, update); err != nil {
+		zap.L().Error("Error recording failed refresh", zap.Any("id", company["_id"]), zap.Error(err))
+	}
+}
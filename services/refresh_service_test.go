@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stockbackend/clients/http_client"
+	"stockbackend/utils/helpers"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestRefreshStale(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("stored page and backoff", func(mt *mtest.T) {
+		useMockMongo(mt)
+		useConfig(mt, map[string]string{"REFRESH_RATE_PER_MINUTE": "60000", "REFRESH_INTERVAL_MINUTES": "60"})
+		// Searching would find another company, the stored pages are scraped instead
+		useSource(mt, &fakeSource{url: "https://www.screener.in/company/OTHER/", failing: "https://www.screener.in/company/GONE/"})
+		refreshedID, failedID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: refreshedID}, {Key: "name", Value: "63 Moons Tech."}, {Key: "url", Value: "https://www.screener.in/company/63MOONS/"}},
+				bson.D{{Key: "_id", Value: failedID}, {Key: "name", Value: "Gone Ltd"}, {Key: "url", Value: "https://www.screener.in/company/GONE/"}, {Key: "refreshFailures", Value: int32(1)}},
+			),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "stocks.companies", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: refreshedID},
+				{Key: "name", Value: "63 Moons Tech."},
+				{Key: "url", Value: "https://www.screener.in/company/63MOONS/"},
+				{Key: "marketCap", Value: "1,889"},
+				{Key: "stockPE", Value: "11.5"},
+				{Key: "roce", Value: "6.70"},
+			}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		start := time.Now()
+		refreshed, err := (&refreshService{}).RefreshStale(context.Background())
+		if err != nil || refreshed != 1 {
+			t.Fatalf("Expected 1 company to be refreshed, got %d (%v)", refreshed, err)
+		}
+
+		mt.GetStartedEvent()
+		find := mt.GetStartedEvent()
+		if _, err := find.Command.LookupErr("filter", "$and"); err != nil {
+			t.Errorf("Expected the companies backing off to be left out, got %v", find.Command)
+		}
+
+		update := mt.GetStartedEvent()
+		if update == nil || update.CommandName != "update" {
+			t.Fatalf("Expected the refreshed company to be updated, got %v", update)
+		}
+		if id := update.Command.Lookup("updates", "0", "q", "_id").ObjectID(); id != refreshedID {
+			t.Errorf("Expected the company to be updated by its _id, got %v", update.Command)
+		}
+		if upsert, _ := update.Command.Lookup("updates", "0", "upsert").BooleanOK(); upsert {
+			t.Errorf("Expected no upsert by _id, got %v", update.Command)
+		}
+		if url := update.Command.Lookup("updates", "0", "u", "$set", "url").StringValue(); url != "https://www.screener.in/company/63MOONS/" {
+			t.Errorf("Expected the stored page to be scraped, got %s", url)
+		}
+
+		// The refreshed fundamentals are scored again
+		if find := mt.GetStartedEvent(); find == nil || find.CommandName != "find" {
+			t.Fatalf("Expected the refreshed company to be read back, got %v", find)
+		}
+		scores := mt.GetStartedEvent()
+		if scores == nil || scores.CommandName != "update" {
+			t.Fatalf("Expected the scores to be updated, got %v", scores)
+		}
+		set := scores.Command.Lookup("updates", "0", "u", "$set").Document()
+		if id := scores.Command.Lookup("updates", "0", "q", "_id").ObjectID(); id != refreshedID {
+			t.Errorf("Expected the scores to be updated by _id, got %v", scores.Command)
+		}
+		if _, err := set.LookupErr("stockRate"); err != nil {
+			t.Errorf("Expected the stock rate to be recomputed, got %v", set)
+		}
+		if _, err := set.LookupErr("lastScored"); err != nil {
+			t.Errorf("Expected the scores to be stamped, got %v", set)
+		}
+
+		failure := mt.GetStartedEvent()
+		if failure == nil || failure.CommandName != "update" {
+			t.Fatalf("Expected the failed refresh to be recorded, got %v", failure)
+		}
+		if id := failure.Command.Lookup("updates", "0", "q", "_id").ObjectID(); id != failedID {
+			t.Errorf("Expected the failure to be recorded by _id, got %v", failure.Command)
+		}
+		// The second failure in a row waits twice REFRESH_INTERVAL_MINUTES
+		next := failure.Command.Lookup("updates", "0", "u", "$set", "nextRefreshAt").Time()
+		if wait := next.Sub(start); wait < 2*time.Hour || wait > 2*time.Hour+time.Minute {
+			t.Errorf("Expected the next refresh in 2h, got %v", wait)
+		}
+	})
+}
+
+func TestRefreshBackoff(t *testing.T) {
+	backoff := helpers.Backoff{Initial: time.Hour, Max: 7 * 24 * time.Hour}
+	tests := []struct {
+		name    string
+		err     error
+		initial time.Duration
+	}{
+		{"rate limited", fmt.Errorf("error fetching company data: %w", http_client.ErrRateLimited), time.Hour},
+		{"transient", fmt.Errorf("error fetching company data: %w", http_client.ErrTransient), time.Hour},
+		{"unclassified", errors.New("write failed"), time.Hour},
+		{"not found", fmt.Errorf("error fetching company data: %w", http_client.ErrNotFound), 7 * 24 * time.Hour},
+		{"layout changed", fmt.Errorf("error fetching company data: %w", http_client.ErrLayoutChanged), 7 * 24 * time.Hour},
+	}
+
+	for _, test := range tests {
+		if got := refreshBackoff(backoff, test.err); got.Initial != test.initial || got.Max != backoff.Max {
+			t.Errorf("%s: expected to wait %v first, got %+v", test.name, test.initial, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type SectorServiceI interface {
+	GetBenchmark(ctx context.Context, sector string) (*helpers.SectorBenchmark, error)
+}
+
+type cachedBenchmark struct {
+	benchmark helpers.SectorBenchmark
+	expiresAt time.Time
+}
+
+type sectorService struct {
+	mu    sync.Mutex
+	cache map[string]cachedBenchmark
+}
+
+var SectorService SectorServiceI = &sectorService{cache: make(map[string]cachedBenchmark)}
+
+// GetBenchmark returns the median fundamentals of the stored companies in a sector,
+// computing them from the collection when the cached copy is missing or expired
+func (ss *sectorService) GetBenchmark(ctx context.Context, sector string) (*helpers.SectorBenchmark, error) {
+	key := helpers.NormalizeSector(sector)
+	if key == "" {
+		return nil, nil
+	}
+
+	ss.mu.Lock()
+	cached, ok := ss.cache[key]
+	ss.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return &cached.benchmark, nil
+	}
+
+	collection := companiesCollection()
+	findOptions := options.Find().SetProjection(bson.M{
+		"stockPE":       1,
+		"marketCap":     1,
+		"dividendYield": 1,
+		"roce":          1,
+	})
+	cursor, err := collection.Find(ctx, bson.M{"sector": key}, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error finding sector documents: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []map[string]interface{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("error decoding sector documents: %w", err)
+	}
+
+	benchmark := helpers.ComputeSectorBenchmark(key, docs)
+	ss.mu.Lock()
+	ss.cache[key] = cachedBenchmark{benchmark: benchmark, expiresAt: time.Now().Add(config.Get().SectorBenchmarkTTL)}
+	ss.mu.Unlock()
+
+	return &benchmark, nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mongo_client "stockbackend/clients/mongo"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/helpers"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrUploadNotFound = errors.New("upload not found")
+
+type UploadServiceI interface {
+	SaveUpload(ctx context.Context, record types.UploadRecord) error
+	GetUpload(ctx context.Context, id string) (*types.UploadRecord, error)
+	GetUnmatched(ctx context.Context, id string) ([]types.UnmatchedInstrument, error)
+	DiffUploads(ctx context.Context, fromID, toID string, thresholds helpers.DiffThresholds) (*types.HoldingsDiff, error)
+	RenameMatches(ctx context.Context, from, to string) (int, error)
+	GetBacklog(ctx context.Context, id string) (*types.UploadBacklog, error)
+	FinishBacklog(ctx context.Context, id string, holdings []map[string]interface{}, snapshots []types.HoldingSnapshot, unmatched []types.UnmatchedInstrument) error
+}
+
+type uploadService struct{}
+
+var UploadService UploadServiceI = &uploadService{}
+
+// uploadsCollection holds one record per processed upload (UPLOADS_COLLECTION, default "uploads")
+func uploadsCollection() *mongo.Collection {
+	cfg := config.Get()
+	return collections.Collection(mongo_client.Client, cfg.Database, cfg.UploadsCollection)
+}
+
+func (us *uploadService) SaveUpload(ctx context.Context, record types.UploadRecord) error {
+	if _, err := uploadsCollection().InsertOne(ctx, record); err != nil {
+		return fmt.Errorf("error saving upload: %w", err)
+	}
+	return nil
+}
+
+func (us *uploadService) GetUpload(ctx context.Context, id string) (*types.UploadRecord, error) {
+	var record types.UploadRecord
+	err := uploadsCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding upload: %w", err)
+	}
+	return &record, nil
+}
+
+// GetUnmatched returns the holdings of a stored upload whose company couldn't be matched, uploads
+// recorded before they were kept have none
+func (us *uploadService) GetUnmatched(ctx context.Context, id string) ([]types.UnmatchedInstrument, error) {
+	var record types.UploadRecord
+	err := uploadsCollection().FindOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"unmatched": 1})).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding upload: %w", err)
+	}
+	if record.Unmatched == nil {
+		return []types.UnmatchedInstrument{}, nil
+	}
+	return record.Unmatched, nil
+}
+
+// GetBacklog returns the holdings a stored upload left past its deadline, nil when it left none
+func (us *uploadService) GetBacklog(ctx context.Context, id string) (*types.UploadBacklog, error) {
+	var record types.UploadRecord
+	err := uploadsCollection().FindOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"backlog": 1})).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding upload: %w", err)
+	}
+	return record.Backlog, nil
+}
+
+// FinishBacklog stores the enriched holdings of an upload's backlog in place of its pending rows, and
+// adds their snapshots and unmatched instruments to the upload's own
+func (us *uploadService) FinishBacklog(ctx context.Context, id string, holdings []map[string]interface{}, snapshots []types.HoldingSnapshot, unmatched []types.UnmatchedInstrument) error {
+	update := bson.M{
+		"$set": bson.M{
+			"backlog.status":     helpers.BacklogFinished,
+			"backlog.pending":    []types.BacklogRow{},
+			"backlog.holdings":   holdings,
+			"backlog.finishedAt": time.Now(),
+		},
+		"$push": bson.M{
+			"holdings":  bson.M{"$each": snapshots},
+			"unmatched": bson.M{"$each": unmatched},
+		},
+	}
+	result, err := uploadsCollection().UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("error saving upload backlog: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrUploadNotFound
+	}
+	return nil
+}
+
+// RenameMatches renames the company the unmatched holdings of stored uploads were compared with (their
+// text match and fuzzy candidates), e.g. a merged away duplicate, returning how many uploads changed
+func (us *uploadService) RenameMatches(ctx context.Context, from, to string) (int, error) {
+	filter := bson.M{"$or": []bson.M{
+		{"unmatched.textMatch": from},
+		{"unmatched.candidates.name": from},
+	}}
+	update := bson.M{"$set": bson.M{
+		"unmatched.$[match].textMatch":               to,
+		"unmatched.$[].candidates.$[candidate].name": to,
+	}}
+	arrayFilters := options.ArrayFilters{Filters: []interface{}{
+		bson.M{"match.textMatch": from},
+		bson.M{"candidate.name": from},
+	}}
+	result, err := uploadsCollection().UpdateMany(ctx, filter, update, options.Update().SetArrayFilters(arrayFilters))
+	if err != nil {
+		return 0, fmt.Errorf("error renaming upload matches: %w", err)
+	}
+	return int(result.ModifiedCount), nil
+}
+
+// DiffUploads compares the holdings of two stored uploads
+func (us *uploadService) DiffUploads(ctx context.Context, fromID, toID string, thresholds helpers.DiffThresholds) (*types.HoldingsDiff, error) {
+	from, err := us.GetUpload(ctx, fromID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fromID, err)
+	}
+	to, err := us.GetUpload(ctx, toID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", toID, err)
+	}
+
+	diff := helpers.DiffHoldings(from.Holdings, to.Holdings, thresholds)
+	return &diff, nil
+}
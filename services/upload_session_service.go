@@ -0,0 +1,23 @@
+package services
+
+import (
+	"stockbackend/config"
+	"stockbackend/utils/helpers"
+	"sync"
+)
+
+var (
+	uploadSessionsOnce sync.Once
+	uploadSessions     *helpers.UploadSessionStore
+)
+
+// UploadSessions returns the store assembling the files of resumable uploads, kept in
+// UPLOAD_SESSION_DIR and dropped after UPLOAD_SESSION_TTL_MINUTES without a chunk, at most
+// MAX_UPLOAD_SESSIONS at once
+func UploadSessions() *helpers.UploadSessionStore {
+	uploadSessionsOnce.Do(func() {
+		cfg := config.Get()
+		uploadSessions = helpers.NewUploadSessionStore(cfg.UploadSessionDir, cfg.UploadSessionTTL, cfg.MaxUploadSessions)
+	})
+	return uploadSessions
+}
@@ -1,5 +1,11 @@
 package types
 
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 // Stock represents the data of a stock
 type Stock struct {
 	Name            string
@@ -18,3 +24,286 @@ type Company struct {
 	Name string `json:"name"`
 	URL  string `json:"url"`
 }
+
+// TableRow is a single row of a financial table, kept in page order.
+// Children holds the sub-rows of an expandable "+" row (e.g. Borrowings -> Secured/Unsecured loans)
+type TableRow struct {
+	Label    string     `json:"label" bson:"label"`
+	Values   []string   `json:"values" bson:"values"`
+	Children []TableRow `json:"children,omitempty" bson:"children,omitempty"`
+}
+
+// CompanySummary is the compact view of a stored company used by list endpoints
+type CompanySummary struct {
+	Name             string      `json:"name" bson:"name"`
+	MarketCap        string      `json:"marketCap" bson:"marketCapCategory"`
+	MarketCapValue   *float64    `json:"marketCapValue,omitempty" bson:"marketCapValue,omitempty"`
+	StockRate        float64     `json:"stockRate" bson:"stockRate"`
+	InsufficientData bool        `json:"insufficientData" bson:"insufficientData"`
+	FScore           interface{} `json:"fScore" bson:"fScore"`
+	HighDebt         *bool       `json:"highDebt" bson:"highDebt"`
+	DebtToEquity     *float64    `json:"debtToEquity" bson:"debtToEquity"`
+	Valuation        *Valuation  `json:"valuation,omitempty" bson:"valuation,omitempty"`
+	ZScore           *ZScore     `json:"zScore,omitempty" bson:"zScore,omitempty"`
+	// MarketCapValue for display, see helpers.FormatMarketCap
+	MarketCapFormatted string `json:"marketCapFormatted,omitempty" bson:"-"`
+
+	LastScraped *time.Time `json:"lastScraped,omitempty" bson:"lastScraped,omitempty"`
+	LastScored  *time.Time `json:"lastScored,omitempty" bson:"lastScored,omitempty"`
+}
+
+// PERange is the spread of a company's own yearly PE
+type PERange struct {
+	Min    float64 `json:"min" bson:"min"`
+	Max    float64 `json:"max" bson:"max"`
+	Median float64 `json:"median" bson:"median"`
+}
+
+// Valuation is the verdict on a stock's PE against its peer median and its own history: one of
+// "undervalued", "fairly valued", "overvalued" or "not applicable" (loss making or nothing to
+// compare with). The Reasons explain it.
+type Valuation struct {
+	Verdict      string   `json:"verdict" bson:"verdict"`
+	PE           *float64 `json:"pe" bson:"pe"`
+	PeerMedianPE *float64 `json:"peerMedianPe" bson:"peerMedianPe"`
+	HistoricalPE *PERange `json:"historicalPe" bson:"historicalPe"`
+	Reasons      []string `json:"reasons" bson:"reasons"`
+}
+
+// ZScore is the Altman Z-score of a company and the bankruptcy risk zone it falls in, the value is
+// nil when the zone is "not applicable"
+type ZScore struct {
+	Value *float64 `json:"value" bson:"value"`
+	Zone  string   `json:"zone" bson:"zone"`
+}
+
+// Insight is a pro or con of a company tagged with what it is about, with the number it quotes
+// when it has one (e.g. 0.85 "times" for "Stock is trading at 0.85 times its book value")
+type Insight struct {
+	Text     string   `json:"text" bson:"text"`
+	Category string   `json:"category" bson:"category"`
+	Value    *float64 `json:"value,omitempty" bson:"value,omitempty"`
+	Unit     string   `json:"unit,omitempty" bson:"unit,omitempty"`
+}
+
+// HoldingSnapshot is the part of a streamed holding persisted with its upload
+type HoldingSnapshot struct {
+	Name            string  `json:"name" bson:"name"`
+	ISIN            string  `json:"isin,omitempty" bson:"isin,omitempty"`
+	Classification  string  `json:"classification" bson:"classification"`
+	Quantity        float64 `json:"quantity" bson:"quantity"`
+	MarketValue     float64 `json:"marketValue" bson:"marketValue"`
+	PercentageOfAUM float64 `json:"percentageOfAUM" bson:"percentageOfAUM"`
+}
+
+// FundTag identifies the fund an upload belongs to, AsOfDate is the date of its holdings
+type FundTag struct {
+	FundName string    `json:"fundName,omitempty" bson:"fundName,omitempty"`
+	AMC      string    `json:"amc,omitempty" bson:"amc,omitempty"`
+	AsOfDate time.Time `json:"asOfDate" bson:"asOfDate"`
+}
+
+// FuzzyCandidate is a stored company name resembling an instrument, with its similarity (0 to 1)
+type FuzzyCandidate struct {
+	Name       string  `json:"name" bson:"name"`
+	Similarity float64 `json:"similarity" bson:"similarity"`
+}
+
+// UnmatchedInstrument is a holding of an upload whose company couldn't be found or scraped, with
+// the stored companies that came closest so the name map can be corrected
+type UnmatchedInstrument struct {
+	// Name as it appears in the sheet, MappedName when the name map replaced it
+	Name       string `json:"name" bson:"name"`
+	MappedName string `json:"mappedName,omitempty" bson:"mappedName,omitempty"`
+	ISIN       string `json:"isin,omitempty" bson:"isin,omitempty"`
+	File       string `json:"file" bson:"file"`
+	Sheet      string `json:"sheet" bson:"sheet"`
+	Reason     string `json:"reason" bson:"reason"`
+	// Best result of the text search and its score, a match needs a score of at least 1
+	TextMatch  string           `json:"textMatch,omitempty" bson:"textMatch,omitempty"`
+	TextScore  float64          `json:"textScore" bson:"textScore"`
+	Candidates []FuzzyCandidate `json:"candidates" bson:"candidates"`
+}
+
+// NameMapEntry maps the name a company is listed under in fund sheets onto its ISIN and screener
+// page, it resolves holdings before the text search (see POST /api/namemap/import)
+type NameMapEntry struct {
+	// Key is the normalized name the entry is looked up by, see helpers.NameMapKey
+	Key       string    `json:"-" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	ISIN      string    `json:"isin,omitempty" bson:"isin,omitempty"`
+	URL       string    `json:"url" bson:"url"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// UploadRecord is a processed upload and the holdings it contained
+type UploadRecord struct {
+	ID        string            `json:"id" bson:"_id"`
+	CreatedAt time.Time         `json:"createdAt" bson:"createdAt"`
+	Files     []string          `json:"files" bson:"files"`
+	Holdings  []HoldingSnapshot `json:"holdings" bson:"holdings"`
+	// Holdings whose company couldn't be matched, see GET /api/uploads/:id/unmatched
+	Unmatched []UnmatchedInstrument `json:"unmatched" bson:"unmatched"`
+	// Holdings left past the upload deadline, see GET /api/uploads/:id/backlog
+	Backlog *UploadBacklog `json:"backlog,omitempty" bson:"backlog,omitempty"`
+
+	FundTag `bson:",inline"`
+}
+
+// BacklogRow is a holding an upload left past its deadline, with the file and sheet it was read from
+type BacklogRow struct {
+	File    string                 `json:"file" bson:"file"`
+	Sheet   string                 `json:"sheet" bson:"sheet"`
+	Holding map[string]interface{} `json:"holding" bson:"holding"`
+}
+
+// UploadBacklog is the part of an upload left past its deadline (UPLOAD_DEADLINE_SECONDS). Its pending
+// rows are enriched in the background and moved to Holdings, like the entries of the upload's stream.
+type UploadBacklog struct {
+	Status     string                   `json:"status" bson:"status"`
+	Pending    []BacklogRow             `json:"pending" bson:"pending"`
+	Holdings   []map[string]interface{} `json:"holdings" bson:"holdings"`
+	FinishedAt *time.Time               `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
+}
+
+// HoldingChange is a holding present in both uploads whose position moved beyond the threshold
+type HoldingChange struct {
+	Name                 string  `json:"name"`
+	ISIN                 string  `json:"isin,omitempty"`
+	PreviousQuantity     float64 `json:"previousQuantity"`
+	Quantity             float64 `json:"quantity"`
+	QuantityChangePct    float64 `json:"quantityChangePct"`
+	PreviousPercentOfAUM float64 `json:"previousPercentageOfAUM"`
+	PercentageOfAUM      float64 `json:"percentageOfAUM"`
+}
+
+// HoldingsDiff is what changed between two uploads of the same fund
+type HoldingsDiff struct {
+	Added   []HoldingSnapshot `json:"added"`
+	Removed []HoldingSnapshot `json:"removed"`
+	Changed []HoldingChange   `json:"changed"`
+}
+
+// CompanyIdentity names a stored company
+type CompanyIdentity struct {
+	Name string `json:"name" bson:"name"`
+	ISIN string `json:"isin,omitempty" bson:"isin,omitempty"`
+}
+
+// DuplicateCompanies is a pair of stored companies that likely are the same company, Reason is
+// "isin" when they share an ISIN and "name" when their names are similar
+type DuplicateCompanies struct {
+	Companies  [2]CompanyIdentity `json:"companies"`
+	Reason     string             `json:"reason"`
+	Similarity float64            `json:"similarity"`
+}
+
+// IngestResult is the outcome of ingesting one company name, Company is the stored name it resolved
+// to. Failed names carry the Reason, one of an unresolved holding (see helpers.MarkUnresolved) or an ingest's.
+type IngestResult struct {
+	Name      string      `json:"name"`
+	Status    string      `json:"status"`
+	Company   string      `json:"company,omitempty"`
+	StockRate interface{} `json:"stockRate,omitempty"`
+	FScore    interface{} `json:"fScore,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
+// PreviewColumn is the sheet column a standard holding key was mapped onto, Index is 0-based
+type PreviewColumn struct {
+	Column string `json:"column"`
+	Index  int    `json:"index"`
+	Header string `json:"header"`
+}
+
+// SheetPreview is how the parser reads a sheet: HeaderRow is the 1-based row of the detected header
+// (0 when none was found), Holdings the number of holdings extracted and Rows the first of them
+type SheetPreview struct {
+	Sheet     string                   `json:"sheet"`
+	HeaderRow int                      `json:"headerRow"`
+	HeaderMap map[string]PreviewColumn `json:"headerMap"`
+	Unmapped  []string                 `json:"unmapped"`
+	Holdings  int                      `json:"holdings"`
+	Rows      []map[string]interface{} `json:"rows"`
+	Error     string                   `json:"error,omitempty"`
+}
+
+// CompanyDocument is a stored company document, typed for the code reading it. The scraper and the
+// scoring still pass companies around as maps, see helpers.CompanyDocumentFromMap and
+// helpers.CompanyDocumentMap. Sections whose stored shape differs between documents (ordered rows
+// or the label keyed maps of older documents) are left untyped, and fields without a typed
+// counterpart are kept in Extra so a document converts back without losing them.
+type CompanyDocument struct {
+	ID     primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name   string             `json:"name" bson:"name"`
+	ISIN   string             `json:"isin,omitempty" bson:"isin,omitempty"`
+	URL    string             `json:"url,omitempty" bson:"url,omitempty"`
+	Sector string             `json:"sector,omitempty" bson:"sector,omitempty"`
+	Funds  []string           `json:"funds,omitempty" bson:"funds,omitempty"`
+
+	// Top ratios as scraped, e.g. "14,78,954" (in crore) or "30.8"
+	MarketCap     string   `json:"marketCap,omitempty" bson:"marketCap,omitempty"`
+	CurrentPrice  string   `json:"currentPrice,omitempty" bson:"currentPrice,omitempty"`
+	HighLow       string   `json:"highLow,omitempty" bson:"highLow,omitempty"`
+	StockPE       string   `json:"stockPE,omitempty" bson:"stockPE,omitempty"`
+	BookValue     string   `json:"bookValue,omitempty" bson:"bookValue,omitempty"`
+	DividendYield string   `json:"dividendYield,omitempty" bson:"dividendYield,omitempty"`
+	ROCE          string   `json:"roce,omitempty" bson:"roce,omitempty"`
+	ROE           string   `json:"roe,omitempty" bson:"roe,omitempty"`
+	FaceValue     string   `json:"faceValue,omitempty" bson:"faceValue,omitempty"`
+	Pros          []string `json:"pros,omitempty" bson:"pros,omitempty"`
+	Cons          []string `json:"cons,omitempty" bson:"cons,omitempty"`
+	// The pros and cons classified, see helpers.ClassifyInsights
+	ProsInsights []Insight `json:"prosInsights,omitempty" bson:"prosInsights,omitempty"`
+	ConsInsights []Insight `json:"consInsights,omitempty" bson:"consInsights,omitempty"`
+
+	// Metric label to its quarters, each a one entry map of the quarter to its value
+	QuarterlyResults    map[string][]map[string]string `json:"quarterlyResults,omitempty" bson:"quarterlyResults,omitempty"`
+	ProfitLoss          interface{}                    `json:"profitLoss,omitempty" bson:"profitLoss,omitempty"`
+	BalanceSheet        interface{}                    `json:"balanceSheet,omitempty" bson:"balanceSheet,omitempty"`
+	CashFlows           interface{}                    `json:"cashFlows,omitempty" bson:"cashFlows,omitempty"`
+	Ratios              interface{}                    `json:"ratios,omitempty" bson:"ratios,omitempty"`
+	ProfitLossHeaders   []string                       `json:"profitLossHeaders,omitempty" bson:"profitLossHeaders,omitempty"`
+	BalanceSheetHeaders []string                       `json:"balanceSheetHeaders,omitempty" bson:"balanceSheetHeaders,omitempty"`
+	CashFlowsHeaders    []string                       `json:"cashFlowsHeaders,omitempty" bson:"cashFlowsHeaders,omitempty"`
+	RatiosHeaders       []string                       `json:"ratiosHeaders,omitempty" bson:"ratiosHeaders,omitempty"`
+	StatementBasis      string                         `json:"statementBasis,omitempty" bson:"statementBasis,omitempty"`
+	Standalone          interface{}                    `json:"standalone,omitempty" bson:"standalone,omitempty"`
+	RatioSeries         interface{}                    `json:"ratioSeries,omitempty" bson:"ratioSeries,omitempty"`
+	GrowthSummary       map[string]map[string]float64  `json:"growthSummary,omitempty" bson:"growthSummary,omitempty"`
+	Deltas              interface{}                    `json:"deltas,omitempty" bson:"deltas,omitempty"`
+	ShareholdingPattern interface{}                    `json:"shareholdingPattern,omitempty" bson:"shareholdingPattern,omitempty"`
+	ShareholdingTrend   interface{}                    `json:"shareholdingTrend,omitempty" bson:"shareholdingTrend,omitempty"`
+
+	// Rows of the peers API, the median row marked by helpers.PeerMedianMarker
+	Peers            []map[string]string `json:"peers,omitempty" bson:"peers,omitempty"`
+	PeersUnavailable bool                `json:"peersUnavailable,omitempty" bson:"peersUnavailable,omitempty"`
+	PeersTable       interface{}         `json:"peersTable,omitempty" bson:"peersTable,omitempty"`
+	PeerSnapshots    interface{}         `json:"peerSnapshots,omitempty" bson:"peerSnapshots,omitempty"`
+	PeerPercentiles  map[string]float64  `json:"peerPercentiles,omitempty" bson:"peerPercentiles,omitempty"`
+
+	// Scores, nil when they couldn't be computed (or were cleared by STRICT_PARSING)
+	StockRate         *float64   `json:"stockRate,omitempty" bson:"stockRate,omitempty"`
+	StockRateRaw      *float64   `json:"stockRateRaw,omitempty" bson:"stockRateRaw,omitempty"`
+	InsufficientData  *bool      `json:"insufficientData,omitempty" bson:"insufficientData,omitempty"`
+	ScoreReasons      []string   `json:"scoreReasons,omitempty" bson:"scoreReasons,omitempty"`
+	FScore            *int       `json:"fScore,omitempty" bson:"fScore,omitempty"`
+	MarketCapCategory string     `json:"marketCapCategory,omitempty" bson:"marketCapCategory,omitempty"`
+	MarketCapValue    *float64   `json:"marketCapValue,omitempty" bson:"marketCapValue,omitempty"`
+	HighDebt          *bool      `json:"highDebt,omitempty" bson:"highDebt,omitempty"`
+	DebtToEquity      *float64   `json:"debtToEquity,omitempty" bson:"debtToEquity,omitempty"`
+	Valuation         *Valuation `json:"valuation,omitempty" bson:"valuation,omitempty"`
+	ZScore            *ZScore    `json:"zScore,omitempty" bson:"zScore,omitempty"`
+	UnparseableFields []string   `json:"unparseableFields,omitempty" bson:"unparseableFields,omitempty"`
+	// Hash of the scoring config the scores were computed with, see helpers.ScoringConfig
+	ScoringConfig string `json:"scoringConfig,omitempty" bson:"scoringConfig,omitempty"`
+	// Yearly debt to equity of the latest years, see helpers.DebtToEquity
+	DebtToEquityHistory interface{} `json:"debtToEquityHistory,omitempty" bson:"debtToEquityHistory,omitempty"`
+
+	DebugHTML   string     `json:"debugHtml,omitempty" bson:"debugHtml,omitempty"`
+	LastScraped *time.Time `json:"lastScraped,omitempty" bson:"lastScraped,omitempty"`
+	LastScored  *time.Time `json:"lastScored,omitempty" bson:"lastScored,omitempty"`
+	LastUpdated *time.Time `json:"lastUpdated,omitempty" bson:"lastUpdated,omitempty"`
+
+	Extra map[string]interface{} `json:"-" bson:",inline"`
+}
@@ -8,4 +8,56 @@ var (
 		"Samvardhana Motherson International Limited": "Samvardh. Mothe.",
 		"Coromandel International Limited":            "Coromandel Inter",
 	}
+
+	// ScrapedFields are the company fundamentals written from a scrape, invalidating a
+	// company clears them (and the scores derived from them) while keeping its identity
+	ScrapedFields = []string{
+		"marketCap",
+		"currentPrice",
+		"highLow",
+		"stockPE",
+		"bookValue",
+		"dividendYield",
+		"roce",
+		"roe",
+		"faceValue",
+		"pros",
+		"cons",
+		"prosInsights",
+		"consInsights",
+		"quarterlyResults",
+		"profitLoss",
+		"balanceSheet",
+		"cashFlows",
+		"ratios",
+		"profitLossHeaders",
+		"balanceSheetHeaders",
+		"cashFlowsHeaders",
+		"ratiosHeaders",
+		"deltas",
+		"ratioSeries",
+		"growthSummary",
+		"statementBasis",
+		"standalone",
+		"shareholdingPattern",
+		"peersTable",
+		"peers",
+		"peerPercentiles",
+		"debugHtml",
+		"shareholdingTrend",
+		"lastScraped",
+		"lastScored",
+		"stockRate",
+		"stockRateRaw",
+		"insufficientData",
+		"highDebt",
+		"debtToEquity",
+		"debtToEquityHistory",
+		"valuation",
+		"zScore",
+		"scoreReasons",
+		"fScore",
+		"marketCapCategory",
+		"marketCapValue",
+	}
 )
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ttmSections are the tables assumed to end with a TTM column when a document has no stored
+// headers (scraped before they were kept): screener adds one to the profit & loss table only
+var ttmSections = map[string]bool{"profitLoss": true}
+
+// hasTrailingTTM reports whether the last column of a table section is the trailing twelve months
+func hasTrailingTTM(stock map[string]interface{}, section string) bool {
+	headers, ok := toArray(stock[section+"Headers"])
+	if !ok || len(headers) == 0 {
+		return ttmSections[section]
+	}
+	last, _ := headers[len(headers)-1].(string)
+	return strings.EqualFold(strings.TrimSpace(last), "TTM")
+}
+
+// getAnnualArrayField returns the yearly values of a table row without the TTM column, so the
+// last value is the latest full year and the one before it the previous year whatever the table shape
+func getAnnualArrayField(stock map[string]interface{}, section string, path ...string) (primitive.A, error) {
+	values, err := getNestedArrayField(stock, append([]string{section}, path...)...)
+	if err != nil {
+		return values, err
+	}
+	if len(values) > 0 && hasTrailingTTM(stock, section) {
+		values = values[:len(values)-1]
+	}
+	return values, nil
+}
@@ -0,0 +1,108 @@
+package helpers
+
+import (
+	"stockbackend/types"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func row(label string, values ...string) types.TableRow {
+	return types.TableRow{Label: label, Values: values}
+}
+
+// fScoreFixture builds a stock scoring 9 on its last two full years (Mar 2023 and Mar 2024). With
+// ttm set, profit & loss ends with a TTM column whose values would break every comparison using it.
+func fScoreFixture(profitLossTTM bool, balanceSheetTTM bool) map[string]interface{} {
+	profitLoss := []types.TableRow{
+		row("Sales\u00a0+", "100", "200"),
+		row("OPM %", "10", "12"),
+		row("Net Profit\u00a0+", "10", "20"),
+	}
+	profitLossHeaders := []string{"Mar 2023", "Mar 2024"}
+	if profitLossTTM {
+		for i, ttm := range []string{"5", "1", "999"} {
+			profitLoss[i].Values = append(profitLoss[i].Values, ttm)
+		}
+		profitLossHeaders = append(profitLossHeaders, "TTM")
+	}
+
+	balanceSheet := []types.TableRow{
+		row("Equity Capital", "10", "10"),
+		row("Borrowings\u00a0+", "50", "40"),
+		row("Other Liabilities\u00a0+", "20", "20"),
+		row("Other Assets\u00a0+", "30", "40"),
+		row("Total Assets", "100", "110"),
+	}
+	balanceSheetHeaders := []string{"Mar 2023", "Mar 2024"}
+	if balanceSheetTTM {
+		for i, ttm := range []string{"99", "900", "1", "1", "1"} {
+			balanceSheet[i].Values = append(balanceSheet[i].Values, ttm)
+		}
+		balanceSheetHeaders = append(balanceSheetHeaders, "TTM")
+	}
+
+	return map[string]interface{}{
+		"profitLoss":          profitLoss,
+		"profitLossHeaders":   profitLossHeaders,
+		"balanceSheet":        balanceSheet,
+		"balanceSheetHeaders": balanceSheetHeaders,
+		"cashFlows":           []types.TableRow{row("Cash from Operating Activity\u00a0+", "15", "30")},
+		"cashFlowsHeaders":    []string{"Mar 2023", "Mar 2024"},
+	}
+}
+
+func TestGenerateFScore_TableShapes(t *testing.T) {
+	legacy := fScoreFixture(true, false)
+	delete(legacy, "profitLossHeaders")
+	delete(legacy, "balanceSheetHeaders")
+	delete(legacy, "cashFlowsHeaders")
+
+	cases := []struct {
+		name  string
+		stock map[string]interface{}
+	}{
+		{"profit & loss with TTM, balance sheet without", fScoreFixture(true, false)},
+		{"no TTM column", fScoreFixture(false, false)},
+		{"TTM column in both", fScoreFixture(true, true)},
+		{"no stored headers", legacy},
+	}
+	for _, c := range cases {
+		if score := GenerateFScore(c.stock); score != 9 {
+			t.Errorf("%s: expected an F-Score of 9, got %d", c.name, score)
+		}
+	}
+}
+
+func TestGetAnnualArrayField(t *testing.T) {
+	stock := fScoreFixture(true, false)
+
+	netProfit, err := getAnnualArrayField(stock, "profitLoss", "Net Profit +")
+	if err != nil || len(netProfit) != 2 || netProfit[1] != "20" {
+		t.Errorf("Expected the TTM value to be dropped, got %v (%v)", netProfit, err)
+	}
+	totalAssets, err := getAnnualArrayField(stock, "balanceSheet", "Total Assets")
+	if err != nil || len(totalAssets) != 2 || totalAssets[1] != "110" {
+		t.Errorf("Expected every balance sheet year to be kept, got %v (%v)", totalAssets, err)
+	}
+}
+
+func TestParseTableHeaders(t *testing.T) {
+	fixture := `<section id="profit-loss"><div data-result-table><table>
+<thead><tr><th></th><th>Mar 2023</th><th>Mar 2024</th><th>TTM</th></tr></thead>
+<tbody><tr><td class="text">Sales +</td><td>100</td><td>200</td><td>210</td></tr></tbody>
+</table></div></section>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatalf("Error parsing fixture: %v", err)
+	}
+
+	headers := ParseTableHeaders(doc.Find("section#profit-loss"), "div[data-result-table]")
+	if strings.Join(headers, ",") != "Mar 2023,Mar 2024,TTM" {
+		t.Errorf("Expected the year and TTM headers, got %v", headers)
+	}
+	if !hasTrailingTTM(map[string]interface{}{"profitLossHeaders": headers}, "profitLoss") {
+		t.Errorf("Expected the TTM column to be detected")
+	}
+}
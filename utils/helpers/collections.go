@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CollectionCache hands out collection handles resolved once per database and collection name.
+// Handles are safe for concurrent use, so a single one is shared by every request.
+type CollectionCache struct {
+	mu      sync.RWMutex
+	handles map[string]*mongo.Collection
+}
+
+// Collection returns the cached handle of database.name, resolving it on first use
+func (c *CollectionCache) Collection(client *mongo.Client, database string, name string) *mongo.Collection {
+	key := database + "." + name
+
+	c.mu.RLock()
+	handle, ok := c.handles[key]
+	c.mu.RUnlock()
+	if ok {
+		return handle
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if handle, ok := c.handles[key]; ok {
+		return handle
+	}
+	if c.handles == nil {
+		c.handles = map[string]*mongo.Collection{}
+	}
+	handle = client.Database(database).Collection(name)
+	c.handles[key] = handle
+	return handle
+}
@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// unconnectedClient returns a client that is never used for a command, Connect doesn't dial the server
+func unconnectedClient(tb testing.TB) *mongo.Client {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		tb.Fatalf("Error creating client: %v", err)
+	}
+	tb.Cleanup(func() { client.Disconnect(context.Background()) })
+	return client
+}
+
+func TestCollectionCache(t *testing.T) {
+	client := unconnectedClient(t)
+	var cache CollectionCache
+
+	companies := cache.Collection(client, "stocks", "companies")
+	if companies.Name() != "companies" || companies.Database().Name() != "stocks" {
+		t.Errorf("Expected stocks.companies, got %s.%s", companies.Database().Name(), companies.Name())
+	}
+	if uploads := cache.Collection(client, "stocks", "uploads"); uploads == companies {
+		t.Errorf("Expected a separate handle per collection")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if handle := cache.Collection(client, "stocks", "companies"); handle != companies {
+				t.Errorf("Expected the cached handle to be shared")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BenchmarkCollectionPerRow compares resolving the collection for every row with reusing the cached handle
+func BenchmarkCollectionPerRow(b *testing.B) {
+	client := unconnectedClient(b)
+
+	b.Run("resolved", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = client.Database("stocks").Collection("companies")
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		var cache CollectionCache
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = cache.Collection(client, "stocks", "companies")
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"stockbackend/types"
+
+	driverbson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DecodeCompanyDocument decodes a raw stored company document, e.g. of SingleResult.Raw. Its untyped
+// sections decode into the maps the helpers read (see toMap) rather than the driver's primitive.D.
+func DecodeCompanyDocument(raw []byte) (types.CompanyDocument, error) {
+	var document types.CompanyDocument
+	decoder, err := driverbson.NewDecoder(bsonrw.NewBSONDocumentReader(raw))
+	if err != nil {
+		return document, fmt.Errorf("error decoding company document: %w", err)
+	}
+	decoder.DefaultDocumentM()
+	if err := decoder.Decode(&document); err != nil {
+		return document, fmt.Errorf("error decoding company document: %w", err)
+	}
+	return document, nil
+}
+
+// CompanyDocumentFromMap converts a company in the map form passed around by the scraper and the
+// scoring into its typed document, through its BSON encoding so the BSON tags decide the fields
+func CompanyDocumentFromMap(company map[string]interface{}) (types.CompanyDocument, error) {
+	raw, err := driverbson.Marshal(company)
+	if err != nil {
+		return types.CompanyDocument{}, fmt.Errorf("error encoding company: %w", err)
+	}
+	return DecodeCompanyDocument(raw)
+}
+
+// LenientCompanyDocument converts like CompanyDocumentFromMap, but the fields whose stored shape
+// doesn't fit their typed counterpart (e.g. a numeric cell under quarterlyResults, a fractional
+// fScore) are left out instead of failing the whole document. It returns the fields left out.
+func LenientCompanyDocument(company map[string]interface{}) (types.CompanyDocument, []string) {
+	document, err := CompanyDocumentFromMap(company)
+	if err == nil {
+		return document, nil
+	}
+	fitting := make(map[string]interface{}, len(company))
+	var dropped []string
+	for key, value := range company {
+		if _, err := CompanyDocumentFromMap(map[string]interface{}{key: value}); err != nil {
+			dropped = append(dropped, key)
+			continue
+		}
+		fitting[key] = value
+	}
+	sort.Strings(dropped)
+	document, _ = CompanyDocumentFromMap(fitting)
+	return document, dropped
+}
+
+// CompanyDocumentMap converts a typed company document back into the map form, shaped like a
+// document read from Mongo into a bson.M (nested documents as bson.M, arrays as primitive.A)
+func CompanyDocumentMap(document types.CompanyDocument) (bson.M, error) {
+	raw, err := driverbson.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding company document: %w", err)
+	}
+	company := bson.M{}
+	if err := driverbson.Unmarshal(raw, &company); err != nil {
+		return nil, fmt.Errorf("error decoding company: %w", err)
+	}
+	return company, nil
+}
+
+// RateCompanyDocument rates a typed company document like RateStockDetailed rates its map form
+func RateCompanyDocument(document types.CompanyDocument, benchmark *SectorBenchmark) (StockRating, error) {
+	company, err := CompanyDocumentMap(document)
+	if err != nil {
+		return StockRating{}, err
+	}
+	return RateStockDetailed(company, benchmark), nil
+}
+
+// RateCompany rates a company through its typed document, see RateCompanyDocument. A company whose
+// stored shape doesn't fit the document is rated from its map form, every field counting.
+func RateCompany(company map[string]interface{}, benchmark *SectorBenchmark) StockRating {
+	if document, err := CompanyDocumentFromMap(company); err == nil {
+		if rating, err := RateCompanyDocument(document, benchmark); err == nil {
+			return rating
+		}
+	}
+	return RateStockDetailed(company, benchmark)
+}
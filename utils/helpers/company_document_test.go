@@ -0,0 +1,167 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/types"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// storedCompany is a company shaped like a document read from Mongo into a bson.M
+func storedCompany() bson.M {
+	scraped := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	return bson.M{
+		"_id":           primitive.NewObjectIDFromTimestamp(scraped),
+		"name":          "Infosys Ltd",
+		"isin":          "INE009A01021",
+		"sector":        "IT - Software",
+		"marketCap":     "6,48,000",
+		"stockPE":       "24.5",
+		"roce":          "39.9",
+		"pros":          primitive.A{"Company has a good return on equity"},
+		"stockRate":     61.23,
+		"fScore":        int32(7),
+		"highDebt":      false,
+		"scoreReasons":  primitive.A{"PE below peer median: +2.50"},
+		"zScore":        bson.M{"value": 3.42, "zone": "safe"},
+		"lastScraped":   primitive.NewDateTimeFromTime(scraped),
+		"peers":         primitive.A{bson.M{"name": "TCS", "pe": "30.8"}},
+		"growthSummary": bson.M{"salesGrowth": bson.M{"3 Years": 12.0}},
+		"quarterlyResults": bson.M{
+			"Sales +": primitive.A{bson.M{"Mar 2024": "37,923"}, bson.M{"Jun 2024": "39,315"}},
+		},
+		"profitLoss": primitive.A{
+			bson.M{"label": "Sales +", "values": primitive.A{"1,46,767", "1,53,670"}},
+		},
+		// A label keyed statement of an older document
+		"balanceSheet":      bson.M{"Borrowings +": primitive.A{"8,359", "8,000"}},
+		"profitLossHeaders": primitive.A{"Mar 2023", "Mar 2024"},
+		// Fields without a typed counterpart
+		"match": bson.M{"method": "text"},
+		"stale": true,
+	}
+}
+
+func TestCompanyDocumentFromMap(t *testing.T) {
+	document, err := CompanyDocumentFromMap(storedCompany())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if document.Name != "Infosys Ltd" || document.ISIN != "INE009A01021" || document.MarketCap != "6,48,000" {
+		t.Errorf("Unexpected fields: %+v", document)
+	}
+	if document.StockRate == nil || *document.StockRate != 61.23 || document.FScore == nil || *document.FScore != 7 {
+		t.Errorf("Unexpected scores: %v, %v", document.StockRate, document.FScore)
+	}
+	if document.HighDebt == nil || *document.HighDebt || document.InsufficientData != nil {
+		t.Errorf("Expected a known highDebt and an unknown insufficientData, got %v, %v", document.HighDebt, document.InsufficientData)
+	}
+	if document.ZScore == nil || document.ZScore.Zone != "safe" || *document.ZScore.Value != 3.42 {
+		t.Errorf("Unexpected zScore: %+v", document.ZScore)
+	}
+	if document.LastScraped == nil || !document.LastScraped.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected lastScraped: %v", document.LastScraped)
+	}
+	if !reflect.DeepEqual(document.Peers, []map[string]string{{"name": "TCS", "pe": "30.8"}}) {
+		t.Errorf("Unexpected peers: %v", document.Peers)
+	}
+	if document.QuarterlyResults["Sales +"][1]["Jun 2024"] != "39,315" || document.GrowthSummary["salesGrowth"]["3 Years"] != 12 {
+		t.Errorf("Unexpected quarterly results or growth: %v, %v", document.QuarterlyResults, document.GrowthSummary)
+	}
+	if !reflect.DeepEqual(document.Extra, map[string]interface{}{"match": primitive.M{"method": "text"}, "stale": true}) {
+		t.Errorf("Expected the untyped fields in Extra, got %#v", document.Extra)
+	}
+
+	// The untyped statements are still read by the helpers, in either layout
+	if values, ok := metricValues(map[string]interface{}{"profitLoss": document.ProfitLoss}, "profitLoss", "Sales"); !ok || len(values) != 2 {
+		t.Errorf("Expected the ordered rows to be readable, got %v", document.ProfitLoss)
+	}
+	if values, ok := metricValues(map[string]interface{}{"balanceSheet": document.BalanceSheet}, "balanceSheet", "Borrowings"); !ok || len(values) != 2 {
+		t.Errorf("Expected the label keyed rows to be readable, got %v", document.BalanceSheet)
+	}
+}
+
+func TestCompanyDocumentMap_RoundTrip(t *testing.T) {
+	company := storedCompany()
+	document, err := CompanyDocumentFromMap(company)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	back, err := CompanyDocumentMap(document)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(back, company) {
+		t.Errorf("Expected the document to convert back unchanged\nwant %#v\ngot  %#v", company, back)
+	}
+
+	// And the typed document again from the map
+	again, err := CompanyDocumentFromMap(back)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(again, document) {
+		t.Errorf("Expected the same document\nwant %+v\ngot  %+v", document, again)
+	}
+}
+
+func TestRateCompanyDocument(t *testing.T) {
+	company := storedCompany()
+	document, err := CompanyDocumentFromMap(company)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rating, err := RateCompanyDocument(document, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := RateStockDetailed(company, nil); !reflect.DeepEqual(rating, expected) {
+		t.Errorf("Expected the rating of the map form %+v, got %+v", expected, rating)
+	}
+}
+
+func TestCompanyDocument_Empty(t *testing.T) {
+	back, err := CompanyDocumentMap(types.CompanyDocument{Name: "Example Ltd"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Unset fields are left out rather than stored as zero values
+	if !reflect.DeepEqual(back, bson.M{"name": "Example Ltd"}) {
+		t.Errorf("Unexpected map: %#v", back)
+	}
+}
+
+func TestLenientCompanyDocument(t *testing.T) {
+	company := storedCompany()
+	if _, dropped := LenientCompanyDocument(company); dropped != nil {
+		t.Errorf("Expected every field to fit, got %v left out", dropped)
+	}
+
+	// Shapes an older scraper stored
+	company["quarterlyResults"] = bson.M{"Sales +": primitive.A{bson.M{"Mar 2024": 37923.0}}}
+	company["fScore"] = 6.5
+	document, dropped := LenientCompanyDocument(company)
+	if !reflect.DeepEqual(dropped, []string{"fScore", "quarterlyResults"}) {
+		t.Errorf("Expected the drifted fields to be left out, got %v", dropped)
+	}
+	if document.Name != "Infosys Ltd" || document.MarketCap != "6,48,000" || document.QuarterlyResults != nil || document.FScore != nil {
+		t.Errorf("Expected the fields that fit, got %+v", document)
+	}
+}
+
+func TestRateCompany(t *testing.T) {
+	company := storedCompany()
+	if rating, expected := RateCompany(company, nil), RateStockDetailed(company, nil); !reflect.DeepEqual(rating, expected) {
+		t.Errorf("Expected the rating of the map form %+v, got %+v", expected, rating)
+	}
+
+	// A drifted document is still rated with all its fields
+	company["quarterlyResults"] = bson.M{"Sales +": primitive.A{bson.M{"Mar 2024": 37923.0}}}
+	if rating, expected := RateCompany(company, nil), RateStockDetailed(company, nil); !reflect.DeepEqual(rating, expected) {
+		t.Errorf("Expected the rating of the map form %+v, got %+v", expected, rating)
+	}
+}
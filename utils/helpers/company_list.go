@@ -0,0 +1,266 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultCompanyListLimit = 50
+	maxCompanyListLimit     = 200
+)
+
+// companyListSortFields maps the accepted sort params onto stored fields
+var companyListSortFields = map[string]string{
+	"name":      "name",
+	"stockRate": "stockRate",
+	"fScore":    "fScore",
+	"marketCap": "marketCapValue",
+}
+
+// CompanyListQuery holds the filters, sorting and pagination of a company list request
+type CompanyListQuery struct {
+	MarketCap string
+	MinScore  *float64
+	MaxScore  *float64
+	MinFScore *int
+	Sector    string
+	Fund      string
+	SortField string
+	SortDesc  bool
+	Limit     int64
+	Offset    int64
+	// Fields of the summaries returned, see ParseFieldProjection
+	Fields FieldProjection
+	// Bounds on the latest debt to equity, see DebtToEquity
+	MinDebtToEquity *float64
+	MaxDebtToEquity *float64
+}
+
+// companySummaryFields maps the fields of a company summary onto the stored fields they're read from
+var companySummaryFields = map[string]string{
+	"name":           "name",
+	"marketCap":      "marketCapCategory",
+	"marketCapValue": "marketCapValue",
+	// Formatted from the stored number
+	"marketCapFormatted": "marketCapValue",
+	"stockRate":          "stockRate",
+	"insufficientData":   "insufficientData",
+	"fScore":             "fScore",
+	"highDebt":           "highDebt",
+	"debtToEquity":       "debtToEquity",
+	"valuation":          "valuation",
+	"zScore":             "zScore",
+	"lastScraped":        "lastScraped",
+	"lastScored":         "lastScored",
+}
+
+// ParseCompanyListQuery validates the query params of a company list request
+func ParseCompanyListQuery(values url.Values) (CompanyListQuery, error) {
+	fields, err := ParseFieldProjection(values.Get("fields"), nil)
+	if err != nil {
+		return CompanyListQuery{}, err
+	}
+	query := CompanyListQuery{
+		Fields:    fields,
+		MarketCap: strings.TrimSpace(values.Get("marketCap")),
+		Sector:    NormalizeSector(values.Get("sector")),
+		Fund:      strings.TrimSpace(values.Get("fund")),
+		SortField: "stockRate",
+		SortDesc:  true,
+		Limit:     defaultCompanyListLimit,
+	}
+
+	for param, target := range map[string]**float64{
+		"minScore":        &query.MinScore,
+		"maxScore":        &query.MaxScore,
+		"minDebtToEquity": &query.MinDebtToEquity,
+		"maxDebtToEquity": &query.MaxDebtToEquity,
+	} {
+		if raw := values.Get(param); raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s: %q", param, raw)
+			}
+			*target = &value
+		}
+	}
+
+	if raw := values.Get("minFScore"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return query, fmt.Errorf("invalid minFScore: %q", raw)
+		}
+		query.MinFScore = &value
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		desc := strings.HasPrefix(raw, "-")
+		field, ok := companyListSortFields[strings.TrimPrefix(raw, "-")]
+		if !ok {
+			return query, fmt.Errorf("invalid sort: %q", raw)
+		}
+		query.SortField = field
+		query.SortDesc = desc
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || value <= 0 {
+			return query, fmt.Errorf("invalid limit: %q", raw)
+		}
+		query.Limit = min(value, maxCompanyListLimit)
+	}
+
+	if raw := values.Get("offset"); raw != "" {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || value < 0 {
+			return query, fmt.Errorf("invalid offset: %q", raw)
+		}
+		query.Offset = value
+	}
+
+	return query, nil
+}
+
+// Filter builds the Mongo filter for the query
+func (q CompanyListQuery) Filter() bson.M {
+	filter := bson.M{}
+	if q.MarketCap != "" {
+		filter["marketCapCategory"] = q.MarketCap
+	}
+	if q.Sector != "" {
+		filter["sector"] = q.Sector
+	}
+	if q.Fund != "" {
+		filter["funds"] = q.Fund
+	}
+
+	score := bson.M{}
+	if q.MinScore != nil {
+		score["$gte"] = *q.MinScore
+	}
+	if q.MaxScore != nil {
+		score["$lte"] = *q.MaxScore
+	}
+	if len(score) > 0 {
+		filter["stockRate"] = score
+	}
+
+	if q.MinFScore != nil {
+		filter["fScore"] = bson.M{"$gte": *q.MinFScore}
+	}
+
+	// Companies with an unknown (null) debt to equity never match a bound
+	debtToEquity := bson.M{}
+	if q.MinDebtToEquity != nil {
+		debtToEquity["$gte"] = *q.MinDebtToEquity
+	}
+	if q.MaxDebtToEquity != nil {
+		debtToEquity["$lte"] = *q.MaxDebtToEquity
+	}
+	if len(debtToEquity) > 0 {
+		filter["debtToEquity"] = debtToEquity
+	}
+	return filter
+}
+
+// Projection reads the stored fields of the requested summary fields only. The name is always
+// read, an empty projection would return whole documents.
+func (q CompanyListQuery) Projection() bson.M {
+	projection := bson.M{"name": 1}
+	for field, stored := range companySummaryFields {
+		if q.Fields.Allows(field) {
+			projection[stored] = 1
+		}
+	}
+	return projection
+}
+
+// Sort builds the Mongo sort document for the query, name is used as a tiebreaker for stable pages
+func (q CompanyListQuery) Sort() primitive.D {
+	direction := 1
+	if q.SortDesc {
+		direction = -1
+	}
+	sort := primitive.D{{Key: q.SortField, Value: direction}}
+	if q.SortField != "name" {
+		sort = append(sort, primitive.E{Key: "name", Value: 1})
+	}
+	return sort
+}
+
+// CompanyLookupFilter resolves a company by its ISIN or its exact stored name
+func CompanyLookupFilter(key string) bson.M {
+	key = strings.TrimSpace(key)
+	return bson.M{"$or": []bson.M{
+		{"isin": strings.ToUpper(key)},
+		{"name": key},
+	}}
+}
+
+// StaleCompanyFilter matches the companies last scraped before the cutoff, documents
+// without a lastScraped (stored before it was recorded, or invalidated) count as stale. Companies
+// whose last refresh failed are left out until their nextRefreshAt, see RefreshFailureUpdate.
+func StaleCompanyFilter(cutoff time.Time, now time.Time) bson.M {
+	return bson.M{"$and": []bson.M{
+		{"$or": []bson.M{
+			{"lastScraped": bson.M{"$lt": cutoff}},
+			{"lastScraped": bson.M{"$exists": false}},
+		}},
+		{"$or": []bson.M{
+			{"nextRefreshAt": bson.M{"$exists": false}},
+			{"nextRefreshAt": bson.M{"$lte": now}},
+		}},
+	}}
+}
+
+// RefreshFailureUpdate records a failed refresh of the stored company: the attempt time, the count
+// of failures in a row and the time it may be refreshed again, pushed back by the backoff so a
+// company that keeps failing doesn't head every batch of the oldest ones. A successful scrape
+// unsets refreshFailures and nextRefreshAt.
+func RefreshFailureUpdate(doc map[string]interface{}, backoff Backoff, now time.Time) bson.M {
+	failures := 0
+	switch count := doc["refreshFailures"].(type) {
+	case int32:
+		failures = int(count)
+	case int64:
+		failures = int(count)
+	case int:
+		failures = count
+	}
+	return bson.M{
+		"$set": bson.M{
+			"lastRefreshAttempt": now,
+			"nextRefreshAt":      now.Add(backoff.wait(failures + 1)),
+		},
+		"$inc": bson.M{"refreshFailures": 1},
+	}
+}
+
+// HasFundamentals reports whether a stored company still carries scraped data,
+// invalidated companies only keep their identity and have to be scraped again
+func HasFundamentals(doc map[string]interface{}) bool {
+	_, ok := doc["marketCap"]
+	return ok
+}
+
+// UsableStoredData reports whether an upload can use a stored company instead of scraping it: it
+// has fundamentals scraped less than maxAge ago (any age when maxAge is 0). Documents without a
+// lastScraped (stored before it was recorded) count as too old.
+func UsableStoredData(doc map[string]interface{}, maxAge time.Duration, now time.Time) bool {
+	if !HasFundamentals(doc) {
+		return false
+	}
+	if maxAge <= 0 {
+		return true
+	}
+	lastScraped := documentTime(doc["lastScraped"])
+	return !lastScraped.IsZero() && now.Sub(lastScraped) < maxAge
+}
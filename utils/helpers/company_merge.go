@@ -0,0 +1,208 @@
+package helpers
+
+import (
+	"sort"
+	"stockbackend/config"
+	"stockbackend/types"
+	"stockbackend/utils/constants"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// mergeSkippedFields are not merged field by field: the winner keeps its _id and name, the
+// timestamps and peer history are combined and funds are unioned
+var mergeSkippedFields = map[string]bool{
+	"_id":           true,
+	"name":          true,
+	"funds":         true,
+	"peerSnapshots": true,
+	"lastScraped":   true,
+	"lastUpdated":   true,
+	"lastScored":    true,
+}
+
+// documentTime reads a stored timestamp, the zero time when it is missing
+func documentTime(value interface{}) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case primitive.DateTime:
+		return v.Time()
+	}
+	return time.Time{}
+}
+
+// scrapedFieldCount counts the scraped sections a stored company carries
+func scrapedFieldCount(doc map[string]interface{}) int {
+	count := 0
+	for _, field := range constants.ScrapedFields {
+		if !isEmptyScrapedValue(doc[field]) {
+			count++
+		}
+	}
+	return count
+}
+
+// MergeWinner orders two duplicate companies into the one to keep and the one to delete: the one
+// with the more scraped sections is kept, then the more recently scraped one, then a
+func MergeWinner(a, b map[string]interface{}) (winner, loser map[string]interface{}) {
+	countA, countB := scrapedFieldCount(a), scrapedFieldCount(b)
+	if countB > countA || (countB == countA && documentTime(b["lastScraped"]).After(documentTime(a["lastScraped"]))) {
+		return b, a
+	}
+	return a, b
+}
+
+// MergeCompanyFields builds the $set fields turning the winner into the merge of both duplicates.
+// Scraped sections come from the more recently scraped document, other fields (url, isin, sector,
+// ...) from the winner, either falling back to the other document when empty. funds are unioned,
+// the peer snapshots of both are kept in order up to PEER_SNAPSHOTS and lastScraped is the latest.
+// The scores are copied like any field, they have to be computed again on the merged data.
+func MergeCompanyFields(winner, loser map[string]interface{}) bson.M {
+	fresher, staler := winner, loser
+	if documentTime(loser["lastScraped"]).After(documentTime(winner["lastScraped"])) {
+		fresher, staler = loser, winner
+	}
+	scraped := map[string]bool{}
+	for _, field := range constants.ScrapedFields {
+		scraped[field] = true
+	}
+
+	fields := bson.M{}
+	for _, doc := range []map[string]interface{}{winner, loser} {
+		for key := range doc {
+			if mergeSkippedFields[key] {
+				continue
+			}
+			preferred, other := winner, loser
+			if scraped[key] {
+				preferred, other = fresher, staler
+			}
+			if value := preferred[key]; !isEmptyScrapedValue(value) {
+				fields[key] = value
+			} else if value := other[key]; !isEmptyScrapedValue(value) {
+				fields[key] = value
+			}
+		}
+	}
+
+	if funds := mergeFunds(winner["funds"], loser["funds"]); len(funds) > 0 {
+		fields["funds"] = funds
+	}
+	if snapshots := mergePeerSnapshots(winner["peerSnapshots"], loser["peerSnapshots"]); len(snapshots) > 0 {
+		fields["peerSnapshots"] = snapshots
+	}
+	if lastScraped := fresher["lastScraped"]; lastScraped != nil {
+		fields["lastScraped"] = lastScraped
+	}
+	return fields
+}
+
+// mergeFunds unions the funds of both documents, keeping the order they were first seen in
+func mergeFunds(values ...interface{}) []string {
+	var funds []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		list, _ := toArray(value)
+		for _, raw := range list {
+			if fund, ok := raw.(string); ok && fund != "" && !seen[fund] {
+				seen[fund] = true
+				funds = append(funds, fund)
+			}
+		}
+	}
+	return funds
+}
+
+// mergePeerSnapshots combines the peer snapshots of both documents oldest first, keeping the last PEER_SNAPSHOTS
+func mergePeerSnapshots(values ...interface{}) []interface{} {
+	var snapshots []interface{}
+	for _, value := range values {
+		list, _ := toArray(value)
+		snapshots = append(snapshots, list...)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		a, _ := toMap(snapshots[i])
+		b, _ := toMap(snapshots[j])
+		return documentTime(a["scrapedAt"]).Before(documentTime(b["scrapedAt"]))
+	})
+	if keep := config.Get().PeerSnapshots; keep >= 0 && len(snapshots) > keep {
+		snapshots = snapshots[len(snapshots)-keep:]
+	}
+	return snapshots
+}
+
+// FindDuplicateCompanies lists the pairs of stored companies that likely are the same company:
+// sharing an ISIN, or with names at least the threshold similar. Only names sharing a normalized
+// word are compared, which keeps the scan far from comparing every pair.
+func FindDuplicateCompanies(companies []types.CompanyIdentity, threshold float64) []types.DuplicateCompanies {
+	duplicates := []types.DuplicateCompanies{}
+	reported := map[[2]int]bool{}
+	report := func(i, j int, reason string, similarity float64) {
+		if reported[[2]int{i, j}] {
+			return
+		}
+		reported[[2]int{i, j}] = true
+		duplicates = append(duplicates, types.DuplicateCompanies{
+			Companies:  [2]types.CompanyIdentity{companies[i], companies[j]},
+			Reason:     reason,
+			Similarity: similarity,
+		})
+	}
+
+	byISIN := map[string][]int{}
+	byToken := map[string][]int{}
+	for i, company := range companies {
+		if isin := strings.ToUpper(strings.TrimSpace(company.ISIN)); isin != "" {
+			byISIN[isin] = append(byISIN[isin], i)
+		}
+		seen := map[string]bool{}
+		for _, token := range strings.Fields(NormalizeCompanyName(company.Name)) {
+			if !seen[token] {
+				seen[token] = true
+				byToken[token] = append(byToken[token], i)
+			}
+		}
+	}
+
+	for _, indexes := range byISIN {
+		for x, i := range indexes {
+			for _, j := range indexes[x+1:] {
+				report(i, j, "isin", NameSimilarity(companies[i].Name, companies[j].Name))
+			}
+		}
+	}
+
+	if threshold > 0 {
+		compared := map[[2]int]bool{}
+		for _, indexes := range byToken {
+			for x, i := range indexes {
+				for _, j := range indexes[x+1:] {
+					if compared[[2]int{i, j}] {
+						continue
+					}
+					compared[[2]int{i, j}] = true
+					if similarity := NameSimilarity(companies[i].Name, companies[j].Name); similarity >= threshold {
+						report(i, j, "name", similarity)
+					}
+				}
+			}
+		}
+	}
+
+	// ISIN matches first, then the most similar names
+	sort.Slice(duplicates, func(i, j int) bool {
+		a, b := duplicates[i], duplicates[j]
+		if (a.Reason == "isin") != (b.Reason == "isin") {
+			return a.Reason == "isin"
+		}
+		if a.Similarity != b.Similarity {
+			return a.Similarity > b.Similarity
+		}
+		return a.Companies[0].Name+a.Companies[1].Name < b.Companies[0].Name+b.Companies[1].Name
+	})
+	return duplicates
+}
@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/config"
+	"stockbackend/types"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMergeWinner(t *testing.T) {
+	older := primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	newer := primitive.NewDateTimeFromTime(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	complete := map[string]interface{}{"name": "Infosys Ltd", "marketCap": "100", "roce": "30", "lastScraped": older}
+	sparse := map[string]interface{}{"name": "Infosys Limited", "marketCap": "100", "lastScraped": newer}
+	if winner, _ := MergeWinner(sparse, complete); winner["name"] != "Infosys Ltd" {
+		t.Errorf("Expected the more complete company to win, got %v", winner["name"])
+	}
+
+	fresh := map[string]interface{}{"name": "Infosys Limited", "marketCap": "100", "roce": "31", "lastScraped": newer}
+	if winner, _ := MergeWinner(complete, fresh); winner["name"] != "Infosys Limited" {
+		t.Errorf("Expected the fresher company to win a tie, got %v", winner["name"])
+	}
+}
+
+func TestMergeCompanyFields(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"PEER_SNAPSHOTS": "2"}))
+
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := func(day int) map[string]interface{} {
+		return map[string]interface{}{"scrapedAt": time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)}
+	}
+
+	winner := map[string]interface{}{
+		"_id":           "winner",
+		"name":          "Infosys Ltd",
+		"url":           "/company/INFY/",
+		"sector":        "",
+		"marketCap":     "100",
+		"roce":          "30",
+		"pros":          []interface{}{},
+		"funds":         []interface{}{"Flexi Cap", "Large Cap"},
+		"peerSnapshots": []interface{}{snapshot(1), snapshot(3)},
+		"lastScraped":   older,
+	}
+	loser := map[string]interface{}{
+		"_id":           "loser",
+		"name":          "Infosys Limited",
+		"url":           "/company/INFOSYS/",
+		"isin":          "INE009A01021",
+		"sector":        "IT - Software",
+		"marketCap":     "120",
+		"pros":          []interface{}{"Debt free"},
+		"funds":         []interface{}{"Large Cap", "Tax Saver"},
+		"peerSnapshots": []interface{}{snapshot(2)},
+		"lastScraped":   newer,
+	}
+
+	fields := MergeCompanyFields(winner, loser)
+
+	if _, ok := fields["_id"]; ok {
+		t.Errorf("Expected the _id to be left out")
+	}
+	if _, ok := fields["name"]; ok {
+		t.Errorf("Expected the name to be left out")
+	}
+	if fields["marketCap"] != "120" {
+		t.Errorf("Expected the fresher scraped value, got %v", fields["marketCap"])
+	}
+	if fields["roce"] != "30" {
+		t.Errorf("Expected a section missing from the fresher company to be kept, got %v", fields["roce"])
+	}
+	if !reflect.DeepEqual(fields["pros"], []interface{}{"Debt free"}) {
+		t.Errorf("Expected the non-empty pros, got %v", fields["pros"])
+	}
+	if fields["url"] != "/company/INFY/" {
+		t.Errorf("Expected the winner's url, got %v", fields["url"])
+	}
+	if fields["isin"] != "INE009A01021" || fields["sector"] != "IT - Software" {
+		t.Errorf("Expected the identity the winner lacks from the loser, got %v, %v", fields["isin"], fields["sector"])
+	}
+	if !reflect.DeepEqual(fields["funds"], []string{"Flexi Cap", "Large Cap", "Tax Saver"}) {
+		t.Errorf("Expected the union of the funds, got %v", fields["funds"])
+	}
+	if !reflect.DeepEqual(fields["peerSnapshots"], []interface{}{snapshot(2), snapshot(3)}) {
+		t.Errorf("Expected the last PEER_SNAPSHOTS snapshots oldest first, got %v", fields["peerSnapshots"])
+	}
+	if fields["lastScraped"] != newer {
+		t.Errorf("Expected the latest lastScraped, got %v", fields["lastScraped"])
+	}
+}
+
+func TestFindDuplicateCompanies(t *testing.T) {
+	companies := []types.CompanyIdentity{
+		{Name: "Infosys Ltd", ISIN: "INE009A01021"},
+		{Name: "Infosys Limited"},
+		{Name: "Infosys Technologies", ISIN: "ine009a01021"},
+		{Name: "ITC Ltd"},
+		{Name: "ITC Hotels Ltd"},
+	}
+
+	duplicates := FindDuplicateCompanies(companies, 0.85)
+	if len(duplicates) != 2 {
+		t.Fatalf("Expected 2 duplicate pairs, got %+v", duplicates)
+	}
+	if duplicates[0].Reason != "isin" || duplicates[0].Companies[1].Name != "Infosys Technologies" {
+		t.Errorf("Expected the ISIN match first, got %+v", duplicates[0])
+	}
+	if duplicates[1].Reason != "name" || duplicates[1].Similarity != 1 || duplicates[1].Companies[1].Name != "Infosys Limited" {
+		t.Errorf("Expected the name match second, got %+v", duplicates[1])
+	}
+
+	if duplicates := FindDuplicateCompanies(companies, 0); len(duplicates) != 1 {
+		t.Errorf("Expected only ISIN matches without a threshold, got %+v", duplicates)
+	}
+}
@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"fmt"
+	"stockbackend/config"
+	"strings"
+)
+
+// scoredRatioFields are the company ratios read by the stock rating
+var scoredRatioFields = []string{"stockPE", "marketCap", "dividendYield", "roce"}
+
+// scoredPeerColumns are the peer table columns compared by the stock rating
+var scoredPeerColumns = []string{"pe", "market_cap", "div_yield", "roce", "sales_qtr", "np_qtr"}
+
+// fScoreRows are the rows whose last two annual values the F-Score compares
+var fScoreRows = []struct{ section, label string }{
+	{"profitLoss", "Net Profit +"},
+	{"profitLoss", "OPM %"},
+	{"profitLoss", "Sales +"},
+	{"balanceSheet", "Total Assets"},
+	{"balanceSheet", "Borrowings +"},
+	{"balanceSheet", "Other Assets +"},
+	{"balanceSheet", "Other Liabilities +"},
+	{"balanceSheet", "Equity Capital"},
+	{"cashFlows", "Cash from Operating Activity +"},
+}
+
+// StrictParsing reports whether companies with unparseable financial cells are excluded from
+// scoring (STRICT_PARSING) instead of being scored with those cells read as 0
+func StrictParsing() bool {
+	return config.Get().StrictParsing
+}
+
+// UnparseableFields lists the financial cells the scores read that are present but not numbers,
+// blanks included, e.g. "stockPE", "peers[2].div_yield" or "balanceSheet: Total Assets". Missing
+// fields and rows are not listed, the scores already treat them as unknown.
+func UnparseableFields(stock map[string]interface{}) []string {
+	fields := []string{}
+	for _, field := range scoredRatioFields {
+		if value, ok := stock[field]; ok {
+			if _, err := toFloat(value); err != nil {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	if peers, ok := toArray(stock["peers"]); ok {
+		for i, peerRaw := range peers {
+			peer, ok := toMap(peerRaw)
+			if !ok {
+				continue
+			}
+			for _, column := range scoredPeerColumns {
+				if value, ok := peer[column]; !ok || value == nil {
+					continue
+				}
+				if _, ok := parseFloat(peer[column]); !ok {
+					fields = append(fields, fmt.Sprintf("peers[%d].%s", i, column))
+				}
+			}
+		}
+	}
+
+	for _, row := range fScoreRows {
+		values, err := getAnnualArrayField(stock, row.section, row.label)
+		if err != nil {
+			continue
+		}
+		if len(values) > 2 {
+			values = values[len(values)-2:]
+		}
+		for _, value := range values {
+			if _, err := toFloat(value); err != nil {
+				fields = append(fields, row.section+": "+strings.TrimSpace(row.label))
+				break
+			}
+		}
+	}
+	return fields
+}
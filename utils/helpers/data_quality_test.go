@@ -0,0 +1,33 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/types"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUnparseableFields(t *testing.T) {
+	stock := fScoreFixture(true, false)
+	stock["stockPE"] = "N.A."
+	stock["marketCap"] = "1,234"
+	stock["dividendYield"] = "1.5%"
+	stock["peers"] = primitive.A{
+		bson.M{"name": "Peer A", "pe": "12.5", "market_cap": "900", "div_yield": "", "roce": nil},
+		bson.M{"name": "Median", "pe": "N.A.", "roce": int64(18), "market_cap": true},
+	}
+	// The unparseable TTM and old values are not compared by the F-Score
+	stock["cashFlows"] = []types.TableRow{row("Cash from Operating Activity\u00a0+", "--", "15", "30")}
+	stock["profitLoss"].([]types.TableRow)[1] = row("OPM %", "10", "N.A.", "12")
+
+	expected := []string{"stockPE", "peers[0].div_yield", "peers[1].pe", "peers[1].market_cap", "profitLoss: OPM %"}
+	if fields := UnparseableFields(stock); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v, got %v", expected, fields)
+	}
+
+	if fields := UnparseableFields(fScoreFixture(true, true)); len(fields) != 0 {
+		t.Errorf("Expected no unparseable fields, got %v", fields)
+	}
+}
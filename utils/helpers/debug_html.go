@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"stockbackend/config"
+	"strings"
+)
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// DebugStoreHTMLEnabled reports whether raw scraped pages should be kept for debugging
+func DebugStoreHTMLEnabled() bool {
+	return config.Get().DebugStoreHTML
+}
+
+// DebugEndpointsEnabled reports whether the debug routes are allowed to serve data
+func DebugEndpointsEnabled() bool {
+	return config.Get().DebugEndpoints
+}
+
+// DebugHTMLDir returns the directory where raw scraped pages are stored
+func DebugHTMLDir() string {
+	return config.Get().DebugHTMLDir
+}
+
+// DebugHTMLKey derives a filesystem safe key for a company page URL, keeping the statement basis
+// apart so the standalone page doesn't overwrite the consolidated one,
+// e.g. https://www.screener.in/company/TCS/consolidated/ becomes "TCS_consolidated"
+// and https://www.screener.in/company/TCS/ becomes "TCS"
+func DebugHTMLKey(pageURL string) string {
+	key := pageURL
+	if parsed, err := url.Parse(pageURL); err == nil {
+		segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		for i, segment := range segments {
+			if segment == "company" && i+1 < len(segments) {
+				key = segments[i+1]
+				if i+2 < len(segments) && segments[i+2] == StatementsConsolidated {
+					key += "_" + StatementsConsolidated
+				}
+				break
+			}
+		}
+	}
+	key = unsafeFilenameChars.ReplaceAllString(key, "_")
+	return strings.Trim(key, "_")
+}
+
+// StoreDebugHTML writes the raw HTML of a company page to the debug directory and returns its path
+func StoreDebugHTML(pageURL string, html []byte) (string, error) {
+	key := DebugHTMLKey(pageURL)
+	if key == "" {
+		return "", fmt.Errorf("could not derive a debug key from url %q", pageURL)
+	}
+
+	dir := DebugHTMLDir()
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("error creating debug HTML directory: %w", err)
+	}
+
+	path := filepath.Join(dir, key+".html")
+	if err := os.WriteFile(path, html, 0644); err != nil {
+		return "", fmt.Errorf("error writing debug HTML: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// deltaSections are the yearly statements whose rows get year over year deltas
+var deltaSections = []string{"profitLoss", "balanceSheet", "cashFlows"}
+
+// YoYDeltas returns the year over year % change of every value of a yearly series against the one
+// before it, parallel to the series. A delta is nil for the first year, when either value isn't a
+// number, when the base is zero and for a trailing TTM column, which isn't a year after the last
+// one. A negative base is compared by its magnitude, so a loss shrinking reads as an increase.
+func YoYDeltas(values primitive.A, trailingTTM bool) []*float64 {
+	deltas := make([]*float64, len(values))
+	years := len(values)
+	if trailingTTM && years > 0 {
+		years--
+	}
+	for i := 1; i < years; i++ {
+		current, ok := parsePeerNumber(values[i])
+		base, baseOk := parsePeerNumber(values[i-1])
+		if !ok || !baseOk || base == 0 {
+			continue
+		}
+		delta := Round((current - base) / math.Abs(base) * 100)
+		deltas[i] = &delta
+	}
+	return deltas
+}
+
+// FinancialDeltas computes the YoYDeltas of every row of the yearly statements, keyed by statement
+// and row label (non-breaking spaces written as spaces). Rows already in percent (e.g. "OPM %")
+// are left out, a % change of a percentage is ambiguous. Statements that aren't present are too.
+func FinancialDeltas(stock map[string]interface{}) map[string]map[string][]*float64 {
+	deltas := map[string]map[string][]*float64{}
+	for _, section := range deltaSections {
+		trailingTTM := hasTrailingTTM(stock, section)
+		rows := map[string][]*float64{}
+		for label, values := range statementRows(stock[section]) {
+			label = strings.TrimSpace(strings.ReplaceAll(label, "\u00a0", " "))
+			if label == "" || strings.HasSuffix(label, "%") {
+				continue
+			}
+			rows[label] = YoYDeltas(values, trailingTTM)
+		}
+		if len(rows) > 0 {
+			deltas[section] = rows
+		}
+	}
+	return deltas
+}
+
+// statementRows returns the values of every row of a statement, sub-rows included, whether it is
+// stored as ordered rows or as a label keyed map
+func statementRows(statement interface{}) map[string]primitive.A {
+	rows := map[string]primitive.A{}
+	if tableRows, ok := toTableRows(statement); ok {
+		for label, values := range TableRowsToMap(tableRows) {
+			if array, ok := toArray(values); ok {
+				rows[label] = array
+			}
+		}
+		return rows
+	}
+	if table, ok := toMap(statement); ok {
+		for label, values := range table {
+			if array, ok := toArray(values); ok {
+				rows[label] = array
+			}
+		}
+	}
+	return rows
+}
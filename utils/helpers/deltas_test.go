@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/types"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func deltaValues(deltas []*float64) []interface{} {
+	values := make([]interface{}, len(deltas))
+	for i, delta := range deltas {
+		if delta != nil {
+			values[i] = *delta
+		}
+	}
+	return values
+}
+
+func TestYoYDeltas(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   primitive.A
+		ttm      bool
+		expected []interface{}
+	}{
+		{"increasing", primitive.A{"100", "110", "1,210"}, false, []interface{}{nil, 10.0, 1000.0}},
+		{"decreasing", primitive.A{"200", "150", "120"}, false, []interface{}{nil, -25.0, -20.0}},
+		{"zero base", primitive.A{"0", "50", "0", "10"}, false, []interface{}{nil, nil, -100.0, nil}},
+		{"negative base", primitive.A{"-40", "-10", "20"}, false, []interface{}{nil, 75.0, 300.0}},
+		{"missing values", primitive.A{"100", "", "N.A.", "90", "99"}, false, []interface{}{nil, nil, nil, nil, 10.0}},
+		{"trailing TTM", primitive.A{"100", "120", "130"}, true, []interface{}{nil, 20.0, nil}},
+		{"rounded", primitive.A{"3", "4"}, false, []interface{}{nil, 33.33}},
+		{"empty", primitive.A{}, true, []interface{}{}},
+	}
+	for _, test := range tests {
+		if deltas := deltaValues(YoYDeltas(test.values, test.ttm)); !reflect.DeepEqual(deltas, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, deltas)
+		}
+	}
+}
+
+func TestFinancialDeltas(t *testing.T) {
+	stock := map[string]interface{}{
+		"profitLoss": []types.TableRow{
+			{Label: "Sales +", Values: []string{"100", "120", "150"}},
+			{Label: "OPM %", Values: []string{"20%", "22%", "21%"}},
+			{Label: "Expenses +", Values: []string{"80", "90", "100"}, Children: []types.TableRow{
+				{Label: "Employee Cost %", Values: []string{"10", "11", "12"}},
+				{Label: "Other Costs", Values: []string{"40", "30", "35"}},
+			}},
+		},
+		"profitLossHeaders": []string{"Mar 2023", "Mar 2024", "TTM"},
+		"balanceSheet":      map[string]interface{}{"Total Assets": []interface{}{"500", "550"}},
+	}
+
+	deltas := FinancialDeltas(stock)
+	expected := map[string]map[string][]interface{}{
+		"profitLoss": {
+			"Sales +":     {nil, 20.0, nil},
+			"Expenses +":  {nil, 12.5, nil},
+			"Other Costs": {nil, -25.0, nil},
+		},
+		"balanceSheet": {"Total Assets": {nil, 10.0}},
+	}
+	if len(deltas) != len(expected) {
+		t.Fatalf("Expected the deltas of %d statements, got %v", len(expected), deltas)
+	}
+	for section, rows := range expected {
+		if len(deltas[section]) != len(rows) {
+			t.Errorf("Expected the rows %v of %s, got %v", rows, section, deltas[section])
+		}
+		for label, values := range rows {
+			if got := deltaValues(deltas[section][label]); !reflect.DeepEqual(got, values) {
+				t.Errorf("%s %s: expected %v, got %v", section, label, values, got)
+			}
+		}
+	}
+
+	// Stored with the scrape, per statement
+	update := ScrapedFieldsUpdate(map[string]interface{}{"profitLoss": stock["profitLoss"], "profitLossHeaders": stock["profitLossHeaders"]})
+	if _, ok := update["deltas.profitLoss"]; !ok {
+		t.Errorf("Expected the profit & loss deltas to be set, got %v", update)
+	}
+	if _, ok := update["deltas.balanceSheet"]; ok {
+		t.Errorf("Expected the stored balance sheet deltas to be kept")
+	}
+}
@@ -0,0 +1,94 @@
+package helpers
+
+import (
+	"math"
+	"sort"
+	"stockbackend/types"
+	"strings"
+)
+
+// DiffThresholds decide when a holding present in both uploads counts as changed
+type DiffThresholds struct {
+	// QuantityPct is the relative change in quantity, in percent
+	QuantityPct float64 `json:"quantityPct"`
+	// PercentageOfAUM is the absolute change in % of AUM, in percentage points
+	PercentageOfAUM float64 `json:"percentageOfAUM"`
+}
+
+// NewHoldingSnapshot extracts the persisted fields of a streamed holding
+func NewHoldingSnapshot(stockDetail map[string]interface{}) types.HoldingSnapshot {
+	name, _ := stockDetail["Name of the Instrument"].(string)
+	isin, _ := stockDetail["ISIN"].(string)
+	classification, _ := stockDetail["classification"].(string)
+	return types.HoldingSnapshot{
+		Name:            strings.TrimSpace(name),
+		ISIN:            strings.ToUpper(strings.TrimSpace(isin)),
+		Classification:  classification,
+		Quantity:        ParseAmount(stockDetail["Quantity"]),
+		MarketValue:     ParseAmount(stockDetail["Market/Fair Value"]),
+		PercentageOfAUM: ParsePercentage(stockDetail["Percentage of AUM"]),
+	}
+}
+
+// holdingKey matches holdings by ISIN, falling back to the normalized name for rows without one
+func holdingKey(holding types.HoldingSnapshot) string {
+	if holding.ISIN != "" {
+		return "isin:" + holding.ISIN
+	}
+	return "name:" + NormalizeString(holding.Name)
+}
+
+// DiffHoldings compares the holdings of two uploads
+func DiffHoldings(previous, current []types.HoldingSnapshot, thresholds DiffThresholds) types.HoldingsDiff {
+	diff := types.HoldingsDiff{
+		Added:   []types.HoldingSnapshot{},
+		Removed: []types.HoldingSnapshot{},
+		Changed: []types.HoldingChange{},
+	}
+
+	previousByKey := make(map[string]types.HoldingSnapshot, len(previous))
+	for _, holding := range previous {
+		previousByKey[holdingKey(holding)] = holding
+	}
+	currentKeys := make(map[string]bool, len(current))
+
+	for _, holding := range current {
+		key := holdingKey(holding)
+		currentKeys[key] = true
+
+		before, ok := previousByKey[key]
+		if !ok {
+			diff.Added = append(diff.Added, holding)
+			continue
+		}
+
+		quantityChangePct := 0.0
+		if before.Quantity != 0 {
+			quantityChangePct = (holding.Quantity - before.Quantity) / math.Abs(before.Quantity) * 100
+		} else if holding.Quantity != 0 {
+			quantityChangePct = 100
+		}
+		aumChange := math.Abs(holding.PercentageOfAUM - before.PercentageOfAUM)
+
+		if math.Abs(quantityChangePct) > thresholds.QuantityPct || aumChange > thresholds.PercentageOfAUM {
+			diff.Changed = append(diff.Changed, types.HoldingChange{
+				Name:                 holding.Name,
+				ISIN:                 holding.ISIN,
+				PreviousQuantity:     before.Quantity,
+				Quantity:             holding.Quantity,
+				QuantityChangePct:    Round(quantityChangePct),
+				PreviousPercentOfAUM: before.PercentageOfAUM,
+				PercentageOfAUM:      holding.PercentageOfAUM,
+			})
+		}
+	}
+
+	for _, holding := range previous {
+		if !currentKeys[holdingKey(holding)] {
+			diff.Removed = append(diff.Removed, holding)
+		}
+	}
+
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Name < diff.Changed[j].Name })
+	return diff
+}
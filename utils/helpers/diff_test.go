@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/types"
+	"testing"
+)
+
+func TestDiffHoldings(t *testing.T) {
+	previous := []types.HoldingSnapshot{
+		{Name: "Infosys Limited", ISIN: "INE009A01021", Quantity: 1000, PercentageOfAUM: 5},
+		{Name: "HDFC Bank Limited", ISIN: "INE040A01034", Quantity: 500, PercentageOfAUM: 4},
+		{Name: "ITC Limited", ISIN: "INE154A01025", Quantity: 200, PercentageOfAUM: 2},
+		{Name: "TREPS", Quantity: 0, PercentageOfAUM: 1},
+	}
+	current := []types.HoldingSnapshot{
+		// Renamed in the new sheet but the same ISIN
+		{Name: "Infosys Ltd", ISIN: "INE009A01021", Quantity: 1500, PercentageOfAUM: 7},
+		{Name: "HDFC Bank Limited", ISIN: "INE040A01034", Quantity: 501, PercentageOfAUM: 4.05},
+		{Name: "Reliance Industries Limited", ISIN: "INE002A01018", Quantity: 300, PercentageOfAUM: 3},
+		{Name: "treps", Quantity: 0, PercentageOfAUM: 1},
+	}
+
+	diff := DiffHoldings(previous, current, DiffThresholds{QuantityPct: 1, PercentageOfAUM: 0.1})
+
+	if len(diff.Added) != 1 || diff.Added[0].ISIN != "INE002A01018" {
+		t.Errorf("Expected Reliance to be added, got %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].ISIN != "INE154A01025" {
+		t.Errorf("Expected ITC to be removed, got %v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 {
+		t.Fatalf("Expected only Infosys to change beyond the threshold, got %v", diff.Changed)
+	}
+	change := diff.Changed[0]
+	if change.ISIN != "INE009A01021" || change.QuantityChangePct != 50 || change.PreviousPercentOfAUM != 5 || change.PercentageOfAUM != 7 {
+		t.Errorf("Unexpected change %+v", change)
+	}
+}
+
+func TestNewHoldingSnapshot(t *testing.T) {
+	snapshot := NewHoldingSnapshot(map[string]interface{}{
+		"Name of the Instrument": " Infosys Limited ",
+		"ISIN":                   "ine009a01021",
+		"classification":         HoldingEquity,
+		"Quantity":               "1,23,456",
+		"Market/Fair Value":      "2,345.67",
+		"Percentage of AUM":      "4.56%",
+	})
+	expected := types.HoldingSnapshot{
+		Name:            "Infosys Limited",
+		ISIN:            "INE009A01021",
+		Classification:  HoldingEquity,
+		Quantity:        123456,
+		MarketValue:     2345.67,
+		PercentageOfAUM: 4.56,
+	}
+	if snapshot != expected {
+		t.Errorf("Expected %+v, got %+v", expected, snapshot)
+	}
+}
+
+func TestNewUnmatchedInstrument(t *testing.T) {
+	// Renamed by the name map before its search and scrape failed
+	stockDetail := map[string]interface{}{
+		"Name of the Instrument": "Zomato Limited",
+		"ISIN":                   " ine758t01015",
+	}
+	MarkUnresolved(stockDetail, UnresolvedNoSearchMatch)
+
+	unmatched := NewUnmatchedInstrument(stockDetail, "Zomato Ltd.")
+	expected := types.UnmatchedInstrument{
+		Name:       "Zomato Ltd.",
+		MappedName: "Zomato Limited",
+		ISIN:       "INE758T01015",
+		Reason:     UnresolvedNoSearchMatch,
+		Candidates: []types.FuzzyCandidate{},
+	}
+	if !reflect.DeepEqual(unmatched, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, unmatched)
+	}
+
+	if unmatched := NewUnmatchedInstrument(map[string]interface{}{"Name of the Instrument": "Zomato Ltd."}, "Zomato Ltd."); unmatched.MappedName != "" {
+		t.Errorf("Expected no mapped name when the name map didn't apply, got %q", unmatched.MappedName)
+	}
+}
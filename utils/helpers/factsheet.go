@@ -0,0 +1,136 @@
+package helpers
+
+import (
+	"fmt"
+	"stockbackend/types"
+	"strconv"
+	"strings"
+)
+
+// FactSheet is the one page summary of a stored company exported as a PDF
+type FactSheet struct {
+	Name    string
+	Sector  string
+	Metrics []FactSheetMetric
+	Pros    []string
+	Cons    []string
+	Table   FactSheetTable
+}
+
+// FactSheetMetric is a labelled value of the key metrics grid, "-" when it isn't stored
+type FactSheetMetric struct {
+	Label string
+	Value string
+}
+
+// FactSheetTable is the small financial table of a fact sheet, the latest periods of a statement
+type FactSheetTable struct {
+	Title   string
+	Headers []string
+	Rows    []FactSheetRow
+}
+
+// FactSheetRow is a labelled row of a FactSheetTable, one value per header
+type FactSheetRow struct {
+	Label  string
+	Values []string
+}
+
+// factSheetMetrics formats the stored fields of the key metrics grid, in order, with their unit
+func factSheetMetrics(company types.CompanyDocument) []FactSheetMetric {
+	metric := func(label string, value interface{}, unit string) FactSheetMetric {
+		return FactSheetMetric{Label: label, Value: factSheetValue(value, unit)}
+	}
+	return []FactSheetMetric{
+		metric("Market Cap", company.MarketCap, " Cr."),
+		metric("Current Price", company.CurrentPrice, ""),
+		metric("High / Low", company.HighLow, ""),
+		metric("Stock P/E", company.StockPE, ""),
+		metric("Book Value", company.BookValue, ""),
+		metric("Dividend Yield", company.DividendYield, "%"),
+		metric("ROCE", company.ROCE, "%"),
+		metric("ROE", company.ROE, "%"),
+		metric("Face Value", company.FaceValue, ""),
+		metric("Stock Rate", company.StockRate, ""),
+		metric("F-Score", company.FScore, ""),
+	}
+}
+
+// factSheetRows are the profit & loss rows of the financial table, left out when not stored
+var factSheetRows = []string{"Sales", "Operating Profit", "Net Profit", "EPS in Rs"}
+
+// factSheetPeriods is how many of the latest profit & loss periods the financial table shows
+const factSheetPeriods = 5
+
+// NewFactSheet builds the fact sheet of a stored company
+func NewFactSheet(company types.CompanyDocument) FactSheet {
+	sheet := FactSheet{
+		Name:    factSheetValue(company.Name, ""),
+		Sector:  factSheetValue(company.Sector, ""),
+		Pros:    factSheetList(company.Pros),
+		Cons:    factSheetList(company.Cons),
+		Metrics: factSheetMetrics(company),
+		Table:   FactSheetTable{Title: "Profit & Loss (Rs. Cr.)"},
+	}
+
+	headers := company.ProfitLossHeaders
+	periods := min(len(headers), factSheetPeriods)
+	for _, header := range headers[len(headers)-periods:] {
+		sheet.Table.Headers = append(sheet.Table.Headers, factSheetValue(header, ""))
+	}
+	// The statement is left untyped, its rows are read like the ones of a map
+	statements := map[string]interface{}{"profitLoss": company.ProfitLoss}
+	for _, label := range factSheetRows {
+		values, ok := metricValues(statements, "profitLoss", label)
+		if !ok || periods == 0 {
+			continue
+		}
+		// Rows are aligned with the headers from the latest period, like FinancialHistory
+		row := FactSheetRow{Label: label, Values: make([]string, periods)}
+		for i := range row.Values {
+			row.Values[i] = "-"
+			if index := len(values) - periods + i; index >= 0 {
+				row.Values[i] = factSheetValue(values[index], "")
+			}
+		}
+		sheet.Table.Rows = append(sheet.Table.Rows, row)
+	}
+	return sheet
+}
+
+// factSheetValue formats a stored value with its unit, "-" when it is missing or blank
+func factSheetValue(value interface{}, unit string) string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+	case *float64:
+		if v != nil {
+			text = strconv.FormatFloat(Round(*v), 'f', -1, 64)
+		}
+	case *int:
+		if v != nil {
+			text = strconv.Itoa(*v)
+		}
+	case string:
+		text = strings.TrimSpace(v)
+	case float64:
+		text = strconv.FormatFloat(Round(v), 'f', -1, 64)
+	default:
+		text = fmt.Sprintf("%v", v)
+	}
+	if text == "" {
+		return "-"
+	}
+	return text + unit
+}
+
+// factSheetList trims a stored list of strings (pros, cons), skipping blank entries
+func factSheetList(items []string) []string {
+	var list []string
+	for _, item := range items {
+		if text := strings.TrimSpace(item); text != "" {
+			list = append(list, text)
+		}
+	}
+	return list
+}
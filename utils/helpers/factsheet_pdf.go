@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"io"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// FactSheetContentType is the content type of a fact sheet written by WriteFactSheetPDF
+const FactSheetContentType = "application/pdf"
+
+// factSheetBullets is how many pros and cons a fact sheet lists, so it fits on one page
+const factSheetBullets = 5
+
+// factSheetBlock renders a part of a fact sheet below the previous one, tr translates UTF-8 text
+// into the encoding of the core fonts
+type factSheetBlock func(pdf *fpdf.Fpdf, tr func(string) string, sheet FactSheet)
+
+// factSheetLayout is the fact sheet page from top to bottom, parts are added, moved or restyled here
+var factSheetLayout = []factSheetBlock{
+	factSheetTitleBlock,
+	factSheetMetricsBlock("Key Metrics", 3),
+	factSheetListBlock("Pros", func(sheet FactSheet) []string { return sheet.Pros }),
+	factSheetListBlock("Cons", func(sheet FactSheet) []string { return sheet.Cons }),
+	factSheetTableBlock,
+}
+
+// WriteFactSheetPDF renders the fact sheet as a one page A4 PDF
+func WriteFactSheetPDF(w io.Writer, sheet FactSheet) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetTitle(sheet.Name, true)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	for _, block := range factSheetLayout {
+		block(pdf, tr, sheet)
+	}
+	return pdf.Output(w)
+}
+
+// factSheetWidth is the width between the page margins
+func factSheetWidth(pdf *fpdf.Fpdf) float64 {
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	return pageWidth - left - right
+}
+
+// factSheetHeading starts a section of the fact sheet
+func factSheetHeading(pdf *fpdf.Fpdf, tr func(string) string, heading string) {
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.SetFillColor(230, 236, 245)
+	pdf.CellFormat(0, 7, tr(heading), "", 1, "L", true, 0, "")
+	pdf.Ln(2)
+}
+
+func factSheetTitleBlock(pdf *fpdf.Fpdf, tr func(string) string, sheet FactSheet) {
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(0, 10, tr(sheet.Name), "", 1, "L", false, 0, "")
+	if sheet.Sector != "-" {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.SetTextColor(90, 90, 90)
+		pdf.CellFormat(0, 6, tr(sheet.Sector), "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+}
+
+// factSheetMetricsBlock lays the key metrics out in a grid of label and value pairs
+func factSheetMetricsBlock(heading string, columns int) factSheetBlock {
+	return func(pdf *fpdf.Fpdf, tr func(string) string, sheet FactSheet) {
+		factSheetHeading(pdf, tr, heading)
+		cell := factSheetWidth(pdf) / float64(columns) / 2
+		for i, metric := range sheet.Metrics {
+			pdf.SetFont("Helvetica", "", 9)
+			pdf.SetTextColor(90, 90, 90)
+			pdf.CellFormat(cell, 6, tr(metric.Label), "", 0, "L", false, 0, "")
+			pdf.SetFont("Helvetica", "B", 10)
+			pdf.SetTextColor(0, 0, 0)
+			pdf.CellFormat(cell, 6, tr(metric.Value), "", 0, "L", false, 0, "")
+			if (i+1)%columns == 0 || i == len(sheet.Metrics)-1 {
+				pdf.Ln(6)
+			}
+		}
+	}
+}
+
+// factSheetListBlock lists the first factSheetBullets entries of a list, left out when it is empty
+func factSheetListBlock(heading string, list func(FactSheet) []string) factSheetBlock {
+	return func(pdf *fpdf.Fpdf, tr func(string) string, sheet FactSheet) {
+		items := list(sheet)
+		if len(items) == 0 {
+			return
+		}
+		factSheetHeading(pdf, tr, heading)
+		pdf.SetFont("Helvetica", "", 9)
+		for _, item := range items[:min(len(items), factSheetBullets)] {
+			pdf.MultiCell(0, 5, tr("- "+item), "", "L", false)
+		}
+	}
+}
+
+// factSheetTableBlock draws the financial table, left out when no row is stored
+func factSheetTableBlock(pdf *fpdf.Fpdf, tr func(string) string, sheet FactSheet) {
+	table := sheet.Table
+	if len(table.Rows) == 0 {
+		return
+	}
+	factSheetHeading(pdf, tr, table.Title)
+	labelWidth := 45.0
+	valueWidth := (factSheetWidth(pdf) - labelWidth) / float64(len(table.Headers))
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(245, 245, 245)
+	pdf.CellFormat(labelWidth, 6, "", "1", 0, "L", true, 0, "")
+	for _, header := range table.Headers {
+		pdf.CellFormat(valueWidth, 6, tr(header), "1", 0, "R", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, row := range table.Rows {
+		pdf.CellFormat(labelWidth, 6, tr(row.Label), "1", 0, "L", false, 0, "")
+		for _, value := range row.Values {
+			pdf.CellFormat(valueWidth, 6, tr(value), "1", 0, "R", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
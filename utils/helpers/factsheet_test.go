@@ -0,0 +1,104 @@
+package helpers
+
+import (
+	"bytes"
+	"reflect"
+	"stockbackend/types"
+	"testing"
+)
+
+func factSheetCompany() types.CompanyDocument {
+	stockRate, fScore := 61.234, 7
+	return types.CompanyDocument{
+		Name:          "Infosys Ltd",
+		Sector:        "IT - Software",
+		MarketCap:     "6,48,000",
+		CurrentPrice:  "1,560",
+		StockPE:       "24.5",
+		DividendYield: "2.7",
+		ROCE:          "",
+		StockRate:     &stockRate,
+		FScore:        &fScore,
+		Pros:          []string{"Company has a good return on equity", " "},
+		Cons:          []string{},
+		ProfitLoss: []types.TableRow{
+			row("Sales +", "1", "2", "3", "4", "5", "6"),
+			row("Net Profit +", "10", "11"),
+			row("Tax %", "25%", "26%"),
+		},
+		ProfitLossHeaders: []string{"Mar 2020", "Mar 2021", "Mar 2022", "Mar 2023", "Mar 2024", "TTM"},
+	}
+}
+
+func TestNewFactSheet(t *testing.T) {
+	sheet := NewFactSheet(factSheetCompany())
+
+	if sheet.Name != "Infosys Ltd" || sheet.Sector != "IT - Software" {
+		t.Errorf("Unexpected title: %q, %q", sheet.Name, sheet.Sector)
+	}
+	metrics := map[string]string{}
+	for _, metric := range sheet.Metrics {
+		metrics[metric.Label] = metric.Value
+	}
+	want := map[string]string{
+		"Market Cap":     "6,48,000 Cr.",
+		"Dividend Yield": "2.7%",
+		"ROCE":           "-",
+		"ROE":            "-",
+		"Stock Rate":     "61.23",
+		"F-Score":        "7",
+	}
+	for label, value := range want {
+		if metrics[label] != value {
+			t.Errorf("%s = %q, want %q", label, metrics[label], value)
+		}
+	}
+	if len(sheet.Metrics) != 11 {
+		t.Errorf("Expected every key metric, got %v", sheet.Metrics)
+	}
+
+	if !reflect.DeepEqual(sheet.Pros, []string{"Company has a good return on equity"}) || len(sheet.Cons) != 0 {
+		t.Errorf("Unexpected pros and cons: %v, %v", sheet.Pros, sheet.Cons)
+	}
+
+	// The latest 5 periods, with a short row aligned on the latest one
+	table := sheet.Table
+	if !reflect.DeepEqual(table.Headers, []string{"Mar 2021", "Mar 2022", "Mar 2023", "Mar 2024", "TTM"}) {
+		t.Errorf("Unexpected headers: %v", table.Headers)
+	}
+	rows := []FactSheetRow{
+		{Label: "Sales", Values: []string{"2", "3", "4", "5", "6"}},
+		{Label: "Net Profit", Values: []string{"-", "-", "-", "10", "11"}},
+	}
+	if !reflect.DeepEqual(table.Rows, rows) {
+		t.Errorf("Unexpected rows: %v", table.Rows)
+	}
+}
+
+func TestWriteFactSheetPDF(t *testing.T) {
+	tests := []struct {
+		name    string
+		company types.CompanyDocument
+	}{
+		{"stored company", factSheetCompany()},
+		// A company without statements still gets its page
+		{"bare company", types.CompanyDocument{Name: "Société Générale"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pdf bytes.Buffer
+			if err := WriteFactSheetPDF(&pdf, NewFactSheet(tt.company)); err != nil {
+				t.Fatalf("WriteFactSheetPDF() error = %v", err)
+			}
+			if !bytes.HasPrefix(pdf.Bytes(), []byte("%PDF-")) || !bytes.Contains(pdf.Bytes(), []byte("%%EOF")) {
+				t.Errorf("Expected a complete PDF, got %d bytes", pdf.Len())
+			}
+			if bytes.Count(pdf.Bytes(), []byte("/Type /Page\n")) != 1 {
+				t.Errorf("Expected a single page")
+			}
+		})
+	}
+	if FactSheetContentType != "application/pdf" {
+		t.Errorf("Unexpected content type %q", FactSheetContentType)
+	}
+}
@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"stockbackend/config"
+	"strings"
+)
+
+// allFields is the ?fields= value returning every field, the denylisted ones included
+const allFields = "*"
+
+// FieldProjection selects the fields of the documents a response returns: only the requested
+// ones, or every field but the denylisted bulky tables when none were requested
+type FieldProjection struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// ParseFieldProjection validates the comma separated ?fields= param of an endpoint. An empty value
+// leaves out the denylisted fields, "*" returns every field, any other list returns those fields
+// only, denylisted or not.
+func ParseFieldProjection(value string, denylist []string) (FieldProjection, error) {
+	value = strings.TrimSpace(value)
+	if value == allFields {
+		return FieldProjection{}, nil
+	}
+	if value == "" {
+		projection := FieldProjection{exclude: map[string]bool{}}
+		for _, field := range denylist {
+			projection.exclude[field] = true
+		}
+		return projection, nil
+	}
+
+	projection := FieldProjection{include: map[string]bool{}}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		// Field names end up in Mongo projections, where "$" starts an operator
+		if strings.Contains(field, "$") || field == allFields {
+			return FieldProjection{}, fmt.Errorf("invalid field %q in fields", field)
+		}
+		projection.include[field] = true
+	}
+	if len(projection.include) == 0 {
+		return FieldProjection{}, fmt.Errorf("invalid fields: %q", value)
+	}
+	return projection, nil
+}
+
+// DefaultFieldProjection leaves out the RESPONSE_DENYLIST fields, for responses without a ?fields= param
+func DefaultFieldProjection() FieldProjection {
+	projection, _ := ParseFieldProjection("", config.Get().ResponseDenylist)
+	return projection
+}
+
+// Allows reports whether the field is returned
+func (p FieldProjection) Allows(field string) bool {
+	if p.include != nil {
+		return p.include[field]
+	}
+	return !p.exclude[field]
+}
+
+// Apply returns the allowed fields of the document, the document itself is left untouched
+func (p FieldProjection) Apply(doc map[string]interface{}) map[string]interface{} {
+	if p.include == nil && len(p.exclude) == 0 {
+		return doc
+	}
+	projected := make(map[string]interface{}, len(doc))
+	for field, value := range doc {
+		if p.Allows(field) {
+			projected[field] = value
+		}
+	}
+	return projected
+}
+
+// ApplyJSON applies the projection to a value marshalled to JSON, a struct or a list of them, for
+// responses that aren't maps
+func (p FieldProjection) ApplyJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling data: %w", err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("error unmarshalling data: %w", err)
+	}
+	switch decoded := decoded.(type) {
+	case map[string]interface{}:
+		return p.Apply(decoded), nil
+	case []interface{}:
+		for i, item := range decoded {
+			if doc, ok := item.(map[string]interface{}); ok {
+				decoded[i] = p.Apply(doc)
+			}
+		}
+		return decoded, nil
+	}
+	return decoded, nil
+}
@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"stockbackend/types"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var projectedCompany = map[string]interface{}{
+	"name":             "Infosys Ltd",
+	"stockRate":        61.3,
+	"roce":             "37.5",
+	"quarterlyResults": map[string]interface{}{"Sales +": []interface{}{"100", "110"}},
+	"peers":            []interface{}{map[string]interface{}{"name": "TCS"}},
+}
+
+func TestParseFieldProjection(t *testing.T) {
+	denylist := []string{"quarterlyResults", "peers"}
+
+	// No fields leaves out the denylisted tables
+	projection, err := ParseFieldProjection("", denylist)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := map[string]interface{}{"name": "Infosys Ltd", "stockRate": 61.3, "roce": "37.5"}; !reflect.DeepEqual(projection.Apply(projectedCompany), expected) {
+		t.Errorf("Expected %v, got %v", expected, projection.Apply(projectedCompany))
+	}
+
+	// Requested fields are returned alone, denylisted or not
+	projection, err = ParseFieldProjection(" name, peers ,,", denylist)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{"name": "Infosys Ltd", "peers": projectedCompany["peers"]}
+	if !reflect.DeepEqual(projection.Apply(projectedCompany), expected) {
+		t.Errorf("Expected %v, got %v", expected, projection.Apply(projectedCompany))
+	}
+
+	// "*" returns everything
+	projection, err = ParseFieldProjection("*", denylist)
+	if err != nil || !reflect.DeepEqual(projection.Apply(projectedCompany), projectedCompany) {
+		t.Errorf("Expected every field, got %v (%v)", projection.Apply(projectedCompany), err)
+	}
+
+	if len(projectedCompany) != 5 {
+		t.Errorf("Expected the document to be left untouched, got %v", projectedCompany)
+	}
+
+	for _, invalid := range []string{",", "name,$where", "name,*"} {
+		if _, err := ParseFieldProjection(invalid, denylist); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestFieldProjection_ApplyJSON(t *testing.T) {
+	projection, _ := ParseFieldProjection("stockRate,marketCap", nil)
+	summaries := []types.CompanySummary{{Name: "Infosys Ltd", MarketCap: "Large Cap", StockRate: 61.3}}
+
+	projected, err := projection.ApplyJSON(summaries)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []interface{}{map[string]interface{}{"marketCap": "Large Cap", "stockRate": 61.3}}
+	if !reflect.DeepEqual(projected, expected) {
+		t.Errorf("Expected %v, got %v", expected, projected)
+	}
+}
+
+func TestCompanyListQuery_Projection(t *testing.T) {
+	query, err := ParseCompanyListQuery(url.Values{"fields": {"stockRate,marketCap,quarterlyResults"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := (bson.M{"name": 1, "stockRate": 1, "marketCapCategory": 1}); !reflect.DeepEqual(query.Projection(), expected) {
+		t.Errorf("Expected %v, got %v", expected, query.Projection())
+	}
+
+	// The formatted market cap is read from the stored number
+	query, _ = ParseCompanyListQuery(url.Values{"fields": {"marketCapFormatted"}})
+	if expected := (bson.M{"name": 1, "marketCapValue": 1}); !reflect.DeepEqual(query.Projection(), expected) {
+		t.Errorf("Expected %v, got %v", expected, query.Projection())
+	}
+
+	query, _ = ParseCompanyListQuery(url.Values{})
+	stored := map[string]bool{}
+	for _, field := range companySummaryFields {
+		stored[field] = true
+	}
+	if len(query.Projection()) != len(stored) {
+		t.Errorf("Expected every summary field without fields, got %v", query.Projection())
+	}
+
+	if _, err := ParseCompanyListQuery(url.Values{"fields": {"$natural"}}); err == nil {
+		t.Errorf("Expected an error for an operator in fields")
+	}
+}
+
+func TestStreamWriter_WriteHolding(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	stream := NewStreamWriter(recorder, StreamNDJSON)
+	fields, _ := ParseFieldProjection("name,stockRate", nil)
+	stream.SetFields(fields)
+
+	if err := stream.WriteHolding(projectedCompany); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Summaries aren't holdings, they're written whole
+	if err := stream.WriteEntry(map[string]interface{}{"type": "summary", "file": "fund.xlsx"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if expected := []string{`{"name":"Infosys Ltd","stockRate":61.3}`, `{"file":"fund.xlsx","type":"summary"}`}; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %v, got %v", expected, lines)
+	}
+}
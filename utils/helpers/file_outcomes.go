@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Statuses of the files of an upload
+const (
+	FileProcessed = "processed"
+	FileFailed    = "failed"
+	// Reached once the upload deadline passed, its holdings were counted without being enriched
+	FileUnprocessed = "unprocessed"
+)
+
+// ErrUploadFilesFailed is returned when too many files of an upload failed, see UploadOutcomes.Check
+var ErrUploadFilesFailed = errors.New("upload files failed")
+
+// FileOutcome is the status of a file of an upload, with the reason when it failed
+type FileOutcome struct {
+	File   string `json:"file"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// UploadOutcomes collects the status of every file of an upload in the order they were processed
+type UploadOutcomes struct {
+	Files []FileOutcome
+}
+
+func NewUploadOutcomes() *UploadOutcomes {
+	return &UploadOutcomes{Files: []FileOutcome{}}
+}
+
+// Processed records a file whose sheets were read, a corrupt sheet is reported on its own
+func (uo *UploadOutcomes) Processed(file string) {
+	uo.Files = append(uo.Files, FileOutcome{File: file, Status: FileProcessed})
+}
+
+// Failed records a file that could not be opened, archived or parsed
+func (uo *UploadOutcomes) Failed(file string, err error) {
+	uo.Files = append(uo.Files, FileOutcome{File: file, Status: FileFailed, Error: err.Error()})
+}
+
+// Unprocessed records a file left once the upload deadline passed
+func (uo *UploadOutcomes) Unprocessed(file string) {
+	uo.Files = append(uo.Files, FileOutcome{File: file, Status: FileUnprocessed, Error: "upload deadline exceeded"})
+}
+
+// Count returns how many files have the status
+func (uo *UploadOutcomes) Count(status string) int {
+	count := 0
+	for _, outcome := range uo.Files {
+		if outcome.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Entry builds the summary entry streamed after the last file of the upload
+func (uo *UploadOutcomes) Entry() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "uploadSummary",
+		"files":       uo.Files,
+		"processed":   uo.Count(FileProcessed),
+		"failed":      uo.Count(FileFailed),
+		"unprocessed": uo.Count(FileUnprocessed),
+	}
+}
+
+// Check fails the upload with ErrUploadFilesFailed when fewer than minProcessed files were
+// processed or, with failOnError, when any file failed
+func (uo *UploadOutcomes) Check(minProcessed int, failOnError bool) error {
+	processed, failed := uo.Count(FileProcessed), uo.Count(FileFailed)
+	if processed < minProcessed {
+		return fmt.Errorf("%w: %d of %d files processed, at least %d required", ErrUploadFilesFailed, processed, len(uo.Files), minProcessed)
+	}
+	if failOnError && failed > 0 {
+		return fmt.Errorf("%w: %d of %d files failed", ErrUploadFilesFailed, failed, len(uo.Files))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUploadOutcomes_Entry(t *testing.T) {
+	outcomes := NewUploadOutcomes()
+	outcomes.Processed("first.xlsx")
+	outcomes.Failed("corrupt.xlsx", errors.New("could not open workbook: zip: not a valid zip file"))
+	outcomes.Processed("second.xlsx")
+
+	data, err := json.Marshal(outcomes.Entry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry struct {
+		Type      string        `json:"type"`
+		Files     []FileOutcome `json:"files"`
+		Processed int           `json:"processed"`
+		Failed    int           `json:"failed"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Type != "uploadSummary" || entry.Processed != 2 || entry.Failed != 1 || len(entry.Files) != 3 {
+		t.Fatalf("Unexpected upload summary: %s", data)
+	}
+	want := []FileOutcome{
+		{File: "first.xlsx", Status: FileProcessed},
+		{File: "corrupt.xlsx", Status: FileFailed},
+		{File: "second.xlsx", Status: FileProcessed},
+	}
+	for i, outcome := range entry.Files {
+		if outcome.File != want[i].File || outcome.Status != want[i].Status {
+			t.Errorf("files[%d] = %+v, want %+v", i, outcome, want[i])
+		}
+		if failed := outcome.Status == FileFailed; failed != strings.Contains(outcome.Error, "could not open workbook") {
+			t.Errorf("files[%d] has error %q", i, outcome.Error)
+		}
+	}
+	if strings.Contains(string(data), `"error":""`) {
+		t.Errorf("Expected no error on processed files: %s", data)
+	}
+}
+
+func TestUploadOutcomes_Check(t *testing.T) {
+	outcomes := NewUploadOutcomes()
+	outcomes.Processed("first.xlsx")
+	outcomes.Failed("corrupt.xlsx", errors.New("could not open workbook"))
+	outcomes.Processed("second.xlsx")
+
+	tests := []struct {
+		name         string
+		minProcessed int
+		failOnError  bool
+		fails        bool
+	}{
+		{"report only", 0, false, false},
+		{"enough processed", 2, false, false},
+		{"too few processed", 3, false, true},
+		{"any failed", 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := outcomes.Check(tt.minProcessed, tt.failOnError)
+			if failed := errors.Is(err, ErrUploadFilesFailed); failed != tt.fails {
+				t.Errorf("Check() = %v, want failure %v", err, tt.fails)
+			}
+		})
+	}
+
+	if err := NewUploadOutcomes().Check(0, true); err != nil {
+		t.Errorf("Expected an empty upload to pass, got %v", err)
+	}
+}
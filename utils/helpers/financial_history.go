@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownStatement = errors.New("unknown statement")
+	ErrUnknownMetric    = errors.New("unknown metric")
+)
+
+// HistoryStatements are the stored financial statements a history can be read from, with the
+// field holding their period headers
+var HistoryStatements = map[string]string{
+	"profitLoss":   "profitLossHeaders",
+	"balanceSheet": "balanceSheetHeaders",
+	"cashFlows":    "cashFlowsHeaders",
+	"ratios":       "ratiosHeaders",
+}
+
+// HistoryPoint is the value of a metric in one period, nil when the cell is blank or not a number
+type HistoryPoint struct {
+	Period string   `json:"period"`
+	Value  *float64 `json:"value"`
+}
+
+// metricValues reads the row of a metric, the expandable marker may be left out of its name
+// (e.g. "Net Profit" for "Net Profit +")
+func metricValues(stock map[string]interface{}, statement string, metric string) ([]interface{}, bool) {
+	values, err := getNestedArrayField(stock, statement, metric)
+	if err != nil && !isExpandableLabel(metric) {
+		values, err = getNestedArrayField(stock, statement, metric+" +")
+	}
+	return values, err == nil
+}
+
+// FinancialHistory returns the series of every metric of a stored statement, each value aligned
+// with its period header. Values and headers are aligned from the latest period, so a row shorter
+// than the headers (or headers missing from older documents) still ends on the latest period.
+// ErrUnknownStatement and ErrUnknownMetric are returned for what the document doesn't have.
+func FinancialHistory(stock map[string]interface{}, statement string, metrics []string) (map[string][]HistoryPoint, error) {
+	headersField, ok := HistoryStatements[statement]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStatement, statement)
+	}
+	if isEmptyScrapedValue(stock[statement]) {
+		return nil, fmt.Errorf("%w: %q is not stored for this company", ErrUnknownStatement, statement)
+	}
+
+	var periods []string
+	headers, _ := toArray(stock[headersField])
+	for _, header := range headers {
+		period, _ := header.(string)
+		periods = append(periods, period)
+	}
+
+	history := map[string][]HistoryPoint{}
+	for _, metric := range metrics {
+		metric = strings.TrimSpace(metric)
+		values, ok := metricValues(stock, statement, metric)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q in %s", ErrUnknownMetric, metric, statement)
+		}
+
+		points := make([]HistoryPoint, len(values))
+		offset := len(periods) - len(values)
+		for i, raw := range values {
+			if i+offset >= 0 {
+				points[i].Period = periods[i+offset]
+			}
+			if value, ok := parsePeerNumber(raw); ok {
+				points[i].Value = &value
+			}
+		}
+		history[metric] = points
+	}
+	return history, nil
+}
@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"errors"
+	"reflect"
+	"stockbackend/types"
+	"strconv"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// historyValues flattens the points of a series into "period=value" strings, "-" for nil values
+func historyValues(points []HistoryPoint) []string {
+	var values []string
+	for _, point := range points {
+		value := "-"
+		if point.Value != nil {
+			value = strconv.FormatFloat(*point.Value, 'f', -1, 64)
+		}
+		values = append(values, point.Period+"="+value)
+	}
+	return values
+}
+
+func TestFinancialHistory(t *testing.T) {
+	stock := map[string]interface{}{
+		"profitLoss": []types.TableRow{
+			{Label: "Sales +", Values: []string{"1,000", "1,200", "1,300"}},
+			{Label: "Net Profit +", Values: []string{"100", "", "150"}},
+			{Label: "OPM %", Values: []string{"20%", "22%"}},
+		},
+		"profitLossHeaders": []string{"Mar 2023", "Mar 2024", "TTM"},
+	}
+
+	history, err := FinancialHistory(stock, "profitLoss", []string{"Net Profit", "Sales +", "OPM %"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string][]string{
+		"Net Profit": {"Mar 2023=100", "Mar 2024=-", "TTM=150"},
+		"Sales +":    {"Mar 2023=1000", "Mar 2024=1200", "TTM=1300"},
+		// A shorter row ends on the latest period
+		"OPM %": {"Mar 2024=20", "TTM=22"},
+	}
+	for metric, values := range expected {
+		if got := historyValues(history[metric]); !reflect.DeepEqual(got, values) {
+			t.Errorf("%s: expected %v, got %v", metric, values, got)
+		}
+	}
+
+	if _, err := FinancialHistory(stock, "profitLoss", []string{"EBITDA"}); !errors.Is(err, ErrUnknownMetric) {
+		t.Errorf("Expected an unknown metric, got %v", err)
+	}
+	if _, err := FinancialHistory(stock, "quarterlyResults", []string{"Sales"}); !errors.Is(err, ErrUnknownStatement) {
+		t.Errorf("Expected an unknown statement, got %v", err)
+	}
+	if _, err := FinancialHistory(stock, "balanceSheet", []string{"Reserves"}); !errors.Is(err, ErrUnknownStatement) {
+		t.Errorf("Expected a statement that isn't stored to be unknown, got %v", err)
+	}
+}
+
+func TestFinancialHistory_StoredDocument(t *testing.T) {
+	// Nested rows as decoded from Mongo, with a regular space before the expandable marker
+	stock := map[string]interface{}{
+		"balanceSheet": primitive.A{
+			bson.M{"label": "Borrowings +", "values": primitive.A{"50", "70"}, "children": primitive.A{
+				bson.M{"label": "Lease Liabilities", "values": primitive.A{"5", "6"}},
+			}},
+		},
+	}
+	history, err := FinancialHistory(stock, "balanceSheet", []string{"Borrowings"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := historyValues(history["Borrowings"]); !reflect.DeepEqual(got, []string{"=50", "=70"}) {
+		t.Errorf("Expected the values without periods, got %v", got)
+	}
+}
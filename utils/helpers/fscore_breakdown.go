@@ -0,0 +1,82 @@
+package helpers
+
+import "strings"
+
+// Piotroski criteria as scored by GenerateFScore
+const (
+	fScorePositiveROA         = "Positive ROA"
+	fScoreHigherCashFlow      = "Higher operating cash flow"
+	fScoreHigherROA           = "Higher ROA"
+	fScoreCashFlowAboveProfit = "Operating cash flow above net profit"
+	fScoreLowerLeverage       = "Lower borrowings to total assets"
+	fScoreHigherCurrentRatio  = "Higher current ratio"
+	fScoreNoDilution          = "No new shares issued"
+	fScoreHigherMargin        = "Higher operating margin"
+	fScoreHigherAssetTurnover = "Higher asset turnover"
+)
+
+// FScoreSections are the table sections the F-Score is computed from
+var FScoreSections = []string{"profitLoss", "balanceSheet", "cashFlows"}
+
+// FScoreCriterion is the outcome of one Piotroski criterion, unavailable when the tables don't
+// hold the two years it compares
+type FScoreCriterion struct {
+	Criterion string `json:"criterion"`
+	Passed    bool   `json:"passed"`
+	Available bool   `json:"available"`
+}
+
+// FScoreBreakdown is an F-Score with the criteria behind it and the basis of the statements it was
+// computed from. The score is -1 when a required row is missing, Missing then lists it as
+// "<section>: <row>". A nil breakdown records nothing.
+type FScoreBreakdown struct {
+	Score      int               `json:"fScore"`
+	Statements string            `json:"statements"`
+	Criteria   []FScoreCriterion `json:"criteria"`
+	Missing    []string          `json:"missing,omitempty"`
+}
+
+// pass records an evaluated criterion and returns whether it passed
+func (b *FScoreBreakdown) pass(criterion string, passed bool) bool {
+	if b != nil {
+		b.Criteria = append(b.Criteria, FScoreCriterion{Criterion: criterion, Passed: passed, Available: true})
+	}
+	return passed
+}
+
+// unavailable records a criterion the data was too short to evaluate
+func (b *FScoreBreakdown) unavailable(criterion string) {
+	if b != nil {
+		b.Criteria = append(b.Criteria, FScoreCriterion{Criterion: criterion})
+	}
+}
+
+// missingRow records the row the score couldn't be computed without and returns the -1 score
+func (b *FScoreBreakdown) missingRow(section string, label string) int {
+	if b != nil {
+		b.Missing = append(b.Missing, section+": "+strings.TrimSpace(label))
+	}
+	return -1
+}
+
+// GenerateFScoreBreakdown computes the F-Score of a stock together with its per-criterion breakdown
+func GenerateFScoreBreakdown(stock map[string]interface{}) FScoreBreakdown {
+	breakdown := FScoreBreakdown{Criteria: []FScoreCriterion{}}
+	breakdown.Score = generateFScore(stock, &breakdown)
+	return breakdown
+}
+
+// MissingFScoreSections returns the F-Score sections that are absent or not tables (ordered rows or a label keyed map)
+func MissingFScoreSections(stock map[string]interface{}) []string {
+	missing := []string{}
+	for _, section := range FScoreSections {
+		if _, ok := toTableRows(stock[section]); ok {
+			continue
+		}
+		if _, ok := toMap(stock[section]); ok {
+			continue
+		}
+		missing = append(missing, section)
+	}
+	return missing
+}
@@ -0,0 +1,143 @@
+package helpers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// fScoreRequest is the fScoreFixture as it arrives in a JSON body, with regular spaces in the labels
+const fScoreRequest = `{
+	"profitLoss": [
+		{"label": "Sales +", "values": ["100", "200"]},
+		{"label": "OPM %", "values": ["10", "12"]},
+		{"label": "Net Profit +", "values": ["10", "20"]}
+	],
+	"profitLossHeaders": ["Mar 2023", "Mar 2024"],
+	"balanceSheet": [
+		{"label": "Equity Capital", "values": ["10", "10"]},
+		{"label": "Borrowings +", "values": ["50", "40"]},
+		{"label": "Other Liabilities +", "values": ["20", "20"]},
+		{"label": "Other Assets +", "values": ["30", "40"]},
+		{"label": "Total Assets", "values": ["100", "110"]}
+	],
+	"cashFlows": {"Cash from Operating Activity +": ["15", "30"]}
+}`
+
+func decodeFScoreRequest(t *testing.T) map[string]interface{} {
+	var stock map[string]interface{}
+	if err := json.Unmarshal([]byte(fScoreRequest), &stock); err != nil {
+		t.Fatalf("Error decoding request: %v", err)
+	}
+	return stock
+}
+
+func TestGenerateFScoreBreakdown(t *testing.T) {
+	breakdown := GenerateFScoreBreakdown(decodeFScoreRequest(t))
+
+	if breakdown.Score != 9 {
+		t.Errorf("Expected an F-Score of 9, got %d (%v)", breakdown.Score, breakdown.Missing)
+	}
+	if len(breakdown.Criteria) != 9 {
+		t.Fatalf("Expected the 9 criteria, got %v", breakdown.Criteria)
+	}
+	for _, criterion := range breakdown.Criteria {
+		if !criterion.Available || !criterion.Passed {
+			t.Errorf("Expected %q to pass, got %+v", criterion.Criterion, criterion)
+		}
+	}
+	if score := GenerateFScore(decodeFScoreRequest(t)); score != breakdown.Score {
+		t.Errorf("Expected GenerateFScore to agree with the breakdown, got %d", score)
+	}
+}
+
+func TestGenerateFScoreBreakdown_SingleYear(t *testing.T) {
+	stock := map[string]interface{}{
+		"profitLoss":   map[string]interface{}{"Sales +": []interface{}{"100"}, "OPM %": []interface{}{"10"}, "Net Profit +": []interface{}{"10"}},
+		"balanceSheet": map[string]interface{}{"Equity Capital": []interface{}{"10"}, "Borrowings +": []interface{}{"50"}, "Other Liabilities +": []interface{}{"20"}, "Other Assets +": []interface{}{"30"}, "Total Assets": []interface{}{"100"}},
+		"cashFlows":    map[string]interface{}{"Cash from Operating Activity +": []interface{}{"15"}},
+		// Annual values only
+		"profitLossHeaders": []interface{}{"Mar 2024"},
+	}
+
+	breakdown := GenerateFScoreBreakdown(stock)
+	if breakdown.Score != 2 {
+		t.Errorf("Expected only the single year criteria to score, got %d", breakdown.Score)
+	}
+	available := []string{}
+	for _, criterion := range breakdown.Criteria {
+		if criterion.Available {
+			available = append(available, criterion.Criterion)
+		}
+	}
+	if want := []string{fScorePositiveROA, fScoreCashFlowAboveProfit}; !reflect.DeepEqual(available, want) {
+		t.Errorf("Expected %v to be the only available criteria, got %v", want, available)
+	}
+}
+
+func TestGenerateFScoreBreakdown_MissingRow(t *testing.T) {
+	stock := decodeFScoreRequest(t)
+	stock["balanceSheet"] = stock["balanceSheet"].([]interface{})[:4]
+
+	breakdown := GenerateFScoreBreakdown(stock)
+	if breakdown.Score != -1 {
+		t.Errorf("Expected no score without total assets, got %d", breakdown.Score)
+	}
+	if !reflect.DeepEqual(breakdown.Missing, []string{"balanceSheet: Total Assets"}) {
+		t.Errorf("Expected the missing row to be reported, got %v", breakdown.Missing)
+	}
+}
+
+func TestMissingFScoreSections(t *testing.T) {
+	if missing := MissingFScoreSections(decodeFScoreRequest(t)); len(missing) != 0 {
+		t.Errorf("Expected no missing sections, got %v", missing)
+	}
+
+	stock := decodeFScoreRequest(t)
+	delete(stock, "cashFlows")
+	stock["balanceSheet"] = "not a table"
+	if missing := MissingFScoreSections(stock); !reflect.DeepEqual(missing, []string{"balanceSheet", "cashFlows"}) {
+		t.Errorf("Expected the missing and invalid sections, got %v", missing)
+	}
+}
+
+func TestGenerateFScoreBreakdown_StandaloneOnly(t *testing.T) {
+	standalone := decodeFScoreRequest(t)
+	stock := map[string]interface{}{
+		// The consolidated rows are there but blank, as on the page of a company without subsidiaries
+		"profitLoss":   []interface{}{map[string]interface{}{"label": "Net Profit +", "values": []interface{}{"", ""}}},
+		"balanceSheet": []interface{}{},
+		"cashFlows":    []interface{}{},
+		"standalone":   standalone,
+	}
+
+	breakdown := GenerateFScoreBreakdown(stock)
+	if breakdown.Score != 9 || breakdown.Statements != StatementsStandalone {
+		t.Errorf("Expected the F-Score of the standalone statements, got %+v", breakdown)
+	}
+
+	// A page scraped as standalone scores its own tables
+	standalone["statementBasis"] = StatementsStandalone
+	if breakdown := GenerateFScoreBreakdown(standalone); breakdown.Score != 9 || breakdown.Statements != StatementsStandalone {
+		t.Errorf("Expected the F-Score of the standalone page, got %+v", breakdown)
+	}
+}
+
+func TestGenerateFScoreBreakdown_NotComputable(t *testing.T) {
+	stock := decodeFScoreRequest(t)
+	standalone := decodeFScoreRequest(t)
+	delete(standalone, "cashFlows")
+	stock["standalone"] = standalone
+	stock["cashFlows"] = map[string]interface{}{"Cash from Operating Activity +": []interface{}{"15", " "}}
+
+	breakdown := GenerateFScoreBreakdown(stock)
+	if breakdown.Score != FScoreNotComputable || breakdown.Statements != StatementsConsolidated {
+		t.Errorf("Expected no F-Score without a complete statement set, got %+v", breakdown)
+	}
+	if want := []string{"cashFlows: Cash from Operating Activity +"}; !reflect.DeepEqual(breakdown.Missing, want) {
+		t.Errorf("Expected the gaps of the consolidated statements %v, got %v", want, breakdown.Missing)
+	}
+	if score := GenerateFScore(stock); score != FScoreNotComputable {
+		t.Errorf("Expected %d, got %d", FScoreNotComputable, score)
+	}
+}
@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"fmt"
+	"stockbackend/types"
+	"strings"
+	"time"
+)
+
+// AsOfDateLayout is the accepted format of the asOfDate of an upload
+const AsOfDateLayout = "2006-01-02"
+
+// ParseFundTag validates the fund fields of an upload, asOfDate defaults to now when empty
+func ParseFundTag(fundName string, amc string, asOfDate string, now time.Time) (types.FundTag, error) {
+	tag := types.FundTag{
+		FundName: strings.TrimSpace(fundName),
+		AMC:      strings.TrimSpace(amc),
+		AsOfDate: now.UTC(),
+	}
+	if asOfDate = strings.TrimSpace(asOfDate); asOfDate != "" {
+		date, err := time.Parse(AsOfDateLayout, asOfDate)
+		if err != nil {
+			return tag, fmt.Errorf("invalid asOfDate %q, expected YYYY-MM-DD", asOfDate)
+		}
+		tag.AsOfDate = date
+	}
+	return tag, nil
+}
+
+// FundHoldingFields are the fund fields added to the enriched holdings of a tagged upload, nil
+// when the upload has no fund name
+func FundHoldingFields(tag types.FundTag) map[string]interface{} {
+	if tag.FundName == "" {
+		return nil
+	}
+	fields := map[string]interface{}{
+		"fundName": tag.FundName,
+		"asOfDate": tag.AsOfDate,
+	}
+	if tag.AMC != "" {
+		fields["amc"] = tag.AMC
+	}
+	return fields
+}
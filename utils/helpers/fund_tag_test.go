@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFundTag(t *testing.T) {
+	now := time.Date(2024, 10, 5, 14, 30, 0, 0, time.UTC)
+
+	tag, err := ParseFundTag(" Flexi Cap Fund ", "Example AMC", "2024-09-30", now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tag.FundName != "Flexi Cap Fund" || tag.AMC != "Example AMC" {
+		t.Errorf("Expected the trimmed fund and AMC, got %+v", tag)
+	}
+	if !tag.AsOfDate.Equal(time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the given date, got %v", tag.AsOfDate)
+	}
+
+	tag, err = ParseFundTag("", "", "", now)
+	if err != nil || !tag.AsOfDate.Equal(now) {
+		t.Errorf("Expected asOfDate to default to now, got %v (%v)", tag.AsOfDate, err)
+	}
+
+	for _, date := range []string{"30/09/2024", "2024-9-30", "2024-02-30", "yesterday"} {
+		if _, err := ParseFundTag("Flexi Cap Fund", "", date, now); err == nil {
+			t.Errorf("Expected an error for %q", date)
+		}
+	}
+}
+
+func TestFundHoldingFields(t *testing.T) {
+	now := time.Now()
+	untagged, _ := ParseFundTag("", "Example AMC", "", now)
+	if fields := FundHoldingFields(untagged); fields != nil {
+		t.Errorf("Expected no fields without a fund name, got %v", fields)
+	}
+
+	tagged, _ := ParseFundTag("Flexi Cap Fund", "", "2024-09-30", now)
+	fields := FundHoldingFields(tagged)
+	if fields["fundName"] != "Flexi Cap Fund" || fields["asOfDate"] != tagged.AsOfDate {
+		t.Errorf("Expected the fund name and date, got %v", fields)
+	}
+	if _, ok := fields["amc"]; ok {
+		t.Errorf("Expected no empty amc, got %v", fields["amc"])
+	}
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"fmt"
+	"stockbackend/config"
+)
+
+// Points of the fundamentals fallback, on the scale of the peer comparison against a single peer
+const (
+	fundamentalsPEPoints        = 10
+	fundamentalsExpensivePoints = -5
+	fundamentalsLossPoints      = -10
+	fundamentalsROCEPoints      = 10
+	fundamentalsDividendPoints  = 5
+)
+
+// peersAvailable reports whether the peers of a stock could be fetched: FETCH_PEERS is on and its
+// page had a warehouse id to fetch them by
+func peersAvailable(stock map[string]interface{}) bool {
+	unavailable, _ := stock["peersUnavailable"].(bool)
+	return config.Get().FetchPeers && !unavailable
+}
+
+// hasPeerComparison reports whether the stock has something to stand in for the peer comparison:
+// stored peers (or their median row), or the F-Score when the peers are unavailable
+func hasPeerComparison(stock map[string]interface{}) bool {
+	if !peersAvailable(stock) {
+		return GenerateFScore(stock) >= 0
+	}
+	peers, medianRow := SplitPeers(stock["peers"])
+	return len(peers) > 0 || medianRow != nil
+}
+
+// hasQuarterlyHistory reports whether the quarterly results have two consecutive numeric quarters
+// of a metric the trend looks at, i.e. whether AnalyzeTrend has anything to compare
+func hasQuarterlyHistory(pastData interface{}) bool {
+	data, ok := toMap(pastData)
+	if !ok {
+		return false
+	}
+	directions := TrendMetricDirections()
+	for metric, quarterData := range data {
+		if direction := directions[NormalizeMetricName(metric)]; direction == 0 {
+			continue
+		}
+		series := quarterSeries(quarterData)
+		for i := 1; i < len(series); i++ {
+			if series[i] != nil && series[i-1] != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasInsufficientData reports whether a stock has neither peers to compare with nor quarterly
+// history to follow, newly listed and thinly covered companies. Their score is computed from
+// the single point fundamentals (see fundamentalsScore) and flagged as low confidence.
+func HasInsufficientData(stock map[string]interface{}) bool {
+	return !hasPeerComparison(stock) && !hasQuarterlyHistory(stock["quarterlyResults"])
+}
+
+// fundamentalsScore scores the stock's own PE, ROCE and dividend yield against absolute
+// thresholds: a PE up to FUNDAMENTALS_MAX_PE, a ROCE of at least FUNDAMENTALS_MIN_ROCE and a
+// dividend yield within DIVIDEND_YIELD_MIN..DIVIDEND_YIELD_MAX. Loss makers (negative PE or
+// ROCE) lose points, fundamentals that weren't scraped are left out.
+func fundamentalsScore(stock map[string]interface{}, explanation *scoreExplanation) float64 {
+	cfg := config.Get()
+	score := 0.0
+	award := func(points float64, reason string) {
+		score += points
+		explanation.add(points, reason)
+	}
+
+	if pe, ok := parsePeerNumber(stock["stockPE"]); ok {
+		switch {
+		case pe <= 0:
+			award(fundamentalsLossPoints, "Negative PE, loss making")
+		case pe <= cfg.FundamentalsMaxPE:
+			award(fundamentalsPEPoints, fmt.Sprintf("PE at or below %g", cfg.FundamentalsMaxPE))
+		default:
+			award(fundamentalsExpensivePoints, fmt.Sprintf("PE above %g", cfg.FundamentalsMaxPE))
+		}
+	}
+	if roce, ok := parsePeerNumber(stock["roce"]); ok {
+		switch {
+		case roce < 0:
+			award(fundamentalsLossPoints, "Negative ROCE")
+		case roce >= cfg.FundamentalsMinROCE:
+			award(fundamentalsROCEPoints, fmt.Sprintf("ROCE at or above %g", cfg.FundamentalsMinROCE))
+		}
+	}
+	if dividendYield, ok := parsePeerNumber(stock["dividendYield"]); ok && dividendYield > 0 && dividendYieldInBand(dividendYield) {
+		award(fundamentalsDividendPoints, "Dividend yield within band")
+	}
+	return score
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/config"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestRateStockDetailed_InsufficientData(t *testing.T) {
+	// Newly listed: no peers and a single quarter of results
+	stock := map[string]interface{}{
+		"name":          "Example Ltd",
+		"stockPE":       "18",
+		"roce":          "22",
+		"dividendYield": "1.5",
+		"peers":         primitive.A{},
+		"quarterlyResults": quarterlyFixture(map[string][]string{
+			"Net Profit +": {"16"},
+		}),
+	}
+
+	rating := RateStockDetailed(stock, nil)
+	if !rating.InsufficientData {
+		t.Errorf("Expected the rating to be flagged as insufficient data")
+	}
+	if rating.Raw != 22.5 {
+		t.Errorf("Expected the fundamentals to carry the peer and trend weights, got %v", rating.Raw)
+	}
+	expected := []string{"PE at or below 25: +9.00", "ROCE at or above 15: +9.00", "Dividend yield within band: +4.50"}
+	if !reflect.DeepEqual(rating.Reasons, expected) {
+		t.Errorf("Expected %v, got %v", expected, rating.Reasons)
+	}
+	if RateStock(stock) != rating.Rate {
+		t.Errorf("Expected RateStock to match the detailed rating, got %v and %v", RateStock(stock), rating.Rate)
+	}
+
+	// A loss maker without peers or history still rates below a profitable one
+	loss := map[string]interface{}{"name": "Loss Ltd", "stockPE": "-4", "roce": "-3"}
+	lossRating := RateStockDetailed(loss, nil)
+	if !lossRating.InsufficientData || lossRating.Raw != -18 {
+		t.Errorf("Expected an insufficient data rating of -18, got %+v", lossRating)
+	}
+
+	// Nothing scraped at all rates 0, still flagged
+	if empty := RateStockDetailed(map[string]interface{}{"name": "Example Ltd"}, nil); !empty.InsufficientData || empty.Raw != 0 || len(empty.Reasons) != 0 {
+		t.Errorf("Expected an empty insufficient data rating, got %+v", empty)
+	}
+}
+
+func TestRateStockDetailed_FundamentalsThresholds(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"FUNDAMENTALS_MAX_PE": "15", "FUNDAMENTALS_MIN_ROCE": "25", "DIVIDEND_YIELD_MAX": "1"}))
+
+	stock := map[string]interface{}{"name": "Example Ltd", "stockPE": "18", "roce": "22", "dividendYield": "1.5"}
+	rating := RateStockDetailed(stock, nil)
+	if rating.Raw != -4.5 || !reflect.DeepEqual(rating.Reasons, []string{"PE above 15: -4.50"}) {
+		t.Errorf("Expected only the PE above the threshold to count, got %v %v", rating.Raw, rating.Reasons)
+	}
+}
+
+func TestRateStockDetailed_SufficientData(t *testing.T) {
+	withPeers := map[string]interface{}{
+		"name":    "Example Ltd",
+		"stockPE": "10",
+		"peers":   primitive.A{bson.M{"name": "Peer Ltd", "pe": "20"}},
+	}
+	withHistory := map[string]interface{}{
+		"name":    "Example Ltd",
+		"stockPE": "10",
+		"quarterlyResults": quarterlyFixture(map[string][]string{
+			"Sales +": {"10", "12"},
+		}),
+	}
+	for name, stock := range map[string]map[string]interface{}{"peers": withPeers, "quarterly history": withHistory} {
+		if rating := RateStockDetailed(stock, nil); rating.InsufficientData {
+			t.Errorf("Expected a stock with %s to have sufficient data, got %+v", name, rating)
+		}
+	}
+
+	// Without peers fetched, the F-Score stands in for them
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"FETCH_PEERS": "false"}))
+	stock := decodeFScoreRequest(t)
+	stock["name"] = "Example Ltd"
+	if HasInsufficientData(stock) {
+		t.Errorf("Expected the F-Score to count as data when peers aren't fetched")
+	}
+	delete(stock, "profitLoss")
+	if !HasInsufficientData(stock) {
+		t.Errorf("Expected insufficient data without the F-Score, peers or history")
+	}
+}
@@ -0,0 +1,157 @@
+package helpers
+
+import (
+	"sort"
+	"stockbackend/types"
+	"strings"
+	"unicode"
+)
+
+// companyNameAbbreviations maps the spellings used by AMC sheets and by the stored names onto one form
+var companyNameAbbreviations = map[string]string{
+	"corporation":   "corp",
+	"corpn":         "corp",
+	"industries":    "inds",
+	"international": "intl",
+	"technologies":  "tech",
+}
+
+// companyNameNoise are words carrying nothing about which company a name refers to
+var companyNameNoise = map[string]bool{
+	"the":     true,
+	"and":     true,
+	"ltd":     true,
+	"limited": true,
+}
+
+// NormalizeCompanyName lowercases a company name, drops punctuation (including "&") and filler words and unifies
+// common abbreviations, e.g. "Sun Pharmaceutical Industries Limited" becomes "sun pharmaceutical inds"
+func NormalizeCompanyName(name string) string {
+	var tokens []string
+	for _, token := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if companyNameNoise[token] {
+			continue
+		}
+		if abbreviation, ok := companyNameAbbreviations[token]; ok {
+			token = abbreviation
+		}
+		tokens = append(tokens, token)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// levenshtein returns the number of single character edits turning a into b
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// levenshteinRatio scales the edit distance to a similarity between 0 and 1
+func levenshteinRatio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// tokenSetRatio compares two names with their words sorted, the shared words first, so the
+// word order doesn't count as a difference
+func tokenSetRatio(a, b string) float64 {
+	setA := map[string]bool{}
+	for _, token := range strings.Fields(a) {
+		setA[token] = true
+	}
+	setB := map[string]bool{}
+	for _, token := range strings.Fields(b) {
+		setB[token] = true
+	}
+
+	var common, onlyA, onlyB []string
+	for token := range setA {
+		if setB[token] {
+			common = append(common, token)
+		} else {
+			onlyA = append(onlyA, token)
+		}
+	}
+	for token := range setB {
+		if !setA[token] {
+			onlyB = append(onlyB, token)
+		}
+	}
+	sort.Strings(common)
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+
+	intersection := strings.Join(common, " ")
+	combinedA := strings.TrimSpace(intersection + " " + strings.Join(onlyA, " "))
+	combinedB := strings.TrimSpace(intersection + " " + strings.Join(onlyB, " "))
+
+	// Unlike the classic token set ratio the intersection alone is not compared, it would
+	// make "ITC" a perfect match for "ITC Hotels"
+	return levenshteinRatio(combinedA, combinedB)
+}
+
+// NameSimilarity scores how alike two company names are, from 0 (nothing in common) to 1 (same normalized name)
+func NameSimilarity(a, b string) float64 {
+	a, b = NormalizeCompanyName(a), NormalizeCompanyName(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	return max(levenshteinRatio(a, b), tokenSetRatio(a, b))
+}
+
+// BestFuzzyMatch returns the candidate most similar to the name and its similarity,
+// or an empty name when there are no candidates
+func BestFuzzyMatch(name string, candidates []string) (string, float64) {
+	best, bestSimilarity := "", 0.0
+	for _, candidate := range candidates {
+		if similarity := NameSimilarity(name, candidate); similarity > bestSimilarity {
+			best, bestSimilarity = candidate, similarity
+		}
+	}
+	return best, bestSimilarity
+}
+
+// TopFuzzyMatches returns up to limit candidates resembling the name, the most similar first (ties
+// by name). Candidates with nothing in common are left out.
+func TopFuzzyMatches(name string, candidates []string, limit int) []types.FuzzyCandidate {
+	matches := []types.FuzzyCandidate{}
+	for _, candidate := range candidates {
+		if similarity := NameSimilarity(name, candidate); similarity > 0 {
+			matches = append(matches, types.FuzzyCandidate{Name: candidate, Similarity: similarity})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].Name < matches[j].Name
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	for i := range matches {
+		matches[i].Similarity = Round(matches[i].Similarity)
+	}
+	return matches
+}
@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/types"
+	"testing"
+)
+
+func TestNormalizeCompanyName(t *testing.T) {
+	tests := map[string]string{
+		"Sun Pharmaceutical Industries Limited": "sun pharmaceutical inds",
+		"Larsen & Toubro Ltd.":                  "larsen toubro",
+		"The Indian Hotels Company Ltd":         "indian hotels company",
+		"Container Corporation of India Ltd":    "container corp of india",
+	}
+	for input, expected := range tests {
+		if result := NormalizeCompanyName(input); result != expected {
+			t.Errorf("NormalizeCompanyName(%q): expected %q, got %q", input, expected, result)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	if similarity := NameSimilarity("Larsen & Toubro Limited", "Larsen and Toubro Ltd"); similarity != 1 {
+		t.Errorf("Expected spelling variants to match exactly, got %v", similarity)
+	}
+	if similarity := NameSimilarity("Toubro Larsen", "Larsen & Toubro"); similarity < 0.85 {
+		t.Errorf("Expected word order to barely matter, got %v", similarity)
+	}
+	if similarity := NameSimilarity("Bharti Airtl Ltd", "Bharti Airtel Limited"); similarity < 0.85 {
+		t.Errorf("Expected a typo to stay similar, got %v", similarity)
+	}
+	if similarity := NameSimilarity("ITC Ltd", "ITC Hotels Ltd"); similarity >= 0.85 {
+		t.Errorf("Expected a name contained in another not to match, got %v", similarity)
+	}
+	if similarity := NameSimilarity("", "Infosys Ltd"); similarity != 0 {
+		t.Errorf("Expected 0 for an empty name, got %v", similarity)
+	}
+}
+
+func TestBestFuzzyMatch(t *testing.T) {
+	candidates := []string{"Tata Steel", "Tata Motors", "Tata Consultancy Services"}
+
+	name, similarity := BestFuzzyMatch("Tata Motor Ltd", candidates)
+	if name != "Tata Motors" || similarity < 0.85 {
+		t.Errorf("Expected Tata Motors, got %q (%v)", name, similarity)
+	}
+
+	if name, similarity := BestFuzzyMatch("Tata Motors", nil); name != "" || similarity != 0 {
+		t.Errorf("Expected no match without candidates, got %q (%v)", name, similarity)
+	}
+}
+
+func TestTopFuzzyMatches(t *testing.T) {
+	candidates := []string{"Tata Steel", "Tata Motors", "Tata Consultancy Services", "Infosys", "Tata Motors DVR"}
+
+	matches := TopFuzzyMatches("Tata Motor Ltd", candidates, 2)
+	if len(matches) != 2 || matches[0].Name != "Tata Motors" || matches[1].Name != "Tata Motors DVR" {
+		t.Fatalf("Expected the two Tata Motors names first, got %+v", matches)
+	}
+	if matches[0].Similarity < matches[1].Similarity || matches[0].Similarity != Round(matches[0].Similarity) {
+		t.Errorf("Expected rounded similarities, the most similar first, got %+v", matches)
+	}
+
+	if matches := TopFuzzyMatches("Zydus Lifesciences", []string{"ABB"}, 3); !reflect.DeepEqual(matches, []types.FuzzyCandidate{}) {
+		t.Errorf("Expected no candidate with nothing in common, got %+v", matches)
+	}
+}
@@ -0,0 +1,158 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"stockbackend/config"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// GrowthSummary holds screener's compounded growth tables, e.g. {"compoundedSalesGrowth": {"10yr": 12,
+// "5yr": 10, "3yr": 8, "1yr": 5}}, in whole percent. Periods a table leaves blank are left out.
+type GrowthSummary map[string]map[string]float64
+
+// Growth periods of a GrowthSummary table
+const (
+	Growth10Years = "10yr"
+	Growth5Years  = "5yr"
+	Growth3Years  = "3yr"
+	Growth1Year   = "1yr"
+)
+
+// growthTables maps the headings of the growth tables onto their GrowthSummary keys
+var growthTables = map[string]string{
+	"compounded sales growth":  "compoundedSalesGrowth",
+	"compounded profit growth": "compoundedProfitGrowth",
+	"stock price cagr":         "stockPriceCagr",
+	"return on equity":         "returnOnEquity",
+}
+
+// growthPeriodPattern reads the period of a growth table row: "10 Years:", "3 Years:" or the
+// latest year, labelled "TTM:" in the growth tables, "1 Year:" for the price and "Last Year:" for ROE
+var growthPeriodPattern = regexp.MustCompile(`^(?:(10|5|3|1)\s*years?|ttm|last\s*year)\s*:?$`)
+
+// growthSummarySelector finds the growth tables below the profit & loss table
+const growthSummarySelector = "section#profit-loss table.ranges-table"
+
+// ParseGrowthSummary parses the compounded growth tables of a screener company page, empty when
+// the page has none
+func ParseGrowthSummary(doc *goquery.Document) GrowthSummary {
+	summary := GrowthSummary{}
+	doc.Find(growthSummarySelector).Each(func(i int, table *goquery.Selection) {
+		heading := strings.Join(strings.Fields(NormalizeString(table.Find("th").First().Text())), " ")
+		key, ok := growthTables[heading]
+		if !ok {
+			return
+		}
+		rates := map[string]float64{}
+		table.Find("tr").Each(func(i int, row *goquery.Selection) {
+			cells := row.Find("td")
+			if cells.Length() < 2 {
+				return
+			}
+			label := strings.Join(strings.Fields(NormalizeString(cells.First().Text())), " ")
+			match := growthPeriodPattern.FindStringSubmatch(label)
+			if match == nil {
+				return
+			}
+			period := Growth1Year
+			if match[1] != "" {
+				period = match[1] + "yr"
+			}
+			if rate, ok := parsePeerNumber(strings.TrimSpace(cells.Eq(1).Text())); ok {
+				rates[period] = rate
+			}
+		})
+		if len(rates) > 0 {
+			summary[key] = rates
+		}
+	})
+	return summary
+}
+
+// growthRate reads a period of a growth table from a stored growthSummary, as parsed or as
+// decoded from Mongo
+func growthRate(summary interface{}, table, period string) (float64, bool) {
+	if parsed, ok := summary.(GrowthSummary); ok {
+		rate, ok := parsed[table][period]
+		return rate, ok
+	}
+	tables, ok := toMap(summary)
+	if !ok {
+		return 0, false
+	}
+	rates, ok := toMap(tables[table])
+	if !ok {
+		return 0, false
+	}
+	switch rate := rates[period].(type) {
+	case float64:
+		return rate, true
+	case int32:
+		return float64(rate), true
+	case int64:
+		return float64(rate), true
+	case int:
+		return float64(rate), true
+	}
+	return 0, false
+}
+
+// computedCAGR is the compounded annual growth (%) of a yearly row over its last years, at most
+// 3, reporting false when there are fewer than two years or an end isn't positive
+func computedCAGR(stock map[string]interface{}, label string) (float64, bool) {
+	values, err := getAnnualArrayField(stock, "profitLoss", label)
+	if err != nil || len(values) < 2 {
+		return 0, false
+	}
+	years := min(len(values)-1, 3)
+	first, firstOk := parsePeerNumber(values[len(values)-1-years])
+	last, lastOk := parsePeerNumber(values[len(values)-1])
+	if !firstOk || !lastOk || first <= 0 || last <= 0 {
+		return 0, false
+	}
+	return (math.Pow(last/first, 1/float64(years)) - 1) * 100, true
+}
+
+// growthMetrics are the growths the rating scores, with the growth table read and the profit &
+// loss row the CAGR is computed from when the page had no table
+var growthMetrics = []struct{ name, table, row string }{
+	{"sales", "compoundedSalesGrowth", "Sales +"},
+	{"profit", "compoundedProfitGrowth", "Net Profit +"},
+}
+
+// GrowthWeight returns the weight of the growth component in the final score (GROWTH_WEIGHT)
+func GrowthWeight() float64 {
+	return config.Get().GrowthWeight
+}
+
+// growthScore rewards the 3 year compounded sales and profit growth, the 5 year one when the
+// table has no 3 year period, and computes it from the profit & loss rows when the page had no
+// growth tables: 5 points per growth at or above GROWTH_STRONG_PERCENT, -5 per shrinking one
+func growthScore(stock map[string]interface{}, explanation *scoreExplanation) float64 {
+	score := 0.0
+	for _, metric := range growthMetrics {
+		rate, ok := growthRate(stock["growthSummary"], metric.table, Growth3Years)
+		if !ok {
+			rate, ok = growthRate(stock["growthSummary"], metric.table, Growth5Years)
+		}
+		if !ok {
+			rate, ok = computedCAGR(stock, metric.row)
+		}
+		if !ok {
+			continue
+		}
+		switch {
+		case rate >= config.Get().GrowthStrongPercent:
+			score += 5
+			explanation.add(5, fmt.Sprintf("Strong compounded %s growth of %v%%", metric.name, Round(rate)))
+		case rate < 0:
+			score -= 5
+			explanation.add(-5, fmt.Sprintf("Shrinking compounded %s of %v%%", metric.name, Round(rate)))
+		}
+	}
+	return score
+}
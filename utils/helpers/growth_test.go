@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/types"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const growthPage = `<section id="profit-loss">
+  <table class="ranges-table">
+    <tr><th colspan="2">Compounded Sales Growth</th></tr>
+    <tr><td>10 Years:</td><td>12%</td></tr>
+    <tr><td>5 Years:</td><td>10%</td></tr>
+    <tr><td>3 Years:</td><td>8%</td></tr>
+    <tr><td>TTM:</td><td>5%</td></tr>
+  </table>
+  <table class="ranges-table">
+    <tr><th colspan="2">Compounded Profit Growth</th></tr>
+    <tr><td>10 Years:</td><td></td></tr>
+    <tr><td>5 Years:</td><td>-3%</td></tr>
+    <tr><td>3 Years:</td><td>1,200%</td></tr>
+  </table>
+  <table class="ranges-table">
+    <tr><th colspan="2">Stock Price CAGR</th></tr>
+    <tr><td>1 Year:</td><td>-7%</td></tr>
+  </table>
+  <table class="ranges-table">
+    <tr><th colspan="2">Return on Equity</th></tr>
+    <tr><td>Last Year:</td><td>18%</td></tr>
+  </table>
+  <table class="ranges-table">
+    <tr><th colspan="2">Dividend Growth</th></tr>
+    <tr><td>3 Years:</td><td>4%</td></tr>
+  </table>
+</section>
+<table class="ranges-table">
+  <tr><th colspan="2">Compounded Sales Growth</th></tr>
+  <tr><td>1 Year:</td><td>99%</td></tr>
+</table>`
+
+func TestParseGrowthSummary(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(growthPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := GrowthSummary{
+		"compoundedSalesGrowth":  {Growth10Years: 12, Growth5Years: 10, Growth3Years: 8, Growth1Year: 5},
+		"compoundedProfitGrowth": {Growth5Years: -3, Growth3Years: 1200},
+		"stockPriceCagr":         {Growth1Year: -7},
+		"returnOnEquity":         {Growth1Year: 18},
+	}
+	if summary := ParseGrowthSummary(doc); !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Expected %v, got %v", expected, summary)
+	}
+
+	empty, _ := goquery.NewDocumentFromReader(strings.NewReader(`<section id="profit-loss"></section>`))
+	if summary := ParseGrowthSummary(empty); len(summary) != 0 {
+		t.Errorf("Expected no growth without the tables, got %v", summary)
+	}
+}
+
+func TestGrowthScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		stock   map[string]interface{}
+		score   float64
+		reasons []string
+	}{
+		{
+			"3 year growth",
+			map[string]interface{}{"growthSummary": GrowthSummary{
+				"compoundedSalesGrowth":  {Growth5Years: -4, Growth3Years: 15},
+				"compoundedProfitGrowth": {Growth3Years: -2},
+			}},
+			0,
+			[]string{"Strong compounded sales growth of 15%", "Shrinking compounded profit of -2%"},
+		},
+		{
+			"5 year fallback",
+			map[string]interface{}{"growthSummary": GrowthSummary{
+				"compoundedSalesGrowth":  {Growth10Years: -1, Growth5Years: 10},
+				"compoundedProfitGrowth": {Growth5Years: 6},
+			}},
+			5,
+			[]string{"Strong compounded sales growth of 10%"},
+		},
+		{
+			"mongo decoded summary",
+			map[string]interface{}{"growthSummary": bson.M{
+				"compoundedSalesGrowth":  bson.M{Growth3Years: int32(20)},
+				"compoundedProfitGrowth": bson.M{Growth3Years: 11.5},
+			}},
+			10,
+			[]string{"Strong compounded sales growth of 20%", "Strong compounded profit growth of 11.5%"},
+		},
+		{
+			// (200/90)^(1/3) - 1 over the 3 years between 4 columns; profit starts with a loss
+			"computed without tables",
+			map[string]interface{}{
+				"profitLoss": []types.TableRow{
+					row("Sales +", "90", "100", "150", "200"),
+					row("Net Profit +", "-5", "10", "12", "14"),
+				},
+				"profitLossHeaders": []string{"Mar 2021", "Mar 2022", "Mar 2023", "Mar 2024"},
+			},
+			5,
+			[]string{"Strong compounded sales growth of 30.5%"},
+		},
+		{"no data", map[string]interface{}{}, 0, nil},
+	}
+	for _, test := range tests {
+		explanation := &scoreExplanation{}
+		if score := growthScore(test.stock, explanation); score != test.score {
+			t.Errorf("%s: expected %v, got %v", test.name, test.score, score)
+		}
+		var reasons []string
+		for _, item := range explanation.reasons {
+			reasons = append(reasons, item.text)
+		}
+		if !reflect.DeepEqual(reasons, test.reasons) {
+			t.Errorf("%s: expected reasons %v, got %v", test.name, test.reasons, reasons)
+		}
+	}
+}
@@ -1,21 +1,26 @@
 package helpers
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"net/http"
-	"os"
 	"regexp"
+	"sort"
 	"stockbackend/clients/http_client"
+	"stockbackend/clients/tracing"
+	"stockbackend/config"
 	"stockbackend/types"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -48,37 +53,31 @@ func CheckInstrumentName(input string) bool {
 	return re.MatchString(input)
 }
 
+// ToFloat converts a scraped string to a number, 0 when it doesn't parse
 func ToFloat(value interface{}) float64 {
+	f, err := toFloat(value)
+	if err != nil {
+		zap.L().Error("Error converting to float64", zap.Error(err))
+		return 0.0
+	}
+	return f
+}
+
+// toFloat is ToFloat reporting the values that don't parse, non-string values are 0. Percentages
+// are whole percent throughout, as the scrape stores them: "12.3%" and "12.3" both read 12.3.
+func toFloat(value interface{}) (float64, error) {
 	if str, ok := value.(string); ok {
-		// Remove commas from the string
-		cleanStr := strings.ReplaceAll(str, ",", "")
-
-		// Check if the string contains a percentage symbol
-		if strings.Contains(cleanStr, "%") {
-			// Remove the percentage symbol
-			cleanStr = strings.ReplaceAll(cleanStr, "%", "")
-			// Convert to float and divide by 100 to get the decimal equivalent
-			f, err := strconv.ParseFloat(cleanStr, 64)
-			if err != nil {
-				zap.L().Error("Error converting to float64", zap.Error(err))
-				return 0.0
-			}
-			return f / 100.0
-		}
+		cleanStr := strings.TrimSpace(strings.ReplaceAll(str, "%", ""))
 
-		// Parse the cleaned string to float
-		f, err := strconv.ParseFloat(cleanStr, 64)
-		if err != nil {
-			zap.L().Error("Error converting to float64", zap.Error(err))
-			return 0.0
-		}
-		return f
+		// Parse the string according to the configured number format
+		return ParseNumber(cleanStr, CurrentNumberFormat())
 	}
-	return 0.0
+	return 0.0, nil
 }
 
+// ToStringArray reads the strings of a list, stored (primitive.A) or freshly scraped ([]string)
 func ToStringArray(value interface{}) []string {
-	if arr, ok := value.(primitive.A); ok {
+	if arr, ok := toArray(value); ok {
 		var strArr []string
 		for _, v := range arr {
 			if str, ok := v.(string); ok {
@@ -98,20 +97,61 @@ func GetMarketCapCategory(marketCapValue string) string {
 	if err != nil {
 		zap.L().Error("Failed to convert market cap to integer: ", zap.Any("error", err.Error()))
 	}
-	// Define market cap categories in crore (or billions as per comment)
-	if marketCap >= 20000 {
-		return "Large Cap"
-	} else if marketCap >= 5000 && marketCap < 20000 {
-		return "Mid Cap"
-	} else if marketCap < 5000 {
-		return "Small Cap"
-	}
-	return "Unknown Category"
+	// Market caps are scraped in crore, MARKET_CAP_TIERS may be in another unit or currency
+	return MarketCapCategory(marketCap, config.Get().MarketCapTiers, config.Get().MarketCapUnitRate)
 }
 
 // rateStock calculates the final stock rating
 
 func RateStock(stock map[string]interface{}) float64 {
+	return RateStockWithSector(stock, nil)
+}
+
+// RateStockExplained calculates the final stock rating along with the reasons behind it,
+// e.g. "PE below peer median: +0.83", the dominant factors first
+func RateStockExplained(stock map[string]interface{}) (float64, []string) {
+	return RateStockWithSectorExplained(stock, nil)
+}
+
+// RateStockWithSector calculates the final stock rating, adding the sector benchmark
+// component when a benchmark is available and SECTOR_BENCHMARK_WEIGHT is set
+func RateStockWithSector(stock map[string]interface{}, benchmark *SectorBenchmark) float64 {
+	raw, _ := rateStock(stock, benchmark, nil)
+	return ClampStockRate(raw)
+}
+
+// RateStockWithSectorExplained is RateStockWithSector returning the reasons behind the score
+func RateStockWithSectorExplained(stock map[string]interface{}, benchmark *SectorBenchmark) (float64, []string) {
+	rating := RateStockDetailed(stock, benchmark)
+	return rating.Rate, rating.Reasons
+}
+
+// StockRating is a stock rating clamped into [STOCK_RATE_MIN, STOCK_RATE_MAX] with the raw score
+// it was clamped from and the reasons behind it. InsufficientData marks a low confidence rating,
+// computed from the fundamentals alone for lack of peers and quarterly history.
+type StockRating struct {
+	Rate             float64
+	Raw              float64
+	Reasons          []string
+	InsufficientData bool
+}
+
+// RateStockDetailed rates a stock like RateStockWithSectorExplained, keeping the unclamped score
+func RateStockDetailed(stock map[string]interface{}, benchmark *SectorBenchmark) StockRating {
+	explanation := &scoreExplanation{}
+	raw, insufficientData := rateStock(stock, benchmark, explanation)
+	return StockRating{Rate: ClampStockRate(raw), Raw: raw, Reasons: explanation.lines(), InsufficientData: insufficientData}
+}
+
+// ClampStockRate bounds a raw rating to the [STOCK_RATE_MIN, STOCK_RATE_MAX] scale (default -10 to 35)
+func ClampStockRate(raw float64) float64 {
+	cfg := config.Get()
+	return math.Min(cfg.StockRateMax, math.Max(cfg.StockRateMin, raw))
+}
+
+// rateStock combines the score components into the raw, unclamped, rating, recording their weighted contributions in the explanation when one is given.
+// Without peers and quarterly history the fundamentals take both of their weights, which is reported as insufficient data.
+func rateStock(stock map[string]interface{}, benchmark *SectorBenchmark, explanation *scoreExplanation) (float64, bool) {
 	// zap.L().Info("Stock data", zap.Any("stock", stock))
 	stockData := types.Stock{
 		Name:          stock["name"].(string),
@@ -124,31 +164,114 @@ func RateStock(stock map[string]interface{}) float64 {
 	}
 	// zap.L().Info("Stock data", zap.Any("stock", stockData))
 	// zap.L().Info("Stock data", zap.Any("stock", stockData))
-	peerComparisonScore := compareWithPeers(stockData, stock["peers"]) * 0.5
-	trendScore := AnalyzeTrend(stockData, stock["quarterlyResults"]) * 0.4
-	// prosConsScore := prosConsAdjustment(stock) * 0.1
-	// zap.L().Info("Peer comparison score", zap.Float64("peerComparisonScore", peerComparisonScore))
+	component := func() *scoreExplanation {
+		if explanation == nil {
+			return nil
+		}
+		return &scoreExplanation{}
+	}
 
-	finalScore := peerComparisonScore + trendScore
-	finalScore = math.Round(finalScore*100) / 100
-	return finalScore
+	finalScore := 0.0
+	insufficientData := HasInsufficientData(stock)
+	if insufficientData {
+		fundamentalsReasons := component()
+		fundamentalsWeight := peerComparisonWeight + trendScoreWeight
+		finalScore += fundamentalsScore(stock, fundamentalsReasons) * fundamentalsWeight
+		explanation.merge(fundamentalsReasons, fundamentalsWeight)
+	} else {
+		trendWeight := trendScoreWeight
+		if peersAvailable(stock) {
+			peerReasons := component()
+			finalScore += compareWithPeers(stockData, stock["peers"], peerReasons) * peerComparisonWeight
+			explanation.merge(peerReasons, peerComparisonWeight)
+		} else {
+			// Without peers (FETCH_PEERS=false or no warehouse id on the page) their weight goes to the
+			// F-Score, or to the trend when it can't be computed
+			fScoreReasons := component()
+			if score, ok := fScoreComponent(stock, fScoreReasons); ok {
+				finalScore += score * peerComparisonWeight
+				explanation.merge(fScoreReasons, peerComparisonWeight)
+			} else {
+				trendWeight += peerComparisonWeight
+			}
+		}
+
+		trendReasons := component()
+		finalScore += analyzeTrend(stockData, stock["quarterlyResults"], trendReasons) * trendWeight
+		explanation.merge(trendReasons, trendWeight)
+	}
+
+	if benchmark != nil {
+		sectorReasons := component()
+		finalScore += compareWithSector(stockData, *benchmark, sectorReasons) * SectorBenchmarkWeight()
+		explanation.merge(sectorReasons, SectorBenchmarkWeight())
+	}
+	shareholdingReasons := component()
+	finalScore += shareholdingScore(AnalyzeShareholding(stock["shareholdingPattern"]), shareholdingReasons) * ShareholdingWeight()
+	explanation.merge(shareholdingReasons, ShareholdingWeight())
+	workingCapitalReasons := component()
+	finalScore += workingCapitalScore(AnalyzeWorkingCapital(ParseRatioSeries(stock["ratios"], stock["ratiosHeaders"])), workingCapitalReasons) * WorkingCapitalWeight()
+	explanation.merge(workingCapitalReasons, WorkingCapitalWeight())
+	growthReasons := component()
+	finalScore += growthScore(stock, growthReasons) * GrowthWeight()
+	explanation.merge(growthReasons, GrowthWeight())
+	prosConsReasons := component()
+	finalScore += prosConsScore(stockData, prosConsReasons) * ProsConsWeight()
+	explanation.merge(prosConsReasons, ProsConsWeight())
+	finalScore = Round(finalScore)
+	return finalScore, insufficientData
+}
+
+// Weights of the peer comparison and quarterly trend components of the rating
+const (
+	peerComparisonWeight = 0.5
+	trendScoreWeight     = 0.4
+)
+
+// fScorePoints scores each F-Score point so the F-Score (0 to 9) spans the points a stock earns
+// against a single peer (up to 45), it stands in for the peer comparison when FETCH_PEERS is false
+const fScorePoints = 5
+
+// fScoreComponent scores the F-Score of a stock, reporting false when it can't be computed
+func fScoreComponent(stock map[string]interface{}, explanation *scoreExplanation) (float64, bool) {
+	fScore := GenerateFScore(stock)
+	if fScore < 0 {
+		return 0, false
+	}
+	score := float64(fScore * fScorePoints)
+	explanation.add(score, fmt.Sprintf("F-Score of %d", fScore))
+	return score, true
+}
+
+// dividendYieldInBand reports whether a dividend yield is within DIVIDEND_YIELD_MIN..DIVIDEND_YIELD_MAX,
+// only such yields score above peers: an outlier-high yield more often follows a falling price
+// (a dividend trap) than a strong payout
+func dividendYieldInBand(dividendYield float64) bool {
+	cfg := config.Get()
+	return dividendYield >= cfg.DividendYieldMin && dividendYield <= cfg.DividendYieldMax
 }
 
 // compareWithPeers calculates a peer comparison score
-func compareWithPeers(stock types.Stock, peers interface{}) float64 {
+func compareWithPeers(stock types.Stock, peers interface{}, explanation *scoreExplanation) float64 {
 	peerScore := 0.0
 	var medianScore float64
 
-	if arr, ok := peers.(primitive.A); ok {
-		// Ensure there are enough peers to compare
-		if len(arr) < 2 {
+	// Points per factor, reported normalized like the score itself
+	factors := &scoreExplanation{}
+	award := func(points float64, reason string) {
+		peerScore += points
+		factors.add(points, reason)
+	}
+
+	if _, ok := peers.(primitive.A); ok {
+		// The median row is tagged (see IsPeerMedian), a table without one is compared with its peers only
+		rows, median := SplitPeers(peers)
+		if len(rows) == 0 {
 			zap.L().Warn("Not enough peers to compare")
 			return 0.0
 		}
 
-		for _, peerRaw := range arr[:len(arr)-1] {
-			peer := peerRaw.(bson.M)
-
+		for _, peer := range rows {
 			// Parse peer values to float64
 			peerPE := ParseFloat(peer["pe"])
 			peerMarketCap := ParseFloat(peer["market_cap"])
@@ -159,33 +282,35 @@ func compareWithPeers(stock types.Stock, peers interface{}) float64 {
 
 			// Example scoring logic
 			if stock.PE < peerPE {
-				peerScore += 10
+				award(10, "PE below peers")
 			} else {
-				peerScore += math.Max(0, 10-(stock.PE-peerPE))
+				award(math.Max(0, 10-(stock.PE-peerPE)), "PE close to peers")
 			}
 
 			if stock.MarketCap > peerMarketCap {
-				peerScore += 5
+				award(5, "Market cap above peers")
 			}
 
-			if stock.DividendYield > peerDividendYield {
-				peerScore += 5
+			if dividendYieldInBand(stock.DividendYield) && stock.DividendYield > peerDividendYield {
+				award(5, "Dividend yield above peers")
 			}
 
 			if stock.ROCE > peerROCE {
-				peerScore += 10
+				award(10, "ROCE above peers")
 			}
 
 			if stock.QuarterlySales > peerQuarterlySales {
-				peerScore += 5
+				award(5, "Quarterly sales above peers")
 			}
 
 			if stock.QuarterlyProfit > peerQuarterlyProfit {
-				peerScore += 10
+				award(10, "Quarterly profit above peers")
 			}
 		}
-		medianRaw := arr[len(arr)-1]
-		median := medianRaw.(bson.M)
+		if median == nil {
+			explanation.merge(factors, 1/float64(len(rows)))
+			return peerScore / float64(len(rows))
+		}
 
 		// Parse median values to float64
 		medianPE := ParseFloat(median["pe"])
@@ -197,38 +322,34 @@ func compareWithPeers(stock types.Stock, peers interface{}) float64 {
 
 		// Adjust score based on median comparison
 		if stock.PE < medianPE {
-			peerScore += 5
+			award(5, "PE below peer median")
 		} else {
-			peerScore += math.Max(0, 5-(stock.PE-medianPE))
+			award(math.Max(0, 5-(stock.PE-medianPE)), "PE close to peer median")
 		}
 
 		if stock.MarketCap > medianMarketCap {
-			peerScore += 3
+			award(3, "Market cap above peer median")
 		}
 
-		if stock.DividendYield > medianDividendYield {
-			peerScore += 3
+		if dividendYieldInBand(stock.DividendYield) && stock.DividendYield > medianDividendYield {
+			award(3, "Dividend yield above peer median")
 		}
 
 		if stock.ROCE > medianROCE {
-			peerScore += 5
+			award(5, "ROCE above peer median")
 		}
 
 		if stock.QuarterlySales > medianQuarterlySales {
-			peerScore += 2
+			award(2, "Quarterly sales above peer median")
 		}
 
 		if stock.QuarterlyProfit > medianQuarterlyProfit {
-			peerScore += 5
+			award(5, "Quarterly profit above peer median")
 		}
 
 		// Normalize by the number of peers (excluding the median)
-		peerCount := len(arr) - 1
-		if peerCount > 0 {
-			return peerScore / float64(peerCount)
-		}
-
-		// Normalize by the number of peers excluding the last element
+		explanation.merge(factors, 1/float64(len(rows)))
+		return peerScore / float64(len(rows))
 	}
 
 	// Combine peerScore with medianScore (example: giving 10% weight to the median)
@@ -239,87 +360,117 @@ func compareWithPeers(stock types.Stock, peers interface{}) float64 {
 
 // Helper function to convert values from map to float64
 func ParseFloat(value interface{}) float64 {
+	f, _ := parseFloat(value)
+	return f
+}
+
+// parseFloat is ParseFloat reporting whether the value parsed, nil and other types than strings and
+// numbers are 0 and not parsed, callers tell a missing value apart themselves
+func parseFloat(value interface{}) (float64, bool) {
 	switch v := value.(type) {
 	case string:
 		f, err := strconv.ParseFloat(v, 64)
 		if err != nil {
-			return 0.0
+			return 0.0, false
 		}
-		return f
+		return f, true
 	case float64:
-		return v
+		return v, true
+	case float32:
+		return float64(v), true
 	case int:
-		return float64(v)
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
 	default:
-		return 0.0
+		return 0.0, false
 	}
 }
+
+// AnalyzeTrend scores the quarterly results of a stock, only looking at the metrics with a known
+// direction (see TrendMetricDirections) so e.g. rising sales count positively and rising interest negatively
 func AnalyzeTrend(stock types.Stock, pastData interface{}) float64 {
+	return analyzeTrend(stock, pastData, nil)
+}
+
+func analyzeTrend(stock types.Stock, pastData interface{}, explanation *scoreExplanation) float64 {
 	trendScore := 0.0
 	comparisons := 0 // Keep track of the number of comparisons
+	directions := TrendMetricDirections()
+	metricScores := map[string]float64{}
+
+	data, ok := toMap(pastData)
+	if !ok {
+		return 0.0
+	}
 
-	// Ensure pastData is in bson.M format
-	if data, ok := pastData.(bson.M); ok {
-		for _, quarterData := range data {
-			// zap.L().Info("Processing quarter", zap.String("quarter", key))
-
-			// Process the quarter data if it's a primitive.A (array of quarter maps)
-			if quarterArray, ok := quarterData.(primitive.A); ok {
-				var prevElem bson.M
-				for i, elem := range quarterArray {
-					if elemMap, ok := elem.(bson.M); ok {
-						// zap.L().Info("Processing quarter element", zap.Any("element", elemMap))
-
-						// Only perform comparisons starting from the second element
-						if i > 0 && prevElem != nil {
-							// zap.L().Info("Comparing with previous element", zap.Any("previous", prevElem), zap.Any("current", elemMap))
-
-							// Iterate over the keys in the current quarter and compare with previous quarter
-							for key, v := range elemMap {
-								if prevVal, ok := prevElem[key]; ok {
-									// Compare consecutive values for the same key
-									if ToFloat(v) > ToFloat(prevVal) {
-										trendScore += 5
-									} else if ToFloat(v) < ToFloat(prevVal) {
-										trendScore -= 5
-									}
-									// Increment comparisons for each valid comparison
-									comparisons++
-								}
-							}
-						}
-						// Update previous element for next iteration
-						prevElem = elemMap
-					}
-				}
+	for metric, quarterData := range data {
+		direction, ok := directions[NormalizeMetricName(metric)]
+		if !ok || direction == 0 {
+			continue
+		}
+
+		series := quarterSeries(quarterData)
+		for i := 1; i < len(series); i++ {
+			// Gaps (non numeric cells) break the chain instead of being compared as zeros
+			if series[i] == nil || series[i-1] == nil {
+				continue
+			}
+			if *series[i] > *series[i-1] {
+				trendScore += 5 * direction
+				metricScores[metric] += 5 * direction
+			} else if *series[i] < *series[i-1] {
+				trendScore -= 5 * direction
+				metricScores[metric] -= 5 * direction
 			}
+			comparisons++
 		}
 	}
 
 	// Normalize the score by dividing it by the number of comparisons
 	if comparisons > 0 {
+		metrics := make([]string, 0, len(metricScores))
+		for metric := range metricScores {
+			metrics = append(metrics, metric)
+		}
+		sort.Strings(metrics)
+		for _, metric := range metrics {
+			movement := "Rising"
+			if metricScores[metric]*directions[NormalizeMetricName(metric)] < 0 {
+				movement = "Declining"
+			}
+			explanation.add(metricScores[metric]/float64(comparisons), fmt.Sprintf("%s quarterly %s trend", movement, displayMetricName(metric)))
+		}
 		return trendScore / float64(comparisons)
 	}
 	return 0.0 // Return 0 if no comparisons were made
 }
 
-// prosConsAdjustment calculates score adjustments based on pros and cons
+// ProsConsAdjustment calculates score adjustments based on pros and cons: +1 per pro, -1 per con
 func ProsConsAdjustment(stock types.Stock) float64 {
-	adjustment := 0.0
+	return float64(len(stock.Pros)) - float64(len(stock.Cons))
+}
 
-	// Adjust score based on pros
-	// for _, pro := range stock.Pros {
-	// zap.L().Info("Pro", zap.String("pro", pro)) // This line is optional, just showing how we could use 'pro'
-	adjustment += ToFloat(1.0 * len(stock.Pros))
-	// }
+// prosConsPoints is the score of a stock with only pros, -prosConsPoints with only cons
+const prosConsPoints = 10
 
-	// Adjust score based on cons
-	// for _, con := range stock.Cons {
-	// zap.L().Info("Con", zap.String("con", con)) // This line is optional, just showing how we could use 'con'
-	adjustment -= ToFloat(1.0 * len(stock.Cons))
-	// }/
+// prosConsScore scores the pros and cons screener lists by their balance, the adjustment over
+// their count, so a long list of either can't swamp the other components
+func prosConsScore(stock types.Stock, explanation *scoreExplanation) float64 {
+	count := len(stock.Pros) + len(stock.Cons)
+	if count == 0 {
+		return 0
+	}
+	score := ProsConsAdjustment(stock) / float64(count) * prosConsPoints
+	explanation.add(score, fmt.Sprintf("%d pros and %d cons listed", len(stock.Pros), len(stock.Cons)))
+	return score
+}
 
-	return adjustment
+// ProsConsWeight returns the weight of the pros and cons component in the final score (PROS_CONS_WEIGHT)
+func ProsConsWeight() float64 {
+	return config.Get().ProsConsWeight
 }
 
 func ParsePeersTable(doc *goquery.Document, selector string) []map[string]string {
@@ -345,31 +496,36 @@ func ParsePeersTable(doc *goquery.Document, selector string) []map[string]string
 	return peers
 }
 
-func FetchPeerData(dataWarehouseID string) ([]map[string]string, error) {
-	time.Sleep(1 * time.Second)
-	peerURL := fmt.Sprintf(os.Getenv("COMPANY_URL")+"/api/company/%s/peers/", dataWarehouseID)
+func FetchPeerData(ctx context.Context, dataWarehouseID string) ([]map[string]string, error) {
+	ctx, span := tracing.Start(ctx, "FetchPeerData", attribute.String("dataWarehouseId", dataWarehouseID))
+	defer span.End()
+
+	peers, err := fetchPeerData(ctx, dataWarehouseID)
+	tracing.RecordError(span, err)
+	return peers, err
+}
+
+func fetchPeerData(ctx context.Context, dataWarehouseID string) ([]map[string]string, error) {
+	peerURL := fmt.Sprintf(config.Get().CompanyURL+"/api/company/%s/peers/", dataWarehouseID)
 
 	// Create a new HTTP request
-	req, err := http.NewRequest("GET", peerURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", peerURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request to peers API: %w", err)
 	}
 
-	// Add any required headers or cookies here
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	resp, err := client.Do(req)
+	// The shared scraper client adds the User-Agent and Accept headers
+	resp, err := http_client.ScraperClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching peers data from API: %w", err)
+		return nil, fmt.Errorf("error fetching peers data from API: %w", http_client.RequestError(err))
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if err := http_client.StatusError(resp.StatusCode); err != nil {
 		bodyBytes, _ := ioutil.ReadAll(resp.Body)
 		bodyString := string(bodyBytes)
-		zap.L().Error("Received non-200 response code", zap.Int("status_code", resp.StatusCode), zap.String("body", bodyString))
-		return nil, fmt.Errorf("received non-200 response code from peers API: %d", resp.StatusCode)
+		Logger(ctx).Error("Received non-200 response code", zap.Int("status_code", resp.StatusCode), zap.String("body", bodyString))
+		return nil, fmt.Errorf("error from peers API: %w", err)
 	}
 
 	// Parse the HTML response
@@ -377,91 +533,56 @@ func FetchPeerData(dataWarehouseID string) ([]map[string]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error parsing HTML response: %w", err)
 	}
+	if doc.Find("table").Length() == 0 {
+		return nil, fmt.Errorf("%w: the peers response has no table", http_client.ErrLayoutChanged)
+	}
 
-	var peersData []map[string]string
-	var medianData map[string]string
-
-	// Parse peers data from the table rows
-	doc.Find("tr[data-row-company-id]").Each(func(index int, item *goquery.Selection) {
-		peer := make(map[string]string)
-
-		peer["name"] = item.Find("td.text a").Text()
-		peer["current_price"] = strings.TrimSpace(item.Find("td").Eq(2).Text())
-		peer["pe"] = strings.TrimSpace(item.Find("td").Eq(3).Text())
-		peer["market_cap"] = strings.TrimSpace(item.Find("td").Eq(4).Text())
-		peer["div_yield"] = strings.TrimSpace(item.Find("td").Eq(5).Text())
-		peer["np_qtr"] = strings.TrimSpace(item.Find("td").Eq(6).Text())
-		peer["qtr_profit_var"] = strings.TrimSpace(item.Find("td").Eq(7).Text())
-		peer["sales_qtr"] = strings.TrimSpace(item.Find("td").Eq(8).Text())
-		peer["qtr_sales_var"] = strings.TrimSpace(item.Find("td").Eq(9).Text())
-		peer["roce"] = strings.TrimSpace(item.Find("td").Eq(10).Text())
-
-		peersData = append(peersData, peer)
-	})
-
-	// Parse median data from the footer of the table
-	doc.Find("tfoot tr").Each(func(index int, item *goquery.Selection) {
-		medianData = make(map[string]string)
-		medianData["company_count"] = strings.TrimSpace(item.Find("td").Eq(1).Text())
-		medianData["current_price"] = strings.TrimSpace(item.Find("td").Eq(2).Text())
-		medianData["pe"] = strings.TrimSpace(item.Find("td").Eq(3).Text())
-		medianData["market_cap"] = strings.TrimSpace(item.Find("td").Eq(4).Text())
-		medianData["div_yield"] = strings.TrimSpace(item.Find("td").Eq(5).Text())
-		medianData["np_qtr"] = strings.TrimSpace(item.Find("td").Eq(6).Text())
-		medianData["qtr_profit_var"] = strings.TrimSpace(item.Find("td").Eq(7).Text())
-		medianData["sales_qtr"] = strings.TrimSpace(item.Find("td").Eq(8).Text())
-		medianData["qtr_sales_var"] = strings.TrimSpace(item.Find("td").Eq(9).Text())
-		medianData["roce"] = strings.TrimSpace(item.Find("td").Eq(10).Text())
-	})
-
-	peersData = append(peersData, medianData)
-	return peersData, nil
+	return ParsePeersAPITable(doc), nil
 }
 
-func ParseTableData(section *goquery.Selection, tableSelector string) map[string]interface{} {
-	table := section.Find(tableSelector)
-	if table.Length() == 0 {
-		return nil
+// ParseTableData returns the label keyed map view of a result table, with the error of
+// ParseSectionTable when it has no data rows
+func ParseTableData(section *goquery.Selection, tableSelector string) (map[string]interface{}, error) {
+	rows, err := ParseSectionTable(section, tableSelector)
+	if err != nil {
+		return nil, err
 	}
-
-	// Extract months/years from table headers
-	headers := []string{}
-	table.Find("thead th").Each(func(i int, th *goquery.Selection) {
-		headers = append(headers, strings.TrimSpace(th.Text()))
-	})
-
-	// Extract table rows and values
-	data := make(map[string]interface{})
-	table.Find("tbody tr").Each(func(i int, tr *goquery.Selection) {
-		rowKey := strings.TrimSpace(tr.Find("td.text").Text())
-		rowValues := []string{}
-		tr.Find("td").Each(func(i int, td *goquery.Selection) {
-			if i > 0 { // Skip the first column which is the row key
-				rowValues = append(rowValues, strings.TrimSpace(td.Text()))
-			}
-		})
-		data[rowKey] = rowValues
-	})
-
-	return data
+	return TableRowsToMap(rows), nil
 }
 
-func ParseShareholdingPattern(section *goquery.Selection) map[string]interface{} {
+// ParseShareholdingPattern parses the quarterly and yearly shareholding tables, leaving out the
+// rows without a category. ErrSectionTableNotFound is returned when neither table is on the page
+// and ErrSectionNoDataRows when they have no categorized rows.
+func ParseShareholdingPattern(section *goquery.Selection) (map[string]interface{}, error) {
 	shareholdingData := make(map[string]interface{})
 
-	// Extract quarterly data
-	quarterlyData := ParseTable(section.Find("div#quarterly-shp"))
-	if len(quarterlyData) > 0 {
-		shareholdingData["quarterly"] = quarterlyData
-	}
+	tables, rows := 0, 0
+	for key, selector := range map[string]string{"quarterly": "div#quarterly-shp", "yearly": "div#yearly-shp"} {
+		tableDiv := section.Find(selector)
+		if tableDiv.Find("table").Length() == 0 {
+			continue
+		}
+		tables++
 
-	// Extract yearly data
-	yearlyData := ParseTable(section.Find("div#yearly-shp"))
-	if len(yearlyData) > 0 {
-		shareholdingData["yearly"] = yearlyData
+		var data []map[string]interface{}
+		for _, row := range ParseTable(tableDiv) {
+			rows++
+			if category, _ := row["category"].(string); category != "" {
+				data = append(data, row)
+			}
+		}
+		if len(data) > 0 {
+			shareholdingData[key] = data
+		}
 	}
 
-	return shareholdingData
+	if tables == 0 {
+		return shareholdingData, fmt.Errorf("%w: div#quarterly-shp, div#yearly-shp", ErrSectionTableNotFound)
+	}
+	if len(shareholdingData) == 0 {
+		return shareholdingData, fmt.Errorf("%w: none of the %d rows has a category", ErrSectionNoDataRows, rows)
+	}
+	return shareholdingData, nil
 }
 
 func ParseTable(tableDiv *goquery.Selection) []map[string]interface{} {
@@ -499,31 +620,69 @@ func ParseTable(tableDiv *goquery.Selection) []map[string]interface{} {
 	return tableData
 }
 
-func FetchCompanyData(url string) (map[string]interface{}, error) {
-	body, err := http_client.GetCompanyPage(url)
+func FetchCompanyData(ctx context.Context, url string) (map[string]interface{}, error) {
+	ctx, span := tracing.Start(ctx, "FetchCompanyData", attribute.String("url", url))
+	defer span.End()
+
+	data, err := fetchCompanyData(ctx, url)
+	tracing.RecordError(span, err)
+	return data, err
+}
+
+// companyRatiosSelector matches the top ratios (market cap, PE, ...) every company page starts with
+const companyRatiosSelector = "li.flex.flex-space-between[data-source='default']"
+
+// fetchCompanyData scrapes a company page. Its errors wrap the http_client scraping errors: a page
+// without the top ratios is http_client.ErrLayoutChanged.
+func fetchCompanyData(ctx context.Context, url string) (map[string]interface{}, error) {
+	body, err := http_client.GetCompanyPage(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the company page: %w", err)
+	}
+	defer body.Close()
+
+	html, err := io.ReadAll(body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch the company page: %v", err)
+		return nil, fmt.Errorf("failed to read the company page: %w", http_client.RequestError(err))
 	}
 
 	// Parse the HTML content of the company page
-	doc, err := goquery.NewDocumentFromReader(body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse the HTML content: %v", err)
 	}
 	// Extract data-warehouse-id
 	companyData := make(map[string]interface{})
+	layoutChanged := doc.Find(companyRatiosSelector).Length() == 0
 
-	dataWarehouseID, exists := doc.Find("div[data-warehouse-id]").Attr("data-warehouse-id")
-	if exists {
-		peerData, err := FetchPeerData(dataWarehouseID)
-		if err == nil {
-			companyData["peers"] = peerData
+	// Keep a copy of the raw page around so parser breakage can be diagnosed later
+	if DebugStoreHTMLEnabled() {
+		htmlPath, err := StoreDebugHTML(url, html)
+		if err != nil {
+			Logger(ctx).Error("Error storing debug HTML", zap.String("url", url), zap.Error(err))
+		} else {
+			companyData["debugHtml"] = htmlPath
 		}
 	}
+	// Checked once the page is kept for debugging, a page without the ratios can't be parsed either
+	if layoutChanged {
+		return nil, fmt.Errorf("%w: no %s on the company page", http_client.ErrLayoutChanged, companyRatiosSelector)
+	}
+
+	// Without a warehouse id the peers can't be fetched, peersUnavailable tells the scoring not to
+	// read the missing peers as a stock no better than its peers. The peers themselves are fetched by
+	// the data source with the id.
+	dataWarehouseID, exists := findWarehouseID(doc, html)
+	companyData["peersUnavailable"] = !exists
+	if exists {
+		companyData[PeersIDKey] = dataWarehouseID
+	} else {
+		Logger(ctx).Warn("No warehouse id on the company page, peers are unavailable", zap.String("url", url))
+	}
 
 	// Extract the data we need
 	// Extract data as specified
-	doc.Find("li.flex.flex-space-between[data-source='default']").Each(func(index int, item *goquery.Selection) {
+	doc.Find(companyRatiosSelector).Each(func(index int, item *goquery.Selection) {
 		key := strings.TrimSpace(item.Find("span.name").Text())
 
 		// Extract value text and clean it up
@@ -541,13 +700,14 @@ func FetchCompanyData(url string) (map[string]interface{}, error) {
 		// Remove currency symbols and units from value
 		value = strings.ReplaceAll(value, "₹", "")
 		value = strings.ReplaceAll(value, "Cr.", "")
+		// Percentages are stored in whole percent, "12.3%" as "12.3" (see toFloat)
 		value = strings.ReplaceAll(value, "%", "")
 
 		// Add to company data
 		companyData[key] = value
 
 		// Print cleaned key-value pairs
-		zap.L().Info("Company Data", zap.String("key", key), zap.String("value", value))
+		Logger(ctx).Info("Company Data", zap.String("key", key), zap.String("value", value))
 	})
 	// Extract pros
 	var pros []string
@@ -564,11 +724,18 @@ func FetchCompanyData(url string) (map[string]interface{}, error) {
 		cons = append(cons, con)
 	})
 	companyData["cons"] = cons
-	// Extract Quarterly Results
+	companyData["prosInsights"] = ClassifyInsights(pros)
+	companyData["consInsights"] = ClassifyInsights(cons)
+	// Extract Quarterly Results, from the quarters section when the page has one since the other
+	// result tables share the data-table class
 	quarterlyResults := make(map[string][]map[string]string)
+	quartersTable := doc.Find("section#quarters table.data-table")
+	if quartersTable.Length() == 0 {
+		quartersTable = doc.Find("table.data-table")
+	}
 	// Get the months (headers) from the table
 	var months []string
-	doc.Find("table.data-table thead tr th").Each(func(index int, item *goquery.Selection) {
+	quartersTable.Find("thead tr th").Each(func(index int, item *goquery.Selection) {
 		month := strings.TrimSpace(item.Text())
 		if month != "" && month != "-" { // Skip empty or irrelevant headers
 			months = append(months, month)
@@ -576,7 +743,7 @@ func FetchCompanyData(url string) (map[string]interface{}, error) {
 	})
 
 	// Iterate over each row in the tbody
-	doc.Find("table.data-table tbody tr").Each(func(index int, row *goquery.Selection) {
+	quartersTable.Find("tbody tr").Each(func(index int, row *goquery.Selection) {
 		fieldName := strings.TrimSpace(row.Find("td.text").Text())
 		var fieldData []map[string]string
 
@@ -584,7 +751,8 @@ func FetchCompanyData(url string) (map[string]interface{}, error) {
 		row.Find("td").Each(func(colIndex int, col *goquery.Selection) {
 			if colIndex > 0 && colIndex <= len(months) { // Ensure we are within the bounds of the months array
 				value := strings.TrimSpace(col.Text())
-				month := months[colIndex]
+				// The label column has no month, the first value is the first month
+				month := months[colIndex-1]
 				fieldData = append(fieldData, map[string]string{
 					month: value,
 				})
@@ -596,31 +764,69 @@ func FetchCompanyData(url string) (map[string]interface{}, error) {
 		}
 	})
 
+	if len(quarterlyResults) == 0 {
+		warnEmptySection(ctx, url, "quarterlyResults", fmt.Errorf("%w: none of the %d rows has a label and a value", ErrSectionNoDataRows, quartersTable.Find("tbody tr").Length()))
+	}
 	companyData["quarterlyResults"] = quarterlyResults
-	profitLossSection := doc.Find("section#profit-loss")
-	if profitLossSection.Length() > 0 {
-		companyData["profitLoss"] = ParseTableData(profitLossSection, "div[data-result-table]")
+
+	// A section missing from the page is logged too, the sections every company page has
+	// disappearing at once means the page layout changed
+	for _, table := range resultTableSections {
+		section := doc.Find(table.selector)
+		if section.Length() == 0 {
+			warnEmptySection(ctx, url, table.key, fmt.Errorf("%s is not on the page", table.selector))
+			continue
+		}
+		rows, err := ParseSectionTable(section, "div[data-result-table]")
+		if err != nil {
+			warnEmptySection(ctx, url, table.key, err)
+			continue
+		}
+		companyData[table.key] = rows
+		companyData[table.key+"Headers"] = ParseTableHeaders(section, "div[data-result-table]")
 	}
-	balanceSheetSection := doc.Find("section#balance-sheet")
-	if balanceSheetSection.Length() > 0 {
-		companyData["balanceSheet"] = ParseTableData(balanceSheetSection, "div[data-result-table]")
+	if ratios, ok := companyData["ratios"]; ok {
+		companyData["ratioSeries"] = ParseRatioSeries(ratios, companyData["ratiosHeaders"])
 	}
+
 	shareHoldingPattern := doc.Find("section#shareholding")
 	if shareHoldingPattern.Length() > 0 {
-		companyData["shareholdingPattern"] = ParseShareholdingPattern(shareHoldingPattern)
+		if pattern, err := ParseShareholdingPattern(shareHoldingPattern); err != nil {
+			warnEmptySection(ctx, url, "shareholdingPattern", err)
+		} else {
+			companyData["shareholdingPattern"] = pattern
+			companyData["shareholdingTrend"] = AnalyzeShareholding(pattern)
+		}
+	} else {
+		warnEmptySection(ctx, url, "shareholdingPattern", errors.New("section#shareholding is not on the page"))
 	}
 
-	ratiosSection := doc.Find("section#ratios")
-	if ratiosSection.Length() > 0 {
-		companyData["ratios"] = ParseTableData(ratiosSection, "div[data-result-table]")
+	// screener's growth tables, the rating computes the growth from the yearly rows without them
+	if growth := ParseGrowthSummary(doc); len(growth) > 0 {
+		companyData["growthSummary"] = growth
 	}
-	cashFlowsSection := doc.Find("section#cash-flow")
-	if cashFlowsSection.Length() > 0 {
-		companyData["cashFlows"] = ParseTableData(cashFlowsSection, "div[data-result-table]")
+
+	if sector := ParseSector(doc); sector != "" {
+		companyData["sector"] = sector
 	}
 	return companyData, nil
 }
 
+// resultTableSections are the result tables of a company page, stored under key with their
+// headers under key+"Headers"
+var resultTableSections = []struct{ key, selector string }{
+	{"profitLoss", "section#profit-loss"},
+	{"balanceSheet", "section#balance-sheet"},
+	{"ratios", "section#ratios"},
+	{"cashFlows", "section#cash-flow"},
+}
+
+// warnEmptySection logs a section of the company page that yielded no data, the error tells a
+// missing section or table (the selector likely broke) from a table without data rows
+func warnEmptySection(ctx context.Context, url string, section string, err error) {
+	Logger(ctx).Warn("No data parsed from company page section", zap.String("url", url), zap.String("section", section), zap.Error(err))
+}
+
 func calculateRoa(netProfit string, totalAssets string) float64 {
 	// Calculate the Return on Assets (ROA) for the current year
 	currentYearRoa := ToFloat(netProfit) / ToFloat(totalAssets)
@@ -628,33 +834,57 @@ func calculateRoa(netProfit string, totalAssets string) float64 {
 	return currentYearRoa
 }
 
+// increaseInRoa compares the ROA of the last two years, both series hold annual values only
 func increaseInRoa(netProfit primitive.A, totalAssets primitive.A) bool {
+	if len(netProfit) < 2 || len(totalAssets) < 2 {
+		return false
+	}
+
 	// Calculate the Return on Assets (ROA) for the current year
-	currentYearRoa := calculateRoa(netProfit[len(netProfit)-2].(string), totalAssets[len(totalAssets)-1].(string)) // No TTM in the denominator
+	currentYearRoa := calculateRoa(netProfit[len(netProfit)-1].(string), totalAssets[len(totalAssets)-1].(string))
 
 	// Calculate the Return on Assets (ROA) for the previous year
-	previousYearRoa := calculateRoa(netProfit[len(netProfit)-3].(string), totalAssets[len(totalAssets)-2].(string)) // No TTM in the denominator
+	previousYearRoa := calculateRoa(netProfit[len(netProfit)-2].(string), totalAssets[len(totalAssets)-2].(string))
 
 	return currentYearRoa > previousYearRoa
 }
 
 // Helper function to generate the F-Score for a stock
 func GenerateFScore(stock map[string]interface{}) int {
+	return generateFScore(stock, nil)
+}
+
+// generateFScore scores the first statement set with the rows it needs, FScoreNotComputable
+// when neither the consolidated nor the standalone statements have them
+func generateFScore(stock map[string]interface{}, breakdown *FScoreBreakdown) int {
+	statements, gaps := selectFScoreStatements(stock)
+	if breakdown != nil {
+		breakdown.Statements = statements.Basis
+	}
+	if len(gaps) > 0 {
+		for _, gap := range gaps {
+			section, label, _ := strings.Cut(gap, ": ")
+			breakdown.missingRow(section, label)
+		}
+		return FScoreNotComputable
+	}
+	stock = statements.Tables
+
 	fScore := 0
 
-	profitablityScore := calculateProfitabilityScore(stock)
+	profitablityScore := calculateProfitabilityScore(stock, breakdown)
 	if profitablityScore < 0 {
 		return -1
 	}
 	fScore += profitablityScore
 
-	leverageScore := calculateLeverageScore(stock)
+	leverageScore := calculateLeverageScore(stock, breakdown)
 	if leverageScore < 0 {
 		return -1
 	}
 	fScore += leverageScore
 
-	operatingEfficiencyScore := calculateOperatingEfficiencyScore(stock)
+	operatingEfficiencyScore := calculateOperatingEfficiencyScore(stock, breakdown)
 	if operatingEfficiencyScore < 0 {
 		return -1
 	}
@@ -663,177 +893,193 @@ func GenerateFScore(stock map[string]interface{}) int {
 	return fScore
 }
 
-func calculateProfitabilityScore(stock map[string]interface{}) int {
+func calculateProfitabilityScore(stock map[string]interface{}, breakdown *FScoreBreakdown) int {
 	score := 0
 
 	// 1 - Profitability Ratios
 	// 1.1 - Is the ROA (Return on Assets) positive?
-	netProfit, err := getNestedArrayField(stock, "profitLoss", "Net Profit +")
+	netProfit, err := getAnnualArrayField(stock, "profitLoss", "Net Profit +")
 	if err != nil {
-		return -1
+		return breakdown.missingRow("profitLoss", "Net Profit +")
 	}
-	totalAssets, err := getNestedArrayField(stock, "balanceSheet", "Total Assets")
+	totalAssets, err := getAnnualArrayField(stock, "balanceSheet", "Total Assets")
 	if err != nil {
-		return -1
+		return breakdown.missingRow("balanceSheet", "Total Assets")
 	}
 
 	if len(netProfit) > 0 && len(totalAssets) > 0 {
-		roa := calculateRoa(netProfit[len(netProfit)-2].(string), totalAssets[len(totalAssets)-1].(string))
-		if roa > 0 {
+		roa := calculateRoa(netProfit[len(netProfit)-1].(string), totalAssets[len(totalAssets)-1].(string))
+		if breakdown.pass(fScorePositiveROA, roa > 0) {
 			score++
 		}
+	} else {
+		breakdown.unavailable(fScorePositiveROA)
 	}
 
 	// 1.2 - Positive Cash from Operating Activities in the current year compared to the previous year
-	cashFlowOps, err := getNestedArrayField(stock, "cashFlows", "Cash from Operating Activity +")
+	cashFlowOps, err := getAnnualArrayField(stock, "cashFlows", "Cash from Operating Activity +")
 	if err != nil {
-		return -1
+		return breakdown.missingRow("cashFlows", "Cash from Operating Activity +")
 	}
 
 	if len(cashFlowOps) > 1 {
 		currentCashFlow := ToFloat(cashFlowOps[len(cashFlowOps)-1])
 		previousCashFlow := ToFloat(cashFlowOps[len(cashFlowOps)-2])
-		if currentCashFlow > previousCashFlow {
+		if breakdown.pass(fScoreHigherCashFlow, currentCashFlow > previousCashFlow) {
 			score++
 		}
+	} else {
+		breakdown.unavailable(fScoreHigherCashFlow)
 	}
 
 	// 1.3 - Positive Return on Assets in the current year compared to the previous year
-	if increaseInRoa(netProfit, totalAssets) {
-		score++
+	if len(netProfit) > 1 && len(totalAssets) > 1 {
+		if breakdown.pass(fScoreHigherROA, increaseInRoa(netProfit, totalAssets)) {
+			score++
+		}
+	} else {
+		breakdown.unavailable(fScoreHigherROA)
 	}
 
-	// 1.4 - Higher Cash from Operating Activities than Net Profit (excluding TTM value)
-	if len(cashFlowOps) > 0 && len(netProfit) > 1 {
+	// 1.4 - Higher Cash from Operating Activities than Net Profit
+	if len(cashFlowOps) > 0 && len(netProfit) > 0 {
 		cashFlow := ToFloat(cashFlowOps[len(cashFlowOps)-1])
-		profit := ToFloat(netProfit[len(netProfit)-2])
-		if cashFlow > profit {
+		profit := ToFloat(netProfit[len(netProfit)-1])
+		if breakdown.pass(fScoreCashFlowAboveProfit, cashFlow > profit) {
 			score++
 		}
+	} else {
+		breakdown.unavailable(fScoreCashFlowAboveProfit)
 	}
 
 	return score
 }
 
-func calculateLeverageScore(stock map[string]interface{}) int {
+func calculateLeverageScore(stock map[string]interface{}, breakdown *FScoreBreakdown) int {
 	score := 0
 
 	// 2 - Leverage, Liquidity, and Source of Funds
 	// 2.1 Lower Long-term Debt to Total Assets ratio in the current year compared to the previous year
-	borrowings, err := getNestedArrayField(stock, "balanceSheet", "Borrowings +")
+	borrowings, err := getAnnualArrayField(stock, "balanceSheet", "Borrowings +")
 	if err != nil {
-		return -1
+		return breakdown.missingRow("balanceSheet", "Borrowings +")
 	}
-	totalAssets, err := getNestedArrayField(stock, "balanceSheet", "Total Assets")
+	totalAssets, err := getAnnualArrayField(stock, "balanceSheet", "Total Assets")
 	if err != nil {
-		return -1
+		return breakdown.missingRow("balanceSheet", "Total Assets")
 	}
 	if len(borrowings) > 1 && len(totalAssets) > 1 {
 		currentRatio := ToFloat(borrowings[len(borrowings)-1]) / ToFloat(totalAssets[len(totalAssets)-1])
 		previousRatio := ToFloat(borrowings[len(borrowings)-2]) / ToFloat(totalAssets[len(totalAssets)-2])
-		if currentRatio <= previousRatio {
+		if breakdown.pass(fScoreLowerLeverage, currentRatio <= previousRatio) {
 			score++
 		}
+	} else {
+		breakdown.unavailable(fScoreLowerLeverage)
 	}
 
 	// 2.2 Higher Current Ratio in the current year compared to the previous year
-	otherAssets, err := getNestedArrayField(stock, "balanceSheet", "Other Assets +")
+	otherAssets, err := getAnnualArrayField(stock, "balanceSheet", "Other Assets +")
 	if err != nil {
-		return -1
+		return breakdown.missingRow("balanceSheet", "Other Assets +")
 	}
 
-	otherLiabilities, err := getNestedArrayField(stock, "balanceSheet", "Other Liabilities +")
+	otherLiabilities, err := getAnnualArrayField(stock, "balanceSheet", "Other Liabilities +")
 	if err != nil {
-		return -1
+		return breakdown.missingRow("balanceSheet", "Other Liabilities +")
 	}
 
 	if len(otherAssets) > 1 && len(otherLiabilities) > 1 {
 		currentRatio := ToFloat(otherAssets[len(otherAssets)-1]) / ToFloat(otherLiabilities[len(otherLiabilities)-1])
 		previousRatio := ToFloat(otherAssets[len(otherAssets)-2]) / ToFloat(otherLiabilities[len(otherLiabilities)-2])
-		if currentRatio > previousRatio {
+		if breakdown.pass(fScoreHigherCurrentRatio, currentRatio > previousRatio) {
 			score++
 		}
+	} else {
+		breakdown.unavailable(fScoreHigherCurrentRatio)
 	}
 
 	// 2.3 No new shares issued in the last year - assuming Equity Capital is the same as Share Capital
-	equityCapital, err := getNestedArrayField(stock, "balanceSheet", "Equity Capital")
+	equityCapital, err := getAnnualArrayField(stock, "balanceSheet", "Equity Capital")
 	if err != nil {
-		return -1
+		return breakdown.missingRow("balanceSheet", "Equity Capital")
 	}
 
 	if len(equityCapital) > 1 {
 		currentEquity := ToFloat(equityCapital[len(equityCapital)-1])
 		previousEquity := ToFloat(equityCapital[len(equityCapital)-2])
-		if currentEquity <= previousEquity {
+		if breakdown.pass(fScoreNoDilution, currentEquity <= previousEquity) {
 			score++
 		}
+	} else {
+		breakdown.unavailable(fScoreNoDilution)
 	}
 
 	return score
 }
 
-func calculateOperatingEfficiencyScore(stock map[string]interface{}) int {
+func calculateOperatingEfficiencyScore(stock map[string]interface{}, breakdown *FScoreBreakdown) int {
 	score := 0
 
 	// 3 - Operating Efficiency
-	// 3.1 Higher Gross Margin in the current year compared to the previous year - excluding TTM value
-	opm, err := getNestedArrayField(stock, "profitLoss", "OPM %")
+	// 3.1 Higher Gross Margin in the current year compared to the previous year
+	opm, err := getAnnualArrayField(stock, "profitLoss", "OPM %")
 	if err != nil {
 		// For Banks and Financial Institutions, OPM may not be available - we'll resort to Net Margin in such cases
 		// Net Margin = Net Profit / Revenue (Revenue in case of banks)
-		netProfit, err := getNestedArrayField(stock, "profitLoss", "Net Profit +")
+		netProfit, err := getAnnualArrayField(stock, "profitLoss", "Net Profit +")
 		if err != nil {
-			return -1
+			return breakdown.missingRow("profitLoss", "Net Profit +")
 		}
-		totalRevenue, err := getNestedArrayField(stock, "profitLoss", "Revenue")
+		totalRevenue, err := getAnnualArrayField(stock, "profitLoss", "Revenue")
 		if err != nil {
-			return -1
+			return breakdown.missingRow("profitLoss", "OPM % or Revenue")
 		}
 
-		// exclude TTM value
-		if len(netProfit) > 2 && len(totalRevenue) > 2 {
-			currentMargin := ToFloat(netProfit[len(netProfit)-2]) / ToFloat(totalRevenue[len(totalRevenue)-2])
-			previousMargin := ToFloat(netProfit[len(netProfit)-3]) / ToFloat(totalRevenue[len(totalRevenue)-3])
-			if currentMargin > previousMargin {
+		if len(netProfit) > 1 && len(totalRevenue) > 1 {
+			currentMargin := ToFloat(netProfit[len(netProfit)-1]) / ToFloat(totalRevenue[len(totalRevenue)-1])
+			previousMargin := ToFloat(netProfit[len(netProfit)-2]) / ToFloat(totalRevenue[len(totalRevenue)-2])
+			if breakdown.pass(fScoreHigherMargin, currentMargin > previousMargin) {
 				score++
 			}
 		} else {
-			return -1
+			return breakdown.missingRow("profitLoss", "Net Profit + and Revenue for two years")
 		}
-	}
-
-	if len(opm) > 2 {
-		currentOpm := ToFloat(opm[len(opm)-2])
-		previousOpm := ToFloat(opm[len(opm)-3])
-		if currentOpm > previousOpm {
+	} else if len(opm) > 1 {
+		currentOpm := ToFloat(opm[len(opm)-1])
+		previousOpm := ToFloat(opm[len(opm)-2])
+		if breakdown.pass(fScoreHigherMargin, currentOpm > previousOpm) {
 			score++
 		}
+	} else {
+		breakdown.unavailable(fScoreHigherMargin)
 	}
 
-	// 3.2 Higher Asset Turnover Ratio in the current year compared to the previous year - excluding TTM value for sales
-	sales, err := getNestedArrayField(stock, "profitLoss", "Sales +")
+	// 3.2 Higher Asset Turnover Ratio in the current year compared to the previous year
+	sales, err := getAnnualArrayField(stock, "profitLoss", "Sales +")
 	if err != nil {
 		// For Banks and Financial Institutions, we can use Revenue instead of Sales
-		revenue, err := getNestedArrayField(stock, "profitLoss", "Revenue")
+		revenue, err := getAnnualArrayField(stock, "profitLoss", "Revenue")
 		if err != nil {
-			return -1
+			return breakdown.missingRow("profitLoss", "Sales + or Revenue")
 		} else {
 			sales = revenue
 		}
 	}
 
-	totalAssets, err := getNestedArrayField(stock, "balanceSheet", "Total Assets")
+	totalAssets, err := getAnnualArrayField(stock, "balanceSheet", "Total Assets")
 	if err != nil {
-		return -1
+		return breakdown.missingRow("balanceSheet", "Total Assets")
 	}
 
-	// exclude TTM value for sales/revenue
-	if len(sales) > 2 && len(totalAssets) > 1 {
-		currentAssetTurnoverRatio := ToFloat(sales[len(sales)-2]) / ToFloat(totalAssets[len(totalAssets)-1])
-		previousAssetTurnoverRatio := ToFloat(sales[len(sales)-3]) / ToFloat(totalAssets[len(totalAssets)-2])
-		if currentAssetTurnoverRatio > previousAssetTurnoverRatio {
+	if len(sales) > 1 && len(totalAssets) > 1 {
+		currentAssetTurnoverRatio := ToFloat(sales[len(sales)-1]) / ToFloat(totalAssets[len(totalAssets)-1])
+		previousAssetTurnoverRatio := ToFloat(sales[len(sales)-2]) / ToFloat(totalAssets[len(totalAssets)-2])
+		if breakdown.pass(fScoreHigherAssetTurnover, currentAssetTurnoverRatio > previousAssetTurnoverRatio) {
 			score++
 		}
+	} else {
+		breakdown.unavailable(fScoreHigherAssetTurnover)
 	}
 
 	return score
@@ -852,9 +1098,11 @@ func checkArrayElementsAreString(arr primitive.A) (primitive.A, error) {
 	return arr, nil
 }
 
-// Helper function to get an array field from a nested map
+// Helper function to get an array field from a nested document.
+// Sections can either be label keyed maps (older documents) or ordered table rows,
+// in which case the path continues into the children of expandable rows.
 func getNestedArrayField(stock map[string]interface{}, path ...string) (primitive.A, error) {
-	var current bson.M = stock
+	var current interface{} = bson.M(stock)
 
 	for i, key := range path {
 		key = strings.TrimSpace(key)
@@ -864,10 +1112,30 @@ func getNestedArrayField(stock map[string]interface{}, path ...string) (primitiv
 			key = strings.ReplaceAll(key, " +", "\u00A0+")
 		}
 
+		var next interface{}
+		if m, ok := toMap(current); ok {
+			next = m[key]
+			if next == nil {
+				// Documents written outside of the scraper may use a regular space
+				next = m[strings.ReplaceAll(key, "\u00A0", " ")]
+			}
+		} else if rows, ok := toTableRows(current); ok {
+			row, found := findTableRow(rows, key)
+			if !found {
+				return primitive.A{}, errors.New("field not found")
+			}
+			if i == len(path)-1 {
+				values, _ := toArray(row.Values)
+				return checkArrayElementsAreString(values)
+			}
+			next = row.Children
+		} else {
+			return primitive.A{}, errors.New("field not found")
+		}
+
 		// If we're at the last key in the path
 		if i == len(path)-1 {
-			result, ok := current[key].(primitive.A)
-
+			result, ok := toArray(next)
 			if !ok {
 				// Return an empty array if the field is not an array
 				return primitive.A{}, errors.New("field not found")
@@ -876,12 +1144,7 @@ func getNestedArrayField(stock map[string]interface{}, path ...string) (primitiv
 			return checkArrayElementsAreString(result)
 		}
 
-		// Expect another nested map for intermediate keys
-		if result, ok := current[key].(bson.M); ok {
-			current = result
-		} else {
-			return primitive.A{}, errors.New("field not found")
-		}
+		current = next
 	}
 
 	return primitive.A{}, errors.New("field not found")
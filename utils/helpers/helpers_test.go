@@ -1,10 +1,22 @@
 package helpers
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
 	"reflect"
+	"stockbackend/config"
+	"stockbackend/types"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/PuerkitoBio/goquery"
+	"github.com/xuri/excelize/v2"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
 )
 
 func TestMatchHeader_NonMatchingPattern(t *testing.T) {
@@ -42,6 +54,30 @@ func TestToFloat_NonNumericString(t *testing.T) {
 	}
 }
 
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected float64
+		ok       bool
+	}{
+		{"string", "12.5", 12.5, true},
+		{"blank string", "", 0, false},
+		{"float64", 3.25, 3.25, true},
+		{"int", 7, 7, true},
+		{"int32", int32(8), 8, true},
+		{"int64", int64(1234567890123), 1234567890123, true},
+		{"nil", nil, 0, false},
+		{"bool", true, 0, false},
+	}
+	for _, test := range tests {
+		result, ok := parseFloat(test.value)
+		if result != test.expected || ok != test.ok {
+			t.Errorf("%s: expected (%v, %v), got (%v, %v)", test.name, test.expected, test.ok, result, ok)
+		}
+	}
+}
+
 func TestToStringArray(t *testing.T) {
 	input := primitive.A{"one", "two", "three"}
 	expected := []string{"one", "two", "three"}
@@ -59,3 +95,822 @@ func TestNormalizeString(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, result)
 	}
 }
+
+func TestDebugHTMLKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://www.screener.in/company/TCS/consolidated/", "TCS_consolidated"},
+		{"https://www.screener.in/company/TCS/", "TCS"},
+		{"https://www.screener.in/company/M&M/consolidated/", "M_M_consolidated"},
+	}
+	for _, test := range tests {
+		if result := DebugHTMLKey(test.input); result != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.input, test.expected, result)
+		}
+	}
+}
+
+const balanceSheetFixture = `<section id="balance-sheet"><div data-result-table><table>
+<thead><tr><th></th><th>Mar 2023</th><th>Mar 2024</th></tr></thead>
+<tbody>
+<tr><td class="text">Equity Capital</td><td>100</td><td>100</td></tr>
+<tr><td class="text">Borrowings&nbsp;+</td><td>50</td><td>70</td></tr>
+<tr class="sub"><td class="text">Secured Loan</td><td>20</td><td>30</td></tr>
+<tr class="sub"><td class="text">Unsecured Loan</td><td>30</td><td>40</td></tr>
+<tr><td class="text">Total Assets</td><td>500</td><td>600</td></tr>
+</tbody></table></div></section>`
+
+func TestParseTableRows_NestedRows(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(balanceSheetFixture))
+	if err != nil {
+		t.Fatalf("Error parsing fixture: %v", err)
+	}
+	rows := ParseTableRows(doc.Find("section#balance-sheet"), "div[data-result-table]")
+
+	labels := []string{}
+	for _, row := range rows {
+		labels = append(labels, row.Label)
+	}
+	expected := []string{"Equity Capital", "Borrowings\u00a0+", "Total Assets"}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("Expected %v, got %v", expected, labels)
+	}
+	if len(rows[1].Children) != 2 || rows[1].Children[0].Label != "Secured Loan" {
+		t.Errorf("Expected Borrowings to have the secured/unsecured children, got %v", rows[1].Children)
+	}
+	if len(rows[2].Children) != 0 {
+		t.Errorf("Expected Total Assets to have no children, got %v", rows[2].Children)
+	}
+}
+
+func TestParseSectionTable_Empty(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		expected error
+	}{
+		{"no table", `<section id="balance-sheet"><p>Balance sheet</p></section>`, ErrSectionTableNotFound},
+		{"no rows", `<section id="balance-sheet"><div data-result-table><table><thead><tr><th></th><th>Mar 2024</th></tr></thead><tbody></tbody></table></div></section>`, ErrSectionNoDataRows},
+		{"unlabelled rows", `<section id="balance-sheet"><div data-result-table><table><tbody>
+<tr><td>100</td><td>200</td></tr>
+<tr><td class="text">Equity Capital</td><td></td></tr>
+</tbody></table></div></section>`, ErrSectionNoDataRows},
+	}
+	for _, test := range tests {
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(test.fixture))
+		rows, err := ParseSectionTable(doc.Find("section#balance-sheet"), "div[data-result-table]")
+		if !errors.Is(err, test.expected) || rows != nil {
+			t.Errorf("%s: expected %v, got %v (%v)", test.name, test.expected, err, rows)
+		}
+	}
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(balanceSheetFixture))
+	rows, err := ParseSectionTable(doc.Find("section#balance-sheet"), "div[data-result-table]")
+	if err != nil || len(rows) != 3 {
+		t.Errorf("Expected the 3 balance sheet rows, got %v (%v)", rows, err)
+	}
+}
+
+func TestParseTableData_Empty(t *testing.T) {
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<section id="ratios"><div data-result-table><table><tbody><tr><td class="text"></td></tr></tbody></table></div></section>`))
+	if data, err := ParseTableData(doc.Find("section#ratios"), "div[data-result-table]"); !errors.Is(err, ErrSectionNoDataRows) || data != nil {
+		t.Errorf("Expected no data rows, got %v (%v)", data, err)
+	}
+}
+
+func TestGetNestedArrayField_TableRows(t *testing.T) {
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(balanceSheetFixture))
+	rows := ParseTableRows(doc.Find("section#balance-sheet"), "div[data-result-table]")
+
+	// Round trip through Mongo's representation of the rows
+	stored := primitive.A{}
+	for _, row := range rows {
+		children := primitive.A{}
+		for _, child := range row.Children {
+			children = append(children, bson.M{"label": child.Label, "values": primitive.A{child.Values[0], child.Values[1]}})
+		}
+		stored = append(stored, bson.M{"label": row.Label, "values": primitive.A{row.Values[0], row.Values[1]}, "children": children})
+	}
+	stock := map[string]interface{}{"balanceSheet": stored}
+
+	result, err := getNestedArrayField(stock, "balanceSheet", "Borrowings +")
+	if err != nil || !reflect.DeepEqual(result, primitive.A{"50", "70"}) {
+		t.Errorf("Expected [50 70], got %v (%v)", result, err)
+	}
+	result, err = getNestedArrayField(stock, "balanceSheet", "Borrowings +", "Unsecured Loan")
+	if err != nil || !reflect.DeepEqual(result, primitive.A{"30", "40"}) {
+		t.Errorf("Expected [30 40], got %v (%v)", result, err)
+	}
+	if _, err := getNestedArrayField(stock, "balanceSheet", "Reserves"); err == nil {
+		t.Errorf("Expected an error for a missing row")
+	}
+
+	// The label keyed map view keeps working for older documents
+	legacy := map[string]interface{}{"balanceSheet": bson.M{"Total Assets": primitive.A{"500", "600"}}}
+	result, err = getNestedArrayField(legacy, "balanceSheet", "Total Assets")
+	if err != nil || !reflect.DeepEqual(result, primitive.A{"500", "600"}) {
+		t.Errorf("Expected [500 600], got %v (%v)", result, err)
+	}
+}
+
+func TestComputeSectorBenchmark(t *testing.T) {
+	docs := []map[string]interface{}{
+		{"stockPE": "10", "roce": "12", "dividendYield": "1", "marketCap": "1,000"},
+		{"stockPE": "20", "roce": "18", "dividendYield": "2", "marketCap": "3,000"},
+		{"stockPE": "", "roce": "30", "dividendYield": "0", "marketCap": "5,000"},
+	}
+	result := ComputeSectorBenchmark(" Finance  - NBFC ", docs)
+	expected := SectorBenchmark{Sector: "finance - nbfc", Count: 3, PE: 15, MarketCap: 3000, DividendYield: 1, ROCE: 18}
+	if result != expected {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestParseSector(t *testing.T) {
+	tests := map[string]string{
+		// Current pages title the sector link
+		`<section id="peers"><p class="sub"><a title="Broad Sector">Financials</a> <a title="Sector">Finance - NBFC</a> <a title="Industry">Non Banking Financial Company (NBFC)</a></p></section>`: "finance - nbfc",
+		// Older pages spell it out, the link text may wrap over several lines
+		`<section id="peers"><p class="sub">Sector: <a href="/company/compare/00000034/">IT -
+			Software</a> Industry: <a href="/company/compare/00000034/00000027/">Computers - Software - Large</a></p></section>`: "it - software",
+		`<section id="peers"><p class="sub">Sector: <a>Banks</a></p></section>`:           "banks",
+		`<section id="peers"><p class="sub">Industry: <a>Banks</a></p></section>`:         "",
+		`<section id="analysis"><p class="sub"><a title="Sector">Banks</a></p></section>`: "",
+	}
+	for fixture, expected := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(fixture))
+		if err != nil {
+			t.Fatalf("Error parsing fixture: %v", err)
+		}
+		if sector := ParseSector(doc); sector != expected {
+			t.Errorf("Expected %q, got %q for %s", expected, sector, fixture)
+		}
+	}
+}
+
+func TestCompareWithPeers_DividendTrap(t *testing.T) {
+	peers := primitive.A{
+		bson.M{"pe": "20", "div_yield": "2", "roce": "20"},
+		bson.M{"pe": "20", "div_yield": "3", "roce": "20"},
+		bson.M{"pe": "20", "div_yield": "2.5", "roce": "20"},
+	}
+	inline := types.Stock{PE: 20, DividendYield: 2.5, ROCE: 20}
+	healthy := types.Stock{PE: 20, DividendYield: 4, ROCE: 20}
+	trap := types.Stock{PE: 20, DividendYield: 25, ROCE: 20}
+
+	if compareWithPeers(healthy, peers, nil) <= compareWithPeers(inline, peers, nil) {
+		t.Errorf("Expected a yield above peers within the band to score")
+	}
+	if result, baseline := compareWithPeers(trap, peers, nil), compareWithPeers(types.Stock{PE: 20, ROCE: 20}, peers, nil); result != baseline {
+		t.Errorf("Expected a trap-level yield not to inflate the score, got %v instead of %v", result, baseline)
+	}
+	if result := CompareWithSector(trap, SectorBenchmark{Count: 5, PE: 10, DividendYield: 2, ROCE: 30, MarketCap: 1}); result != 0 {
+		t.Errorf("Expected a trap-level yield not to score against the sector, got %v", result)
+	}
+
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"DIVIDEND_YIELD_MAX": "30"}))
+	if compareWithPeers(trap, peers, nil) <= compareWithPeers(inline, peers, nil) {
+		t.Errorf("Expected a 25%% yield to score within a 0 to 30 band")
+	}
+}
+
+func TestCompareWithSector(t *testing.T) {
+	benchmark := SectorBenchmark{Count: 5, PE: 20, MarketCap: 5000, DividendYield: 1, ROCE: 15}
+
+	strong := types.Stock{PE: 12, MarketCap: 8000, DividendYield: 2, ROCE: 25}
+	if result := CompareWithSector(strong, benchmark); result != 30 {
+		t.Errorf("Expected 30, got %v", result)
+	}
+
+	weak := types.Stock{PE: 35, MarketCap: 1000, DividendYield: 0.5, ROCE: 8}
+	if result := CompareWithSector(weak, benchmark); result != 0 {
+		t.Errorf("Expected 0, got %v", result)
+	}
+
+	if result := CompareWithSector(strong, SectorBenchmark{Count: 1}); result != 0 {
+		t.Errorf("Expected a single company sector to be ignored, got %v", result)
+	}
+}
+
+func TestReadXLSXSheets_MalformedFile(t *testing.T) {
+	_, err := ReadXLSXSheets(strings.NewReader("this is not a spreadsheet"))
+	if err == nil {
+		t.Fatalf("Expected an error for a malformed workbook")
+	}
+
+	entry := StreamErrorEntry("broken.xlsx", "", err)
+	if entry["type"] != "error" || entry["file"] != "broken.xlsx" || !strings.Contains(entry["error"].(string), "could not open workbook") {
+		t.Errorf("Expected a clear error entry, got %v", entry)
+	}
+	if _, ok := entry["sheet"]; ok {
+		t.Errorf("Expected no sheet for a workbook level error, got %v", entry)
+	}
+}
+
+func TestReadXLSXSheets_CorruptSheet(t *testing.T) {
+	workbook := excelize.NewFile()
+	workbook.SetCellValue("Sheet1", "A1", "Name of the Instrument")
+	workbook.NewSheet("Sheet2")
+	workbook.SetCellValue("Sheet2", "A1", "Name of the Instrument")
+	var original bytes.Buffer
+	if err := workbook.Write(&original); err != nil {
+		t.Fatalf("Error writing fixture: %v", err)
+	}
+
+	// Rebuild the archive with an unreadable second sheet
+	reader, err := zip.NewReader(bytes.NewReader(original.Bytes()), int64(original.Len()))
+	if err != nil {
+		t.Fatalf("Error reading fixture: %v", err)
+	}
+	var corrupted bytes.Buffer
+	writer := zip.NewWriter(&corrupted)
+	for _, entry := range reader.File {
+		w, _ := writer.Create(entry.Name)
+		if entry.Name == "xl/worksheets/sheet2.xml" {
+			w.Write([]byte("<worksheet><sheetData><row><c"))
+			continue
+		}
+		r, _ := entry.Open()
+		io.Copy(w, r)
+		r.Close()
+	}
+	writer.Close()
+
+	sheets, err := ReadXLSXSheets(bytes.NewReader(corrupted.Bytes()))
+	if err != nil {
+		t.Fatalf("Expected the workbook to open, got %v", err)
+	}
+	if len(sheets) != 2 {
+		t.Fatalf("Expected 2 sheets, got %d", len(sheets))
+	}
+	if sheets[0].Err != nil || len(sheets[0].Rows) != 1 || sheets[0].Rows[0][0] != "Name of the Instrument" {
+		t.Errorf("Expected the readable sheet to be recovered, got %v (%v)", sheets[0].Rows, sheets[0].Err)
+	}
+	if sheets[1].Err == nil {
+		t.Errorf("Expected an error for the corrupt sheet")
+	}
+}
+
+func protectedWorkbook(t *testing.T, password string) []byte {
+	workbook := excelize.NewFile()
+	workbook.SetCellValue("Sheet1", "A1", "Name of the Instrument")
+	var buf bytes.Buffer
+	if err := workbook.Write(&buf, excelize.Options{Password: password}); err != nil {
+		t.Fatalf("Error writing protected fixture: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSXSheets_PasswordProtected(t *testing.T) {
+	fixture := protectedWorkbook(t, "secret")
+	if !IsEncryptedXLSX(fixture) {
+		t.Fatalf("Expected the fixture to be detected as protected")
+	}
+
+	if _, err := ReadXLSXSheets(bytes.NewReader(fixture)); !errors.Is(err, ErrXLSXPasswordRequired) {
+		t.Errorf("Expected ErrXLSXPasswordRequired, got %v", err)
+	}
+	if _, err := ReadXLSXSheets(bytes.NewReader(fixture), excelize.Options{Password: "wrong"}); !errors.Is(err, ErrXLSXWrongPassword) {
+		t.Errorf("Expected ErrXLSXWrongPassword, got %v", err)
+	}
+
+	sheets, err := ReadXLSXSheets(bytes.NewReader(fixture), excelize.Options{Password: "secret"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sheets) != 1 || sheets[0].Rows[0][0] != "Name of the Instrument" {
+		t.Errorf("Expected the protected sheet to be read, got %v", sheets)
+	}
+}
+
+func TestCheckXLSXPassword(t *testing.T) {
+	fixture := protectedWorkbook(t, "secret")
+
+	if err := CheckXLSXPassword(bytes.NewReader(fixture), ""); !errors.Is(err, ErrXLSXPasswordRequired) {
+		t.Errorf("Expected ErrXLSXPasswordRequired, got %v", err)
+	}
+	if err := CheckXLSXPassword(bytes.NewReader(fixture), "wrong"); !errors.Is(err, ErrXLSXWrongPassword) {
+		t.Errorf("Expected ErrXLSXWrongPassword, got %v", err)
+	}
+	if err := CheckXLSXPassword(bytes.NewReader(fixture), "secret"); err != nil {
+		t.Errorf("Expected the correct password to pass, got %v", err)
+	}
+
+	// Corrupt files are not password problems
+	err := CheckXLSXPassword(strings.NewReader("this is not a spreadsheet"), "")
+	if err != nil || IsXLSXPasswordError(err) {
+		t.Errorf("Expected an unprotected file to pass the password check, got %v", err)
+	}
+	if _, err := ReadXLSXSheets(strings.NewReader("this is not a spreadsheet"), excelize.Options{Password: "secret"}); IsXLSXPasswordError(err) {
+		t.Errorf("Expected a corrupt file not to be reported as a password error, got %v", err)
+	}
+}
+
+func TestParseCompanyListQuery(t *testing.T) {
+	values := url.Values{
+		"marketCap":       {"Large Cap"},
+		"minScore":        {"5"},
+		"maxScore":        {"20.5"},
+		"minFScore":       {"6"},
+		"maxDebtToEquity": {"1.5"},
+		"sector":          {"  IT - Software "},
+		"fund":            {" Flexi Cap Fund "},
+		"sort":            {"-fScore"},
+		"limit":           {"1000"},
+		"offset":          {"20"},
+	}
+	query, err := ParseCompanyListQuery(values)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedFilter := bson.M{
+		"marketCapCategory": "Large Cap",
+		"sector":            "it - software",
+		"funds":             "Flexi Cap Fund",
+		"stockRate":         bson.M{"$gte": 5.0, "$lte": 20.5},
+		"fScore":            bson.M{"$gte": 6},
+		"debtToEquity":      bson.M{"$lte": 1.5},
+	}
+	if !reflect.DeepEqual(query.Filter(), expectedFilter) {
+		t.Errorf("Expected %v, got %v", expectedFilter, query.Filter())
+	}
+	expectedSort := primitive.D{{Key: "fScore", Value: -1}, {Key: "name", Value: 1}}
+	if !reflect.DeepEqual(query.Sort(), expectedSort) {
+		t.Errorf("Expected %v, got %v", expectedSort, query.Sort())
+	}
+	if query.Limit != 200 || query.Offset != 20 {
+		t.Errorf("Expected limit 200 and offset 20, got %v and %v", query.Limit, query.Offset)
+	}
+}
+
+func TestParseCompanyListQuery_MarketCapSort(t *testing.T) {
+	// The category text would sort "Small Cap" above "Large Cap", the stored number sorts by size
+	query, err := ParseCompanyListQuery(url.Values{"sort": {"-marketCap"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := primitive.D{{Key: "marketCapValue", Value: -1}, {Key: "name", Value: 1}}
+	if !reflect.DeepEqual(query.Sort(), expected) {
+		t.Errorf("Expected %v, got %v", expected, query.Sort())
+	}
+}
+
+func TestParseCompanyListQuery_Invalid(t *testing.T) {
+	for _, values := range []url.Values{
+		{"minScore": {"abc"}},
+		{"minDebtToEquity": {"low"}},
+		{"sort": {"url"}},
+		{"limit": {"-1"}},
+	} {
+		if _, err := ParseCompanyListQuery(values); err == nil {
+			t.Errorf("Expected an error for %v", values)
+		}
+	}
+}
+
+func TestParseNumber_Formats(t *testing.T) {
+	tests := []struct {
+		input    string
+		format   NumberFormat
+		expected float64
+	}{
+		{"1,234.56", NumberFormatUS, 1234.56},
+		{"1,234,567", NumberFormatUS, 1234567},
+		{"-42", NumberFormatUS, -42},
+		{"1.234,56", NumberFormatEU, 1234.56},
+		{"1,5", NumberFormatEU, 1.5},
+		{"1 234,5", NumberFormatEU, 1234.5},
+		{"1,23,456", NumberFormatIndian, 123456},
+		{"12,34,567.89", NumberFormatIndian, 1234567.89},
+		{"1,23,456", NumberFormatAuto, 123456},
+		{"1,234,567.5", NumberFormatAuto, 1234567.5},
+		{"1.234.567,5", NumberFormatAuto, 1234567.5},
+		{"1,5", NumberFormatAuto, 1.5},
+		{"12,75", NumberFormatAuto, 12.75},
+		{"1.234.567", NumberFormatAuto, 1234567},
+		{"3.14", NumberFormatAuto, 3.14},
+	}
+	for _, test := range tests {
+		result, err := ParseNumber(test.input, test.format)
+		if err != nil || result != test.expected {
+			t.Errorf("ParseNumber(%q, %v): expected %v, got %v (%v)", test.input, test.format, test.expected, result, err)
+		}
+	}
+}
+
+func TestParseAmountValue(t *testing.T) {
+	valid := map[string]float64{
+		"1,234":         1234,
+		" Rs. 1,500.50": 1500.5,
+		"₹12,34,567":    1234567,
+		"INR 250":       250,
+		"(1,000)":       -1000,
+	}
+	for input, expected := range valid {
+		if result, ok := ParseAmountValue(input); !ok || result != expected {
+			t.Errorf("ParseAmountValue(%q): expected %v, got %v (%v)", input, expected, result, ok)
+		}
+	}
+	for _, input := range []interface{}{"", "  ", "-", "N.A.", "12abc", "NaN", nil} {
+		if result, ok := ParseAmountValue(input); ok {
+			t.Errorf("ParseAmountValue(%q): expected it to be rejected, got %v", input, result)
+		}
+	}
+	if result, ok := ParseAmountValue(42.5); !ok || result != 42.5 {
+		t.Errorf("Expected a number to be kept, got %v (%v)", result, ok)
+	}
+
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"NUMBER_FORMAT": "eu"}))
+	if result, ok := ParseAmountValue("€ 1.234,5"); ok {
+		t.Errorf("Expected an unknown currency to be rejected, got %v", result)
+	}
+	if result, ok := ParseAmountValue("1.234,5"); !ok || result != 1234.5 {
+		t.Errorf("Expected the EU grouping to be used, got %v (%v)", result, ok)
+	}
+}
+
+func TestToFloat_NumberFormatFromEnv(t *testing.T) {
+	if result := ToFloat("1,5"); result != 15 {
+		t.Errorf("Expected the default format to strip commas, got %v", result)
+	}
+
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"NUMBER_FORMAT": "eu"}))
+	if result := ToFloat("1,5"); result != 1.5 {
+		t.Errorf("Expected 1.5, got %v", result)
+	}
+	if result := ToFloat("12,5%"); result != 12.5 {
+		t.Errorf("Expected 12.5, got %v", result)
+	}
+}
+
+func TestToFloat_WholePercent(t *testing.T) {
+	// The scrape stores "12.3%" as "12.3", every parser must read both the same way
+	for _, input := range []string{"12.3", "12.3%", " 12.3 % "} {
+		if result := ToFloat(input); result != 12.3 {
+			t.Errorf("Expected ToFloat(%q) to be 12.3, got %v", input, result)
+		}
+		if result, ok := parsePeerNumber(input); !ok || result != 12.3 {
+			t.Errorf("Expected parsePeerNumber(%q) to be 12.3, got %v", input, result)
+		}
+		if result := ParsePercentage(input); result != 12.3 {
+			t.Errorf("Expected ParsePercentage(%q) to be 12.3, got %v", input, result)
+		}
+	}
+
+	// A ROCE scraped with its sign compares to the one stored without
+	if ToFloat("18%") <= ToFloat("15") {
+		t.Errorf("Expected 18%% to compare above 15")
+	}
+}
+
+func TestIsDerivativeHolding(t *testing.T) {
+	derivatives := []string{
+		"Nifty 50 Index - Futures",
+		"RELIANCE INDUSTRIES LTD 27-JUN-2024 FUT",
+		"Bank Nifty Index Futures",
+		"NIFTY 22000 CE 27-Jun-2024",
+		"HDFC Bank Ltd Stock Futures",
+		"Nifty Index Options",
+	}
+	for _, name := range derivatives {
+		if !IsDerivativeHolding(map[string]interface{}{"Name of the Instrument": name}) {
+			t.Errorf("Expected %q to be a derivative", name)
+		}
+	}
+
+	equities := []string{"Reliance Industries Limited", "Future Retail Ltd.", "Infosys Limited"}
+	for _, name := range equities {
+		if IsDerivativeHolding(map[string]interface{}{"Name of the Instrument": name}) {
+			t.Errorf("Expected %q not to be a derivative", name)
+		}
+	}
+
+	withMargin := map[string]interface{}{"Name of the Instrument": "Nifty 50", "Margin": "1,234.00"}
+	if !IsDerivativeHolding(withMargin) {
+		t.Errorf("Expected a row with a margin value to be a derivative")
+	}
+}
+
+func TestIsForeignHolding(t *testing.T) {
+	foreign := []map[string]interface{}{
+		{"Name of the Instrument": "Alphabet Inc", "ISIN": "US02079K3059"},
+		{"Name of the Instrument": "Microsoft Corporation", "ISIN": " us5949181045 "},
+		{"Name of the Instrument": "ASML Holding", "ISIN": "NL0010273215"},
+		{"Name of the Instrument": "Tencent Holdings Ltd", "ISIN": "KYG875721634"},
+		{"Name of the Instrument": "Microsoft Corp"},
+		{"Name of the Instrument": "Taiwan Semiconductor Manufacturing (ADR)"},
+		{"Name of the Instrument": "Unilever PLC", "ISIN": "n/a"},
+	}
+	for _, stockDetail := range foreign {
+		if !IsForeignHolding(stockDetail) {
+			t.Errorf("Expected %v to be foreign", stockDetail)
+		}
+	}
+
+	domestic := []map[string]interface{}{
+		{"Name of the Instrument": "Infosys Limited", "ISIN": "INE009A01021"},
+		// An Indian ISIN wins over the name
+		{"Name of the Instrument": "Procter & Gamble Hygiene and Health Care Ltd Inc", "ISIN": "INE179A01014"},
+		// A mistyped country code fails the check digit, the name decides
+		{"Name of the Instrument": "Infosys Limited", "ISIN": "USE009A01021"},
+		{"Name of the Instrument": "Power Finance Corporation Ltd"},
+		{"Name of the Instrument": "Incredible Industries Ltd"},
+	}
+	for _, stockDetail := range domestic {
+		if IsForeignHolding(stockDetail) {
+			t.Errorf("Expected %v not to be foreign", stockDetail)
+		}
+	}
+
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"FOREIGN_NAME_MARKERS": "AG"}))
+	if !IsForeignHolding(map[string]interface{}{"Name of the Instrument": "Siemens AG"}) || IsForeignHolding(map[string]interface{}{"Name of the Instrument": "Microsoft Corp"}) {
+		t.Errorf("Expected the configured markers to replace the defaults")
+	}
+}
+
+func TestPortfolioSummary(t *testing.T) {
+	summary := NewPortfolioSummary()
+	summary.Add(map[string]interface{}{"classification": HoldingEquity, "Market/Fair Value": "1,000.50", "Percentage of AUM": "2.5%"})
+	summary.Add(map[string]interface{}{"classification": HoldingEquity, "Market/Fair Value": "500", "Percentage of AUM": "1.5"})
+	summary.Add(map[string]interface{}{"classification": HoldingDerivative, "Market/Fair Value": "-250", "Percentage of AUM": "-0.5%"})
+
+	expectedEquity := ExposureTotals{Holdings: 2, MarketValue: 1500.5, PercentageOfAUM: 4}
+	if *summary.Exposure[HoldingEquity] != expectedEquity {
+		t.Errorf("Expected %v, got %v", expectedEquity, *summary.Exposure[HoldingEquity])
+	}
+	expectedDerivative := ExposureTotals{Holdings: 1, MarketValue: -250, PercentageOfAUM: -0.5}
+	if *summary.Exposure[HoldingDerivative] != expectedDerivative {
+		t.Errorf("Expected %v, got %v", expectedDerivative, *summary.Exposure[HoldingDerivative])
+	}
+}
+
+func TestPortfolioSummary_AUMTotals(t *testing.T) {
+	summary := NewPortfolioSummary()
+	for sheet, total := range map[string]float64{"Bluechip": 99.98, "Midcap": 45.5, "Liquid": 112} {
+		summary.AddAUMTotal(sheet, total)
+	}
+
+	plausible := map[string]bool{}
+	for _, aumTotal := range summary.AUMTotals {
+		plausible[aumTotal.Sheet] = aumTotal.Plausible
+	}
+	if !plausible["Bluechip"] || plausible["Midcap"] || plausible["Liquid"] {
+		t.Errorf("Expected only totals within 90-110%% to be plausible, got %v", summary.AUMTotals)
+	}
+	if totals, _ := summary.Entry("holdings.xlsx")["aumTotals"].([]AUMTotal); len(totals) != 3 {
+		t.Errorf("Expected the summary entry to report every sheet, got %v", totals)
+	}
+}
+
+func quarterlyFixture(rows map[string][]string) bson.M {
+	months := []string{"Jun 2023", "Sep 2023", "Dec 2023", "Mar 2024"}
+	data := bson.M{}
+	for metric, values := range rows {
+		quarters := primitive.A{}
+		for i, value := range values {
+			quarters = append(quarters, bson.M{months[i]: value})
+		}
+		data[metric] = quarters
+	}
+	return data
+}
+
+func TestAnalyzeTrend_MetricDirections(t *testing.T) {
+	improving := quarterlyFixture(map[string][]string{
+		"Sales\u00a0+":      {"100", "110", "120", "130"},
+		"Net Profit\u00a0+": {"10", "12", "14", "16"},
+		"Interest":          {"5", "4", "3", "2"},
+		"Tax %":             {"20%", "25%", "30%", "35%"},
+	})
+	if result := AnalyzeTrend(types.Stock{}, improving); result != 5 {
+		t.Errorf("Expected 5, got %v", result)
+	}
+
+	// Rising debt cost counts against the stock even though the number goes up
+	worsening := quarterlyFixture(map[string][]string{
+		"Interest": {"2", "3", "4", "5"},
+		"Raw PDF":  {"", "", "", ""},
+	})
+	if result := AnalyzeTrend(types.Stock{}, worsening); result != -5 {
+		t.Errorf("Expected -5, got %v", result)
+	}
+
+	mixed := quarterlyFixture(map[string][]string{
+		"Sales\u00a0+": {"100", "90", "", "120"},
+	})
+	if result := AnalyzeTrend(types.Stock{}, mixed); result != -5 {
+		t.Errorf("Expected non numeric quarters to be skipped, got %v", result)
+	}
+}
+
+func TestAnalyzeTrend_ConfiguredDirections(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"TREND_METRIC_DIRECTIONS": `{"Sales": 0, "Depreciation": -1}`}))
+	data := quarterlyFixture(map[string][]string{
+		"Sales\u00a0+": {"100", "110", "120", "130"},
+		"Depreciation": {"1", "2", "3", "4"},
+	})
+	if result := AnalyzeTrend(types.Stock{}, data); result != -5 {
+		t.Errorf("Expected -5, got %v", result)
+	}
+}
+
+func TestAnalyzeTrend_TrailingTTM(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+
+	// Sales rise every quarter, the partial TTM period reads as a drop
+	withTTM := quarterlyFixture(map[string][]string{"Sales\u00a0+": {"100", "110", "120", "130"}})
+	withTTM["Sales\u00a0+"] = append(withTTM["Sales\u00a0+"].(primitive.A), bson.M{"TTM": "60"})
+	withoutTTM := quarterlyFixture(map[string][]string{"Sales\u00a0+": {"100", "110", "120", "130"}})
+
+	config.Set(config.FromMap(map[string]string{}))
+	if result := AnalyzeTrend(types.Stock{}, withTTM); result != 2.5 {
+		t.Errorf("Expected the TTM column to be compared by default, got %v", result)
+	}
+
+	config.Set(config.FromMap(map[string]string{"TREND_EXCLUDE_TTM": "true"}))
+	if result := AnalyzeTrend(types.Stock{}, withTTM); result != 5 {
+		t.Errorf("Expected the TTM column to be left out when excluded, got %v", result)
+	}
+	if result := AnalyzeTrend(types.Stock{}, withoutTTM); result != 5 {
+		t.Errorf("Expected every quarter to be compared without a TTM column, got %v", result)
+	}
+	if result := AnalyzeTrend(types.Stock{}, withoutTTM); result != 5 {
+		t.Errorf("Expected a table without TTM column to be unaffected, got %v", result)
+	}
+}
+
+func TestCompanyLookupFilter(t *testing.T) {
+	expected := bson.M{"$or": []bson.M{{"isin": "INE002A01018"}, {"name": "ine002a01018"}}}
+	if result := CompanyLookupFilter(" ine002a01018 "); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestStaleCompanyFilter(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := cutoff.Add(7 * 24 * time.Hour)
+	filter := StaleCompanyFilter(cutoff, now)
+
+	conditions, ok := filter["$and"].([]bson.M)
+	if !ok || len(conditions) != 2 {
+		t.Fatalf("Expected two conditions, got %v", filter)
+	}
+	expected := bson.M{"$or": []bson.M{
+		{"lastScraped": bson.M{"$lt": cutoff}},
+		{"lastScraped": bson.M{"$exists": false}},
+	}}
+	if !reflect.DeepEqual(conditions[0], expected) {
+		t.Errorf("Expected documents older than the cutoff or without lastScraped, got %v", conditions[0])
+	}
+	expected = bson.M{"$or": []bson.M{
+		{"nextRefreshAt": bson.M{"$exists": false}},
+		{"nextRefreshAt": bson.M{"$lte": now}},
+	}}
+	if !reflect.DeepEqual(conditions[1], expected) {
+		t.Errorf("Expected documents not backing off, got %v", conditions[1])
+	}
+}
+
+func TestRefreshFailureUpdate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	backoff := Backoff{Initial: 6 * time.Hour, Max: 7 * 24 * time.Hour}
+	tests := []struct {
+		name     string
+		doc      map[string]interface{}
+		expected time.Duration
+	}{
+		{"first failure", map[string]interface{}{"name": "TCS"}, 6 * time.Hour},
+		{"third failure", map[string]interface{}{"name": "TCS", "refreshFailures": int32(2)}, 24 * time.Hour},
+		{"capped", map[string]interface{}{"name": "TCS", "refreshFailures": int64(10)}, 7 * 24 * time.Hour},
+	}
+
+	for _, test := range tests {
+		update := RefreshFailureUpdate(test.doc, backoff, now)
+		set, _ := update["$set"].(bson.M)
+		if set["lastRefreshAttempt"] != now {
+			t.Errorf("%s: expected the attempt at %v, got %v", test.name, now, set["lastRefreshAttempt"])
+		}
+		if next := now.Add(test.expected); set["nextRefreshAt"] != next {
+			t.Errorf("%s: expected the next refresh at %v, got %v", test.name, next, set["nextRefreshAt"])
+		}
+		if !reflect.DeepEqual(update["$inc"], bson.M{"refreshFailures": 1}) {
+			t.Errorf("%s: expected the failures to be counted, got %v", test.name, update["$inc"])
+		}
+	}
+}
+
+func TestHasFundamentals(t *testing.T) {
+	if HasFundamentals(map[string]interface{}{"name": "TCS", "url": "/company/TCS/"}) {
+		t.Errorf("Expected an invalidated company to have no fundamentals")
+	}
+	if !HasFundamentals(map[string]interface{}{"name": "TCS", "marketCap": "1,000"}) {
+		t.Errorf("Expected a scraped company to have fundamentals")
+	}
+}
+
+func TestUsableStoredData(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	fresh := map[string]interface{}{"name": "TCS", "marketCap": "1,000", "lastScraped": primitive.NewDateTimeFromTime(now.Add(-24 * time.Hour))}
+	stale := map[string]interface{}{"name": "TCS", "marketCap": "1,000", "lastScraped": now.Add(-60 * 24 * time.Hour)}
+	legacy := map[string]interface{}{"name": "TCS", "marketCap": "1,000"}
+	maxAge := 30 * 24 * time.Hour
+
+	if !UsableStoredData(fresh, maxAge, now) {
+		t.Errorf("Expected a fresh match to use the stored data")
+	}
+	if UsableStoredData(stale, maxAge, now) {
+		t.Errorf("Expected a stale match to be scraped again")
+	}
+	if UsableStoredData(legacy, maxAge, now) {
+		t.Errorf("Expected a match without lastScraped to be scraped again")
+	}
+	if !UsableStoredData(stale, 0, now) || !UsableStoredData(legacy, 0, now) {
+		t.Errorf("Expected any age to be used without a max age")
+	}
+	if UsableStoredData(map[string]interface{}{"name": "TCS", "lastScraped": now}, maxAge, now) {
+		t.Errorf("Expected an invalidated company to be scraped again")
+	}
+}
+
+const shareholdingFixture = `<section id="shareholding"><div id="quarterly-shp"><table>
+<thead><tr><th></th><th>Sep 2023</th><th>Dec 2023</th><th>Mar 2024</th></tr></thead>
+<tbody>
+<tr><td class="text">Promoters&nbsp;+</td><td>55.10%</td><td>54.20%</td><td>52.00%</td></tr>
+<tr><td class="text">Pledged</td><td>1.00%</td><td>2.50%</td><td>4.00%</td></tr>
+<tr><td class="text">FIIs&nbsp;+</td><td>20.00%</td><td>21.00%</td><td>22.00%</td></tr>
+</tbody></table></div></section>`
+
+func TestParseShareholdingPattern_Empty(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		expected error
+	}{
+		{"no tables", `<section id="shareholding"><div id="quarterly-shp"></div></section>`, ErrSectionTableNotFound},
+		{"no rows", `<section id="shareholding"><div id="quarterly-shp"><table><thead><tr><th></th><th>Mar 2024</th></tr></thead><tbody></tbody></table></div></section>`, ErrSectionNoDataRows},
+		{"uncategorized rows", `<section id="shareholding"><div id="yearly-shp"><table><tbody><tr><td>52.00%</td></tr></tbody></table></div></section>`, ErrSectionNoDataRows},
+	}
+	for _, test := range tests {
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(test.fixture))
+		pattern, err := ParseShareholdingPattern(doc.Find("section#shareholding"))
+		if !errors.Is(err, test.expected) || len(pattern) != 0 {
+			t.Errorf("%s: expected %v, got %v (%v)", test.name, test.expected, err, pattern)
+		}
+	}
+}
+
+func TestAnalyzeShareholding_Fixture(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(shareholdingFixture))
+	if err != nil {
+		t.Fatalf("Error parsing fixture: %v", err)
+	}
+	pattern, err := ParseShareholdingPattern(doc.Find("section#shareholding"))
+	if err != nil {
+		t.Fatalf("Error parsing shareholding: %v", err)
+	}
+	analysis := AnalyzeShareholding(pattern)
+
+	if analysis.Promoter.Trend != TrendDecreasing || analysis.Promoter.First != 55.1 || analysis.Promoter.Latest != 52 {
+		t.Errorf("Expected a decreasing promoter holding from 55.1 to 52, got %+v", analysis.Promoter)
+	}
+	if analysis.Pledge.Trend != TrendIncreasing {
+		t.Errorf("Expected an increasing pledge, got %+v", analysis.Pledge)
+	}
+	if score := ShareholdingScore(analysis); score != -20 {
+		t.Errorf("Expected -20, got %v", score)
+	}
+}
+
+func TestAnalyzeShareholding_NoPledgeData(t *testing.T) {
+	pattern := bson.M{"quarterly": primitive.A{
+		bson.M{"category": "Promoters\u00a0+", "values": bson.M{"Mar 2024": "60.00%", "Dec 2023": "58.00%"}},
+	}}
+	analysis := AnalyzeShareholding(pattern)
+
+	if analysis.Promoter.Trend != TrendIncreasing {
+		t.Errorf("Expected an increasing promoter holding, got %+v", analysis.Promoter)
+	}
+	if analysis.Pledge.Trend != TrendUnavailable {
+		t.Errorf("Expected the pledge trend to be unavailable, got %+v", analysis.Pledge)
+	}
+	if score := ShareholdingScore(analysis); score != 5 {
+		t.Errorf("Expected 5, got %v", score)
+	}
+	if analysis := AnalyzeShareholding(nil); analysis.Promoter.Trend != TrendUnavailable {
+		t.Errorf("Expected a missing pattern to be unavailable, got %+v", analysis)
+	}
+}
@@ -0,0 +1,79 @@
+package helpers
+
+// holdingSum is a cell summed when holdings sharing an ISIN are aggregated, with the parsed field
+// stored next to it (see holdingAmountFields), empty for % of AUM
+type holdingSum struct {
+	column, field string
+	parse         func(interface{}) (float64, bool)
+}
+
+var holdingSumColumns = []holdingSum{
+	{"Quantity", "quantity", ParseAmountValue},
+	{"Market/Fair Value", "marketValue", ParseAmountValue},
+	{"Percentage of AUM", "", parsePeerNumber},
+}
+
+// AggregateHoldingsByISIN merges the holdings of a sheet sharing an ISIN (share classes, partly
+// paid shares) into the first of them, so their exposure reads as one holding. The quantity,
+// market value and % of AUM cells are replaced by their sums, rounded to DECIMAL_PLACES, with the
+// parsed quantity and marketValue; cells that don't parse are left out of the sums. The merged
+// rows are kept, as extracted, under "breakdown". Holdings without an ISIN, or with one failing
+// ValidateISIN (a transcription error may share it with another company), are left alone.
+func AggregateHoldingsByISIN(holdings []map[string]interface{}) []map[string]interface{} {
+	aggregated := []map[string]interface{}{}
+	positions := map[string]int{}
+	for _, stockDetail := range holdings {
+		isin, _ := HoldingISIN(stockDetail)
+		position, seen := positions[isin]
+		if isin == "" || !seen {
+			if isin != "" {
+				positions[isin] = len(aggregated)
+			}
+			aggregated = append(aggregated, stockDetail)
+			continue
+		}
+
+		consolidated := aggregated[position]
+		if _, ok := consolidated["breakdown"]; !ok {
+			consolidated = aggregatedHolding(consolidated)
+			aggregated[position] = consolidated
+		}
+		consolidated["breakdown"] = append(consolidated["breakdown"].([]map[string]interface{}), stockDetail)
+		for _, sum := range holdingSumColumns {
+			addHoldingAmount(consolidated, stockDetail, sum)
+		}
+	}
+	return aggregated
+}
+
+// aggregatedHolding copies the first holding of an ISIN into the consolidated holding, the first
+// entry of its breakdown
+func aggregatedHolding(first map[string]interface{}) map[string]interface{} {
+	consolidated := make(map[string]interface{}, len(first)+1)
+	for key, value := range first {
+		consolidated[key] = value
+	}
+	consolidated["breakdown"] = []map[string]interface{}{first}
+	for _, sum := range holdingSumColumns {
+		consolidated[sum.column] = nil
+		if sum.field != "" {
+			consolidated[sum.field] = nil
+		}
+		addHoldingAmount(consolidated, first, sum)
+	}
+	return consolidated
+}
+
+// addHoldingAmount adds a row's cell to the consolidated sum, a sum stays nil until a cell parses
+func addHoldingAmount(consolidated, row map[string]interface{}, sum holdingSum) {
+	value, ok := sum.parse(row[sum.column])
+	if !ok {
+		return
+	}
+	total, _ := consolidated[sum.column].(float64)
+	total = Round(total + value)
+	consolidated[sum.column] = total
+	if sum.field != "" {
+		consolidated[sum.field] = total
+	}
+}
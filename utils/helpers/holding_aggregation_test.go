@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"testing"
+)
+
+func TestAggregateHoldingsByISIN(t *testing.T) {
+	rows := sheetFixture(t, [][]interface{}{
+		{"Name of the Instrument", "ISIN", "Quantity", "Market/Fair Value", "% to Net Assets"},
+		{"Reliance Industries Limited", "INE002A01018", "1,000", "2,500.10", "1.20%"},
+		{"Infosys Limited", "INE009A01021", "500", "750.00", "0.40%"},
+		{"Reliance Industries Limited (Partly Paid)", "ine002a01018", "200", "250.20", "0.15%"},
+		{"Unlisted Holding", "", "10", "1.00", "0.01%"},
+		{"Other Unlisted Holding", "", "20", "2.00", "0.02%"},
+	})
+
+	holdings := AggregateHoldingsByISIN(ExtractHoldings(rows))
+	if len(holdings) != 4 {
+		t.Fatalf("Expected the two Reliance rows to merge into 4 holdings, got %d: %v", len(holdings), holdings)
+	}
+
+	reliance := holdings[0]
+	if reliance["Name of the Instrument"] != "Reliance Industries Limited" {
+		t.Errorf("Expected the first row's name, got %v", reliance["Name of the Instrument"])
+	}
+	if reliance["quantity"] != 1200.0 || reliance["Quantity"] != 1200.0 {
+		t.Errorf("Expected a quantity of 1200, got %v and %v", reliance["quantity"], reliance["Quantity"])
+	}
+	if reliance["marketValue"] != 2750.3 || ParseAmount(reliance["Market/Fair Value"]) != 2750.3 {
+		t.Errorf("Expected a market value of 2750.3, got %v and %v", reliance["marketValue"], reliance["Market/Fair Value"])
+	}
+	if ParsePercentage(reliance["Percentage of AUM"]) != 1.35 {
+		t.Errorf("Expected 1.35%% of AUM, got %v", reliance["Percentage of AUM"])
+	}
+
+	breakdown, ok := reliance["breakdown"].([]map[string]interface{})
+	if !ok || len(breakdown) != 2 {
+		t.Fatalf("Expected the two rows in the breakdown, got %v", reliance["breakdown"])
+	}
+	if breakdown[0]["quantity"] != 1000.0 || breakdown[1]["Name of the Instrument"] != "Reliance Industries Limited (Partly Paid)" || breakdown[1]["Percentage of AUM"] != "0.15%" {
+		t.Errorf("Expected the rows as extracted, got %v", breakdown)
+	}
+	if _, nested := breakdown[0]["breakdown"]; nested {
+		t.Errorf("Expected the breakdown rows to be left untouched, got %v", breakdown[0])
+	}
+
+	// Holdings with their own ISIN or none are kept apart
+	if _, ok := holdings[1]["breakdown"]; ok || holdings[1]["quantity"] != 500.0 {
+		t.Errorf("Expected Infosys unchanged, got %v", holdings[1])
+	}
+	if holdings[2]["Name of the Instrument"] != "Unlisted Holding" || holdings[3]["Name of the Instrument"] != "Other Unlisted Holding" {
+		t.Errorf("Expected holdings without an ISIN to stay apart, got %v, %v", holdings[2], holdings[3])
+	}
+}
+
+func TestAggregateHoldingsByISIN_InvalidISIN(t *testing.T) {
+	// A transcription error may give two companies the same ISIN, failing its check digit
+	rows := sheetFixture(t, [][]interface{}{
+		{"Name of the Instrument", "ISIN", "Quantity", "Market/Fair Value", "% to Net Assets"},
+		{"Infosys Limited", "INE009A01022", "500", "750.00", "0.40%"},
+		{"Wipro Limited", "INE009A01022", "300", "150.00", "0.10%"},
+	})
+
+	holdings := AggregateHoldingsByISIN(ExtractHoldings(rows))
+	if len(holdings) != 2 {
+		t.Fatalf("Expected the holdings with an invalid ISIN to stay apart, got %v", holdings)
+	}
+	if _, ok := holdings[0]["breakdown"]; ok || holdings[0]["quantity"] != 500.0 || holdings[1]["quantity"] != 300.0 {
+		t.Errorf("Expected both holdings unchanged, got %v", holdings)
+	}
+}
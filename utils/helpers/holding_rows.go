@@ -0,0 +1,230 @@
+package helpers
+
+import (
+	"regexp"
+	"stockbackend/config"
+	"strings"
+)
+
+var (
+	instrumentNameHeader = []string{`name\s*of\s*(the)?\s*instrument`}
+	isinHeader           = []string{`isin`}
+
+	isinPattern = regexp.MustCompile(`\b[A-Z]{2}[A-Z0-9]{9}[0-9]\b`)
+)
+
+// holdingAmountFields maps the amount columns onto the numeric fields stored next to their raw cells
+var holdingAmountFields = map[string]string{
+	"Quantity":          "quantity",
+	"Market/Fair Value": "marketValue",
+}
+
+// nameSeparators are the characters left around a name once the ISIN is cut out of a merged cell
+const nameSeparators = " \t-/|,:;()[]\u00a0"
+
+// SplitNameAndISIN separates an instrument name from an ISIN written in the same cell,
+// e.g. "Infosys Limited (INE009A01021)" or "INE009A01021 - Infosys Limited".
+// The ISIN is empty when the cell doesn't contain one.
+func SplitNameAndISIN(cell string) (string, string) {
+	isin := isinPattern.FindString(cell)
+	if isin == "" {
+		return strings.TrimSpace(cell), ""
+	}
+	name := strings.Replace(cell, isin, " ", 1)
+	name = strings.Join(strings.Fields(name), " ")
+	return strings.Trim(name, nameSeparators), isin
+}
+
+// BuildHeaderMap maps the standard holding fields onto the columns of a header row, in whatever
+// order they appear. It reports false when the row isn't the header (no instrument name column).
+// A merged "Name of the Instrument / ISIN" column is mapped as the name column, the ISIN is split
+// out of its cells by ExtractHoldings.
+func BuildHeaderMap(row []string) (map[string]int, bool) {
+	headerMap := make(map[string]int)
+	found := false
+	for i, headerCell := range row {
+		normalizedHeader := NormalizeString(headerCell)
+		// Map possible variations to standard keys
+		switch {
+		case MatchHeader(normalizedHeader, instrumentNameHeader):
+			headerMap["Name of the Instrument"] = i
+			found = true
+		case MatchHeader(normalizedHeader, isinHeader):
+			headerMap["ISIN"] = i
+		case MatchHeader(normalizedHeader, []string{`rating\s*/\s*industry`, `industry\s*/\s*rating`}):
+			headerMap["Industry/Rating"] = i
+		case MatchHeader(normalizedHeader, []string{`quantity`}):
+			headerMap["Quantity"] = i
+		case MatchHeader(normalizedHeader, []string{`market\s*/\s*fair\s*value.*`, `market\s*value.*`}):
+			headerMap["Market/Fair Value"] = i
+		case MatchHeader(normalizedHeader, []string{`%.*nav`, `%.*net\s*assets`}):
+			headerMap["Percentage of AUM"] = i
+		case MatchHeader(normalizedHeader, []string{`notional`}):
+			headerMap["Notional Value"] = i
+		case MatchHeader(normalizedHeader, []string{`margin`}):
+			headerMap["Margin"] = i
+		}
+	}
+	return headerMap, found
+}
+
+// ExtractHoldings returns the holdings of a sheet: the rows between the header row and the
+// first (sub)total row that have an instrument name, section labels (see IsSkippedLabel)
+// excepted. Names merged with their ISIN are split, filling the ISIN when the sheet has no
+// separate (or an empty) ISIN column. The quantity and market value columns are also parsed into
+// quantity and marketValue, nil when the cell is blank or malformed. Rows whose market value and
+// % of AUM look swapped are corrected, see CorrectSwappedColumns.
+func ExtractHoldings(rows [][]string) []map[string]interface{} {
+	var headerMap map[string]int
+	holdings := []map[string]interface{}{}
+
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+
+		if headerMap == nil {
+			if header, found := BuildHeaderMap(row); found {
+				headerMap = header
+			}
+			continue
+		}
+
+		// Check for the end marker "Subtotal" or "Total"
+		joinedRow := strings.ToLower(strings.Join(row, ""))
+		if strings.Contains(joinedRow, "subtotal") || strings.Contains(joinedRow, "total") {
+			break
+		}
+
+		stockDetail := make(map[string]interface{})
+
+		// Extract data using the header map
+		for key, idx := range headerMap {
+			if idx < len(row) {
+				stockDetail[key] = row[idx]
+			} else {
+				stockDetail[key] = ""
+			}
+		}
+
+		name, isin := SplitNameAndISIN(stockDetail["Name of the Instrument"].(string))
+		if name == "" || IsSkippedLabel(name) {
+			continue
+		}
+		stockDetail["Name of the Instrument"] = name
+		if existing, _ := stockDetail["ISIN"].(string); isin != "" && strings.TrimSpace(existing) == "" {
+			stockDetail["ISIN"] = isin
+		}
+		for column, field := range holdingAmountFields {
+			if raw, ok := stockDetail[column]; ok {
+				stockDetail[field] = nil
+				if amount, ok := ParseAmountValue(raw); ok {
+					stockDetail[field] = amount
+				}
+			}
+		}
+		CorrectSwappedColumns(stockDetail)
+
+		holdings = append(holdings, stockDetail)
+	}
+	return holdings
+}
+
+// columnsSwapped reports whether a row's market value and % of AUM cells look swapped: both parse,
+// the market value passes for a percentage and either the % of AUM is above SWAP_MAX_AUM_PERCENT
+// or, with a quantity, the market value per unit is below SWAP_MIN_VALUE_PER_UNIT and smaller
+// than the % of AUM
+func columnsSwapped(valueCell, percentCell interface{}, quantity float64) bool {
+	marketValue, valueOk := swapCellAmount(valueCell)
+	percent, percentOk := swapCellAmount(percentCell)
+	cfg := config.Get()
+	if !valueOk || !percentOk || marketValue < 0 || marketValue > cfg.SwapMaxAUMPercent {
+		return false
+	}
+	if percent > cfg.SwapMaxAUMPercent {
+		return true
+	}
+	return quantity > 0 && percent > marketValue && marketValue/quantity < cfg.SwapMinValuePerUnit
+}
+
+// swapCellAmount parses a market value or % of AUM cell alike, either may hold the other's value
+func swapCellAmount(cell interface{}) (float64, bool) {
+	if str, ok := cell.(string); ok {
+		cell = strings.ReplaceAll(str, "%", "")
+	}
+	return ParseAmountValue(cell)
+}
+
+// CorrectSwappedColumns swaps back the market value and % of AUM cells of a holding that has them
+// the wrong way round (sheets listing % of AUM first, or with an extra unlabelled column), parsing
+// marketValue again and flagging it "columnsSwapped". It reports whether the row was corrected.
+func CorrectSwappedColumns(stockDetail map[string]interface{}) bool {
+	rawValue, hasValue := stockDetail["Market/Fair Value"]
+	rawPercent, hasPercent := stockDetail["Percentage of AUM"]
+	if !hasValue || !hasPercent {
+		return false
+	}
+	quantity, _ := stockDetail["quantity"].(float64)
+	if !columnsSwapped(rawValue, rawPercent, quantity) {
+		return false
+	}
+
+	stockDetail["Market/Fair Value"], stockDetail["Percentage of AUM"] = rawPercent, rawValue
+	stockDetail["marketValue"] = nil
+	if amount, ok := ParseAmountValue(rawPercent); ok {
+		stockDetail["marketValue"] = amount
+	}
+	stockDetail["columnsSwapped"] = true
+	return true
+}
+
+// SheetAUMTotal sums the % of AUM of every instrument row of a sheet, equity, debt and other
+// (cash, receivables) sections alike. Total and subtotal rows are skipped so nothing is counted
+// twice, and the sum stops at the grand total. Rows with swapped columns count their market value
+// cell, which holds the % of AUM. It reports false when the sheet has no % of AUM column.
+func SheetAUMTotal(rows [][]string) (float64, bool) {
+	var headerMap map[string]int
+	total := 0.0
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		if headerMap == nil {
+			if header, found := BuildHeaderMap(row); found {
+				headerMap = header
+				if _, ok := headerMap["Percentage of AUM"]; !ok {
+					return 0, false
+				}
+			}
+			continue
+		}
+
+		joinedRow := strings.ToLower(strings.Join(row, ""))
+		if strings.Contains(joinedRow, "grand total") {
+			break
+		}
+		if strings.Contains(joinedRow, "total") {
+			continue
+		}
+
+		nameColumn, percentColumn := headerMap["Name of the Instrument"], headerMap["Percentage of AUM"]
+		if nameColumn >= len(row) || percentColumn >= len(row) || strings.TrimSpace(row[nameColumn]) == "" {
+			continue
+		}
+		percent := ParsePercentage(row[percentColumn])
+		if valueColumn, ok := headerMap["Market/Fair Value"]; ok && valueColumn < len(row) {
+			quantity := 0.0
+			if quantityColumn, ok := headerMap["Quantity"]; ok && quantityColumn < len(row) {
+				quantity = ParseAmount(row[quantityColumn])
+			}
+			if columnsSwapped(row[valueColumn], row[percentColumn], quantity) {
+				percent = ParsePercentage(row[valueColumn])
+			}
+		}
+		total += percent
+	}
+	if headerMap == nil {
+		return 0, false
+	}
+	return Round(total), true
+}
@@ -0,0 +1,204 @@
+package helpers
+
+import (
+	"bytes"
+	"stockbackend/config"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// sheetFixture writes the rows into a workbook and reads them back the way uploads are read
+func sheetFixture(t *testing.T, rows [][]interface{}) [][]string {
+	workbook := excelize.NewFile()
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := workbook.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatalf("Error writing fixture row: %v", err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := workbook.Write(&buf); err != nil {
+		t.Fatalf("Error writing fixture: %v", err)
+	}
+	sheets, err := ReadXLSXSheets(&buf)
+	if err != nil || len(sheets) != 1 {
+		t.Fatalf("Error reading fixture: %v", err)
+	}
+	return sheets[0].Rows
+}
+
+func TestSplitNameAndISIN(t *testing.T) {
+	tests := []struct {
+		cell, name, isin string
+	}{
+		{"Infosys Limited (INE009A01021)", "Infosys Limited", "INE009A01021"},
+		{"INE009A01021 - Infosys Limited", "Infosys Limited", "INE009A01021"},
+		{"Infosys Limited / INE009A01021", "Infosys Limited", "INE009A01021"},
+		{"HDFC Bank Limited", "HDFC Bank Limited", ""},
+		{"  ", "", ""},
+	}
+	for _, test := range tests {
+		name, isin := SplitNameAndISIN(test.cell)
+		if name != test.name || isin != test.isin {
+			t.Errorf("SplitNameAndISIN(%q): expected (%q, %q), got (%q, %q)", test.cell, test.name, test.isin, name, isin)
+		}
+	}
+}
+
+func TestExtractHoldings_MergedNameAndISIN(t *testing.T) {
+	rows := sheetFixture(t, [][]interface{}{
+		{"DSP Tax Saver Fund"},
+		{"Name of the Instrument / ISIN", "Industry", "Quantity", "Market value (Rs. in lakhs)", "% to Net Assets"},
+		{"Infosys Limited (INE009A01021)", "IT - Software", "1000", "1500.5", "2.5%"},
+		{"INE040A01034 HDFC Bank Limited", "Banks", "2000", "3000", "5%"},
+		{"Total", "", "", "4500.5", "7.5%"},
+		{"Reliance Industries Limited (INE002A01018)", "Petroleum Products", "10", "20", "0.1%"},
+	})
+
+	holdings := ExtractHoldings(rows)
+	if len(holdings) != 2 {
+		t.Fatalf("Expected 2 holdings before the total row, got %d: %v", len(holdings), holdings)
+	}
+	if holdings[0]["Name of the Instrument"] != "Infosys Limited" || holdings[0]["ISIN"] != "INE009A01021" {
+		t.Errorf("Expected the merged cell to be split, got %v", holdings[0])
+	}
+	if holdings[1]["Name of the Instrument"] != "HDFC Bank Limited" || holdings[1]["ISIN"] != "INE040A01034" {
+		t.Errorf("Expected a leading ISIN to be split, got %v", holdings[1])
+	}
+	if holdings[0]["Quantity"] != "1000" || holdings[0]["Percentage of AUM"] != "2.5%" {
+		t.Errorf("Expected the other columns to be kept, got %v", holdings[0])
+	}
+}
+
+func TestExtractHoldings_ReversedColumns(t *testing.T) {
+	rows := sheetFixture(t, [][]interface{}{
+		{"% to NAV", "Market/Fair Value", "Quantity", "Rating/Industry", "Name of Instrument", "ISIN"},
+		{"2.5%", "1500.5", "1000", "IT - Software", "Infosys Limited", "INE009A01021"},
+		{"", "", "", "", "", ""},
+		{"5%", "3000", "2000", "Banks", "HDFC Bank Limited", ""},
+		{"1%", "100", "10", "Banks", "Axis Bank Limited INE238A01034", "INE238A01034"},
+		{"Sub Total", "", "", "", "", ""},
+	})
+
+	holdings := ExtractHoldings(rows)
+	if len(holdings) != 3 {
+		t.Fatalf("Expected 3 holdings, got %d: %v", len(holdings), holdings)
+	}
+	expected := map[string]interface{}{
+		"Name of the Instrument": "Infosys Limited",
+		"ISIN":                   "INE009A01021",
+		"Industry/Rating":        "IT - Software",
+		"Quantity":               "1000",
+		"Market/Fair Value":      "1500.5",
+		"Percentage of AUM":      "2.5%",
+	}
+	for key, value := range expected {
+		if holdings[0][key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, holdings[0][key])
+		}
+	}
+	if holdings[0]["quantity"] != 1000.0 || holdings[0]["marketValue"] != 1500.5 {
+		t.Errorf("Expected the numeric amounts next to the raw cells, got %v, %v", holdings[0]["quantity"], holdings[0]["marketValue"])
+	}
+	if holdings[1]["ISIN"] != "" {
+		t.Errorf("Expected an empty ISIN to stay empty, got %v", holdings[1]["ISIN"])
+	}
+	if holdings[2]["Name of the Instrument"] != "Axis Bank Limited" || holdings[2]["ISIN"] != "INE238A01034" {
+		t.Errorf("Expected the name to be cleaned of its ISIN, got %v", holdings[2])
+	}
+}
+
+func TestSheetAUMTotal(t *testing.T) {
+	rows := sheetFixture(t, [][]interface{}{
+		{"Axis Bluechip Fund"},
+		{"Name of the Instrument", "ISIN", "Quantity", "Market value", "% to NAV"},
+		{"Equity & Equity related"},
+		{"Infosys Limited", "INE009A01021", "1000", "1500", "60.25%"},
+		{"HDFC Bank Limited", "INE040A01034", "2000", "1000", "30"},
+		{"Sub Total", "", "", "2500", "90.25%"},
+		{"Debt Instruments"},
+		{"7.26% GOI 2033", "IN0020220151", "100", "200", "7.5%"},
+		{"Total", "", "", "200", "7.5%"},
+		{"Net Receivables / (Payables)", "", "", "50", "2.25%"},
+		{"Grand Total", "", "", "2750", "100%"},
+		{"Notes: 1.5% of the portfolio is illiquid"},
+	})
+
+	total, ok := SheetAUMTotal(rows)
+	if !ok || total != 100 {
+		t.Errorf("Expected every section to sum to 100, got %v (%v)", total, ok)
+	}
+
+	if _, ok := SheetAUMTotal([][]string{{"Name of the Instrument", "Quantity"}, {"Infosys Limited", "1000"}}); ok {
+		t.Errorf("Expected no total for a sheet without a %% of AUM column")
+	}
+	if _, ok := SheetAUMTotal([][]string{{"Portfolio disclosure"}}); ok {
+		t.Errorf("Expected no total for a sheet without a header")
+	}
+}
+
+func TestExtractHoldings_Amounts(t *testing.T) {
+	rows := sheetFixture(t, [][]interface{}{
+		{"Name of the Instrument", "Quantity", "Market/Fair Value"},
+		{"Infosys Limited", "1,23,456", "₹ 1,500.50"},
+		{"HDFC Bank Limited", "", "-"},
+		{"Axis Bank Limited", "N.A.", "(250)"},
+	})
+
+	holdings := ExtractHoldings(rows)
+	if len(holdings) != 3 {
+		t.Fatalf("Expected 3 holdings, got %d: %v", len(holdings), holdings)
+	}
+	if holdings[0]["quantity"] != 123456.0 || holdings[0]["marketValue"] != 1500.5 {
+		t.Errorf("Expected grouped amounts with a currency to parse, got %v", holdings[0])
+	}
+	if holdings[0]["Market/Fair Value"] != "₹ 1,500.50" {
+		t.Errorf("Expected the raw cell to be kept, got %v", holdings[0]["Market/Fair Value"])
+	}
+	if v, ok := holdings[1]["quantity"]; !ok || v != nil || holdings[1]["marketValue"] != nil {
+		t.Errorf("Expected blank amounts to be nil, got %v", holdings[1])
+	}
+	if holdings[2]["quantity"] != nil || holdings[2]["marketValue"] != -250.0 {
+		t.Errorf("Expected a malformed quantity to be nil and an accounting negative to parse, got %v", holdings[2])
+	}
+}
+
+func TestExtractHoldings_SwappedColumns(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{}))
+
+	// The sheet lists % of AUM before the market value, under each other's headers
+	rows := sheetFixture(t, [][]interface{}{
+		{"Name of the Instrument", "ISIN", "Quantity", "Market value (Rs. in lakhs)", "% to Net Assets"},
+		{"Infosys Limited", "INE009A01021", "100000", "2.5%", "1500.5"},
+		{"Tiny Holding Limited", "INE000A01011", "50000000", "0.5", "45"},
+		{"HDFC Bank Limited", "INE040A01034", "2000", "3000", "5%"},
+		{"Net Receivables / (Payables)", "", "", "5", "0.5%"},
+		{"Grand Total", "", "", "", ""},
+	})
+
+	holdings := ExtractHoldings(rows)
+	if len(holdings) != 3 {
+		t.Fatalf("Expected 3 holdings, got %d: %v", len(holdings), holdings)
+	}
+	if holdings[0]["marketValue"] != 1500.5 || holdings[0]["Percentage of AUM"] != "2.5%" || holdings[0]["columnsSwapped"] != true {
+		t.Errorf("Expected a %% of AUM above 100 to be swapped back, got %v", holdings[0])
+	}
+	if holdings[1]["marketValue"] != 45.0 || ParsePercentage(holdings[1]["Percentage of AUM"]) != 0.5 || holdings[1]["columnsSwapped"] != true {
+		t.Errorf("Expected a market value too small for the quantity to be swapped back, got %v", holdings[1])
+	}
+	if _, flagged := holdings[2]["columnsSwapped"]; flagged {
+		t.Errorf("Expected a plausible row to be left alone, got %v", holdings[2])
+	}
+	if total, ok := SheetAUMTotal(rows); !ok || total != 8.5 {
+		t.Errorf("Expected the swapped rows to count their %% of AUM, got %v (%v)", total, ok)
+	}
+
+	// Stricter thresholds leave the small market value alone
+	config.Set(config.FromMap(map[string]string{"SWAP_MIN_VALUE_PER_UNIT": "0"}))
+	if holdings := ExtractHoldings(rows); holdings[1]["columnsSwapped"] != nil || holdings[1]["marketValue"] != 0.5 {
+		t.Errorf("Expected no swap with SWAP_MIN_VALUE_PER_UNIT=0, got %v", holdings[1])
+	}
+}
@@ -0,0 +1,238 @@
+package helpers
+
+import (
+	"math"
+	"regexp"
+	"stockbackend/config"
+	"stockbackend/types"
+	"strings"
+)
+
+// Classifications attached to every streamed holding
+const (
+	HoldingEquity     = "equity"
+	HoldingDerivative = "derivative"
+	HoldingForeign    = "foreign"
+)
+
+// Reasons an equity holding is streamed without the data of its company
+const (
+	UnresolvedNoSearchMatch = "no search match"
+	UnresolvedScrapeFailed  = "scrape failed"
+	UnresolvedLookupFailed  = "lookup failed"
+)
+
+// MarkUnresolved flags a holding whose company couldn't be found or scraped, it is streamed with
+// its sheet columns only so the output keeps one entry per input row
+func MarkUnresolved(stockDetail map[string]interface{}, reason string) {
+	stockDetail["unresolved"] = true
+	stockDetail["reason"] = reason
+}
+
+// NewUnmatchedInstrument records an unresolved holding (see MarkUnresolved) for its upload, the
+// original name is the sheet's before the name map applied. The text match and fuzzy candidates
+// are left for the caller, they come from the stored companies.
+func NewUnmatchedInstrument(stockDetail map[string]interface{}, originalName string) types.UnmatchedInstrument {
+	unmatched := types.UnmatchedInstrument{Name: originalName, Candidates: []types.FuzzyCandidate{}}
+	if name, _ := stockDetail["Name of the Instrument"].(string); name != originalName {
+		unmatched.MappedName = name
+	}
+	if isin, _ := stockDetail["ISIN"].(string); isin != "" {
+		unmatched.ISIN = strings.ToUpper(strings.TrimSpace(isin))
+	}
+	unmatched.Reason, _ = stockDetail["reason"].(string)
+	return unmatched
+}
+
+// Names of futures and options contracts, e.g. "Nifty 50 Index - Futures", "RELIANCE 27-Jun-2024 FUT"
+// or "NIFTY 22000 CE"
+var derivativeNamePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bfutures\b`),
+	regexp.MustCompile(`(?i)\b(index|stock)\s+future\b`),
+	regexp.MustCompile(`(?i)\bfut\b`),
+	regexp.MustCompile(`(?i)\b(index|stock)\s+options?\b`),
+	regexp.MustCompile(`(?i)\b(call|put)\s+options?\b`),
+	regexp.MustCompile(`(?i)\b\d+(\.\d+)?\s*(ce|pe)\b`),
+}
+
+// IsDerivativeHolding reports whether a sheet row is a futures/options position, either by its
+// name or because it carries a value in the notional/margin columns only derivatives have
+func IsDerivativeHolding(stockDetail map[string]interface{}) bool {
+	for _, key := range []string{"Notional Value", "Margin"} {
+		if value, _ := stockDetail[key].(string); strings.TrimSpace(value) != "" {
+			return true
+		}
+	}
+
+	name, _ := stockDetail["Name of the Instrument"].(string)
+	for _, pattern := range derivativeNamePatterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePercentage parses a percentage cell into whole percent, with or without the "%" sign
+func ParsePercentage(value interface{}) float64 {
+	str, ok := value.(string)
+	if !ok {
+		return ParseFloat(value)
+	}
+	f, err := ParseNumber(strings.ReplaceAll(str, "%", ""), CurrentNumberFormat())
+	if err != nil {
+		return 0.0
+	}
+	return f
+}
+
+// amountCurrencyMarkers are stripped from quantity and market value cells before parsing
+var amountCurrencyMarkers = strings.NewReplacer("₹", "", "Rs.", "", "Rs", "", "INR", "", "$", "")
+
+// ParseAmount parses a quantity or market value cell, returning 0 for blanks and malformed values
+func ParseAmount(value interface{}) float64 {
+	f, _ := ParseAmountValue(value)
+	return f
+}
+
+// ParseAmountValue parses a quantity or market value cell in the NUMBER_FORMAT grouping, dropping
+// currency markers. An accounting negative such as "(1,234)" is read as negative. It reports false
+// for blanks (a lone "-" included) and malformed values.
+func ParseAmountValue(value interface{}) (float64, bool) {
+	var str string
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		str = v
+	default:
+		return 0, false
+	}
+	str = strings.TrimSpace(amountCurrencyMarkers.Replace(str))
+	negative := strings.HasPrefix(str, "(") && strings.HasSuffix(str, ")")
+	if negative {
+		str = strings.TrimSpace(str[1 : len(str)-1])
+	}
+	if str == "" || str == "-" {
+		return 0, false
+	}
+	f, err := ParseNumber(str, CurrentNumberFormat())
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	if negative {
+		f = -f
+	}
+	return f, true
+}
+
+// ExposureTotals is the aggregated exposure of one holding classification
+type ExposureTotals struct {
+	Holdings        int     `json:"holdings"`
+	MarketValue     float64 `json:"marketValue"`
+	PercentageOfAUM float64 `json:"percentageOfAUM"`
+	// MarketValue for display, see AddFormattedFields
+	MarketValueFormatted string `json:"marketValueFormatted,omitempty"`
+}
+
+// A sheet whose % of AUM doesn't sum to within these bounds most likely lost or double counted rows,
+// the margin leaves room for rounding and for cash, receivables and other rows
+const (
+	minPlausibleAUMTotal = 90.0
+	maxPlausibleAUMTotal = 110.0
+)
+
+// AUMTotal is the sum of the % of AUM of a sheet, a data quality check of its parsing
+type AUMTotal struct {
+	Sheet           string  `json:"sheet"`
+	PercentageOfAUM float64 `json:"percentageOfAUM"`
+	Plausible       bool    `json:"plausible"`
+}
+
+// PortfolioSummary aggregates the exposure of the holdings streamed for a file
+type PortfolioSummary struct {
+	Exposure  map[string]*ExposureTotals
+	AUMTotals []AUMTotal
+}
+
+func NewPortfolioSummary() *PortfolioSummary {
+	return &PortfolioSummary{Exposure: make(map[string]*ExposureTotals), AUMTotals: []AUMTotal{}}
+}
+
+// AddAUMTotal records the % of AUM total of a sheet, flagging it when it is implausible
+func (ps *PortfolioSummary) AddAUMTotal(sheet string, total float64) AUMTotal {
+	aumTotal := AUMTotal{
+		Sheet:           sheet,
+		PercentageOfAUM: total,
+		Plausible:       total >= minPlausibleAUMTotal && total <= maxPlausibleAUMTotal,
+	}
+	ps.AUMTotals = append(ps.AUMTotals, aumTotal)
+	return aumTotal
+}
+
+// Add includes a streamed holding in the aggregate of its classification
+func (ps *PortfolioSummary) Add(stockDetail map[string]interface{}) {
+	classification, _ := stockDetail["classification"].(string)
+	if classification == "" {
+		classification = HoldingEquity
+	}
+	totals, ok := ps.Exposure[classification]
+	if !ok {
+		totals = &ExposureTotals{}
+		ps.Exposure[classification] = totals
+	}
+	totals.Holdings++
+	totals.MarketValue += ParseAmount(stockDetail["Market/Fair Value"])
+	totals.PercentageOfAUM += ParsePercentage(stockDetail["Percentage of AUM"])
+}
+
+// roundedExposure is the exposure with its totals rounded, summing many holdings adds float noise
+func (ps *PortfolioSummary) roundedExposure() map[string]*ExposureTotals {
+	exposure := make(map[string]*ExposureTotals, len(ps.Exposure))
+	for key, totals := range ps.Exposure {
+		rounded := *totals
+		rounded.MarketValue = Round(totals.MarketValue)
+		rounded.PercentageOfAUM = Round(totals.PercentageOfAUM)
+		if format, ok := CurrentOutputNumberFormat(); ok {
+			rounded.MarketValueFormatted = FormatNumber(totals.MarketValue, format)
+		}
+		exposure[key] = &rounded
+	}
+	return exposure
+}
+
+// Entry builds the summary entry streamed at the end of a file
+func (ps *PortfolioSummary) Entry(file string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "summary",
+		"file":      file,
+		"exposure":  ps.roundedExposure(),
+		"aumTotals": ps.AUMTotals,
+	}
+}
+
+// IsForeignHolding reports whether a sheet row is a foreign stock or depositary receipt, which
+// screener (listing Indian companies only) can't enrich: its ISIN has a country code other than
+// IN or, without an ISIN passing ValidateISIN, its name has one of the FOREIGN_NAME_MARKERS words
+// (e.g. "Alphabet Inc", "Taiwan Semiconductor ADR"). A mistyped country code fails the check digit,
+// so it can't make an Indian holding foreign.
+func IsForeignHolding(stockDetail map[string]interface{}) bool {
+	if isin, err := HoldingISIN(stockDetail); err == nil && isin != "" {
+		return !strings.HasPrefix(isin, "IN")
+	}
+
+	name, _ := stockDetail["Name of the Instrument"].(string)
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '(' || r == ')' || r == '-' || r == '/'
+	})
+	for _, word := range words {
+		for _, marker := range config.Get().ForeignNameMarkers {
+			if strings.EqualFold(word, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
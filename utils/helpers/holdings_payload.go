@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"stockbackend/types"
+	"strings"
+)
+
+// HoldingValidationError is a field of a JSON holding that doesn't match the schema, Field is empty
+// when the item itself is invalid
+type HoldingValidationError struct {
+	Index int    `json:"index"`
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// ValidateHoldings reads a JSON array of holdings item by item and checks each against the
+// HoldingSnapshot schema: a non-blank name, an optional ISIN passing ValidateISIN, a non-negative
+// quantity and optional non-negative marketValue and percentageOfAUM (at most 100). Values of the
+// wrong type are reported, not coerced. The valid holdings are returned with the errors of the
+// invalid ones so they can be processed anyway; the error is for a body that isn't a JSON array.
+func ValidateHoldings(r io.Reader) ([]types.HoldingSnapshot, []HoldingValidationError, error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, nil, errors.New("expected a JSON array of holdings")
+	}
+
+	holdings := []types.HoldingSnapshot{}
+	invalid := []HoldingValidationError{}
+	for index := 0; decoder.More(); index++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, nil, fmt.Errorf("invalid JSON at item %d: %w", index, err)
+		}
+		holding, errs := validateHolding(index, raw)
+		if len(errs) > 0 {
+			invalid = append(invalid, errs...)
+			continue
+		}
+		holdings = append(holdings, holding)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON array: %w", err)
+	}
+	return holdings, invalid, nil
+}
+
+// HoldingDetail turns a validated JSON holding into a holding row as ExtractHoldings reads it from
+// a sheet, so it is enriched like one
+func HoldingDetail(holding types.HoldingSnapshot) map[string]interface{} {
+	stockDetail := map[string]interface{}{
+		"Name of the Instrument": strings.TrimSpace(holding.Name),
+		"Quantity":               holding.Quantity,
+		"quantity":               holding.Quantity,
+		"Market/Fair Value":      holding.MarketValue,
+		"marketValue":            holding.MarketValue,
+		"Percentage of AUM":      holding.PercentageOfAUM,
+	}
+	if holding.ISIN != "" {
+		stockDetail["ISIN"] = holding.ISIN
+	}
+	return stockDetail
+}
+
+// validateHolding checks one item of the array, reporting every invalid field
+func validateHolding(index int, raw json.RawMessage) (types.HoldingSnapshot, []HoldingValidationError) {
+	var holding types.HoldingSnapshot
+	var errs []HoldingValidationError
+	invalid := func(field, message string) {
+		errs = append(errs, HoldingValidationError{Index: index, Field: field, Error: message})
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		invalid("", "must be an object")
+		return holding, errs
+	}
+
+	if value, ok := fields["name"]; !ok {
+		invalid("name", "is required")
+	} else if err := json.Unmarshal(value, &holding.Name); err != nil {
+		invalid("name", "must be a string")
+	} else if strings.TrimSpace(holding.Name) == "" {
+		invalid("name", "must not be blank")
+	}
+
+	if value, ok := fields["isin"]; ok {
+		if err := json.Unmarshal(value, &holding.ISIN); err != nil {
+			invalid("isin", "must be a string")
+		} else if err := ValidateISIN(holding.ISIN); errors.Is(err, ErrISINCheckDigit) {
+			invalid("isin", "check digit doesn't match")
+		} else if err != nil {
+			invalid("isin", "must be 12 characters: a country code, 9 letters or digits and a check digit")
+		}
+	}
+
+	if value, ok := fields["classification"]; ok {
+		if err := json.Unmarshal(value, &holding.Classification); err != nil {
+			invalid("classification", "must be a string")
+		}
+	}
+
+	number := func(field string, target *float64, required bool, max float64) {
+		value, ok := fields[field]
+		if !ok {
+			if required {
+				invalid(field, "is required")
+			}
+			return
+		}
+		if err := json.Unmarshal(value, target); err != nil {
+			invalid(field, "must be a number")
+		} else if *target < 0 || (max > 0 && *target > max) {
+			if max > 0 {
+				invalid(field, fmt.Sprintf("must be between 0 and %v", max))
+			} else {
+				invalid(field, "must not be negative")
+			}
+		}
+	}
+	number("quantity", &holding.Quantity, true, 0)
+	number("marketValue", &holding.MarketValue, false, 0)
+	number("percentageOfAUM", &holding.PercentageOfAUM, false, 100)
+
+	return holding, errs
+}
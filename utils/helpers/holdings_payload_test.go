@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/types"
+	"strings"
+	"testing"
+)
+
+const holdingsPayload = `[
+	{"name": "Infosys Limited", "isin": "INE009A01021", "quantity": 1000, "marketValue": 1500.5, "percentageOfAUM": 2.5},
+	{"name": "  ", "isin": "INE009A0102", "quantity": "1000"},
+	{"name": "Tata Consultancy Services Limited", "quantity": 10},
+	"Reliance Industries Limited",
+	{"isin": "INE002A01018", "quantity": 5, "percentageOfAUM": 120},
+	{"name": "Infosys Limited", "isin": "INE009A01022", "quantity": 1000}
+]`
+
+func TestValidateHoldings(t *testing.T) {
+	holdings, invalid, err := ValidateHoldings(strings.NewReader(holdingsPayload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(holdings) != 2 || holdings[0].ISIN != "INE009A01021" || holdings[0].MarketValue != 1500.5 || holdings[1].Name != "Tata Consultancy Services Limited" {
+		t.Errorf("Expected the two valid holdings, got %+v", holdings)
+	}
+
+	// The numeric string is reported instead of being coerced
+	expected := []HoldingValidationError{
+		{Index: 1, Field: "name", Error: "must not be blank"},
+		{Index: 1, Field: "isin", Error: "must be 12 characters: a country code, 9 letters or digits and a check digit"},
+		{Index: 1, Field: "quantity", Error: "must be a number"},
+		{Index: 3, Field: "", Error: "must be an object"},
+		{Index: 4, Field: "name", Error: "is required"},
+		{Index: 4, Field: "percentageOfAUM", Error: "must be between 0 and 100"},
+		// A transcription error in the ISIN fails its check digit
+		{Index: 5, Field: "isin", Error: "check digit doesn't match"},
+	}
+	if !reflect.DeepEqual(invalid, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, invalid)
+	}
+}
+
+func TestValidateHoldings_NotAnArray(t *testing.T) {
+	for _, payload := range []string{`{"name": "Infosys Limited"}`, `[{"name": "Infosys Limited", "quantity": 1}`, ``} {
+		if _, _, err := ValidateHoldings(strings.NewReader(payload)); err == nil {
+			t.Errorf("Expected an error for %q", payload)
+		}
+	}
+}
+
+func TestHoldingDetail(t *testing.T) {
+	holding := types.HoldingSnapshot{Name: " Infosys Limited ", ISIN: "INE009A01021", Quantity: 1000, MarketValue: 1500.5, PercentageOfAUM: 2.5}
+	stockDetail := HoldingDetail(holding)
+	if stockDetail["Name of the Instrument"] != "Infosys Limited" || stockDetail["quantity"] != 1000.0 || stockDetail["marketValue"] != 1500.5 {
+		t.Errorf("Expected the holding row of a sheet, got %v", stockDetail)
+	}
+	// The row is recorded as the holding it came from
+	holding.Name = "Infosys Limited"
+	if snapshot := NewHoldingSnapshot(stockDetail); snapshot != holding {
+		t.Errorf("Expected %+v, got %+v", holding, snapshot)
+	}
+	if _, ok := HoldingDetail(types.HoldingSnapshot{Name: "Cash"})["ISIN"]; ok {
+		t.Errorf("Expected no ISIN for a holding without one")
+	}
+}
@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Reasons an ingested name fails besides the ones of an unresolved holding (UnresolvedNoSearchMatch, ...):
+// the name is a derivative or foreign holding, or its company was resolved but couldn't be stored
+const (
+	IngestNotEquity = "not an equity"
+	IngestNotStored = "not stored"
+)
+
+// ParseIngestNames cleans the company names of an ingest request: names are trimmed, blank ones
+// dropped and repeated ones (ignoring case) kept once in their first position. At most max names
+// are accepted.
+func ParseIngestNames(names []string, max int) ([]string, error) {
+	cleaned := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		cleaned = append(cleaned, name)
+	}
+	if len(cleaned) == 0 {
+		return nil, errors.New("no company names given")
+	}
+	if len(cleaned) > max {
+		return nil, fmt.Errorf("too many company names: %d, at most %d are accepted", len(cleaned), max)
+	}
+	return cleaned, nil
+}
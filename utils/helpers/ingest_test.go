@@ -0,0 +1,19 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIngestNames(t *testing.T) {
+	names, err := ParseIngestNames([]string{" Infosys  Ltd ", "", "TCS", "infosys ltd", "  "}, 2)
+	if err != nil || !reflect.DeepEqual(names, []string{"Infosys Ltd", "TCS"}) {
+		t.Errorf("Expected the names trimmed and deduplicated, got %v (%v)", names, err)
+	}
+	if _, err := ParseIngestNames([]string{"Infosys", "TCS", "Wipro"}, 2); err == nil {
+		t.Errorf("Expected more than the max names to be rejected")
+	}
+	if _, err := ParseIngestNames([]string{" "}, 2); err == nil {
+		t.Errorf("Expected a list without names to be rejected")
+	}
+}
@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"regexp"
+	"stockbackend/types"
+	"strings"
+)
+
+// Categories a pro or con is tagged with
+const (
+	InsightValuation = "valuation"
+	InsightDebt      = "debt"
+	InsightGrowth    = "growth"
+	InsightPromoter  = "promoter"
+	InsightDividend  = "dividend"
+	InsightOther     = "other"
+)
+
+// insightCategoryPatterns tag a pro or con, matched in order so the first category wins: "dividend
+// payout of 35% of profits" is about the dividend, not the profit growth
+var insightCategoryPatterns = []struct {
+	category string
+	pattern  *regexp.Regexp
+}{
+	{InsightPromoter, regexp.MustCompile(`(?i)\bpromoter`)},
+	{InsightDividend, regexp.MustCompile(`(?i)\bdividend`)},
+	{InsightValuation, regexp.MustCompile(`(?i)book value|times its|\bp/e\b|price to earning|valuation|(over|under)valued`)},
+	// "debt" as a word, debtor days are about working capital
+	{InsightDebt, regexp.MustCompile(`(?i)\bdebt\b|borrowing|interest coverage|interest cost|leverage|contingent liabilit`)},
+	{InsightGrowth, regexp.MustCompile(`(?i)growth|\bcagr\b|\bgr(own|ew)\b`)},
+}
+
+// insightNumberPattern matches a number a pro or con quotes with its unit, if any: "25.4%",
+// "0.85 times", "150 days", "Rs.1,234 Cr"
+var insightNumberPattern = regexp.MustCompile(`(?i)(-?\d(?:[\d,]*\d)?(?:\.\d+)?)\s*(%|times\b|days\b|cr\b)?`)
+
+// insightUnits normalizes the units matched by insightNumberPattern
+var insightUnits = map[string]string{"%": "%", "times": "times", "days": "days", "cr": "cr"}
+
+// ClassifyInsight tags a pro or con as scraped from screener with its category and extracts the
+// number it quotes. A percentage is preferred over other numbers ("3 Years ROE 25.3%" quotes
+// 25.3%), then a multiple, then a number with a unit, then the first number.
+func ClassifyInsight(text string) types.Insight {
+	text = strings.TrimSpace(text)
+	insight := types.Insight{Text: text, Category: InsightOther}
+
+	for _, rule := range insightCategoryPatterns {
+		if rule.pattern.MatchString(text) {
+			insight.Category = rule.category
+			break
+		}
+	}
+
+	var best []string
+	for _, match := range insightNumberPattern.FindAllStringSubmatch(text, -1) {
+		if best == nil || insightUnitRank(match[2]) > insightUnitRank(best[2]) {
+			best = match
+		}
+	}
+	if best != nil {
+		if value, err := ParseNumber(best[1], NumberFormatUS); err == nil {
+			insight.Value = &value
+			insight.Unit = insightUnits[strings.ToLower(best[2])]
+		}
+	}
+	return insight
+}
+
+// insightUnitRank orders the numbers of a pro or con by how likely they are the one it is about
+func insightUnitRank(unit string) int {
+	switch strings.ToLower(unit) {
+	case "":
+		return 0
+	case "%":
+		return 3
+	case "times":
+		return 2
+	}
+	return 1
+}
+
+// ClassifyInsights classifies each of the pros or cons of a company, see ClassifyInsight
+func ClassifyInsights(texts []string) []types.Insight {
+	insights := make([]types.Insight, 0, len(texts))
+	for _, text := range texts {
+		insights = append(insights, ClassifyInsight(text))
+	}
+	return insights
+}
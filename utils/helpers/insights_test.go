@@ -0,0 +1,54 @@
+package helpers
+
+import "testing"
+
+func TestClassifyInsight(t *testing.T) {
+	tests := []struct {
+		text     string
+		category string
+		value    *float64
+		unit     string
+	}{
+		{"Company is almost debt free.", InsightDebt, nil, ""},
+		{"Company has reduced debt.", InsightDebt, nil, ""},
+		{"Company has low interest coverage ratio.", InsightDebt, nil, ""},
+		{"Stock is trading at 0.85 times its book value", InsightValuation, floatPtr(0.85), "times"},
+		{"Stock is trading at 8.45 times its book value", InsightValuation, floatPtr(8.45), "times"},
+		{"Company has delivered good profit growth of 25.4% CAGR over last 5 years", InsightGrowth, floatPtr(25.4), "%"},
+		{"The company has delivered a poor sales growth of 4.21% over past five years.", InsightGrowth, floatPtr(4.21), "%"},
+		{"Promoter holding has decreased over last quarter: -1.25%", InsightPromoter, floatPtr(-1.25), "%"},
+		{"Promoters have pledged 35.2% of their holding.", InsightPromoter, floatPtr(35.2), "%"},
+		{"Company has been maintaining a healthy dividend payout of 35.2%", InsightDividend, floatPtr(35.2), "%"},
+		{"Though the company is reporting repeated profits, it is not paying out dividend", InsightDividend, nil, ""},
+		{"Company has a good return on equity (ROE) track record: 3 Years ROE 25.3%", InsightOther, floatPtr(25.3), "%"},
+		{"Debtor days have improved from 60.2 to 45.3 days.", InsightOther, floatPtr(45.3), "days"},
+		{"Earnings include an other income of Rs.1,234 Cr.", InsightOther, floatPtr(1234), "cr"},
+		{"  Tax rate seems low ", InsightOther, nil, ""},
+	}
+
+	for _, test := range tests {
+		got := ClassifyInsight(test.text)
+		if got.Category != test.category {
+			t.Errorf("%q: expected category %q, got %q", test.text, test.category, got.Category)
+		}
+		switch {
+		case test.value == nil && got.Value != nil:
+			t.Errorf("%q: expected no value, got %v", test.text, *got.Value)
+		case test.value != nil && (got.Value == nil || *got.Value != *test.value):
+			t.Errorf("%q: expected %v, got %v", test.text, *test.value, got.Value)
+		}
+		if got.Unit != test.unit {
+			t.Errorf("%q: expected unit %q, got %q", test.text, test.unit, got.Unit)
+		}
+	}
+}
+
+func TestClassifyInsights(t *testing.T) {
+	if insights := ClassifyInsights(nil); insights == nil || len(insights) != 0 {
+		t.Errorf("Expected an empty list for no pros, got %v", insights)
+	}
+	insights := ClassifyInsights([]string{"Company has reduced debt.", "Tax rate seems low"})
+	if len(insights) != 2 || insights[0].Text != "Company has reduced debt." || insights[1].Category != InsightOther {
+		t.Errorf("Expected each pro classified in order, got %+v", insights)
+	}
+}
@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var exactISINPattern = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{9}[0-9]$`)
+
+var (
+	ErrISINFormat     = errors.New("not an ISIN: expected a country code, 9 letters or digits and a check digit")
+	ErrISINCheckDigit = errors.New("ISIN check digit doesn't match")
+)
+
+// ValidateISIN checks the format and the check digit of an ISIN: its letters are read as numbers
+// (A = 10 to Z = 35) and the resulting digits must pass the Luhn check
+func ValidateISIN(isin string) error {
+	if !exactISINPattern.MatchString(isin) {
+		return ErrISINFormat
+	}
+	var digits []int
+	for _, char := range isin {
+		if char >= 'A' && char <= 'Z' {
+			value := int(char-'A') + 10
+			digits = append(digits, value/10, value%10)
+		} else {
+			digits = append(digits, int(char-'0'))
+		}
+	}
+	// From the check digit leftwards, every second digit is doubled
+	sum := 0
+	for i := range digits {
+		digit := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	if sum%10 != 0 {
+		return ErrISINCheckDigit
+	}
+	return nil
+}
+
+// HoldingISIN returns the ISIN of a sheet row, uppercased, when it is valid. A row without an ISIN
+// returns "" and no error; an ISIN failing ValidateISIN (e.g. a transcription error) returns "" with
+// the reason, it mustn't be trusted to match the row to a company.
+func HoldingISIN(stockDetail map[string]interface{}) (string, error) {
+	isin, _ := stockDetail["ISIN"].(string)
+	if isin = strings.ToUpper(strings.TrimSpace(isin)); isin == "" {
+		return "", nil
+	}
+	if err := ValidateISIN(isin); err != nil {
+		return "", err
+	}
+	return isin, nil
+}
@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateISIN(t *testing.T) {
+	tests := []struct {
+		isin     string
+		expected error
+	}{
+		{"INE009A01021", nil},
+		{"INE040A01034", nil},
+		// Letters in the body and the country code
+		{"US02079K3059", nil},
+		{"AU0000XVGZA3", nil},
+		{"KYG875721634", nil},
+		{"INE009A01022", ErrISINCheckDigit},
+		// Two digits swapped
+		{"INE009A10021", ErrISINCheckDigit},
+		{"INE000000000", ErrISINCheckDigit},
+		{"INE009A0102", ErrISINFormat},
+		{"ine009a01021", ErrISINFormat},
+		{"INE009A0102X", ErrISINFormat},
+		{"", ErrISINFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.isin, func(t *testing.T) {
+			if err := ValidateISIN(tt.isin); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestHoldingISIN(t *testing.T) {
+	tests := []struct {
+		name     string
+		isin     interface{}
+		expected string
+		err      error
+	}{
+		{"valid", " ine009a01021 ", "INE009A01021", nil},
+		{"missing", nil, "", nil},
+		{"blank", " ", "", nil},
+		{"check digit", "INE009A01022", "", ErrISINCheckDigit},
+		{"malformed", "N.A.", "", ErrISINFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isin, err := HoldingISIN(map[string]interface{}{"ISIN": tt.isin})
+			if isin != tt.expected || !errors.Is(err, tt.err) {
+				t.Errorf("Expected %q, %v, got %q, %v", tt.expected, tt.err, isin, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"context"
+	"stockbackend/config"
+	"sync"
+)
+
+// JobQueue runs background jobs one at a time, on a context of the queue that Stop cancels. At most
+// its capacity of jobs are queued or running: a place is reserved before the job is known, so a
+// caller can tell whether its job will run before recording it.
+type JobQueue struct {
+	places chan struct{}
+	jobs   chan func(ctx context.Context)
+	ctx    context.Context
+	stop   context.CancelFunc
+	start  sync.Once
+}
+
+var (
+	backlogsMu sync.Mutex
+	backlogs   *JobQueue
+)
+
+// NewJobQueue returns a queue holding at most capacity jobs
+func NewJobQueue(capacity int) *JobQueue {
+	ctx, stop := context.WithCancel(context.Background())
+	return &JobQueue{
+		places: make(chan struct{}, capacity),
+		jobs:   make(chan func(ctx context.Context), capacity),
+		ctx:    ctx,
+		stop:   stop,
+	}
+}
+
+// Backlogs returns the process wide queue of the upload backlogs, the holdings uploads left past their
+// deadline, UPLOAD_BACKLOG_QUEUE of them. Like the upload slots it is made again when a new configuration
+// changes its capacity, the jobs of the previous queue still run.
+func Backlogs() *JobQueue {
+	capacity := config.Get().UploadBacklogQueue
+	backlogsMu.Lock()
+	defer backlogsMu.Unlock()
+	if backlogs == nil || cap(backlogs.places) != capacity {
+		backlogs = NewJobQueue(capacity)
+	}
+	return backlogs
+}
+
+// Reserve takes a place in the queue, it reports false when the queue is full or stopped. A reserved
+// place must be used by Run or given back by Release.
+func (q *JobQueue) Reserve() bool {
+	if q.ctx.Err() != nil {
+		return false
+	}
+	select {
+	case q.places <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release gives back a reserved place no job was run in
+func (q *JobQueue) Release() {
+	<-q.places
+}
+
+// Run queues job in a reserved place, it runs after the jobs queued before it. The job's context is
+// cancelled by Stop.
+func (q *JobQueue) Run(job func(ctx context.Context)) {
+	q.start.Do(func() { go q.work() })
+	q.jobs <- job
+}
+
+func (q *JobQueue) work() {
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case job := <-q.jobs:
+			job(q.ctx)
+			<-q.places
+		}
+	}
+}
+
+// Stop cancels the running job, the queued ones are dropped
+func (q *JobQueue) Stop() {
+	q.stop()
+}
+
+// Len returns the number of jobs queued or running, with the places reserved for them
+func (q *JobQueue) Len() int {
+	return len(q.places)
+}
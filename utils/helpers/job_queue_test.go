@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJobQueue(t *testing.T) {
+	queue := NewJobQueue(2)
+	if !queue.Reserve() || !queue.Reserve() {
+		t.Fatalf("Expected 2 places")
+	}
+	if queue.Reserve() {
+		t.Errorf("Expected the queue to be full")
+	}
+
+	// The jobs run one at a time, in the order they were queued
+	started := make(chan int, 2)
+	unblock := make(chan struct{})
+	cancelled := make(chan struct{})
+	queue.Run(func(ctx context.Context) {
+		started <- 1
+		<-unblock
+	})
+	queue.Run(func(ctx context.Context) {
+		started <- 2
+		<-ctx.Done()
+		close(cancelled)
+	})
+	if first := <-started; first != 1 {
+		t.Errorf("Expected the first job to run first, got %d", first)
+	}
+	select {
+	case <-started:
+		t.Errorf("Expected the second job to wait for the first")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(unblock)
+	if second := <-started; second != 2 {
+		t.Errorf("Expected the second job, got %d", second)
+	}
+	// The first job's place is free again
+	if !queue.Reserve() {
+		t.Errorf("Expected a place once the first job finished")
+	}
+	queue.Release()
+
+	queue.Stop()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Stop to cancel the running job")
+	}
+	if queue.Reserve() {
+		t.Errorf("Expected no place in a stopped queue")
+	}
+}
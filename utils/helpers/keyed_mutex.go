@@ -0,0 +1,91 @@
+package helpers
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// KeyedMutex serializes work per key, e.g. the writes to one company, while work on other keys
+// proceeds. A key's lock is dropped once nobody holds or waits for it. The zero value is ready to use.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu sync.Mutex
+	// Holders and waiters of the lock, guarded by KeyedMutex.mu
+	refs int
+}
+
+// Lock blocks until the key is free and returns the function releasing it
+func (k *KeyedMutex) Lock(key string) (unlock func()) {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*keyedLock{}
+	}
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		k.locks[key] = lock
+	}
+	lock.refs++
+	k.mu.Unlock()
+
+	lock.mu.Lock()
+	return k.unlock(key, lock)
+}
+
+// TryLock takes the key's lock when nobody holds or waits for it, it reports false without
+// waiting otherwise, e.g. to skip work another goroutine is already doing for the key
+func (k *KeyedMutex) TryLock(key string) (unlock func(), ok bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, held := k.locks[key]; held {
+		return nil, false
+	}
+	if k.locks == nil {
+		k.locks = map[string]*keyedLock{}
+	}
+	lock := &keyedLock{refs: 1}
+	lock.mu.Lock()
+	k.locks[key] = lock
+	return k.unlock(key, lock), true
+}
+
+// unlock returns the function releasing a held lock of the key
+func (k *KeyedMutex) unlock(key string, lock *keyedLock) func() {
+	return func() {
+		lock.mu.Unlock()
+		k.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// CompanyLockKey is the key the writes to a company are serialized on: the path of its page URL,
+// which search results, name map entries and stored companies all carry, whatever its host, case or
+// trailing slash. A company without a URL is locked on its name, names differing only in case,
+// punctuation or suffixes (Ltd, Limited) being the same company.
+func CompanyLockKey(pageURL string, name string) string {
+	if parsed, err := url.Parse(strings.TrimSpace(pageURL)); err == nil {
+		if path := strings.TrimSuffix(parsed.Path, "/"); path != "" {
+			return strings.ToLower(path)
+		}
+	}
+	if key := NormalizeCompanyName(name); key != "" {
+		return key
+	}
+	return name
+}
+
+// StoredCompanyLockKey is the CompanyLockKey of a stored company document
+func StoredCompanyLockKey(company map[string]interface{}) string {
+	pageURL, _ := company["url"].(string)
+	name, _ := company["name"].(string)
+	return CompanyLockKey(pageURL, name)
+}
@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedMutex_SerializesSameKey(t *testing.T) {
+	var locks KeyedMutex
+	// A company document enriched concurrently: every writer reads the document, works on it and
+	// writes back, a lost update would leave fewer scrapes than writers
+	document := map[string]int{"scrapes": 0}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// A search result and a name map entry of the same company share the lock
+			pageURL, name := "https://www.screener.in/company/INFY/consolidated/", "Infosys Ltd"
+			if i%2 == 0 {
+				pageURL, name = "https://www.screener.in/company/infy/consolidated", "INFOSYS LIMITED"
+			}
+			unlock := locks.Lock(CompanyLockKey(pageURL, name))
+			defer unlock()
+			scrapes := document["scrapes"]
+			time.Sleep(time.Millisecond)
+			document["scrapes"] = scrapes + 1
+		}(i)
+	}
+	wg.Wait()
+
+	if document["scrapes"] != 20 {
+		t.Errorf("Expected the 20 writes to be serialized, got %d", document["scrapes"])
+	}
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected the released keys to be dropped, got %v", locks.locks)
+	}
+}
+
+func TestKeyedMutex_OtherKeysProceed(t *testing.T) {
+	var locks KeyedMutex
+	unlock := locks.Lock(CompanyLockKey("", "Infosys Ltd"))
+	defer unlock()
+
+	done := make(chan struct{})
+	go func() {
+		locks.Lock(CompanyLockKey("", "TCS Ltd"))()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected another company to be locked while the first is held")
+	}
+}
+
+func TestKeyedMutex_TryLock(t *testing.T) {
+	var locks KeyedMutex
+	unlock, ok := locks.TryLock(CompanyLockKey("", "Infosys Ltd"))
+	if !ok {
+		t.Fatalf("Expected a free key to be locked")
+	}
+	if _, ok := locks.TryLock(CompanyLockKey("", "INFOSYS LIMITED")); ok {
+		t.Errorf("Expected a held key not to be locked again")
+	}
+	if other, ok := locks.TryLock(CompanyLockKey("", "TCS Ltd")); !ok {
+		t.Errorf("Expected another key to be locked")
+	} else {
+		other()
+	}
+
+	// Lock waits for the TryLock holder like for any other
+	locked := make(chan struct{})
+	go func() {
+		locks.Lock(CompanyLockKey("", "Infosys Ltd"))()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatalf("Expected Lock to wait for the held key")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected the released keys to be dropped, got %v", locks.locks)
+	}
+}
+
+func TestCompanyLockKey(t *testing.T) {
+	tests := []struct {
+		pageURL  string
+		name     string
+		expected string
+	}{
+		{"https://www.screener.in/company/INFY/consolidated/", "Infosys Ltd", "/company/infy/consolidated"},
+		{"http://localhost:8080/company/infy/consolidated", "Infosys", "/company/infy/consolidated"},
+		{"/company/INFY/consolidated/", "", "/company/infy/consolidated"},
+		{"", "INFOSYS LIMITED", CompanyLockKey("", "Infosys Ltd")},
+		{"https://www.screener.in/", "TCS Ltd", CompanyLockKey("", "TCS Ltd")},
+	}
+	for _, test := range tests {
+		if key := CompanyLockKey(test.pageURL, test.name); key != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.pageURL, test.expected, key)
+		}
+	}
+
+	stored := map[string]interface{}{"name": "Infosys Ltd", "url": "https://www.screener.in/company/INFY/consolidated/"}
+	if key := StoredCompanyLockKey(stored); key != "/company/infy/consolidated" {
+		t.Errorf("Expected the stored URL to be the key, got %q", key)
+	}
+}
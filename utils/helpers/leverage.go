@@ -0,0 +1,150 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"strings"
+)
+
+// latestBalanceSheetValue returns the latest yearly value of a balance sheet row, reporting
+// false when the row is missing or its value is blank or not a number
+func latestBalanceSheetValue(stock map[string]interface{}, label string) (float64, bool) {
+	return latestAnnualValue(stock, "balanceSheet", label)
+}
+
+// latestAnnualValue returns the latest yearly value of a row of a yearly statement, a trailing TTM
+// column left out, reporting false when the row is missing or its value is blank or not a number
+func latestAnnualValue(stock map[string]interface{}, section, label string) (float64, bool) {
+	values, err := getAnnualArrayField(stock, section, label)
+	if err != nil || len(values) == 0 {
+		return 0, false
+	}
+	raw, _ := values[len(values)-1].(string)
+	if strings.TrimSpace(raw) == "" {
+		return 0, false
+	}
+	value, err := ParseNumber(raw, CurrentNumberFormat())
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// HighDebt flags a leveraged company from its latest balance sheet: a debt to equity ratio (see
+// DebtToEquity) above HIGH_DEBT_TO_EQUITY, or borrowings above HIGH_DEBT_TO_ASSETS of the total
+// assets when the ratio isn't known. Companies without borrowings are not high debt, nil means the
+// balance sheet doesn't tell.
+func HighDebt(stock map[string]interface{}) *bool {
+	cfg := config.Get()
+	borrowings, ok := latestBalanceSheetValue(stock, "Borrowings +")
+	if !ok {
+		return nil
+	}
+	if borrowings <= 0 {
+		highDebt := false
+		return &highDebt
+	}
+
+	if ratio := latestDebtToEquity(stock); ratio != nil {
+		highDebt := *ratio > cfg.HighDebtToEquity
+		return &highDebt
+	}
+
+	if totalAssets, ok := latestBalanceSheetValue(stock, "Total Assets"); ok && totalAssets > 0 {
+		highDebt := borrowings/totalAssets > cfg.HighDebtToAssets
+		return &highDebt
+	}
+	return nil
+}
+
+// debtToEquityHistoryYears is the number of latest years kept in the stored debt to equity history
+const debtToEquityHistoryYears = 5
+
+// annualBalanceSheetNumbers reads the yearly values of a balance sheet row, nil for the blank or
+// unparseable cells. ok is false when the row is missing.
+func annualBalanceSheetNumbers(stock map[string]interface{}, label string) ([]*float64, bool) {
+	values, err := getAnnualArrayField(stock, "balanceSheet", label)
+	if err != nil {
+		return nil, false
+	}
+	numbers := make([]*float64, len(values))
+	for i, value := range values {
+		raw, _ := value.(string)
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		if number, err := ParseNumber(raw, CurrentNumberFormat()); err == nil {
+			numbers[i] = &number
+		}
+	}
+	return numbers, true
+}
+
+// alignedNumber returns the value of a row aligned from the latest year, i counting back from it
+func alignedNumber(numbers []*float64, i int) *float64 {
+	if i >= len(numbers) {
+		return nil
+	}
+	return numbers[len(numbers)-1-i]
+}
+
+// debtToEquityRatio divides the borrowings by the equity (equity capital and reserves, missing
+// reserves read as none), nil when the equity isn't known or positive. The ratio isn't rounded so
+// HighDebt compares the exact one.
+func debtToEquityRatio(borrowings, equityCapital, reserves *float64) *float64 {
+	if borrowings == nil || equityCapital == nil {
+		return nil
+	}
+	equity := *equityCapital
+	if reserves != nil {
+		equity += *reserves
+	}
+	if equity <= 0 {
+		return nil
+	}
+	ratio := *borrowings / equity
+	return &ratio
+}
+
+// latestDebtToEquity returns the unrounded debt to equity ratio of the latest balance sheet year
+func latestDebtToEquity(stock map[string]interface{}) *float64 {
+	borrowings, _ := annualBalanceSheetNumbers(stock, "Borrowings +")
+	equityCapital, _ := annualBalanceSheetNumbers(stock, "Equity Capital")
+	reserves, _ := annualBalanceSheetNumbers(stock, "Reserves")
+	return debtToEquityRatio(alignedNumber(borrowings, 0), alignedNumber(equityCapital, 0), alignedNumber(reserves, 0))
+}
+
+// DebtToEquity returns the borrowings to equity ratio of the latest balance sheet year and of the
+// last debtToEquityHistoryYears years, oldest first, each aligned with its period header. A year
+// without borrowings or equity capital, or whose equity isn't positive, has a nil ratio.
+func DebtToEquity(stock map[string]interface{}) (*float64, []HistoryPoint) {
+	borrowings, ok := annualBalanceSheetNumbers(stock, "Borrowings +")
+	if !ok || len(borrowings) == 0 {
+		return nil, nil
+	}
+	equityCapital, _ := annualBalanceSheetNumbers(stock, "Equity Capital")
+	reserves, _ := annualBalanceSheetNumbers(stock, "Reserves")
+
+	var periods []string
+	headers, _ := toArray(stock["balanceSheetHeaders"])
+	for _, header := range headers {
+		period, _ := header.(string)
+		periods = append(periods, period)
+	}
+	if len(periods) > 0 && hasTrailingTTM(stock, "balanceSheet") {
+		periods = periods[:len(periods)-1]
+	}
+
+	years := min(len(borrowings), debtToEquityHistoryYears)
+	history := make([]HistoryPoint, years)
+	for i := 0; i < years; i++ {
+		point := &history[years-1-i]
+		if i < len(periods) {
+			point.Period = periods[len(periods)-1-i]
+		}
+		if ratio := debtToEquityRatio(alignedNumber(borrowings, i), alignedNumber(equityCapital, i), alignedNumber(reserves, i)); ratio != nil {
+			rounded := Round(*ratio)
+			point.Value = &rounded
+		}
+	}
+	return history[years-1].Value, history
+}
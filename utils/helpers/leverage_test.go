@@ -0,0 +1,135 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"stockbackend/types"
+	"testing"
+)
+
+func balanceSheetStock(rows ...types.TableRow) map[string]interface{} {
+	return map[string]interface{}{"balanceSheet": rows}
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func TestHighDebt(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"HIGH_DEBT_TO_EQUITY": "2", "HIGH_DEBT_TO_ASSETS": "0.5"}))
+
+	yes, no := true, false
+	tests := []struct {
+		name     string
+		stock    map[string]interface{}
+		expected *bool
+	}{
+		{"debt to equity at the threshold", balanceSheetStock(
+			row("Equity Capital", "50", "100"), row("Reserves", "400", "400"), row("Borrowings +", "900", "1,000"),
+		), &no},
+		{"debt to equity above the threshold", balanceSheetStock(
+			row("Equity Capital", "100"), row("Reserves", "400"), row("Borrowings +", "1,001"),
+		), &yes},
+		{"missing reserves read as none", balanceSheetStock(
+			row("Equity Capital", "100"), row("Borrowings +", "201"), row("Total Assets", "1,000"),
+		), &yes},
+		{"no borrowings", balanceSheetStock(row("Borrowings +", "500", "0")), &no},
+		{"negative equity falls back to assets at the threshold", balanceSheetStock(
+			row("Equity Capital", "100"), row("Reserves", "-300"), row("Borrowings +", "500"), row("Total Assets", "1,000"),
+		), &no},
+		{"borrowings to assets above the threshold", balanceSheetStock(
+			row("Borrowings +", "501"), row("Total Assets", "1,000"),
+		), &yes},
+		{"no borrowings row", balanceSheetStock(row("Equity Capital", "100"), row("Total Assets", "1,000")), nil},
+		{"blank borrowings", balanceSheetStock(row("Borrowings +", ""), row("Total Assets", "1,000")), nil},
+		{"neither equity nor assets", balanceSheetStock(row("Borrowings +", "500")), nil},
+		{"no balance sheet", map[string]interface{}{}, nil},
+	}
+
+	for _, test := range tests {
+		got := HighDebt(test.stock)
+		switch {
+		case test.expected == nil && got != nil:
+			t.Errorf("%s: expected unknown, got %v", test.name, *got)
+		case test.expected != nil && got == nil:
+			t.Errorf("%s: expected %v, got unknown", test.name, *test.expected)
+		case test.expected != nil && *got != *test.expected:
+			t.Errorf("%s: expected %v, got %v", test.name, *test.expected, *got)
+		}
+	}
+}
+
+func TestDebtToEquity(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"DECIMAL_PLACES": "2"}))
+
+	stock := balanceSheetStock(
+		row("Equity Capital", "100", "100", "100"),
+		row("Reserves", "300", "", "-100"),
+		row("Borrowings +", "200", "50", "1,000"),
+	)
+	stock["balanceSheetHeaders"] = []string{"Mar 2022", "Mar 2023", "Mar 2024"}
+
+	latest, history := DebtToEquity(stock)
+	if latest != nil {
+		t.Errorf("Expected no ratio for a zero equity, got %v", *latest)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Expected a 3 year history, got %v", history)
+	}
+	expected := []struct {
+		period string
+		value  *float64
+	}{
+		{"Mar 2022", floatPtr(0.5)},
+		// Blank reserves read as none
+		{"Mar 2023", floatPtr(0.5)},
+		{"Mar 2024", nil},
+	}
+	for i, point := range history {
+		if point.Period != expected[i].period {
+			t.Errorf("Expected period %q, got %q", expected[i].period, point.Period)
+		}
+		switch {
+		case expected[i].value == nil && point.Value != nil:
+			t.Errorf("%s: expected no ratio, got %v", point.Period, *point.Value)
+		case expected[i].value != nil && (point.Value == nil || *point.Value != *expected[i].value):
+			t.Errorf("%s: expected %v, got %v", point.Period, *expected[i].value, point.Value)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		stock    map[string]interface{}
+		expected *float64
+	}{
+		{"missing reserves row", balanceSheetStock(row("Equity Capital", "200"), row("Borrowings +", "300")), floatPtr(1.5)},
+		{"no borrowings", balanceSheetStock(row("Equity Capital", "200"), row("Reserves", "50"), row("Borrowings +", "0")), floatPtr(0)},
+		{"negative equity", balanceSheetStock(row("Equity Capital", "100"), row("Reserves", "-300"), row("Borrowings +", "500")), nil},
+		{"no equity capital", balanceSheetStock(row("Reserves", "300"), row("Borrowings +", "500")), nil},
+		{"no borrowings row", balanceSheetStock(row("Equity Capital", "100")), nil},
+		{"no balance sheet", map[string]interface{}{}, nil},
+	}
+	for _, test := range tests {
+		got, _ := DebtToEquity(test.stock)
+		switch {
+		case test.expected == nil && got != nil:
+			t.Errorf("%s: expected unknown, got %v", test.name, *got)
+		case test.expected != nil && (got == nil || *got != *test.expected):
+			t.Errorf("%s: expected %v, got %v", test.name, *test.expected, got)
+		}
+	}
+}
+
+func TestDebtToEquity_HistoryLength(t *testing.T) {
+	values := []string{"1", "2", "3", "4", "5", "6", "7"}
+	_, history := DebtToEquity(balanceSheetStock(row("Equity Capital", values...), row("Borrowings +", values...)))
+	if len(history) != debtToEquityHistoryYears {
+		t.Errorf("Expected the last %d years, got %d", debtToEquityHistoryYears, len(history))
+	}
+	if history[0].Period != "" || history[0].Value == nil || *history[0].Value != 1 {
+		t.Errorf("Expected ratios without periods when no headers are stored, got %+v", history[0])
+	}
+}
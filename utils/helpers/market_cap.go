@@ -0,0 +1,28 @@
+package helpers
+
+import "stockbackend/config"
+
+// UnknownMarketCapCategory is the category of a market cap below the smallest tier
+const UnknownMarketCapCategory = "Unknown Category"
+
+// MarketCapValue reads the scraped market cap of a company in crore, nil when it isn't a number. It is
+// stored as marketCapValue so companies sort by their market cap rather than by its formatted text.
+func MarketCapValue(company map[string]interface{}) *float64 {
+	value, ok := parsePeerNumber(company["marketCap"])
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+// MarketCapCategory returns the first tier a market cap in crore reaches, once converted into the
+// unit of the tiers by rate (MARKET_CAP_UNIT_RATE)
+func MarketCapCategory(marketCap float64, tiers []config.MarketCapTier, rate float64) string {
+	value := marketCap * rate
+	for _, tier := range tiers {
+		if value >= tier.MinValue {
+			return tier.Name
+		}
+	}
+	return UnknownMarketCapCategory
+}
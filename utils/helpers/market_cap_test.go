@@ -0,0 +1,66 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"testing"
+)
+
+func TestMarketCapCategory(t *testing.T) {
+	microCap, err := config.ParseMarketCapTiers([]byte(`[
+		{"name": "Large Cap", "minValue": 20000},
+		{"name": "Mid Cap", "minValue": 5000},
+		{"name": "Small Cap", "minValue": 500},
+		{"name": "Micro Cap", "minValue": 0}
+	]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// USD millions, at 0.12 million per crore
+	usd := []config.MarketCapTier{{Name: "Large Cap", MinValue: 10000}, {Name: "Mid Cap", MinValue: 2000}, {Name: "Small Cap", MinValue: 300}}
+
+	tests := []struct {
+		name      string
+		marketCap float64
+		tiers     []config.MarketCapTier
+		rate      float64
+		expected  string
+	}{
+		{"default large", 20000, config.DefaultMarketCapTiers, 1, "Large Cap"},
+		{"default mid", 19999.99, config.DefaultMarketCapTiers, 1, "Mid Cap"},
+		{"default small", 4999, config.DefaultMarketCapTiers, 1, "Small Cap"},
+		{"four tiers small", 1200, microCap, 1, "Small Cap"},
+		{"four tiers micro", 499, microCap, 1, "Micro Cap"},
+		{"usd large", 100000, usd, 0.12, "Large Cap"},
+		{"usd mid", 20000, usd, 0.12, "Mid Cap"},
+		{"below the smallest tier", 1000, usd, 0.12, UnknownMarketCapCategory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MarketCapCategory(tt.marketCap, tt.tiers, tt.rate); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMarketCapValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		company  map[string]interface{}
+		expected *float64
+	}{
+		{"grouped crore", map[string]interface{}{"marketCap": "1,23,456"}, floatPtr(123456)},
+		{"decimal", map[string]interface{}{"marketCap": "950.5"}, floatPtr(950.5)},
+		{"blank", map[string]interface{}{"marketCap": ""}, nil},
+		{"not scraped", map[string]interface{}{}, nil},
+	}
+	for _, test := range tests {
+		got := MarketCapValue(test.company)
+		switch {
+		case test.expected == nil && got != nil:
+			t.Errorf("%s: expected none, got %v", test.name, *got)
+		case test.expected != nil && (got == nil || *got != *test.expected):
+			t.Errorf("%s: expected %v, got %v", test.name, *test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"stockbackend/types"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ErrInvalidNameMap is a name map import entry without a name, with an ISIN failing ValidateISIN or
+// without a company page URL
+var ErrInvalidNameMap = errors.New("invalid name map entry")
+
+// NameMapKey normalizes the name a name map entry is looked up by, case and spacing don't matter
+func NameMapKey(name string) string {
+	return strings.Join(strings.Fields(NormalizeString(name)), " ")
+}
+
+// NormalizeNameMap validates the entries of a name map import, keyed by their normalized name with
+// an upper case ISIN. Paths such as "/company/TCS/consolidated/" are made absolute against the
+// companyURL like search results. A name listed twice keeps its last entry.
+func NormalizeNameMap(entries []types.NameMapEntry, companyURL string, now time.Time) ([]types.NameMapEntry, error) {
+	normalized := []types.NameMapEntry{}
+	positions := map[string]int{}
+	for i, entry := range entries {
+		entry.Key = NameMapKey(entry.Name)
+		if entry.Key == "" {
+			return nil, fmt.Errorf("%w %d: name is required", ErrInvalidNameMap, i)
+		}
+		entry.Name = strings.TrimSpace(entry.Name)
+
+		entry.ISIN = strings.ToUpper(strings.TrimSpace(entry.ISIN))
+		if entry.ISIN != "" {
+			if err := ValidateISIN(entry.ISIN); err != nil {
+				return nil, fmt.Errorf("%w %d: %q: %v", ErrInvalidNameMap, i, entry.ISIN, err)
+			}
+		}
+
+		entry.URL = strings.TrimSpace(entry.URL)
+		if strings.HasPrefix(entry.URL, "/") {
+			entry.URL = strings.TrimSuffix(companyURL, "/") + entry.URL
+		}
+		if parsed, err := url.Parse(entry.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("%w %d: %q is not a company page URL", ErrInvalidNameMap, i, entry.URL)
+		}
+		entry.UpdatedAt = now
+
+		if position, ok := positions[entry.Key]; ok {
+			normalized[position] = entry
+			continue
+		}
+		positions[entry.Key] = len(normalized)
+		normalized = append(normalized, entry)
+	}
+	return normalized, nil
+}
+
+// NameMapLookupFilter matches the name map entries of a holding, by ISIN or by name
+func NameMapLookupFilter(isin, name string) bson.M {
+	filters := []bson.M{{"_id": NameMapKey(name)}}
+	if isin = strings.ToUpper(strings.TrimSpace(isin)); isin != "" {
+		filters = append(filters, bson.M{"isin": isin})
+	}
+	return bson.M{"$or": filters}
+}
+
+// PickNameMapEntry chooses the entry resolving a holding among the ones NameMapLookupFilter
+// matched: the entry of its ISIN wins over the entry of its name, nil when none matches
+func PickNameMapEntry(entries []types.NameMapEntry, isin, name string) *types.NameMapEntry {
+	isin = strings.ToUpper(strings.TrimSpace(isin))
+	key := NameMapKey(name)
+	var byName *types.NameMapEntry
+	for i := range entries {
+		if isin != "" && entries[i].ISIN == isin {
+			return &entries[i]
+		}
+		if entries[i].Key == key && byName == nil {
+			byName = &entries[i]
+		}
+	}
+	return byName
+}
+
+// MappedCompanyFilter matches the stored company of a name map entry, by its ISIN or page URL
+func MappedCompanyFilter(entry types.NameMapEntry) bson.M {
+	filters := []bson.M{{"url": entry.URL}}
+	if entry.ISIN != "" {
+		filters = append(filters, bson.M{"isin": entry.ISIN})
+	}
+	return bson.M{"$or": filters}
+}
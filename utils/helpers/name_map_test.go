@@ -0,0 +1,94 @@
+package helpers
+
+import (
+	"errors"
+	"reflect"
+	"stockbackend/types"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestNormalizeNameMap(t *testing.T) {
+	now := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	entries := []types.NameMapEntry{
+		{Name: " Sun Pharmaceutical  Industries Limited ", ISIN: "ine044a01036", URL: "/company/SUNPHARMA/consolidated/"},
+		{Name: "KEC International Limited", URL: "https://www.screener.in/company/KEC/"},
+		// Listed again, the last entry of a name wins
+		{Name: "sun pharmaceutical industries limited", ISIN: "INE044A01036", URL: "https://www.screener.in/company/SUNPHARMA/"},
+	}
+	got, err := NormalizeNameMap(entries, "https://www.screener.in/", now)
+	if err != nil {
+		t.Fatalf("NormalizeNameMap() error = %v", err)
+	}
+	want := []types.NameMapEntry{
+		{Key: "sun pharmaceutical industries limited", Name: "sun pharmaceutical industries limited", ISIN: "INE044A01036", URL: "https://www.screener.in/company/SUNPHARMA/", UpdatedAt: now},
+		{Key: "kec international limited", Name: "KEC International Limited", URL: "https://www.screener.in/company/KEC/", UpdatedAt: now},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeNameMap() = %+v, want %+v", got, want)
+	}
+
+	relative, _ := NormalizeNameMap(entries[:1], "https://www.screener.in/", now)
+	if relative[0].URL != "https://www.screener.in/company/SUNPHARMA/consolidated/" || relative[0].ISIN != "INE044A01036" {
+		t.Errorf("Expected an absolute URL and upper case ISIN, got %+v", relative[0])
+	}
+
+	invalid := []types.NameMapEntry{
+		{Name: " ", URL: "https://www.screener.in/company/TCS/"},
+		{Name: "TCS", ISIN: "INE467B0102", URL: "https://www.screener.in/company/TCS/"},
+		// A wrong check digit, INE467B01029 is TCS
+		{Name: "TCS", ISIN: "INE467B01028", URL: "https://www.screener.in/company/TCS/"},
+		{Name: "TCS"},
+		{Name: "TCS", URL: "company/TCS"},
+		{Name: "TCS", URL: "ftp://www.screener.in/company/TCS/"},
+	}
+	for _, entry := range invalid {
+		if _, err := NormalizeNameMap([]types.NameMapEntry{entry}, "https://www.screener.in", now); !errors.Is(err, ErrInvalidNameMap) {
+			t.Errorf("Expected ErrInvalidNameMap for %+v, got %v", entry, err)
+		}
+	}
+}
+
+func TestPickNameMapEntry(t *testing.T) {
+	byName := types.NameMapEntry{Key: "hdfc bank limited", Name: "HDFC Bank Limited", URL: "https://www.screener.in/company/HDFCBANK/"}
+	byISIN := types.NameMapEntry{Key: "hdfc bank", Name: "HDFC Bank", ISIN: "INE040A01034", URL: "https://www.screener.in/company/HDFCBANK/consolidated/"}
+	entries := []types.NameMapEntry{byName, byISIN}
+
+	tests := []struct {
+		name       string
+		isin       string
+		instrument string
+		want       *types.NameMapEntry
+	}{
+		{"isin wins over name", "ine040a01034", "HDFC Bank  Limited", &byISIN},
+		{"name without isin", "", "hdfc bank limited", &byName},
+		{"name with another isin", "INE001A01036", "HDFC Bank Limited", &byName},
+		{"no entry", "INE001A01036", "Housing Development Finance", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PickNameMapEntry(entries, tt.isin, tt.instrument); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PickNameMapEntry() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameMapFilters(t *testing.T) {
+	lookup := NameMapLookupFilter(" ine040a01034 ", "HDFC Bank Limited")
+	want := bson.M{"$or": []bson.M{{"_id": "hdfc bank limited"}, {"isin": "INE040A01034"}}}
+	if !reflect.DeepEqual(lookup, want) {
+		t.Errorf("NameMapLookupFilter() = %v, want %v", lookup, want)
+	}
+	if lookup := NameMapLookupFilter("", "HDFC Bank"); !reflect.DeepEqual(lookup, bson.M{"$or": []bson.M{{"_id": "hdfc bank"}}}) {
+		t.Errorf("Expected a name only lookup without an ISIN, got %v", lookup)
+	}
+
+	company := MappedCompanyFilter(types.NameMapEntry{ISIN: "INE040A01034", URL: "https://www.screener.in/company/HDFCBANK/"})
+	want = bson.M{"$or": []bson.M{{"url": "https://www.screener.in/company/HDFCBANK/"}, {"isin": "INE040A01034"}}}
+	if !reflect.DeepEqual(company, want) {
+		t.Errorf("MappedCompanyFilter() = %v, want %v", company, want)
+	}
+}
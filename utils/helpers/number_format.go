@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"regexp"
+	"stockbackend/config"
+	"strconv"
+	"strings"
+)
+
+// NumberFormat describes how grouping and decimal separators are written in source data
+type NumberFormat string
+
+const (
+	// NumberFormatUS treats commas as grouping separators and the dot as decimal separator (default)
+	NumberFormatUS NumberFormat = "us"
+	// NumberFormatIndian uses lakh/crore grouping ("1,23,456.78") with a dot decimal separator
+	NumberFormatIndian NumberFormat = "indian"
+	// NumberFormatEU treats dots (or spaces) as grouping separators and the comma as decimal separator
+	NumberFormatEU NumberFormat = "eu"
+	// NumberFormatAuto infers the separators from the shape of each value
+	NumberFormatAuto NumberFormat = "auto"
+)
+
+// Matches comma grouping in either the western ("1,234,567") or Indian ("12,34,567") style
+var commaGroupingPattern = regexp.MustCompile(`^[+-]?(\d{1,3}(,\d{3})+|\d{1,2}(,\d{2})*,\d{3})$`)
+
+// CurrentNumberFormat returns the configured number format (NUMBER_FORMAT), defaulting to NumberFormatUS
+func CurrentNumberFormat() NumberFormat {
+	return NumberFormat(config.Get().NumberFormat)
+}
+
+// ParseNumber parses a number written in the given format
+func ParseNumber(value string, format NumberFormat) (float64, error) {
+	value = strings.TrimSpace(value)
+	switch format {
+	case NumberFormatEU:
+		value = strings.NewReplacer(".", "", " ", "", "\u00a0", "").Replace(value)
+		value = strings.ReplaceAll(value, ",", ".")
+	case NumberFormatAuto:
+		value = normalizeAutoNumber(value)
+	default:
+		// US and Indian numbers only differ in where the grouping commas go
+		value = strings.ReplaceAll(value, ",", "")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// normalizeAutoNumber rewrites a number with unknown separators into the form strconv expects.
+// When both separators are present the last one is the decimal separator, a lone comma is only
+// treated as grouping when the digits around it follow western or Indian grouping.
+func normalizeAutoNumber(value string) string {
+	value = strings.NewReplacer(" ", "", "\u00a0", "").Replace(value)
+	lastComma := strings.LastIndex(value, ",")
+	lastDot := strings.LastIndex(value, ".")
+
+	switch {
+	case lastComma >= 0 && lastDot >= 0:
+		if lastComma > lastDot {
+			value = strings.ReplaceAll(value, ".", "")
+			return strings.ReplaceAll(value, ",", ".")
+		}
+		return strings.ReplaceAll(value, ",", "")
+	case lastComma >= 0:
+		if commaGroupingPattern.MatchString(value) {
+			return strings.ReplaceAll(value, ",", "")
+		}
+		if strings.Count(value, ",") == 1 {
+			return strings.ReplaceAll(value, ",", ".")
+		}
+		return strings.ReplaceAll(value, ",", "")
+	case strings.Count(value, ".") > 1:
+		// Several dots can only be grouping separators
+		return strings.ReplaceAll(value, ".", "")
+	}
+	return value
+}
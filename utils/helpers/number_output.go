@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"strconv"
+	"strings"
+)
+
+// rupeeSymbol prefixes the formatted amounts
+const rupeeSymbol = "₹"
+
+// CurrentOutputNumberFormat returns the format of the formatted fields added next to numeric ones
+// (OUTPUT_NUMBER_FORMAT): indian (default), us or eu. It reports false when set to none, responses
+// then carry the numeric fields only.
+func CurrentOutputNumberFormat() (NumberFormat, bool) {
+	format := config.Get().OutputNumberFormat
+	if format == "none" {
+		return "", false
+	}
+	return NumberFormat(format), true
+}
+
+// FormatNumber writes a number for display, rounded to DECIMAL_PLACES without trailing zeros and
+// grouped in the format: "1,23,456.78" (indian), "123,456.78" (us) or "123.456,78" (eu)
+func FormatNumber(value float64, format NumberFormat) string {
+	value = Round(value)
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	integer, fraction, _ := strings.Cut(strconv.FormatFloat(value, 'f', -1, 64), ".")
+	grouping, decimal := ",", "."
+	if format == NumberFormatEU {
+		grouping, decimal = ".", ","
+	}
+
+	formatted := sign + groupDigits(integer, grouping, format == NumberFormatIndian)
+	if fraction != "" {
+		formatted += decimal + fraction
+	}
+	return formatted
+}
+
+// FormatRupees writes an amount in rupees for display, e.g. "₹1,23,456 Cr" with the "Cr" unit, the
+// unit is left out when empty
+func FormatRupees(value float64, unit string, format NumberFormat) string {
+	number := FormatNumber(value, format)
+	formatted := rupeeSymbol + strings.TrimPrefix(number, "-")
+	if strings.HasPrefix(number, "-") {
+		formatted = "-" + formatted
+	}
+	if unit != "" {
+		formatted += " " + unit
+	}
+	return formatted
+}
+
+// groupDigits inserts the grouping separator into a run of digits: every 3 digits, or the last 3
+// then every 2 in the indian lakh/crore style
+func groupDigits(digits, separator string, indian bool) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	head, tail := digits[:len(digits)-3], digits[len(digits)-3:]
+	size := 3
+	if indian {
+		size = 2
+	}
+
+	var groups []string
+	for len(head) > size {
+		groups = append([]string{head[len(head)-size:]}, groups...)
+		head = head[:len(head)-size]
+	}
+	groups = append([]string{head}, groups...)
+	return strings.Join(append(groups, tail), separator)
+}
+
+// FormatMarketCap writes a market cap in crore for display, e.g. "₹1,23,456 Cr", reporting false when
+// OUTPUT_NUMBER_FORMAT is none
+func FormatMarketCap(marketCap float64) (string, bool) {
+	format, ok := CurrentOutputNumberFormat()
+	if !ok {
+		return "", false
+	}
+	return FormatRupees(marketCap, "Cr", format), true
+}
+
+// AddCompanyFormattedFields adds the marketCapFormatted display string to a stored company, see
+// FormatMarketCap. Nothing is added when its market cap isn't known.
+func AddCompanyFormattedFields(company map[string]interface{}) {
+	if marketCap := MarketCapValue(company); marketCap != nil {
+		if formatted, ok := FormatMarketCap(*marketCap); ok {
+			company["marketCapFormatted"] = formatted
+		}
+	}
+}
+
+// AddFormattedFields adds display strings next to the numeric fields of a holding: quantityFormatted,
+// marketValueFormatted and marketCapFormatted (in crore). The market value is in the unit and currency
+// of the sheet, which it doesn't state, so it is grouped without a currency symbol. Nothing is added
+// when OUTPUT_NUMBER_FORMAT is none or the numeric field is missing.
+func AddFormattedFields(stockDetail map[string]interface{}) {
+	format, ok := CurrentOutputNumberFormat()
+	if !ok {
+		return
+	}
+	if quantity, ok := stockDetail["quantity"].(float64); ok {
+		stockDetail["quantityFormatted"] = FormatNumber(quantity, format)
+	}
+	if marketValue, ok := stockDetail["marketValue"].(float64); ok {
+		stockDetail["marketValueFormatted"] = FormatNumber(marketValue, format)
+	}
+	if marketCap, ok := parsePeerNumber(stockDetail["marketCapValue"]); ok {
+		stockDetail["marketCapFormatted"] = FormatRupees(marketCap, "Cr", format)
+	}
+}
@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/config"
+	"testing"
+)
+
+func TestFormatNumber_Indian(t *testing.T) {
+	tests := map[float64]string{
+		0:              "0",
+		999:            "999",
+		1000:           "1,000",
+		123456:         "1,23,456",
+		1234567.891:    "12,34,567.89",
+		123456789:      "12,34,56,789",
+		-98765.5:       "-98,765.5",
+		12345678901234: "1,23,45,67,89,01,234",
+	}
+	for value, expected := range tests {
+		if formatted := FormatNumber(value, NumberFormatIndian); formatted != expected {
+			t.Errorf("FormatNumber(%v): expected %q, got %q", value, expected, formatted)
+		}
+	}
+}
+
+func TestFormatNumber_Formats(t *testing.T) {
+	if formatted := FormatNumber(1234567.5, NumberFormatUS); formatted != "1,234,567.5" {
+		t.Errorf("Expected 1,234,567.5, got %q", formatted)
+	}
+	if formatted := FormatNumber(1234567.5, NumberFormatEU); formatted != "1.234.567,5" {
+		t.Errorf("Expected 1.234.567,5, got %q", formatted)
+	}
+	if formatted := FormatRupees(123456, "Cr", NumberFormatIndian); formatted != "₹1,23,456 Cr" {
+		t.Errorf("Expected ₹1,23,456 Cr, got %q", formatted)
+	}
+	if formatted := FormatRupees(-2500.25, "", NumberFormatIndian); formatted != "-₹2,500.25" {
+		t.Errorf("Expected -₹2,500.25, got %q", formatted)
+	}
+}
+
+func TestAddFormattedFields(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{}))
+
+	stockDetail := map[string]interface{}{"quantity": 1234567.0, "marketValue": 98765.43, "marketCapValue": "1,23,456", "Quantity": "12,34,567"}
+	AddFormattedFields(stockDetail)
+	expected := map[string]interface{}{
+		"quantity":             1234567.0,
+		"marketValue":          98765.43,
+		"marketCapValue":       "1,23,456",
+		"Quantity":             "12,34,567",
+		"quantityFormatted":    "12,34,567",
+		"marketValueFormatted": "98,765.43",
+		"marketCapFormatted":   "₹1,23,456 Cr",
+	}
+	if !reflect.DeepEqual(stockDetail, expected) {
+		t.Errorf("Expected %v, got %v", expected, stockDetail)
+	}
+
+	// Blank amounts are parsed as nil and get no formatted field
+	blank := map[string]interface{}{"quantity": nil}
+	AddFormattedFields(blank)
+	if _, ok := blank["quantityFormatted"]; ok {
+		t.Errorf("Expected no formatted quantity, got %v", blank)
+	}
+
+	config.Set(config.FromMap(map[string]string{"OUTPUT_NUMBER_FORMAT": "none"}))
+	disabled := map[string]interface{}{"quantity": 10.0}
+	AddFormattedFields(disabled)
+	if len(disabled) != 1 {
+		t.Errorf("Expected no formatted fields with OUTPUT_NUMBER_FORMAT=none, got %v", disabled)
+	}
+
+	config.Set(config.FromMap(map[string]string{"OUTPUT_NUMBER_FORMAT": "us"}))
+	western := map[string]interface{}{"quantity": 1234567.0}
+	AddFormattedFields(western)
+	if western["quantityFormatted"] != "1,234,567" {
+		t.Errorf("Expected 1,234,567, got %v", western["quantityFormatted"])
+	}
+}
+
+func TestAddCompanyFormattedFields(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{}))
+
+	company := map[string]interface{}{"name": "TCS", "marketCap": "12,34,567"}
+	AddCompanyFormattedFields(company)
+	if company["marketCapFormatted"] != "₹12,34,567 Cr" {
+		t.Errorf("Expected ₹12,34,567 Cr, got %v", company["marketCapFormatted"])
+	}
+
+	invalidated := map[string]interface{}{"name": "TCS"}
+	AddCompanyFormattedFields(invalidated)
+	if _, ok := invalidated["marketCapFormatted"]; ok {
+		t.Errorf("Expected no formatted market cap without one, got %v", invalidated)
+	}
+
+	config.Set(config.FromMap(map[string]string{"OUTPUT_NUMBER_FORMAT": "none"}))
+	if formatted, ok := FormatMarketCap(1000); ok {
+		t.Errorf("Expected no formatted market cap with OUTPUT_NUMBER_FORMAT=none, got %q", formatted)
+	}
+}
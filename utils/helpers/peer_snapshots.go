@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"stockbackend/types"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// PeerHistoryEntry is a stored peer snapshot with its peer comparison score, Change is the
+// difference with the previous snapshot and nil for the oldest one
+type PeerHistoryEntry struct {
+	ScrapedAt interface{}   `json:"scrapedAt"`
+	PeerScore float64       `json:"peerScore"`
+	Change    *float64      `json:"change"`
+	Peers     []interface{} `json:"peers"`
+}
+
+// PeerSnapshot captures the scraped peers with the stock's own metrics they were compared against,
+// nil when the scrape has no peers
+func PeerSnapshot(fields map[string]interface{}, scrapedAt time.Time) bson.M {
+	peers, ok := toArray(fields["peers"])
+	if !ok || len(peers) == 0 {
+		return nil
+	}
+	return bson.M{
+		"scrapedAt":     scrapedAt,
+		"stockPE":       fields["stockPE"],
+		"marketCap":     fields["marketCap"],
+		"dividendYield": fields["dividendYield"],
+		"roce":          fields["roce"],
+		"peers":         fields["peers"],
+	}
+}
+
+// PeerSnapshotsPush appends the snapshot to peerSnapshots, keeping the last PEER_SNAPSHOTS of them
+func PeerSnapshotsPush(snapshot bson.M) bson.M {
+	return bson.M{"peerSnapshots": bson.M{
+		"$each":  []bson.M{snapshot},
+		"$slice": -config.Get().PeerSnapshots,
+	}}
+}
+
+// PeerSnapshotScore is the unweighted peer comparison score of a snapshot
+func PeerSnapshotScore(snapshot interface{}) float64 {
+	fields, ok := toMap(snapshot)
+	if !ok {
+		return 0
+	}
+	peers, _ := toArray(fields["peers"])
+	// compareWithPeers expects the shape of a decoded document
+	normalized := make(primitive.A, 0, len(peers))
+	for _, peerRaw := range peers {
+		peer, _ := toMap(peerRaw)
+		normalized = append(normalized, bson.M(peer))
+	}
+	stock := types.Stock{
+		PE:            ToFloat(fields["stockPE"]),
+		MarketCap:     ToFloat(fields["marketCap"]),
+		DividendYield: ToFloat(fields["dividendYield"]),
+		ROCE:          ToFloat(fields["roce"]),
+	}
+	return Round(compareWithPeers(stock, normalized, nil))
+}
+
+// PeerScoreChange is how much the peer comparison score moved from one snapshot to a later one
+func PeerScoreChange(from, to interface{}) float64 {
+	return Round(PeerSnapshotScore(to) - PeerSnapshotScore(from))
+}
+
+// PeerHistory scores the stored peerSnapshots, oldest first
+func PeerHistory(snapshots interface{}) []PeerHistoryEntry {
+	history := []PeerHistoryEntry{}
+	arr, _ := toArray(snapshots)
+	for i, snapshotRaw := range arr {
+		snapshot, ok := toMap(snapshotRaw)
+		if !ok {
+			continue
+		}
+		peers, _ := toArray(snapshot["peers"])
+		entry := PeerHistoryEntry{
+			ScrapedAt: snapshot["scrapedAt"],
+			PeerScore: PeerSnapshotScore(snapshot),
+			Peers:     []interface{}(peers),
+		}
+		if i > 0 {
+			change := PeerScoreChange(arr[i-1], snapshot)
+			entry.Change = &change
+		}
+		history = append(history, entry)
+	}
+	return history
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/config"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var snapshotPeers = []map[string]string{
+	{"name": "Infosys", "pe": "25", "market_cap": "600000", "div_yield": "2", "roce": "30"},
+	{"name": "Wipro", "pe": "20", "market_cap": "250000", "div_yield": "1", "roce": "20"},
+	{PeerMedianMarker: "true", "company_count": "Median: 2 Co.", "pe": "22", "market_cap": "400000", "div_yield": "1.5", "roce": "25"},
+}
+
+func TestPeerSnapshot(t *testing.T) {
+	scrapedAt := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	fields := map[string]interface{}{"stockPE": "18", "marketCap": "500000", "dividendYield": "1.2", "roce": "22", "peers": snapshotPeers}
+
+	snapshot := PeerSnapshot(fields, scrapedAt)
+	if snapshot["scrapedAt"] != scrapedAt || snapshot["stockPE"] != "18" || !reflect.DeepEqual(snapshot["peers"], snapshotPeers) {
+		t.Errorf("Unexpected snapshot: %v", snapshot)
+	}
+	if snapshot := PeerSnapshot(map[string]interface{}{"stockPE": "18"}, scrapedAt); snapshot != nil {
+		t.Errorf("Expected no snapshot without peers, got %v", snapshot)
+	}
+}
+
+func TestPeerSnapshotsPush(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"PEER_SNAPSHOTS": "3"}))
+
+	snapshot := bson.M{"peers": snapshotPeers}
+	expected := bson.M{"peerSnapshots": bson.M{"$each": []bson.M{snapshot}, "$slice": -3}}
+	if result := PeerSnapshotsPush(snapshot); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestPeerScoreChange(t *testing.T) {
+	before := bson.M{"stockPE": "18", "marketCap": "500000", "dividendYield": "1.2", "roce": "22", "peers": snapshotPeers}
+	// The stock's ROCE fell below both peers, stored snapshots decode as primitive types
+	after := primitive.M{"stockPE": "18", "marketCap": "500000", "dividendYield": "1.2", "roce": "15", "peers": primitive.A{
+		primitive.M{"name": "Infosys", "pe": "25", "market_cap": "600000", "div_yield": "2", "roce": "30"},
+		primitive.M{"name": "Wipro", "pe": "20", "market_cap": "250000", "div_yield": "1", "roce": "20"},
+		primitive.M{PeerMedianMarker: "true", "company_count": "Median: 2 Co.", "pe": "22", "market_cap": "400000", "div_yield": "1.5", "roce": "25"},
+	}}
+
+	if score := PeerSnapshotScore(before); score <= 0 {
+		t.Fatalf("Expected a positive peer score, got %v", score)
+	}
+	change := PeerScoreChange(before, after)
+	if change >= 0 {
+		t.Errorf("Expected the peer score to drop, got %v", change)
+	}
+	if reverse := PeerScoreChange(after, before); reverse != -change {
+		t.Errorf("Expected the reverse change to be %v, got %v", -change, reverse)
+	}
+
+	history := PeerHistory(primitive.A{before, after})
+	if len(history) != 2 || history[0].Change != nil || history[1].Change == nil || *history[1].Change != change {
+		t.Errorf("Unexpected peer history: %+v", history)
+	}
+	if history := PeerHistory(nil); len(history) != 0 {
+		t.Errorf("Expected an empty history without snapshots, got %+v", history)
+	}
+}
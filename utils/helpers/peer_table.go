@@ -0,0 +1,145 @@
+package helpers
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// peerColumns matches the headers of the screener peers table onto the keys peers are stored under,
+// position is the column the metric used to be read from and is only used when no header is recognized
+var peerColumns = []struct {
+	key      string
+	patterns []string
+	position int
+}{
+	{"current_price", []string{`^cmp`, `current\s*price`}, 2},
+	{"pe", []string{`^p\s*/\s*e`}, 3},
+	{"market_cap", []string{`mar(ket)?\s*cap`}, 4},
+	{"div_yield", []string{`div(idend)?\s*y(ie)?ld`}, 5},
+	{"np_qtr", []string{`^np\s*qtr`, `net\s*profit\s*q(ua)?rt?`}, 6},
+	{"qtr_profit_var", []string{`qtr\s*profit\s*var`}, 7},
+	{"sales_qtr", []string{`^sales\s*qtr`}, 8},
+	{"qtr_sales_var", []string{`qtr\s*sales\s*var`}, 9},
+	{"roce", []string{`^roce`}, 10},
+}
+
+// peerNamePosition is the column of the peer name, which holds the company count in the median row
+const peerNamePosition = 1
+
+// PeerMedianMarker tags the median row of a peers table, it is stored among the peers
+const PeerMedianMarker = "__median"
+
+// IsPeerMedian reports whether a stored peer is the median row of its table. Peers stored before
+// the row was tagged are recognized by their company_count, which only the median row has.
+func IsPeerMedian(peer map[string]interface{}) bool {
+	if marker, _ := peer[PeerMedianMarker].(string); marker == "true" {
+		return true
+	}
+	_, hasCount := peer["company_count"]
+	_, hasName := peer["name"]
+	return hasCount && !hasName
+}
+
+// SplitPeers separates the peer rows of a stored peers table from its median row, which is nil
+// when the table had none. Entries that aren't maps are skipped.
+func SplitPeers(peers interface{}) ([]map[string]interface{}, map[string]interface{}) {
+	arr, _ := toArray(peers)
+	rows := []map[string]interface{}{}
+	var median map[string]interface{}
+	for _, peerRaw := range arr {
+		peer, ok := toMap(peerRaw)
+		if !ok {
+			continue
+		}
+		if IsPeerMedian(peer) {
+			median = peer
+			continue
+		}
+		rows = append(rows, peer)
+	}
+	return rows, median
+}
+
+// BuildPeerColumnMap maps the peer metrics onto the columns of the peers table header, in whatever
+// order they appear. It reports false when none of the headers is recognized.
+func BuildPeerColumnMap(headers []string) (map[string]int, bool) {
+	columns := make(map[string]int)
+	for i, header := range headers {
+		header = strings.Join(strings.Fields(header), " ")
+		if MatchHeader(header, []string{`^name$`}) {
+			columns["name"] = i
+			continue
+		}
+		for _, column := range peerColumns {
+			if _, taken := columns[column.key]; !taken && MatchHeader(header, column.patterns) {
+				columns[column.key] = i
+				break
+			}
+		}
+	}
+	return columns, len(columns) > 0
+}
+
+// positionalPeerColumns is the historical screener layout, used when the header can't be read
+func positionalPeerColumns() map[string]int {
+	columns := map[string]int{"name": peerNamePosition}
+	for _, column := range peerColumns {
+		columns[column.key] = column.position
+	}
+	return columns
+}
+
+// ParsePeersAPITable reads the peers of a screener peers API table by column name, followed by the median
+// row of its footer tagged with PeerMedianMarker. A table without a footer has no median row. Metrics whose
+// column is missing are left out rather than read from another column.
+func ParsePeersAPITable(doc *goquery.Document) []map[string]string {
+	// The header is the first row made of th cells, whether or not it sits in a thead
+	headers := []string{}
+	doc.Find("tr").FilterFunction(func(i int, tr *goquery.Selection) bool {
+		return tr.Find("th").Length() > 0
+	}).First().Find("th").Each(func(i int, th *goquery.Selection) {
+		headers = append(headers, th.Text())
+	})
+	columns, ok := BuildPeerColumnMap(headers)
+	if !ok {
+		columns = positionalPeerColumns()
+	}
+
+	readRow := func(cells *goquery.Selection, row map[string]string) {
+		for _, column := range peerColumns {
+			if i, ok := columns[column.key]; ok && i < cells.Length() {
+				row[column.key] = strings.TrimSpace(cells.Eq(i).Text())
+			}
+		}
+	}
+
+	var peersData []map[string]string
+	var medianData map[string]string
+
+	// Parse peers data from the table rows
+	doc.Find("tr[data-row-company-id]").Each(func(index int, item *goquery.Selection) {
+		peer := map[string]string{"name": item.Find("td.text a").Text()}
+		readRow(item.Find("td"), peer)
+		peersData = append(peersData, peer)
+	})
+
+	// Parse median data from the footer of the table
+	doc.Find("tfoot tr").Each(func(index int, item *goquery.Selection) {
+		cells := item.Find("td")
+		nameColumn, ok := columns["name"]
+		if !ok {
+			nameColumn = peerNamePosition
+		}
+		if cells.Length() == 0 {
+			return
+		}
+		medianData = map[string]string{PeerMedianMarker: "true", "company_count": strings.TrimSpace(cells.Eq(nameColumn).Text())}
+		readRow(cells, medianData)
+	})
+
+	if medianData != nil {
+		peersData = append(peersData, medianData)
+	}
+	return peersData
+}
@@ -0,0 +1,145 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/types"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// peersTableFixture follows the screener peers API layout with the ROCE column moved before P/E,
+// an extra column and no dividend yield column
+const peersTableFixture = `<table class="data-table">
+<tbody>
+<tr>
+	<th>S.No.</th><th>Name</th><th>CMP <span>Rs.</span></th><th>ROCE <span>%</span></th><th>P/E</th>
+	<th>Mar Cap <span>Rs.Cr.</span></th><th>NP Qtr <span>Rs.Cr.</span></th><th>Qtr Profit Var <span>%</span></th>
+	<th>Sales Qtr <span>Rs.Cr.</span></th><th>Qtr Sales Var <span>%</span></th><th>EPS <span>Rs.</span></th>
+</tr>
+<tr data-row-company-id="1">
+	<td>1.</td><td class="text"><a href="/company/ALPHA/">Alpha Ltd</a></td><td>100.5</td><td>21.3</td><td>18.2</td>
+	<td>5,000</td><td>120</td><td>10.5</td><td>900</td><td>8.1</td><td>12</td>
+</tr>
+<tr data-row-company-id="2">
+	<td>2.</td><td class="text"><a href="/company/BETA/">Beta Ltd</a></td><td>50</td><td>15</td><td>25.4</td>
+	<td>2,500</td><td>40</td><td>-3.2</td><td>300</td><td>4.4</td><td>5</td>
+</tr>
+</tbody>
+<tfoot>
+<tr>
+	<td></td><td>Median: 2 Co.</td><td>75.25</td><td>18.15</td><td>21.8</td>
+	<td>3,750</td><td>80</td><td>3.65</td><td>600</td><td>6.25</td><td>8.5</td>
+</tr>
+</tfoot>
+</table>`
+
+func TestParsePeersAPITable_ByHeader(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(peersTableFixture))
+	if err != nil {
+		t.Fatalf("Error parsing fixture: %v", err)
+	}
+
+	peers := ParsePeersAPITable(doc)
+	if len(peers) != 3 {
+		t.Fatalf("Expected 2 peers and the median, got %v", peers)
+	}
+	expectedAlpha := map[string]string{
+		"name":           "Alpha Ltd",
+		"current_price":  "100.5",
+		"pe":             "18.2",
+		"market_cap":     "5,000",
+		"np_qtr":         "120",
+		"qtr_profit_var": "10.5",
+		"sales_qtr":      "900",
+		"qtr_sales_var":  "8.1",
+		"roce":           "21.3",
+	}
+	if !reflect.DeepEqual(peers[0], expectedAlpha) {
+		t.Errorf("Expected %v, got %v", expectedAlpha, peers[0])
+	}
+	if _, ok := peers[1]["div_yield"]; ok {
+		t.Errorf("Expected no dividend yield without its column, got %q", peers[1]["div_yield"])
+	}
+
+	median := peers[2]
+	if median["company_count"] != "Median: 2 Co." || median["pe"] != "21.8" || median["roce"] != "18.15" {
+		t.Errorf("Expected the median read by column name, got %v", median)
+	}
+	if median[PeerMedianMarker] != "true" {
+		t.Errorf("Expected the median to be tagged, got %v", median)
+	}
+}
+
+func TestParsePeersAPITable_PositionalFallback(t *testing.T) {
+	fixture := `<table><tbody>
+<tr><th>#</th><th>Co.</th><th>A</th><th>B</th><th>C</th><th>D</th><th>E</th><th>F</th><th>G</th><th>H</th><th>I</th></tr>
+<tr data-row-company-id="1"><td>1.</td><td class="text"><a>Alpha Ltd</a></td><td>100</td><td>18</td><td>5,000</td><td>1.2</td><td>120</td><td>10</td><td>900</td><td>8</td><td>21</td></tr>
+</tbody></table>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatalf("Error parsing fixture: %v", err)
+	}
+
+	peers := ParsePeersAPITable(doc)
+	if peers[0]["pe"] != "18" || peers[0]["div_yield"] != "1.2" || peers[0]["roce"] != "21" {
+		t.Errorf("Expected the historical column positions, got %v", peers[0])
+	}
+	if len(peers) != 1 {
+		t.Errorf("Expected no median row without a footer, got %v", peers)
+	}
+}
+
+func TestSplitPeers(t *testing.T) {
+	rows, median := SplitPeers(primitive.A{
+		bson.M{"name": "Alpha Ltd", "pe": "18"},
+		bson.M{PeerMedianMarker: "true", "company_count": "Median: 2 Co.", "pe": "20"},
+		bson.M{"name": "Beta Ltd", "pe": "22"},
+	})
+	if len(rows) != 2 || rows[1]["name"] != "Beta Ltd" || median["pe"] != "20" {
+		t.Errorf("Expected the tagged median wherever it is, got %v and %v", rows, median)
+	}
+
+	// Peers stored before the median was tagged
+	rows, median = SplitPeers([]map[string]string{{"name": "Alpha Ltd"}, {"company_count": "Median: 1 Co.", "pe": "18"}})
+	if len(rows) != 1 || median["pe"] != "18" {
+		t.Errorf("Expected an untagged median to be recognized by its company count, got %v and %v", rows, median)
+	}
+
+	rows, median = SplitPeers(primitive.A{bson.M{"name": "Alpha Ltd"}, bson.M{"name": "Beta Ltd"}})
+	if len(rows) != 2 || median != nil {
+		t.Errorf("Expected no median, got %v and %v", rows, median)
+	}
+}
+
+func TestCompareWithPeers_WithoutMedian(t *testing.T) {
+	stock := types.Stock{PE: 10, ROCE: 20}
+	peers := primitive.A{bson.M{"name": "Alpha Ltd", "pe": "20", "roce": "10"}, bson.M{"name": "Beta Ltd", "pe": "15", "roce": "15"}}
+
+	// Below both peers' PE and above their ROCE, without the median awards
+	if score := compareWithPeers(stock, peers, nil); score != 20 {
+		t.Errorf("Expected 20, got %v", score)
+	}
+	withMedian := append(primitive.A{bson.M{PeerMedianMarker: "true", "pe": "17.5", "roce": "12.5"}}, peers...)
+	if score := compareWithPeers(stock, withMedian, nil); score != 25 {
+		t.Errorf("Expected the leading tagged median to add 5, got %v", score)
+	}
+}
+
+func TestBuildPeerColumnMap(t *testing.T) {
+	columns, ok := BuildPeerColumnMap([]string{"S.No.", "Name", "Div Yld\n %", "Dividend Yield %", "P/E"})
+	if !ok {
+		t.Fatalf("Expected the headers to be recognized")
+	}
+	expected := map[string]int{"name": 1, "div_yield": 2, "pe": 4}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected %v, got %v", expected, columns)
+	}
+
+	if _, ok := BuildPeerColumnMap([]string{"#", "Company", "Price"}); ok {
+		t.Errorf("Expected unknown headers not to be recognized")
+	}
+}
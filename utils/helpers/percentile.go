@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"strings"
+)
+
+// peerPercentileMetrics maps the percentile keys onto the peer table columns and the stock fields
+// holding the subject's own value (quarterly figures are only known from the peer table)
+var peerPercentileMetrics = []struct {
+	key        string
+	peerColumn string
+	stockField string
+}{
+	{"pe", "pe", "stockPE"},
+	{"marketCap", "market_cap", "marketCap"},
+	{"dividendYield", "div_yield", "dividendYield"},
+	{"roce", "roce", "roce"},
+	{"quarterlySales", "sales_qtr", ""},
+	{"quarterlyProfit", "np_qtr", ""},
+}
+
+// minPercentilePeers is the smallest peer set a percentile is computed against
+const minPercentilePeers = 2
+
+// parsePeerNumber reads a peer table cell, blanks and non numeric cells are reported as missing
+func parsePeerNumber(value interface{}) (float64, bool) {
+	str, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+	str = strings.TrimSpace(strings.ReplaceAll(str, "%", ""))
+	if str == "" {
+		return 0, false
+	}
+	f, err := ParseNumber(str, CurrentNumberFormat())
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// PercentileRank returns the share of values below the subject, counting ties as half,
+// on a 0-100 scale, e.g. a subject above all the values ranks 100 and one tied with all of them 50
+func PercentileRank(subject float64, values []float64) float64 {
+	below, equal := 0.0, 0.0
+	for _, value := range values {
+		if value < subject {
+			below++
+		} else if value == subject {
+			equal++
+		}
+	}
+	return (below + 0.5*equal) / float64(len(values)) * 100
+}
+
+// PeerPercentiles ranks a stock against its peers on every peer table metric, a higher
+// percentile meaning a higher value (so a low PE percentile is a relatively cheap stock).
+// The stock's own row is left out of the peer set, as is the median row, and metrics with
+// fewer than two comparable peers are omitted.
+func PeerPercentiles(stock map[string]interface{}) map[string]float64 {
+	percentiles := map[string]float64{}
+	peers, _ := SplitPeers(stock["peers"])
+	if len(peers) == 0 {
+		return percentiles
+	}
+
+	name, _ := stock["name"].(string)
+	var own map[string]interface{}
+	var others []map[string]interface{}
+	for _, peer := range peers {
+		peerName, _ := peer["name"].(string)
+		if own == nil && name != "" && NormalizeCompanyName(peerName) == NormalizeCompanyName(name) {
+			own = peer
+			continue
+		}
+		others = append(others, peer)
+	}
+
+	for _, metric := range peerPercentileMetrics {
+		// Prefer the peer table row of the stock so both sides use the same units
+		subject, ok := parsePeerNumber(own[metric.peerColumn])
+		if !ok && metric.stockField != "" {
+			subject, ok = parsePeerNumber(stock[metric.stockField])
+		}
+		if !ok {
+			continue
+		}
+
+		var values []float64
+		for _, peer := range others {
+			if value, ok := parsePeerNumber(peer[metric.peerColumn]); ok {
+				values = append(values, value)
+			}
+		}
+		if len(values) < minPercentilePeers {
+			continue
+		}
+		percentiles[metric.key] = Round(PercentileRank(subject, values))
+	}
+	return percentiles
+}
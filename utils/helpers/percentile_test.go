@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestPercentileRank(t *testing.T) {
+	tests := []struct {
+		subject  float64
+		values   []float64
+		expected float64
+	}{
+		{50, []float64{10, 20, 30, 40}, 100},
+		{5, []float64{10, 20, 30, 40}, 0},
+		{25, []float64{10, 20, 30, 40}, 50},
+		{20, []float64{10, 20, 20, 40}, 50},
+		{20, []float64{20, 20}, 50},
+	}
+	for _, test := range tests {
+		if result := PercentileRank(test.subject, test.values); result != test.expected {
+			t.Errorf("PercentileRank(%v, %v): expected %v, got %v", test.subject, test.values, test.expected, result)
+		}
+	}
+}
+
+func TestPeerPercentiles(t *testing.T) {
+	stock := map[string]interface{}{
+		"name":          "Example Ltd",
+		"stockPE":       "99",
+		"dividendYield": "1.5",
+		"peers": primitive.A{
+			bson.M{"name": "Example", "pe": "15", "market_cap": "2,000", "roce": "20", "np_qtr": "50", "sales_qtr": ""},
+			bson.M{"name": "Alpha Ltd", "pe": "10", "market_cap": "1,000", "div_yield": "1.0", "roce": "10", "np_qtr": "10"},
+			bson.M{"name": "Beta Ltd", "pe": "20", "market_cap": "3,000", "div_yield": "2.0", "roce": "20", "np_qtr": "60"},
+			bson.M{"name": "Gamma Ltd", "pe": "30", "market_cap": "4,000", "div_yield": "", "roce": "30", "np_qtr": ""},
+			bson.M{"company_count": "4", "pe": "17.5"},
+		},
+	}
+
+	expected := map[string]float64{
+		"pe":            33.33, // the peer table PE is used over the stock field
+		"marketCap":     33.33,
+		"dividendYield": 50, // from the stock field, a blank peer is skipped
+		"roce":          50, // tied with Beta counts half
+		// quarterlyProfit only has two comparable peers
+		"quarterlyProfit": 50,
+	}
+	if result := PeerPercentiles(stock); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestPeerPercentiles_SmallPeerSet(t *testing.T) {
+	scraped := map[string]interface{}{
+		"name":    "Example Ltd",
+		"stockPE": "15",
+		"peers": []map[string]string{
+			{"name": "Example Ltd", "pe": "15"},
+			{"name": "Alpha Ltd", "pe": "10"},
+			{"company_count": "2", "pe": "12.5"},
+		},
+	}
+	if result := PeerPercentiles(scraped); len(result) != 0 {
+		t.Errorf("Expected no percentiles against a single peer, got %v", result)
+	}
+	if result := PeerPercentiles(map[string]interface{}{"name": "Example Ltd"}); len(result) != 0 {
+		t.Errorf("Expected no percentiles without peers, got %v", result)
+	}
+}
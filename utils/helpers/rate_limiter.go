@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces events by a minimum interval, whichever goroutine waits for them. The zero
+// value is ready to use.
+type RateLimiter struct {
+	mu sync.Mutex
+	// next is the earliest time the next event may happen
+	next time.Time
+}
+
+// Wait blocks until an event may happen, reserving its time so concurrent callers queue up interval
+// apart. It returns early with the context's error when it is done, the reserved time is then lost.
+func (l *RateLimiter) Wait(ctx context.Context, interval time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := now
+	if l.next.After(now) {
+		slot = l.next
+	}
+	l.next = slot.Add(interval)
+	l.mu.Unlock()
+
+	wait := slot.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
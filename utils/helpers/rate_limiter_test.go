@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var limiter RateLimiter
+	const interval = 20 * time.Millisecond
+
+	// Concurrent callers are spaced interval apart, the first one goes at once
+	start := time.Now()
+	var mu sync.Mutex
+	var times []time.Duration
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.Wait(context.Background(), interval); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			mu.Lock()
+			times = append(times, time.Since(start))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(times) != 3 || times[0] >= interval || times[2] < 2*interval {
+		t.Errorf("Expected the events %v apart, got %v", interval, times)
+	}
+
+	// A done context returns before the reserved time
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("Expected the context's error, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"strings"
+)
+
+// ratioRows are the rows of screener's ratios table kept as numeric series, keyed by their stored name
+var ratioRows = []struct{ key, label string }{
+	{"debtorDays", "Debtor Days"},
+	{"inventoryDays", "Inventory Days"},
+	{"daysPayable", "Days Payable"},
+	{"cashConversionCycle", "Cash Conversion Cycle"},
+	{"workingCapitalDays", "Working Capital Days"},
+	{"roce", "ROCE %"},
+}
+
+// RatioSeries is the numeric view of the ratios table, one value per period with nil for blank or
+// unparseable cells. Rows the table doesn't have (banks and NBFCs have no working capital rows)
+// are left out of Series.
+type RatioSeries struct {
+	Periods []string              `json:"periods" bson:"periods"`
+	Series  map[string][]*float64 `json:"series" bson:"series"`
+}
+
+// RatioTrend is the change of a ratio between its first and latest known period
+type RatioTrend struct {
+	Trend  string  `json:"trend" bson:"trend"`
+	First  float64 `json:"first" bson:"first"`
+	Latest float64 `json:"latest" bson:"latest"`
+	Change float64 `json:"change" bson:"change"`
+}
+
+// WorkingCapitalWeight returns the weight of the cash conversion cycle component in the final score (WORKING_CAPITAL_WEIGHT)
+func WorkingCapitalWeight() float64 {
+	return config.Get().WorkingCapitalWeight
+}
+
+// ParseRatioSeries extracts the important rows of the stored ratios table and its headers
+func ParseRatioSeries(ratios interface{}, headers interface{}) RatioSeries {
+	series := RatioSeries{Periods: []string{}, Series: map[string][]*float64{}}
+	if periods, ok := toArray(headers); ok {
+		for _, period := range periods {
+			label, _ := period.(string)
+			series.Periods = append(series.Periods, label)
+		}
+	}
+
+	rows, ok := toTableRows(ratios)
+	if !ok {
+		return series
+	}
+	for _, ratio := range ratioRows {
+		row, ok := findTableRow(rows, ratio.label)
+		if !ok {
+			continue
+		}
+		values := make([]*float64, len(row.Values))
+		for i, raw := range row.Values {
+			raw = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(raw), "%"))
+			if raw == "" {
+				continue
+			}
+			if value, err := ParseNumber(raw, CurrentNumberFormat()); err == nil {
+				values[i] = &value
+			}
+		}
+		series.Series[ratio.key] = values
+	}
+	return series
+}
+
+// AnalyzeWorkingCapital compares the first and latest known cash conversion cycle, a decreasing
+// cycle means the company turns its working capital into cash faster
+func AnalyzeWorkingCapital(series RatioSeries) RatioTrend {
+	var known []float64
+	for _, value := range series.Series["cashConversionCycle"] {
+		if value != nil {
+			known = append(known, *value)
+		}
+	}
+	if len(known) < 2 {
+		return RatioTrend{Trend: TrendUnavailable}
+	}
+
+	first, latest := known[0], known[len(known)-1]
+	trend := RatioTrend{First: Round(first), Latest: Round(latest), Change: Round(latest - first), Trend: TrendStable}
+	if latest > first {
+		trend.Trend = TrendIncreasing
+	} else if latest < first {
+		trend.Trend = TrendDecreasing
+	}
+	return trend
+}
+
+// WorkingCapitalScore scores the cash conversion cycle trend before weighting
+func WorkingCapitalScore(trend RatioTrend) float64 {
+	return workingCapitalScore(trend, nil)
+}
+
+// workingCapitalScore rewards an improving (decreasing) cash conversion cycle and penalizes a worsening one
+func workingCapitalScore(trend RatioTrend, explanation *scoreExplanation) float64 {
+	switch trend.Trend {
+	case TrendDecreasing:
+		explanation.add(5, "Cash conversion cycle improving")
+		return 5
+	case TrendIncreasing:
+		explanation.add(-5, "Cash conversion cycle worsening")
+		return -5
+	}
+	return 0
+}
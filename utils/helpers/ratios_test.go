@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/config"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bankRatiosFixture follows the ratios table of a bank, which only has the ROE row
+const bankRatiosFixture = `<section id="ratios"><div data-result-table><table class="data-table">
+<thead><tr><th class="text"></th><th>Mar 2022</th><th>Mar 2023</th><th>Mar 2024</th></tr></thead>
+<tbody><tr><td class="text">ROE %</td><td>15%</td><td>17%</td><td>17%</td></tr></tbody>
+</table></div></section>`
+
+func TestParseRatioSeries_Bank(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(bankRatiosFixture))
+	if err != nil {
+		t.Fatalf("Error parsing fixture: %v", err)
+	}
+	section := doc.Find("section#ratios")
+	series := ParseRatioSeries(ParseTableRows(section, "div[data-result-table]"), ParseTableHeaders(section, "div[data-result-table]"))
+	if len(series.Periods) != 3 || len(series.Series) != 0 {
+		t.Errorf("Expected the periods without any working capital series, got %+v", series)
+	}
+	if trend := AnalyzeWorkingCapital(series); trend.Trend != TrendUnavailable || WorkingCapitalScore(trend) != 0 {
+		t.Errorf("Expected an unavailable, unscored, trend, got %+v", trend)
+	}
+}
+
+func TestParseRatioSeries_Stored(t *testing.T) {
+	// Stored documents decode the table rows as primitive types
+	ratios := primitive.A{
+		primitive.M{"label": "Cash Conversion Cycle", "values": primitive.A{"40", "", "n/a", "1,052"}},
+		primitive.M{"label": "ROCE %", "values": primitive.A{"12%", "14%", "15%", "18%"}},
+	}
+	series := ParseRatioSeries(ratios, primitive.A{"Mar 2021", "Mar 2022", "Mar 2023", "Mar 2024"})
+
+	cycle := series.Series["cashConversionCycle"]
+	if len(cycle) != 4 || *cycle[0] != 40 || cycle[1] != nil || cycle[2] != nil || *cycle[3] != 1052 {
+		t.Fatalf("Unexpected cash conversion cycle: %+v", cycle)
+	}
+	if roce := series.Series["roce"]; *roce[3] != 18 {
+		t.Errorf("Expected the percent sign to be dropped, got %+v", roce)
+	}
+
+	trend := AnalyzeWorkingCapital(series)
+	if trend.Trend != TrendIncreasing || trend.First != 40 || trend.Latest != 1052 || WorkingCapitalScore(trend) != -5 {
+		t.Errorf("Expected a worsening cash conversion cycle, got %+v", trend)
+	}
+
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"WORKING_CAPITAL_WEIGHT": "0.1"}))
+	_, reasons := RateStockExplained(map[string]interface{}{"name": "Example Ltd", "ratios": ratios, "ratiosHeaders": series.Periods})
+	if expected := []string{"Cash conversion cycle worsening: -0.50"}; !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("Expected %v, got %v", expected, reasons)
+	}
+}
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"stockbackend/config"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Mongo states reported by the readiness check as they are, other ping errors are only logged
+var (
+	ErrMongoConnecting    = errors.New("connecting to MongoDB")
+	ErrMongoNotConfigured = errors.New("MongoDB client not configured")
+)
+
+// ReadinessReport tells whether the API can serve traffic, with the outcome of every check
+type ReadinessReport struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// MissingRequiredConfig lists the unset settings the API can't serve requests without
+func MissingRequiredConfig(cfg *config.Config) []string {
+	missing := []string{}
+	for _, setting := range []struct{ key, value string }{
+		{"MONGO_URI", cfg.MongoURI},
+		{"DATABASE", cfg.Database},
+		{"COLLECTION", cfg.Collection},
+		{"COMPANY_URL", cfg.CompanyURL},
+	} {
+		if setting.value == "" {
+			missing = append(missing, setting.key)
+		}
+	}
+	return missing
+}
+
+// CheckReadiness reports ready when the required config is present and ping (e.g. a Mongo ping)
+// succeeds. A failed ping is reported as "unreachable": the driver's errors name the hosts of the
+// URI, so they are logged rather than returned to an unauthenticated probe.
+func CheckReadiness(ctx context.Context, cfg *config.Config, ping func(context.Context) error) ReadinessReport {
+	report := ReadinessReport{Ready: true, Checks: map[string]string{"config": "ok", "mongo": "ok"}}
+	if missing := MissingRequiredConfig(cfg); len(missing) > 0 {
+		report.Ready = false
+		report.Checks["config"] = "missing " + strings.Join(missing, ", ")
+	}
+	if err := ping(ctx); err != nil {
+		report.Ready = false
+		report.Checks["mongo"] = "unreachable"
+		if errors.Is(err, ErrMongoConnecting) || errors.Is(err, ErrMongoNotConfigured) {
+			report.Checks["mongo"] = err.Error()
+		} else {
+			Logger(ctx).Warn("MongoDB ping failed", zap.Error(err))
+		}
+	}
+	return report
+}
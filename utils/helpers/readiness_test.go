@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"context"
+	"stockbackend/config"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func readyConfig() *config.Config {
+	return config.FromMap(map[string]string{
+		"MONGO_URI":   "mongodb://127.0.0.1:1",
+		"DATABASE":    "stocks",
+		"COLLECTION":  "companies",
+		"COMPANY_URL": "https://example.com",
+	})
+}
+
+func TestCheckReadiness_MongoUp(t *testing.T) {
+	report := CheckReadiness(context.Background(), readyConfig(), func(context.Context) error { return nil })
+	if !report.Ready || report.Checks["mongo"] != "ok" || report.Checks["config"] != "ok" {
+		t.Errorf("Expected ready, got %+v", report)
+	}
+}
+
+func TestCheckReadiness_MongoDown(t *testing.T) {
+	cfg := readyConfig()
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(cfg.MongoURI).SetServerSelectionTimeout(200*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Error creating the client: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	report := CheckReadiness(context.Background(), cfg, func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+	// The driver's error names the hosts of the URI, it is only logged
+	if report.Ready || report.Checks["mongo"] != "unreachable" {
+		t.Errorf("Expected not ready with Mongo unreachable, got %+v", report)
+	}
+}
+
+func TestCheckReadiness_MongoStates(t *testing.T) {
+	for _, err := range []error{ErrMongoConnecting, ErrMongoNotConfigured} {
+		report := CheckReadiness(context.Background(), readyConfig(), func(context.Context) error { return err })
+		if report.Ready || report.Checks["mongo"] != err.Error() {
+			t.Errorf("Expected %q to be reported, got %+v", err, report)
+		}
+	}
+}
+
+func TestCheckReadiness_MissingConfig(t *testing.T) {
+	cfg := readyConfig()
+	cfg.Database = ""
+	cfg.CompanyURL = ""
+
+	report := CheckReadiness(context.Background(), cfg, func(context.Context) error { return nil })
+	if report.Ready || report.Checks["config"] != "missing DATABASE, COMPANY_URL" {
+		t.Errorf("Expected the missing settings to be reported, got %+v", report)
+	}
+}
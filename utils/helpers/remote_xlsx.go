@@ -0,0 +1,211 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrRemoteInvalidURL     = errors.New("invalid spreadsheet URL, expected an absolute http or https URL")
+	ErrRemoteForbiddenHost  = errors.New("the spreadsheet URL points to a private or local address")
+	ErrRemoteUnreachable    = errors.New("the spreadsheet URL could not be fetched")
+	ErrRemoteNotSpreadsheet = errors.New("the URL doesn't point to a spreadsheet")
+	ErrRemoteTooLarge       = errors.New("the spreadsheet exceeds the maximum allowed size")
+)
+
+// maxRemoteRedirects is how many redirects a spreadsheet URL may follow
+const maxRemoteRedirects = 5
+
+// spreadsheetContentTypes are the content types served for xlsx files. Generic binary types are
+// accepted as well when the file name has a spreadsheet extension, some servers send nothing better.
+var (
+	spreadsheetContentTypes = map[string]bool{
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": true,
+		"application/vnd.ms-excel.sheet.macroenabled.12":                   true,
+		"application/vnd.ms-excel":                                          true,
+	}
+	genericContentTypes = map[string]bool{
+		"":                         true,
+		"application/octet-stream": true,
+		"application/zip":          true,
+		"binary/octet-stream":      true,
+	}
+	spreadsheetExtensions = map[string]bool{".xlsx": true, ".xlsm": true}
+)
+
+// xlsxSignature starts every xlsx file (a zip container), protected ones are OLE files (oleSignature)
+var xlsxSignature = []byte("PK\x03\x04")
+
+// RemoteXLSXFetcher downloads spreadsheets published at a URL
+type RemoteXLSXFetcher struct {
+	Client  *http.Client
+	MaxSize int64
+}
+
+// NewRemoteXLSXFetcher returns a fetcher whose connections may only reach public addresses, unless
+// allowPrivate is set. The address is checked when dialing, after DNS resolution, so neither a
+// redirect nor a host name resolving to a private address gets around it.
+func NewRemoteXLSXFetcher(timeout time.Duration, maxSize int64, allowPrivate bool) *RemoteXLSXFetcher {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return fmt.Errorf("%w: %s", ErrRemoteForbiddenHost, host)
+			}
+			return nil
+		}
+	}
+	return &RemoteXLSXFetcher{
+		Client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRemoteRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRemoteRedirects)
+				}
+				return checkRemoteURL(req.URL)
+			},
+		},
+		MaxSize: maxSize,
+	}
+}
+
+// nonPublicPrefixes are the special purpose ranges the net.IP checks let through: "this network",
+// carrier-grade NAT (where some cloud metadata endpoints sit, e.g. 100.100.100.200), the IETF
+// protocol assignments, benchmarking and the reserved class E (broadcast included)
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// IsPublicIP reports whether the address is routable on the internet, loopback, private, link local
+// (cloud metadata endpoints), unspecified addresses and the nonPublicPrefixes are not
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	// An IPv4-mapped IPv6 address reaches the IPv4 one
+	addr = addr.Unmap()
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkRemoteURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return ErrRemoteInvalidURL
+	}
+	return nil
+}
+
+// Fetch downloads the spreadsheet at rawURL into w and returns its file name, taken from the
+// Content-Disposition header or the URL path. The response must look like a spreadsheet by its
+// content type or extension and start like an xlsx file, and may not exceed MaxSize bytes.
+func (f *RemoteXLSXFetcher) Fetch(ctx context.Context, rawURL string, w io.Writer) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", ErrRemoteInvalidURL
+	}
+	if err := checkRemoteURL(u); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", ErrRemoteInvalidURL
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrRemoteForbiddenHost) {
+			return "", ErrRemoteForbiddenHost
+		}
+		if errors.Is(err, ErrRemoteInvalidURL) {
+			return "", ErrRemoteInvalidURL
+		}
+		return "", fmt.Errorf("%w: %v", ErrRemoteUnreachable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: status code %d", ErrRemoteUnreachable, resp.StatusCode)
+	}
+	if f.MaxSize > 0 && resp.ContentLength > f.MaxSize {
+		return "", ErrRemoteTooLarge
+	}
+
+	filename := remoteFilename(resp)
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	contentType = strings.ToLower(contentType)
+	if !spreadsheetContentTypes[contentType] &&
+		!(genericContentTypes[contentType] && spreadsheetExtensions[strings.ToLower(path.Ext(filename))]) {
+		return "", fmt.Errorf("%w: content type %q", ErrRemoteNotSpreadsheet, resp.Header.Get("Content-Type"))
+	}
+
+	body := io.Reader(resp.Body)
+	if f.MaxSize > 0 {
+		body = io.LimitReader(resp.Body, f.MaxSize+1)
+	}
+	head := make([]byte, len(oleSignature))
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("%w: %v", ErrRemoteUnreachable, err)
+	}
+	head = head[:n]
+	if !bytes.HasPrefix(head, xlsxSignature) && !bytes.HasPrefix(head, oleSignature) {
+		return "", fmt.Errorf("%w: the content isn't an xlsx file", ErrRemoteNotSpreadsheet)
+	}
+
+	written, err := io.Copy(w, io.MultiReader(bytes.NewReader(head), body))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrRemoteUnreachable, err)
+	}
+	if f.MaxSize > 0 && written > f.MaxSize {
+		return "", ErrRemoteTooLarge
+	}
+	return filename, nil
+}
+
+// remoteFilename is the file name of the download, "download.xlsx" when neither the
+// Content-Disposition header nor the URL path has one
+func remoteFilename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(strings.ReplaceAll(params["filename"], "\\", "/")); isFileName(name) {
+			return name
+		}
+	}
+	if name := path.Base(resp.Request.URL.Path); isFileName(name) {
+		return name
+	}
+	return "download.xlsx"
+}
+
+// isFileName reports whether the base of a path names a file, not the directory itself or its parent
+func isFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && name != "/"
+}
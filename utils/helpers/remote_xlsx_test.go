@@ -0,0 +1,159 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func remoteWorkbook(t *testing.T) []byte {
+	workbook := excelize.NewFile()
+	workbook.SetCellValue("Sheet1", "A1", "Name of the Instrument")
+	var buf bytes.Buffer
+	if err := workbook.Write(&buf); err != nil {
+		t.Fatalf("Error writing fixture: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func remoteFileServer(t *testing.T, workbook []byte) *httptest.Server {
+	mux := http.NewServeMux()
+	// Served with the content type of its extension
+	mux.HandleFunc("/holdings.xlsx", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "holdings.xlsx", time.Time{}, bytes.NewReader(workbook))
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="portfolio-sep.xlsx"`)
+		w.Write(workbook)
+	})
+	mux.HandleFunc("/report.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>Monthly portfolio</html>"))
+	})
+	mux.HandleFunc("/renamed.xlsx", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("Name,Quantity\n"))
+	})
+	mux.HandleFunc("/parent", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", `attachment; filename=".."`)
+		w.Write(workbook)
+	})
+	mux.HandleFunc("/nested/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Write(workbook)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/holdings.xlsx", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRemoteXLSXFetcher_Fetch(t *testing.T) {
+	workbook := remoteWorkbook(t)
+	server := remoteFileServer(t, workbook)
+	fetcher := NewRemoteXLSXFetcher(5*time.Second, 1<<20, true)
+
+	for path, name := range map[string]string{
+		"/holdings.xlsx": "holdings.xlsx",
+		"/download":      "portfolio-sep.xlsx",
+		"/redirect":      "holdings.xlsx",
+		// A parent directory name would move the download out of its directory, the URL names it
+		// instead or, when the URL ends in one too, the default name
+		"/parent":        "parent",
+		"/nested/%2E%2E": "download.xlsx",
+	} {
+		var buf bytes.Buffer
+		filename, err := fetcher.Fetch(context.Background(), server.URL+path, &buf)
+		if err != nil {
+			t.Errorf("%s: expected the workbook, got %v", path, err)
+			continue
+		}
+		if filename != name || !bytes.Equal(buf.Bytes(), workbook) {
+			t.Errorf("%s: expected %s with the workbook, got %s with %d bytes", path, name, filename, buf.Len())
+		}
+		if sheets, err := ReadXLSXSheets(&buf); err != nil || sheets[0].Rows[0][0] != "Name of the Instrument" {
+			t.Errorf("%s: expected a readable workbook, got %v", path, err)
+		}
+	}
+}
+
+func TestRemoteXLSXFetcher_Errors(t *testing.T) {
+	workbook := remoteWorkbook(t)
+	server := remoteFileServer(t, workbook)
+	fetcher := NewRemoteXLSXFetcher(5*time.Second, 1<<20, true)
+
+	tests := []struct {
+		name     string
+		fetcher  *RemoteXLSXFetcher
+		url      string
+		expected error
+	}{
+		{"not http", fetcher, "file:///etc/passwd", ErrRemoteInvalidURL},
+		{"relative", fetcher, "/holdings.xlsx", ErrRemoteInvalidURL},
+		{"missing", fetcher, server.URL + "/missing.xlsx", ErrRemoteUnreachable},
+		{"html page", fetcher, server.URL + "/report.html", ErrRemoteNotSpreadsheet},
+		{"not a workbook", fetcher, server.URL + "/renamed.xlsx", ErrRemoteNotSpreadsheet},
+		{"too large", NewRemoteXLSXFetcher(5*time.Second, int64(len(workbook)-1), true), server.URL + "/holdings.xlsx", ErrRemoteTooLarge},
+		{"loopback", NewRemoteXLSXFetcher(5*time.Second, 1<<20, false), server.URL + "/holdings.xlsx", ErrRemoteForbiddenHost},
+		{"metadata endpoint", NewRemoteXLSXFetcher(time.Second, 1<<20, false), "http://169.254.169.254/latest/meta-data", ErrRemoteForbiddenHost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := tt.fetcher.Fetch(context.Background(), tt.url, &buf); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	// The server is closed, nothing listens on its address any more
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	if _, err := fetcher.Fetch(context.Background(), closed.URL+"/holdings.xlsx", &bytes.Buffer{}); !errors.Is(err, ErrRemoteUnreachable) {
+		t.Errorf("Expected ErrRemoteUnreachable for a closed server, got %v", err)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for address, public := range map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.0.0.8":        false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		// Special purpose ranges the net.IP checks don't cover
+		"0.1.2.3":           false,
+		"100.64.0.1":        false,
+		"100.100.100.200":   false,
+		"100.127.255.254":   false,
+		"192.0.0.170":       false,
+		"198.18.0.1":        false,
+		"198.19.255.255":    false,
+		"240.0.0.1":         false,
+		"255.255.255.255":   false,
+		"::ffff:100.64.0.1": false,
+		// Just outside them
+		"100.128.0.1": true,
+		"198.20.0.1":  true,
+		"192.0.2.1":   true,
+	} {
+		if IsPublicIP(net.ParseIP(address)) != public {
+			t.Errorf("Expected IsPublicIP(%s) to be %v", address, public)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is the response header carrying the correlation ID of a request
+const RequestIDHeader = "X-Request-Id"
+
+// Keys of the correlation ID and the request logger on a gin context, which only resolves string keys
+const (
+	RequestIDKey = "requestId"
+	LoggerKey    = "logger"
+)
+
+type requestIDContextKey struct{}
+type loggerContextKey struct{}
+
+// WithRequestLogger attaches the correlation ID of a request and the logger carrying it to a context
+func WithRequestLogger(ctx context.Context, id string, logger *zap.Logger) context.Context {
+	ctx = context.WithValue(ctx, requestIDContextKey{}, id)
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// Logger returns the request logger of a request context, or of a gin context, and the global logger
+// outside of a request
+func Logger(ctx context.Context) *zap.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerContextKey{}).(*zap.Logger); ok {
+			return logger
+		}
+		if logger, ok := ctx.Value(LoggerKey).(*zap.Logger); ok {
+			return logger
+		}
+	}
+	return zap.L()
+}
+
+// RequestID returns the correlation ID of a request context, or of a gin context, empty outside of a request
+func RequestID(ctx context.Context) string {
+	if ctx != nil {
+		if id, ok := ctx.Value(requestIDContextKey{}).(string); ok {
+			return id
+		}
+		if id, ok := ctx.Value(RequestIDKey).(string); ok {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Backoff bounds RetryWithBackoff: at most Attempts tries, waiting Initial after the first failure
+// and twice as long after every further one, up to Max. Retryable, when set, tells the errors worth
+// another try from those returned at once (e.g. http_client.IsRetryable).
+type Backoff struct {
+	Attempts  int
+	Initial   time.Duration
+	Max       time.Duration
+	Retryable func(error) bool
+}
+
+// wait is the pause after the failed attempt (1-based)
+func (b Backoff) wait(attempt int) time.Duration {
+	wait := b.Initial
+	for i := 1; i < attempt && wait < b.Max; i++ {
+		wait *= 2
+	}
+	return min(wait, b.Max)
+}
+
+// RetryWithBackoff runs op until it succeeds, fails with an error the backoff doesn't retry, the
+// attempts are exhausted or the context is done, returning the last error. onRetry, when set, is called before every pause with the failed attempt.
+func RetryWithBackoff(ctx context.Context, backoff Backoff, op func(context.Context) error, onRetry func(attempt int, wait time.Duration, err error)) error {
+	attempts := max(backoff.Attempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = op(ctx); err == nil {
+			return nil
+		}
+		if backoff.Retryable != nil && !backoff.Retryable(err) {
+			return err
+		}
+		if attempt == attempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+		}
+
+		wait := backoff.wait(attempt)
+		if onRetry != nil {
+			onRetry(attempt, wait, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// SucceedOnce runs a function until it succeeds once, e.g. creating indexes that fail while Mongo
+// is still unreachable. Unlike sync.Once a failed run is tried again by the next Do.
+type SucceedOnce struct {
+	mu   sync.Mutex
+	done bool
+}
+
+// Do runs f unless an earlier run succeeded, concurrent calls wait for the running one
+func (o *SucceedOnce) Do(f func() error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.done {
+		return nil
+	}
+	if err := f(); err != nil {
+		return err
+	}
+	o.done = true
+	return nil
+}
@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var errUnavailable = errors.New("server selection error: connection refused")
+
+func TestRetryWithBackoff_DelayedMongo(t *testing.T) {
+	// Mongo answers from the third ping on, like a database container starting after the API
+	pings := 0
+	ping := func(ctx context.Context) error {
+		pings++
+		if pings < 3 {
+			return errUnavailable
+		}
+		return nil
+	}
+
+	var waits []time.Duration
+	backoff := Backoff{Attempts: 5, Initial: time.Millisecond, Max: 3 * time.Millisecond}
+	err := RetryWithBackoff(context.Background(), backoff, ping, func(attempt int, wait time.Duration, err error) {
+		if !errors.Is(err, errUnavailable) {
+			t.Errorf("Expected the ping error, got %v", err)
+		}
+		waits = append(waits, wait)
+	})
+	if err != nil {
+		t.Fatalf("Expected the connection to succeed, got %v", err)
+	}
+	if pings != 3 {
+		t.Errorf("Expected 3 pings, got %d", pings)
+	}
+	if expected := []time.Duration{time.Millisecond, 2 * time.Millisecond}; !reflect.DeepEqual(waits, expected) {
+		t.Errorf("Expected waits %v, got %v", expected, waits)
+	}
+}
+
+func TestRetryWithBackoff_GivesUp(t *testing.T) {
+	pings := 0
+	backoff := Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Millisecond}
+	err := RetryWithBackoff(context.Background(), backoff, func(ctx context.Context) error {
+		pings++
+		return errUnavailable
+	}, nil)
+	if !errors.Is(err, errUnavailable) || pings != 3 {
+		t.Errorf("Expected to give up with the last error after 3 pings, got %v after %d", err, pings)
+	}
+
+	// A cancelled context stops the retries during the pause
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = RetryWithBackoff(ctx, Backoff{Attempts: 3, Initial: time.Hour, Max: time.Hour}, func(ctx context.Context) error {
+		return errUnavailable
+	}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRetryWithBackoff_NotRetryable(t *testing.T) {
+	errNotFound := errors.New("not found")
+	backoff := Backoff{Attempts: 5, Initial: time.Hour, Max: time.Hour, Retryable: func(err error) bool {
+		return !errors.Is(err, errNotFound)
+	}}
+
+	// An error the backoff doesn't retry is returned at once, without a pause
+	tries := 0
+	err := RetryWithBackoff(context.Background(), backoff, func(ctx context.Context) error {
+		tries++
+		return errNotFound
+	}, nil)
+	if err != errNotFound || tries != 1 {
+		t.Errorf("Expected the error after 1 try, got %v after %d", err, tries)
+	}
+
+	// Retryable errors are retried until one that isn't
+	backoff.Initial, backoff.Max = time.Millisecond, time.Millisecond
+	tries = 0
+	err = RetryWithBackoff(context.Background(), backoff, func(ctx context.Context) error {
+		tries++
+		if tries < 3 {
+			return errUnavailable
+		}
+		return errNotFound
+	}, nil)
+	if err != errNotFound || tries != 3 {
+		t.Errorf("Expected the error after 3 tries, got %v after %d", err, tries)
+	}
+}
+
+func TestBackoff_Wait(t *testing.T) {
+	backoff := Backoff{Attempts: 10, Initial: time.Second, Max: 30 * time.Second}
+	var waits []time.Duration
+	for attempt := 1; attempt <= 7; attempt++ {
+		waits = append(waits, backoff.wait(attempt))
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	if !reflect.DeepEqual(waits, expected) {
+		t.Errorf("Expected %v, got %v", expected, waits)
+	}
+}
+
+func TestSucceedOnce(t *testing.T) {
+	var once SucceedOnce
+	runs := 0
+	create := func() error {
+		runs++
+		if runs == 1 {
+			return errUnavailable
+		}
+		return nil
+	}
+
+	if err := once.Do(create); !errors.Is(err, errUnavailable) {
+		t.Errorf("Expected the first run to fail, got %v", err)
+	}
+	if err := once.Do(create); err != nil {
+		t.Errorf("Expected the second run to succeed, got %v", err)
+	}
+	if err := once.Do(create); err != nil || runs != 2 {
+		t.Errorf("Expected no run after a success, got %d runs (%v)", runs, err)
+	}
+}
@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"stockbackend/config"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// RevalidateMode is how a read of stale stored data revalidates it
+type RevalidateMode string
+
+const (
+	// RevalidateAsync returns the stale data at once and scrapes it again in the background
+	RevalidateAsync RevalidateMode = "async"
+	// RevalidateSync scrapes the stale data again before returning it
+	RevalidateSync RevalidateMode = "sync"
+	// RevalidateNever returns the stale data as it is
+	RevalidateNever RevalidateMode = "never"
+)
+
+// ParseRevalidateMode validates the ?revalidate= param of company reads, defaulting to READ_REVALIDATE
+func ParseRevalidateMode(value string) (RevalidateMode, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		value = strings.ToLower(strings.TrimSpace(config.Get().ReadRevalidate))
+	}
+	switch mode := RevalidateMode(value); mode {
+	case RevalidateAsync, RevalidateSync, RevalidateNever:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid revalidate %q, expected async, sync or never", value)
+}
+
+// Revalidate refreshes the stale data of a key in the mode and reports whether the data read is
+// still stale. refresh runs holding the key's lock in locks, the one writes to the key are
+// serialized on, so it must not take it again. An async revalidation runs in the background with
+// a context that outlives the request (so ctx mustn't be a gin context, which is reused), and is
+// skipped while the key is locked (a scrape or another revalidation of it is already running) or
+// while every upload slot is taken, it holds one like an upload. A sync one waits for the lock,
+// refresh should then check whether the data is still stale. A failed sync refresh returns its error with the data still stale.
+func Revalidate(ctx context.Context, locks *KeyedMutex, key string, mode RevalidateMode, refresh func(context.Context) error) (bool, error) {
+	switch mode {
+	case RevalidateSync:
+		unlock := locks.Lock(key)
+		defer unlock()
+		if err := refresh(ctx); err != nil {
+			return true, err
+		}
+		return false, nil
+	case RevalidateAsync:
+		unlock, ok := locks.TryLock(key)
+		if !ok {
+			return true, nil
+		}
+		slots := Uploads()
+		if err := slots.Acquire(ctx, 0); err != nil {
+			unlock()
+			return true, nil
+		}
+		background := context.WithoutCancel(ctx)
+		go func() {
+			defer unlock()
+			defer slots.Release()
+			if err := refresh(background); err != nil {
+				Logger(background).Error("Error revalidating stale data", zap.String("key", key), zap.Error(err))
+			}
+		}()
+	}
+	return true, nil
+}
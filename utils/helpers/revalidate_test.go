@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"stockbackend/config"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRevalidateMode(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"READ_REVALIDATE": "never"}))
+
+	tests := map[string]RevalidateMode{"": RevalidateNever, "async": RevalidateAsync, " SYNC ": RevalidateSync, "never": RevalidateNever}
+	for value, expected := range tests {
+		if mode, err := ParseRevalidateMode(value); err != nil || mode != expected {
+			t.Errorf("ParseRevalidateMode(%q): expected %v, got %v (%v)", value, expected, mode, err)
+		}
+	}
+	if _, err := ParseRevalidateMode("later"); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestRevalidate_Never(t *testing.T) {
+	var locks KeyedMutex
+	stale, err := Revalidate(context.Background(), &locks, "infosys", RevalidateNever, func(context.Context) error {
+		t.Errorf("Expected no refresh")
+		return nil
+	})
+	if !stale || err != nil {
+		t.Errorf("Expected the data to stay stale, got %v (%v)", stale, err)
+	}
+}
+
+func TestRevalidate_Sync(t *testing.T) {
+	var locks KeyedMutex
+	stale, err := Revalidate(context.Background(), &locks, "infosys", RevalidateSync, func(context.Context) error {
+		if _, ok := locks.TryLock("infosys"); ok {
+			t.Errorf("Expected the refresh to hold the key's lock")
+		}
+		return nil
+	})
+	if stale || err != nil {
+		t.Errorf("Expected fresh data, got %v (%v)", stale, err)
+	}
+
+	scrapeErr := errors.New("scrape failed")
+	stale, err = Revalidate(context.Background(), &locks, "infosys", RevalidateSync, func(context.Context) error { return scrapeErr })
+	if !stale || !errors.Is(err, scrapeErr) {
+		t.Errorf("Expected a failed refresh to leave the data stale, got %v (%v)", stale, err)
+	}
+}
+
+func TestRevalidate_Async(t *testing.T) {
+	var locks KeyedMutex
+	var refreshes atomic.Int32
+	release, finished := make(chan struct{}), make(chan struct{})
+	refresh := func(ctx context.Context) error {
+		refreshes.Add(1)
+		<-release
+		if ctx.Err() != nil {
+			t.Errorf("Expected the revalidation to outlive the request, got %v", ctx.Err())
+		}
+		close(finished)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	stale, err := Revalidate(ctx, &locks, "infosys", RevalidateAsync, refresh)
+	if !stale || err != nil || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("Expected the stale data at once, got %v (%v) after %v", stale, err, time.Since(start))
+	}
+	// The request ending doesn't cancel the revalidation
+	cancel()
+
+	// Reads while it runs don't start another one, nor do they wait for it
+	for i := 0; i < 3; i++ {
+		if stale, _ := Revalidate(context.Background(), &locks, "infosys", RevalidateAsync, refresh); !stale {
+			t.Errorf("Expected the data to stay stale while revalidating")
+		}
+	}
+
+	close(release)
+	<-finished
+	// The lock is released once the refresh returns
+	for deadline := time.Now().Add(time.Second); ; {
+		unlock, ok := locks.TryLock("infosys")
+		if ok {
+			unlock()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the revalidation to release the lock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if refreshes.Load() != 1 {
+		t.Errorf("Expected a single revalidation, got %d", refreshes.Load())
+	}
+}
+
+func TestRevalidate_AsyncTakesUploadSlot(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"MAX_CONCURRENT_UPLOADS": "1"}))
+	var locks KeyedMutex
+
+	// Every slot is taken by an upload
+	slots := Uploads()
+	if err := slots.Acquire(context.Background(), 0); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stale, err := Revalidate(context.Background(), &locks, "infosys", RevalidateAsync, func(context.Context) error {
+		t.Errorf("Expected no revalidation without a slot")
+		return nil
+	})
+	if !stale || err != nil {
+		t.Errorf("Expected the stale data, got %v (%v)", stale, err)
+	}
+	if unlock, ok := locks.TryLock("infosys"); !ok {
+		t.Errorf("Expected the skipped revalidation to release the lock")
+	} else {
+		unlock()
+	}
+	slots.Release()
+
+	// The revalidation holds the slot while it runs
+	release, finished := make(chan struct{}), make(chan struct{})
+	Revalidate(context.Background(), &locks, "infosys", RevalidateAsync, func(context.Context) error {
+		<-release
+		return nil
+	})
+	if stats := slots.Stats(); stats.Active != 1 {
+		t.Errorf("Expected the revalidation to hold a slot, got %+v", stats)
+	}
+	go func() {
+		close(release)
+		for slots.Stats().Active != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the revalidation to release its slot")
+	}
+}
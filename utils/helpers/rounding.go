@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"math"
+	"stockbackend/config"
+	"strconv"
+)
+
+// roundingSignificantDigits is the precision a value is read with before rounding, enough for any
+// computed number and few enough to drop the noise of binary floats (1.005 is 1.00499999999999989)
+const roundingSignificantDigits = 15
+
+// round rounds the value half away from zero to the decimal places, rounding the decimal value it
+// stands for rather than its binary approximation. NaN, infinities and values too large to have
+// decimals are returned as they are, negative zero becomes zero.
+func round(value float64, places int) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+	scale := math.Pow10(places)
+	scaled := value * scale
+	if math.Abs(scaled) >= 1<<52 {
+		return value
+	}
+	// Strip the representation noise first, so 1.005 scales to 100.5 and not 100.49999999999999
+	if cleaned, err := strconv.ParseFloat(strconv.FormatFloat(scaled, 'g', roundingSignificantDigits, 64), 64); err == nil {
+		scaled = cleaned
+	}
+	rounded := math.Round(scaled) / scale
+	if rounded == 0 {
+		return 0
+	}
+	return rounded
+}
+
+// Round rounds a computed number to DECIMAL_PLACES. Every number computed for a response or a
+// stored document goes through it, so they share one precision and marshal without float noise.
+func Round(value float64) float64 {
+	return round(value, config.Get().DecimalPlaces)
+}
+
+// roundPtr rounds the value a pointer points to, nil stays nil
+func roundPtr(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	rounded := Round(*value)
+	return &rounded
+}
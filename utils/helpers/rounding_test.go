@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"encoding/json"
+	"math"
+	"stockbackend/config"
+	"testing"
+)
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		value    float64
+		places   int
+		expected float64
+	}{
+		{1.005, 2, 1.01}, // stored as 1.00499999999999989
+		{2.675, 2, 2.68},
+		{-1.005, 2, -1.01}, // half away from zero
+		{1.004999, 2, 1},
+		{0.125, 2, 0.13},
+		{33.333333, 2, 33.33},
+		{66.666666, 2, 66.67},
+		{12.5, 0, 13},
+		{-12.5, 0, -13},
+		{0.1 + 0.2, 2, 0.3},
+		{-0.001, 2, 0},
+		{123456.789, 1, 123456.8},
+		{1e300, 2, 1e300}, // too large to have decimals
+	}
+	for _, test := range tests {
+		if result := round(test.value, test.places); result != test.expected {
+			t.Errorf("round(%v, %d): expected %v, got %v", test.value, test.places, test.expected, result)
+		}
+	}
+
+	if result := round(-0.001, 2); math.Signbit(result) {
+		t.Errorf("Expected negative zero to become zero, got %v", result)
+	}
+	if result := round(math.NaN(), 2); !math.IsNaN(result) {
+		t.Errorf("Expected NaN to be kept, got %v", result)
+	}
+	if result := round(math.Inf(1), 2); !math.IsInf(result, 1) {
+		t.Errorf("Expected +Inf to be kept, got %v", result)
+	}
+}
+
+func TestRound_ConfiguredPlaces(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+
+	if result := Round(2.34567); result != 2.35 {
+		t.Errorf("Expected 2 decimal places by default, got %v", result)
+	}
+	config.Set(config.FromMap(map[string]string{"DECIMAL_PLACES": "1"}))
+	if result := Round(2.34567); result != 2.3 {
+		t.Errorf("Expected 1 decimal place, got %v", result)
+	}
+	config.Set(config.FromMap(map[string]string{"DECIMAL_PLACES": "0"}))
+	if result := Round(2.5); result != 3 {
+		t.Errorf("Expected no decimals, got %v", result)
+	}
+}
+
+func TestRound_MarshalsWithoutNoise(t *testing.T) {
+	// Summing percentages is how exposure totals are built
+	var total float64
+	for _, value := range []float64{10.1, 20.2, 30.3, 0.7} {
+		total += value
+	}
+	encoded, err := json.Marshal(map[string]float64{"total": Round(total), "percentile": Round(PercentileRank(2, []float64{1, 2, 3}))})
+	if err != nil {
+		t.Fatalf("Error marshalling: %v", err)
+	}
+	if string(encoded) != `{"percentile":50,"total":61.3}` {
+		t.Errorf("Expected rounded numbers, got %s", encoded)
+	}
+}
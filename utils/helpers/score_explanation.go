@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"stockbackend/config"
+	"strings"
+)
+
+type scoreReason struct {
+	text   string
+	points float64
+}
+
+// scoreExplanation collects the factors behind a rating. A nil explanation ignores every
+// reason, so the scoring internals can be used with or without one.
+type scoreExplanation struct {
+	reasons []scoreReason
+}
+
+func (e *scoreExplanation) add(points float64, text string) {
+	if e == nil || points == 0 {
+		return
+	}
+	for i := range e.reasons {
+		if e.reasons[i].text == text {
+			e.reasons[i].points += points
+			return
+		}
+	}
+	e.reasons = append(e.reasons, scoreReason{text: text, points: points})
+}
+
+// merge adds the reasons of a score component scaled by the weight of that component
+func (e *scoreExplanation) merge(other *scoreExplanation, weight float64) {
+	if e == nil || other == nil {
+		return
+	}
+	for _, reason := range other.reasons {
+		e.add(reason.points*weight, reason.text)
+	}
+}
+
+// lines formats the reasons as "<factor>: +x.xx" with DECIMAL_PLACES decimals, the dominant factors first
+func (e *scoreExplanation) lines() []string {
+	if e == nil {
+		return []string{}
+	}
+	reasons := append([]scoreReason(nil), e.reasons...)
+	sort.SliceStable(reasons, func(i, j int) bool {
+		return math.Abs(reasons[i].points) > math.Abs(reasons[j].points)
+	})
+
+	places := config.Get().DecimalPlaces
+	lines := []string{}
+	for _, reason := range reasons {
+		points := round(reason.points, places)
+		if points == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %+.*f", reason.text, places, points))
+	}
+	return lines
+}
+
+// displayMetricName strips the expandable row marker from a table label, e.g. "Net Profit +" becomes "Net Profit"
+func displayMetricName(metric string) string {
+	metric = strings.ReplaceAll(metric, "\u00a0", " ")
+	metric = strings.TrimSuffix(strings.TrimSpace(metric), "+")
+	return strings.Join(strings.Fields(metric), " ")
+}
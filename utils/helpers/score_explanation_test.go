@@ -0,0 +1,205 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/config"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestRateStockExplained(t *testing.T) {
+	stock := map[string]interface{}{
+		"name":    "Example Ltd",
+		"stockPE": "10",
+		"roce":    "20",
+		"peers": primitive.A{
+			bson.M{"pe": "20", "roce": "10"},
+			bson.M{"pe": "15", "roce": "15", PeerMedianMarker: "true"},
+		},
+		"quarterlyResults": quarterlyFixture(map[string][]string{
+			"Net Profit\u00a0+": {"16", "14", "12", "10"},
+		}),
+	}
+
+	score, reasons := RateStockExplained(stock)
+	if score != 13 {
+		t.Errorf("Expected 13, got %v", score)
+	}
+	if score != RateStock(stock) {
+		t.Errorf("Expected the explained score to match RateStock, got %v and %v", score, RateStock(stock))
+	}
+
+	expected := []string{
+		"PE below peers: +5.00",
+		"ROCE above peers: +5.00",
+		"PE below peer median: +2.50",
+		"ROCE above peer median: +2.50",
+		"Declining quarterly Net Profit trend: -2.00",
+	}
+	if !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("Expected %v, got %v", expected, reasons)
+	}
+}
+
+func TestRateStockExplained_PeersDisabled(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"FETCH_PEERS": "false"}))
+
+	stock := decodeFScoreRequest(t)
+	stock["name"] = "Example Ltd"
+	stock["stockPE"] = "10"
+	// Stored before peers were disabled, they are left out of the score
+	stock["peers"] = primitive.A{bson.M{"pe": "20"}, bson.M{"pe": "15", PeerMedianMarker: "true"}}
+	stock["quarterlyResults"] = quarterlyFixture(map[string][]string{
+		"Net Profit\u00a0+": {"16", "14", "12", "10"},
+	})
+
+	score, reasons := RateStockExplained(stock)
+	if score != 20.5 {
+		t.Errorf("Expected the F-Score to take the peer comparison's weight, got %v", score)
+	}
+	expected := []string{"F-Score of 9: +22.50", "Declining quarterly Net Profit trend: -2.00"}
+	if !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("Expected %v, got %v", expected, reasons)
+	}
+
+	// Without statements to compute the F-Score from, the trend carries both weights
+	for _, section := range []string{"profitLoss", "balanceSheet", "cashFlows"} {
+		delete(stock, section)
+	}
+	rating := RateStockDetailed(stock, nil)
+	if rating.Raw != -4.5 || !reflect.DeepEqual(rating.Reasons, []string{"Declining quarterly Net Profit trend: -4.50"}) {
+		t.Errorf("Expected the trend alone, got %v %v", rating.Raw, rating.Reasons)
+	}
+}
+
+func TestRateStockExplained_PeersUnavailable(t *testing.T) {
+	stock := decodeFScoreRequest(t)
+	stock["name"] = "Example Ltd"
+	stock["stockPE"] = "10"
+	// The page had no warehouse id, the empty peers aren't a stock no better than its peers
+	stock["peersUnavailable"] = true
+	stock["quarterlyResults"] = quarterlyFixture(map[string][]string{
+		"Net Profit\u00a0+": {"16", "14", "12", "10"},
+	})
+
+	score, reasons := RateStockExplained(stock)
+	if score != 20.5 || reasons[0] != "F-Score of 9: +22.50" {
+		t.Errorf("Expected the F-Score to take the peer comparison's weight, got %v %v", score, reasons)
+	}
+}
+
+func TestRateStock_ProsCons(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"PROS_CONS_WEIGHT": "0.1"}))
+
+	rated := func(pros, cons []string) (float64, []string) {
+		stock := map[string]interface{}{
+			"name":    "Example Ltd",
+			"stockPE": "10",
+			"peers":   primitive.A{bson.M{"pe": "20"}},
+			"pros":    pros,
+			"cons":    cons,
+		}
+		return RateStockExplained(stock)
+	}
+
+	base, _ := rated(nil, nil)
+	withPros, reasons := rated([]string{"Debt free", "Good dividend payout"}, nil)
+	if withPros != base+1 {
+		t.Errorf("Expected pros to add 1 to %v, got %v", base, withPros)
+	}
+	if last := reasons[len(reasons)-1]; last != "2 pros and 0 cons listed: +1.00" {
+		t.Errorf("Unexpected pros reason %q", last)
+	}
+	withCons, _ := rated(nil, []string{"Low interest coverage"})
+	if withCons != base-1 {
+		t.Errorf("Expected cons to take 1 from %v, got %v", base, withCons)
+	}
+	// Normalized by the count, many pros weigh no more than a few
+	mixed, _ := rated([]string{"a", "b", "c", "d", "e", "f"}, []string{"g", "h"})
+	if mixed != base+0.5 {
+		t.Errorf("Expected 6 pros and 2 cons to add 0.5 to %v, got %v", base, mixed)
+	}
+
+	// Left out by default
+	config.Set(config.FromMap(map[string]string{}))
+	if unweighted, _ := rated([]string{"Debt free"}, nil); unweighted != base {
+		t.Errorf("Expected the default PROS_CONS_WEIGHT of 0 to leave %v, got %v", base, unweighted)
+	}
+}
+
+func TestRateStockExplained_NoData(t *testing.T) {
+	score, reasons := RateStockExplained(map[string]interface{}{"name": "Example Ltd"})
+	if score != 0 {
+		t.Errorf("Expected 0, got %v", score)
+	}
+	if len(reasons) != 0 {
+		t.Errorf("Expected no reasons, got %v", reasons)
+	}
+}
+
+func TestRateStock_ClampedToScale(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"STOCK_RATE_MIN": "0", "STOCK_RATE_MAX": "10"}))
+
+	// Worse than every peer on every metric, with every quarterly metric declining steeply
+	worst := map[string]interface{}{
+		"name":          "Worst Ltd",
+		"stockPE":       "500",
+		"marketCap":     "1",
+		"dividendYield": "0",
+		"roce":          "-50",
+		"peers": primitive.A{
+			bson.M{"pe": "10", "market_cap": "1000", "div_yield": "5", "roce": "30"},
+			bson.M{"pe": "12", "market_cap": "900", "div_yield": "4", "roce": "25", PeerMedianMarker: "true"},
+		},
+		"quarterlyResults": quarterlyFixture(map[string][]string{
+			"Sales\u00a0+":      {"1000", "500", "100", "10"},
+			"Net Profit\u00a0+": {"500", "100", "-100", "-500"},
+			"Interest":          {"1", "100", "500", "1000"},
+		}),
+	}
+	// Better than every peer on every metric, with every quarterly metric rising steeply
+	best := map[string]interface{}{
+		"name":          "Best Ltd",
+		"stockPE":       "1",
+		"marketCap":     "100000",
+		"dividendYield": "10",
+		"roce":          "90",
+		"peers": primitive.A{
+			bson.M{"pe": "50", "market_cap": "10", "div_yield": "0", "roce": "5"},
+			bson.M{"pe": "40", "market_cap": "20", "div_yield": "0.1", "roce": "6", PeerMedianMarker: "true"},
+		},
+		"quarterlyResults": quarterlyFixture(map[string][]string{
+			"Sales\u00a0+":      {"10", "100", "500", "1000"},
+			"Net Profit\u00a0+": {"-500", "-100", "100", "500"},
+			"Interest":          {"1000", "500", "100", "1"},
+		}),
+	}
+
+	worstRating := RateStockDetailed(worst, nil)
+	if worstRating.Raw >= 0 || worstRating.Rate != 0 || RateStock(worst) != 0 {
+		t.Errorf("Expected the worst stock to be clamped to 0, got %+v", worstRating)
+	}
+	bestRating := RateStockDetailed(best, nil)
+	if bestRating.Raw <= 10 || bestRating.Rate != 10 || RateStock(best) != 10 {
+		t.Errorf("Expected the best stock to be clamped to 10, got %+v", bestRating)
+	}
+	if inRange := RateStockDetailed(map[string]interface{}{"name": "Example Ltd"}, nil); inRange.Rate != inRange.Raw {
+		t.Errorf("Expected a score within the scale to be kept, got %+v", inRange)
+	}
+
+	// The default scale covers the raw scores of the default weights, neither extreme is flattened
+	config.Set(config.FromMap(map[string]string{}))
+	for _, stock := range []map[string]interface{}{worst, best} {
+		if rating := RateStockDetailed(stock, nil); rating.Rate != rating.Raw {
+			t.Errorf("Expected %v to keep its raw score on the default scale, got %+v", stock["name"], rating)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"stockbackend/config"
+)
+
+// ScoringConfig is everything the stock rating depends on besides the company's own data: the
+// weights of its components, the points they award and the thresholds they apply. It is read
+// from the configuration on every call, like the scoring itself.
+type ScoringConfig struct {
+	Weights    ScoringWeights    `json:"weights"`
+	Points     ScoringPoints     `json:"points"`
+	Thresholds ScoringThresholds `json:"thresholds"`
+	// Metric directions of the quarterly trend, see TrendMetricDirections
+	TrendMetricDirections map[string]float64 `json:"trendMetricDirections"`
+	TrendExcludeTTM       bool               `json:"trendExcludeTTM"`
+	// Without peers their weight goes to the F-Score
+	FetchPeers    bool    `json:"fetchPeers"`
+	StockRateMin  float64 `json:"stockRateMin"`
+	StockRateMax  float64 `json:"stockRateMax"`
+	DecimalPlaces int     `json:"decimalPlaces"`
+}
+
+// ScoringWeights are the weights of the rating components
+type ScoringWeights struct {
+	PeerComparison  float64 `json:"peerComparison"`
+	Trend           float64 `json:"trend"`
+	SectorBenchmark float64 `json:"sectorBenchmark"`
+	Shareholding    float64 `json:"shareholding"`
+	WorkingCapital  float64 `json:"workingCapital"`
+	Growth          float64 `json:"growth"`
+	ProsCons        float64 `json:"prosCons"`
+}
+
+// ScoringPoints are the points of the F-Score and pros and cons components and of the fundamentals
+// fallback of companies with insufficient data
+type ScoringPoints struct {
+	FScorePoint           float64 `json:"fScorePoint"`
+	ProsCons              float64 `json:"prosCons"`
+	FundamentalsPE        float64 `json:"fundamentalsPE"`
+	FundamentalsExpensive float64 `json:"fundamentalsExpensive"`
+	FundamentalsLoss      float64 `json:"fundamentalsLoss"`
+	FundamentalsROCE      float64 `json:"fundamentalsROCE"`
+	FundamentalsDividend  float64 `json:"fundamentalsDividend"`
+}
+
+// ScoringThresholds are the configured thresholds of the rating and of the verdicts stored with it
+type ScoringThresholds struct {
+	FundamentalsMaxPE         float64 `json:"fundamentalsMaxPE"`
+	FundamentalsMinROCE       float64 `json:"fundamentalsMinROCE"`
+	DividendYieldMin          float64 `json:"dividendYieldMin"`
+	DividendYieldMax          float64 `json:"dividendYieldMax"`
+	GrowthStrongPercent       float64 `json:"growthStrongPercent"`
+	HighDebtToEquity          float64 `json:"highDebtToEquity"`
+	HighDebtToAssets          float64 `json:"highDebtToAssets"`
+	ValuationUndervaluedRatio float64 `json:"valuationUndervaluedRatio"`
+	ValuationOvervaluedRatio  float64 `json:"valuationOvervaluedRatio"`
+}
+
+// CurrentScoringConfig returns the scoring config in effect
+func CurrentScoringConfig() ScoringConfig {
+	cfg := config.Get()
+	return ScoringConfig{
+		Weights: ScoringWeights{
+			PeerComparison:  peerComparisonWeight,
+			Trend:           trendScoreWeight,
+			SectorBenchmark: SectorBenchmarkWeight(),
+			Shareholding:    ShareholdingWeight(),
+			WorkingCapital:  WorkingCapitalWeight(),
+			Growth:          GrowthWeight(),
+			ProsCons:        ProsConsWeight(),
+		},
+		Points: ScoringPoints{
+			FScorePoint:           fScorePoints,
+			ProsCons:              prosConsPoints,
+			FundamentalsPE:        fundamentalsPEPoints,
+			FundamentalsExpensive: fundamentalsExpensivePoints,
+			FundamentalsLoss:      fundamentalsLossPoints,
+			FundamentalsROCE:      fundamentalsROCEPoints,
+			FundamentalsDividend:  fundamentalsDividendPoints,
+		},
+		Thresholds: ScoringThresholds{
+			FundamentalsMaxPE:         cfg.FundamentalsMaxPE,
+			FundamentalsMinROCE:       cfg.FundamentalsMinROCE,
+			DividendYieldMin:          cfg.DividendYieldMin,
+			DividendYieldMax:          cfg.DividendYieldMax,
+			GrowthStrongPercent:       cfg.GrowthStrongPercent,
+			HighDebtToEquity:          cfg.HighDebtToEquity,
+			HighDebtToAssets:          cfg.HighDebtToAssets,
+			ValuationUndervaluedRatio: cfg.ValuationUndervaluedRatio,
+			ValuationOvervaluedRatio:  cfg.ValuationOvervaluedRatio,
+		},
+		TrendMetricDirections: TrendMetricDirections(),
+		TrendExcludeTTM:       cfg.TrendExcludeTTM,
+		FetchPeers:            cfg.FetchPeers,
+		StockRateMin:          cfg.StockRateMin,
+		StockRateMax:          cfg.StockRateMax,
+		DecimalPlaces:         cfg.DecimalPlaces,
+	}
+}
+
+// Hash identifies the scoring config, two scores with the same hash were computed alike. It is the
+// start of the SHA-256 of the config printed with its field names (fmt sorts the map keys).
+func (c ScoringConfig) Hash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%+v", c)))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestCurrentScoringConfig(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{
+		"PROS_CONS_WEIGHT":      "0.3",
+		"GROWTH_WEIGHT":         "0.2",
+		"SHAREHOLDING_WEIGHT":   "0",
+		"FUNDAMENTALS_MAX_PE":   "30",
+		"DIVIDEND_YIELD_MAX":    "6",
+		"HIGH_DEBT_TO_EQUITY":   "1.5",
+		"FETCH_PEERS":           "false",
+		"STOCK_RATE_MAX":        "10",
+		"DECIMAL_PLACES":        "3",
+		"GROWTH_STRONG_PERCENT": "15",
+	}))
+
+	scoring := CurrentScoringConfig()
+	weights := ScoringWeights{
+		PeerComparison:  peerComparisonWeight,
+		Trend:           trendScoreWeight,
+		SectorBenchmark: 0,
+		Shareholding:    0,
+		WorkingCapital:  0,
+		Growth:          0.2,
+		ProsCons:        0.3,
+	}
+	if scoring.Weights != weights {
+		t.Errorf("Expected weights %+v, got %+v", weights, scoring.Weights)
+	}
+	if scoring.Thresholds.FundamentalsMaxPE != 30 || scoring.Thresholds.DividendYieldMax != 6 || scoring.Thresholds.HighDebtToEquity != 1.5 || scoring.Thresholds.GrowthStrongPercent != 15 {
+		t.Errorf("Unexpected thresholds: %+v", scoring.Thresholds)
+	}
+	if scoring.FetchPeers || scoring.StockRateMax != 10 || scoring.DecimalPlaces != 3 || scoring.TrendMetricDirections["net profit"] != 1 {
+		t.Errorf("Unexpected scale or trend: %+v", scoring)
+	}
+
+	// The pros and cons component of a rating is its points times the weight the config reports
+	stock := map[string]interface{}{"name": "Example Ltd", "pros": []string{"Debt free"}}
+	_, reasons := RateStockExplained(stock)
+	expected := Round(scoring.Points.ProsCons * scoring.Weights.ProsCons)
+	if len(reasons) != 1 || reasons[0] != "1 pros and 0 cons listed: +3.000" || expected != 3 {
+		t.Errorf("Expected the pros to score %v, got %v", expected, reasons)
+	}
+}
+
+func TestScoringConfigHash(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+
+	config.Set(config.FromMap(map[string]string{}))
+	hash := CurrentScoringConfig().Hash()
+	if len(hash) != 12 || CurrentScoringConfig().Hash() != hash {
+		t.Fatalf("Expected a stable 12 character hash, got %q", hash)
+	}
+	if stamped := StampScored(bson.M{"stockRate": 12.5}); stamped["scoringConfig"] != hash {
+		t.Errorf("Expected the scores to be stamped with %q, got %v", hash, stamped["scoringConfig"])
+	}
+
+	config.Set(config.FromMap(map[string]string{"GROWTH_WEIGHT": "0.2"}))
+	if CurrentScoringConfig().Hash() == hash {
+		t.Errorf("Expected another weight to change the hash")
+	}
+	// Settings scoring doesn't use leave it alone
+	config.Set(config.FromMap(map[string]string{"INGEST_MAX_NAMES": "5"}))
+	if CurrentScoringConfig().Hash() != hash {
+		t.Errorf("Expected an unrelated setting to keep the hash")
+	}
+}
@@ -0,0 +1,108 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"stockbackend/config"
+	"strings"
+	"time"
+)
+
+// ErrDiagnosticsURL is returned for a diagnostics URL that isn't a page of COMPANY_URL
+var ErrDiagnosticsURL = errors.New("url must be a page of COMPANY_URL")
+
+const (
+	PeersFetched  = "fetched"
+	PeersMissing  = "missing"
+	PeersDisabled = "disabled"
+	// The page has no warehouse id to fetch the peers by
+	PeersUnavailable = "unavailable"
+)
+
+// undiagnosedScrapeFields are left out of the sections: derived from other sections, bookkeeping or
+// reported on their own (peers)
+var undiagnosedScrapeFields = map[string]bool{
+	"statementBasis":   true,
+	"standalone":       true,
+	"debugHtml":        true,
+	"peers":            true,
+	"peersUnavailable": true,
+	"prosInsights":     true,
+	"consInsights":     true,
+}
+
+// ScrapeDiagnostics reports how a scrape of a single company page went
+type ScrapeDiagnostics struct {
+	URL        string   `json:"url"`
+	OK         bool     `json:"ok"`
+	Error      string   `json:"error,omitempty"`
+	DurationMs int64    `json:"durationMs"`
+	Parsed     []string `json:"parsed"`
+	Empty      []string `json:"empty"`
+	Peers      string   `json:"peers"`
+}
+
+// DiagnosticsURL returns the page a scrape diagnostics request targets: an absolute http(s) URL on the
+// host of companyURL, or a path made absolute against it. Any other host is refused with
+// ErrDiagnosticsURL, the scraper mustn't be pointed at internal services.
+func DiagnosticsURL(target, companyURL string) (string, error) {
+	target = strings.TrimSpace(target)
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimSuffix(companyURL, "/") + target
+	}
+	base, err := url.Parse(companyURL)
+	if err != nil || base.Host == "" {
+		return "", ErrDiagnosticsURL
+	}
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || !strings.EqualFold(parsed.Host, base.Host) || parsed.User != nil {
+		return "", ErrDiagnosticsURL
+	}
+	return parsed.String(), nil
+}
+
+// DiagnoseScrape scrapes url with fetch, the data source in use, without storing
+// anything and reports which sections were parsed, which came back empty and how long it took. A failed
+// scrape reports every section as empty.
+func DiagnoseScrape(ctx context.Context, url string, fetch func(ctx context.Context, url string) (map[string]interface{}, error)) ScrapeDiagnostics {
+	start := time.Now()
+	data, err := fetch(ctx, url)
+	report := NewScrapeDiagnostics(url, data)
+	report.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		report.OK = false
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// NewScrapeDiagnostics sorts the sections of scraped data into parsed and empty ones
+func NewScrapeDiagnostics(url string, data map[string]interface{}) ScrapeDiagnostics {
+	report := ScrapeDiagnostics{URL: url, OK: data != nil, Parsed: []string{}, Empty: []string{}}
+	for stored, scraped := range scrapedFieldKeys {
+		if undiagnosedScrapeFields[stored] {
+			continue
+		}
+		if isEmptyScrapedValue(data[scraped]) {
+			report.Empty = append(report.Empty, stored)
+		} else {
+			report.Parsed = append(report.Parsed, stored)
+		}
+	}
+	sort.Strings(report.Parsed)
+	sort.Strings(report.Empty)
+
+	switch {
+	case !config.Get().FetchPeers:
+		report.Peers = PeersDisabled
+	case data["peersUnavailable"] == true:
+		report.Peers = PeersUnavailable
+	case isEmptyScrapedValue(data["peers"]):
+		report.Peers = PeersMissing
+	default:
+		report.Peers = PeersFetched
+	}
+	return report
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"stockbackend/config"
+	"testing"
+)
+
+func TestDiagnosticsURL(t *testing.T) {
+	const companyURL = "https://www.screener.in"
+	tests := []struct {
+		name   string
+		target string
+		want   string
+		err    bool
+	}{
+		{"company page", "https://www.screener.in/company/TCS/consolidated/", "https://www.screener.in/company/TCS/consolidated/", false},
+		{"host in another case", "https://WWW.Screener.in/company/TCS/", "https://WWW.Screener.in/company/TCS/", false},
+		{"path", "/company/TCS/", "https://www.screener.in/company/TCS/", false},
+		{"empty", "", "", true},
+		{"other host", "https://example.com/company/TCS/", "", true},
+		{"internal service", "http://169.254.169.254/latest/meta-data/", "", true},
+		{"other port", "https://www.screener.in:8443/company/TCS/", "", true},
+		{"credentials", "https://user@www.screener.in/company/TCS/", "", true},
+		{"not http", "ftp://www.screener.in/company/TCS/", "", true},
+		{"relative", "company/TCS/", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := DiagnosticsURL(test.target, companyURL)
+		if test.err {
+			if !errors.Is(err, ErrDiagnosticsURL) {
+				t.Errorf("%s: expected ErrDiagnosticsURL, got %q (%v)", test.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%s: expected %q, got %q (%v)", test.name, test.want, got, err)
+		}
+	}
+}
+
+func TestDiagnoseScrape(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"FETCH_PEERS": "true"}))
+
+	t.Run("parsed page", func(t *testing.T) {
+		var fetched string
+		fetch := func(ctx context.Context, url string) (map[string]interface{}, error) {
+			fetched = url
+			return map[string]interface{}{
+				"Market Cap":  "12,34,567",
+				"pros":        []string{"Debt free"},
+				"profitLoss":  map[string]interface{}{"Sales": []string{"100"}},
+				"peers":       []map[string]string{{"name": "Infosys"}},
+				"ratioSeries": map[string]interface{}{},
+			}, nil
+		}
+		report := DiagnoseScrape(context.Background(), "https://www.screener.in/company/TCS/consolidated/", fetch)
+		if fetched != "https://www.screener.in/company/TCS/consolidated/" {
+			t.Errorf("Expected the page to be fetched, got %q", fetched)
+		}
+		if !report.OK || report.Error != "" {
+			t.Fatalf("Expected the scrape to succeed, got %+v", report)
+		}
+		for _, section := range []string{"marketCap", "pros", "profitLoss"} {
+			if !slices.Contains(report.Parsed, section) {
+				t.Errorf("Expected %s among the parsed sections %v", section, report.Parsed)
+			}
+		}
+		if !slices.Contains(report.Empty, "balanceSheet") {
+			t.Errorf("Expected balanceSheet among the empty sections %v", report.Empty)
+		}
+		if report.Peers != PeersFetched {
+			t.Errorf("Expected the peers to be %s, got %s", PeersFetched, report.Peers)
+		}
+	})
+
+	t.Run("failed page", func(t *testing.T) {
+		fetch := func(ctx context.Context, url string) (map[string]interface{}, error) {
+			return nil, errors.New("page not found")
+		}
+		report := DiagnoseScrape(context.Background(), "https://www.screener.in/company/DOWN/", fetch)
+		if report.OK || report.Error != "page not found" {
+			t.Fatalf("Expected the scrape to fail, got %+v", report)
+		}
+		if len(report.Parsed) != 0 || !slices.Contains(report.Empty, "profitLoss") {
+			t.Errorf("Expected every section to be empty, got parsed %v and empty %v", report.Parsed, report.Empty)
+		}
+		if report.Peers != PeersMissing {
+			t.Errorf("Expected the peers to be %s, got %s", PeersMissing, report.Peers)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// scrapedFieldKeys maps the stored field names onto the keys FetchCompanyData returns them under
+var scrapedFieldKeys = map[string]string{
+	"marketCap":           "Market Cap",
+	"currentPrice":        "Current Price",
+	"highLow":             "High / Low",
+	"stockPE":             "Stock P/E",
+	"bookValue":           "Book Value",
+	"dividendYield":       "Dividend Yield",
+	"roce":                "ROCE",
+	"roe":                 "ROE",
+	"faceValue":           "Face Value",
+	"pros":                "pros",
+	"cons":                "cons",
+	"prosInsights":        "prosInsights",
+	"consInsights":        "consInsights",
+	"quarterlyResults":    "quarterlyResults",
+	"profitLoss":          "profitLoss",
+	"balanceSheet":        "balanceSheet",
+	"cashFlows":           "cashFlows",
+	"ratios":              "ratios",
+	"profitLossHeaders":   "profitLossHeaders",
+	"balanceSheetHeaders": "balanceSheetHeaders",
+	"cashFlowsHeaders":    "cashFlowsHeaders",
+	"ratiosHeaders":       "ratiosHeaders",
+	"ratioSeries":         "ratioSeries",
+	"growthSummary":       "growthSummary",
+	"statementBasis":      "statementBasis",
+	"standalone":          "standalone",
+	"shareholdingPattern": "shareholdingPattern",
+	"shareholdingTrend":   "shareholdingTrend",
+	"peersTable":          "peersTable",
+	"peers":               "peers",
+	"peersUnavailable":    "peersUnavailable",
+	"sector":              "sector",
+	"debugHtml":           "debugHtml",
+}
+
+// isEmptyScrapedValue reports whether a scraped value carries nothing: missing, blank or an empty table/list
+func isEmptyScrapedValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if str, ok := value.(string); ok {
+		return strings.TrimSpace(str) == ""
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// ScrapedFieldsUpdate builds the $set fields of a scrape, leaving out the sections that came back
+// empty so a partially failed scrape doesn't overwrite the data stored by a previous one
+func ScrapedFieldsUpdate(data map[string]interface{}) bson.M {
+	fields := bson.M{}
+	for stored, scraped := range scrapedFieldKeys {
+		if value := data[scraped]; !isEmptyScrapedValue(value) {
+			fields[stored] = value
+		}
+	}
+	// The trend is derived from the pattern, without one it would only say "unavailable"
+	if _, ok := fields["shareholdingPattern"]; !ok {
+		delete(fields, "shareholdingTrend")
+	}
+	// Deltas are set per statement, those of a statement that came back empty are kept with it
+	for section, rows := range FinancialDeltas(fields) {
+		fields["deltas."+section] = rows
+	}
+	return fields
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"reflect"
+	"stockbackend/types"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestScrapedFieldsUpdate_PreservesFailedSections(t *testing.T) {
+	storedBalanceSheet := []types.TableRow{{Label: "Borrowings\u00a0+", Values: []string{"100", "120"}}}
+	existing := bson.M{
+		"name":             "Example Ltd",
+		"stockPE":          "17.8",
+		"balanceSheet":     storedBalanceSheet,
+		"quarterlyResults": map[string]interface{}{"Sales\u00a0+": []interface{}{map[string]interface{}{"Jun 2024": "90"}}},
+	}
+
+	// The balance sheet section failed to parse this time, the quarterly results didn't
+	quarterly := map[string]interface{}{"Sales\u00a0+": []map[string]string{{"Sep 2024": "100"}}}
+	data := map[string]interface{}{
+		"Market Cap":       "1,070",
+		"Stock P/E":        "  ",
+		"quarterlyResults": quarterly,
+		"balanceSheet":     []types.TableRow{},
+		"cashFlows":        nil,
+		"pros":             []string{},
+	}
+	update := ScrapedFieldsUpdate(data)
+
+	for _, field := range []string{"balanceSheet", "stockPE", "cashFlows", "pros", "shareholdingTrend"} {
+		if _, ok := update[field]; ok {
+			t.Errorf("Expected the empty %s not to be set, got %v", field, update[field])
+		}
+	}
+
+	// Apply the $set the way Mongo would
+	for key, value := range update {
+		existing[key] = value
+	}
+	if !reflect.DeepEqual(existing["balanceSheet"], storedBalanceSheet) {
+		t.Errorf("Expected the stored balance sheet to be preserved, got %v", existing["balanceSheet"])
+	}
+	if existing["stockPE"] != "17.8" {
+		t.Errorf("Expected the stored PE to be preserved, got %v", existing["stockPE"])
+	}
+	if !reflect.DeepEqual(existing["quarterlyResults"], quarterly) {
+		t.Errorf("Expected the quarterly results to be updated, got %v", existing["quarterlyResults"])
+	}
+	if existing["marketCap"] != "1,070" {
+		t.Errorf("Expected the market cap to be set, got %v", existing["marketCap"])
+	}
+}
+
+func TestScrapedFieldsUpdate_ShareholdingTrend(t *testing.T) {
+	data := map[string]interface{}{
+		"shareholdingPattern": map[string]interface{}{"quarterly": map[string]interface{}{"Promoters\u00a0+": map[string]string{"Mar 2024": "50%"}}},
+		"shareholdingTrend":   ShareholdingAnalysis{},
+	}
+	if _, ok := ScrapedFieldsUpdate(data)["shareholdingTrend"]; !ok {
+		t.Errorf("Expected the trend to be stored with its pattern")
+	}
+}
@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"regexp"
+	"sort"
+	"stockbackend/config"
+	"stockbackend/types"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// legacySectorPattern reads the "Sector: ... Industry: ..." line of older company pages
+var legacySectorPattern = regexp.MustCompile(`Sector:\s*(.+?)\s*(?:Industry:|$)`)
+
+// SectorBenchmark holds the median fundamentals of all stored companies in a sector
+type SectorBenchmark struct {
+	Sector        string  `json:"sector"`
+	Count         int     `json:"count"`
+	PE            float64 `json:"pe"`
+	MarketCap     float64 `json:"marketCap"`
+	DividendYield float64 `json:"dividendYield"`
+	ROCE          float64 `json:"roce"`
+}
+
+// SectorBenchmarkWeight returns the weight of the sector component in the final score (SECTOR_BENCHMARK_WEIGHT).
+// It defaults to 0, which leaves the sector comparison out of the rating.
+func SectorBenchmarkWeight() float64 {
+	return config.Get().SectorBenchmarkWeight
+}
+
+// ParseSector returns the normalized sector of a screener company page, shown as a link titled
+// "Sector" above the peers table, or on the "Sector: ... Industry: ..." line of older pages. It is
+// empty when the page shows neither.
+func ParseSector(doc *goquery.Document) string {
+	peers := doc.Find("section#peers")
+	if sector := strings.TrimSpace(peers.Find(`a[title="Sector"]`).First().Text()); sector != "" {
+		return NormalizeSector(sector)
+	}
+	var sector string
+	peers.Find("p.sub").EachWithBreak(func(i int, line *goquery.Selection) bool {
+		text := strings.Join(strings.Fields(line.Text()), " ")
+		if match := legacySectorPattern.FindStringSubmatch(text); match != nil {
+			sector = NormalizeSector(match[1])
+			return false
+		}
+		return true
+	})
+	return sector
+}
+
+// NormalizeSector maps the different spellings of a sector onto a single key
+func NormalizeSector(sector string) string {
+	return strings.Join(strings.Fields(NormalizeString(sector)), " ")
+}
+
+// ComputeSectorBenchmark computes the median fundamentals from the stored documents of a sector
+func ComputeSectorBenchmark(sector string, docs []map[string]interface{}) SectorBenchmark {
+	var pe, marketCap, dividendYield, roce []float64
+	for _, doc := range docs {
+		// Loss making companies have no meaningful PE and would drag the median down
+		if value := ToFloat(doc["stockPE"]); value > 0 {
+			pe = append(pe, value)
+		}
+		if value := ToFloat(doc["marketCap"]); value > 0 {
+			marketCap = append(marketCap, value)
+		}
+		dividendYield = append(dividendYield, ToFloat(doc["dividendYield"]))
+		roce = append(roce, ToFloat(doc["roce"]))
+	}
+
+	return SectorBenchmark{
+		Sector:        NormalizeSector(sector),
+		Count:         len(docs),
+		PE:            Round(median(pe)),
+		MarketCap:     Round(median(marketCap)),
+		DividendYield: Round(median(dividendYield)),
+		ROCE:          Round(median(roce)),
+	}
+}
+
+// CompareWithSector scores a stock against its sector medians, rewarding above median fundamentals
+func CompareWithSector(stock types.Stock, benchmark SectorBenchmark) float64 {
+	return compareWithSector(stock, benchmark, nil)
+}
+
+func compareWithSector(stock types.Stock, benchmark SectorBenchmark, explanation *scoreExplanation) float64 {
+	// A benchmark built from the stock alone says nothing about the sector
+	if benchmark.Count < 2 {
+		return 0.0
+	}
+
+	score := 0.0
+	award := func(points float64, reason string) {
+		score += points
+		explanation.add(points, reason)
+	}
+	if stock.PE > 0 && benchmark.PE > 0 && stock.PE < benchmark.PE {
+		award(10, "PE below sector median")
+	}
+	if stock.ROCE > benchmark.ROCE {
+		award(10, "ROCE above sector median")
+	}
+	if dividendYieldInBand(stock.DividendYield) && stock.DividendYield > benchmark.DividendYield {
+		award(5, "Dividend yield above sector median")
+	}
+	if stock.MarketCap > benchmark.MarketCap {
+		award(5, "Market cap above sector median")
+	}
+	return score
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0.0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,147 @@
+package helpers
+
+import (
+	"regexp"
+	"sort"
+	"stockbackend/config"
+	"strings"
+	"time"
+)
+
+// Shareholding trend directions
+const (
+	TrendIncreasing  = "increasing"
+	TrendDecreasing  = "decreasing"
+	TrendStable      = "stable"
+	TrendUnavailable = "unavailable"
+)
+
+var (
+	promoterCategoryPattern = regexp.MustCompile(`(?i)^promoters?\b`)
+	pledgeCategoryPattern   = regexp.MustCompile(`(?i)pledge`)
+)
+
+// HoldingTrend is the change of a shareholding category between the first and the latest period
+type HoldingTrend struct {
+	Trend  string  `json:"trend" bson:"trend"`
+	First  float64 `json:"first" bson:"first"`
+	Latest float64 `json:"latest" bson:"latest"`
+	Change float64 `json:"change" bson:"change"`
+}
+
+// ShareholdingAnalysis is the derived view of the shareholding pattern stored on the document
+type ShareholdingAnalysis struct {
+	Promoter HoldingTrend `json:"promoter" bson:"promoter"`
+	Pledge   HoldingTrend `json:"pledge" bson:"pledge"`
+}
+
+// ShareholdingWeight returns the weight of the shareholding component in the final score (SHAREHOLDING_WEIGHT)
+func ShareholdingWeight() float64 {
+	return config.Get().ShareholdingWeight
+}
+
+// AnalyzeShareholding computes the promoter holding and pledged shares trends from the
+// shareholding pattern, preferring the quarterly table over the yearly one
+func AnalyzeShareholding(pattern interface{}) ShareholdingAnalysis {
+	analysis := ShareholdingAnalysis{
+		Promoter: HoldingTrend{Trend: TrendUnavailable},
+		Pledge:   HoldingTrend{Trend: TrendUnavailable},
+	}
+
+	data, ok := toMap(pattern)
+	if !ok {
+		return analysis
+	}
+	table, ok := toArray(data["quarterly"])
+	if !ok || len(table) == 0 {
+		if table, ok = toArray(data["yearly"]); !ok {
+			return analysis
+		}
+	}
+
+	for _, rowRaw := range table {
+		row, ok := toMap(rowRaw)
+		if !ok {
+			continue
+		}
+		category, _ := row["category"].(string)
+		category = NormalizeMetricName(category)
+
+		switch {
+		case pledgeCategoryPattern.MatchString(category):
+			analysis.Pledge = holdingTrend(row["values"])
+		case promoterCategoryPattern.MatchString(category):
+			analysis.Promoter = holdingTrend(row["values"])
+		}
+	}
+	return analysis
+}
+
+// ShareholdingScore penalizes a decreasing promoter holding and any increase in pledged shares
+func ShareholdingScore(analysis ShareholdingAnalysis) float64 {
+	return shareholdingScore(analysis, nil)
+}
+
+func shareholdingScore(analysis ShareholdingAnalysis, explanation *scoreExplanation) float64 {
+	score := 0.0
+	switch analysis.Promoter.Trend {
+	case TrendIncreasing:
+		score += 5
+		explanation.add(5, "Promoter holding increasing")
+	case TrendDecreasing:
+		score -= 10
+		explanation.add(-10, "Promoter holding decreasing")
+	}
+	if analysis.Pledge.Trend == TrendIncreasing {
+		score -= 10
+		explanation.add(-10, "Pledged shares increasing")
+	}
+	return score
+}
+
+// holdingTrend orders the {period: percentage} values of a category chronologically
+// and compares the first and the latest period
+func holdingTrend(values interface{}) HoldingTrend {
+	cells, ok := toMap(values)
+	if !ok {
+		return HoldingTrend{Trend: TrendUnavailable}
+	}
+
+	type period struct {
+		date  time.Time
+		label string
+		value float64
+	}
+	var periods []period
+	for label, cell := range cells {
+		str, ok := cell.(string)
+		if !ok || strings.TrimSpace(str) == "" {
+			continue
+		}
+		value, err := ParseNumber(strings.ReplaceAll(str, "%", ""), CurrentNumberFormat())
+		if err != nil {
+			continue
+		}
+		date, _ := time.Parse("Jan 2006", strings.TrimSpace(label))
+		periods = append(periods, period{date: date, label: label, value: value})
+	}
+	if len(periods) < 2 {
+		return HoldingTrend{Trend: TrendUnavailable}
+	}
+
+	sort.Slice(periods, func(i, j int) bool {
+		if periods[i].date.Equal(periods[j].date) {
+			return periods[i].label < periods[j].label
+		}
+		return periods[i].date.Before(periods[j].date)
+	})
+
+	first, latest := periods[0].value, periods[len(periods)-1].value
+	trend := HoldingTrend{First: Round(first), Latest: Round(latest), Change: Round(latest - first), Trend: TrendStable}
+	if latest > first {
+		trend.Trend = TrendIncreasing
+	} else if latest < first {
+		trend.Trend = TrendDecreasing
+	}
+	return trend
+}
@@ -0,0 +1,56 @@
+package helpers
+
+import (
+	"stockbackend/types"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// PreviewSheet shows how the upload parser reads a sheet: the header row it detects, the column
+// each standard key is mapped onto and the first limit holdings as ExtractHoldings returns them.
+// Nothing is looked up, scraped or stored. Header cells no standard key matched are listed in
+// Unmapped, a sheet without a header row only carries its name.
+func PreviewSheet(sheet SheetRows, limit int) types.SheetPreview {
+	preview := types.SheetPreview{
+		Sheet:     sheet.Sheet,
+		HeaderMap: map[string]types.PreviewColumn{},
+		Unmapped:  []string{},
+		Rows:      []map[string]interface{}{},
+	}
+	if sheet.Err != nil {
+		preview.Error = sheet.Err.Error()
+		return preview
+	}
+
+	for i, row := range sheet.Rows {
+		headerMap, found := BuildHeaderMap(row)
+		if len(row) == 0 || !found {
+			continue
+		}
+		preview.HeaderRow = i + 1
+		mapped := map[int]bool{}
+		for key, index := range headerMap {
+			column, _ := excelize.ColumnNumberToName(index + 1)
+			preview.HeaderMap[key] = types.PreviewColumn{Column: column, Index: index, Header: strings.TrimSpace(row[index])}
+			mapped[index] = true
+		}
+		for index, cell := range row {
+			if cell := strings.TrimSpace(cell); cell != "" && !mapped[index] {
+				preview.Unmapped = append(preview.Unmapped, cell)
+			}
+		}
+		break
+	}
+	if preview.HeaderRow == 0 {
+		return preview
+	}
+
+	holdings := ExtractHoldings(sheet.Rows)
+	preview.Holdings = len(holdings)
+	if limit >= 0 && len(holdings) > limit {
+		holdings = holdings[:limit]
+	}
+	preview.Rows = holdings
+	return preview
+}
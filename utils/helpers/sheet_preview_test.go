@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"errors"
+	"reflect"
+	"stockbackend/types"
+	"testing"
+)
+
+func TestPreviewSheet(t *testing.T) {
+	rows := sheetFixture(t, [][]interface{}{
+		{"ABC Flexi Cap Fund"},
+		{"Portfolio as on 31-Mar-2024"},
+		{"Sr. No.", "ISIN", "Name of the Instrument", "Rating / Industry", "Quantity", "Market value (Rs. in lakhs)", "% to NAV", "Yield"},
+		{"1", "INE009A01021", "Infosys Limited", "IT - Software", "1,000", "1500.5", "2.5%", ""},
+		{"2", "INE040A01034", "HDFC Bank Limited", "Banks", "2,000", "3000", "5%", ""},
+		{"3", "INE002A01018", "Reliance Industries Limited", "Petroleum Products", "10", "20", "0.1%", ""},
+		{"Total", "", "", "", "", "4520.5", "7.6%", ""},
+	})
+
+	preview := PreviewSheet(SheetRows{Sheet: "Sheet1", Rows: rows}, 2)
+
+	if preview.HeaderRow != 3 {
+		t.Errorf("Expected the header on row 3, got %d", preview.HeaderRow)
+	}
+	expected := map[string]types.PreviewColumn{
+		"ISIN":                   {Column: "B", Index: 1, Header: "ISIN"},
+		"Name of the Instrument": {Column: "C", Index: 2, Header: "Name of the Instrument"},
+		"Industry/Rating":        {Column: "D", Index: 3, Header: "Rating / Industry"},
+		"Quantity":               {Column: "E", Index: 4, Header: "Quantity"},
+		"Market/Fair Value":      {Column: "F", Index: 5, Header: "Market value (Rs. in lakhs)"},
+		"Percentage of AUM":      {Column: "G", Index: 6, Header: "% to NAV"},
+	}
+	if !reflect.DeepEqual(preview.HeaderMap, expected) {
+		t.Errorf("Unexpected header map: %+v", preview.HeaderMap)
+	}
+	if !reflect.DeepEqual(preview.Unmapped, []string{"Sr. No.", "Yield"}) {
+		t.Errorf("Expected the unmatched header cells, got %v", preview.Unmapped)
+	}
+	if preview.Holdings != 3 || len(preview.Rows) != 2 {
+		t.Fatalf("Expected 3 holdings with the first 2 previewed, got %d and %v", preview.Holdings, preview.Rows)
+	}
+	if preview.Rows[0]["Name of the Instrument"] != "Infosys Limited" || preview.Rows[0]["quantity"] != 1000.0 {
+		t.Errorf("Expected the first holding as extracted, got %v", preview.Rows[0])
+	}
+}
+
+func TestPreviewSheet_NoHeader(t *testing.T) {
+	rows := [][]string{{"Scheme", "Value"}, {"Infosys Limited", "100"}}
+	preview := PreviewSheet(SheetRows{Sheet: "Notes", Rows: rows}, 5)
+	if preview.HeaderRow != 0 || len(preview.HeaderMap) != 0 || len(preview.Rows) != 0 {
+		t.Errorf("Expected no header and no rows, got %+v", preview)
+	}
+
+	preview = PreviewSheet(SheetRows{Sheet: "Broken", Err: errors.New("could not read sheet")}, 5)
+	if preview.Error != "could not read sheet" {
+		t.Errorf("Expected the sheet error, got %+v", preview)
+	}
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"stockbackend/config"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// defaultSkipLabels are the common AMFI section headers, see skip_labels.json
+//
+//go:embed skip_labels.json
+var defaultSkipLabels []byte
+
+// SkipLabel is a section label written in the instrument name column, pattern matches the
+// lowercased name and label is an example of it
+type SkipLabel struct {
+	Label   string `json:"label"`
+	Pattern string `json:"pattern"`
+}
+
+// Enumerators in front of section labels, e.g. "(a)", "b)", "iii." or "1."
+var labelEnumeratorPattern = regexp.MustCompile(`^\(?([a-z]|[ivx]+|\d+)[).]\s*`)
+
+var (
+	skipLabelsOnce     sync.Once
+	skipLabelsPatterns []*regexp.Regexp
+)
+
+// ParseSkipLabels compiles a JSON list of skip labels
+func ParseSkipLabels(data []byte) ([]*regexp.Regexp, error) {
+	var labels []SkipLabel
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("error parsing skip labels: %w", err)
+	}
+	patterns := make([]*regexp.Regexp, 0, len(labels))
+	for _, label := range labels {
+		pattern, err := regexp.Compile(label.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %q: %w", label.Label, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// skipLabels returns the patterns of SKIP_LABELS_FILE, or the embedded defaults when it is unset
+// or can't be read
+func skipLabels() []*regexp.Regexp {
+	skipLabelsOnce.Do(func() {
+		if path := config.Get().SkipLabelsFile; path != "" {
+			data, err := os.ReadFile(path)
+			if err == nil {
+				skipLabelsPatterns, err = ParseSkipLabels(data)
+			}
+			if err == nil {
+				return
+			}
+			zap.L().Error("Error loading SKIP_LABELS_FILE, using the default labels", zap.String("path", path), zap.Error(err))
+		}
+		patterns, err := ParseSkipLabels(defaultSkipLabels)
+		if err != nil {
+			panic(err)
+		}
+		skipLabelsPatterns = patterns
+	})
+	return skipLabelsPatterns
+}
+
+// IsSkippedLabel reports whether an instrument name is a section label (e.g. "Equity & Equity related"
+// or "Net Receivables / (Payables)") rather than a holding
+func IsSkippedLabel(name string) bool {
+	return matchesSkipLabel(name, skipLabels())
+}
+
+func matchesSkipLabel(name string, patterns []*regexp.Regexp) bool {
+	normalized := strings.Join(strings.Fields(NormalizeString(name)), " ")
+	normalized = labelEnumeratorPattern.ReplaceAllString(normalized, "")
+	normalized = strings.TrimRight(normalized, ": ")
+	for _, pattern := range patterns {
+		if pattern.MatchString(normalized) {
+			return true
+		}
+	}
+	return false
+}
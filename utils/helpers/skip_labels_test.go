@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDefaultSkipLabels(t *testing.T) {
+	var labels []SkipLabel
+	if err := json.Unmarshal(defaultSkipLabels, &labels); err != nil {
+		t.Fatalf("Error parsing the default labels: %v", err)
+	}
+	patterns, err := ParseSkipLabels(defaultSkipLabels)
+	if err != nil {
+		t.Fatalf("Error compiling the default labels: %v", err)
+	}
+	for _, label := range labels {
+		if !matchesSkipLabel(label.Label, patterns) {
+			t.Errorf("Expected the default label %q to be skipped", label.Label)
+		}
+	}
+}
+
+func TestMatchesSkipLabel(t *testing.T) {
+	patterns, err := ParseSkipLabels(defaultSkipLabels)
+	if err != nil {
+		t.Fatalf("Error compiling the default labels: %v", err)
+	}
+	tests := []struct {
+		name    string
+		skipped bool
+	}{
+		{"(a) Listed / awaiting listing on Stock Exchanges", true},
+		{"EQUITY AND EQUITY RELATED INSTRUMENTS", true},
+		{"b) Unlisted", true},
+		{"Net Receivables/(Payables)", true},
+		{"Money Market Instruments:", true},
+		{"ii. Certificates of Deposit", true},
+		{"Infosys Limited", false},
+		{"91 Days Treasury Bill 2024", false},
+		{"NIFTY 50 Index Futures Mar 2024", false},
+		{"Cash Management Bill", false},
+	}
+	for _, test := range tests {
+		if skipped := matchesSkipLabel(test.name, patterns); skipped != test.skipped {
+			t.Errorf("matchesSkipLabel(%q): expected %v, got %v", test.name, test.skipped, skipped)
+		}
+	}
+
+	if _, err := ParseSkipLabels([]byte(`[{"label": "Broken", "pattern": "("}]`)); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+}
+
+func TestExtractHoldings_SkipsSectionLabels(t *testing.T) {
+	rows := sheetFixture(t, [][]interface{}{
+		{"Name of the Instrument", "ISIN", "Quantity", "Market value", "% to NAV"},
+		{"Equity & Equity related"},
+		{"(a) Listed / awaiting listing on Stock Exchanges"},
+		{"Infosys Limited", "INE009A01021", "1000", "1500", "60%"},
+		{"Net Receivables / (Payables)", "", "", "50", "2%"},
+		{"Total", "", "", "1550", "62%"},
+	})
+
+	holdings := ExtractHoldings(rows)
+	if len(holdings) != 1 || holdings[0]["Name of the Instrument"] != "Infosys Limited" {
+		t.Errorf("Expected only the instrument to be extracted, got %v", holdings)
+	}
+}
@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"strings"
+)
+
+// Bases of the financial statements of a company page
+const (
+	StatementsConsolidated = "consolidated"
+	StatementsStandalone   = "standalone"
+)
+
+// FScoreNotComputable is the F-Score of a company none of whose statement sets has the rows it needs
+const FScoreNotComputable = -1
+
+// statementSections are the sections, and their headers, making up a statement set
+var statementSections = []string{
+	"profitLoss", "profitLossHeaders",
+	"balanceSheet", "balanceSheetHeaders",
+	"cashFlows", "cashFlowsHeaders",
+}
+
+// fScoreRequiredRows are the rows every F-Score needs a latest value of, alternatives separated by " or "
+var fScoreRequiredRows = []struct{ section, label string }{
+	{"profitLoss", "Net Profit +"},
+	{"profitLoss", "Sales + or Revenue"},
+	{"balanceSheet", "Total Assets"},
+	{"balanceSheet", "Borrowings +"},
+	{"balanceSheet", "Other Assets +"},
+	{"balanceSheet", "Other Liabilities +"},
+	{"balanceSheet", "Equity Capital"},
+	{"cashFlows", "Cash from Operating Activity +"},
+}
+
+// StatementSet is one set of financial statements of a company with the basis they are reported on
+type StatementSet struct {
+	Basis  string
+	Tables map[string]interface{}
+}
+
+// StatementBasis tells the basis of a screener company page from its URL
+func StatementBasis(url string) string {
+	if strings.Contains(url, "/consolidated") {
+		return StatementsConsolidated
+	}
+	return StatementsStandalone
+}
+
+// StandaloneURL returns the standalone page of a consolidated screener company page
+func StandaloneURL(url string) string {
+	return strings.Replace(url, "/consolidated/", "/", 1)
+}
+
+// StatementTables keeps the statement sections of scraped company data
+func StatementTables(data map[string]interface{}) map[string]interface{} {
+	tables := map[string]interface{}{}
+	for _, section := range statementSections {
+		if value, ok := data[section]; ok {
+			tables[section] = value
+		}
+	}
+	return tables
+}
+
+// StatementSets lists the statement sets of a stock, consolidated first. The top level tables are
+// the ones of the scraped page (statementBasis, consolidated when not recorded), a standalone set
+// fetched alongside them is stored under "standalone".
+func StatementSets(stock map[string]interface{}) []StatementSet {
+	basis, _ := stock["statementBasis"].(string)
+	if basis != StatementsStandalone {
+		basis = StatementsConsolidated
+	}
+	sets := []StatementSet{{Basis: basis, Tables: stock}}
+	if standalone, ok := toMap(stock["standalone"]); ok && basis == StatementsConsolidated {
+		sets = append(sets, StatementSet{Basis: StatementsStandalone, Tables: standalone})
+	}
+	return sets
+}
+
+// FScoreStatementGaps lists the required F-Score rows missing from the tables or without a latest
+// value, as "<section>: <row>"
+func FScoreStatementGaps(tables map[string]interface{}) []string {
+	gaps := []string{}
+	for _, required := range fScoreRequiredRows {
+		found := false
+		for _, label := range strings.Split(required.label, " or ") {
+			values, err := getAnnualArrayField(tables, required.section, label)
+			if err == nil && len(values) > 0 {
+				latest, _ := values[len(values)-1].(string)
+				found = strings.TrimSpace(latest) != ""
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			gaps = append(gaps, required.section+": "+required.label)
+		}
+	}
+	return gaps
+}
+
+// selectFScoreStatements picks the first statement set, consolidated preferred, with the rows the
+// F-Score needs. Without one it returns the preferred set and what it is missing.
+func selectFScoreStatements(stock map[string]interface{}) (StatementSet, []string) {
+	sets := StatementSets(stock)
+	var preferredGaps []string
+	for i, set := range sets {
+		gaps := FScoreStatementGaps(set.Tables)
+		if len(gaps) == 0 {
+			return set, nil
+		}
+		if i == 0 {
+			preferredGaps = gaps
+		}
+	}
+	return sets[0], preferredGaps
+}
@@ -0,0 +1,222 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type StreamFormat string
+
+const (
+	// StreamNDJSON writes one JSON object per line followed by "Stream complete."
+	StreamNDJSON StreamFormat = "ndjson"
+	// StreamJSON writes a single JSON array, streamed element by element
+	StreamJSON StreamFormat = "json"
+	// StreamSSE writes Server-Sent Events "data:" frames and a final "complete" event
+	StreamSSE StreamFormat = "sse"
+)
+
+// ParseStreamFormat validates the ?format= param of streaming endpoints, defaulting to ndjson
+func ParseStreamFormat(value string) (StreamFormat, error) {
+	switch format := StreamFormat(strings.ToLower(strings.TrimSpace(value))); format {
+	case "":
+		return StreamNDJSON, nil
+	case StreamNDJSON, StreamJSON, StreamSSE:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid format %q, expected ndjson, json or sse", value)
+}
+
+// StreamWriter writes streamed entries in one of the StreamFormat framings, flushing each entry.
+// Its methods may be called from the heartbeat goroutine and the handler at once.
+type StreamWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	format  StreamFormat
+	entries int
+	started bool
+	closed  bool
+	fields  FieldProjection
+	// lastWrite is when the last entry or heartbeat was flushed
+	lastWrite time.Time
+}
+
+func NewStreamWriter(w io.Writer, format StreamFormat) *StreamWriter {
+	return &StreamWriter{w: w, format: format}
+}
+
+// SetFields sets the projection WriteHolding applies to the holdings
+func (sw *StreamWriter) SetFields(fields FieldProjection) {
+	sw.fields = fields
+}
+
+// WriteHolding writes the fields of a holding selected by SetFields, summaries and errors are
+// written whole with WriteEntry
+func (sw *StreamWriter) WriteHolding(holding map[string]interface{}) error {
+	return sw.WriteEntry(sw.fields.Apply(holding))
+}
+
+// ContentType returns the Content-Type matching the format
+func (sw *StreamWriter) ContentType() string {
+	switch sw.format {
+	case StreamJSON:
+		return "application/json"
+	case StreamSSE:
+		return "text/event-stream"
+	}
+	return "text/plain"
+}
+
+func (sw *StreamWriter) flush() {
+	if flusher, ok := sw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// start opens the JSON array before the first entry
+func (sw *StreamWriter) start() error {
+	if sw.started {
+		return nil
+	}
+	sw.started = true
+	if sw.format == StreamJSON {
+		if _, err := io.WriteString(sw.w, "["); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteEntry writes a single entry and flushes it to the client
+func (sw *StreamWriter) WriteEntry(entry interface{}) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error marshalling data: %w", err)
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if err := sw.start(); err != nil {
+		return fmt.Errorf("error writing data: %w", err)
+	}
+
+	var frame []byte
+	switch sw.format {
+	case StreamJSON:
+		if sw.entries > 0 {
+			frame = append(frame, ',')
+		}
+		frame = append(frame, data...)
+	case StreamSSE:
+		frame = append([]byte("data: "), data...)
+		frame = append(frame, '\n', '\n')
+	default:
+		frame = append(data, '\n')
+	}
+	if _, err := sw.w.Write(frame); err != nil {
+		return fmt.Errorf("error writing data: %w", err)
+	}
+	sw.entries++
+	sw.lastWrite = time.Now()
+	sw.flush()
+	return nil
+}
+
+// StartHeartbeat keeps an idle stream alive through proxies while entries are slow to come, e.g.
+// during scrapes: every interval without an entry it writes a heartbeat the format's readers skip.
+// That's a {"type": "heartbeat"} line in ndjson, whitespace between the elements of the json
+// array and a ": heartbeat" comment in sse. A non-positive interval disables it. The returned
+// function stops the heartbeat and waits until it no longer writes, it must be called before
+// the response is written to by anything but the StreamWriter.
+func (sw *StreamWriter) StartHeartbeat(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	sw.mu.Lock()
+	sw.lastWrite = time.Now()
+	sw.mu.Unlock()
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// A client that went away ends the heartbeat, the handler notices on its next entry
+				if err := sw.heartbeat(interval); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// heartbeat writes a heartbeat when nothing was flushed for the interval
+func (sw *StreamWriter) heartbeat(interval time.Duration) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.closed || time.Since(sw.lastWrite) < interval {
+		return nil
+	}
+	if err := sw.start(); err != nil {
+		return err
+	}
+
+	var frame string
+	switch sw.format {
+	case StreamJSON:
+		frame = "\n"
+	case StreamSSE:
+		frame = ": heartbeat\n\n"
+	default:
+		frame = `{"type":"heartbeat"}` + "\n"
+	}
+	if _, err := io.WriteString(sw.w, frame); err != nil {
+		return err
+	}
+	sw.lastWrite = time.Now()
+	sw.flush()
+	return nil
+}
+
+// Close terminates the stream: closes the JSON array, sends the SSE "complete" event
+// or the ndjson "Stream complete." line
+func (sw *StreamWriter) Close() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if err := sw.start(); err != nil {
+		return err
+	}
+	sw.closed = true
+
+	var end string
+	switch sw.format {
+	case StreamJSON:
+		end = "]"
+	case StreamSSE:
+		end = "event: complete\ndata: {}\n\n"
+	default:
+		end = "\nStream complete.\n"
+	}
+	if _, err := io.WriteString(sw.w, end); err != nil {
+		return err
+	}
+	sw.flush()
+	return nil
+}
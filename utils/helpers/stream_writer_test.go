@@ -0,0 +1,186 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var streamEntries = []interface{}{
+	map[string]interface{}{"Name of the Instrument": "Infosys Limited", "Quantity": "1000"},
+	map[string]interface{}{"type": "error", "file": "broken.xlsx", "error": "could not open workbook"},
+	map[string]interface{}{"type": "summary", "file": "fund.xlsx"},
+}
+
+func writeStream(t *testing.T, format StreamFormat, entries []interface{}) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	stream := NewStreamWriter(recorder, format)
+	for _, entry := range entries {
+		if err := stream.WriteEntry(entry); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return recorder
+}
+
+func TestParseStreamFormat(t *testing.T) {
+	tests := map[string]StreamFormat{"": StreamNDJSON, "ndjson": StreamNDJSON, "JSON": StreamJSON, "sse": StreamSSE}
+	for value, expected := range tests {
+		if format, err := ParseStreamFormat(value); err != nil || format != expected {
+			t.Errorf("ParseStreamFormat(%q): expected %v, got %v (%v)", value, expected, format, err)
+		}
+	}
+	if _, err := ParseStreamFormat("xml"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
+
+func TestStreamWriter_NDJSON(t *testing.T) {
+	recorder := writeStream(t, StreamNDJSON, streamEntries)
+	if !recorder.Flushed {
+		t.Errorf("Expected the entries to be flushed")
+	}
+
+	body := recorder.Body.String()
+	if !strings.HasSuffix(body, "\nStream complete.\n") {
+		t.Errorf("Expected the ndjson stream to keep its completion line, got %q", body)
+	}
+	lines := strings.Split(strings.TrimSuffix(body, "\nStream complete.\n"), "\n")
+	if len(lines) != len(streamEntries)+1 || lines[len(lines)-1] != "" {
+		t.Fatalf("Expected one line per entry, got %q", lines)
+	}
+	for _, line := range lines[:len(lines)-1] {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Errorf("Expected each line to be valid JSON, got %q: %v", line, err)
+		}
+	}
+}
+
+func TestStreamWriter_JSON(t *testing.T) {
+	recorder := writeStream(t, StreamJSON, streamEntries)
+
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Expected a valid JSON array, got %q: %v", recorder.Body.String(), err)
+	}
+	if len(entries) != len(streamEntries) || entries[1]["type"] != "error" {
+		t.Errorf("Expected the entries in order, got %v", entries)
+	}
+
+	empty := writeStream(t, StreamJSON, nil)
+	if empty.Body.String() != "[]" {
+		t.Errorf("Expected an empty array without entries, got %q", empty.Body.String())
+	}
+}
+
+func TestStreamWriter_SSE(t *testing.T) {
+	recorder := writeStream(t, StreamSSE, streamEntries)
+
+	// Events are separated by blank lines, every line is a "field: value" pair
+	var events []map[string]string
+	event := map[string]string{}
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			events = append(events, event)
+			event = map[string]string{}
+			continue
+		}
+		field, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			t.Fatalf("Expected a field line, got %q", line)
+		}
+		event[field] = value
+	}
+	if len(event) != 0 {
+		t.Fatalf("Expected the stream to end with a blank line, got %v", event)
+	}
+
+	if len(events) != len(streamEntries)+1 {
+		t.Fatalf("Expected %d events, got %v", len(streamEntries)+1, events)
+	}
+	for _, event := range events[:len(streamEntries)] {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(event["data"]), &entry); err != nil {
+			t.Errorf("Expected JSON data, got %q: %v", event["data"], err)
+		}
+	}
+	if events[len(events)-1]["event"] != "complete" {
+		t.Errorf("Expected a final complete event, got %v", events[len(events)-1])
+	}
+}
+
+// slowStream streams an entry after a pause long enough for several heartbeats, like a slow scrape
+func slowStream(t *testing.T, format StreamFormat) string {
+	recorder := httptest.NewRecorder()
+	stream := NewStreamWriter(recorder, format)
+	stop := stream.StartHeartbeat(10 * time.Millisecond)
+	time.Sleep(80 * time.Millisecond)
+	if err := stream.WriteEntry(streamEntries[0]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stop()
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return recorder.Body.String()
+}
+
+func TestStreamWriter_Heartbeat(t *testing.T) {
+	body := slowStream(t, StreamNDJSON)
+	lines := strings.Split(strings.TrimSpace(strings.TrimSuffix(body, "\nStream complete.\n")), "\n")
+	if len(lines) < 3 || lines[0] != `{"type":"heartbeat"}` || lines[len(lines)-1] == `{"type":"heartbeat"}` {
+		t.Fatalf("Expected heartbeat lines before the entry, got %q", body)
+	}
+	for _, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("Expected every line to be JSON, got %q", line)
+		}
+	}
+
+	// Heartbeats are whitespace in the array, which parses as the entries alone
+	body = slowStream(t, StreamJSON)
+	var entries []interface{}
+	if err := json.Unmarshal([]byte(body), &entries); err != nil || len(entries) != 1 {
+		t.Errorf("Expected a JSON array of 1 entry, got %q (%v)", body, err)
+	}
+	if !strings.Contains(body, "[\n\n") {
+		t.Errorf("Expected heartbeats in the array, got %q", body)
+	}
+
+	body = slowStream(t, StreamSSE)
+	if !strings.HasPrefix(body, ": heartbeat\n\n: heartbeat\n\n") || !strings.Contains(body, "data: {") {
+		t.Errorf("Expected heartbeat comments before the data frame, got %q", body)
+	}
+
+	// Entries written often enough leave no room for a heartbeat, and a zero interval disables it
+	recorder := httptest.NewRecorder()
+	stream := NewStreamWriter(recorder, StreamNDJSON)
+	stop := stream.StartHeartbeat(100 * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		stream.WriteEntry(streamEntries[0])
+	}
+	stop()
+	NewStreamWriter(recorder, StreamNDJSON).StartHeartbeat(0)()
+	if strings.Contains(recorder.Body.String(), "heartbeat") {
+		t.Errorf("Expected no heartbeat between frequent entries, got %q", recorder.Body.String())
+	}
+}
+
+func TestStreamWriter_ContentType(t *testing.T) {
+	expected := map[StreamFormat]string{StreamNDJSON: "text/plain", StreamJSON: "application/json", StreamSSE: "text/event-stream"}
+	for format, contentType := range expected {
+		if result := NewStreamWriter(nil, format).ContentType(); result != contentType {
+			t.Errorf("Expected %s for %s, got %s", contentType, format, result)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"stockbackend/types"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ParseTableRows parses a screener result table into rows in their original order.
+// Rows whose label ends with "+" are expandable, the rows screener inserts below them
+// when expanded carry the "sub" class and are attached as children of that parent.
+func ParseTableRows(section *goquery.Selection, tableSelector string) []types.TableRow {
+	table := section.Find(tableSelector)
+	if table.Length() == 0 {
+		return nil
+	}
+
+	rows := []types.TableRow{}
+	parent := -1
+	table.Find("tbody tr").Each(func(i int, tr *goquery.Selection) {
+		row := types.TableRow{
+			Label:  strings.TrimSpace(tr.Find("td.text").Text()),
+			Values: []string{},
+		}
+		tr.Find("td").Each(func(i int, td *goquery.Selection) {
+			if i > 0 { // Skip the first column which is the row key
+				row.Values = append(row.Values, strings.TrimSpace(td.Text()))
+			}
+		})
+
+		if tr.HasClass("sub") && parent >= 0 {
+			rows[parent].Children = append(rows[parent].Children, row)
+			return
+		}
+
+		rows = append(rows, row)
+		if isExpandableLabel(row.Label) {
+			parent = len(rows) - 1
+		} else {
+			parent = -1
+		}
+	})
+
+	return rows
+}
+
+var (
+	// ErrSectionTableNotFound means the section is on the page but the table selector matched
+	// nothing, usually a sign the page layout changed
+	ErrSectionTableNotFound = errors.New("section table not found")
+	// ErrSectionNoDataRows means the table has no row with a label and a value, either the company
+	// has no data for the section or the rows are no longer laid out as expected
+	ErrSectionNoDataRows = errors.New("section table has no data rows")
+)
+
+// isDataRow reports whether a parsed row carries data: a label with a value, or with sub-rows
+func isDataRow(row types.TableRow) bool {
+	if row.Label == "" {
+		return false
+	}
+	for _, value := range row.Values {
+		if value != "" {
+			return true
+		}
+	}
+	return len(row.Children) > 0
+}
+
+// ParseSectionTable parses the result table of a section like ParseTableRows, dropping the rows
+// without a label. ErrSectionTableNotFound is returned when the table is missing and
+// ErrSectionNoDataRows, with the number of rows seen, when none of its rows carries data.
+func ParseSectionTable(section *goquery.Selection, tableSelector string) ([]types.TableRow, error) {
+	parsed := ParseTableRows(section, tableSelector)
+	if parsed == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSectionTableNotFound, tableSelector)
+	}
+
+	rows := []types.TableRow{}
+	dataRows := 0
+	for _, row := range parsed {
+		if row.Label == "" && len(row.Children) == 0 {
+			continue
+		}
+		if isDataRow(row) {
+			dataRows++
+		}
+		rows = append(rows, row)
+	}
+	if dataRows == 0 {
+		return nil, fmt.Errorf("%w: none of the %d rows has a label and a value", ErrSectionNoDataRows, len(parsed))
+	}
+	return rows, nil
+}
+
+// ParseTableHeaders returns the column headers of a screener result table (e.g. "Mar 2024", "TTM"),
+// without the header of the label column
+func ParseTableHeaders(section *goquery.Selection, tableSelector string) []string {
+	headers := []string{}
+	section.Find(tableSelector).Find("thead th").Each(func(i int, th *goquery.Selection) {
+		if i > 0 {
+			headers = append(headers, strings.TrimSpace(th.Text()))
+		}
+	})
+	return headers
+}
+
+// TableRowsToMap flattens ordered rows into the label keyed map used by older documents
+func TableRowsToMap(rows []types.TableRow) map[string]interface{} {
+	data := make(map[string]interface{})
+	for _, row := range rows {
+		data[row.Label] = row.Values
+		for key, value := range TableRowsToMap(row.Children) {
+			data[key] = value
+		}
+	}
+	return data
+}
+
+func isExpandableLabel(label string) bool {
+	return strings.HasSuffix(strings.TrimSpace(label), "+")
+}
+
+// toMap accepts the different map flavours a document can arrive in (scraped, decoded from Mongo or JSON)
+func toMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case bson.M:
+		return v, true
+	case primitive.M:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = value
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// toTableRows accepts ordered table rows either as parsed or as decoded from Mongo/JSON
+func toTableRows(value interface{}) ([]types.TableRow, bool) {
+	switch v := value.(type) {
+	case []types.TableRow:
+		return v, true
+	case primitive.A:
+		return toTableRows([]interface{}(v))
+	case []interface{}:
+		rows := make([]types.TableRow, 0, len(v))
+		for _, elem := range v {
+			m, ok := toMap(elem)
+			if !ok {
+				return nil, false
+			}
+			label, ok := m["label"].(string)
+			if !ok {
+				return nil, false
+			}
+			row := types.TableRow{Label: label}
+			if values, ok := toArray(m["values"]); ok {
+				for _, value := range values {
+					str, _ := value.(string)
+					row.Values = append(row.Values, str)
+				}
+			}
+			if children, ok := toTableRows(m["children"]); ok {
+				row.Children = children
+			}
+			rows = append(rows, row)
+		}
+		return rows, true
+	}
+	return nil, false
+}
+
+func toArray(value interface{}) (primitive.A, bool) {
+	switch v := value.(type) {
+	case primitive.A:
+		return v, true
+	case []interface{}:
+		return primitive.A(v), true
+	case []string:
+		arr := make(primitive.A, len(v))
+		for i, str := range v {
+			arr[i] = str
+		}
+		return arr, true
+	case []map[string]interface{}:
+		arr := make(primitive.A, len(v))
+		for i, m := range v {
+			arr[i] = m
+		}
+		return arr, true
+	case []map[string]string:
+		arr := make(primitive.A, len(v))
+		for i, m := range v {
+			arr[i] = m
+		}
+		return arr, true
+	}
+	return nil, false
+}
+
+// findTableRow finds a row by label, a non-breaking space and a regular space are considered equal
+func findTableRow(rows []types.TableRow, label string) (types.TableRow, bool) {
+	label = strings.ReplaceAll(label, "\u00a0", " ")
+	for _, row := range rows {
+		if strings.ReplaceAll(row.Label, "\u00a0", " ") == label {
+			return row, true
+		}
+	}
+	return types.TableRow{}, false
+}
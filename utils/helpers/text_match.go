@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// TextMatch is the stored company a text search picked for a holding with its text score.
+// Ambiguous is set when results tied on the top score and no tiebreaker told them apart, Tied then
+// lists the names of the tied companies and the first of them by name is picked.
+type TextMatch struct {
+	Company   bson.M
+	Score     float64
+	Ambiguous bool
+	Tied      []string
+}
+
+// textMatchTiebreakers tell apart the results tied on the top text score, in order: each keeps
+// the tied results it prefers, unless it prefers none of them
+var textMatchTiebreakers = []func(company bson.M, isin, name string) bool{
+	// The stored ISIN is the holding's
+	func(company bson.M, isin, name string) bool {
+		stored, _ := company["isin"].(string)
+		return isin != "" && strings.EqualFold(strings.TrimSpace(stored), isin)
+	},
+	// The stored name is the instrument name once normalized
+	func(company bson.M, isin, name string) bool {
+		stored, _ := company["name"].(string)
+		return NormalizeCompanyName(stored) == NormalizeCompanyName(name)
+	},
+	// The stored market cap is plausible, a stub without fundamentals has none
+	func(company bson.M, isin, name string) bool {
+		marketCap, err := toFloat(company["marketCap"])
+		return err == nil && marketCap > 0
+	},
+}
+
+// PickTextMatch picks the best of the text search results of a holding, sorted by descending
+// score. Results tied on the top score are told apart by textMatchTiebreakers; the pick is
+// deterministic, it doesn't depend on the order Mongo returned the tied results in.
+func PickTextMatch(results []bson.M, isin, name string) TextMatch {
+	if len(results) == 0 {
+		return TextMatch{}
+	}
+	score, _ := results[0]["score"].(float64)
+	tied := []bson.M{}
+	for _, result := range results {
+		if resultScore, _ := result["score"].(float64); math.Abs(resultScore-score) < 1e-9 {
+			tied = append(tied, result)
+		}
+	}
+
+	isin = strings.ToUpper(strings.TrimSpace(isin))
+	for _, prefers := range textMatchTiebreakers {
+		if len(tied) == 1 {
+			break
+		}
+		preferred := []bson.M{}
+		for _, result := range tied {
+			if prefers(result, isin, name) {
+				preferred = append(preferred, result)
+			}
+		}
+		if len(preferred) > 0 {
+			tied = preferred
+		}
+	}
+	if len(tied) == 1 {
+		return TextMatch{Company: tied[0], Score: score}
+	}
+
+	sort.Slice(tied, func(i, j int) bool {
+		first, _ := tied[i]["name"].(string)
+		second, _ := tied[j]["name"].(string)
+		return first < second
+	})
+	names := make([]string, len(tied))
+	for i, result := range tied {
+		names[i], _ = result["name"].(string)
+	}
+	return TextMatch{Company: tied[0], Score: score, Ambiguous: true, Tied: names}
+}
@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestPickTextMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		results    []bson.M
+		isin       string
+		instrument string
+		want       string
+		ambiguous  bool
+		tied       []string
+	}{
+		{
+			"single best",
+			[]bson.M{{"name": "Tata Motors", "score": 2.0}, {"name": "Tata Steel", "score": 1.5}},
+			"", "Tata Motors Limited", "Tata Motors", false, nil,
+		},
+		{
+			"tie broken by isin",
+			[]bson.M{
+				{"name": "Bharat Electronics", "score": 1.5, "isin": "INE263A01024", "marketCap": "2,00,000"},
+				{"name": "Bharat Forge", "score": 1.5, "isin": "INE465A01025", "marketCap": "60,000"},
+			},
+			"ine465a01025", "Bharat Forge Ltd", "Bharat Forge", false, nil,
+		},
+		{
+			"tie broken by normalized name",
+			[]bson.M{
+				{"name": "Indian Bank", "score": 1.5, "marketCap": "80,000"},
+				{"name": "Indian Hotels Co", "score": 1.5, "marketCap": "90,000"},
+			},
+			"", "Indian Bank Limited", "Indian Bank", false, nil,
+		},
+		{
+			"tie broken by market cap",
+			[]bson.M{
+				{"name": "Reliance Industrial Infra", "score": 1.1},
+				{"name": "Reliance Industries", "score": 1.1, "marketCap": "19,00,000"},
+			},
+			"", "Reliance", "Reliance Industries", false, nil,
+		},
+		{
+			"isin mismatch falls through to the next tiebreaker",
+			[]bson.M{
+				{"name": "Indian Hotels Co", "score": 1.5, "isin": "INE053A01029", "marketCap": "90,000"},
+				{"name": "Indian Bank", "score": 1.5, "isin": "INE562A01011", "marketCap": "80,000"},
+			},
+			"INE000000000", "Indian Bank", "Indian Bank", false, nil,
+		},
+		{
+			"unresolved tie",
+			[]bson.M{
+				{"name": "Tata Steel", "score": 1.2, "marketCap": "1,80,000"},
+				{"name": "Tata Power", "score": 1.2, "marketCap": "1,20,000"},
+				{"name": "Tata Chemicals", "score": 0.8, "marketCap": "25,000"},
+			},
+			"", "Tata", "Tata Power", true, []string{"Tata Power", "Tata Steel"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := PickTextMatch(tt.results, tt.isin, tt.instrument)
+			if name, _ := match.Company["name"].(string); name != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, name)
+			}
+			if match.Ambiguous != tt.ambiguous || !reflect.DeepEqual(match.Tied, tt.tied) {
+				t.Errorf("Expected ambiguous %v %v, got %v %v", tt.ambiguous, tt.tied, match.Ambiguous, match.Tied)
+			}
+			if want, _ := tt.results[0]["score"].(float64); match.Score != want {
+				t.Errorf("Expected the top score %v, got %v", want, match.Score)
+			}
+		})
+	}
+}
+
+func TestPickTextMatch_OrderIndependent(t *testing.T) {
+	first := bson.M{"name": "Tata Steel", "score": 1.2}
+	second := bson.M{"name": "Tata Power", "score": 1.2}
+	forward := PickTextMatch([]bson.M{first, second}, "", "Tata")
+	backward := PickTextMatch([]bson.M{second, first}, "", "Tata")
+	if forward.Company["name"] != backward.Company["name"] || !reflect.DeepEqual(forward.Tied, backward.Tied) {
+		t.Errorf("Expected the same pick whatever the order, got %v and %v", forward.Company["name"], backward.Company["name"])
+	}
+
+	if match := PickTextMatch(nil, "", "Tata"); match.Company != nil || match.Ambiguous {
+		t.Errorf("Expected no match without results, got %+v", match)
+	}
+}
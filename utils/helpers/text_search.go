@@ -0,0 +1,35 @@
+package helpers
+
+import (
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// textSearchOperators are the characters $text search gives a meaning to: quotes start a phrase
+// search and a leading minus negates a term, so they are replaced with plain word separators
+var textSearchOperators = strings.NewReplacer(
+	`"`, " ",
+	"\u201c", " ",
+	"\u201d", " ",
+	"-", " ",
+	"\u2013", " ",
+	"\u2014", " ",
+	`\`, " ",
+)
+
+// SanitizeTextSearch turns an instrument name into a $text search string matching its words,
+// e.g. `"XYZ-Ltd"` becomes `XYZ Ltd` instead of a phrase search, and `-ABC` searches for ABC
+// instead of excluding it
+func SanitizeTextSearch(query string) string {
+	return strings.Join(strings.Fields(textSearchOperators.Replace(query)), " ")
+}
+
+// TextSearchFilter builds the $text filter for an instrument name
+func TextSearchFilter(query string) bson.M {
+	return bson.M{
+		"$text": bson.M{
+			"$search": SanitizeTextSearch(query),
+		},
+	}
+}
@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestSanitizeTextSearch(t *testing.T) {
+	tests := map[string]string{
+		"Tata Consultancy Services Ltd ":    "Tata Consultancy Services Ltd",
+		"XYZ-Ltd":                           "XYZ Ltd",
+		"-ABC Industries":                   "ABC Industries",
+		"Bajaj Finance - Ltd":               "Bajaj Finance Ltd",
+		`"Quoted" Holdings Ltd`:             "Quoted Holdings Ltd",
+		`Stray " Quote Ltd`:                 "Stray Quote Ltd",
+		"\u201cSmart\u201d Quotes Ltd":      "Smart Quotes Ltd",
+		"Dr. Reddy's Laboratories Ltd":      "Dr. Reddy's Laboratories Ltd",
+		"Larsen & Toubro Ltd":               "Larsen & Toubro Ltd",
+		`Back\slash Ltd`:                    "Back slash Ltd",
+		"  --  ":                            "",
+		"Mahindra \u2013 Mahindra Fin. Ltd": "Mahindra Mahindra Fin. Ltd",
+	}
+	for input, expected := range tests {
+		if result := SanitizeTextSearch(input); result != expected {
+			t.Errorf("SanitizeTextSearch(%q): expected %q, got %q", input, expected, result)
+		}
+	}
+}
+
+func TestTextSearchFilter(t *testing.T) {
+	expected := bson.M{"$text": bson.M{"$search": "XYZ Ltd"}}
+	if filter := TextSearchFilter(`-"XYZ-Ltd"`); !reflect.DeepEqual(filter, expected) {
+		t.Errorf("Expected %v, got %v", expected, filter)
+	}
+}
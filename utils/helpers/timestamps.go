@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	writeTimeMu   sync.Mutex
+	lastWriteTime time.Time
+)
+
+// NextWriteTime returns the timestamp for a document write. Mongo stores dates with millisecond
+// precision, so the value is truncated to milliseconds and bumped past the previous one to keep
+// the timestamps of consecutive writes strictly increasing.
+func NextWriteTime() time.Time {
+	writeTimeMu.Lock()
+	defer writeTimeMu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if !now.After(lastWriteTime) {
+		now = lastWriteTime.Add(time.Millisecond)
+	}
+	lastWriteTime = now
+	return now
+}
+
+// StampScraped marks an update of scraped fundamentals with lastScraped and lastUpdated
+func StampScraped(fields bson.M) bson.M {
+	now := NextWriteTime()
+	fields["lastScraped"] = now
+	fields["lastUpdated"] = now
+	return fields
+}
+
+// StampScored marks an update of computed scores (stockRate, fScore) with lastScored and lastUpdated,
+// and the hash of the scoring config they were computed with in scoringConfig
+func StampScored(fields bson.M) bson.M {
+	now := NextWriteTime()
+	fields["lastScored"] = now
+	fields["lastUpdated"] = now
+	fields["scoringConfig"] = CurrentScoringConfig().Hash()
+	return fields
+}
@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestStampScrapedAndScored(t *testing.T) {
+	scraped := StampScraped(bson.M{"marketCap": "1,070"})
+	scored := StampScored(bson.M{"stockRate": 12.5})
+
+	lastScraped, ok := scraped["lastScraped"].(time.Time)
+	if !ok || scraped["lastUpdated"] != lastScraped {
+		t.Fatalf("Expected lastScraped and lastUpdated to be written, got %v", scraped)
+	}
+	if _, ok := scraped["lastScored"]; ok {
+		t.Errorf("Expected a scrape not to touch lastScored, got %v", scraped)
+	}
+
+	lastScored, ok := scored["lastScored"].(time.Time)
+	if !ok || scored["lastUpdated"] != lastScored {
+		t.Fatalf("Expected lastScored and lastUpdated to be written, got %v", scored)
+	}
+	if !lastScored.After(lastScraped) {
+		t.Errorf("Expected the later write to have a later timestamp, got %v and %v", lastScraped, lastScored)
+	}
+}
+
+func TestNextWriteTime_Monotonic(t *testing.T) {
+	previous := NextWriteTime()
+	for i := 0; i < 1000; i++ {
+		next := NextWriteTime()
+		if !next.After(previous) {
+			t.Fatalf("Expected strictly increasing timestamps, got %v after %v", next, previous)
+		}
+		if next.Truncate(time.Millisecond) != next {
+			t.Fatalf("Expected millisecond precision, got %v", next)
+		}
+		previous = next
+	}
+}
@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"strings"
+)
+
+// defaultTrendMetricDirections lists the quarterly metrics used for trend analysis and whether an
+// increase is good (1) or bad (-1). Metrics not listed (other income, tax %, raw PDF links...) are ignored.
+var defaultTrendMetricDirections = map[string]float64{
+	"sales":              1,
+	"revenue":            1,
+	"operating profit":   1,
+	"financing profit":   1,
+	"opm %":              1,
+	"financing margin %": 1,
+	"profit before tax":  1,
+	"net profit":         1,
+	"eps in rs":          1,
+	"expenses":           -1,
+	"interest":           -1,
+	"borrowings":         -1,
+}
+
+// NormalizeMetricName strips the expandable "+" marker and casing from a table row label
+func NormalizeMetricName(metric string) string {
+	metric = strings.ReplaceAll(metric, "\u00a0", " ")
+	metric = strings.TrimSuffix(strings.TrimSpace(metric), "+")
+	return strings.Join(strings.Fields(strings.ToLower(metric)), " ")
+}
+
+// TrendMetricDirections returns the metric directions used by AnalyzeTrend. TREND_METRIC_DIRECTIONS
+// can hold a JSON object (e.g. {"Sales": 1, "Expenses": -1, "Depreciation": 0}) overriding the defaults.
+func TrendMetricDirections() map[string]float64 {
+	directions := make(map[string]float64, len(defaultTrendMetricDirections))
+	for metric, direction := range defaultTrendMetricDirections {
+		directions[metric] = direction
+	}
+
+	for metric, direction := range config.Get().TrendMetricDirections {
+		directions[NormalizeMetricName(metric)] = direction
+	}
+	return directions
+}
+
+// isTTMQuarter reports whether a quarterly cell ({"TTM": "1,234"}) is the trailing twelve months
+func isTTMQuarter(quarter interface{}) bool {
+	cells, ok := toMap(quarter)
+	if !ok {
+		return false
+	}
+	for period := range cells {
+		if strings.EqualFold(strings.TrimSpace(period), "TTM") {
+			return true
+		}
+	}
+	return false
+}
+
+// quarterSeries turns a quarterly row ([{"Mar 2024": "1,234"}, ...]) into its values in order,
+// with nil for cells that are not numeric. A trailing TTM cell is left out when TREND_EXCLUDE_TTM
+// is set, the latest quarter is then compared with the one before it rather than with a partial period.
+func quarterSeries(quarterData interface{}) []*float64 {
+	quarters, ok := toArray(quarterData)
+	if !ok {
+		return nil
+	}
+	if len(quarters) > 0 && config.Get().TrendExcludeTTM && isTTMQuarter(quarters[len(quarters)-1]) {
+		quarters = quarters[:len(quarters)-1]
+	}
+
+	series := make([]*float64, 0, len(quarters))
+	for _, quarter := range quarters {
+		var value *float64
+		if cells, ok := toMap(quarter); ok {
+			for _, cell := range cells {
+				if str, ok := cell.(string); ok {
+					if f, err := ParseNumber(strings.ReplaceAll(str, "%", ""), CurrentNumberFormat()); err == nil {
+						value = &f
+					}
+				}
+			}
+		}
+		series = append(series, value)
+	}
+	return series
+}
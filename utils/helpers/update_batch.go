@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BulkWriter is the part of a collection an UpdateBatch writes through, *mongo.Collection implements it
+type BulkWriter interface {
+	BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
+}
+
+// UpdateBatch accumulates UpdateOne operations and writes them with one unordered BulkWrite
+// per size updates. Every update is labelled (e.g. with the company name) so the failed
+// ones can be reported. It is not safe for concurrent use.
+type UpdateBatch struct {
+	collection BulkWriter
+	size       int
+	models     []mongo.WriteModel
+	labels     []string
+}
+
+// NewUpdateBatch creates a batch flushing every size updates, sizes below 1 flush every update
+func NewUpdateBatch(collection BulkWriter, size int) *UpdateBatch {
+	if size < 1 {
+		size = 1
+	}
+	return &UpdateBatch{collection: collection, size: size}
+}
+
+// Add queues an update, flushing the batch once it is full. The failed labels and error are
+// those of that flush.
+func (b *UpdateBatch) Add(ctx context.Context, label string, filter interface{}, update interface{}) ([]string, error) {
+	b.models = append(b.models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update))
+	b.labels = append(b.labels, label)
+	if len(b.models) < b.size {
+		return nil, nil
+	}
+	return b.Flush(ctx)
+}
+
+// Pending returns the number of queued updates
+func (b *UpdateBatch) Pending() int {
+	return len(b.models)
+}
+
+// Flush writes the queued updates, returning the labels of the updates that failed. Without
+// per-update errors (e.g. the connection failed) every update of the batch is reported failed.
+func (b *UpdateBatch) Flush(ctx context.Context) ([]string, error) {
+	if len(b.models) == 0 {
+		return nil, nil
+	}
+	models, labels := b.models, b.labels
+	b.models, b.labels = nil, nil
+
+	_, err := b.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err == nil {
+		return nil, nil
+	}
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 {
+		failed := make([]string, 0, len(bulkErr.WriteErrors))
+		for _, writeErr := range bulkErr.WriteErrors {
+			if writeErr.Index >= 0 && writeErr.Index < len(labels) {
+				failed = append(failed, labels[writeErr.Index])
+			}
+		}
+		return failed, err
+	}
+	return labels, err
+}
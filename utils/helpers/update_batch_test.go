@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// recordingCollection keeps the filters of every bulk write, failing the indices in failIndices
+type recordingCollection struct {
+	writes      [][]interface{}
+	failIndices []int
+	err         error
+}
+
+func (c *recordingCollection) BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	filters := []interface{}{}
+	for _, model := range models {
+		filters = append(filters, model.(*mongo.UpdateOneModel).Filter)
+	}
+	c.writes = append(c.writes, filters)
+	if c.err != nil {
+		return nil, c.err
+	}
+	if len(c.failIndices) > 0 {
+		exception := mongo.BulkWriteException{}
+		for _, index := range c.failIndices {
+			exception.WriteErrors = append(exception.WriteErrors, mongo.BulkWriteError{WriteError: mongo.WriteError{Index: index, Message: "failed"}})
+		}
+		return nil, exception
+	}
+	return &mongo.BulkWriteResult{ModifiedCount: int64(len(models))}, nil
+}
+
+func TestUpdateBatch_FlushesRemainder(t *testing.T) {
+	collection := &recordingCollection{}
+	batch := NewUpdateBatch(collection, 3)
+	for i := 0; i < 7; i++ {
+		if _, err := batch.Add(context.Background(), fmt.Sprint(i), bson.M{"_id": i}, bson.M{"$set": bson.M{"stockRate": i}}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if len(collection.writes) != 2 || batch.Pending() != 1 {
+		t.Fatalf("Expected two full batches and one pending update, got %d writes and %d pending", len(collection.writes), batch.Pending())
+	}
+	if _, err := batch.Flush(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	written := []interface{}{}
+	for _, write := range collection.writes {
+		written = append(written, write...)
+	}
+	if len(written) != 7 || !reflect.DeepEqual(written[6], bson.M{"_id": 6}) {
+		t.Errorf("Expected all 7 updates to land, got %v", written)
+	}
+	if batch.Pending() != 0 {
+		t.Errorf("Expected nothing pending after the flush")
+	}
+	if _, err := batch.Flush(context.Background()); err != nil || len(collection.writes) != 3 {
+		t.Errorf("Expected an empty flush not to write")
+	}
+}
+
+func TestUpdateBatch_ReportsFailedLabels(t *testing.T) {
+	collection := &recordingCollection{failIndices: []int{1}}
+	batch := NewUpdateBatch(collection, 2)
+	batch.Add(context.Background(), "Infosys", bson.M{"name": "Infosys"}, bson.M{})
+	failed, err := batch.Add(context.Background(), "TCS", bson.M{"name": "TCS"}, bson.M{})
+	if err == nil || !reflect.DeepEqual(failed, []string{"TCS"}) {
+		t.Errorf("Expected only TCS to fail, got %v (%v)", failed, err)
+	}
+
+	collection = &recordingCollection{err: errors.New("connection reset")}
+	batch = NewUpdateBatch(collection, 10)
+	batch.Add(context.Background(), "Infosys", bson.M{"name": "Infosys"}, bson.M{})
+	batch.Add(context.Background(), "TCS", bson.M{"name": "TCS"}, bson.M{})
+	if failed, err := batch.Flush(context.Background()); err == nil || !reflect.DeepEqual(failed, []string{"Infosys", "TCS"}) {
+		t.Errorf("Expected the whole batch to fail, got %v (%v)", failed, err)
+	}
+}
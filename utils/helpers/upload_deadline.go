@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"stockbackend/types"
+	"time"
+)
+
+// Statuses of the backlog of an upload, the holdings it left past its deadline
+const (
+	BacklogQueued   = "queued"
+	BacklogSkipped  = "skipped"
+	BacklogFinished = "finished"
+)
+
+// UploadDeadline is the time budget of a whole upload, the zero value has none
+type UploadDeadline struct {
+	at time.Time
+}
+
+// NewUploadDeadline starts the budget of an upload, the configured one (UPLOAD_DEADLINE_SECONDS), none when 0
+func NewUploadDeadline(start time.Time, configured time.Duration) UploadDeadline {
+	if configured <= 0 {
+		return UploadDeadline{}
+	}
+	return UploadDeadline{at: start.Add(configured)}
+}
+
+// Exceeded reports whether the budget is spent at now, never without a deadline
+func (d UploadDeadline) Exceeded(now time.Time) bool {
+	return !d.at.IsZero() && !now.Before(d.at)
+}
+
+// UploadBacklog keeps the holdings an upload left unenriched once its deadline passed, with the file
+// and sheet they were read from, so they can be enriched in the background
+type UploadBacklog struct {
+	Rows []types.BacklogRow
+}
+
+func NewUploadBacklog() *UploadBacklog {
+	return &UploadBacklog{Rows: []types.BacklogRow{}}
+}
+
+// Add records a holding left unprocessed
+func (b *UploadBacklog) Add(file, sheet string, holding map[string]interface{}) {
+	b.Rows = append(b.Rows, types.BacklogRow{File: file, Sheet: sheet, Holding: holding})
+}
+
+// Record returns the backlog to store on the upload record with its status
+func (b *UploadBacklog) Record(status string) *types.UploadBacklog {
+	return &types.UploadBacklog{Status: status, Pending: b.Rows, Holdings: []map[string]interface{}{}}
+}
+
+// AddTo reports the backlog and its status on the summary entry of the upload
+func (b *UploadBacklog) AddTo(entry map[string]interface{}, status string) {
+	entry["deadlineExceeded"] = true
+	entry["unprocessedRows"] = len(b.Rows)
+	entry["backlog"] = status
+}
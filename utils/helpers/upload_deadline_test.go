@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewUploadDeadline(t *testing.T) {
+	start := time.Date(2024, 9, 30, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		configured time.Duration
+	}{
+		{"none", 0},
+		{"configured", time.Minute},
+		{"fraction of a second", 1500 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		deadline := NewUploadDeadline(start, test.configured)
+		if test.configured == 0 {
+			if deadline.Exceeded(start.Add(24 * time.Hour)) {
+				t.Errorf("%s: expected no deadline", test.name)
+			}
+			continue
+		}
+		if deadline.Exceeded(start.Add(test.configured - time.Millisecond)) {
+			t.Errorf("%s: expected the deadline not to be exceeded before %v", test.name, test.configured)
+		}
+		if !deadline.Exceeded(start.Add(test.configured)) {
+			t.Errorf("%s: expected the deadline to be exceeded at %v", test.name, test.configured)
+		}
+	}
+}
+
+func TestUploadBacklog_Record(t *testing.T) {
+	backlog := NewUploadBacklog()
+	backlog.Add("first.xlsx", "Sheet1", map[string]interface{}{"Name of the Instrument": "Infosys Limited"})
+	backlog.Add("second.xlsx", "Equity", map[string]interface{}{"Name of the Instrument": "HDFC Bank Limited"})
+
+	record := backlog.Record(BacklogQueued)
+	if record.Status != BacklogQueued || len(record.Pending) != 2 || record.Pending[1].File != "second.xlsx" || record.Pending[1].Sheet != "Equity" {
+		t.Errorf("Unexpected backlog record: %+v", record)
+	}
+
+	outcomes := NewUploadOutcomes()
+	outcomes.Processed("first.xlsx")
+	outcomes.Unprocessed("second.xlsx")
+	entry := outcomes.Entry()
+	backlog.AddTo(entry, BacklogQueued)
+	if entry["deadlineExceeded"] != true || entry["unprocessedRows"] != 2 || entry["backlog"] != BacklogQueued || entry["unprocessed"] != 1 || entry["processed"] != 1 {
+		t.Errorf("Unexpected upload summary: %v", entry)
+	}
+}
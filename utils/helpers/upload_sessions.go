@@ -0,0 +1,274 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+	ErrUploadIncomplete      = errors.New("upload is not complete")
+	ErrInvalidContentRange   = errors.New("invalid Content-Range")
+	ErrTooManyUploadSessions = errors.New("too many open upload sessions")
+)
+
+var contentRangePattern = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+)$`)
+
+// ContentRange is a parsed "Content-Range: bytes start-end/total" header, End is inclusive
+type ContentRange struct {
+	Start int64
+	End   int64
+	Total int64
+}
+
+// Length is the number of bytes in the range
+func (r ContentRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// ParseContentRange parses the Content-Range of a chunk, the total size has to be known
+func ParseContentRange(header string) (ContentRange, error) {
+	match := contentRangePattern.FindStringSubmatch(header)
+	if match == nil {
+		return ContentRange{}, fmt.Errorf("%w: %q, expected bytes start-end/total", ErrInvalidContentRange, header)
+	}
+	var values [3]int64
+	for i, raw := range match[1:] {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return ContentRange{}, fmt.Errorf("%w: %q", ErrInvalidContentRange, header)
+		}
+		values[i] = value
+	}
+	r := ContentRange{Start: values[0], End: values[1], Total: values[2]}
+	if r.Start > r.End || r.End >= r.Total {
+		return ContentRange{}, fmt.Errorf("%w: %q, the range is outside the file", ErrInvalidContentRange, header)
+	}
+	return r, nil
+}
+
+// ByteRanges are the received byte ranges of a file, sorted and merged, both ends inclusive
+type ByteRanges [][2]int64
+
+// Add returns the ranges with start-end added, merging it with the ranges it overlaps or touches
+func (r ByteRanges) Add(start, end int64) ByteRanges {
+	merged := ByteRanges{}
+	inserted := false
+	for _, current := range r {
+		switch {
+		case current[1]+1 < start:
+			merged = append(merged, current)
+		case end+1 < current[0]:
+			if !inserted {
+				merged = append(merged, [2]int64{start, end})
+				inserted = true
+			}
+			merged = append(merged, current)
+		default:
+			start, end = min(start, current[0]), max(end, current[1])
+		}
+	}
+	if !inserted {
+		merged = append(merged, [2]int64{start, end})
+	}
+	return merged
+}
+
+// Covers reports whether the ranges hold every byte of a file of the given size
+func (r ByteRanges) Covers(size int64) bool {
+	return size > 0 && len(r) == 1 && r[0][0] == 0 && r[0][1] == size-1
+}
+
+// UploadSessionStatus tells a client which parts of its file were received, so it only resends the others
+type UploadSessionStatus struct {
+	ID       string     `json:"sessionId"`
+	Filename string     `json:"filename"`
+	Size     int64      `json:"size"`
+	Received ByteRanges `json:"received"`
+	Complete bool       `json:"complete"`
+}
+
+type uploadSession struct {
+	mu       sync.Mutex
+	filename string
+	// Size is unknown (0) until the first chunk tells the total
+	size     int64
+	received ByteRanges
+	touched  time.Time
+	// Set once the session was taken or dropped, a chunk arriving afterwards mustn't write its file
+	ended bool
+}
+
+// UploadSessionStore assembles files uploaded in chunks, each session writes its file into its own
+// directory under dir. Sessions without a chunk for ttl are dropped with their file, at most
+// maxSessions are open at once.
+type UploadSessionStore struct {
+	dir         string
+	ttl         time.Duration
+	maxSessions int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*uploadSession
+}
+
+func NewUploadSessionStore(dir string, ttl time.Duration, maxSessions int) *UploadSessionStore {
+	return &UploadSessionStore{dir: dir, ttl: ttl, maxSessions: maxSessions, now: time.Now, sessions: map[string]*uploadSession{}}
+}
+
+func (s *UploadSessionStore) path(id string, session *uploadSession) string {
+	return filepath.Join(s.dir, id, session.filename)
+}
+
+// status must be called with the session locked
+func (s *UploadSessionStore) status(id string, session *uploadSession) UploadSessionStatus {
+	return UploadSessionStatus{
+		ID:       id,
+		Filename: session.filename,
+		Size:     session.size,
+		Received: append(ByteRanges{}, session.received...),
+		Complete: session.received.Covers(session.size),
+	}
+}
+
+// removeExpired drops the idle sessions, s.mu must be held
+func (s *UploadSessionStore) removeExpired() {
+	for id, session := range s.sessions {
+		// A session busy writing a chunk is not idle
+		if !session.mu.TryLock() {
+			continue
+		}
+		expired := s.now().Sub(session.touched) > s.ttl
+		session.ended = session.ended || expired
+		session.mu.Unlock()
+		if expired {
+			delete(s.sessions, id)
+			os.RemoveAll(filepath.Join(s.dir, id))
+		}
+	}
+}
+
+func (s *UploadSessionStore) session(id string) (*uploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeExpired()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrUploadSessionNotFound
+	}
+	return session, nil
+}
+
+// Create starts a session for a file, its chunks are then sent to WriteChunk. ErrTooManyUploadSessions
+// is returned while maxSessions are open.
+func (s *UploadSessionStore) Create(filename string) (UploadSessionStatus, error) {
+	filename = filepath.Base(filename)
+	if filename == "." || filename == string(filepath.Separator) {
+		return UploadSessionStatus{}, errors.New("a filename is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeExpired()
+	if len(s.sessions) >= s.maxSessions {
+		return UploadSessionStatus{}, fmt.Errorf("%w, at most %d", ErrTooManyUploadSessions, s.maxSessions)
+	}
+
+	id := uuid.New().String()
+	if err := os.MkdirAll(filepath.Join(s.dir, id), os.ModePerm); err != nil {
+		return UploadSessionStatus{}, fmt.Errorf("error creating upload session: %w", err)
+	}
+	session := &uploadSession{filename: filename, touched: s.now()}
+	s.sessions[id] = session
+	return s.status(id, session), nil
+}
+
+// WriteChunk writes the bytes of the range read from the body at their offset, chunks may arrive
+// in any order and be sent again. When the body ends early the bytes read are still recorded and
+// io.ErrUnexpectedEOF is returned with the status, so the client resumes from what was received.
+func (s *UploadSessionStore) WriteChunk(id string, r ContentRange, body io.Reader) (UploadSessionStatus, error) {
+	session, err := s.session(id)
+	if err != nil {
+		return UploadSessionStatus{}, err
+	}
+	return s.writeChunk(id, session, r, body)
+}
+
+// writeChunk writes a chunk of the session looked up, which may have been taken since
+func (s *UploadSessionStore) writeChunk(id string, session *uploadSession, r ContentRange, body io.Reader) (UploadSessionStatus, error) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	// Taken or dropped while the chunk waited for the lock, its file may be parsed or removed already
+	if session.ended {
+		return UploadSessionStatus{}, ErrUploadSessionNotFound
+	}
+
+	if session.size != 0 && session.size != r.Total {
+		return s.status(id, session), fmt.Errorf("%w: the total size changed from %d to %d", ErrInvalidContentRange, session.size, r.Total)
+	}
+
+	file, err := os.OpenFile(s.path(id, session), os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return s.status(id, session), fmt.Errorf("error opening upload session file: %w", err)
+	}
+	written, copyErr := io.Copy(io.NewOffsetWriter(file, r.Start), io.LimitReader(body, r.Length()))
+	if err := file.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	session.size = r.Total
+	session.touched = s.now()
+	if written > 0 {
+		session.received = session.received.Add(r.Start, r.Start+written-1)
+	}
+	if copyErr != nil {
+		return s.status(id, session), fmt.Errorf("error writing chunk: %w", copyErr)
+	}
+	if written < r.Length() {
+		return s.status(id, session), io.ErrUnexpectedEOF
+	}
+	return s.status(id, session), nil
+}
+
+// Status returns the received ranges of a session
+func (s *UploadSessionStore) Status(id string) (UploadSessionStatus, error) {
+	session, err := s.session(id)
+	if err != nil {
+		return UploadSessionStatus{}, err
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return s.status(id, session), nil
+}
+
+// Take ends a complete session and returns the path of its assembled file, the caller removes
+// the file and its directory once done. ErrUploadIncomplete is returned, with the status, while
+// bytes are missing.
+func (s *UploadSessionStore) Take(id string) (string, UploadSessionStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeExpired()
+	session, ok := s.sessions[id]
+	if !ok {
+		return "", UploadSessionStatus{}, ErrUploadSessionNotFound
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	status := s.status(id, session)
+	if !status.Complete {
+		return "", status, ErrUploadIncomplete
+	}
+	session.ended = true
+	delete(s.sessions, id)
+	return s.path(id, session), status, nil
+}
@@ -0,0 +1,168 @@
+package helpers
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseContentRange(t *testing.T) {
+	r, err := ParseContentRange("bytes 10-19/100")
+	if err != nil || r != (ContentRange{Start: 10, End: 19, Total: 100}) || r.Length() != 10 {
+		t.Errorf("Unexpected range %+v (%v)", r, err)
+	}
+	for _, header := range []string{"", "bytes 0-9/*", "bytes */100", "bytes 10-5/100", "bytes 0-100/100", "items 0-9/100"} {
+		if _, err := ParseContentRange(header); !errors.Is(err, ErrInvalidContentRange) {
+			t.Errorf("Expected %q to be rejected, got %v", header, err)
+		}
+	}
+}
+
+func TestByteRanges_Add(t *testing.T) {
+	var ranges ByteRanges
+	ranges = ranges.Add(20, 29)
+	ranges = ranges.Add(0, 9)
+	if !reflect.DeepEqual(ranges, ByteRanges{{0, 9}, {20, 29}}) {
+		t.Errorf("Expected two sorted ranges, got %v", ranges)
+	}
+	ranges = ranges.Add(10, 19)
+	if !reflect.DeepEqual(ranges, ByteRanges{{0, 29}}) || !ranges.Covers(30) {
+		t.Errorf("Expected the touching ranges to merge, got %v", ranges)
+	}
+	if ranges.Add(5, 40); ranges.Covers(41) {
+		t.Errorf("Expected Add not to modify the ranges in place")
+	}
+	if ranges.Covers(31) {
+		t.Errorf("Expected a missing last byte not to be covered")
+	}
+}
+
+func TestUploadSessionStore_OutOfOrderChunks(t *testing.T) {
+	store := NewUploadSessionStore(t.TempDir(), time.Hour, 10)
+	content := "0123456789abcdefghij"
+
+	session, err := store.Create("../holdings.xlsx")
+	if err != nil {
+		t.Fatalf("Error creating session: %v", err)
+	}
+	if session.Filename != "holdings.xlsx" {
+		t.Errorf("Expected the directories to be dropped from the filename, got %q", session.Filename)
+	}
+
+	for _, r := range []ContentRange{{Start: 15, End: 19, Total: 20}, {Start: 0, End: 4, Total: 20}, {Start: 5, End: 14, Total: 20}} {
+		if _, _, err := store.Take(session.ID); !errors.Is(err, ErrUploadIncomplete) {
+			t.Errorf("Expected the incomplete upload not to be taken, got %v", err)
+		}
+		if _, err := store.WriteChunk(session.ID, r, strings.NewReader(content[r.Start:r.End+1])); err != nil {
+			t.Fatalf("Error writing chunk %+v: %v", r, err)
+		}
+	}
+
+	path, status, err := store.Take(session.ID)
+	if err != nil || !status.Complete {
+		t.Fatalf("Expected the upload to be complete, got %+v (%v)", status, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("Expected the chunks assembled in order, got %q", data)
+	}
+	if _, err := store.Status(session.ID); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("Expected the taken session to be gone, got %v", err)
+	}
+}
+
+func TestUploadSessionStore_ResumedChunk(t *testing.T) {
+	store := NewUploadSessionStore(t.TempDir(), time.Hour, 10)
+	content := "0123456789"
+	session, _ := store.Create("holdings.xlsx")
+
+	// The connection drops after 4 of the 10 bytes
+	status, err := store.WriteChunk(session.ID, ContentRange{Start: 0, End: 9, Total: 10}, io.LimitReader(strings.NewReader(content), 4))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected the short chunk to be reported, got %v", err)
+	}
+	if !reflect.DeepEqual(status.Received, ByteRanges{{0, 3}}) || status.Complete {
+		t.Errorf("Expected the bytes read to be kept, got %+v", status)
+	}
+
+	// The client resumes from the end of the received range
+	if _, err := store.WriteChunk(session.ID, ContentRange{Start: 4, End: 9, Total: 10}, strings.NewReader(content[4:])); err != nil {
+		t.Fatalf("Error resuming: %v", err)
+	}
+	if _, err := store.WriteChunk(session.ID, ContentRange{Start: 0, End: 4, Total: 11}, strings.NewReader(content[:5])); !errors.Is(err, ErrInvalidContentRange) {
+		t.Errorf("Expected a changed total to be rejected, got %v", err)
+	}
+
+	path, _, err := store.Take(session.ID)
+	if err != nil {
+		t.Fatalf("Expected the upload to be complete: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("Expected %q, got %q", content, data)
+	}
+}
+
+func TestUploadSessionStore_Expiry(t *testing.T) {
+	dir := t.TempDir()
+	store := NewUploadSessionStore(dir, time.Minute, 10)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	session, _ := store.Create("holdings.xlsx")
+	store.WriteChunk(session.ID, ContentRange{Start: 0, End: 0, Total: 2}, strings.NewReader("0"))
+
+	now = now.Add(2 * time.Minute)
+	if _, err := store.Status(session.ID); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("Expected the idle session to expire, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected the expired session's file to be removed, got %v", entries)
+	}
+}
+
+func TestUploadSessionStore_MaxSessions(t *testing.T) {
+	dir := t.TempDir()
+	store := NewUploadSessionStore(dir, time.Minute, 2)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	store.Create("first.xlsx")
+	store.Create("second.xlsx")
+	if _, err := store.Create("third.xlsx"); !errors.Is(err, ErrTooManyUploadSessions) {
+		t.Errorf("Expected a third session to be refused, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Expected no directory for the refused session, got %d", len(entries))
+	}
+
+	// Expired sessions free their place
+	now = now.Add(2 * time.Minute)
+	if _, err := store.Create("third.xlsx"); err != nil {
+		t.Errorf("Expected a session once the others expired, got %v", err)
+	}
+}
+
+func TestUploadSessionStore_ChunkAfterTake(t *testing.T) {
+	store := NewUploadSessionStore(t.TempDir(), time.Hour, 10)
+	created, _ := store.Create("holdings.xlsx")
+	store.WriteChunk(created.ID, ContentRange{Start: 0, End: 3, Total: 4}, strings.NewReader("0123"))
+
+	// A chunk found the session, then the session was taken before the chunk got its lock
+	session, err := store.session(created.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	path, _, err := store.Take(created.ID)
+	if err != nil {
+		t.Fatalf("Expected the upload to be complete: %v", err)
+	}
+	if _, err := store.writeChunk(created.ID, session, ContentRange{Start: 0, End: 3, Total: 4}, strings.NewReader("abcd")); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("Expected the chunk to find the session gone, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "0123" {
+		t.Errorf("Expected the taken file to be left alone, got %q", data)
+	}
+}
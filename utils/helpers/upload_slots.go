@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"stockbackend/config"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrUploadSlotsFull = errors.New("too many uploads in progress")
+
+// UploadSlots bounds the number of uploads processed at once, the uploads over the limit wait in
+// a queue for a slot to free up
+type UploadSlots struct {
+	slots  chan struct{}
+	queued atomic.Int64
+}
+
+// UploadSlotsStats is the state of the upload slots reported by the health endpoint
+type UploadSlotsStats struct {
+	Limit  int   `json:"limit"`
+	Active int   `json:"active"`
+	Queued int64 `json:"queued"`
+}
+
+var (
+	uploadSlotsMu sync.Mutex
+	uploadSlots   *UploadSlots
+)
+
+// NewUploadSlots returns slots for limit uploads at once
+func NewUploadSlots(limit int) *UploadSlots {
+	return &UploadSlots{slots: make(chan struct{}, limit)}
+}
+
+// Uploads returns the process wide upload slots, MAX_CONCURRENT_UPLOADS of them. Background work of
+// uploads (ingesting the rows left past the deadline, async revalidations) takes a slot too. The slots
+// are made again when a new configuration (config.Set) changes the limit, the work holding a slot of
+// the previous ones releases it there.
+func Uploads() *UploadSlots {
+	limit := config.Get().MaxConcurrentUploads
+	uploadSlotsMu.Lock()
+	defer uploadSlotsMu.Unlock()
+	if uploadSlots == nil || cap(uploadSlots.slots) != limit {
+		uploadSlots = NewUploadSlots(limit)
+	}
+	return uploadSlots
+}
+
+// Acquire takes a slot, waiting up to timeout for one to free up. It returns ErrUploadSlotsFull when
+// none did, at once for a timeout of 0, or the context's error when it is done first. Every
+// successful Acquire must be paired with a Release.
+func (s *UploadSlots) Acquire(ctx context.Context, timeout time.Duration) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if timeout <= 0 {
+		return ErrUploadSlotsFull
+	}
+
+	s.queued.Add(1)
+	defer s.queued.Add(-1)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrUploadSlotsFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (s *UploadSlots) Release() {
+	<-s.slots
+}
+
+// Stats returns the number of slots, the uploads holding one and the uploads waiting for one
+func (s *UploadSlots) Stats() UploadSlotsStats {
+	return UploadSlotsStats{Limit: cap(s.slots), Active: len(s.slots), Queued: s.queued.Load()}
+}
@@ -0,0 +1,26 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"testing"
+)
+
+func TestUploads_FollowsConfig(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+
+	config.Set(config.FromMap(map[string]string{"MAX_CONCURRENT_UPLOADS": "3"}))
+	slots := Uploads()
+	if limit := slots.Stats().Limit; limit != 3 {
+		t.Errorf("Expected 3 slots, got %d", limit)
+	}
+	// The same limit keeps the slots and the uploads holding them
+	config.Set(config.FromMap(map[string]string{"MAX_CONCURRENT_UPLOADS": "3", "DATABASE": "other"}))
+	if Uploads() != slots {
+		t.Errorf("Expected the slots to be kept")
+	}
+	config.Set(config.FromMap(map[string]string{"MAX_CONCURRENT_UPLOADS": "5"}))
+	if limit := Uploads().Stats().Limit; limit != 5 {
+		t.Errorf("Expected 5 slots, got %d", limit)
+	}
+}
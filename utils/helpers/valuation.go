@@ -0,0 +1,133 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"stockbackend/config"
+	"stockbackend/types"
+)
+
+// Valuation verdicts
+const (
+	ValuationUndervalued   = "undervalued"
+	ValuationFairlyValued  = "fairly valued"
+	ValuationOvervalued    = "overvalued"
+	ValuationNotApplicable = "not applicable"
+)
+
+// peRatioLabel matches the PE row of a ratios table, screener's default ratios don't have one
+var peRatioLabel = regexp.MustCompile(`(?i)^\s*(price\s*to\s*earnings?|p\s*/\s*e|pe)(\s*ratio)?\s*\+?\s*$`)
+
+// minHistoricalPEYears is the fewest yearly PEs a historical range is built from
+const minHistoricalPEYears = 3
+
+// peerMedianPE is the PE of the median row of the peers table, or the median PE of the peers
+// (the stock's own row left out) when the table has no median row. Loss makers are left out.
+func peerMedianPE(stock map[string]interface{}) (float64, bool) {
+	peers, medianRow := SplitPeers(stock["peers"])
+	if value, ok := parsePeerNumber(medianRow["pe"]); ok && value > 0 {
+		return value, true
+	}
+
+	name, _ := stock["name"].(string)
+	var values []float64
+	for _, peer := range peers {
+		peerName, _ := peer["name"].(string)
+		if name != "" && NormalizeCompanyName(peerName) == NormalizeCompanyName(name) {
+			continue
+		}
+		if value, ok := parsePeerNumber(peer["pe"]); ok && value > 0 {
+			values = append(values, value)
+		}
+	}
+	if len(values) < minPercentilePeers {
+		return 0, false
+	}
+	return median(values), true
+}
+
+// historicalPE is the range of the yearly PEs of the ratios table, when it has a PE row with
+// at least minHistoricalPEYears positive values
+func historicalPE(stock map[string]interface{}) (types.PERange, bool) {
+	rows, ok := toTableRows(stock["ratios"])
+	if !ok {
+		return types.PERange{}, false
+	}
+	for _, row := range rows {
+		if !peRatioLabel.MatchString(row.Label) {
+			continue
+		}
+		var values []float64
+		for _, raw := range row.Values {
+			if value, ok := parsePeerNumber(raw); ok && value > 0 {
+				values = append(values, value)
+			}
+		}
+		if len(values) < minHistoricalPEYears {
+			return types.PERange{}, false
+		}
+		return types.PERange{Min: slices.Min(values), Max: slices.Max(values), Median: median(values)}, true
+	}
+	return types.PERange{}, false
+}
+
+// compareValuation returns -1 when the PE is at or below VALUATION_UNDERVALUED_RATIO of the
+// benchmark, 1 when at or above VALUATION_OVERVALUED_RATIO and 0 in between, with the reason
+func compareValuation(pe, benchmark float64, against string) (int, string) {
+	cfg := config.Get()
+	ratio := pe / benchmark
+	change := math.Round(math.Abs(ratio-1) * 100)
+	switch {
+	case ratio <= cfg.ValuationUndervaluedRatio:
+		return -1, fmt.Sprintf("PE %.1f is %.0f%% below %s of %.1f", pe, change, against, benchmark)
+	case ratio >= cfg.ValuationOvervaluedRatio:
+		return 1, fmt.Sprintf("PE %.1f is %.0f%% above %s of %.1f", pe, change, against, benchmark)
+	}
+	return 0, fmt.Sprintf("PE %.1f is in line with %s of %.1f", pe, against, benchmark)
+}
+
+// StockValuation classifies the stock's PE against the median PE of its peers and the median of
+// its own historical PE (from the ratios table, when it has a PE row). Each comparison counts as
+// cheap, in line or expensive; the verdict is the balance of both, so a stock cheap against its
+// peers but expensive against its history is fairly valued. A negative or unknown PE (loss
+// making) is not applicable, as is a stock with neither comparison available.
+func StockValuation(stock map[string]interface{}) types.Valuation {
+	valuation := types.Valuation{Verdict: ValuationNotApplicable, Reasons: []string{}}
+
+	pe, ok := parsePeerNumber(stock["stockPE"])
+	if !ok || pe <= 0 {
+		valuation.Reasons = append(valuation.Reasons, "PE is negative or not available, the company is likely loss making")
+		return valuation
+	}
+	valuation.PE = roundPtr(&pe)
+
+	balance, compared := 0, 0
+	if peerMedian, ok := peerMedianPE(stock); ok {
+		valuation.PeerMedianPE = roundPtr(&peerMedian)
+		signal, reason := compareValuation(pe, peerMedian, "the peer median")
+		balance += signal
+		compared++
+		valuation.Reasons = append(valuation.Reasons, reason)
+	}
+	if history, ok := historicalPE(stock); ok {
+		valuation.HistoricalPE = &types.PERange{Min: Round(history.Min), Max: Round(history.Max), Median: Round(history.Median)}
+		signal, reason := compareValuation(pe, history.Median, "its historical median")
+		balance += signal
+		compared++
+		valuation.Reasons = append(valuation.Reasons, reason)
+	}
+
+	switch {
+	case compared == 0:
+		valuation.Reasons = append(valuation.Reasons, "No peer or historical PE to compare with")
+	case balance < 0:
+		valuation.Verdict = ValuationUndervalued
+	case balance > 0:
+		valuation.Verdict = ValuationOvervalued
+	default:
+		valuation.Verdict = ValuationFairlyValued
+	}
+	return valuation
+}
@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"stockbackend/config"
+	"stockbackend/types"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// valuationStock is a stock with the PE, a peers table with its median row and a PE history
+func valuationStock(pe string, peerMedian string, history ...string) map[string]interface{} {
+	stock := map[string]interface{}{
+		"name":    "Example Ltd",
+		"stockPE": pe,
+		"peers": primitive.A{
+			bson.M{"name": "Alpha Ltd", "pe": "10"},
+			bson.M{"name": "Beta Ltd", "pe": "30"},
+			bson.M{PeerMedianMarker: "true", "company_count": "Median: 2 Co.", "pe": peerMedian},
+		},
+	}
+	if len(history) > 0 {
+		stock["ratios"] = []types.TableRow{
+			{Label: "ROCE %", Values: []string{"20", "21", "22"}},
+			{Label: "Price to Earning", Values: history},
+		}
+	}
+	return stock
+}
+
+func TestStockValuation(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{}))
+
+	tests := []struct {
+		name     string
+		stock    map[string]interface{}
+		expected string
+	}{
+		{"cheap against peers and history", valuationStock("12", "20", "18", "20", "22"), ValuationUndervalued},
+		{"expensive against peers and history", valuationStock("40", "20", "18", "20", "22"), ValuationOvervalued},
+		{"in line with both", valuationStock("21", "20", "18", "20", "22"), ValuationFairlyValued},
+		{"cheap against peers, expensive against history", valuationStock("15", "20", "8", "10", "12"), ValuationFairlyValued},
+		{"cheap against peers only", valuationStock("12", "20"), ValuationUndervalued},
+		{"loss making", valuationStock("-5", "20", "18", "20", "22"), ValuationNotApplicable},
+		{"no PE", valuationStock("", "20"), ValuationNotApplicable},
+		{"nothing to compare with", map[string]interface{}{"name": "Example Ltd", "stockPE": "15"}, ValuationNotApplicable},
+	}
+	for _, test := range tests {
+		valuation := StockValuation(test.stock)
+		if valuation.Verdict != test.expected {
+			t.Errorf("%s: expected %q, got %q (%v)", test.name, test.expected, valuation.Verdict, valuation.Reasons)
+		}
+		if len(valuation.Reasons) == 0 {
+			t.Errorf("%s: expected the reasons of the verdict", test.name)
+		}
+	}
+
+	valuation := StockValuation(valuationStock("12", "20", "18", "-3", "20", "22"))
+	if *valuation.PE != 12 || *valuation.PeerMedianPE != 20 {
+		t.Errorf("Expected the PE and the peer median, got %+v", valuation)
+	}
+	if valuation.HistoricalPE == nil || *valuation.HistoricalPE != (types.PERange{Min: 18, Max: 22, Median: 20}) {
+		t.Errorf("Expected the range of the positive historical PEs, got %+v", valuation.HistoricalPE)
+	}
+}
+
+func TestStockValuation_PeersWithoutMedian(t *testing.T) {
+	stock := map[string]interface{}{
+		"name":    "Example Ltd",
+		"stockPE": "40",
+		"peers": primitive.A{
+			bson.M{"name": "Example", "pe": "40"},
+			bson.M{"name": "Alpha Ltd", "pe": "10"},
+			bson.M{"name": "Beta Ltd", "pe": "20"},
+			bson.M{"name": "Gamma Ltd", "pe": "-8"},
+		},
+	}
+	valuation := StockValuation(stock)
+	if valuation.PeerMedianPE == nil || *valuation.PeerMedianPE != 15 || valuation.Verdict != ValuationOvervalued {
+		t.Errorf("Expected the median of the other profitable peers, got %+v", valuation)
+	}
+}
+
+func TestStockValuation_Thresholds(t *testing.T) {
+	previous := config.Get()
+	defer config.Set(previous)
+	config.Set(config.FromMap(map[string]string{"VALUATION_UNDERVALUED_RATIO": "0.5", "VALUATION_OVERVALUED_RATIO": "2"}))
+
+	if valuation := StockValuation(valuationStock("12", "20")); valuation.Verdict != ValuationFairlyValued {
+		t.Errorf("Expected a 40%% discount to be fairly valued with a 0.5 ratio, got %q", valuation.Verdict)
+	}
+}
@@ -0,0 +1,39 @@
+package helpers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PeersIDKey is the key FetchCompanyData returns the warehouse id of a company page under, the id
+// the peers API (FetchPeerData) knows it by
+const PeersIDKey = "peersId"
+
+// warehouseIDSelectors are the elements known to carry the warehouse id of a company page in their
+// data-warehouse-id attribute, in the order they are tried
+var warehouseIDSelectors = []string{"div[data-warehouse-id]", "[data-warehouse-id]"}
+
+// warehouseIDPatterns find the warehouse id in the page source when no element carries it: the
+// peers API URL, or a warehouse id in an inline script
+var warehouseIDPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`/api/company/(\d+)/peers/`),
+	regexp.MustCompile(`(?i)["']?warehouse[_-]?id["']?\s*[:=]\s*["']?(\d+)`),
+}
+
+// findWarehouseID returns the warehouse id the peers API knows a company page by, trying the
+// known locations in turn. It reports false when the page has none of them.
+func findWarehouseID(doc *goquery.Document, html []byte) (string, bool) {
+	for _, selector := range warehouseIDSelectors {
+		if id, ok := doc.Find(selector).First().Attr("data-warehouse-id"); ok && strings.TrimSpace(id) != "" {
+			return strings.TrimSpace(id), true
+		}
+	}
+	for _, pattern := range warehouseIDPatterns {
+		if match := pattern.FindSubmatch(html); match != nil {
+			return string(match[1]), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,117 @@
+package helpers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	ErrXLSXPasswordRequired = errors.New("workbook is password protected, a password is required")
+	ErrXLSXWrongPassword    = errors.New("the supplied workbook password is not correct")
+)
+
+// Encrypted workbooks are OLE compound files holding an EncryptionInfo stream
+var (
+	oleSignature         = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+	encryptionInfoStream = utf16LE("EncryptionInfo")
+)
+
+func utf16LE(s string) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(r), byte(r>>8))
+	}
+	return b
+}
+
+// IsEncryptedXLSX reports whether the workbook bytes are password protected
+func IsEncryptedXLSX(b []byte) bool {
+	return bytes.HasPrefix(b, oleSignature) && bytes.Contains(b, encryptionInfoStream)
+}
+
+// IsXLSXPasswordError reports whether err is caused by a missing or wrong workbook password
+func IsXLSXPasswordError(err error) bool {
+	return errors.Is(err, ErrXLSXPasswordRequired) || errors.Is(err, ErrXLSXWrongPassword)
+}
+
+// CheckXLSXPassword verifies that a protected workbook can be decrypted with the password,
+// without parsing it. Unprotected workbooks always pass, whatever the password.
+func CheckXLSXPassword(r io.Reader, password string) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("could not read workbook: %w", err)
+	}
+	if !IsEncryptedXLSX(b) {
+		return nil
+	}
+	if password == "" {
+		return ErrXLSXPasswordRequired
+	}
+	// A wrong password decrypts to garbage instead of failing, so check for the zip container
+	decrypted, err := excelize.Decrypt(b, &excelize.Options{Password: password})
+	if err != nil {
+		return ErrXLSXWrongPassword
+	}
+	if _, err := zip.NewReader(bytes.NewReader(decrypted), int64(len(decrypted))); err != nil {
+		return ErrXLSXWrongPassword
+	}
+	return nil
+}
+
+// SheetRows holds the rows of a single sheet, or the reason they could not be read
+type SheetRows struct {
+	Sheet string
+	Rows  [][]string
+	Err   error
+}
+
+// ReadXLSXSheets reads every sheet of a workbook. A corrupt sheet is reported in its
+// SheetRows.Err so the readable ones can still be processed, an error is only returned
+// when the workbook itself cannot be opened. Protected workbooks need the password in opts.
+func ReadXLSXSheets(r io.Reader, opts ...excelize.Options) ([]SheetRows, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read workbook: %w", err)
+	}
+
+	f, err := excelize.OpenReader(bytes.NewReader(b), opts...)
+	if err != nil {
+		if IsEncryptedXLSX(b) {
+			if len(opts) == 0 || opts[0].Password == "" {
+				return nil, ErrXLSXPasswordRequired
+			}
+			return nil, ErrXLSXWrongPassword
+		}
+		return nil, fmt.Errorf("could not open workbook: %w", err)
+	}
+	defer f.Close()
+
+	var sheets []SheetRows
+	for _, sheet := range f.GetSheetList() {
+		rows, err := f.GetRows(sheet)
+		if err != nil {
+			err = fmt.Errorf("could not read sheet: %w", err)
+		}
+		sheets = append(sheets, SheetRows{Sheet: sheet, Rows: rows, Err: err})
+	}
+	return sheets, nil
+}
+
+// StreamErrorEntry builds the entry streamed to the client when a file or sheet could not be processed
+func StreamErrorEntry(file string, sheet string, err error) map[string]interface{} {
+	entry := map[string]interface{}{
+		"type":  "error",
+		"file":  file,
+		"error": err.Error(),
+	}
+	if sheet != "" {
+		entry["sheet"] = sheet
+	}
+	return entry
+}
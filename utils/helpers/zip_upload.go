@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrZipInvalid   = errors.New("could not open zip archive")
+	ErrZipTooLarge  = errors.New("zip archive exceeds the maximum uncompressed size")
+	ErrZipTooMany   = errors.New("zip archive contains more xlsx files than an upload accepts")
+	ErrZipEmpty     = errors.New("zip archive contains no xlsx files")
+	ErrZipEntryType = errors.New("only xlsx files are accepted inside a zip archive")
+)
+
+// ZipEntry is a spreadsheet extracted from an uploaded zip, or the reason it was rejected
+type ZipEntry struct {
+	Name string
+	Data []byte
+	Err  error
+}
+
+// IsZipUpload reports whether an uploaded file is a zip archive of spreadsheets (xlsx files are
+// zip containers too, so only the extension tells them apart)
+func IsZipUpload(filename string) bool {
+	return strings.EqualFold(path.Ext(filename), ".zip")
+}
+
+// isZipMetadata reports entries archivers add on their own (macOS resource forks, Finder and
+// Windows folder files), they are skipped rather than rejected
+func isZipMetadata(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, ".") || strings.EqualFold(base, "Thumbs.db")
+}
+
+// uniqueZipName returns the name, or the first free one of its " (2)", " (3)"... variants when it is
+// taken, and marks it taken. Names are compared case insensitively like most file systems do.
+func uniqueZipName(name string, taken map[string]bool) string {
+	unique := name
+	ext := path.Ext(name)
+	for n := 2; taken[strings.ToLower(unique)]; n++ {
+		unique = strings.TrimSuffix(name, ext) + " (" + strconv.Itoa(n) + ")" + ext
+	}
+	taken[strings.ToLower(unique)] = true
+	return unique
+}
+
+// ExtractZipXLSX reads the xlsx files of a zip archive in memory, named after their file name
+// within the archive (a name already taken gets a " (2)" suffix, or the next free one). Other files,
+// macro-enabled workbooks included, are returned with ErrZipEntryType, folders and archiver metadata
+// are skipped. The archive is rejected with ErrZipTooLarge once its entries add up to more than
+// maxUncompressed bytes, whatever sizes their headers declare, so a zip bomb is never inflated past
+// the limit, and with ErrZipTooMany when it holds more than maxFiles spreadsheets.
+func ExtractZipXLSX(data []byte, maxUncompressed int64, maxFiles int) ([]ZipEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrZipInvalid, err)
+	}
+
+	var total uint64
+	for _, file := range reader.File {
+		total += file.UncompressedSize64
+	}
+	if total > uint64(maxUncompressed) {
+		return nil, ErrZipTooLarge
+	}
+
+	entries := []ZipEntry{}
+	taken := map[string]bool{}
+	remaining := maxUncompressed
+	spreadsheets := 0
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || isZipMetadata(file.Name) {
+			continue
+		}
+		name := path.Base(strings.ReplaceAll(file.Name, "\\", "/"))
+		if !strings.EqualFold(path.Ext(name), ".xlsx") {
+			entries = append(entries, ZipEntry{Name: name, Err: ErrZipEntryType})
+			continue
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			entries = append(entries, ZipEntry{Name: name, Err: fmt.Errorf("could not read entry: %w", err)})
+			continue
+		}
+		// The declared sizes can't be trusted, read at most one byte past what is left of the limit
+		b, err := io.ReadAll(io.LimitReader(content, remaining+1))
+		content.Close()
+		if int64(len(b)) > remaining {
+			return nil, ErrZipTooLarge
+		}
+		remaining -= int64(len(b))
+		if err != nil {
+			entries = append(entries, ZipEntry{Name: name, Err: fmt.Errorf("could not read entry: %w", err)})
+			continue
+		}
+		if !bytes.HasPrefix(b, xlsxSignature) && !bytes.HasPrefix(b, oleSignature) {
+			entries = append(entries, ZipEntry{Name: name, Err: fmt.Errorf("%w: the content isn't an xlsx file", ErrZipEntryType)})
+			continue
+		}
+
+		if spreadsheets == maxFiles {
+			return nil, ErrZipTooMany
+		}
+		entries = append(entries, ZipEntry{Name: uniqueZipName(name, taken), Data: b})
+		spreadsheets++
+	}
+	if spreadsheets == 0 {
+		return entries, ErrZipEmpty
+	}
+	return entries, nil
+}
@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func sheetWorkbook(t *testing.T, instrument string) []byte {
+	workbook := excelize.NewFile()
+	workbook.SetCellValue("Sheet1", "A1", "Name of the Instrument")
+	workbook.SetCellValue("Sheet1", "A2", instrument)
+	var buf bytes.Buffer
+	if err := workbook.Write(&buf); err != nil {
+		t.Fatalf("Error writing fixture: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string][]byte, order []string) []byte {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, name := range order {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Error writing zip fixture: %v", err)
+		}
+		w.Write(files[name])
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Error writing zip fixture: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractZipXLSX(t *testing.T) {
+	files := map[string][]byte{
+		"2024-08.xlsx":            sheetWorkbook(t, "Infosys Limited"),
+		"backfill/2024-09.xlsx":   sheetWorkbook(t, "HDFC Bank Limited"),
+		"notes.txt":               []byte("August and September disclosures"),
+		"__MACOSX/._2024-08.xlsx": []byte("resource fork"),
+		"backfill/":               nil,
+		"renamed.xlsx":            []byte("Name,Quantity\n"),
+		"older/2024-08.xlsx":      sheetWorkbook(t, "ITC Limited"),
+		"backfill/.DS_Store":      []byte("finder"),
+		"2024-08 (2).xlsx":        sheetWorkbook(t, "Wipro Limited"),
+		"macros.xlsm":             sheetWorkbook(t, "TCS Limited"),
+	}
+	order := []string{"2024-08.xlsx", "backfill/", "backfill/2024-09.xlsx", "notes.txt", "__MACOSX/._2024-08.xlsx", "renamed.xlsx", "older/2024-08.xlsx", "backfill/.DS_Store", "2024-08 (2).xlsx", "macros.xlsm"}
+
+	entries, err := ExtractZipXLSX(zipArchive(t, files, order), 1<<20, 10)
+	if err != nil {
+		t.Fatalf("Expected the archive to be extracted, got %v", err)
+	}
+
+	var names, rejected []string
+	for _, entry := range entries {
+		if entry.Err != nil {
+			if !errors.Is(entry.Err, ErrZipEntryType) {
+				t.Errorf("Expected %s to be rejected as not xlsx, got %v", entry.Name, entry.Err)
+			}
+			rejected = append(rejected, entry.Name)
+			continue
+		}
+		names = append(names, entry.Name)
+		sheets, err := ReadXLSXSheets(bytes.NewReader(entry.Data))
+		if err != nil || len(sheets[0].Rows) != 2 {
+			t.Errorf("Expected %s to be a readable workbook, got %v", entry.Name, err)
+		}
+	}
+	// The renamed duplicate takes " (2)", the file really named so gets a suffix of its own
+	if expected := []string{"2024-08.xlsx", "2024-09.xlsx", "2024-08 (2).xlsx", "2024-08 (2) (2).xlsx"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected the spreadsheets %v, got %v", expected, names)
+	}
+	if expected := []string{"notes.txt", "renamed.xlsx", "macros.xlsm"}; !reflect.DeepEqual(rejected, expected) {
+		t.Errorf("Expected the junk files %v to be rejected, got %v", expected, rejected)
+	}
+}
+
+func TestExtractZipXLSX_Errors(t *testing.T) {
+	workbook := sheetWorkbook(t, "Infosys Limited")
+
+	if _, err := ExtractZipXLSX([]byte("not a zip"), 1<<20, 10); !errors.Is(err, ErrZipInvalid) {
+		t.Errorf("Expected ErrZipInvalid, got %v", err)
+	}
+
+	junk := zipArchive(t, map[string][]byte{"notes.txt": []byte("notes")}, []string{"notes.txt"})
+	if entries, err := ExtractZipXLSX(junk, 1<<20, 10); !errors.Is(err, ErrZipEmpty) || len(entries) != 1 {
+		t.Errorf("Expected ErrZipEmpty with the rejected entry, got %v %v", entries, err)
+	}
+
+	two := zipArchive(t, map[string][]byte{"a.xlsx": workbook, "b.xlsx": workbook}, []string{"a.xlsx", "b.xlsx"})
+	if _, err := ExtractZipXLSX(two, int64(2*len(workbook)-1), 10); !errors.Is(err, ErrZipTooLarge) {
+		t.Errorf("Expected ErrZipTooLarge, got %v", err)
+	}
+
+	if _, err := ExtractZipXLSX(two, 1<<20, 1); !errors.Is(err, ErrZipTooMany) {
+		t.Errorf("Expected ErrZipTooMany, got %v", err)
+	}
+	if entries, err := ExtractZipXLSX(two, 1<<20, 2); err != nil || len(entries) != 2 {
+		t.Errorf("Expected the 2 spreadsheets within the file limit, got %v %v", entries, err)
+	}
+
+	// Highly compressible: a few KB of zip inflating to 10 MB
+	bomb := zipArchive(t, map[string][]byte{"bomb.xlsx": append([]byte("PK\x03\x04"), make([]byte, 10<<20)...)}, []string{"bomb.xlsx"})
+	if len(bomb) > 1<<20 {
+		t.Fatalf("Expected the bomb fixture to compress, got %d bytes", len(bomb))
+	}
+	if _, err := ExtractZipXLSX(bomb, 1<<20, 10); !errors.Is(err, ErrZipTooLarge) {
+		t.Errorf("Expected the zip bomb to be rejected, got %v", err)
+	}
+}
+
+func TestIsZipUpload(t *testing.T) {
+	for name, expected := range map[string]bool{"backfill.zip": true, "BACKFILL.ZIP": true, "holdings.xlsx": false, "zip": false} {
+		if IsZipUpload(name) != expected {
+			t.Errorf("Expected IsZipUpload(%q) to be %v", name, expected)
+		}
+	}
+}
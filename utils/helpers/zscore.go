@@ -0,0 +1,106 @@
+package helpers
+
+import (
+	"stockbackend/types"
+	"strings"
+)
+
+// Altman Z-score zones
+const (
+	ZScoreSafe          = "safe"
+	ZScoreGrey          = "grey"
+	ZScoreDistress      = "distress"
+	ZScoreNotApplicable = "not applicable"
+)
+
+// Zone bounds of the original Altman Z-score for listed manufacturers
+const (
+	zScoreSafeAbove     = 2.99
+	zScoreDistressBelow = 1.81
+)
+
+// financialSectorWords mark the sectors of banks, lenders and insurers, whose balance sheets the
+// Z-score wasn't built for
+var financialSectorWords = []string{"bank", "financ", "insurance", "nbfc"}
+
+// isFinancialCompany reports whether a company is a bank, lender or insurer, by its sector or by
+// screener's bank layout of the profit and loss statement (Revenue and Financing Profit rows)
+func isFinancialCompany(stock map[string]interface{}) bool {
+	if sector, _ := stock["sector"].(string); sector != "" {
+		sector = NormalizeSector(sector)
+		for _, word := range financialSectorWords {
+			if strings.Contains(sector, word) {
+				return true
+			}
+		}
+	}
+	_, err := getNestedArrayField(stock, "profitLoss", "Financing Profit")
+	return err == nil
+}
+
+// generateZScore computes the Altman Z-score from the latest yearly statements and the market cap:
+//
+//	Z = 1.2 working capital/total assets + 1.4 retained earnings/total assets + 3.3 EBIT/total assets
+//	  + 0.6 market cap/total liabilities + 1.0 sales/total assets
+//
+// Screener has no current assets and liabilities rows, Other Assets and Other Liabilities stand in
+// for them like in the F-Score. Reserves are the retained earnings, EBIT is the profit before tax
+// with the interest added back and the liabilities are the borrowings and other liabilities.
+// The zone is safe above 2.99, distress below 1.81 and grey in between. Financial companies,
+// whose deposits and loans make the ratios meaningless, and companies missing an input (or with no
+// assets or liabilities) are not applicable, with a 0 score.
+func generateZScore(stock map[string]interface{}) (float64, string) {
+	if isFinancialCompany(stock) {
+		return 0, ZScoreNotApplicable
+	}
+
+	inputs := map[string]float64{}
+	for _, row := range []struct{ section, label string }{
+		{"balanceSheet", "Total Assets"},
+		{"balanceSheet", "Other Assets +"},
+		{"balanceSheet", "Other Liabilities +"},
+		{"balanceSheet", "Borrowings +"},
+		{"balanceSheet", "Reserves"},
+		{"profitLoss", "Profit before tax"},
+		{"profitLoss", "Interest"},
+		{"profitLoss", "Sales +"},
+	} {
+		value, ok := latestAnnualValue(stock, row.section, row.label)
+		if !ok {
+			return 0, ZScoreNotApplicable
+		}
+		inputs[row.label] = value
+	}
+	marketCap, ok := parsePeerNumber(stock["marketCap"])
+	totalAssets := inputs["Total Assets"]
+	totalLiabilities := inputs["Borrowings +"] + inputs["Other Liabilities +"]
+	if !ok || marketCap <= 0 || totalAssets <= 0 || totalLiabilities <= 0 {
+		return 0, ZScoreNotApplicable
+	}
+
+	workingCapital := inputs["Other Assets +"] - inputs["Other Liabilities +"]
+	ebit := inputs["Profit before tax"] + inputs["Interest"]
+	z := Round(1.2*workingCapital/totalAssets +
+		1.4*inputs["Reserves"]/totalAssets +
+		3.3*ebit/totalAssets +
+		0.6*marketCap/totalLiabilities +
+		1.0*inputs["Sales +"]/totalAssets)
+
+	switch {
+	case z > zScoreSafeAbove:
+		return z, ZScoreSafe
+	case z < zScoreDistressBelow:
+		return z, ZScoreDistress
+	}
+	return z, ZScoreGrey
+}
+
+// GenerateZScore returns the Altman Z-score of a company as it is stored on its document, see
+// generateZScore
+func GenerateZScore(stock map[string]interface{}) *types.ZScore {
+	z, zone := generateZScore(stock)
+	if zone == ZScoreNotApplicable {
+		return &types.ZScore{Zone: zone}
+	}
+	return &types.ZScore{Value: &z, Zone: zone}
+}
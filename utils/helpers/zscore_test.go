@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"stockbackend/types"
+	"testing"
+)
+
+// zScoreStock is a manufacturer's statements, the earlier year and the TTM column check that the
+// latest full year is read
+func zScoreStock(marketCap string, otherAssets, reserves, profitBeforeTax, sales string) map[string]interface{} {
+	return map[string]interface{}{
+		"marketCap": marketCap,
+		"balanceSheet": []types.TableRow{
+			row("Reserves", "1", reserves),
+			row("Borrowings +", "1", "300"),
+			row("Other Liabilities +", "1", "200"),
+			row("Other Assets +", "1", otherAssets),
+			row("Total Assets", "1", "1,000"),
+		},
+		"profitLoss": []types.TableRow{
+			row("Sales +", "1", sales, "9,999"),
+			row("Interest", "1", "40", "0"),
+			row("Profit before tax", "1", profitBeforeTax, "9,999"),
+		},
+		"profitLossHeaders": []string{"Mar 2023", "Mar 2024", "TTM"},
+	}
+}
+
+func TestGenerateZScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		stock map[string]interface{}
+		z     float64
+		zone  string
+	}{
+		// 1.2*200/1000 + 1.4*350/1000 + 3.3*160/1000 + 0.6*2000/500 + 1500/1000
+		{"safe", zScoreStock("2,000", "400", "350", "120", "1,500"), 5.16, ZScoreSafe},
+		// 0.24 + 0.49 + 0.528 + 0.6*500/500 + 600/1000
+		{"grey", zScoreStock("500", "400", "350", "120", "600"), 2.46, ZScoreGrey},
+		// 1.2*-50/1000 + 1.4*-100/1000 + 3.3*-160/1000 + 0.6*200/500 + 800/1000
+		{"distress", zScoreStock("200", "150", "-100", "-200", "800"), 0.31, ZScoreDistress},
+		{"no market cap", zScoreStock("", "400", "350", "120", "1,500"), 0, ZScoreNotApplicable},
+		{"blank sales", zScoreStock("2,000", "400", "350", "120", ""), 0, ZScoreNotApplicable},
+		{"no statements", map[string]interface{}{"marketCap": "2,000"}, 0, ZScoreNotApplicable},
+	}
+	for _, test := range tests {
+		z, zone := generateZScore(test.stock)
+		if z != test.z || zone != test.zone {
+			t.Errorf("%s: expected (%v, %s), got (%v, %s)", test.name, test.z, test.zone, z, zone)
+		}
+	}
+
+	// No liabilities leaves the market cap ratio undefined
+	stock := zScoreStock("2,000", "400", "350", "120", "1,500")
+	stock["balanceSheet"] = append(stock["balanceSheet"].([]types.TableRow)[2:], row("Borrowings +", "0"), row("Other Liabilities +", "0"))
+	if _, zone := generateZScore(stock); zone != ZScoreNotApplicable {
+		t.Errorf("Expected no liabilities to be not applicable, got %s", zone)
+	}
+}
+
+func TestGenerateZScore_FinancialCompanies(t *testing.T) {
+	bank := zScoreStock("2,000", "400", "350", "120", "1,500")
+	bank["sector"] = "Banks"
+	if z, zone := generateZScore(bank); z != 0 || zone != ZScoreNotApplicable {
+		t.Errorf("Expected a bank to be not applicable, got (%v, %s)", z, zone)
+	}
+
+	// Screener's bank layout gives a lender away without a sector
+	lender := zScoreStock("2,000", "400", "350", "120", "1,500")
+	lender["profitLoss"] = append(lender["profitLoss"].([]types.TableRow), row("Financing Profit", "1", "90", "95"))
+	if _, zone := generateZScore(lender); zone != ZScoreNotApplicable {
+		t.Errorf("Expected a lender to be not applicable, got %s", zone)
+	}
+
+	if score := GenerateZScore(lender); score.Value != nil || score.Zone != ZScoreNotApplicable {
+		t.Errorf("Expected no value for a not applicable score, got %v", score)
+	}
+	if score := GenerateZScore(zScoreStock("2,000", "400", "350", "120", "1,500")); score.Value == nil || *score.Value != 5.16 || score.Zone != ZScoreSafe {
+		t.Errorf("Expected the stored score to carry its value, got %+v", score)
+	}
+}